@@ -36,6 +36,11 @@ func init() {
 	cmd.Perform("purge", &compute.SDnsZoneIdOptions{})
 	cmd.Perform("add-vpcs", &compute.DnsZoneAddVpcsOptions{})
 	cmd.Perform("remove-vpcs", &compute.DnsZoneRemoveVpcsOptions{})
+	cmd.Perform("enable-dnssec", &compute.DnsZoneEnableDnssecOptions{})
+	cmd.Perform("disable-dnssec", &compute.SDnsZoneIdOptions{})
+	cmd.Perform("rollover-dnssec-key", &compute.DnsZoneRolloverDnssecKeyOptions{})
+	cmd.Perform("retire-dnssec-key", &compute.DnsZoneRetireDnssecKeyOptions{})
+	cmd.Get("dnssec", &compute.SDnsZoneIdOptions{})
 	cmd.GetWithCustomShow("exports", func(result jsonutils.JSONObject) {
 		rr := make(map[string]string)
 		err := result.Unmarshal(&rr)
@@ -30,4 +30,5 @@ func init() {
 	cmd.Perform("public", &options.LoadbalancerCertificatePublicOptions{})
 	cmd.Perform("private", &options.LoadbalancerCertificateIdOptions{})
 	cmd.Perform("syncstatus", &options.LoadbalancerCertificateIdOptions{})
+	cmd.Perform("acme-renew", &options.LoadbalancerCertificateIdOptions{})
 }
@@ -35,6 +35,7 @@ func init() {
 	cmd.Perform("private", &options.VpcIdOptions{})
 	cmd.Perform("public", &options.BasePublicOptions{})
 	cmd.Perform("change-owner", &options.VpcChangeOwnerOptions{})
+	cmd.Perform("accept-peering", &options.VpcAcceptPeeringOptions{})
 	cmd.Get("vpc-change-owner-candidate-domains", &options.VpcIdOptions{})
 	cmd.Get("topology", &options.VpcIdOptions{})
 
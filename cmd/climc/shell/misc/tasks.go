@@ -86,6 +86,19 @@ func init() {
 			printObject(result)
 			return nil
 		})
+
+		type TaskCancelOptions struct {
+			ID string `help:"ID of the task"`
+			apis.TaskCancelInput
+		}
+		R(&TaskCancelOptions{}, fmt.Sprintf("%s-task-cancel", c.service), "Cancel an unfinished task", func(s *mcclient.ClientSession, args *TaskCancelOptions) error {
+			result, err := c.manager.PerformAction(s, args.ID, "cancel", jsonutils.Marshal(args.TaskCancelInput))
+			if err != nil {
+				return err
+			}
+			printObject(result)
+			return nil
+		})
 	}
 
 	/*type TaskListOptions struct {
@@ -146,6 +146,7 @@ var (
 func init() {
 	cmd := NewResourceCmd(modules.CommonAlerts)
 	cmd.Create(new(options.CommonAlertCreateOptions))
+	cmd.CreateWithKeyword("create-promql", new(options.CommonAlertPromQLCreateOptions))
 	cmd.List(new(options.CommonAlertListOptions))
 	cmd.Show(new(options.CommonAlertShowOptions))
 	cmd.Perform("enable", &options.CommonAlertShowOptions{})
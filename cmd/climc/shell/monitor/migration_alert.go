@@ -25,4 +25,5 @@ func init() {
 	cmd.Show(new(options.MigrationAlertShowOptions))
 	cmd.Create(new(options.MigrationAlertCreateOptions))
 	cmd.Delete(new(options.MigrationAlertShowOptions))
+	cmd.Perform("apply-plan", new(options.MigrationAlertShowOptions))
 }
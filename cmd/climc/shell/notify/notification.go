@@ -86,9 +86,10 @@ func init() {
 	type NotificationListInput struct {
 		options.BaseListOptions
 
-		ContactType string `help:"contact_type"`
-		ReceiverId  string `help:"receiver_id"`
-		TopicType   string `help:"topic type"`
+		ContactType  string `help:"contact_type"`
+		ReceiverId   string `help:"receiver_id"`
+		TopicType    string `help:"topic type"`
+		Acknowledged *bool  `help:"filter notifications acknowledged or not"`
 	}
 	R(&NotificationListInput{}, "notify-list", "List notify message", func(s *mcclient.ClientSession, args *NotificationListInput) error {
 		params, err := options.ListStructToParams(args)
@@ -102,6 +103,14 @@ func init() {
 		printList(ret, modules.Notification.GetColumns(s))
 		return nil
 	})
+	R(&NotificationInput{}, "notify-acknowledge", "Acknowledge a notify message and stop its escalation", func(s *mcclient.ClientSession, args *NotificationInput) error {
+		ret, err := modules.Notification.PerformAction(s, args.ID, "acknowledge", nil)
+		if err != nil {
+			return err
+		}
+		printObject(ret)
+		return nil
+	})
 	type NotificationEventInput struct {
 		AdvanceDays  int
 		Event        string
@@ -0,0 +1,60 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"fmt"
+	"os"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/streamutils"
+
+	"yunion.io/x/onecloud/cmd/climc/shell"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules/webconsole"
+	options "yunion.io/x/onecloud/pkg/mcclient/options/webconsole"
+)
+
+func init() {
+	cmd := shell.NewResourceCmd(webconsole.SessionRecordings).WithKeyword("webconsole-session-recording")
+	cmd.List(new(options.SessionRecordingListOptions))
+	cmd.Show(new(options.SessionRecordingIdOptions))
+	cmd.Delete(new(options.SessionRecordingIdOptions))
+	cmd.Get("replay", new(options.SessionRecordingReplayOptions))
+
+	R(&options.SessionRecordingDownloadOptions{}, "webconsole-session-recording-download", "Download asciicast file of a webconsole session recording", func(s *mcclient.ClientSession, args *options.SessionRecordingDownloadOptions) error {
+		reader, err := webconsole.SessionRecordings.Download(s, args.ID)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		output := args.Output
+		if len(output) == 0 {
+			output = fmt.Sprintf("%s.cast", args.ID)
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.Wrapf(err, "create %s", output)
+		}
+		defer f.Close()
+		_, err = streamutils.StreamPipe(reader, f, false, nil)
+		if err != nil {
+			return errors.Wrap(err, "save recording")
+		}
+		fmt.Printf("saved to %s\n", output)
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/cmd/climc/shell"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modules/webconsole"
+	options "yunion.io/x/onecloud/pkg/mcclient/options/webconsole"
+)
+
+func init() {
+	R(&options.SessionListOptions{}, "webconsole-session-list", "List live webconsole terminal sessions", func(s *mcclient.ClientSession, args *options.SessionListOptions) error {
+		params, err := args.Params()
+		if err != nil {
+			return err
+		}
+		ret, err := webconsole.WebConsole.ListSessions(s, params)
+		if err != nil {
+			return err
+		}
+		shell.PrintList(ret, []string{"id", "protocol", "obj_name", "login_user", "user", "tenant", "connected_at"})
+		return nil
+	})
+
+	doAction := func(s *mcclient.ClientSession, id, action string, args interface {
+		Params() (jsonutils.JSONObject, error)
+	}) error {
+		var params jsonutils.JSONObject
+		if args != nil {
+			var err error
+			params, err = args.Params()
+			if err != nil {
+				return err
+			}
+		}
+		ret, err := webconsole.WebConsole.DoSessionAction(s, id, action, params)
+		if err != nil {
+			return err
+		}
+		if ret != nil {
+			shell.PrintObject(ret)
+		}
+		return nil
+	}
+
+	R(&options.SessionShareOptions{}, "webconsole-session-share", "Invite a user to watch or co-type a webconsole session", func(s *mcclient.ClientSession, args *options.SessionShareOptions) error {
+		return doAction(s, args.ID, "share", args)
+	})
+
+	R(&options.SessionUnshareOptions{}, "webconsole-session-unshare", "Revoke the invitation of a webconsole session", func(s *mcclient.ClientSession, args *options.SessionUnshareOptions) error {
+		return doAction(s, args.ID, "unshare", args)
+	})
+
+	R(&options.SessionAttachOptions{}, "webconsole-session-attach", "Attach a shared webconsole session", func(s *mcclient.ClientSession, args *options.SessionAttachOptions) error {
+		return doAction(s, args.ID, "attach", args)
+	})
+
+	R(&options.SessionMessageOptions{}, "webconsole-session-message", "Send a message to a webconsole session", func(s *mcclient.ClientSession, args *options.SessionMessageOptions) error {
+		return doAction(s, args.ID, "message", args)
+	})
+
+	R(&options.SessionIdOptions{}, "webconsole-session-terminate", "Forcibly terminate a webconsole session", func(s *mcclient.ClientSession, args *options.SessionIdOptions) error {
+		return doAction(s, args.ID, "terminate", nil)
+	})
+}
@@ -28,6 +28,7 @@ import (
 	"yunion.io/x/onecloud/pkg/apigateway/constants"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
 )
 
 func Base64UrlEncode(data []byte) string {
@@ -132,6 +133,9 @@ func FetchAuthToken(f func(context.Context, http.ResponseWriter, *http.Request))
 			httperrors.InvalidCredentialError(ctx, w, "No token in header: %v", err)
 			return
 		}
+		if !auth.CheckRateLimit(ctx, w, r, auth.FetchUserCredential(ctx, nil)) {
+			return
+		}
 		f(ctx, w, r)
 	}
 }
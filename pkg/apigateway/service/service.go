@@ -58,6 +58,7 @@ func StartService() {
 
 	if !options.Options.DisableReporting {
 		cron := cronman.InitCronJobManager(true, 1)
+		cronman.AddCronJobHandler("", serviceApp.Application)
 		rand.Seed(time.Now().Unix())
 		cron.AddJobEveryFewDays("AutoReport", 1, rand.Intn(23), rand.Intn(59), 0, report.Report, true)
 		go cron.Start()
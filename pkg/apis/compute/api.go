@@ -461,6 +461,8 @@ type ServerCreateInput struct {
 	// 虚拟机内存大小,单位Mb,若未指定instance_type,此参数为必传项
 	VmemSize       int  `json:"vmem_size"`
 	EnableMemclean bool `json:"enable_memclean"`
+	// 为kvm虚拟机添加virtio-balloon设备,宿主机内存紧张时可回收空闲虚拟机的内存
+	EnableMemoryBalloon bool `json:"enable_memory_balloon"`
 
 	// 虚拟机Cpu大小,若未指定instance_type,此参数为必传项
 	// default: 1
@@ -48,6 +48,25 @@ const (
 	BACKUP_NOT_EXIST = "not_exist"
 )
 
+const (
+	// 全量备份
+	DISK_BACKUP_MODE_FULL = "full"
+	// 增量备份, 相对于上一个备份的变化
+	DISK_BACKUP_MODE_INCREMENTAL = "incremental"
+	// 差异备份, 相对于全量备份的变化
+	DISK_BACKUP_MODE_DIFFERENTIAL = "differential"
+
+	// name of the persistent dirty bitmap tracking the changes of a disk
+	// since its last backup
+	DISK_BACKUP_BITMAP_NAME = "onecloud-backup"
+)
+
+var DISK_BACKUP_MODES = []string{
+	DISK_BACKUP_MODE_FULL,
+	DISK_BACKUP_MODE_INCREMENTAL,
+	DISK_BACKUP_MODE_DIFFERENTIAL,
+}
+
 const (
 	BackupStorageOffline = "backup storage offline"
 )
@@ -117,6 +136,8 @@ type DiskBackupDetails struct {
 	BackupStorageName string `json:"backup_storage_name"`
 	// description: 是否是子备份
 	IsSubBackup bool `json:"is_sub_backup"`
+	// description: name of the parent backup in the backup chain
+	ParentBackupName string `json:"parent_backup_name"`
 
 	SDiskBackup
 }
@@ -142,6 +163,14 @@ type DiskBackupCreateInput struct {
 	// swagger:ignore
 	ManagerId   string                `json:"manager_id"`
 	BackupAsTar *DiskBackupAsTarInput `json:"backup_as_tar"`
+
+	// description: backup mode, full, incremental or differential, incremental and differential backups
+	//              are only supported for running kvm guests, a full backup is taken instead if no usable
+	//              backup chain exists or the chain should be rebased. An explicit full backup of the disk
+	//              of a running kvm guest starts a new backup chain, a standalone full backup is taken if
+	//              the mode is not specified
+	// enum: ["full", "incremental", "differential"]
+	BackupMode string `json:"backup_mode"`
 }
 
 type DiskBackupRecoveryInput struct {
@@ -315,6 +315,9 @@ type DiskAllocateFromBackupInput struct {
 	BackupStorageAccessInfo *jsonutils.JSONDict
 	DiskConfig              *DiskConfig           `json:"disk_config"`
 	BackupAsTar             *DiskBackupAsTarInput `json:"backup_as_tar"`
+	// backups the backup depends on, from the full backup to the parent,
+	// empty for a full backup
+	BackupChain []string `json:"backup_chain"`
 }
 
 type DiskDeleteInput struct {
@@ -21,13 +21,27 @@ import (
 
 	"yunion.io/x/onecloud/pkg/apis"
 	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/util/dnsutils"
 )
 
 const (
 	DNS_RECORDSET_STATUS_AVAILABLE = compute.DNS_RECORDSET_STATUS_AVAILABLE
 	DNS_RECORDSET_STATUS_CREATING  = apis.STATUS_CREATING
+
+	DNS_TYPE_SVCB  = dnsutils.DnsTypeSVCB
+	DNS_TYPE_HTTPS = dnsutils.DnsTypeHTTPS
+
+	// 本地区域的解析视图, 按请求来源所在的vpc解析, policy_value为逗号分隔的vpc id
+	DNS_POLICY_TYPE_BY_VPC = "ByVpc"
 )
 
+// 本地区域支持的解析线路类型, IpRange按请求来源IP解析, policy_value为逗号分隔的CIDR
+var LOCAL_DNS_POLICY_TYPES = []string{
+	string(cloudprovider.DnsPolicyTypeSimple),
+	string(cloudprovider.DnsPolicyTypeIpRange),
+	DNS_POLICY_TYPE_BY_VPC,
+}
+
 type DnsRecordCreateInput struct {
 	apis.EnabledStatusStandaloneResourceCreateInput
 
@@ -48,6 +62,9 @@ type DnsRecordUpdateInput struct {
 	DnsValue   string `json:"dns_value"`
 	TTL        *int64 `json:"ttl"`
 	MxPriority *int64 `json:"mx_priority"`
+
+	PolicyType  string `json:"policy_type"`
+	PolicyValue string `json:"policy_value"`
 }
 
 type DnsRecordDetails struct {
@@ -92,6 +109,11 @@ func (record *SDnsRecord) ValidateDnsrecordValue() error {
 		if !regutils.MatchDomainName(record.DnsValue) {
 			return httperrors.NewInputParameterError("invalid domain %s for CNAME record", record.DnsValue)
 		}
+	case cloudprovider.DnsTypeCAA, cloudprovider.DnsTypeNAPTR, DNS_TYPE_SVCB, DNS_TYPE_HTTPS:
+		err := dnsutils.ValidateValue(record.DnsType, record.DnsValue)
+		if err != nil {
+			return httperrors.NewInputParameterError("invalid value %s for %s record: %v", record.DnsValue, record.DnsType, err)
+		}
 	}
 	return nil
 }
@@ -100,4 +122,7 @@ type SDnsResolveResult struct {
 	DnsValue string `json:"dns_value"`
 	TTL      int64  `json:"ttl"`
 	DnsName  string `json:"dns_name"`
+
+	PolicyType  string `json:"policy_type"`
+	PolicyValue string `json:"policy_value"`
 }
@@ -15,6 +15,8 @@
 package compute
 
 import (
+	"time"
+
 	"yunion.io/x/cloudmux/pkg/apis/compute"
 
 	"yunion.io/x/onecloud/pkg/apis"
@@ -97,3 +99,71 @@ type DnsZoneRemoveVpcsInput struct {
 
 type DnsZonePurgeInput struct {
 }
+
+const (
+	DNSSEC_ALGORITHM_RSASHA256       = "RSASHA256"
+	DNSSEC_ALGORITHM_ECDSAP256SHA256 = "ECDSAP256SHA256"
+	DNSSEC_ALGORITHM_ECDSAP384SHA384 = "ECDSAP384SHA384"
+	DNSSEC_ALGORITHM_ED25519         = "ED25519"
+
+	DNSSEC_KEY_TYPE_KSK = "ksk"
+	DNSSEC_KEY_TYPE_ZSK = "zsk"
+
+	DNSSEC_KEY_STATE_PUBLISHED = "published" // 已发布, 未用于签名
+	DNSSEC_KEY_STATE_ACTIVE    = "active"    // 用于签名
+	DNSSEC_KEY_STATE_RETIRED   = "retired"   // 已停止签名, 仍发布
+	DNSSEC_KEY_STATE_REMOVED   = "removed"   // 已移除
+)
+
+var DNSSEC_ALGORITHMS = []string{
+	DNSSEC_ALGORITHM_RSASHA256,
+	DNSSEC_ALGORITHM_ECDSAP256SHA256,
+	DNSSEC_ALGORITHM_ECDSAP384SHA384,
+	DNSSEC_ALGORITHM_ED25519,
+}
+
+type DnsZoneEnableDnssecInput struct {
+	// 签名算法, 默认ECDSAP256SHA256
+	// enum: ["RSASHA256", "ECDSAP256SHA256", "ECDSAP384SHA384", "ED25519"]
+	Algorithm string `json:"algorithm"`
+	// ZSK自动轮换周期(天), 0表示不自动轮换
+	ZskLifetimeDays int `json:"zsk_lifetime_days"`
+}
+
+type DnsZoneDisableDnssecInput struct {
+}
+
+type DnsZoneRolloverDnssecKeyInput struct {
+	// 轮换的密钥类型
+	// enum: ["ksk", "zsk"]
+	KeyType string `json:"key_type"`
+}
+
+type DnsZoneRetireDnssecKeyInput struct {
+	// 停用的密钥ID, 更新上级域DS记录后停用旧的KSK
+	KeyId string `json:"key_id"`
+}
+
+type DnsZoneDnssecKey struct {
+	Id         string     `json:"id"`
+	KeyType    string     `json:"key_type"`
+	Algorithm  string     `json:"algorithm"`
+	KeyTag     int        `json:"key_tag"`
+	State      string     `json:"state"`
+	PublishAt  time.Time  `json:"publish_at"`
+	ActivateAt time.Time  `json:"activate_at"`
+	RetireAt   *time.Time `json:"retire_at"`
+	DeleteAt   *time.Time `json:"delete_at"`
+	// DNSKEY记录
+	Dnskey string `json:"dnskey"`
+}
+
+type DnsZoneDnssecDetails struct {
+	Enabled         bool   `json:"enabled"`
+	Algorithm       string `json:"algorithm"`
+	ZskLifetimeDays int    `json:"zsk_lifetime_days"`
+
+	Keys []DnsZoneDnssecKey `json:"keys"`
+	// 需要添加到上级域的DS记录
+	Ds []string `json:"ds"`
+}
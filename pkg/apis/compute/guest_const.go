@@ -321,6 +321,9 @@ const (
 	VM_METADATA_HOT_REMOVE_NIC      = "hot_remove_nic"
 	VM_METADATA_START_VMEM_MB       = "start_vmem_mb"
 	VM_METADATA_START_VCPU_COUNT    = "start_vcpu_count"
+
+	// enable or disable virtio-balloon device of kvm guest, value true|false, overrides the host default
+	VM_METADATA_ENABLE_MEMORY_BALLOON = "enable_memory_balloon"
 )
 
 // windows allow a maximal length of 15
@@ -153,6 +153,19 @@ type HostListInput struct {
 	OrderByMemCommitRate string `json:"order_by_mem_commit_rate"`
 }
 
+// HostFenceResult is the result of fencing an unhealthy host by its BMC
+type HostFenceResult struct {
+	// enmu: none,poweroff,powercycle
+	Policy string `json:"policy"`
+	// enmu: ipmi,redfish
+	Method string `json:"method"`
+	// enmu: success,failed
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+
+	FencedAt time.Time `json:"fenced_at"`
+}
+
 type HostDetails struct {
 	apis.EnabledStatusInfrasResourceBaseDetails
 	ManagedResourceInfo
@@ -179,6 +179,36 @@ const (
 	HOSTMETA_AUTO_MIGRATE_ON_HOST_DOWN     = "__auto_migrate_on_host_down"
 	HOSTMETA_AUTO_MIGRATE_ON_HOST_SHUTDOWN = "__auto_migrate_on_host_shutdown"
 	HOSTMETA_HOST_ERRORS                   = "__host_errors"
+	// result of the last fencing of the host, in json of HostFenceResult
+	HOSTMETA_LAST_FENCE = "__last_fence"
+)
+
+const (
+	// zone metadata overriding the fencing policy of the unhealthy hosts in the zone
+	ZONEMETA_HOST_FENCE_POLICY = "host_fence_policy"
+	// zone metadata overriding what to do with the guests of the unhealthy host if fencing fails
+	ZONEMETA_HOST_FENCE_FAIL_ACTION = "host_fence_fail_action"
+
+	// no fencing, the guests are restarted on other hosts directly
+	HOST_FENCE_POLICY_NONE        = "none"
+	HOST_FENCE_POLICY_POWER_OFF   = "poweroff"
+	HOST_FENCE_POLICY_POWER_CYCLE = "powercycle"
+
+	// keep the guests on the unhealthy host, waiting for the operator
+	HOST_FENCE_FAIL_ACTION_ABORT = "abort"
+	// restart the guests on other hosts anyway, at the risk of split-brain
+	HOST_FENCE_FAIL_ACTION_CONTINUE = "continue"
+
+	HOST_FENCE_METHOD_IPMI    = "ipmi"
+	HOST_FENCE_METHOD_REDFISH = "redfish"
+
+	HOST_FENCE_STATUS_SUCCESS = "success"
+	HOST_FENCE_STATUS_FAILED  = "failed"
+)
+
+var (
+	HOST_FENCE_POLICIES     = []string{HOST_FENCE_POLICY_NONE, HOST_FENCE_POLICY_POWER_OFF, HOST_FENCE_POLICY_POWER_CYCLE}
+	HOST_FENCE_FAIL_ACTIONS = []string{HOST_FENCE_FAIL_ACTION_ABORT, HOST_FENCE_FAIL_ACTION_CONTINUE}
 )
 
 const (
@@ -15,9 +15,37 @@
 package compute
 
 import (
+	"reflect"
 	"time"
 
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/gotypes"
+
 	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/util/choices"
+)
+
+const (
+	LB_CERT_STATUS_ISSUING      = "issuing"
+	LB_CERT_STATUS_ISSUE_FAILED = "issue_failed"
+
+	LB_CERT_ACME_CHALLENGE_HTTP01 = "http-01"
+	LB_CERT_ACME_CHALLENGE_DNS01  = "dns-01"
+
+	LB_CERT_ACME_KEY_EC256   = "ec256"
+	LB_CERT_ACME_KEY_RSA2048 = "rsa2048"
+
+	LB_CERT_ACME_RENEW_BEFORE_DAYS = 30
+)
+
+var LB_CERT_ACME_CHALLENGE_TYPES = choices.NewChoices(
+	LB_CERT_ACME_CHALLENGE_HTTP01,
+	LB_CERT_ACME_CHALLENGE_DNS01,
+)
+
+var LB_CERT_ACME_KEY_TYPES = choices.NewChoices(
+	LB_CERT_ACME_KEY_EC256,
+	LB_CERT_ACME_KEY_RSA2048,
 )
 
 type LoadbalancerCertificateDetails struct {
@@ -94,4 +122,66 @@ type LoadbalancerCertificateCreateInput struct {
 	CommonName string `json:"common_name"`
 	// swagger: ignore
 	SubjectAlternativeNames string `json:"subject_alternative_names"`
+
+	// 通过ACME自动签发证书, 此时无需指定certificate和private_key
+	Acme *LoadbalancerCertificateAcmeInput `json:"acme"`
+
+	// swagger: ignore
+	AcmeDirectoryUrl string `json:"acme_directory_url"`
+	// swagger: ignore
+	AcmeEmail string `json:"acme_email"`
+	// swagger: ignore
+	AcmeDomains string `json:"acme_domains"`
+	// swagger: ignore
+	AcmeChallengeType string `json:"acme_challenge_type"`
+	// swagger: ignore
+	AcmeKeyType string `json:"acme_key_type"`
+	// swagger: ignore
+	AcmeRenewBeforeDays int `json:"acme_renew_before_days"`
+}
+
+type LoadbalancerCertificateAcmeInput struct {
+	// ACME服务目录地址, 如 https://acme-v02.api.letsencrypt.org/directory
+	DirectoryUrl string `json:"directory_url"`
+	// ACME账号联系邮箱
+	Email string `json:"email"`
+	// 证书域名, 第一个域名作为CommonName, 通配符域名仅支持dns-01验证
+	Domains []string `json:"domains"`
+	// 验证方式
+	// enum: ["http-01", "dns-01"]
+	// default: http-01
+	ChallengeType string `json:"challenge_type"`
+	// 证书私钥类型
+	// enum: ["ec256", "rsa2048"]
+	// default: ec256
+	KeyType string `json:"key_type"`
+	// 过期前多少天自动续期
+	// default: 30
+	RenewBeforeDays int `json:"renew_before_days"`
+}
+
+type LoadbalancerCertificateAcmeRenewInput struct {
+}
+
+// LoadbalancerAcmeChallenge is a pending http-01 challenge served by lbagent
+type LoadbalancerAcmeChallenge struct {
+	Domain           string `json:"domain"`
+	Token            string `json:"token"`
+	KeyAuthorization string `json:"key_authorization"`
+}
+
+type LoadbalancerAcmeChallenges []LoadbalancerAcmeChallenge
+
+func (c LoadbalancerAcmeChallenges) String() string {
+	return jsonutils.Marshal(c).String()
+}
+
+func (c LoadbalancerAcmeChallenges) IsZero() bool {
+	return len(c) == 0
+}
+
+func init() {
+	gotypes.RegisterSerializable(reflect.TypeOf(&LoadbalancerAcmeChallenges{}), func() gotypes.ISerializable {
+		return &LoadbalancerAcmeChallenges{}
+	})
 }
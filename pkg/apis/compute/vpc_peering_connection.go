@@ -56,3 +56,8 @@ type VpcPeeringConnectionListInput struct {
 type VpcPeeringConnectionUpdateInput struct {
 	apis.EnabledStatusInfrasResourceBaseUpdateInput
 }
+
+type VpcAcceptPeeringInput struct {
+	// 待接受的对等连接, 其对端vpc须为当前vpc
+	VpcPeeringConnectionId string `json:"vpc_peering_connection_id"`
+}
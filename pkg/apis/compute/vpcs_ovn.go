@@ -71,6 +71,27 @@ func VpcInterExtIP2() netutils.IPV4Addr {
 	return vpcInterExtIP2
 }
 
+const (
+	// 对等连接两端逻辑路由器互联地址, 每个对等连接占用一个 /30
+	// [100.65.64.0, 100.65.127.255], 4096
+	sVpcPeeringIPStart = "100.65.64.0"
+	sVpcPeeringIPEnd   = "100.65.127.252"
+	VpcPeeringIPMask   = 30
+)
+
+var (
+	vpcPeeringIPStart netutils.IPV4Addr
+	vpcPeeringIPEnd   netutils.IPV4Addr
+)
+
+func VpcPeeringIPStart() netutils.IPV4Addr {
+	return vpcPeeringIPStart
+}
+
+func VpcPeeringIPEnd() netutils.IPV4Addr {
+	return vpcPeeringIPEnd
+}
+
 const (
 	sVpcMappedCidr      = "100.64.0.0/17"
 	VpcMappedIPMask     = 17
@@ -135,6 +156,9 @@ func init() {
 	vpcInterExtIP1 = mi(netutils.NewIPV4Addr(sVpcInterExtIP1))
 	vpcInterExtIP2 = mi(netutils.NewIPV4Addr(sVpcInterExtIP2))
 
+	vpcPeeringIPStart = mi(netutils.NewIPV4Addr(sVpcPeeringIPStart))
+	vpcPeeringIPEnd = mi(netutils.NewIPV4Addr(sVpcPeeringIPEnd))
+
 	vpcMappedCidr = mp(netutils.NewIPV4Prefix(sVpcMappedCidr))
 	vpcMappedGatewayIP = mi(netutils.NewIPV4Addr(sVpcMappedGatewayIP))
 
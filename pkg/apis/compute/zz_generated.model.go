@@ -613,6 +613,14 @@ type SDiskBackup struct {
 	// 操作系统类型
 	OsType     string             `json:"os_type"`
 	DiskConfig *SBackupDiskConfig `json:"disk_config"`
+	// 备份模式
+	BackupMode string `json:"backup_mode"`
+	// 备份链中的上一个备份
+	ParentBackupId string `json:"parent_backup_id"`
+	// 备份链的全量备份
+	BaseBackupId string `json:"base_backup_id"`
+	// 备份在备份链中的序号, 全量备份为0
+	ChainIndex int `json:"chain_index"`
 }
 
 // SDiskResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SDiskResourceBase.
@@ -2814,6 +2822,7 @@ type SVpcPeeringConnection struct {
 	PeerVpcId        string `json:"peer_vpc_id"`
 	PeerAccountId    string `json:"peer_account_id"`
 	Bandwidth        int    `json:"bandwidth"`
+	OvnInterIpAddr   string `json:"ovn_inter_ip_addr"`
 }
 
 // SVpcResourceBase is an autogenerated struct via yunion.io/x/onecloud/pkg/compute/models.SVpcResourceBase.
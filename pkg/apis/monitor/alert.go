@@ -96,6 +96,11 @@ type AlertQuery struct {
 	Model MetricQuery `json:"model"`
 	From  string      `json:"from"`
 	To    string      `json:"to"`
+
+	// PromQL/MetricsQL 表达式, 仅用于 promql_query 类型的报警条件
+	Expr string `json:"expr"`
+	// 表达式结果对应的资源类型, 比如: host, guest, 用于关联资源和报警屏蔽
+	ResType string `json:"res_type"`
 }
 
 type AlertCreateInput struct {
@@ -14,6 +14,8 @@
 
 package monitor
 
+import "fmt"
+
 const (
 	ALERT_STATUS_READY       = "ready"
 	ALERT_STATUS_DELETE      = "start_delete"
@@ -41,6 +43,17 @@ const (
 
 	METRIC_QUERY_TYPE_NO_DATA     = "nodata_query"
 	METRIC_QUERY_NO_DATA_THESHOLD = "nodata"
+	// 直接由 VictoriaMetrics 执行 PromQL/MetricsQL 表达式的报警条件
+	METRIC_QUERY_TYPE_PROMQL = "promql_query"
+
+	// 基于历史同期数据(z-score)的异常检测
+	EVALUATOR_SEASONAL_DEVIATION = "seasonal_deviation"
+	// 线性趋势预测
+	EVALUATOR_LINEAR_FORECAST = "linear_forecast"
+	// Holt-Winters(二次指数平滑)趋势预测
+	EVALUATOR_HOLT_WINTERS_FORECAST = "holt_winters_forecast"
+	// 变化率
+	EVALUATOR_RATE_OF_CHANGE = "rate_of_change"
 
 	CommonAlertLevelNormal    = "normal"
 	CommonAlertLevelImportant = "important"
@@ -100,6 +113,16 @@ type CommonAlertQuery struct {
 	Comparator string `json:"comparator"`
 	// 报警阀值
 	Threshold float64 `json:"threshold"`
+	// 判断方式, 为空时按 comparator 和 threshold 做阈值判断
+	// 可选: seasonal_deviation, linear_forecast, holt_winters_forecast, rate_of_change
+	// comparator 决定判断方向, threshold 为 z-score 或预测值/变化率的阈值
+	EvaluatorType string `json:"evaluator_type"`
+	// 判断方式的其它参数
+	// seasonal_deviation: [周期数, 周期小时数], 默认 [4, 168]
+	// linear_forecast: [预测时长(秒)]
+	// holt_winters_forecast: [预测时长(秒), 平滑系数, 趋势系数]
+	// rate_of_change: [变化率的时间单位(秒)], 默认 [60]
+	EvaluatorParams []float64 `json:"evaluator_params"`
 	//field yunsuan
 	FieldOpt      string `json:"field_opt"`
 	ConditionType string `json:"condition_type"`
@@ -179,4 +202,15 @@ type CommonAlertMetricDetails struct {
 	FieldDescription       MetricFieldDetail
 	FieldOpt               string `json:"field_opt"`
 	GetPointStr            bool   `json:"get_point_str"`
+	// 异常检测/趋势预测等判断方式
+	EvaluatorType   string    `json:"evaluator_type"`
+	EvaluatorParams []float64 `json:"evaluator_params"`
+}
+
+// GetMetric 返回报警条件对应的指标, PromQL 类型的条件返回表达式本身
+func (d CommonAlertMetricDetails) GetMetric() string {
+	if d.ConditionType == METRIC_QUERY_TYPE_PROMQL {
+		return d.Field
+	}
+	return fmt.Sprintf("%s.%s", d.Measurement, d.Field)
 }
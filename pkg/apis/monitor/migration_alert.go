@@ -27,6 +27,10 @@ func init() {
 	for _, drv := range []IMigrationAlertMetric{
 		newMMemAailable(),
 		newMCPUUsageActive(),
+		newMHostMetric(MigrationAlertMetricTypeDiskReadIOPS, "diskio", "read_iops"),
+		newMHostMetric(MigrationAlertMetricTypeDiskWriteIOPS, "diskio", "write_iops"),
+		newMHostMetric(MigrationAlertMetricTypeNetBpsRecv, "net", "bps_recv"),
+		newMHostMetric(MigrationAlertMetricTypeNetBpsSent, "net", "bps_sent"),
 	} {
 		GetMigrationAlertMetricDrivers().register(drv)
 	}
@@ -71,6 +75,10 @@ type MigrationAlertMetricType string
 const (
 	MigrationAlertMetricTypeCPUUsageActive = "cpu.usage_active"
 	MigrationAlertMetricTypeMemAvailable   = "mem.available"
+	MigrationAlertMetricTypeDiskReadIOPS   = "diskio.read_iops"
+	MigrationAlertMetricTypeDiskWriteIOPS  = "diskio.write_iops"
+	MigrationAlertMetricTypeNetBpsRecv     = "net.bps_recv"
+	MigrationAlertMetricTypeNetBpsSent     = "net.bps_sent"
 )
 
 type MetricQueryFields struct {
@@ -126,6 +134,36 @@ func (_ mCPUUsageActive) GetQueryFields() *MetricQueryFields {
 	}
 }
 
+// mHostMetric is the metric of host which is greater than threshold to trigger migration,
+// e.g. disk iops or network bandwidth
+type mHostMetric struct {
+	metricType  MigrationAlertMetricType
+	measurement string
+	field       string
+}
+
+func newMHostMetric(t MigrationAlertMetricType, measurement string, field string) IMigrationAlertMetric {
+	return &mHostMetric{
+		metricType:  t,
+		measurement: measurement,
+		field:       field,
+	}
+}
+
+func (m mHostMetric) GetType() MigrationAlertMetricType {
+	return m.metricType
+}
+
+func (m mHostMetric) GetQueryFields() *MetricQueryFields {
+	return &MetricQueryFields{
+		ResourceType: MigrationAlertResourceTypeHost,
+		Database:     METRIC_DATABASE_TELE,
+		Measurement:  m.measurement,
+		Field:        m.field,
+		Comparator:   ConditionGreaterThan, // >
+	}
+}
+
 func IsValidMigrationAlertMetricType(t MigrationAlertMetricType) error {
 	_, err := GetMigrationAlertMetricDrivers().Get(t)
 	return err
@@ -246,6 +284,15 @@ func (m MigrationAlertCreateInput) getQuery(fs *MetricQueryFields) MetricQuery {
 type MigrationAlertSettings struct {
 	Source *MigrationAlertSettingsSource `json:"source"`
 	Target *MigrationAlertSettingsTarget `json:"target"`
+	// MetricLimits 选择目标宿主机时额外检查的指标，避免迁移后目标宿主机其它指标过载
+	MetricLimits []MigrationAlertSettingsMetricLimit `json:"metric_limits"`
+	// DryRun 只生成迁移计划，不执行迁移，需要通过 apply-plan 确认后再迁移
+	DryRun bool `json:"dry_run"`
+}
+
+type MigrationAlertSettingsMetricLimit struct {
+	MetricType MigrationAlertMetricType `json:"metric_type"`
+	Threshold  float64                  `json:"threshold"`
 }
 
 type MigrationAlertSettingsSource struct {
@@ -257,6 +304,35 @@ type MigrationAlertSettingsTarget struct {
 	HostIds []string `json:"host_ids"`
 }
 
+// MigrationPlanItem is a guest proposed to migrate to target host
+type MigrationPlanItem struct {
+	GuestId        string  `json:"guest_id"`
+	GuestName      string  `json:"guest_name"`
+	GuestScore     float64 `json:"guest_score"`
+	SourceHostId   string  `json:"source_host_id"`
+	SourceHostName string  `json:"source_host_name"`
+	TargetHostId   string  `json:"target_host_id"`
+	TargetHostName string  `json:"target_host_name"`
+	// TargetScore is the metric value of target host before migration
+	TargetScore float64 `json:"target_score"`
+	// TargetExpectedScore is the expected metric value of target host after migration
+	TargetExpectedScore float64 `json:"target_expected_score"`
+	// MetricLoads is the expected load of target host after migration,
+	// value is the ratio to threshold of every checked metric
+	MetricLoads map[string]float64 `json:"metric_loads"`
+}
+
+// MigrationPlan is the migration result calculated by balancer waiting for approval
+type MigrationPlan struct {
+	MetricType     MigrationAlertMetricType `json:"metric_type"`
+	Threshold      float64                  `json:"threshold"`
+	SourceHostId   string                   `json:"source_host_id"`
+	SourceHostName string                   `json:"source_host_name"`
+	SourceScore    float64                  `json:"source_score"`
+	Items          []MigrationPlanItem      `json:"items"`
+	CreatedAt      time.Time                `json:"created_at"`
+}
+
 type MigrationAlertListInput struct {
 	AlertListInput
 
@@ -142,14 +142,6 @@ type SSendParams struct {
 	Receiver       SNotifyReceiver
 }
 
-type SNotificationGroupSearchInput struct {
-	StartTime   time.Time
-	EndTime     time.Time
-	GroupKey    string
-	ReceiverId  string
-	ContactType string
-}
-
 type SendParams struct {
 	Title               string
 	Message             string
@@ -225,6 +217,12 @@ type SNotifyConfigContent struct {
 	//AgentId string
 	//AppSecret string
 	AppKey string
+	// slack, telegram
+	BotToken string
+	// teams
+	//AppId string
+	//AppSecret string
+	TenantId string
 	// sms
 	VerifiyCode     string
 	AlertsCode      string
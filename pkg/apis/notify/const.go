@@ -36,6 +36,12 @@ const (
 	WEBHOOK        = "webhook"
 	WEBHOOK_ROBOT  = "webhook-robot"
 	WEBSOCKET      = "websocket"
+	SLACK          = "slack"
+	TEAMS          = "teams"
+	TELEGRAM       = "telegram"
+	SLACK_ROBOT    = "slack-robot"
+	TEAMS_ROBOT    = "teams-robot"
+	TELEGRAM_ROBOT = "telegram-robot"
 
 	ROBOT = "robot"
 
@@ -43,6 +49,7 @@ const (
 	RECEIVER_NOTIFICATION_SENT     = "sending"   // Nofity module has sent notification, but result unkown
 	RECEIVER_NOTIFICATION_OK       = "sent_ok"   // Notification was sent successfully
 	RECEIVER_NOTIFICATION_FAIL     = "sent_fail" // That sent a notification is failed
+	RECEIVER_NOTIFICATION_DEFERRED = "deferred"  // Notification was queued and will be sent in a digest later
 
 	VERIFICATION_SENT          = "sent"      // Verification was sent
 	VERIFICATION_SENT_FAIL     = "sent_fail" // Verification was sent failed
@@ -66,6 +73,13 @@ const (
 
 	NOTIFICATION_TAG_ALERT = "alert"
 
+	NOTIFICATION_ESCALATION_PENDING = "pending"
+	NOTIFICATION_ESCALATION_ACKED   = "acked"
+	NOTIFICATION_ESCALATION_DONE    = "done"
+
+	// group key of the digest sent when the quiet hours of receiver end
+	NOTIFICATION_GROUP_KEY_QUIET_HOURS = "quiet_hours"
+
 	TEMPLATE_TYPE_TITLE   = "title"
 	TEMPLATE_TYPE_CONTENT = "content"
 	TEMPLATE_TYPE_REMOTE  = "remote"
@@ -83,6 +97,9 @@ const (
 	ROBOT_TYPE_DINGTALK = "dingtalk"
 	ROBOT_TYPE_WORKWX   = "workwx"
 	ROBOT_TYPE_WEBHOOK  = "webhook"
+	ROBOT_TYPE_SLACK    = "slack"
+	ROBOT_TYPE_TEAMS    = "teams"
+	ROBOT_TYPE_TELEGRAM = "telegram"
 
 	ROBOT_STATUS_READY = "ready"
 
@@ -151,5 +168,6 @@ const (
 
 var (
 	ErrNoSuchMobile     = errors.Error("no such mobile")
+	ErrNoSuchEmail      = errors.Error("no such email")
 	ErrIncompleteConfig = errors.Error("incomplete config")
 )
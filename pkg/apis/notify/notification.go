@@ -87,6 +87,12 @@ type NotificationListInput struct {
 	ReceiverId  string
 	Tag         string
 	TopicType   string
+
+	// description: filter notifications acknowledged or not
+	Acknowledged *bool
+}
+
+type NotificationAcknowledgeInput struct {
 }
 
 type SContact struct {
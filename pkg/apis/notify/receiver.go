@@ -46,6 +46,22 @@ type ReceiverCreateInput struct {
 
 	// force verified if admin create the records
 	ForceVerified bool `json:"force_verified"`
+
+	SReceiverQuietHoursInput
+}
+
+type SReceiverQuietHoursInput struct {
+	// description: start of quiet hours in HH:MM, notifications other than fatal ones are deferred until the end of quiet hours, quiet hours are disabled if start equals end
+	// example: 22:00
+	QuietHoursStart string `json:"quiet_hours_start"`
+
+	// description: end of quiet hours in HH:MM
+	// example: 08:00
+	QuietHoursEnd string `json:"quiet_hours_end"`
+
+	// description: time zone of quiet hours, the time zone of notify service is used if empty
+	// example: Asia/Shanghai
+	TimeZone string `json:"time_zone"`
 }
 
 type SInternationalMobile struct {
@@ -166,23 +182,31 @@ type ReceiverUpdateInput struct {
 	EnabledContactTypes []string `json:"enabled_contact_types"`
 
 	ForceVerified bool `json:"force_verified"`
+
+	SReceiverQuietHoursInput
 }
 
 type ReceiverTriggerVerifyInput struct {
 	// description: contact type
 	// required: true
 	// example: email
-	// enum: email,mobile
+	// enum: email,mobile,dingtalk,feishu,workwx,slack,teams,telegram
 	ContactType string `json:"contact_type"`
 }
 
+type ReceiverTriggerVerifyOutput struct {
+	// description: link the user should open to send the verification code to the bot, only for contact types bound by the user such as telegram
+	// example: https://t.me/onecloud_bot?start=123456
+	BindUrl string `json:"bind_url,omitempty"`
+}
+
 type ReceiverVerifyInput struct {
 	// description: Contact type
 	// required: true
 	// example: email
-	// enum: email,mobile
+	// enum: email,mobile,telegram
 	ContactType string `json:"contact_type"`
-	// description: token user input
+	// description: token user input, not required for telegram
 	// required: true
 	// example: 123456
 	Token string `json:"token"`
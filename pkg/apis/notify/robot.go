@@ -25,10 +25,10 @@ type RobotCreateInput struct {
 	apis.SharableVirtualResourceCreateInput
 	apis.EnabledBaseResourceCreateInput
 	// description: robot type
-	// enum: feishu,dingtalk,workwx,webhook
+	// enum: feishu,dingtalk,workwx,slack,teams,telegram,webhook
 	// example: webhook
 	Type string `json:"type"`
-	// description: address
+	// description: address, webhook url for slack and teams, https://api.telegram.org/bot<token>/sendMessage?chat_id=<chat_id> for telegram
 	// example: http://helloworld.io/test/webhook
 	Address string `json:"address"`
 	// description: Language preference
@@ -48,7 +48,7 @@ type RobotListInput struct {
 	apis.SharableVirtualResourceListInput
 	apis.EnabledResourceBaseListInput
 	// description: robot type
-	// enum: feishu,dingtalk,workwx,webhook
+	// enum: feishu,dingtalk,workwx,slack,teams,telegram,webhook
 	// example: webhook
 	Type string `json:"type"`
 	// description: Language preference
@@ -59,6 +59,12 @@ type SubscriberCreateInput struct {
 	Scope string
 	// minutes
 	GroupTimes *uint32
+
+	// description: escalation level, subscribers with level greater than 0 only receive fatal notifications not acknowledged in time
+	EscalationLevel *uint32
+
+	// description: minutes to wait for acknowledgement before escalating from the previous level to this one
+	EscalationMinutes *uint32
 }
 
 type SubscriberChangeInput struct {
@@ -76,6 +82,12 @@ type SubscriberChangeInput struct {
 	Robot string
 	// minutes
 	GroupTimes *uint32
+
+	// description: escalation level, subscribers with level greater than 0 only receive fatal notifications not acknowledged in time
+	EscalationLevel *uint32
+
+	// description: minutes to wait for acknowledgement before escalating from the previous level to this one
+	EscalationMinutes *uint32
 }
 
 type SubscriberListInput struct {
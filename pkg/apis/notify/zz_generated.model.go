@@ -89,6 +89,28 @@ type SNotification struct {
 	ReceivedAt time.Time `json:"received_at"`
 	EventId    string    `json:"event_id"`
 	SendTimes  int       `json:"send_times"`
+	// 确认时间
+	AckedAt time.Time `json:"acked_at"`
+	// 确认人
+	AckedBy string `json:"acked_by"`
+}
+
+// SNotificationEscalation is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SNotificationEscalation.
+type SNotificationEscalation struct {
+	EventId         string `json:"event_id"`
+	TopicId         string `json:"topic_id"`
+	ProjectDomainId string `json:"project_domain_id"`
+	ProjectId       string `json:"project_id"`
+	// 发送通知时使用的联系方式所在的域
+	DomainId string `json:"domain_id"`
+	// 已经通知到的级别
+	Level uint32 `json:"level"`
+	// 下一次升级的时间
+	EscalateAt time.Time `json:"escalate_at"`
+	Status     string    `json:"status"`
+	AckedAt    time.Time `json:"acked_at"`
+	AckedBy    string    `json:"acked_by"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // SNotificationGroup is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SNotificationGroup.
@@ -97,14 +119,19 @@ type SNotificationGroup struct {
 	GroupKey string `json:"group_key"`
 	Title    string `json:"title"`
 	// swagger:ignore
-	Message     string               `json:"message"`
-	ReceiverId  string               `json:"receiver_id"`
-	Body        jsonutils.JSONObject `json:"body"`
-	Header      jsonutils.JSONObject `json:"header"`
-	MsgKey      string               `json:"msg_key"`
-	ContactType string               `json:"contact_type"`
-	Contact     string               `json:"contact"`
-	DomainId    string               `json:"domain_id"`
+	Message        string               `json:"message"`
+	ReceiverId     string               `json:"receiver_id"`
+	Body           jsonutils.JSONObject `json:"body"`
+	Header         jsonutils.JSONObject `json:"header"`
+	MsgKey         string               `json:"msg_key"`
+	ContactType    string               `json:"contact_type"`
+	Contact        string               `json:"contact"`
+	DomainId       string               `json:"domain_id"`
+	NotificationId string               `json:"notification_id"`
+	// 汇总消息的发送时间
+	SendAfter time.Time `json:"send_after"`
+	// 是否已经发送, 已发送的记录仅用于标记聚合窗口
+	Sent *bool `json:"sent,omitempty"`
 }
 
 // SNotificationLog is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SNotificationLog.
@@ -138,6 +165,12 @@ type SReceiver struct {
 	EnabledMobile *bool `json:"enabled_mobile,omitempty"`
 	// swagger:ignore
 	VerifiedMobile *bool `json:"verified_mobile,omitempty"`
+	// 免打扰开始时间, 格式 HH:MM, 免打扰时段内非 fatal 级别的消息会延迟到时段结束后汇总发送
+	QuietHoursStart string `json:"quiet_hours_start"`
+	// 免打扰结束时间, 格式 HH:MM
+	QuietHoursEnd string `json:"quiet_hours_end"`
+	// 免打扰时段所在时区, 为空时使用服务配置的时区
+	TimeZone string `json:"time_zone"`
 }
 
 // SRobot is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SRobot.
@@ -168,6 +201,10 @@ type SSubscriber struct {
 	DomainId                string `json:"domain_id"`
 	// minutes
 	GroupTimes uint32 `json:"group_times"`
+	// 升级级别, 0 表示直接接收通知, 大于 0 时仅接收上一级别通知后未被确认的 fatal 级别通知
+	EscalationLevel uint32 `json:"escalation_level"`
+	// 上一级别通知后超过多少分钟未确认则升级到本级别, 单位分钟
+	EscalationMinutes uint32 `json:"escalation_minutes"`
 }
 
 // SSubscriberDis is an autogenerated struct via yunion.io/x/onecloud/pkg/notify/models.SSubscriberDis.
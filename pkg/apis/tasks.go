@@ -52,6 +52,10 @@ type TaskDetails struct {
 	StartAt time.Time `json:"start_at"`
 	// 完成任务时间
 	EndAt time.Time `json:"end_at"`
+	// 任务超时时间
+	TimeoutAt time.Time `json:"timeout_at"`
+	// 当前阶段超时时间
+	StageTimeoutAt time.Time `json:"stage_timeout_at"`
 
 	DomainId  string `json:"domain_id"`
 	ProjectId string `json:"tenant_id"`
@@ -64,3 +68,8 @@ type TaskDetails struct {
 	Stage        string
 	ParentTaskId string
 }
+
+type TaskCancelInput struct {
+	// 取消任务的原因
+	Reason string `json:"reason" help:"reason to cancel the task"`
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"time"
+
+	"yunion.io/x/onecloud/pkg/apis"
+)
+
+const (
+	SESSION_SHARE_MODE_READ  = "read"
+	SESSION_SHARE_MODE_WRITE = "write"
+)
+
+type SessionListInput struct {
+	// 列出所有用户的会话, 需要管理员权限
+	Admin bool `json:"admin"`
+}
+
+type SessionViewer struct {
+	Id         string    `json:"id"`
+	UserId     string    `json:"user_id"`
+	User       string    `json:"user"`
+	Mode       string    `json:"mode"`
+	AttachedAt time.Time `json:"attached_at"`
+	Connected  bool      `json:"connected"`
+}
+
+type SessionShare struct {
+	UserId    string    `json:"user_id"`
+	User      string    `json:"user"`
+	Mode      string    `json:"mode"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SessionDetails struct {
+	Id          string    `json:"id"`
+	Protocol    string    `json:"protocol"`
+	ObjId       string    `json:"obj_id"`
+	ObjType     string    `json:"obj_type"`
+	ObjName     string    `json:"obj_name"`
+	LoginUser   string    `json:"login_user"`
+	UserId      string    `json:"user_id"`
+	User        string    `json:"user"`
+	TenantId    string    `json:"tenant_id"`
+	Tenant      string    `json:"tenant"`
+	ConnectedAt time.Time `json:"connected_at"`
+
+	Shares  []SessionShare  `json:"shares"`
+	Viewers []SessionViewer `json:"viewers"`
+}
+
+type SessionShareInput struct {
+	// 被邀请用户的ID或名称
+	User string `json:"user"`
+	// 共享模式, read: 只读观看, write: 可以共同输入
+	// enum: ["read", "write"]
+	Mode string `json:"mode"`
+}
+
+type SessionUnshareInput struct {
+	// 被邀请用户的ID或名称
+	User string `json:"user"`
+}
+
+type SessionAttachInput struct {
+	// 接入模式, 被邀请用户只能使用邀请时指定的模式, 管理员可以任意指定
+	// enum: ["read", "write"]
+	Mode string `json:"mode"`
+}
+
+type SessionMessageInput struct {
+	Message string `json:"message"`
+}
+
+type SessionListOutput struct {
+	apis.Meta
+
+	Data  []SessionDetails `json:"data"`
+	Total int              `json:"total"`
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/util/asciicast"
+)
+
+const (
+	SESSION_RECORDING_STATUS_RECORDING   = "recording"
+	SESSION_RECORDING_STATUS_SAVING      = "saving"
+	SESSION_RECORDING_STATUS_READY       = "ready"
+	SESSION_RECORDING_STATUS_SAVE_FAILED = "save_failed"
+
+	SESSION_RECORDING_BACKEND_LOCAL  = "local"
+	SESSION_RECORDING_BACKEND_OBJECT = "object"
+
+	SESSION_RECORDING_REPLAY_DEFAULT_LIMIT = 1000
+	SESSION_RECORDING_REPLAY_MAX_LIMIT     = 10000
+)
+
+type SessionRecordingListInput struct {
+	apis.UserResourceListInput
+	apis.StatusResourceBaseListInput
+
+	// 会话ID
+	SessionId []string `json:"session_id"`
+	// 连接对象ID
+	ObjId []string `json:"obj_id"`
+	// 连接对象类型
+	ObjType []string `json:"obj_type"`
+	// 连接对象名称
+	ObjName []string `json:"obj_name"`
+	// 登录用户名
+	LoginUser []string `json:"login_user"`
+	// 会话协议
+	Protocol []string `json:"protocol"`
+	// 会话开始时间晚于该时间
+	Since time.Time `json:"since"`
+	// 会话开始时间早于该时间
+	Until time.Time `json:"until"`
+}
+
+type SessionRecordingDetails struct {
+	apis.UserResourceDetails
+}
+
+type SessionRecordingReplayInput struct {
+	// 起始事件序号
+	Offset int `json:"offset"`
+	// 返回的最大事件数, 默认1000
+	Limit int `json:"limit"`
+}
+
+type SessionRecordingReplayOutput struct {
+	Header asciicast.SHeader `json:"header"`
+	// asciicast v2 格式的事件, 即 [time, type, data]
+	Events []jsonutils.JSONObject `json:"events"`
+	// 下一页的起始事件序号, 为0表示没有更多事件
+	NextOffset int `json:"next_offset"`
+}
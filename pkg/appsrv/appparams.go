@@ -39,6 +39,8 @@ type SAppParams struct {
 
 	OverrideResponseBodyWrapper bool
 
+	hand *SHandlerInfo
+
 	// Cancel context.CancelFunc
 }
 
@@ -47,6 +47,7 @@ import (
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/proxy"
 	"yunion.io/x/onecloud/pkg/util/ctx"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 type Application struct {
@@ -78,6 +79,8 @@ type Application struct {
 	isTLS bool
 
 	enableProfiling bool
+
+	rateLimiter *SRateLimiter
 }
 
 const (
@@ -276,6 +279,7 @@ func (app *Application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	duration := float64(time.Since(start).Nanoseconds()) / 1000000
 	counter.hit += 1
 	counter.duration += duration
+	observeRequest(app, r, hi, lrw.status, duration/1000)
 	skipLog := false
 	if params != nil {
 		if params.SkipLog {
@@ -340,9 +344,11 @@ func (t *appTask) Run() {
 		t.ctx = context.WithValue(t.ctx, APP_CONTEXT_KEY_APP_PARAMS, t.appParams)
 		func() {
 			span := trace.StartServerTrace(&t.fw, t.r, t.appParams.Name, t.app.GetName(), t.hand.GetTags())
+			otSpan := t.startServerSpan()
 			defer func() {
 				if !t.appParams.SkipTrace {
 					span.EndTrace()
+					t.endServerSpan(otSpan)
 				}
 			}()
 			t.ctx = context.WithValue(t.ctx, appctx.APP_CONTEXT_KEY_TRACE, span)
@@ -352,6 +358,31 @@ func (t *appTask) Run() {
 	t.fw.closeChannels()
 }
 
+// startServerSpan starts an OpenTelemetry span of the request as the child of
+// the W3C trace context in the request headers
+func (t *appTask) startServerSpan() *tracing.SSpan {
+	if t.hand.skipLog {
+		return nil
+	}
+	var span *tracing.SSpan
+	t.ctx = tracing.Extract(t.ctx, t.r.Header)
+	t.ctx, span = tracing.StartSpan(t.ctx, fmt.Sprintf("%s %s", t.r.Method, t.hand.GetName(nil)), tracing.SpanKindServer)
+	span.SetAttribute("http.method", t.r.Method)
+	span.SetAttribute("http.target", t.r.URL.Path)
+	span.SetAttribute("http.request_id", t.rid)
+	span.SetAttribute("app.name", t.app.GetName())
+	return span
+}
+
+func (t *appTask) endServerSpan(span *tracing.SSpan) {
+	status := t.fw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetHTTPStatus(status)
+	span.End()
+}
+
 func (t *appTask) Dump() string {
 	return fmt.Sprintf("%s %s", t.r.Method, t.r.URL.String())
 }
@@ -460,6 +491,9 @@ func (app *Application) addDefaultHandlers() {
 	app.AddDefaultHandler("GET", "/ping", PingHandler, "ping")
 	app.AddDefaultHandler("GET", "/worker_stats", WorkerStatsHandler, "worker_stats")
 	app.AddDefaultHandler("GET", "/process_stats", ProcessStatsHandler, "process_stats")
+	if err := app.addMetricsHandler(); err != nil {
+		log.Fatalf("%v", err)
+	}
 }
 
 func timeoutHandle(h http.Handler) http.HandlerFunc {
@@ -83,6 +83,19 @@ func (this *SHandlerInfo) GetTags() map[string]string {
 	return this.tags
 }
 
+// getRouteName returns the name of the handler prefixed by the resource it
+// serves, e.g. servers.list, the path parameters are not substituted
+func (this *SHandlerInfo) getRouteName() string {
+	name := this.GetName(nil)
+	if len(name) == 0 {
+		name = "default"
+	}
+	if res, ok := this.tags["resource"]; ok && len(res) > 0 {
+		name = res + "." + name
+	}
+	return name
+}
+
 func NewHandlerInfo(method string, path []string, handler func(context.Context, http.ResponseWriter, *http.Request), metadata map[string]interface{}, name string, tags map[string]string) *SHandlerInfo {
 	return newHandlerInfo(method, path, handler, metadata, name, tags)
 }
@@ -152,5 +165,6 @@ func (hi *SHandlerInfo) GetAppParams(params map[string]string, path []string) *S
 	appParams.SkipLog = hi.skipLog
 	appParams.Params = params
 	appParams.Path = path
+	appParams.hand = hi
 	return &appParams
 }
@@ -0,0 +1,151 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	METRICS_NAMESPACE = "onecloud"
+)
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: METRICS_NAMESPACE,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of the HTTP requests by application, method, handler and status class",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"app", "method", "handler", "code"},
+	)
+
+	workerQueueSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker", "queue_size"),
+		"Number of tasks waiting in the queue of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerActiveDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker", "active_workers"),
+		"Number of active workers of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerDetachedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker", "detached_workers"),
+		"Number of detached workers of the worker manager",
+		[]string{"name"}, nil,
+	)
+	workerMaxDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(METRICS_NAMESPACE, "worker", "max_workers"),
+		"Maximal number of workers of the worker manager",
+		[]string{"name"}, nil,
+	)
+)
+
+var metricsHandler http.Handler
+
+func init() {
+	prometheus.MustRegister(httpRequestDuration)
+	prometheus.MustRegister(&sWorkerManagerCollector{})
+	metricsHandler = promhttp.Handler()
+}
+
+// sWorkerManagerCollector collects the states of all worker managers, the
+// states of the managers with the same name are summed up
+type sWorkerManagerCollector struct{}
+
+func (c *sWorkerManagerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- workerQueueSizeDesc
+	ch <- workerActiveDesc
+	ch <- workerDetachedDesc
+	ch <- workerMaxDesc
+}
+
+func (c *sWorkerManagerCollector) Collect(ch chan<- prometheus.Metric) {
+	names := make([]string, 0)
+	states := make(map[string]*SWorkerManagerStates)
+	workerManagerLock.Lock()
+	for i := range workerManagers {
+		state := workerManagers[i].getState()
+		if total, ok := states[state.Name]; ok {
+			total.QueueCnt += state.QueueCnt
+			total.ActiveWorkerCnt += state.ActiveWorkerCnt
+			total.DetachWorkerCnt += state.DetachWorkerCnt
+			total.MaxWorkerCnt += state.MaxWorkerCnt
+		} else {
+			names = append(names, state.Name)
+			states[state.Name] = &state
+		}
+	}
+	workerManagerLock.Unlock()
+	for _, name := range names {
+		state := states[name]
+		ch <- prometheus.MustNewConstMetric(workerQueueSizeDesc, prometheus.GaugeValue, float64(state.QueueCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerActiveDesc, prometheus.GaugeValue, float64(state.ActiveWorkerCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerDetachedDesc, prometheus.GaugeValue, float64(state.DetachWorkerCnt), name)
+		ch <- prometheus.MustNewConstMetric(workerMaxDesc, prometheus.GaugeValue, float64(state.MaxWorkerCnt), name)
+	}
+}
+
+func statusCodeClass(status int) string {
+	if status < 400 {
+		return "2XX"
+	} else if status < 500 {
+		return "4XX"
+	} else {
+		return "5XX"
+	}
+}
+
+func observeRequest(app *Application, r *http.Request, hi *SHandlerInfo, status int, seconds float64) {
+	httpRequestDuration.WithLabelValues(app.name, r.Method, hi.getRouteName(), statusCodeClass(status)).Observe(seconds)
+}
+
+// MetricsHandler exposes the metrics registered to the default prometheus
+// registry in the prometheus text format
+func MetricsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
+
+// metricsPaths are the paths to mount the prometheus metrics handler, the
+// first one not occupied by a resource is taken, e.g. /metrics is a resource
+// of the monitor service
+var metricsPaths = []string{"/metrics", "/prometheus/metrics"}
+
+func (app *Application) addMetricsHandler() error {
+	errs := make([]error, 0)
+	for _, path := range metricsPaths {
+		hi := newHandlerInfo("GET", SplitPath(path), MetricsHandler, nil, "metrics", nil)
+		hi.SetSkipLog(true).SetWorkerManager(app.systemSession)
+		err := app.getRoot(hi.method).Add(hi.path, hi)
+		if err == nil {
+			if len(errs) > 0 {
+				log.Warningf("prometheus metrics handler is mounted at %s: %v", path, errors.NewAggregate(errs))
+			}
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Wrap(errors.NewAggregate(errs), "register prometheus metrics handler")
+}
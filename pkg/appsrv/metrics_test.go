@@ -0,0 +1,69 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	app := NewApplication("metrics_test", 1, false)
+	app.AddHandler2("GET", "/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		Send(w, "world")
+	}, nil, "list", map[string]string{"resource": "hellos"})
+	assert.NoError(t, app.addMetricsHandler())
+
+	assert.True(t, assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/hello", nil, "world"))
+	for _, metric := range []string{
+		`onecloud_http_request_duration_seconds_count{app="metrics_test",code="2XX",handler="hellos.list",method="GET"} 1`,
+		`onecloud_worker_max_workers{name="HttpGetRequestWorkerManager"}`,
+		`onecloud_worker_queue_size{name="InternalHttpRequestWorkerManager"}`,
+	} {
+		assert.True(t, assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/metrics", nil, metric), metric)
+	}
+}
+
+func TestMetricsHandlerPathOccupied(t *testing.T) {
+	app := NewApplication("metrics_occupied_test", 1, false)
+	// the metrics resource of the monitor service
+	app.AddHandler2("GET", "/metrics", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		Send(w, "monitor metrics")
+	}, nil, "list", map[string]string{"resource": "metrics"})
+	assert.NoError(t, app.addMetricsHandler())
+
+	assert.True(t, assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/metrics", nil, "monitor metrics"))
+	assert.True(t, assert.HTTPBodyContains(t, app.ServeHTTP, "GET", "/prometheus/metrics", nil, "onecloud_worker_max_workers"))
+
+	// all the paths are occupied
+	assert.Error(t, app.addMetricsHandler())
+}
+
+func TestStatusCodeClass(t *testing.T) {
+	for status, want := range map[int]string{
+		200: "2XX",
+		302: "2XX",
+		404: "4XX",
+		500: "5XX",
+		504: "5XX",
+	} {
+		if got := statusCodeClass(status); got != want {
+			t.Errorf("status %d: want %s got %s", status, want, got)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// SRateLimit is a token bucket refilled by Rate tokens per second with the
+// capacity of Burst, zero Rate means unlimited
+type SRateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (l SRateLimit) isLimited() bool {
+	return l.Rate > 0
+}
+
+func (l SRateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return int(math.Ceil(l.Rate))
+}
+
+// SRateLimitConfig is the configuration of the API rate limits, the read
+// (GET/HEAD) and write requests are limited separately
+type SRateLimitConfig struct {
+	UserRead     SRateLimit `json:"user_read"`
+	UserWrite    SRateLimit `json:"user_write"`
+	ProjectRead  SRateLimit `json:"project_read"`
+	ProjectWrite SRateLimit `json:"project_write"`
+
+	// per user limits of the routes, keyed by <resource>.<handler name>,
+	// e.g. servers.list, checked in addition to the read/write limits
+	Routes map[string]SRateLimit `json:"routes"`
+
+	// users exempted from rate limiting by id or name
+	ExemptUsers []string `json:"exempt_users"`
+	// whether the system admins are limited, default is false so that the
+	// calls between services are never limited
+	LimitSystemAdmin bool `json:"limit_system_admin"`
+}
+
+const (
+	RATE_LIMIT_SCOPE_USER    = "user"
+	RATE_LIMIT_SCOPE_PROJECT = "project"
+	RATE_LIMIT_SCOPE_ROUTE   = "route"
+
+	// buckets idle for this long are released
+	rateLimitBucketIdleTimeout = 10 * time.Minute
+)
+
+var rateLimitRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Subsystem: "ratelimit",
+		Name:      "requests_total",
+		Help:      "Number of requests checked by the rate limiter by application and result, rejected requests are labeled by the exhausted scope",
+	},
+	[]string{"app", "result", "scope"},
+)
+
+func init() {
+	prometheus.MustRegister(rateLimitRequests)
+}
+
+type sRateLimitBucket struct {
+	limit    SRateLimit
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// SRateLimiter limits the requests by token buckets keyed by user, project and route
+type SRateLimiter struct {
+	lock    sync.Mutex
+	config  SRateLimitConfig
+	buckets map[string]*sRateLimitBucket
+
+	lastCleanup time.Time
+}
+
+func NewRateLimiter() *SRateLimiter {
+	return &SRateLimiter{
+		buckets:     make(map[string]*sRateLimitBucket),
+		lastCleanup: time.Now(),
+	}
+}
+
+func (l *SRateLimiter) SetConfig(config SRateLimitConfig) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.config = config
+}
+
+func (l *SRateLimiter) GetConfig() SRateLimitConfig {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.config
+}
+
+// IsExempted returns whether the requests of the user are never limited
+func (l *SRateLimiter) IsExempted(userId, userName string, isSystemAdmin bool) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if isSystemAdmin && !l.config.LimitSystemAdmin {
+		return true
+	}
+	for _, u := range l.config.ExemptUsers {
+		if u == userId || u == userName {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func (l *SRateLimiter) getBucket(key string, limit SRateLimit, now time.Time) *sRateLimitBucket {
+	bucket, ok := l.buckets[key]
+	if !ok || bucket.limit != limit {
+		bucket = &sRateLimitBucket{
+			limit:   limit,
+			limiter: rate.NewLimiter(rate.Limit(limit.Rate), limit.burst()),
+		}
+		l.buckets[key] = bucket
+	}
+	bucket.lastSeen = now
+	return bucket
+}
+
+func (l *SRateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimitBucketIdleTimeout {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) > rateLimitBucketIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastCleanup = now
+}
+
+// Allow takes a token from each bucket the request falls in, the request is
+// allowed only if all buckets have tokens, otherwise no token is taken and the
+// time to wait and the exhausted scope are returned
+func (l *SRateLimiter) Allow(method, route, userId, projectId string) (bool, time.Duration, string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	isRead := isReadMethod(method)
+	class := "write"
+	userLimit, projectLimit := l.config.UserWrite, l.config.ProjectWrite
+	if isRead {
+		class = "read"
+		userLimit, projectLimit = l.config.UserRead, l.config.ProjectRead
+	}
+	type sCheck struct {
+		scope string
+		key   string
+		limit SRateLimit
+	}
+	checks := []sCheck{}
+	if len(userId) > 0 && userLimit.isLimited() {
+		checks = append(checks, sCheck{RATE_LIMIT_SCOPE_USER, "user/" + userId + "/" + class, userLimit})
+	}
+	if len(projectId) > 0 && projectLimit.isLimited() {
+		checks = append(checks, sCheck{RATE_LIMIT_SCOPE_PROJECT, "project/" + projectId + "/" + class, projectLimit})
+	}
+	if routeLimit, ok := l.config.Routes[route]; ok && routeLimit.isLimited() && len(userId) > 0 {
+		checks = append(checks, sCheck{RATE_LIMIT_SCOPE_ROUTE, "route/" + route + "/" + userId, routeLimit})
+	}
+
+	reservations := make([]*rate.Reservation, 0, len(checks))
+	var wait time.Duration
+	scope := ""
+	for _, check := range checks {
+		r := l.getBucket(check.key, check.limit, now).limiter.ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if !r.OK() {
+			// burst smaller than 1, never allowed
+			wait, scope = time.Second, check.scope
+			continue
+		}
+		if delay := r.DelayFrom(now); delay > wait {
+			wait, scope = delay, check.scope
+		}
+	}
+	if wait == 0 {
+		return true, 0, ""
+	}
+	for _, r := range reservations {
+		r.CancelAt(now)
+	}
+	return false, wait, scope
+}
+
+// SetRateLimiter enables rate limiting of the authenticated requests of the application
+func (app *Application) SetRateLimiter(limiter *SRateLimiter) {
+	app.rateLimiter = limiter
+}
+
+func (app *Application) GetRateLimiter() *SRateLimiter {
+	return app.rateLimiter
+}
+
+// CheckRateLimit checks the rate limits of the request in ctx issued by the
+// user, when it is rejected, the Retry-After header is set and the time to wait
+// is returned with false
+func CheckRateLimit(ctx context.Context, w http.ResponseWriter, r *http.Request, userId, userName, projectId string, isSystemAdmin bool) (bool, time.Duration) {
+	app := AppContextApp(ctx)
+	if app == nil || app.rateLimiter == nil {
+		return true, 0
+	}
+	if app.rateLimiter.IsExempted(userId, userName, isSystemAdmin) {
+		return true, 0
+	}
+	route := ""
+	if params := AppContextGetParams(ctx); params != nil && params.hand != nil {
+		route = params.hand.getRouteName()
+	}
+	ok, wait, scope := app.rateLimiter.Allow(r.Method, route, userId, projectId)
+	if ok {
+		rateLimitRequests.WithLabelValues(app.name, "allowed", "").Inc()
+		return true, 0
+	}
+	rateLimitRequests.WithLabelValues(app.name, "rejected", scope).Inc()
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return false, wait
+}
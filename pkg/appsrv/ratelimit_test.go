@@ -0,0 +1,81 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appsrv
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter()
+	l.SetConfig(SRateLimitConfig{
+		UserRead:     SRateLimit{Rate: 0.001, Burst: 2},
+		ProjectWrite: SRateLimit{Rate: 0.001, Burst: 1},
+		Routes: map[string]SRateLimit{
+			"servers.perform_action": {Rate: 0.001, Burst: 1},
+		},
+		ExemptUsers: []string{"sysadmin"},
+	})
+
+	for i := 0; i < 2; i++ {
+		ok, _, _ := l.Allow("GET", "servers.list", "u1", "p1")
+		assert.True(t, ok, "read %d", i)
+	}
+	ok, wait, scope := l.Allow("GET", "servers.list", "u1", "p1")
+	assert.False(t, ok)
+	assert.True(t, wait > 0)
+	assert.Equal(t, RATE_LIMIT_SCOPE_USER, scope)
+	// another user is not affected
+	ok, _, _ = l.Allow("GET", "servers.list", "u2", "p1")
+	assert.True(t, ok)
+
+	// writes are limited by project, the rejected request takes no token
+	ok, _, _ = l.Allow("POST", "servers.create", "u1", "p2")
+	assert.True(t, ok)
+	ok, _, scope = l.Allow("POST", "servers.create", "u2", "p2")
+	assert.False(t, ok)
+	assert.Equal(t, RATE_LIMIT_SCOPE_PROJECT, scope)
+
+	// route limit is per user
+	ok, _, _ = l.Allow("POST", "servers.perform_action", "u3", "")
+	assert.True(t, ok)
+	ok, _, scope = l.Allow("POST", "servers.perform_action", "u3", "")
+	assert.False(t, ok)
+	assert.Equal(t, RATE_LIMIT_SCOPE_ROUTE, scope)
+
+	assert.True(t, l.IsExempted("", "sysadmin", false))
+	assert.True(t, l.IsExempted("u1", "", true))
+	assert.False(t, l.IsExempted("u1", "", false))
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	app := NewApplication("ratelimit_test", 1, false)
+	limiter := NewRateLimiter()
+	limiter.SetConfig(SRateLimitConfig{UserWrite: SRateLimit{Rate: 0.5, Burst: 1}})
+	app.SetRateLimiter(limiter)
+	app.AddHandler("POST", "/hello", func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if ok, _ := CheckRateLimit(ctx, w, r, "u1", "user1", "p1", false); !ok {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		Send(w, "world")
+	})
+	assert.HTTPSuccess(t, app.ServeHTTP, "POST", "/hello", nil)
+	assert.HTTPStatusCode(t, app.ServeHTTP, "POST", "/hello", nil, http.StatusTooManyRequests)
+}
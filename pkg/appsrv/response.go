@@ -37,6 +37,7 @@ type responseWriterChannel struct {
 	statusChan chan int
 	statusResp chan bool
 
+	status   int
 	isClosed bool
 }
 
@@ -72,6 +73,7 @@ func (w *responseWriterChannel) WriteHeader(status int) {
 	if w.isClosed {
 		return
 	}
+	w.status = status
 	w.statusChan <- status
 	<-w.statusResp
 }
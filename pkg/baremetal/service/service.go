@@ -75,6 +75,7 @@ func (s *BaremetalService) StartService() {
 	common_options.StartOptionManager(&o.Options, o.Options.ConfigSyncPeriodSeconds, api.SERVICE_TYPE, api.SERVICE_VERSION, o.OnOptionsChange)
 
 	handler.InitHandlers(app)
+	cronman.AddCronJobHandler("", app)
 
 	s.startAgent(app)
 
@@ -15,16 +15,22 @@
 package app
 
 import (
+	"context"
 	"net"
 	"os"
 	"strconv"
 	"time"
 
 	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modules/yunionconf"
 	"yunion.io/x/onecloud/pkg/util/seclib2"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Application {
@@ -40,9 +46,80 @@ func InitApp(options *common_options.BaseOptions, dbAccess bool) *appsrv.Applica
 	if options.EnableAppProfiling {
 		app.EnableProfiling()
 	}
+	initTracing(options)
+	initRateLimiter(app, options)
 	return app
 }
 
+func initRateLimiter(app *appsrv.Application, options *common_options.BaseOptions) {
+	if !options.EnableRateLimit {
+		return
+	}
+	limiter := appsrv.NewRateLimiter()
+	app.SetRateLimiter(limiter)
+	interval := time.Duration(options.RateLimitRefreshIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		for {
+			err := refreshRateLimitConfig(limiter, options.Region)
+			if err != nil {
+				log.Errorf("refresh rate limits fail: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func refreshRateLimitConfig(limiter *appsrv.SRateLimiter, region string) error {
+	if !auth.IsAuthed() {
+		// not authenticated yet, retry next time
+		return nil
+	}
+	s := auth.GetAdminSession(context.Background(), region)
+	param, err := yunionconf.Parameters.GetRateLimitSettings(s, nil)
+	if err != nil {
+		return errors.Wrap(err, "GetRateLimitSettings")
+	}
+	config := appsrv.SRateLimitConfig{}
+	if value, _ := param.Get("value"); value != nil {
+		err = value.Unmarshal(&config)
+		if err != nil {
+			return errors.Wrapf(err, "invalid rate limits %s", value)
+		}
+	}
+	limiter.SetConfig(config)
+	return nil
+}
+
+func initTracing(options *common_options.BaseOptions) {
+	var exporter tracing.ISpanExporter
+	switch options.TracingExporter {
+	case "otlp":
+		if len(options.TracingOtlpEndpoint) == 0 {
+			log.Errorf("tracing_otlp_endpoint is required by otlp tracing exporter, tracing disabled")
+			return
+		}
+		exporter = tracing.NewOtlpHttpExporter(options.TracingOtlpEndpoint)
+	case "file":
+		if len(options.TracingFile) == 0 {
+			log.Errorf("tracing_file is required by file tracing exporter, tracing disabled")
+			return
+		}
+		var err error
+		exporter, err = tracing.NewFileExporter(options.TracingFile)
+		if err != nil {
+			log.Errorf("create file tracing exporter fail: %v, tracing disabled", err)
+			return
+		}
+	default:
+		return
+	}
+	log.Infof("OpenTelemetry tracing enabled with %s exporter, sample ratio %f", options.TracingExporter, options.TracingSampleRatio)
+	tracing.Init(consts.GetServiceName(), exporter, options.TracingSampleRatio)
+}
+
 func ServeForever(app *appsrv.Application, options *common_options.BaseOptions) {
 	ServeForeverWithCleanup(app, options, nil)
 }
@@ -79,4 +156,8 @@ func ServeForeverExtended(app *appsrv.Application, options *common_options.BaseO
 		sslfile = options.SslKeyfile
 	}
 	app.ListenAndServeTLSWithCleanup2(addr, certfile, sslfile, onStop, isMaster)
+	if isMaster {
+		// flush the pending spans
+		tracing.Shutdown()
+	}
 }
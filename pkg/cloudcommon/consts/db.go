@@ -14,7 +14,11 @@
 
 package consts
 
-import "yunion.io/x/log"
+import (
+	"time"
+
+	"yunion.io/x/log"
+)
 
 var (
 	QueryOffsetOptimization = false
@@ -29,6 +33,9 @@ var (
 
 	taskWorkerCount      int
 	localTaskWorkerCount int
+	taskResumeMaxTries   int
+
+	taskDefaultStageTimeoutSeconds int
 )
 
 func SetDefaultDB(dialect, connStr string) {
@@ -71,3 +78,19 @@ func TaskWorkerCount() int {
 func LocalTaskWorkerCount() int {
 	return localTaskWorkerCount
 }
+
+func SetTaskResumeMaxTries(cnt int) {
+	taskResumeMaxTries = cnt
+}
+
+func TaskResumeMaxTries() int {
+	return taskResumeMaxTries
+}
+
+func SetTaskDefaultStageTimeoutSeconds(sec int) {
+	taskDefaultStageTimeoutSeconds = sec
+}
+
+func TaskDefaultStageTimeout() time.Duration {
+	return time.Duration(taskDefaultStageTimeoutSeconds) * time.Second
+}
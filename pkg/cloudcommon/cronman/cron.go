@@ -0,0 +1,264 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+)
+
+type sCronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSecond = sCronField{name: "second", min: 0, max: 59}
+	cronMinute = sCronField{name: "minute", min: 0, max: 59}
+	cronHour   = sCronField{name: "hour", min: 0, max: 23}
+	cronDom    = sCronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = sCronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 0 and 7 are both sunday
+	cronDow = sCronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// TimerCron is a timer driven by a standard cron expression
+type TimerCron struct {
+	spec string
+
+	second uint64
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// whether day of month or day of week is restricted, if both are restricted,
+	// a day matching either of them is matched, the same as vixie cron
+	domStar bool
+	dowStar bool
+
+	location *time.Location
+}
+
+// ParseCronSpec parses a cron expression of 5 fields (minute hour dom month dow)
+// or 6 fields (second minute hour dom month dow), or one of the descriptors
+// @yearly, @monthly, @weekly, @daily and @hourly. The time zone can be specified
+// by a CRON_TZ= or TZ= prefix, e.g. "CRON_TZ=Asia/Shanghai 30 2 * * 1-5",
+// otherwise loc is used, nil loc means time.Local
+func ParseCronSpec(spec string, loc *time.Location) (*TimerCron, error) {
+	expr := strings.TrimSpace(spec)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		pos := strings.IndexAny(expr, " \t")
+		if pos < 0 {
+			return nil, errors.Errorf("missing cron fields after time zone in %q", spec)
+		}
+		tz := expr[strings.Index(expr, "=")+1 : pos]
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid time zone %s", tz)
+		}
+		expr = strings.TrimSpace(expr[pos:])
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	if strings.HasPrefix(expr, "@") {
+		desc, ok := cronDescriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, errors.Errorf("unsupported cron descriptor %s", expr)
+		}
+		expr = desc
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, errors.Errorf("expect 5 or 6 cron fields, got %d in %q", len(fields), spec)
+	}
+
+	timer := &TimerCron{
+		spec:     spec,
+		location: loc,
+	}
+	var err error
+	for _, f := range []struct {
+		field sCronField
+		value string
+		bits  *uint64
+	}{
+		{cronSecond, fields[0], &timer.second},
+		{cronMinute, fields[1], &timer.minute},
+		{cronHour, fields[2], &timer.hour},
+		{cronDom, fields[3], &timer.dom},
+		{cronMonth, fields[4], &timer.month},
+		{cronDow, fields[5], &timer.dow},
+	} {
+		*f.bits, err = f.field.parse(f.value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s %q", f.field.name, f.value)
+		}
+	}
+	// sunday can be either 0 or 7
+	if timer.dow&(1<<7) > 0 {
+		timer.dow |= 1
+	}
+	timer.domStar = isCronStar(fields[3])
+	timer.dowStar = isCronStar(fields[5])
+	return timer, nil
+}
+
+func isCronStar(val string) bool {
+	return val == "*" || val == "?"
+}
+
+func (f sCronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		if len(item) == 0 {
+			return 0, errors.Errorf("empty item")
+		}
+		rangeStr, step := item, 1
+		if pos := strings.Index(item, "/"); pos >= 0 {
+			var err error
+			rangeStr = item[:pos]
+			step, err = strconv.Atoi(item[pos+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step %q", item[pos+1:])
+			}
+		}
+		start, end := f.min, f.max
+		if !isCronStar(rangeStr) {
+			var err error
+			if pos := strings.Index(rangeStr, "-"); pos >= 0 {
+				start, err = f.value(rangeStr[:pos])
+				if err != nil {
+					return 0, err
+				}
+				end, err = f.value(rangeStr[pos+1:])
+				if err != nil {
+					return 0, err
+				}
+			} else {
+				start, err = f.value(rangeStr)
+				if err != nil {
+					return 0, err
+				}
+				if strings.Contains(item, "/") {
+					// a/n means from a to max every n
+					end = f.max
+				} else {
+					end = start
+				}
+			}
+		}
+		if start > end {
+			return 0, errors.Errorf("invalid range %q", rangeStr)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (f sCronField) value(str string) (int, error) {
+	if v, ok := f.names[strings.ToLower(str)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, errors.Errorf("invalid value %q", str)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (t *TimerCron) String() string {
+	return t.spec
+}
+
+func (t *TimerCron) Location() *time.Location {
+	return t.location
+}
+
+func (t *TimerCron) matchDay(tm time.Time) bool {
+	domMatch := t.dom&(1<<uint(tm.Day())) > 0
+	dowMatch := t.dow&(1<<uint(tm.Weekday())) > 0
+	if t.domStar || t.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first time matching the expression after now, zero time
+// is returned if no time is matched within 5 years
+func (t *TimerCron) Next(now time.Time) time.Time {
+	origLoc := now.Location()
+	tm := now.In(t.location).Truncate(time.Second).Add(time.Second)
+	yearLimit := tm.Year() + 5
+
+	for tm.Year() <= yearLimit {
+		if t.month&(1<<uint(tm.Month())) == 0 {
+			tm = time.Date(tm.Year(), tm.Month()+1, 1, 0, 0, 0, 0, t.location)
+			continue
+		}
+		if !t.matchDay(tm) {
+			tm = time.Date(tm.Year(), tm.Month(), tm.Day()+1, 0, 0, 0, 0, t.location)
+			continue
+		}
+		// move forward by durations instead of time.Date to go across the
+		// daylight saving time transitions correctly
+		if t.hour&(1<<uint(tm.Hour())) == 0 {
+			tm = tm.Add(time.Duration(60-tm.Minute())*time.Minute - time.Duration(tm.Second())*time.Second)
+			continue
+		}
+		if t.minute&(1<<uint(tm.Minute())) == 0 {
+			tm = tm.Add(time.Duration(60-tm.Second()) * time.Second)
+			continue
+		}
+		if t.second&(1<<uint(tm.Second())) == 0 {
+			tm = tm.Add(time.Second)
+			continue
+		}
+		return tm.In(origLoc)
+	}
+	return time.Time{}
+}
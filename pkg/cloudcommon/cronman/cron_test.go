@@ -0,0 +1,117 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSpec(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("load location: %s", err)
+	}
+	cases := []struct {
+		spec string
+		now  time.Time
+		want time.Time
+	}{
+		{
+			spec: "30 2 * * 1-5",
+			now:  time.Date(2024, 6, 7, 3, 0, 0, 0, shanghai), // friday
+			want: time.Date(2024, 6, 10, 2, 30, 0, 0, shanghai),
+		},
+		{
+			spec: "CRON_TZ=Asia/Shanghai 30 2 * * mon-fri",
+			now:  time.Date(2024, 6, 6, 18, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 6, 6, 18, 30, 0, 0, time.UTC),
+		},
+		{
+			spec: "*/15 * * * * *",
+			now:  time.Date(2024, 6, 7, 3, 0, 1, 0, shanghai),
+			want: time.Date(2024, 6, 7, 3, 0, 15, 0, shanghai),
+		},
+		{
+			// day of month or day of week
+			spec: "0 0 13 * 5",
+			now:  time.Date(2024, 6, 1, 0, 0, 0, 0, shanghai),
+			want: time.Date(2024, 6, 7, 0, 0, 0, 0, shanghai),
+		},
+		{
+			spec: "0 0 29 2 *",
+			now:  time.Date(2024, 3, 1, 0, 0, 0, 0, shanghai),
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, shanghai),
+		},
+		{
+			spec: "@monthly",
+			now:  time.Date(2024, 12, 15, 0, 0, 0, 0, shanghai),
+			want: time.Date(2025, 1, 1, 0, 0, 0, 0, shanghai),
+		},
+		{
+			spec: "0 0 * * 7",
+			now:  time.Date(2024, 6, 7, 0, 0, 0, 0, shanghai),
+			want: time.Date(2024, 6, 9, 0, 0, 0, 0, shanghai),
+		},
+	}
+	for _, c := range cases {
+		timer, err := ParseCronSpec(c.spec, shanghai)
+		if err != nil {
+			t.Errorf("parse %s: %s", c.spec, err)
+			continue
+		}
+		got := timer.Next(c.now)
+		if !got.Equal(c.want) {
+			t.Errorf("%s next of %s want %s got %s", c.spec, c.now, c.want, got)
+		}
+	}
+
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every",
+		"TZ=Mars/Base * * * * *",
+	} {
+		if _, err := ParseCronSpec(spec, nil); err == nil {
+			t.Errorf("parse invalid spec %q should fail", spec)
+		}
+	}
+}
+
+func TestSCronJob_missedRuns(t *testing.T) {
+	timer, err := ParseCronSpec("0 * * * *", time.UTC)
+	if err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	job := &SCronJob{Name: "test", Timer: timer}
+	last := time.Date(2024, 6, 7, 1, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 6, 7, 4, 30, 0, 0, time.UTC)
+	runs := job.missedRuns(last, now)
+	if len(runs) != 3 {
+		t.Fatalf("want 3 missed runs, got %v", runs)
+	}
+	if !runs[2].Equal(time.Date(2024, 6, 7, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected last missed run %s", runs[2])
+	}
+	if runs := job.missedRuns(time.Time{}, now); len(runs) != 0 {
+		t.Errorf("no missed runs without last run, got %v", runs)
+	}
+	if runs := job.missedRuns(now.AddDate(-1, 0, 0), now); len(runs) != maxMissedRuns {
+		t.Errorf("missed runs should be limited to %d, got %d", maxMissedRuns, len(runs))
+	}
+}
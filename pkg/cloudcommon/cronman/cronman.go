@@ -18,8 +18,12 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
+	"math/rand"
+	"os"
 	"runtime/debug"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"yunion.io/x/log"
@@ -38,6 +42,23 @@ import (
 var (
 	DefaultAdminSessionGenerator = auth.AdminCredential
 	ErrCronJobNameConflict       = errors.Error("Cron job Name Conflict")
+	ErrNotLeader                 = errors.Error("Not the leader of cron jobs")
+)
+
+type TMissedRunPolicy string
+
+const (
+	// the runs missed while the leader was down are skipped
+	MissedRunSkip = TMissedRunPolicy("skip")
+	// run once for all the runs missed while the leader was down
+	MissedRunOnce = TMissedRunPolicy("once")
+	// run every missed run in order, each with its scheduled start time
+	MissedRunCatchUp = TMissedRunPolicy("catchup")
+
+	maxMissedRuns = 100
+
+	// the unchanged states are saved at most once in the interval
+	stateSaveInterval = 10 * time.Minute
 )
 
 type TCronJobFunction func(ctx context.Context, userCred mcclient.TokenCredential, isStart bool)
@@ -57,6 +78,10 @@ func (t *Timer1) Next(now time.Time) time.Time {
 	return now.Add(t.dur)
 }
 
+func (t *Timer1) String() string {
+	return fmt.Sprintf("every %s", t.dur)
+}
+
 type Timer2 struct {
 	day, hour, min, sec int
 }
@@ -70,6 +95,10 @@ func (t *Timer2) Next(now time.Time) time.Time {
 	return nextTime
 }
 
+func (t *Timer2) String() string {
+	return fmt.Sprintf("every %d days at %02d:%02d:%02d", t.day, t.hour, t.min, t.sec)
+}
+
 type TimerHour struct {
 	hour, min, sec int
 }
@@ -83,6 +112,24 @@ func (t *TimerHour) Next(now time.Time) time.Time {
 	return nextTime
 }
 
+func (t *TimerHour) String() string {
+	return fmt.Sprintf("every %d hours at xx:%02d:%02d", t.hour, t.min, t.sec)
+}
+
+type SCronJobOptions struct {
+	// time zone of the cron expression, default is local time zone, which
+	// is overridden by the CRON_TZ= prefix of the expression
+	TimeZone string
+	// how to deal with the runs missed while the leader was down, default is skip
+	MissedRunPolicy TMissedRunPolicy
+	// random delay added to each run to avoid thundering herds
+	Jitter time.Duration
+	// max number of concurrently queued or running instances of the job,
+	// a run exceeding the limit is skipped, zero means no limit
+	MaxConcurrency int
+	StartRun       bool
+}
+
 type SCronJob struct {
 	Name             string
 	job              TCronJobFunction
@@ -91,6 +138,31 @@ type SCronJob struct {
 	Next             time.Time
 	StartRun         bool
 	times            []time.Time
+
+	jitter          time.Duration
+	maxConcurrency  int
+	missedRunPolicy TMissedRunPolicy
+	running         int32
+
+	state     SCronJobState
+	stateLock sync.Mutex
+	// the state in the store and when it is saved
+	savedState SCronJobState
+	savedAt    time.Time
+}
+
+type SCronJobInfo struct {
+	Name  string `json:"name"`
+	Timer string `json:"timer"`
+	// only available on the leader
+	NextRun time.Time `json:"next_run"`
+	LastRun time.Time `json:"last_run"`
+	// duration of the last run in seconds
+	LastDuration float64 `json:"last_duration"`
+	LastError    string  `json:"last_error"`
+	Paused       bool    `json:"paused"`
+	// number of the queued or running instances
+	Running int `json:"running"`
 }
 
 type CronJobTimerHeap []*SCronJob
@@ -140,15 +212,19 @@ type SCronJobManager struct {
 	running  bool
 	workers  *appsrv.SWorkerManager
 	dataLock *sync.Mutex
+	store    ICronJobStateStore
+	identity string
 }
 
 func InitCronJobManager(isDbWorker bool, workerCount int) *SCronJobManager {
 	if manager == nil {
+		hostname, _ := os.Hostname()
 		manager = &SCronJobManager{
 			jobs:     make([]*SCronJob, 0),
 			workers:  appsrv.NewWorkerManager("CronJobWorkers", workerCount, 1024, isDbWorker),
 			dataLock: new(sync.Mutex),
 			add:      make(chan struct{}),
+			identity: fmt.Sprintf("%s(pid %d)", hostname, os.Getpid()),
 		}
 	}
 	return manager
@@ -171,6 +247,71 @@ func (self *SCronJobManager) String() string {
 	return self.jobs.String()
 }
 
+// SetStateStore sets the store persisting the states of the jobs, the store
+// of etcd is used by default if the manager is started with election
+func (self *SCronJobManager) SetStateStore(store ICronJobStateStore) {
+	self.store = store
+}
+
+// AddJobWithCron adds a job scheduled by a cron expression, see ParseCronSpec
+func (self *SCronJobManager) AddJobWithCron(name string, spec string, jobFunc TCronJobFunction, opts SCronJobOptions) error {
+	job := SCronJob{
+		Name: name,
+		job:  jobFunc,
+	}
+	return self.addCronJob(&job, spec, opts)
+}
+
+func (self *SCronJobManager) AddJobWithCronWithStartTime(name string, spec string, jobFunc TCronJobFunctionWithStartTime, opts SCronJobOptions) error {
+	job := SCronJob{
+		Name:             name,
+		jobWithStartTime: jobFunc,
+	}
+	return self.addCronJob(&job, spec, opts)
+}
+
+func (self *SCronJobManager) addCronJob(job *SCronJob, spec string, opts SCronJobOptions) error {
+	loc := time.Local
+	if len(opts.TimeZone) > 0 {
+		var err error
+		loc, err = time.LoadLocation(opts.TimeZone)
+		if err != nil {
+			return errors.Wrapf(err, "invalid time zone %s", opts.TimeZone)
+		}
+	}
+	timer, err := ParseCronSpec(spec, loc)
+	if err != nil {
+		return errors.Wrapf(err, "ParseCronSpec %s", spec)
+	}
+	switch opts.MissedRunPolicy {
+	case "", MissedRunSkip, MissedRunOnce, MissedRunCatchUp:
+	default:
+		return errors.Errorf("invalid missed run policy %s", opts.MissedRunPolicy)
+	}
+	if opts.Jitter < 0 || opts.MaxConcurrency < 0 {
+		return errors.Error("AddJobWithCron: jitter and max concurrency must >= 0")
+	}
+
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	if !self.IsNameUnique(job.Name) {
+		return ErrCronJobNameConflict
+	}
+
+	job.Timer = timer
+	job.StartRun = opts.StartRun
+	job.jitter = opts.Jitter
+	job.maxConcurrency = opts.MaxConcurrency
+	job.missedRunPolicy = opts.MissedRunPolicy
+	if !self.running {
+		self.jobs = append(self.jobs, job)
+	} else {
+		self.addJob(job)
+	}
+	return nil
+}
+
 func (self *SCronJobManager) AddJobAtIntervals(name string, interval time.Duration, jobFunc TCronJobFunction) error {
 	return self.AddJobAtIntervalsWithStartRun(name, interval, jobFunc, false)
 }
@@ -312,7 +453,7 @@ func (self *SCronJobManager) AddJobEveryFewHour(name string, hour, min, sec int,
 
 func (self *SCronJobManager) addJob(newJob *SCronJob) {
 	now := time.Now()
-	newJob.Next = newJob.Timer.Next(now)
+	newJob.Next = newJob.nextTime(now)
 	if newJob.StartRun {
 		newJob.runJob(true, now)
 	}
@@ -340,7 +481,7 @@ func (self *SCronJobManager) Remove(name string) error {
 
 func (self *SCronJobManager) next(now time.Time) {
 	for _, job := range self.jobs {
-		job.Next = job.Timer.Next(now)
+		job.Next = job.nextTime(now)
 	}
 }
 
@@ -350,6 +491,10 @@ func (self *SCronJobManager) Start2(ctx context.Context, electObj *elect.Elect)
 		self.start(ctx)
 		return
 	}
+	if self.store == nil {
+		// keep out of the key prefix of the election mutex
+		self.store = NewEtcdStateStore(electObj.Client(), electObj.Path()+"-cronjobs")
+	}
 	electObj.SubscribeWithAction(ctx, func() { self.start(ctx) }, self.Stop)
 }
 
@@ -378,11 +523,20 @@ func (self *SCronJobManager) init() {
 	now := time.Now()
 	self.next(now)
 	heap.Init(&self.jobs)
+	if self.store != nil {
+		err := self.store.SetLeader(context.Background(), self.identity)
+		if err != nil {
+			log.Errorf("set cron job leader fail: %s", err)
+		}
+	}
 	for i := 0; i < len(self.jobs); i += 1 {
+		last := self.jobs[i].getState().LastRun
+		self.jobs[i].loadState(self.store)
 		if self.jobs[i].StartRun {
 			self.jobs[i].StartRun = false
 			self.jobs[i].runJob(true, now)
 		}
+		self.jobs[i].runMissedJobs(last, now)
 	}
 }
 
@@ -415,8 +569,12 @@ func (self *SCronJobManager) runJobs(now time.Time) {
 	defer self.dataLock.Unlock()
 	for i := 0; i < len(self.jobs); i++ {
 		if !(self.jobs[i].Next.After(now) || self.jobs[i].Next.IsZero()) {
-			self.jobs[i].runJob(false, now)
-			self.jobs[i].Next = self.jobs[i].Timer.Next(now)
+			if self.jobs[i].getState().Paused {
+				log.Debugf("Cron job %s is paused, skip run at %s", self.jobs[i].Name, now)
+			} else {
+				self.jobs[i].runJob(false, now)
+			}
+			self.jobs[i].Next = self.jobs[i].nextTime(now)
 			heap.Fix(&self.jobs, i)
 		}
 	}
@@ -435,15 +593,154 @@ func (job *SCronJob) Dump() string {
 	return ""
 }
 
+func (job *SCronJob) nextTime(now time.Time) time.Time {
+	next := job.Timer.Next(now)
+	if job.jitter > 0 && !next.IsZero() {
+		next = next.Add(time.Duration(rand.Int63n(int64(job.jitter))))
+	}
+	return next
+}
+
+// missedRuns returns the scheduled times between last and now
+func (job *SCronJob) missedRuns(last, now time.Time) []time.Time {
+	runs := make([]time.Time, 0)
+	if last.IsZero() {
+		return runs
+	}
+	for tm := job.Timer.Next(last); !tm.IsZero() && !tm.After(now); tm = job.Timer.Next(tm) {
+		runs = append(runs, tm)
+		if len(runs) >= maxMissedRuns {
+			break
+		}
+	}
+	return runs
+}
+
+func (job *SCronJob) getState() SCronJobState {
+	job.stateLock.Lock()
+	defer job.stateLock.Unlock()
+	return job.state
+}
+
+// loadState loads the state saved by the previous leader if it is newer
+func (job *SCronJob) loadState(store ICronJobStateStore) {
+	if store == nil {
+		return
+	}
+	state, err := store.GetJobState(context.Background(), job.Name)
+	if err != nil {
+		log.Errorf("get state of cron job %s fail: %s", job.Name, err)
+		return
+	}
+	job.stateLock.Lock()
+	defer job.stateLock.Unlock()
+	job.savedState = *state
+	if !state.LastRun.Before(job.state.LastRun) {
+		job.state = *state
+	}
+}
+
+// needSaveState returns whether the state should be written to the store. The
+// changes of pause and error are saved at once, the last run is saved at once
+// only if it is needed to find out the missed runs, the other changes are
+// throttled by stateSaveInterval to keep the writes off the runs of the jobs
+func needSaveState(saved, state SCronJobState, savedAt, now time.Time, policy TMissedRunPolicy) bool {
+	if saved.Paused != state.Paused || saved.LastError != state.LastError {
+		return true
+	}
+	lastRunChanged := !saved.LastRun.Equal(state.LastRun)
+	if lastRunChanged && (policy == MissedRunOnce || policy == MissedRunCatchUp) {
+		return true
+	}
+	if !lastRunChanged && saved.LastDuration == state.LastDuration {
+		return false
+	}
+	return now.Sub(savedAt) >= stateSaveInterval
+}
+
+func (job *SCronJob) saveState(update func(state *SCronJobState)) {
+	job.stateLock.Lock()
+	defer job.stateLock.Unlock()
+	update(&job.state)
+	if manager.store == nil {
+		return
+	}
+	now := time.Now()
+	if !needSaveState(job.savedState, job.state, job.savedAt, now, job.missedRunPolicy) {
+		return
+	}
+	err := manager.store.SetJobState(context.Background(), job.Name, &job.state)
+	if err != nil {
+		log.Errorf("save state of cron job %s fail: %s", job.Name, err)
+		return
+	}
+	job.savedState = job.state
+	job.savedAt = now
+}
+
+func (job *SCronJob) getInfo() SCronJobInfo {
+	state := job.getState()
+	info := SCronJobInfo{
+		Name:         job.Name,
+		Timer:        fmt.Sprintf("%s", job.Timer),
+		NextRun:      job.Next,
+		LastRun:      state.LastRun,
+		LastDuration: state.LastDuration.Seconds(),
+		LastError:    state.LastError,
+		Paused:       state.Paused,
+		Running:      int(atomic.LoadInt32(&job.running)),
+	}
+	return info
+}
+
+func (job *SCronJob) runMissedJobs(localLast, now time.Time) {
+	if job.missedRunPolicy != MissedRunOnce && job.missedRunPolicy != MissedRunCatchUp {
+		return
+	}
+	last := job.getState().LastRun
+	if localLast.After(last) {
+		last = localLast
+	}
+	runs := job.missedRuns(last, now)
+	if len(runs) == 0 {
+		return
+	}
+	log.Infof("Cron job %s missed %d runs since %s, policy %s", job.Name, len(runs), last, job.missedRunPolicy)
+	if job.missedRunPolicy == MissedRunOnce {
+		runs = runs[len(runs)-1:]
+	}
+	for _, tm := range runs {
+		job.runJob(false, tm)
+	}
+}
+
 func (job *SCronJob) runJob(isStart bool, now time.Time) {
+	if job.maxConcurrency > 0 && int(atomic.LoadInt32(&job.running)) >= job.maxConcurrency {
+		log.Warningf("Cron job %s reaches max concurrency %d, skip run at %s", job.Name, job.maxConcurrency, now)
+		return
+	}
+	atomic.AddInt32(&job.running, 1)
 	job.StartRun = isStart
 	job.times = append(job.times, now)
-	manager.workers.Run(job, nil, nil)
+	if !manager.workers.Run(job, nil, nil) {
+		atomic.AddInt32(&job.running, -1)
+		job.times = job.times[:len(job.times)-1]
+	}
 }
 
 func (job *SCronJob) runJobInWorker(isStart bool, startTime time.Time) {
+	defer atomic.AddInt32(&job.running, -1)
+	runAt := time.Now()
+	lastError := ""
+	defer func() {
+		job.saveState(func(state *SCronJobState) {
+			state.LastDuration = time.Since(runAt)
+			state.LastError = lastError
+		})
+	}()
 	defer func() {
 		if r := recover(); r != nil {
+			lastError = fmt.Sprintf("%s", r)
 			log.Errorf("CronJob task %s run error: %s", job.Name, r)
 			debug.PrintStack()
 			yunionconf.BugReport.SendBugReport(context.Background(), version.GetShortString(), string(debug.Stack()), errors.Errorf("%s", r))
@@ -451,6 +748,9 @@ func (job *SCronJob) runJobInWorker(isStart bool, startTime time.Time) {
 	}()
 
 	log.Debugf("Cron job: %s started, startTime: %s", job.Name, startTime.Format(time.RFC3339))
+	job.saveState(func(state *SCronJobState) {
+		state.LastRun = startTime
+	})
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_APPNAME, fmt.Sprintf("%s/cron-service", consts.GetServiceName()))
 	ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_TASKNAME, fmt.Sprintf("%s-%d", job.Name, time.Now().Unix()))
@@ -461,3 +761,109 @@ func (job *SCronJob) runJobInWorker(isStart bool, startTime time.Time) {
 		job.jobWithStartTime(ctx, userCred, startTime, isStart)
 	}
 }
+
+func (self *SCronJobManager) findJob(name string) *SCronJob {
+	for i := 0; i < len(self.jobs); i++ {
+		if self.jobs[i].Name == name {
+			return self.jobs[i]
+		}
+	}
+	return nil
+}
+
+// IsLeader returns whether the jobs are scheduled by this replica
+func (self *SCronJobManager) IsLeader() bool {
+	return self.running
+}
+
+// GetLeader returns the identity of the replica scheduling the jobs
+func (self *SCronJobManager) GetLeader(ctx context.Context) (string, error) {
+	if self.running {
+		return self.identity, nil
+	}
+	if self.store == nil {
+		return "", nil
+	}
+	return self.store.GetLeader(ctx)
+}
+
+func (self *SCronJobManager) ListJobs(ctx context.Context) []SCronJobInfo {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	ret := make([]SCronJobInfo, 0, len(self.jobs))
+	for i := 0; i < len(self.jobs); i++ {
+		if !self.running {
+			// the states are updated by the leader
+			self.jobs[i].loadState(self.store)
+		}
+		ret = append(ret, self.jobs[i].getInfo())
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+func (self *SCronJobManager) GetJob(ctx context.Context, name string) (*SCronJobInfo, error) {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	job := self.findJob(name)
+	if job == nil {
+		return nil, errors.Wrapf(errors.ErrNotFound, "job %s", name)
+	}
+	if !self.running {
+		job.loadState(self.store)
+	}
+	info := job.getInfo()
+	return &info, nil
+}
+
+func (self *SCronJobManager) fetchLeaderJob(ctx context.Context, name string) (*SCronJob, error) {
+	job := self.findJob(name)
+	if job == nil {
+		return nil, errors.Wrapf(errors.ErrNotFound, "job %s", name)
+	}
+	if !self.running {
+		leader, _ := self.GetLeader(ctx)
+		return nil, errors.Wrapf(ErrNotLeader, "leader is %q", leader)
+	}
+	return job, nil
+}
+
+// TriggerJob runs the job immediately on the leader, paused jobs can also be triggered
+func (self *SCronJobManager) TriggerJob(ctx context.Context, name string) error {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	job, err := self.fetchLeaderJob(ctx, name)
+	if err != nil {
+		return err
+	}
+	log.Infof("Cron job %s triggered manually", name)
+	job.runJob(false, time.Now())
+	return nil
+}
+
+// PauseJob stops the scheduled runs of the job until it is resumed
+func (self *SCronJobManager) PauseJob(ctx context.Context, name string) error {
+	return self.setJobPaused(ctx, name, true)
+}
+
+func (self *SCronJobManager) ResumeJob(ctx context.Context, name string) error {
+	return self.setJobPaused(ctx, name, false)
+}
+
+func (self *SCronJobManager) setJobPaused(ctx context.Context, name string, paused bool) error {
+	self.dataLock.Lock()
+	defer self.dataLock.Unlock()
+
+	job, err := self.fetchLeaderJob(ctx, name)
+	if err != nil {
+		return err
+	}
+	log.Infof("Cron job %s set paused %v", name, paused)
+	job.saveState(func(state *SCronJobState) {
+		state.Paused = paused
+	})
+	return nil
+}
@@ -40,3 +40,30 @@ func TestSCronJobManager_AddRemoveJobs(t *testing.T) {
 	manager.AddJobEveryFewDays("Test7", 1, 1, 1, 1, testFunc, false)
 	t.Logf("Jobs \n%s", manager.String())
 }
+
+func TestNeedSaveState(t *testing.T) {
+	now := time.Date(2024, 6, 7, 4, 30, 0, 0, time.UTC)
+	saved := SCronJobState{LastRun: now.Add(-time.Minute), LastDuration: time.Second}
+	recent, stale := now.Add(-time.Minute), now.Add(-stateSaveInterval)
+	cases := []struct {
+		name    string
+		state   SCronJobState
+		savedAt time.Time
+		policy  TMissedRunPolicy
+		want    bool
+	}{
+		{name: "unchanged", state: saved, savedAt: stale, policy: MissedRunSkip, want: false},
+		{name: "paused", state: SCronJobState{LastRun: saved.LastRun, LastDuration: saved.LastDuration, Paused: true}, savedAt: recent, policy: MissedRunSkip, want: true},
+		{name: "error", state: SCronJobState{LastRun: saved.LastRun, LastDuration: saved.LastDuration, LastError: "panic"}, savedAt: recent, policy: MissedRunSkip, want: true},
+		{name: "run recently saved", state: SCronJobState{LastRun: now, LastDuration: saved.LastDuration}, savedAt: recent, policy: MissedRunSkip, want: false},
+		{name: "run long ago saved", state: SCronJobState{LastRun: now, LastDuration: saved.LastDuration}, savedAt: stale, policy: MissedRunSkip, want: true},
+		{name: "run of catching up job", state: SCronJobState{LastRun: now, LastDuration: saved.LastDuration}, savedAt: recent, policy: MissedRunCatchUp, want: true},
+		{name: "run once missed job", state: SCronJobState{LastRun: now, LastDuration: saved.LastDuration}, savedAt: recent, policy: MissedRunOnce, want: true},
+		{name: "duration", state: SCronJobState{LastRun: saved.LastRun, LastDuration: time.Minute}, savedAt: recent, policy: MissedRunOnce, want: false},
+	}
+	for _, c := range cases {
+		if got := needSaveState(saved, c.state, c.savedAt, now, c.policy); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
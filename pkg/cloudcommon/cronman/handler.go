@@ -0,0 +1,150 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/rbacscope"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+)
+
+const (
+	CRON_JOB_ACTION_TRIGGER = "trigger"
+	CRON_JOB_ACTION_PAUSE   = "pause"
+	CRON_JOB_ACTION_RESUME  = "resume"
+)
+
+// AddCronJobHandler adds the admin API to inspect and operate the cron jobs
+// under the API prefix of the service:
+//
+//	GET  <prefix>/cron_jobs
+//	GET  <prefix>/cron_jobs/<name>
+//	POST <prefix>/cron_jobs/<name>/trigger|pause|resume
+//
+// the access is checked against the system scope policies of the resource
+// cron-jobs of the service
+func AddCronJobHandler(prefix string, app *appsrv.Application) {
+	app.AddHandler2("GET", fmt.Sprintf("%s/cron_jobs", prefix), auth.Authenticate(listCronJobsHandler), nil, "list_cron_jobs", nil)
+	app.AddHandler2("GET", fmt.Sprintf("%s/cron_jobs/<name>", prefix), auth.Authenticate(getCronJobHandler), nil, "get_cron_job", nil)
+	app.AddHandler2("POST", fmt.Sprintf("%s/cron_jobs/<name>/<action>", prefix), auth.Authenticate(performCronJobHandler), nil, "perform_cron_job", nil)
+}
+
+// allowCronJobAction is replaced in tests where the policies are not loaded
+var allowCronJobAction = func(userCred mcclient.TokenCredential, action string, extra ...string) bool {
+	result := policy.PolicyManager.Allow(rbacscope.ScopeSystem, userCred, consts.GetServiceType(), "cron-jobs", action, extra...)
+	return result.Result.IsAllow()
+}
+
+func fetchCronJobManager(ctx context.Context, w http.ResponseWriter, action string, extra ...string) *SCronJobManager {
+	userCred := auth.FetchUserCredential(ctx, policy.FilterPolicyCredential)
+	if userCred == nil || !allowCronJobAction(userCred, action, extra...) {
+		httperrors.ForbiddenError(ctx, w, "not allow to %s cron jobs", action)
+		return nil
+	}
+	if manager == nil {
+		httperrors.NotFoundError(ctx, w, "no cron jobs in this service")
+		return nil
+	}
+	return manager
+}
+
+func sendCronJobError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case errors.ErrNotFound:
+		httperrors.NotFoundError(ctx, w, "%v", err)
+	case ErrNotLeader:
+		// the jobs can only be operated on the leader, the error tells which one it is
+		httperrors.ConflictError(ctx, w, "%v", err)
+	default:
+		httperrors.GeneralServerError(ctx, w, err)
+	}
+}
+
+func listCronJobsHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	man := fetchCronJobManager(ctx, w, policy.PolicyActionList)
+	if man == nil {
+		return
+	}
+	leader, err := man.GetLeader(ctx)
+	if err != nil {
+		sendCronJobError(ctx, w, errors.Wrap(err, "GetLeader"))
+		return
+	}
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.Marshal(man.ListJobs(ctx)), "cron_jobs")
+	ret.Add(jsonutils.NewString(leader), "leader")
+	ret.Add(jsonutils.NewBool(man.IsLeader()), "is_leader")
+	appsrv.SendJSON(w, ret)
+}
+
+func getCronJobHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	man := fetchCronJobManager(ctx, w, policy.PolicyActionGet)
+	if man == nil {
+		return
+	}
+	params, _, _ := appsrv.FetchEnv(ctx, w, r)
+	info, err := man.GetJob(ctx, params["<name>"])
+	if err != nil {
+		sendCronJobError(ctx, w, err)
+		return
+	}
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.Marshal(info), "cron_job")
+	appsrv.SendJSON(w, ret)
+}
+
+func performCronJobHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	params, _, _ := appsrv.FetchEnv(ctx, w, r)
+	name, action := params["<name>"], params["<action>"]
+	man := fetchCronJobManager(ctx, w, policy.PolicyActionPerform, action)
+	if man == nil {
+		return
+	}
+	var err error
+	switch action {
+	case CRON_JOB_ACTION_TRIGGER:
+		err = man.TriggerJob(ctx, name)
+	case CRON_JOB_ACTION_PAUSE:
+		err = man.PauseJob(ctx, name)
+	case CRON_JOB_ACTION_RESUME:
+		err = man.ResumeJob(ctx, name)
+	default:
+		httperrors.InputParameterError(ctx, w, "unsupported action %s", action)
+		return
+	}
+	if err != nil {
+		sendCronJobError(ctx, w, err)
+		return
+	}
+	info, err := man.GetJob(ctx, name)
+	if err != nil {
+		sendCronJobError(ctx, w, err)
+		return
+	}
+	ret := jsonutils.NewDict()
+	ret.Add(jsonutils.Marshal(info), "cron_job")
+	appsrv.SendJSON(w, ret)
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"yunion.io/x/pkg/appctx"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type testStateStore struct {
+	leader string
+	states map[string]*SCronJobState
+}
+
+func (store *testStateStore) GetJobState(ctx context.Context, name string) (*SCronJobState, error) {
+	if state, ok := store.states[name]; ok {
+		return state, nil
+	}
+	return &SCronJobState{}, nil
+}
+
+func (store *testStateStore) SetJobState(ctx context.Context, name string, state *SCronJobState) error {
+	store.states[name] = state
+	return nil
+}
+
+func (store *testStateStore) GetLeader(ctx context.Context) (string, error) {
+	return store.leader, nil
+}
+
+func (store *testStateStore) SetLeader(ctx context.Context, leader string) error {
+	store.leader = leader
+	return nil
+}
+
+type testHandlerCase struct {
+	name      string
+	handler   func(ctx context.Context, w http.ResponseWriter, r *http.Request)
+	method    string
+	params    map[string]string
+	running   bool
+	allow     bool
+	wantCode  int
+	wantInMsg string
+}
+
+func (c testHandlerCase) run(t *testing.T, store *testStateStore) {
+	oldManager, oldAllow := manager, allowCronJobAction
+	defer func() {
+		manager, allowCronJobAction = oldManager, oldAllow
+	}()
+	manager = &SCronJobManager{
+		jobs:     CronJobTimerHeap{&SCronJob{Name: "Test1"}},
+		dataLock: new(sync.Mutex),
+		running:  c.running,
+		store:    store,
+		identity: "node1",
+	}
+	var actions []string
+	allowCronJobAction = func(userCred mcclient.TokenCredential, action string, extra ...string) bool {
+		actions = append(actions, strings.Join(append([]string{action}, extra...), "/"))
+		return c.allow
+	}
+
+	ctx := context.WithValue(context.Background(), appctx.APP_CONTEXT_KEY_AUTH_TOKEN, &mcclient.SSimpleToken{User: "test"})
+	ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_PARAMS, c.params)
+	w := httptest.NewRecorder()
+	c.handler(ctx, w, httptest.NewRequest(c.method, "/cron_jobs", nil))
+
+	if w.Code != c.wantCode {
+		t.Errorf("%s: code = %d, want %d: %s", c.name, w.Code, c.wantCode, w.Body.String())
+	}
+	if len(c.wantInMsg) > 0 && !strings.Contains(w.Body.String(), c.wantInMsg) {
+		t.Errorf("%s: body %s should contain %q", c.name, w.Body.String(), c.wantInMsg)
+	}
+	if len(actions) != 1 {
+		t.Errorf("%s: policy checked %d times: %v", c.name, len(actions), actions)
+	}
+}
+
+func TestCronJobHandlers(t *testing.T) {
+	store := &testStateStore{
+		leader: "node2",
+		states: map[string]*SCronJobState{},
+	}
+	cases := []testHandlerCase{
+		{
+			name:     "list forbidden",
+			handler:  listCronJobsHandler,
+			method:   "GET",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:      "list on follower",
+			handler:   listCronJobsHandler,
+			method:    "GET",
+			allow:     true,
+			wantCode:  http.StatusOK,
+			wantInMsg: `"leader":"node2"`,
+		},
+		{
+			name:      "get not found",
+			handler:   getCronJobHandler,
+			method:    "GET",
+			params:    map[string]string{"<name>": "Test2"},
+			allow:     true,
+			wantCode:  http.StatusNotFound,
+			wantInMsg: "Test2",
+		},
+		{
+			name:     "pause forbidden",
+			handler:  performCronJobHandler,
+			method:   "POST",
+			params:   map[string]string{"<name>": "Test1", "<action>": CRON_JOB_ACTION_PAUSE},
+			running:  true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:      "pause on follower",
+			handler:   performCronJobHandler,
+			method:    "POST",
+			params:    map[string]string{"<name>": "Test1", "<action>": CRON_JOB_ACTION_PAUSE},
+			allow:     true,
+			wantCode:  http.StatusConflict,
+			wantInMsg: "node2",
+		},
+		{
+			name:      "pause on leader",
+			handler:   performCronJobHandler,
+			method:    "POST",
+			params:    map[string]string{"<name>": "Test1", "<action>": CRON_JOB_ACTION_PAUSE},
+			running:   true,
+			allow:     true,
+			wantCode:  http.StatusOK,
+			wantInMsg: `"paused":true`,
+		},
+		{
+			name:     "unsupported action",
+			handler:  performCronJobHandler,
+			method:   "POST",
+			params:   map[string]string{"<name>": "Test1", "<action>": "stop"},
+			running:  true,
+			allow:    true,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, c := range cases {
+		c.run(t, store)
+	}
+	if state := store.states["Test1"]; state == nil || !state.Paused {
+		t.Errorf("paused state of Test1 not saved: %#v", state)
+	}
+}
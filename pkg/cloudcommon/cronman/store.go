@@ -0,0 +1,112 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cronman
+
+import (
+	"context"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+)
+
+// SCronJobState is the runtime state of a cron job shared by the replicas
+type SCronJobState struct {
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	// panic message of the last run
+	LastError string `json:"last_error"`
+	Paused    bool   `json:"paused"`
+}
+
+// ICronJobStateStore persists the states of the cron jobs and the identity of
+// the leader, so that a new leader can find out the runs missed while the
+// previous leader was down, and the states can be inspected on any replica
+type ICronJobStateStore interface {
+	GetJobState(ctx context.Context, name string) (*SCronJobState, error)
+	SetJobState(ctx context.Context, name string, state *SCronJobState) error
+
+	GetLeader(ctx context.Context) (string, error)
+	SetLeader(ctx context.Context, leader string) error
+}
+
+type sEtcdStateStore struct {
+	cli    *clientv3.Client
+	prefix string
+}
+
+func NewEtcdStateStore(cli *clientv3.Client, prefix string) ICronJobStateStore {
+	return &sEtcdStateStore{
+		cli:    cli,
+		prefix: prefix,
+	}
+}
+
+func (store *sEtcdStateStore) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := store.cli.Get(ctx, store.prefix+"/"+key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Get")
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (store *sEtcdStateStore) put(ctx context.Context, key string, val string) error {
+	_, err := store.cli.Put(ctx, store.prefix+"/"+key, val)
+	if err != nil {
+		return errors.Wrap(err, "Put")
+	}
+	return nil
+}
+
+func (store *sEtcdStateStore) GetJobState(ctx context.Context, name string) (*SCronJobState, error) {
+	state := &SCronJobState{}
+	val, err := store.get(ctx, "jobs/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) == 0 {
+		return state, nil
+	}
+	obj, err := jsonutils.Parse(val)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse state %s", val)
+	}
+	err = obj.Unmarshal(state)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	return state, nil
+}
+
+func (store *sEtcdStateStore) SetJobState(ctx context.Context, name string, state *SCronJobState) error {
+	return store.put(ctx, "jobs/"+name, jsonutils.Marshal(state).String())
+}
+
+func (store *sEtcdStateStore) GetLeader(ctx context.Context) (string, error) {
+	val, err := store.get(ctx, "leader")
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+func (store *sEtcdStateStore) SetLeader(ctx context.Context, leader string) error {
+	return store.put(ctx, "leader", leader)
+}
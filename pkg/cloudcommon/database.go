@@ -37,6 +37,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/util/dbutils"
+	"yunion.io/x/onecloud/pkg/util/dbutils/postgres"
 )
 
 func InitDB(options *common_options.DBOptions) {
@@ -84,6 +85,10 @@ func InitDB(options *common_options.DBOptions) {
 		)
 	case "clickhouse":
 		log.Fatalf("cannot use clickhouse as primary database")
+	case "postgres", "postgresql":
+		backend = postgres.PostgreSQLBackend
+		dialect = postgres.DriverName
+		sqlStr = "postgres://" + sqlStr
 	}
 	log.Infof("database dialect: %s sqlStr: %s", dialect, sqlStr)
 	// save configuration to consts
@@ -117,6 +122,8 @@ func InitDB(options *common_options.DBOptions) {
 		}
 	}
 
+	registerDBMetrics(sqlchemy.DefaultDB, db.ClickhouseDB)
+
 	switch options.LockmanMethod {
 	case common_options.LockMethodInMemory, "":
 		log.Infof("using inmemory lockman")
@@ -40,6 +40,7 @@ import (
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/util/dbutils/postgres"
 	"yunion.io/x/onecloud/pkg/util/filterclause"
 	"yunion.io/x/onecloud/pkg/util/logclient"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
@@ -709,6 +710,13 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 		limit = maxLimit
 	}
 
+	if postgres.IsPostgreSQL(manager.TableSpec().GetDBName()) && isDistinctQuery(q) {
+		// for SELECT DISTINCT, PostgreSQL requires the ORDER BY expressions to appear in the
+		// select list, which is not true for the extra order fields and the offset optimization,
+		// so query the distinct rows as a subquery to be ordered and paged
+		q = q.SubQuery().Query()
+	}
+
 	// orders defined in pagingConf should have the highest priority
 	if pagingConf != nil {
 		for _, f := range pagingConf.MarkerFields {
@@ -865,6 +873,10 @@ func ListItems(manager IModelManager, ctx context.Context, userCred mcclient.Tok
 	return calculateListResult(retList, totalCnt, totalJson, int(limit), int(offset), paginate), nil
 }
 
+func isDistinctQuery(q *sqlchemy.SQuery) bool {
+	return strings.HasPrefix(q.String(), "SELECT DISTINCT ")
+}
+
 // 构造list返回详情
 func calculateListResult(data []jsonutils.JSONObject, total int, totalJson jsonutils.JSONObject, limit, offset int, paginate bool) *printutils.ListResult {
 	if paginate {
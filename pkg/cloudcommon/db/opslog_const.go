@@ -265,6 +265,8 @@ const (
 	ACT_GUEST_PANICKED                   = "guest_panicked"
 	ACT_HOST_MAINTENANCE                 = "host_maintenance"
 	ACT_HOST_DOWN                        = "host_down"
+	ACT_HOST_FENCE                       = "host_fence"
+	ACT_HOST_FENCE_FAIL                  = "host_fence_fail"
 
 	ACT_UPLOAD_OBJECT  = "upload_obj"
 	ACT_DELETE_OBJECT  = "delete_obj"
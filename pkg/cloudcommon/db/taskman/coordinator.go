@@ -42,12 +42,14 @@ type ISingleTask interface {
 
 var ITaskType reflect.Type
 var IBatchTaskType reflect.Type
+var IResumableTaskType reflect.Type
 
 var taskTable map[string]reflect.Type
 
 func init() {
 	ITaskType = reflect.TypeOf((*ISingleTask)(nil)).Elem()
 	IBatchTaskType = reflect.TypeOf((*IBatchTask)(nil)).Elem()
+	IResumableTaskType = reflect.TypeOf((*IResumableTask)(nil)).Elem()
 
 	taskTable = make(map[string]reflect.Type)
 }
@@ -73,3 +75,23 @@ func isTaskExist(taskName string) bool {
 	_, ok := taskTable[taskName]
 	return ok
 }
+
+func isTaskResumable(taskName string) bool {
+	taskType, ok := taskTable[taskName]
+	if !ok {
+		return false
+	}
+	return reflect.PtrTo(taskType).Implements(IResumableTaskType)
+}
+
+func getTimeoutTask(taskName string) ITimeoutTask {
+	taskType, ok := taskTable[taskName]
+	if !ok {
+		return nil
+	}
+	timeoutTask, ok := reflect.New(taskType).Interface().(ITimeoutTask)
+	if !ok {
+		return nil
+	}
+	return timeoutTask
+}
@@ -48,3 +48,36 @@ type ITask interface {
 	SetProgressAndStatus(progress float32, status string) error
 	SetProgress(progress float32) error
 }
+
+// IResumableTask is implemented by the tasks that can be re-driven from their
+// last persisted stage and params after the service restarts, otherwise
+// unfinished tasks are marked as failed on restart
+type IResumableTask interface {
+	// PrepareResume is called with the task objects fetched and locked before the
+	// current stage is re-driven. The task should check whether it is idempotent
+	// to run the stage again and returns the data that the stage is called with.
+	// Returning nil data keeps the task waiting for the callback of the pending
+	// remote operation, returning error calls the failed handler of the stage.
+	// It is not named as On<Stage> to avoid being dispatched as a stage
+	PrepareResume(ctx context.Context, stage string) (jsonutils.JSONObject, error)
+}
+
+// ICancelableTask is implemented by the tasks that need to roll back when they
+// are cancelled or timed out, e.g. cancel the pending usages set by SetPendingUsage.
+// The failed handler of the current stage is called instead for the other tasks
+type ICancelableTask interface {
+	// OnCancel is called with the task objects fetched and locked before the
+	// task is marked as failed
+	OnCancel(ctx context.Context, reason string)
+}
+
+// ITimeoutTask is implemented by the tasks that have deadlines, a task which
+// exceeds its deadline is cancelled by CheckTimeoutTasks. The durations should
+// not depend on the states of the task
+type ITimeoutTask interface {
+	// GetTimeout returns the max duration of the whole task, zero means no limit
+	GetTimeout() time.Duration
+	// GetStageTimeout returns the max duration that the task stays on the stage, zero means
+	// the default stage timeout of the service applies
+	GetStageTimeout(stage string) time.Duration
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+)
+
+var taskStageCountDesc = prometheus.NewDesc(
+	"onecloud_task_stage_count",
+	"Number of unfinished tasks by task name and stage",
+	[]string{"task_name", "stage"}, nil,
+)
+
+type sTaskStageCollector struct{}
+
+var registerTaskStageCollectorOnce sync.Once
+
+// NewTaskStageCollector returns a prometheus collector of the numbers of the
+// unfinished tasks by task name and stage
+func NewTaskStageCollector() prometheus.Collector {
+	return &sTaskStageCollector{}
+}
+
+func (c *sTaskStageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- taskStageCountDesc
+}
+
+func (c *sTaskStageCollector) Collect(ch chan<- prometheus.Metric) {
+	q := TaskManager.Query().NotIn("stage", []string{TASK_STAGE_COMPLETE, TASK_STAGE_FAILED})
+	sq := q.SubQuery()
+	statQ := sq.Query(sq.Field("task_name"), sq.Field("stage"), sqlchemy.COUNT("count", sq.Field("id")))
+	statQ = statQ.GroupBy(sq.Field("task_name"), sq.Field("stage"))
+
+	ret := []struct {
+		TaskName string
+		Stage    string
+		Count    int64
+	}{}
+	err := statQ.All(&ret)
+	if err != nil {
+		log.Errorf("collect task stage count fail: %v", err)
+		return
+	}
+	for _, s := range ret {
+		ch <- prometheus.MustNewConstMetric(taskStageCountDesc, prometheus.GaugeValue, float64(s.Count), s.TaskName, s.Stage)
+	}
+}
+
+// RegisterTaskStageCollector registers the collector of the unfinished task
+// stages to the default prometheus registry, it is for the services keeping
+// the tasks table only
+func RegisterTaskStageCollector() {
+	registerTaskStageCollectorOnce.Do(func() {
+		err := prometheus.Register(NewTaskStageCollector())
+		if err != nil {
+			log.Warningf("register task stage collector fail: %v", err)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/sets"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/util/timeutils"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+)
+
+const (
+	replicaHeartbeatInterval = 30 * time.Second
+	// a replica is considered dead if it misses its heartbeats for this long
+	replicaHeartbeatExpire = 3 * time.Minute
+	// the records of the dead replicas are purged after this long
+	replicaPurgeExpire = 24 * time.Hour
+)
+
+// currentReplicaId identifies the running process of the service, the tasks run
+// by the process are owned by it
+var currentReplicaId = stringutils.UUID4()
+
+type STaskReplicaManager struct {
+	db.SModelBaseManager
+}
+
+var TaskReplicaManager *STaskReplicaManager
+
+func init() {
+	TaskReplicaManager = &STaskReplicaManager{SModelBaseManager: db.NewModelBaseManager(
+		STaskReplica{},
+		"task_replicas_tbl",
+		"task_replica",
+		"task_replicas",
+	)}
+}
+
+// STaskReplica is a running process of the service serving the tasks table
+type STaskReplica struct {
+	db.SModelBase
+
+	Id          string    `width:"36" charset:"ascii" nullable:"false" primary:"true"`
+	Hostname    string    `width:"128" charset:"utf8" nullable:"true"`
+	HeartbeatAt time.Time `nullable:"false" index:"true"`
+}
+
+func (manager *STaskReplicaManager) heartbeat(ctx context.Context) error {
+	hostname, _ := os.Hostname()
+	replica := &STaskReplica{
+		Id:          currentReplicaId,
+		Hostname:    hostname,
+		HeartbeatAt: timeutils.UtcNow(),
+	}
+	replica.SetModelManager(manager, replica)
+	err := manager.TableSpec().InsertOrUpdate(ctx, replica)
+	if err != nil {
+		return errors.Wrap(err, "InsertOrUpdate")
+	}
+	return nil
+}
+
+func (manager *STaskReplicaManager) purgeDeadReplicas() error {
+	sqlDB := sqlchemy.GetDBWithName(manager.TableSpec().GetDBName())
+	_, err := sqlDB.Exec(
+		fmt.Sprintf("delete from %s where heartbeat_at < ?", manager.TableSpec().Name()),
+		timeutils.UtcNow().Add(-replicaPurgeExpire),
+	)
+	if err != nil {
+		return errors.Wrap(err, "delete")
+	}
+	return nil
+}
+
+// getLiveReplicaIds returns the ids of the replicas with fresh heartbeats
+func (manager *STaskReplicaManager) getLiveReplicaIds() (sets.String, error) {
+	replicas := make([]STaskReplica, 0)
+	q := manager.Query().GT("heartbeat_at", timeutils.UtcNow().Add(-replicaHeartbeatExpire))
+	err := db.FetchModelObjects(manager, q, &replicas)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	ret := sets.NewString()
+	for i := range replicas {
+		ret.Insert(replicas[i].Id)
+	}
+	return ret, nil
+}
+
+// StartReplicaHeartbeat registers the process as a replica serving the tasks and
+// keeps its heartbeat, so that the tasks owned by it are not resumed by the others.
+// It should be called by every node of the service after the database is initialized
+func StartReplicaHeartbeat(ctx context.Context) {
+	err := TaskReplicaManager.heartbeat(ctx)
+	if err != nil {
+		log.Errorf("task replica %s heartbeat fail: %s", currentReplicaId, err)
+	}
+	go func() {
+		ticker := time.NewTicker(replicaHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := TaskReplicaManager.heartbeat(ctx)
+				if err != nil {
+					log.Errorf("task replica %s heartbeat fail: %s", currentReplicaId, err)
+				}
+				err = TaskReplicaManager.purgeDeadReplicas()
+				if err != nil {
+					log.Errorf("purge dead task replicas fail: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// isTaskOwnedByLiveReplica returns whether the task is run by a replica which is still alive,
+// the tasks without owner are left by the versions before the owner is recorded
+func isTaskOwnedByLiveReplica(replicaId string, liveReplicaIds sets.String) bool {
+	return len(replicaId) > 0 && liveReplicaIds.Has(replicaId)
+}
+
+// claimReplica takes over the task from its previous owner, it fails if another
+// replica has claimed the task in the meantime
+func (task *STask) claimReplica() (bool, error) {
+	tableSpec := task.GetModelManager().TableSpec()
+	sqlDB := sqlchemy.GetDBWithName(tableSpec.GetDBName())
+	sql := "update %s set replica_id = ?, updated_at = ? where id = ? and replica_id = ?"
+	args := []interface{}{currentReplicaId, timeutils.UtcNow(), task.Id, task.ReplicaId}
+	if len(task.ReplicaId) == 0 {
+		sql = "update %s set replica_id = ?, updated_at = ? where id = ? and (replica_id is null or replica_id = '')"
+		args = args[:3]
+	}
+	result, err := sqlDB.Exec(fmt.Sprintf(sql, tableSpec.Name()), args...)
+	if err != nil {
+		return false, errors.Wrap(err, "update")
+	}
+	cnt, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "RowsAffected")
+	}
+	if cnt == 0 {
+		return false, nil
+	}
+	task.ReplicaId = currentReplicaId
+	return true, nil
+}
+
+// saveReplica records the current replica as the owner of the task which is run
+// by the process
+func (task *STask) saveReplica() {
+	if task.ReplicaId == currentReplicaId {
+		return
+	}
+	_, err := db.Update(task, func() error {
+		task.ReplicaId = currentReplicaId
+		return nil
+	})
+	if err != nil {
+		log.Errorf("task %s save replica fail: %s", task.String(), err)
+	}
+}
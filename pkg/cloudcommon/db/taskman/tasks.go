@@ -41,6 +41,7 @@ import (
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/apis"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/quotas"
@@ -50,6 +51,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/modules/yunionconf"
 	"yunion.io/x/onecloud/pkg/util/logclient"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 const (
@@ -57,6 +59,7 @@ const (
 	PENDING_USAGE_KEY      = "__pending_usage__"
 	PARENT_TASK_NOTIFY_KEY = "__parent_task_notifyurl"
 	REQUEST_CONTEXT_KEY    = "__request_context"
+	TASK_TRACE_PARENT_KEY  = "__trace_parent"
 
 	TASK_STAGE_FAILED   = "failed"
 	TASK_STAGE_COMPLETE = "complete"
@@ -71,6 +74,10 @@ const (
 
 	LANG = "lang"
 
+	TASK_RESUME_KEY       = "__resume__"
+	TASK_RESUME_COUNT_KEY = "__resume_count"
+	TASK_CANCEL_KEY       = "__cancel__"
+
 	taskStatusDone    = "done"
 	TASK_STATUS_QUEUE = "queue"
 )
@@ -106,6 +113,10 @@ type STask struct {
 	StartAt time.Time `nullable:"true" list:"user" json:"start_at"`
 	// 完成任务时间
 	EndAt time.Time `nullable:"true" list:"user" json:"end_at"`
+	// 任务超时时间
+	TimeoutAt time.Time `nullable:"true" list:"user" json:"timeout_at"`
+	// 当前阶段超时时间
+	StageTimeoutAt time.Time `nullable:"true" list:"user" json:"stage_timeout_at"`
 
 	Id string `width:"36" charset:"ascii" primary:"true" list:"user"` // Column(VARCHAR(36, charset='ascii'), primary_key=True, default=get_uuid)
 
@@ -123,6 +134,9 @@ type STask struct {
 	// 父任务Id
 	ParentTaskId string `width:"36" charset:"ascii" list:"user" index:"true" json:"parent_task_id"`
 
+	// 运行任务的服务副本Id
+	ReplicaId string `width:"36" charset:"ascii" nullable:"true" list:"user" json:"replica_id"`
+
 	taskObject  db.IStandaloneModel   `ignore:"true"`
 	taskObjects []db.IStandaloneModel `ignore:"true"`
 
@@ -263,6 +277,10 @@ func fetchTaskParams(
 	if !reqContext.IsZero() {
 		data.Add(jsonutils.Marshal(&reqContext), REQUEST_CONTEXT_KEY)
 	}
+	// the stages of the task are traced as the children of the span creating the task
+	if traceParent := tracing.TraceParentFromContext(ctx); len(traceParent) > 0 {
+		data.Add(jsonutils.NewString(traceParent), TASK_TRACE_PARENT_KEY)
+	}
 	if len(parentTaskId) > 0 || len(parentTaskNotifyUrl) > 0 {
 		if len(parentTaskId) > 0 {
 			data.Add(jsonutils.NewString(parentTaskId), PARENT_TASK_ID_KEY)
@@ -295,6 +313,29 @@ func fetchTaskParams(
 	return data
 }
 
+func (task *STask) initTimeout() {
+	now := timeutils.UtcNow()
+	if timeoutTask := getTimeoutTask(task.TaskName); timeoutTask != nil {
+		if timeout := timeoutTask.GetTimeout(); timeout > 0 {
+			task.TimeoutAt = now.Add(timeout)
+		}
+	}
+	if timeout := getStageTimeout(task.TaskName, task.Stage); timeout > 0 {
+		task.StageTimeoutAt = now.Add(timeout)
+	}
+}
+
+// getStageTimeout returns the deadline duration of the stage of the task, the
+// default stage timeout of the service applies if the task does not limit the stage
+func getStageTimeout(taskName string, stage string) time.Duration {
+	if timeoutTask := getTimeoutTask(taskName); timeoutTask != nil {
+		if timeout := timeoutTask.GetStageTimeout(stage); timeout > 0 {
+			return timeout
+		}
+	}
+	return consts.TaskDefaultStageTimeout()
+}
+
 func (manager *STaskManager) NewTask(
 	ctx context.Context,
 	taskName string,
@@ -319,7 +360,9 @@ func (manager *STaskManager) NewTask(
 		Params:       data,
 		Stage:        TASK_INIT_STAGE,
 		ParentTaskId: parentTaskId,
+		ReplicaId:    currentReplicaId,
 	}
+	task.initTimeout()
 
 	ownerId := obj.GetOwnerId()
 	if ownerId != nil {
@@ -379,6 +422,7 @@ func (manager *STaskManager) NewParallelTask(
 		Stage:        TASK_INIT_STAGE,
 		ParentTaskId: parentTaskId,
 	}
+	task.initTimeout()
 	task.SetModelManager(manager, task)
 	err := manager.TableSpec().Insert(ctx, task)
 	if err != nil {
@@ -488,14 +532,37 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	ctxData := task.GetRequestContext()
 	ctx := ctxData.GetContext()
 
+	traceParent, _ := task.Params.GetString(TASK_TRACE_PARENT_KEY)
+	ctx, span := tracing.StartSpanFromTraceParent(ctx, traceParent, fmt.Sprintf("%s.%s", task.TaskName, task.Stage), tracing.SpanKindInternal)
+	span.SetAttribute("task.id", task.Id)
+	span.SetAttribute("task.name", task.TaskName)
+	span.SetAttribute("task.stage", task.Stage)
+	defer func() {
+		span.SetAttribute("task.next_stage", task.Stage)
+		if task.Stage == TASK_STAGE_FAILED {
+			reason, _ := task.Params.Get("__failed_reason")
+			span.SetError(errors.Errorf("%s", reason))
+		}
+		span.End()
+	}()
+
 	task.saveStartAt()
+	task.saveReplica()
 
 	taskFailed := false
+	isResume := false
+	isCancel := false
+	cancelReason := ""
 
 	var data jsonutils.JSONObject
 	if odata != nil {
 		switch dictdata := odata.(type) {
 		case *jsonutils.JSONDict:
+			isResume = jsonutils.QueryBoolean(dictdata, TASK_RESUME_KEY, false)
+			if dictdata.Contains(TASK_CANCEL_KEY) {
+				isCancel = true
+				cancelReason, _ = dictdata.GetString(TASK_CANCEL_KEY)
+			}
 			taskStatus, _ := odata.GetString("__status__")
 			if len(taskStatus) > 0 && taskStatus != "OK" {
 				taskFailed = true
@@ -520,21 +587,16 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		data = jsonutils.NewDict()
 	}
 
-	stageName := task.Stage
-	if taskFailed {
-		stageName = fmt.Sprintf("%sFailed", task.Stage)
-		if strings.Contains(stageName, "_") {
-			stageName = fmt.Sprintf("%s_failed", task.Stage)
-		}
+	if isCancel && task.isFinished() {
+		log.Warningf("Task %s has been finished on stage %s, skip cancel", task.TaskName, task.Stage)
+		return
 	}
 
-	if strings.Contains(stageName, "_") {
-		stageName = utils.Kebab2Camel(stageName, "_")
-	}
+	stageName := getStageFuncName(task.Stage, taskFailed)
 
 	funcValue := taskValue.MethodByName(stageName)
 
-	if !funcValue.IsValid() || funcValue.IsNil() {
+	if !isCancel && (!funcValue.IsValid() || funcValue.IsNil()) {
 		msg := fmt.Sprintf("Stage %s not found", stageName)
 		if taskFailed {
 			// failed handler is optional, ignore the error
@@ -651,6 +713,32 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 		}
 	}()
 
+	if isCancel {
+		execCancel(ctx, taskValue, task, cancelReason, params)
+		saveRequestContextFuncValue := taskValue.MethodByName("SaveRequestContext")
+		saveRequestContextFuncValue.Call([]reflect.Value{reflect.ValueOf(&ctxData)})
+		return
+	}
+
+	if isResume {
+		resumeFuncName, resumeData := prepareResume(ctx, taskValue, task.Stage)
+		if len(resumeFuncName) == 0 {
+			log.Infof("Task %s resumed, keep waiting on stage %s", task.TaskName, task.Stage)
+			return
+		}
+		stageName = resumeFuncName
+		funcValue = taskValue.MethodByName(stageName)
+		params[2] = reflect.ValueOf(resumeData)
+		if !funcValue.IsValid() || funcValue.IsNil() {
+			// failed handler is optional
+			SetStageFailedFuncValue := taskValue.MethodByName("SetStageFailed")
+			SetStageFailedFuncValue.Call([]reflect.Value{reflect.ValueOf(ctx), params[2]})
+			saveRequestContextFuncValue := taskValue.MethodByName("SaveRequestContext")
+			saveRequestContextFuncValue.Call([]reflect.Value{reflect.ValueOf(&ctxData)})
+			return
+		}
+	}
+
 	log.Debugf("Call %s %s %#v", task.TaskName, stageName, params)
 	funcValue.Call(params)
 
@@ -659,10 +747,118 @@ func execITask(taskValue reflect.Value, task *STask, odata jsonutils.JSONObject,
 	saveRequestContextFuncValue.Call([]reflect.Value{reflect.ValueOf(&ctxData)})
 }
 
+// prepareResume asks the resumed task how to re-drive its current stage, it returns
+// the name of the method to call and the data the method is called with. The
+// failed handler of the stage is called if the task can not be resumed, empty
+// method name keeps the task waiting on the stage
+func prepareResume(ctx context.Context, taskValue reflect.Value, stage string) (string, jsonutils.JSONObject) {
+	data, err := taskValue.Interface().(IResumableTask).PrepareResume(ctx, stage)
+	if err != nil {
+		log.Errorf("resume on stage %s fail: %s", stage, err)
+		return getStageFuncName(stage, true), jsonutils.NewString(fmt.Sprintf("resume fail: %s", err))
+	}
+	if gotypes.IsNil(data) {
+		return "", nil
+	}
+	return getStageFuncName(stage, false), data
+}
+
+// getStageFuncName returns the name of the method handling the stage, or the
+// failure of the stage
+func getStageFuncName(stage string, failed bool) string {
+	stageName := stage
+	if failed {
+		stageName = fmt.Sprintf("%sFailed", stage)
+		if strings.Contains(stageName, "_") {
+			stageName = fmt.Sprintf("%s_failed", stage)
+		}
+	}
+	if strings.Contains(stageName, "_") {
+		stageName = utils.Kebab2Camel(stageName, "_")
+	}
+	return stageName
+}
+
+// execCancel rolls back the cancelled task and marks it as failed. The task rolls
+// back by OnCancel if it is an ICancelableTask, otherwise the failed handler of
+// its current stage is called to leave the objects out of the transitional states,
+// the task keeps running if the failed handler moves it to a rollback stage
+func execCancel(ctx context.Context, taskValue reflect.Value, task *STask, reason string, params []reflect.Value) {
+	log.Infof("Task %s cancelled on stage %s: %s", task.TaskName, task.Stage, reason)
+	stage := task.Stage
+	// collect the running subtasks of current stage before the stage is changed
+	subtasks := SubTaskManager.GetInitSubtasks(task.Id, stage)
+	task.logObjectsAction(ctx, logclient.ACT_CANCEL, fmt.Sprintf("%s on stage %s: %s", task.TaskName, stage, reason), true)
+	msg := jsonutils.NewString(fmt.Sprintf("cancelled: %s", reason))
+	if cancelTask, ok := taskValue.Interface().(ICancelableTask); ok {
+		cancelTask.OnCancel(ctx, reason)
+	} else if failedFuncValue := taskValue.MethodByName(getStageFuncName(stage, true)); failedFuncValue.IsValid() {
+		failedFuncValue.Call([]reflect.Value{params[0], params[1], reflect.ValueOf(msg)})
+	}
+	// the embedded task of taskValue is updated by the handlers
+	if baseTask, ok := taskValue.Interface().(iBaseTask); ok && !baseTask.isFinished() && baseTask.getStage() == stage {
+		SetStageFailedFuncValue := taskValue.MethodByName("SetStageFailed")
+		SetStageFailedFuncValue.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(msg)})
+	}
+	for i := range subtasks {
+		subtask := TaskManager.fetchTask(subtasks[i].SubtaskId)
+		if subtask == nil {
+			continue
+		}
+		err := subtask.Cancel(ctx, reason)
+		if err != nil {
+			log.Errorf("cancel subtask %s of %s fail: %s", subtask.String(), task.String(), err)
+		}
+	}
+}
+
 func (task *STask) ScheduleRun(data jsonutils.JSONObject) error {
 	return runTask(task.Id, data)
 }
 
+func (task *STask) isFinished() bool {
+	return utils.IsInStringArray(task.Stage, []string{TASK_STAGE_FAILED, TASK_STAGE_COMPLETE})
+}
+
+func (task *STask) getStage() string {
+	return task.Stage
+}
+
+// iBaseTask is implemented by the tasks embedding STask
+type iBaseTask interface {
+	isFinished() bool
+	getStage() string
+}
+
+// Cancel schedules the cancellation of the task on the task worker, the
+// running subtasks of its current stage are cancelled as well
+func (task *STask) Cancel(ctx context.Context, reason string) error {
+	if task.isFinished() {
+		return httperrors.NewInvalidStatusError("task %s has been finished on stage %s", task.Id, task.Stage)
+	}
+	data := jsonutils.NewDict()
+	data.Add(jsonutils.NewString(reason), TASK_CANCEL_KEY)
+	return task.ScheduleRun(data)
+}
+
+// 取消任务
+func (task *STask) PerformCancel(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	input apis.TaskCancelInput,
+) (jsonutils.JSONObject, error) {
+	reason := input.Reason
+	if len(reason) == 0 {
+		reason = fmt.Sprintf("cancelled by %s", userCred.GetUserName())
+	}
+	err := task.Cancel(ctx, reason)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cancel")
+	}
+	return nil, nil
+}
+
 func (self *STask) IsSubtask() bool {
 	return self.HasParentTask()
 }
@@ -744,6 +940,10 @@ func (self *STask) SetStage(stageName string, data *jsonutils.JSONDict) error {
 			stageData.Add(jsonutils.NewTimeString(time.Now()), "complete_at")
 			stageList.Add(stageData)
 			self.Stage = stageName
+			self.StageTimeoutAt = time.Time{}
+			if timeout := getStageTimeout(self.TaskName, stageName); timeout > 0 {
+				self.StageTimeoutAt = timeutils.UtcNow().Add(timeout)
+			}
 		}
 		self.Params = params
 		return nil
@@ -848,6 +1048,7 @@ func notifyRemoteTask(ctx context.Context, notifyUrl string, taskid string, body
 	if len(taskid) > 0 {
 		header.Set("X-Task-Id", taskid)
 	}
+	header = tracing.Inject(ctx, header)
 	_, body, err := httputils.JSONRequest(client, ctx, "POST", notifyUrl, header, body, true)
 	if err != nil {
 		log.Errorf("notifyRemoteTask fail %s", err)
@@ -1269,16 +1470,183 @@ func (task *STask) SetProgress(progress float32) error {
 	return nil
 }
 
-func (manager *STaskManager) InitializeData() error {
+// ResumeTasks resumes the unfinished resumable tasks left by the dead replicas of
+// the service and marks the others as failed. It is called by the master nodes
+// of the service serving the tasks table after the models are initialized and
+// StartReplicaHeartbeat is called, the tasks owned by the live replicas are skipped
+// and each of the others is claimed by exactly one replica before it is resumed
+func (manager *STaskManager) ResumeTasks(ctx context.Context) error {
+	liveReplicaIds, err := TaskReplicaManager.getLiveReplicaIds()
+	if err != nil {
+		return errors.Wrap(err, "getLiveReplicaIds")
+	}
 	q := manager.Query().NotIn("stage", []string{TASK_STAGE_FAILED, TASK_STAGE_COMPLETE})
-	tasks := make([]STask, 0)
-	err := db.FetchModelObjects(manager, q, &tasks)
+	allTasks := make([]STask, 0)
+	err = db.FetchModelObjects(manager, q, &allTasks)
 	if err != nil {
 		return errors.Wrap(err, "FetchModelObjects")
 	}
+	tasks := make([]*STask, 0, len(allTasks))
+	for i := range allTasks {
+		task := &allTasks[i]
+		if isTaskOwnedByLiveReplica(task.ReplicaId, liveReplicaIds) {
+			continue
+		}
+		claimed, err := task.claimReplica()
+		if err != nil {
+			log.Errorf("claim task %s fail: %s", task.String(), err)
+			continue
+		}
+		if !claimed {
+			log.Infof("task %s is claimed by another replica", task.String())
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	// snapshot the tasks waiting for subtasks before any task is resumed or failed,
+	// they are driven by the notifications of their subtasks
+	waitSubtasks := make(map[string]bool)
+	for i := range tasks {
+		cnt, _ := SubTaskManager.GetInitSubtasksCount(tasks[i].Id, tasks[i].Stage)
+		waitSubtasks[tasks[i].Id] = cnt > 0
+	}
 	reason := jsonutils.NewString("service restart")
 	for i := range tasks {
-		tasks[i].SetStageFailed(context.Background(), reason)
+		task := tasks[i]
+		if task.Params == nil {
+			task.Params = jsonutils.NewDict()
+		}
+		if !isTaskResumable(task.TaskName) {
+			task.SetStageFailed(ctx, reason)
+			continue
+		}
+		err := task.resume(ctx, waitSubtasks[task.Id])
+		if err != nil {
+			log.Errorf("resume task %s fail: %s", task.String(), err)
+			task.logObjectsAction(ctx, logclient.ACT_TASK_RESUME, fmt.Sprintf("%s: %s", task.TaskName, err), false)
+			task.SetStageFailed(ctx, jsonutils.NewString(fmt.Sprintf("service restart: %s", err)))
+		}
 	}
 	return nil
 }
+
+// getResumeTries returns the number of times the task has been resumed, including
+// this time, a task is resumed at most maxTries times
+func getResumeTries(params *jsonutils.JSONDict, maxTries int) (int64, error) {
+	tried, _ := params.Int(TASK_RESUME_COUNT_KEY)
+	if int(tried) >= maxTries {
+		return 0, errors.Errorf("exceeds max resume tries %d", maxTries)
+	}
+	return tried + 1, nil
+}
+
+func (task *STask) resume(ctx context.Context, waitSubtasks bool) error {
+	tries, err := getResumeTries(task.Params, consts.TaskResumeMaxTries())
+	if err != nil {
+		return err
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewInt(tries), TASK_RESUME_COUNT_KEY)
+	err = task.SaveParams(params)
+	if err != nil {
+		return errors.Wrap(err, "SaveParams")
+	}
+	if waitSubtasks {
+		log.Infof("task %s is waiting for subtasks on stage %s, resume by subtasks", task.String(), task.Stage)
+		task.logObjectsAction(ctx, logclient.ACT_TASK_RESUME, fmt.Sprintf("%s: wait subtasks on stage %s, tries %d", task.TaskName, task.Stage, tries), true)
+		return nil
+	}
+	log.Infof("resume task %s on stage %s, tries %d", task.String(), task.Stage, tries)
+	task.logObjectsAction(ctx, logclient.ACT_TASK_RESUME, fmt.Sprintf("%s: resume on stage %s, tries %d", task.TaskName, task.Stage, tries), true)
+	data := jsonutils.NewDict()
+	data.Add(jsonutils.JSONTrue, TASK_RESUME_KEY)
+	err = task.ScheduleRun(data)
+	if err != nil {
+		return errors.Wrap(err, "ScheduleRun")
+	}
+	return nil
+}
+
+func (task *STask) logObjectsAction(ctx context.Context, action string, notes string, success bool) {
+	manager := db.GetModelManager(task.ObjType)
+	if manager == nil {
+		return
+	}
+	objManager, ok := manager.(db.IStandaloneModelManager)
+	if !ok {
+		return
+	}
+	objIds := []string{task.ObjId}
+	if task.ObjId == MULTI_OBJECTS_ID {
+		objIds = TaskObjectManager.GetObjectIds(task)
+	}
+	for _, objId := range objIds {
+		obj, err := objManager.FetchById(objId)
+		if err != nil {
+			log.Errorf("fetch %s %s for task %s fail: %s", task.ObjType, objId, task.String(), err)
+			continue
+		}
+		logclient.AddActionLogWithContext(ctx, obj, action, notes, task.GetUserCred(), success)
+	}
+}
+
+func timeoutCondition(q *sqlchemy.SQuery, field string, now time.Time) sqlchemy.ICondition {
+	return sqlchemy.AND(
+		sqlchemy.IsNotNull(q.Field(field)),
+		// zero time means no deadline
+		sqlchemy.GT(q.Field(field), time.Unix(0, 0)),
+		sqlchemy.LT(q.Field(field), now),
+	)
+}
+
+func (manager *STaskManager) CheckTimeoutTasks(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	now := timeutils.UtcNow()
+	q := manager.Query().NotIn("stage", []string{TASK_STAGE_FAILED, TASK_STAGE_COMPLETE})
+	q = q.Filter(sqlchemy.OR(
+		timeoutCondition(q, "timeout_at", now),
+		timeoutCondition(q, "stage_timeout_at", now),
+	))
+	tasks := make([]STask, 0)
+	err := db.FetchModelObjects(manager, q, &tasks)
+	if err != nil {
+		log.Errorf("fetch timeout tasks fail: %s", err)
+		return
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		reason, ok := task.getTimeoutReason(now)
+		if !ok {
+			continue
+		}
+		// clear the deadlines before the cancellation is scheduled so that the
+		// task is cancelled only once even if the worker is busy
+		_, err := db.Update(task, func() error {
+			task.clearDeadlines()
+			return nil
+		})
+		if err != nil {
+			log.Errorf("clear deadlines of timeout task %s fail: %s", task.String(), err)
+			continue
+		}
+		err = task.Cancel(ctx, reason)
+		if err != nil {
+			log.Errorf("cancel timeout task %s fail: %s", task.String(), err)
+		}
+	}
+}
+
+// getTimeoutReason returns the reason of cancelling the task if it exceeds its deadlines
+func (task *STask) getTimeoutReason(now time.Time) (string, bool) {
+	if !task.StageTimeoutAt.IsZero() && task.StageTimeoutAt.Before(now) {
+		return fmt.Sprintf("stage %s timeout at %s", task.Stage, task.StageTimeoutAt), true
+	}
+	if !task.TimeoutAt.IsZero() && task.TimeoutAt.Before(now) {
+		return fmt.Sprintf("task timeout at %s", task.TimeoutAt), true
+	}
+	return "", false
+}
+
+func (task *STask) clearDeadlines() {
+	task.TimeoutAt = time.Time{}
+	task.StageTimeoutAt = time.Time{}
+}
@@ -0,0 +1,248 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskman
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/sets"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+)
+
+type testResumableTask struct {
+	STask
+}
+
+func (task *testResumableTask) PrepareResume(ctx context.Context, stage string) (jsonutils.JSONObject, error) {
+	switch stage {
+	case "OnWaitCallback":
+		return nil, nil
+	case "OnBroken":
+		return nil, errors.Errorf("broken")
+	}
+	return jsonutils.NewDict(), nil
+}
+
+type testTask struct {
+	STask
+}
+
+type testTimeoutTask struct {
+	STask
+}
+
+func (task *testTimeoutTask) GetTimeout() time.Duration {
+	return time.Hour
+}
+
+func (task *testTimeoutTask) GetStageTimeout(stage string) time.Duration {
+	if stage == "OnWaitCallback" {
+		return time.Minute
+	}
+	return 0
+}
+
+func init() {
+	RegisterTask(testResumableTask{})
+	RegisterTask(testTask{})
+	RegisterTask(testTimeoutTask{})
+}
+
+func TestIsTaskResumable(t *testing.T) {
+	cases := []struct {
+		taskName string
+		want     bool
+	}{
+		{"testResumableTask", true},
+		{"testTask", false},
+		{"testNotRegisteredTask", false},
+	}
+	for _, c := range cases {
+		if got := isTaskResumable(c.taskName); got != c.want {
+			t.Errorf("isTaskResumable(%s) = %v, want %v", c.taskName, got, c.want)
+		}
+	}
+}
+
+func TestGetStageFuncName(t *testing.T) {
+	cases := []struct {
+		stage  string
+		failed bool
+		want   string
+	}{
+		{"OnInit", false, "OnInit"},
+		{"OnInit", true, "OnInitFailed"},
+		{"on_disk_prepared", false, "OnDiskPrepared"},
+		{"on_disk_prepared", true, "OnDiskPreparedFailed"},
+	}
+	for _, c := range cases {
+		if got := getStageFuncName(c.stage, c.failed); got != c.want {
+			t.Errorf("getStageFuncName(%s, %v) = %s, want %s", c.stage, c.failed, got, c.want)
+		}
+	}
+}
+
+func TestPrepareResume(t *testing.T) {
+	taskValue := reflect.ValueOf(&testResumableTask{})
+	cases := []struct {
+		stage      string
+		wantFunc   string
+		wantData   bool
+		wantReason string
+	}{
+		{stage: "OnWaitCallback", wantFunc: ""},
+		{stage: "OnBroken", wantFunc: "OnBrokenFailed", wantData: true, wantReason: "resume fail: broken"},
+		{stage: "on_data_ready", wantFunc: "OnDataReady", wantData: true},
+	}
+	for _, c := range cases {
+		funcName, data := prepareResume(context.Background(), taskValue, c.stage)
+		if funcName != c.wantFunc {
+			t.Errorf("stage %s: func = %q, want %q", c.stage, funcName, c.wantFunc)
+		}
+		if (data != nil) != c.wantData {
+			t.Errorf("stage %s: data = %v, want data %v", c.stage, data, c.wantData)
+		}
+		if len(c.wantReason) > 0 {
+			reason, _ := data.GetString()
+			if !strings.Contains(reason, c.wantReason) {
+				t.Errorf("stage %s: reason = %q, want %q", c.stage, reason, c.wantReason)
+			}
+		}
+	}
+}
+
+func TestGetResumeTries(t *testing.T) {
+	cases := []struct {
+		tried    int64
+		maxTries int
+		want     int64
+		wantErr  bool
+	}{
+		{tried: 0, maxTries: 3, want: 1},
+		{tried: 2, maxTries: 3, want: 3},
+		{tried: 3, maxTries: 3, wantErr: true},
+		{tried: 0, maxTries: 0, wantErr: true},
+	}
+	for _, c := range cases {
+		params := jsonutils.NewDict()
+		if c.tried > 0 {
+			params.Set(TASK_RESUME_COUNT_KEY, jsonutils.NewInt(c.tried))
+		}
+		got, err := getResumeTries(params, c.maxTries)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("tried %d max %d: want error, got %d", c.tried, c.maxTries, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("tried %d max %d: got %d %v, want %d", c.tried, c.maxTries, got, err, c.want)
+		}
+	}
+}
+
+func TestInitTimeout(t *testing.T) {
+	task := &STask{TaskName: "testTimeoutTask", Stage: "OnWaitCallback"}
+	task.initTimeout()
+	if task.TimeoutAt.IsZero() || task.StageTimeoutAt.IsZero() {
+		t.Fatalf("deadlines not set: %s %s", task.TimeoutAt, task.StageTimeoutAt)
+	}
+	if !task.StageTimeoutAt.Before(task.TimeoutAt) {
+		t.Errorf("stage deadline %s should be before task deadline %s", task.StageTimeoutAt, task.TimeoutAt)
+	}
+
+	task = &STask{TaskName: "testTask", Stage: "OnWaitCallback"}
+	task.initTimeout()
+	if !task.TimeoutAt.IsZero() || !task.StageTimeoutAt.IsZero() {
+		t.Errorf("task without deadlines got %s %s", task.TimeoutAt, task.StageTimeoutAt)
+	}
+}
+
+func TestGetStageTimeout(t *testing.T) {
+	defer consts.SetTaskDefaultStageTimeoutSeconds(0)
+	cases := []struct {
+		name           string
+		taskName       string
+		stage          string
+		defaultSeconds int
+		want           time.Duration
+	}{
+		{name: "no limit", taskName: "testTask", stage: "OnWaitCallback"},
+		{name: "default limit", taskName: "testTask", stage: "OnWaitCallback", defaultSeconds: 3600, want: time.Hour},
+		{name: "own limit", taskName: "testTimeoutTask", stage: "OnWaitCallback", defaultSeconds: 3600, want: time.Minute},
+		{name: "default limit of other stages", taskName: "testTimeoutTask", stage: "OnInit", defaultSeconds: 3600, want: time.Hour},
+	}
+	for _, c := range cases {
+		consts.SetTaskDefaultStageTimeoutSeconds(c.defaultSeconds)
+		if got := getStageTimeout(c.taskName, c.stage); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGetTimeoutReason(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	cases := []struct {
+		name           string
+		timeoutAt      time.Time
+		stageTimeoutAt time.Time
+		wantReason     string
+	}{
+		{name: "no deadlines"},
+		{name: "before deadlines", timeoutAt: future, stageTimeoutAt: future},
+		{name: "task timeout", timeoutAt: past, stageTimeoutAt: future, wantReason: "task timeout"},
+		{name: "stage timeout", timeoutAt: future, stageTimeoutAt: past, wantReason: "stage OnWaitCallback timeout"},
+		{name: "both timeout", timeoutAt: past, stageTimeoutAt: past, wantReason: "stage OnWaitCallback timeout"},
+	}
+	for _, c := range cases {
+		task := &STask{Stage: "OnWaitCallback", TimeoutAt: c.timeoutAt, StageTimeoutAt: c.stageTimeoutAt}
+		reason, ok := task.getTimeoutReason(now)
+		if ok != (len(c.wantReason) > 0) || !strings.HasPrefix(reason, c.wantReason) {
+			t.Errorf("%s: got %q %v, want %q", c.name, reason, ok, c.wantReason)
+		}
+		// the timeout task is cancelled only once
+		task.clearDeadlines()
+		if reason, ok := task.getTimeoutReason(now); ok {
+			t.Errorf("%s: timeout again after deadlines cleared: %s", c.name, reason)
+		}
+	}
+}
+
+func TestIsTaskOwnedByLiveReplica(t *testing.T) {
+	live := sets.NewString(currentReplicaId, "live-replica")
+	cases := []struct {
+		name      string
+		replicaId string
+		want      bool
+	}{
+		{name: "left by old versions", replicaId: "", want: false},
+		{name: "dead replica", replicaId: "dead-replica", want: false},
+		{name: "live replica", replicaId: "live-replica", want: true},
+		{name: "current replica", replicaId: currentReplicaId, want: true},
+	}
+	for _, c := range cases {
+		if got := isTaskOwnedByLiveReplica(c.replicaId, live); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -115,6 +115,16 @@ func NewElect(config *EtcdConfig, key string) (*Elect, error) {
 	return elect, nil
 }
 
+// Client returns the etcd client used by the election
+func (elect *Elect) Client() *clientv3.Client {
+	return elect.cli
+}
+
+// Path returns the etcd key of the election
+func (elect *Elect) Path() string {
+	return elect.path
+}
+
 func (elect *Elect) Stop() {
 	elect.stopFunc()
 }
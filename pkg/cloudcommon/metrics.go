@@ -0,0 +1,73 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudcommon
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+)
+
+var (
+	dbMaxOpenConnsDesc = newDBStatsDesc("max_open_connections", "Maximum number of open connections to the database")
+	dbOpenConnsDesc    = newDBStatsDesc("open_connections", "Number of established connections both in use and idle")
+	dbInUseConnsDesc   = newDBStatsDesc("in_use_connections", "Number of connections currently in use")
+	dbIdleConnsDesc    = newDBStatsDesc("idle_connections", "Number of idle connections")
+	dbWaitCountDesc    = newDBStatsDesc("wait_count_total", "Total number of connections waited for")
+	dbWaitDurationDesc = newDBStatsDesc("wait_duration_seconds_total", "Total time blocked waiting for a new connection")
+)
+
+func newDBStatsDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName("onecloud", "db", name), help, []string{"db"}, nil)
+}
+
+// sDBStatsCollector collects the connection pool stats of the databases
+type sDBStatsCollector struct {
+	dbs []sqlchemy.DBName
+}
+
+func (c *sDBStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		dbMaxOpenConnsDesc, dbOpenConnsDesc, dbInUseConnsDesc,
+		dbIdleConnsDesc, dbWaitCountDesc, dbWaitDurationDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *sDBStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.dbs {
+		db := sqlchemy.GetDBWithName(name)
+		if db == nil {
+			continue
+		}
+		stats := db.DB().Stats()
+		dbName := string(name)
+		ch <- prometheus.MustNewConstMetric(dbMaxOpenConnsDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections), dbName)
+		ch <- prometheus.MustNewConstMetric(dbOpenConnsDesc, prometheus.GaugeValue, float64(stats.OpenConnections), dbName)
+		ch <- prometheus.MustNewConstMetric(dbInUseConnsDesc, prometheus.GaugeValue, float64(stats.InUse), dbName)
+		ch <- prometheus.MustNewConstMetric(dbIdleConnsDesc, prometheus.GaugeValue, float64(stats.Idle), dbName)
+		ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount), dbName)
+		ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds(), dbName)
+	}
+}
+
+func registerDBMetrics(dbs ...sqlchemy.DBName) {
+	err := prometheus.Register(&sDBStatsCollector{dbs: dbs})
+	if err != nil {
+		log.Warningf("register db metrics collector fail: %v", err)
+	}
+}
@@ -68,6 +68,12 @@ func OnBaseOptionsChange(oOpts, nOpts interface{}) bool {
 	if oldOpts.LocalTaskWorkerCount != newOpts.LocalTaskWorkerCount {
 		consts.SetLocalTaskWorkerCount(newOpts.LocalTaskWorkerCount)
 	}
+	if oldOpts.TaskResumeMaxTries != newOpts.TaskResumeMaxTries {
+		consts.SetTaskResumeMaxTries(newOpts.TaskResumeMaxTries)
+	}
+	if oldOpts.TaskDefaultStageTimeoutSeconds != newOpts.TaskDefaultStageTimeoutSeconds {
+		consts.SetTaskDefaultStageTimeoutSeconds(newOpts.TaskDefaultStageTimeoutSeconds)
+	}
 	return changed
 }
 
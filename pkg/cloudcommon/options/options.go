@@ -73,6 +73,10 @@ type BaseOptions struct {
 
 	TaskWorkerCount      int `default:"4" help:"Task manager worker thread count, default is 4"`
 	LocalTaskWorkerCount int `default:"4" help:"Worker thread count that runs local tasks, default is 4"`
+	TaskResumeMaxTries   int `default:"3" help:"Max times to resume an unfinished resumable task after service restart, default is 3"`
+
+	TaskTimeoutCheckIntervalSeconds int `default:"60" help:"interval to cancel the tasks exceeding their deadlines, default is 60 seconds"`
+	TaskDefaultStageTimeoutSeconds  int `default:"0" help:"max seconds that a task stays on a stage without its own stage deadline, 0 means no limit, default is 0"`
 
 	DefaultProcessTimeoutSeconds int `default:"60" help:"request process timeout, default is 60 seconds"`
 
@@ -121,6 +125,14 @@ type BaseOptions struct {
 	PlatformNames map[string]string `help:"identity name of this platform by language"`
 
 	EnableAppProfiling bool `help:"enable profiling API" default:"false"`
+
+	TracingExporter     string  `help:"exporter of the OpenTelemetry spans, none to disable tracing" default:"none" choices:"none|otlp|file"`
+	TracingOtlpEndpoint string  `help:"endpoint of the OTLP/HTTP collector, e.g. http://127.0.0.1:4318"`
+	TracingFile         string  `help:"file to append the spans in OTLP JSON, e.g. /var/log/onecloud/traces.json"`
+	TracingSampleRatio  float64 `help:"probability to sample a new trace, between 0 and 1" default:"1"`
+
+	EnableRateLimit                 bool `help:"limit the API requests of users and projects by the rate-limits parameter of yunionconf" default:"false"`
+	RateLimitRefreshIntervalSeconds int  `help:"interval to reload the rate-limits parameter, default is 60 seconds" default:"60"`
 }
 
 const (
@@ -394,6 +406,8 @@ func parseOptions(optStruct interface{}, args []string, configFileName string, s
 
 	consts.SetTaskWorkerCount(optionsRef.TaskWorkerCount)
 	consts.SetLocalTaskWorkerCount(optionsRef.LocalTaskWorkerCount)
+	consts.SetTaskResumeMaxTries(optionsRef.TaskResumeMaxTries)
+	consts.SetTaskDefaultStageTimeoutSeconds(optionsRef.TaskDefaultStageTimeoutSeconds)
 }
 
 func (self *BaseOptions) HttpTransportProxyFunc() httputils.TransportProxyFunc {
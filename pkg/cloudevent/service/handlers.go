@@ -31,6 +31,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/proxy"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
@@ -43,11 +44,13 @@ func InitHandlers(app *appsrv.Application) {
 	models.InitCloudevent()
 
 	taskman.AddTaskHandler("v1", app)
+	cronman.AddCronJobHandler("v1", app)
 
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
 		taskman.SubTaskManager,
 		taskman.TaskObjectManager,
+		taskman.TaskReplicaManager,
 		db.UserCacheManager,
 		db.TenantCacheManager,
 		db.DistinctFieldManager,
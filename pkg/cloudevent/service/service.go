@@ -27,6 +27,7 @@ import (
 	common_app "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/cloudevent/models"
 	"yunion.io/x/onecloud/pkg/cloudevent/options"
@@ -54,12 +55,20 @@ func StartService() {
 	db.EnsureAppSyncDB(app, dbOpts, models.InitDB)
 	defer cloudcommon.CloseDB()
 
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(app.GetContext())
 	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(app.GetContext()); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
+
 		cron := cronman.InitCronJobManager(true, options.Options.CronJobWorkerCount)
 		cron.AddJobAtIntervalsWithStartRun("SyncCloudprovider", time.Duration(opts.CloudproviderSyncIntervalMinutes)*time.Minute, models.CloudproviderManager.SyncCloudproviders, true)
 		cron.AddJobAtIntervalsWithStartRun("CloudeventSyncTask", time.Duration(opts.CloudeventSyncIntervalHours)*time.Hour, models.CloudproviderManager.SyncCloudeventTask, true)
 
 		cron.AddJobEveryFewHour("AutoPurgeSplitable", 4, 30, 0, db.AutoPurgeSplitable, false)
+		cron.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
 
 		cron.Start()
 		defer cron.Stop()
@@ -31,6 +31,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/proxy"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
@@ -41,12 +42,14 @@ func InitHandlers(app *appsrv.Application) {
 	db.InitAllManagers()
 
 	taskman.AddTaskHandler("v1", app)
+	cronman.AddCronJobHandler("v1", app)
 	db.AddScopeResourceCountHandler("", app)
 
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
 		taskman.SubTaskManager,
 		taskman.TaskObjectManager,
+		taskman.TaskReplicaManager,
 		db.UserCacheManager,
 		db.TenantCacheManager,
 		db.SharedResourceManager,
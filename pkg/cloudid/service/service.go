@@ -30,6 +30,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	_ "yunion.io/x/onecloud/pkg/cloudid/drivers"
@@ -83,12 +84,20 @@ func StartService() {
 		log.Fatalf("StartWatchInRegion error: %v", err)
 	}
 
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(context.Background())
 	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(context.Background()); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
+
 		cron := cronman.InitCronJobManager(true, options.Options.CronJobWorkerCount)
 		cron.AddJobAtIntervalsWithStartRun("SyncCloudaccountResources", time.Duration(opts.CloudIdResourceSyncIntervalHours)*time.Hour, models.CloudaccountManager.SyncCloudaccountResources, true)
 		cron.AddJobAtIntervalsWithStartRun("SyncCloudproviderResources", time.Duration(opts.CloudIdResourceSyncIntervalHours)*time.Hour, models.CloudproviderManager.SyncCloudproviderResources, true)
 
 		cron.AddJobEveryFewHour("AutoPurgeSplitable", 4, 30, 0, db.AutoPurgeSplitable, false)
+		cron.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
 
 		cron.Start()
 		defer cron.Stop()
@@ -88,5 +88,6 @@ func StartService() {
 			session := auth.GetAdminSession(ctx, commonOpts.Region)
 			notifyclient.EventNotifyServiceAbnormal(ctx, session.GetToken(), consts.GetServiceType(), method, path, body, err)
 		})
+	cronman.AddCronJobHandler("", app)
 	app_common.ServeForever(app, baseOpts)
 }
@@ -17,6 +17,7 @@ package models
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"reflect"
 	"time"
 
@@ -24,12 +25,15 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
+	"yunion.io/x/onecloud/pkg/compute/options"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
@@ -64,6 +68,15 @@ type SDiskBackup struct {
 	// 操作系统类型
 	OsType     string `width:"32" charset:"ascii" nullable:"true" list:"user" create:"optional"`
 	DiskConfig *SBackupDiskConfig
+
+	// 备份模式
+	BackupMode string `width:"16" charset:"ascii" nullable:"true" default:"full" list:"user"`
+	// 备份链中的上一个备份
+	ParentBackupId string `width:"36" charset:"ascii" nullable:"true" list:"user" index:"true"`
+	// 备份链的全量备份
+	BaseBackupId string `width:"36" charset:"ascii" nullable:"true" list:"user"`
+	// 备份在备份链中的序号, 全量备份为0
+	ChainIndex int `nullable:"false" default:"0" list:"user"`
 }
 
 var DiskBackupManager *SDiskBackupManager
@@ -144,6 +157,13 @@ func (self *SDiskBackup) ValidateDeleteCondition(ctx context.Context, info jsonu
 	if is {
 		return httperrors.NewBadRequestError("disk backup referenced by instance backup")
 	}
+	cnt, err := DiskBackupManager.Query().Equals("parent_backup_id", self.Id).CountWithError()
+	if err != nil {
+		return errors.Wrap(err, "query child backups")
+	}
+	if cnt > 0 {
+		return httperrors.NewBadRequestError("disk backup is depended on by %d backups in the backup chain", cnt)
+	}
 	return nil
 }
 
@@ -183,6 +203,12 @@ func (db *SDiskBackup) getMoreDetails(out api.DiskBackupDetails) api.DiskBackupD
 	if t, _ := InstanceBackupJointManager.IsSubBackup(db.Id); t {
 		out.IsSubBackup = true
 	}
+	if len(db.ParentBackupId) > 0 {
+		parent, _ := DiskBackupManager.FetchById(db.ParentBackupId)
+		if parent != nil {
+			out.ParentBackupName = parent.GetName()
+		}
+	}
 	return out
 }
 
@@ -292,9 +318,193 @@ func (dm *SDiskBackupManager) ValidateCreateData(
 		input.BackupAsTar.ContainerId = ctr.GetId()
 	}
 
+	// empty mode means a standalone full backup out of any backup chain
+	if len(input.BackupMode) > 0 && !utils.IsInStringArray(input.BackupMode, api.DISK_BACKUP_MODES) {
+		return input, httperrors.NewInputParameterError("invalid backup_mode %s, must be one of %s", input.BackupMode, api.DISK_BACKUP_MODES)
+	}
+	if len(input.BackupMode) > 0 && input.BackupMode != api.DISK_BACKUP_MODE_FULL {
+		guest := disk.GetGuest()
+		if guest == nil || guest.Hypervisor != api.HYPERVISOR_KVM {
+			return input, httperrors.NewUnsupportOperationError("%s backup is only supported for disks of kvm guests", input.BackupMode)
+		}
+		if input.BackupAsTar != nil {
+			return input, httperrors.NewUnsupportOperationError("%s backup can't be taken as tar", input.BackupMode)
+		}
+		if len(disk.EncryptKeyId) > 0 {
+			return input, httperrors.NewUnsupportOperationError("%s backup of encrypted disk is not supported", input.BackupMode)
+		}
+	}
+
 	return input, nil
 }
 
+// getLatestChainBackup returns the latest backup of the disk that belongs to
+// a backup chain on the backup storage, nil if there is none
+func (dm *SDiskBackupManager) getLatestChainBackup(diskId, backupStorageId string) (*SDiskBackup, error) {
+	q := dm.Query().Equals("disk_id", diskId).Equals("backup_storage_id", backupStorageId).IsNotEmpty("base_backup_id")
+	q = q.Desc("created_at")
+	backup := &SDiskBackup{}
+	backup.SetModelManager(dm, backup)
+	err := q.First(backup)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "First")
+	}
+	return backup, nil
+}
+
+// needFullBackup tells whether a backup requested in the given mode has to
+// be taken as a new full backup, which rebases the backup chain
+func needFullBackup(mode string, last, base *SDiskBackup, diskSizeMb int, now time.Time) (bool, string) {
+	if mode == api.DISK_BACKUP_MODE_FULL {
+		return true, "full backup requested"
+	}
+	if last == nil || base == nil {
+		return true, "no backup chain"
+	}
+	if last.Status != api.BACKUP_STATUS_READY || base.Status != api.BACKUP_STATUS_READY {
+		return true, "backup chain is broken"
+	}
+	if last.BackupMode != api.DISK_BACKUP_MODE_FULL && last.BackupMode != mode {
+		return true, fmt.Sprintf("backup mode changed from %s to %s", last.BackupMode, mode)
+	}
+	if last.ChainIndex >= options.Options.DiskBackupMaxChainLength {
+		return true, fmt.Sprintf("backup chain reaches max length %d", options.Options.DiskBackupMaxChainLength)
+	}
+	if days := options.Options.DiskBackupFullIntervalDays; days > 0 && now.Sub(base.CreatedAt) >= time.Duration(days)*24*time.Hour {
+		return true, fmt.Sprintf("full backup is older than %d days", days)
+	}
+	if base.DiskSizeMb != diskSizeMb {
+		return true, "disk is resized"
+	}
+	return false, ""
+}
+
+// canStartBackupChain tells whether the backup can be taken by the dirty bitmap
+// of the guest, which is required by the backups in a backup chain
+func canStartBackupChain(guest *SGuest, asTar bool, encrypted bool) (bool, string) {
+	if guest == nil || guest.Hypervisor != api.HYPERVISOR_KVM {
+		return false, "disk is not attached to a kvm guest"
+	}
+	if guest.Status != api.VM_RUNNING {
+		// dirty bitmaps only live in a running qemu
+		return false, "guest is not running"
+	}
+	if asTar {
+		return false, "backup is taken as tar"
+	}
+	if encrypted {
+		return false, "disk is encrypted"
+	}
+	return true, ""
+}
+
+// setBackupChain places the backup in the backup chain of the disk. A backup
+// that can't be taken incrementally falls back to a full one, an explicit full
+// backup starts a new backup chain, a backup without mode is a standalone full one
+func (db *SDiskBackup) setBackupChain(ctx context.Context, disk *SDisk, mode string) error {
+	db.BackupMode = api.DISK_BACKUP_MODE_FULL
+	if len(mode) == 0 {
+		return nil
+	}
+	asTar := db.DiskConfig != nil && db.DiskConfig.BackupAsTar != nil
+	if ok, reason := canStartBackupChain(disk.GetGuest(), asTar, len(disk.EncryptKeyId) > 0); !ok {
+		// take a standalone full backup
+		if mode != api.DISK_BACKUP_MODE_FULL {
+			log.Warningf("take full backup of disk %s instead of %s: %s", disk.Id, mode, reason)
+		}
+		return nil
+	}
+	last, err := DiskBackupManager.getLatestChainBackup(disk.Id, db.BackupStorageId)
+	if err != nil {
+		return errors.Wrap(err, "getLatestChainBackup")
+	}
+	var base *SDiskBackup
+	if last != nil {
+		baseObj, err := DiskBackupManager.FetchById(last.BaseBackupId)
+		if err != nil && errors.Cause(err) != sql.ErrNoRows {
+			return errors.Wrapf(err, "fetch base backup %s", last.BaseBackupId)
+		}
+		if baseObj != nil {
+			base = baseObj.(*SDiskBackup)
+		}
+	}
+	if full, reason := needFullBackup(mode, last, base, disk.DiskSize, time.Now()); full {
+		log.Infof("take full backup of disk %s as new chain base: %s", disk.Id, reason)
+		if len(db.Id) == 0 {
+			db.Id = stringutils.UUID4()
+		}
+		db.setAsChainBase()
+		return nil
+	}
+	db.BackupMode = mode
+	db.BaseBackupId = base.Id
+	db.ChainIndex = last.ChainIndex + 1
+	if mode == api.DISK_BACKUP_MODE_INCREMENTAL {
+		db.ParentBackupId = last.Id
+	} else {
+		db.ParentBackupId = base.Id
+	}
+	return nil
+}
+
+// setAsChainBase makes the backup the full backup starting a new backup chain
+func (self *SDiskBackup) setAsChainBase() {
+	self.BackupMode = api.DISK_BACKUP_MODE_FULL
+	self.BaseBackupId = self.Id
+	self.ParentBackupId = ""
+	self.ChainIndex = 0
+}
+
+// RebaseBackupChain records the backup as the base of a new backup chain if
+// the host took a full backup instead, e.g. the dirty bitmap of the disk is lost
+func (self *SDiskBackup) RebaseBackupChain(ctx context.Context, userCred mcclient.TokenCredential, takenMode string) error {
+	if !self.IsBitmapBackup() || self.BackupMode == api.DISK_BACKUP_MODE_FULL || takenMode != api.DISK_BACKUP_MODE_FULL {
+		return nil
+	}
+	requestedMode := self.BackupMode
+	_, err := db.Update(self, func() error {
+		self.setAsChainBase()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update backup chain")
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, fmt.Sprintf("dirty bitmap is lost, take full backup instead of %s", requestedMode), userCred)
+	return nil
+}
+
+// IsBitmapBackup tells whether the backup is taken by the dirty bitmap of the
+// running guest instead of from a snapshot
+func (db *SDiskBackup) IsBitmapBackup() bool {
+	return len(db.BaseBackupId) > 0
+}
+
+// GetBackupChain returns the backups that the backup depends on, from the
+// full backup to the parent
+func (db *SDiskBackup) GetBackupChain() ([]string, error) {
+	chain := []string{}
+	parentId := db.ParentBackupId
+	for len(parentId) > 0 {
+		if utils.IsInStringArray(parentId, chain) {
+			return nil, errors.Errorf("loop in backup chain of %s", db.Id)
+		}
+		parentObj, err := DiskBackupManager.FetchById(parentId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch parent backup %s", parentId)
+		}
+		parent := parentObj.(*SDiskBackup)
+		if parent.Status != api.BACKUP_STATUS_READY {
+			return nil, errors.Wrapf(httperrors.ErrInvalidStatus, "parent backup %s status %s", parent.Name, parent.Status)
+		}
+		chain = append([]string{parentId}, chain...)
+		parentId = parent.ParentBackupId
+	}
+	return chain, nil
+}
+
 func (db *SDiskBackup) CustomizeCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
 	input := new(api.DiskBackupCreateInput)
 	if err := data.Unmarshal(input); err != nil {
@@ -320,7 +530,7 @@ func (db *SDiskBackup) CustomizeCreate(ctx context.Context, userCred mcclient.To
 	db.StorageId = disk.StorageId
 	db.DomainId = disk.DomainId
 	db.ProjectId = disk.ProjectId
-	return nil
+	return db.setBackupChain(ctx, disk, input.BackupMode)
 }
 
 func (db *SDiskBackup) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
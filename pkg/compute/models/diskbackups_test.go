@@ -0,0 +1,113 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/options"
+)
+
+func TestNeedFullBackup(t *testing.T) {
+	options.Options.DiskBackupMaxChainLength = 3
+	options.Options.DiskBackupFullIntervalDays = 7
+
+	now := time.Date(2024, 5, 10, 2, 0, 0, 0, time.UTC)
+	newBackup := func(mode string, index int, status string, created time.Time) *SDiskBackup {
+		backup := &SDiskBackup{
+			BackupMode: mode,
+			ChainIndex: index,
+			DiskSizeMb: 10240,
+		}
+		backup.Status = status
+		backup.CreatedAt = created
+		return backup
+	}
+	base := newBackup(api.DISK_BACKUP_MODE_FULL, 0, api.BACKUP_STATUS_READY, now.Add(-48*time.Hour))
+	inc := newBackup(api.DISK_BACKUP_MODE_INCREMENTAL, 1, api.BACKUP_STATUS_READY, now.Add(-24*time.Hour))
+
+	cases := []struct {
+		name   string
+		mode   string
+		last   *SDiskBackup
+		base   *SDiskBackup
+		sizeMb int
+		want   bool
+	}{
+		{"full requested", api.DISK_BACKUP_MODE_FULL, inc, base, 10240, true},
+		{"no chain", api.DISK_BACKUP_MODE_INCREMENTAL, nil, nil, 10240, true},
+		{"incremental after base", api.DISK_BACKUP_MODE_INCREMENTAL, base, base, 10240, false},
+		{"differential after base", api.DISK_BACKUP_MODE_DIFFERENTIAL, base, base, 10240, false},
+		{"incremental after incremental", api.DISK_BACKUP_MODE_INCREMENTAL, inc, base, 10240, false},
+		{"mode changed", api.DISK_BACKUP_MODE_DIFFERENTIAL, inc, base, 10240, true},
+		{"last failed", api.DISK_BACKUP_MODE_INCREMENTAL, newBackup(api.DISK_BACKUP_MODE_INCREMENTAL, 1, api.BACKUP_STATUS_SAVE_FAILED, now), base, 10240, true},
+		{"chain too long", api.DISK_BACKUP_MODE_INCREMENTAL, newBackup(api.DISK_BACKUP_MODE_INCREMENTAL, 3, api.BACKUP_STATUS_READY, now), base, 10240, true},
+		{"base too old", api.DISK_BACKUP_MODE_INCREMENTAL, inc, newBackup(api.DISK_BACKUP_MODE_FULL, 0, api.BACKUP_STATUS_READY, now.Add(-8*24*time.Hour)), 10240, true},
+		{"disk resized", api.DISK_BACKUP_MODE_INCREMENTAL, inc, base, 20480, true},
+	}
+	for _, c := range cases {
+		got, reason := needFullBackup(c.mode, c.last, c.base, c.sizeMb, now)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v(%s)", c.name, c.want, got, reason)
+		}
+	}
+}
+
+func TestSetAsChainBase(t *testing.T) {
+	// the host takes a full backup if the dirty bitmap is lost
+	backup := &SDiskBackup{
+		BackupMode:     api.DISK_BACKUP_MODE_INCREMENTAL,
+		ParentBackupId: "backup-2",
+		BaseBackupId:   "backup-1",
+		ChainIndex:     2,
+	}
+	backup.Id = "backup-3"
+	backup.setAsChainBase()
+	if !backup.IsBitmapBackup() || backup.BackupMode != api.DISK_BACKUP_MODE_FULL || backup.BaseBackupId != backup.Id || len(backup.ParentBackupId) > 0 || backup.ChainIndex != 0 {
+		t.Errorf("unexpected chain base %#v", backup)
+	}
+}
+
+func TestCanStartBackupChain(t *testing.T) {
+	newGuest := func(hypervisor, status string) *SGuest {
+		guest := &SGuest{}
+		guest.Hypervisor = hypervisor
+		guest.Status = status
+		return guest
+	}
+	running := newGuest(api.HYPERVISOR_KVM, api.VM_RUNNING)
+	cases := []struct {
+		name      string
+		guest     *SGuest
+		asTar     bool
+		encrypted bool
+		want      bool
+	}{
+		{"running kvm guest", running, false, false, true},
+		{"no guest", nil, false, false, false},
+		{"not kvm", newGuest(api.HYPERVISOR_ESXI, api.VM_RUNNING), false, false, false},
+		{"not running", newGuest(api.HYPERVISOR_KVM, api.VM_READY), false, false, false},
+		{"as tar", running, true, false, false},
+		{"encrypted", running, false, true, false},
+	}
+	for _, c := range cases {
+		got, reason := canStartBackupChain(c.guest, c.asTar, c.encrypted)
+		if got != c.want {
+			t.Errorf("%s: want %v got %v(%s)", c.name, c.want, got, reason)
+		}
+	}
+}
@@ -825,12 +825,17 @@ func (self *SDisk) getDiskAllocateFromBackupInput(ctx context.Context, backupId
 	if err != nil {
 		return nil, errors.Wrap(err, "backupStorage.GetAccessInfo")
 	}
+	chain, err := backup.GetBackupChain()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get backup chain of backup %s", backupId)
+	}
 	return &api.DiskAllocateFromBackupInput{
 		BackupId:                backupId,
 		BackupStorageId:         bs.GetId(),
 		BackupStorageAccessInfo: jsonutils.Marshal(accessInfo).(*jsonutils.JSONDict),
 		DiskConfig:              &backup.DiskConfig.DiskConfig,
 		BackupAsTar:             backup.DiskConfig.BackupAsTar,
+		BackupChain:             chain,
 	}, nil
 }
 
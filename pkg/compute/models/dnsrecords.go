@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
 	"regexp"
 	"sort"
 	"strings"
@@ -29,6 +30,7 @@ import (
 	"yunion.io/x/pkg/util/compare"
 	"yunion.io/x/pkg/util/rbacscope"
 	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/utils"
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/apis"
@@ -103,10 +105,16 @@ func (manager *SDnsRecordManager) ValidateCreateData(
 		return nil, httperrors.NewInputParameterError("invalid record name %s", input.Name)
 	}
 
-	_, err = validators.ValidateModel(ctx, userCred, DnsZoneManager, &input.DnsZoneId)
+	zoneObj, err := validators.ValidateModel(ctx, userCred, DnsZoneManager, &input.DnsZoneId)
 	if err != nil {
 		return nil, err
 	}
+	if len(zoneObj.(*SDnsZone).ManagerId) == 0 {
+		input.PolicyValue, err = manager.validateLocalPolicy(ctx, userCred, input.PolicyType, input.PolicyValue)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	record := api.SDnsRecord{}
 	record.DnsZoneId = input.DnsZoneId
@@ -130,8 +138,9 @@ func (manager *SDnsRecordManager) ValidateCreateData(
 	// 不同类型policy不能重复
 	// 同类型policy的dnsrecord重复时，需要通过policyvalue区别
 
-	// validate name type
+	// validate name type, records of different views do not conflict
 	q := DnsRecordManager.Query().Equals("dns_zone_id", input.DnsZoneId).Equals("name", input.Name)
+	q = q.Equals("policy_type", input.PolicyType).Equals("policy_value", input.PolicyValue)
 	recordTypeQuery := q
 	switch input.DnsType {
 	case "CNAME":
@@ -151,6 +160,63 @@ func (manager *SDnsRecordManager) ValidateCreateData(
 	return input, nil
 }
 
+// validateLocalPolicy checks the view of records in local zones served by
+// region-dns, returns the normalized policy value
+func (manager *SDnsRecordManager) validateLocalPolicy(ctx context.Context, userCred mcclient.TokenCredential, policyType, policyValue string) (string, error) {
+	values := []string{}
+	for _, v := range strings.Split(policyValue, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	switch policyType {
+	case "", string(cloudprovider.DnsPolicyTypeSimple):
+		if len(values) > 0 {
+			return "", httperrors.NewInputParameterError("policy_value is not allowed for default view")
+		}
+	case string(cloudprovider.DnsPolicyTypeIpRange):
+		if len(values) == 0 {
+			return "", httperrors.NewMissingParameterError("policy_value")
+		}
+		for i, v := range values {
+			if !strings.Contains(v, "/") {
+				if regutils.MatchIP6Addr(v) {
+					v += "/128"
+				} else {
+					v += "/32"
+				}
+			}
+			_, ipnet, err := net.ParseCIDR(v)
+			if err != nil {
+				return "", httperrors.NewInputParameterError("invalid ip range %s", values[i])
+			}
+			values[i] = ipnet.String()
+		}
+	case api.DNS_POLICY_TYPE_BY_VPC:
+		if len(values) == 0 {
+			return "", httperrors.NewMissingParameterError("policy_value")
+		}
+		for i, v := range values {
+			vpc, err := VpcManager.FetchByIdOrName(ctx, userCred, v)
+			if err != nil {
+				if errors.Cause(err) == sql.ErrNoRows {
+					return "", httperrors.NewResourceNotFoundError2("vpc", v)
+				}
+				return "", httperrors.NewGeneralError(errors.Wrapf(err, "VpcManager.FetchByIdOrName"))
+			}
+			values[i] = vpc.GetId()
+		}
+	default:
+		return "", httperrors.NewNotSupportedError("policy type %s is not supported by local dns zone, supported %s", policyType, api.LOCAL_DNS_POLICY_TYPES)
+	}
+	policyValue = strings.Join(values, ",")
+	if len(policyValue) > 256 {
+		return "", httperrors.NewInputParameterError("policy_value too long")
+	}
+	return policyValue, nil
+}
+
 func (self *SDnsRecord) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	self.SEnabledStatusStandaloneResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
 	self.StartCreateTask(ctx, userCred, "")
@@ -350,22 +416,40 @@ func (self *SDnsRecord) ValidateUpdateData(ctx context.Context, userCred mcclien
 	}
 
 	record := api.SDnsRecord{
-		DnsType:  self.DnsType,
-		DnsValue: self.DnsValue,
+		DnsType:    self.DnsType,
+		DnsValue:   self.DnsValue,
+		MxPriority: self.MxPriority,
 	}
 
-	if len(input.DnsType) == 0 {
+	if len(input.DnsType) > 0 {
 		record.DnsType = input.DnsType
 	}
-	if len(input.DnsValue) == 0 {
+	if len(input.DnsValue) > 0 {
 		record.DnsValue = input.DnsValue
 	}
+	if input.MxPriority != nil {
+		record.MxPriority = *input.MxPriority
+	}
 
 	err = record.ValidateDnsrecordValue()
 	if err != nil {
 		return input, err
 	}
 
+	policyType, policyValue := self.PolicyType, self.PolicyValue
+	if len(input.PolicyType) > 0 {
+		policyType, policyValue = input.PolicyType, input.PolicyValue
+	} else if len(input.PolicyValue) > 0 {
+		policyValue = input.PolicyValue
+	}
+	if len(dnsZone.ManagerId) == 0 && (policyType != self.PolicyType || policyValue != self.PolicyValue) {
+		policyValue, err = DnsRecordManager.validateLocalPolicy(ctx, userCred, policyType, policyValue)
+		if err != nil {
+			return input, err
+		}
+		input.PolicyType, input.PolicyValue = policyType, policyValue
+	}
+
 	// 处理重复的记录
 
 	// CNAME  dnsName不能和其他类型record相同
@@ -376,10 +460,16 @@ func (self *SDnsRecord) ValidateUpdateData(ctx context.Context, userCred mcclien
 	// 不同类型policy不能重复
 	// 同类型policy的dnsrecord重复时，需要通过policyvalue区别
 
-	// validate name type
-	q := DnsRecordManager.Query().Equals("dns_zone_id", dnsZone.Id).NotEquals("id", self.Id).Equals("name", input.Name)
+	name := self.Name
+	if len(input.Name) > 0 {
+		name = input.Name
+	}
+
+	// validate name type, records of different views do not conflict
+	q := DnsRecordManager.Query().Equals("dns_zone_id", dnsZone.Id).NotEquals("id", self.Id).Equals("name", name)
+	q = q.Equals("policy_type", policyType).Equals("policy_value", policyValue)
 	recordTypeQuery := q
-	switch input.DnsType {
+	switch record.DnsType {
 	case "CNAME":
 		recordTypeQuery = recordTypeQuery.NotEquals("dns_type", "CNAME")
 	default:
@@ -599,9 +689,77 @@ func (man *SDnsRecordManager) QueryPtr(projectId, ip string) ([]api.SDnsResolveR
 	return results, nil
 }
 
-func (man *SDnsRecordManager) QueryDns(projectId, name, kind string) ([]api.SDnsResolveResult, error) {
+// SDnsQuerySource describes where a dns query comes from, the records of
+// views matching the source take precedence over those of the default view
+type SDnsQuerySource struct {
+	ProjectId string
+	Ip        string
+	VpcIds    []string
+}
+
+// dnsViewPriority returns how specific the view of rec matches src, the
+// default view is 0 and -1 means not matching
+func dnsViewPriority(rec *api.SDnsResolveResult, src *SDnsQuerySource) int {
+	switch rec.PolicyType {
+	case api.DNS_POLICY_TYPE_BY_VPC:
+		for _, vpcId := range strings.Split(rec.PolicyValue, ",") {
+			if utils.IsInStringArray(vpcId, src.VpcIds) {
+				return 256
+			}
+		}
+		return -1
+	case string(cloudprovider.DnsPolicyTypeIpRange):
+		prio := -1
+		ip := net.ParseIP(src.Ip)
+		if ip == nil {
+			return prio
+		}
+		// the longest prefix wins
+		for _, r := range strings.Split(rec.PolicyValue, ",") {
+			_, ipnet, err := net.ParseCIDR(r)
+			if err != nil || !ipnet.Contains(ip) {
+				continue
+			}
+			ones, _ := ipnet.Mask.Size()
+			if ones+1 > prio {
+				prio = ones + 1
+			}
+		}
+		return prio
+	}
+	return 0
+}
+
+// selectDnsView picks the records of the most specific name, and among
+// them those of the most specific view matching src
+func selectDnsView(results []api.SDnsResolveResult, src *SDnsQuerySource) []api.SDnsResolveResult {
+	sort.Sort(sDnsResolveResults(results))
+	dnsName, maxPrio := "", -1
+	ret := make([]api.SDnsResolveResult, 0, len(results))
+	for i := range results {
+		if maxPrio >= 0 && results[i].DnsName != dnsName {
+			break
+		}
+		prio := dnsViewPriority(&results[i], src)
+		if prio < 0 {
+			continue
+		}
+		dnsName = results[i].DnsName
+		if prio > maxPrio {
+			maxPrio = prio
+			ret = ret[:0]
+		}
+		if prio == maxPrio {
+			ret = append(ret, results[i])
+		}
+	}
+	return ret
+}
+
+func (man *SDnsRecordManager) QueryDns(src *SDnsQuerySource, name, kind string) ([]api.SDnsResolveResult, error) {
 	name = strings.TrimSuffix(name, ".")
 
+	projectId := src.ProjectId
 	zonesQ := DnsZoneManager.Query().IsNullOrEmpty("manager_id").IsTrue("enabled")
 	if len(projectId) == 0 {
 		zonesQ = zonesQ.IsTrue("is_public")
@@ -622,6 +780,8 @@ func (man *SDnsRecordManager) QueryDns(projectId, name, kind string) ([]api.SDns
 		recSQ.Field("dns_value"),
 		recSQ.Field("ttl"),
 		sqlchemy.CONCAT("dns_name", recSQ.Field("name"), sqlchemy.NewStringField("."), zones.Field("name")),
+		recSQ.Field("policy_type"),
+		recSQ.Field("policy_value"),
 	).Join(zones, sqlchemy.Equals(recSQ.Field("dns_zone_id"), zones.Field("id")))
 
 	sq := rec.SubQuery()
@@ -655,20 +815,25 @@ func (man *SDnsRecordManager) QueryDns(projectId, name, kind string) ([]api.SDns
 		return nil, errors.Wrap(err, "FetchModelObjects")
 	}
 
-	if len(results) == 0 {
-		return results, nil
-	}
+	return selectDnsView(results, src), nil
+}
 
-	sort.Sort(sDnsResolveResults(results))
-	ret := make([]api.SDnsResolveResult, 0, len(results))
-	for i := range results {
-		if i == 0 || results[i].DnsName == ret[0].DnsName {
-			ret = append(ret, results[i])
-		} else {
-			break
-		}
+// QueryZoneDnsTypes returns the record types of the names in zone, names are relative to the zone
+func (man *SDnsRecordManager) QueryZoneDnsTypes(zoneId string) (map[string][]string, error) {
+	q := man.Query("name", "dns_type").Equals("dns_zone_id", zoneId).IsTrue("enabled").Distinct()
+	rows := []struct {
+		Name    string
+		DnsType string
+	}{}
+	err := q.All(&rows)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "Query")
+	}
+	ret := map[string][]string{}
+	for i := range rows {
+		name := strings.ToLower(rows[i].Name)
+		ret[name] = append(ret[name], rows[i].DnsType)
 	}
-
 	return ret, nil
 }
 
@@ -41,3 +41,44 @@ func TestSortDnsResults(t *testing.T) {
 	sort.Sort(sDnsResolveResults(results))
 	t.Logf("results: %s", jsonutils.Marshal(results))
 }
+
+func TestSelectDnsView(t *testing.T) {
+	results := func() []api.SDnsResolveResult {
+		return []api.SDnsResolveResult{
+			{DnsName: "www.example.com", DnsValue: "1.1.1.1"},
+			{DnsName: "www.example.com", DnsValue: "10.0.0.10", PolicyType: "ByVpc", PolicyValue: "vpc1,vpc2"},
+			{DnsName: "www.example.com", DnsValue: "192.168.1.10", PolicyType: "IpRange", PolicyValue: "192.168.0.0/16"},
+			{DnsName: "www.example.com", DnsValue: "192.168.1.11", PolicyType: "IpRange", PolicyValue: "10.0.0.0/8,192.168.1.0/24"},
+			{DnsName: "*.example.com", DnsValue: "2.2.2.2"},
+			{DnsName: "api.example.com", DnsValue: "10.0.0.11", PolicyType: "ByVpc", PolicyValue: "vpc1"},
+		}
+	}
+	cases := []struct {
+		name string
+		src  SDnsQuerySource
+		want []string
+	}{
+		{"www.example.com", SDnsQuerySource{Ip: "8.8.8.8"}, []string{"1.1.1.1"}},
+		{"www.example.com", SDnsQuerySource{Ip: "10.0.0.5", VpcIds: []string{"vpc2"}}, []string{"10.0.0.10"}},
+		{"www.example.com", SDnsQuerySource{Ip: "192.168.2.5"}, []string{"192.168.1.10"}},
+		{"www.example.com", SDnsQuerySource{Ip: "192.168.1.5"}, []string{"192.168.1.11"}},
+		{"api.example.com", SDnsQuerySource{Ip: "10.0.0.5", VpcIds: []string{"vpc1"}}, []string{"10.0.0.11"}},
+		// falls back to the wildcard when no view of the name matches
+		{"api.example.com", SDnsQuerySource{Ip: "8.8.8.8"}, []string{"2.2.2.2"}},
+	}
+	for _, c := range cases {
+		rs := []api.SDnsResolveResult{}
+		for _, r := range results() {
+			if r.DnsName == c.name || r.DnsName == "*.example.com" {
+				rs = append(rs, r)
+			}
+		}
+		got := []string{}
+		for _, r := range selectDnsView(rs, &c.src) {
+			got = append(got, r.DnsValue)
+		}
+		if jsonutils.Marshal(got).String() != jsonutils.Marshal(c.want).String() {
+			t.Errorf("%s from %s %v: got %v want %v", c.name, c.src.Ip, c.src.VpcIds, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+const (
+	// DNSKEY和签名的TTL
+	DNSSEC_DNSKEY_TTL = 3600
+
+	// 新密钥发布后需等待缓存中旧的DNSKEY过期才能用于签名,
+	// 停用的密钥需等待缓存中旧的签名过期才能移除
+	dnssecKeyPropagationDelay = 2 * DNSSEC_DNSKEY_TTL * time.Second
+)
+
+type sDnssecAlgorithm struct {
+	algorithm uint8
+	kskBits   int
+	zskBits   int
+}
+
+var dnssecAlgorithms = map[string]sDnssecAlgorithm{
+	api.DNSSEC_ALGORITHM_RSASHA256:       {algorithm: dns.RSASHA256, kskBits: 2048, zskBits: 2048},
+	api.DNSSEC_ALGORITHM_ECDSAP256SHA256: {algorithm: dns.ECDSAP256SHA256, kskBits: 256, zskBits: 256},
+	api.DNSSEC_ALGORITHM_ECDSAP384SHA384: {algorithm: dns.ECDSAP384SHA384, kskBits: 384, zskBits: 384},
+	api.DNSSEC_ALGORITHM_ED25519:         {algorithm: dns.ED25519, kskBits: 256, zskBits: 256},
+}
+
+// SDnsZoneKeyManager manages the DNSSEC signing keys of local dns zones
+// served by region-dns, keys are only accessed through dnszones API
+type SDnsZoneKeyManager struct {
+	db.SStandaloneAnonResourceBaseManager
+}
+
+var DnsZoneKeyManager *SDnsZoneKeyManager
+
+func init() {
+	DnsZoneKeyManager = &SDnsZoneKeyManager{
+		SStandaloneAnonResourceBaseManager: db.NewStandaloneAnonResourceBaseManager(
+			SDnsZoneKey{},
+			"dnszone_keys_tbl",
+			"dns_zone_key",
+			"dns_zone_keys",
+		),
+	}
+	DnsZoneKeyManager.SetVirtualObject(DnsZoneKeyManager)
+}
+
+// SDnsZoneKey is a DNSSEC key, the state of a key is decided by its timing,
+// so that rollovers take effect without further operations
+type SDnsZoneKey struct {
+	db.SStandaloneAnonResourceBase
+	SDnsZoneResourceBase
+
+	KeyType   string `width:"8" charset:"ascii" nullable:"false" list:"user"`
+	Algorithm string `width:"32" charset:"ascii" nullable:"false" list:"user"`
+	KeyTag    int    `nullable:"false" list:"user"`
+	// DNSKEY公钥, base64编码
+	PublicKey string `charset:"ascii" nullable:"false"`
+	// 加密的私钥
+	PrivateKey string `charset:"ascii" nullable:"false"`
+
+	PublishAt  time.Time `nullable:"false" list:"user"`
+	ActivateAt time.Time `nullable:"false" list:"user"`
+	RetireAt   time.Time `nullable:"true" list:"user"`
+	DeleteAt   time.Time `nullable:"true" list:"user"`
+}
+
+func (manager *SDnsZoneKeyManager) newKey(ctx context.Context, zone *SDnsZone, keyType string, publishAt, activateAt time.Time) (*SDnsZoneKey, error) {
+	key, err := generateDnssecKey(zone.Name, zone.DnssecAlgorithm, keyType)
+	if err != nil {
+		return nil, err
+	}
+	key.DnsZoneId = zone.Id
+	key.PublishAt = publishAt
+	key.ActivateAt = activateAt
+	key.SetModelManager(manager, key)
+	err = manager.TableSpec().Insert(ctx, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Insert")
+	}
+	return key, nil
+}
+
+func generateDnssecKey(zoneName, algorithm, keyType string) (*SDnsZoneKey, error) {
+	algo, ok := dnssecAlgorithms[algorithm]
+	if !ok {
+		return nil, httperrors.NewNotSupportedError("unsupported dnssec algorithm %q", algorithm)
+	}
+	key := &SDnsZoneKey{
+		KeyType:   keyType,
+		Algorithm: algorithm,
+	}
+	key.Id = db.DefaultUUIDGenerator()
+	dnskey := key.newDnskey(zoneName)
+	bits := algo.zskBits
+	if keyType == api.DNSSEC_KEY_TYPE_KSK {
+		bits = algo.kskBits
+	}
+	priv, err := dnskey.Generate(bits)
+	if err != nil {
+		return nil, errors.Wrapf(err, "generate %s %s key", algorithm, keyType)
+	}
+	key.PublicKey = dnskey.PublicKey
+	key.KeyTag = int(dnskey.KeyTag())
+	key.PrivateKey, err = utils.EncryptAESBase64(key.Id, dnskey.PrivateKeyString(priv))
+	if err != nil {
+		return nil, errors.Wrap(err, "EncryptAESBase64")
+	}
+	return key, nil
+}
+
+func (key *SDnsZoneKey) newDnskey(zoneName string) *dns.DNSKEY {
+	flags := uint16(dns.ZONE)
+	if key.KeyType == api.DNSSEC_KEY_TYPE_KSK {
+		flags |= dns.SEP
+	}
+	algo := dnssecAlgorithms[key.Algorithm]
+	return &dns.DNSKEY{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(zoneName),
+			Rrtype: dns.TypeDNSKEY,
+			Class:  dns.ClassINET,
+			Ttl:    DNSSEC_DNSKEY_TTL,
+		},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: algo.algorithm,
+		PublicKey: key.PublicKey,
+	}
+}
+
+// GetDnskey returns the DNSKEY record of the key in zone
+func (key *SDnsZoneKey) GetDnskey(zoneName string) *dns.DNSKEY {
+	return key.newDnskey(zoneName)
+}
+
+// GetSigner returns the private key used for signing
+func (key *SDnsZoneKey) GetSigner(zoneName string) (crypto.Signer, error) {
+	privStr, err := utils.DescryptAESBase64(key.Id, key.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "DescryptAESBase64")
+	}
+	priv, err := key.newDnskey(zoneName).NewPrivateKey(privStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewPrivateKey")
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.Wrapf(errors.ErrNotSupported, "private key %T", priv)
+	}
+	return signer, nil
+}
+
+func (key *SDnsZoneKey) GetState(now time.Time) string {
+	switch {
+	case !key.DeleteAt.IsZero() && !now.Before(key.DeleteAt):
+		return api.DNSSEC_KEY_STATE_REMOVED
+	case !key.RetireAt.IsZero() && !now.Before(key.RetireAt):
+		return api.DNSSEC_KEY_STATE_RETIRED
+	case !now.Before(key.ActivateAt):
+		return api.DNSSEC_KEY_STATE_ACTIVE
+	case !now.Before(key.PublishAt):
+		return api.DNSSEC_KEY_STATE_PUBLISHED
+	}
+	return ""
+}
+
+// IsPublished tells whether the key is in the DNSKEY rrset
+func (key *SDnsZoneKey) IsPublished(now time.Time) bool {
+	return utils.IsInStringArray(key.GetState(now), []string{
+		api.DNSSEC_KEY_STATE_PUBLISHED,
+		api.DNSSEC_KEY_STATE_ACTIVE,
+		api.DNSSEC_KEY_STATE_RETIRED,
+	})
+}
+
+// IsActive tells whether the key is used for signing
+func (key *SDnsZoneKey) IsActive(now time.Time) bool {
+	return key.GetState(now) == api.DNSSEC_KEY_STATE_ACTIVE
+}
+
+func (key *SDnsZoneKey) retire(ctx context.Context, retireAt time.Time) error {
+	_, err := db.Update(key, func() error {
+		key.RetireAt = retireAt
+		key.DeleteAt = retireAt.Add(dnssecKeyPropagationDelay)
+		return nil
+	})
+	return err
+}
+
+func (key *SDnsZoneKey) GetDetails(zoneName string, now time.Time) api.DnsZoneDnssecKey {
+	ret := api.DnsZoneDnssecKey{
+		Id:         key.Id,
+		KeyType:    key.KeyType,
+		Algorithm:  key.Algorithm,
+		KeyTag:     key.KeyTag,
+		State:      key.GetState(now),
+		PublishAt:  key.PublishAt,
+		ActivateAt: key.ActivateAt,
+		Dnskey:     key.GetDnskey(zoneName).String(),
+	}
+	if !key.RetireAt.IsZero() {
+		ret.RetireAt = &key.RetireAt
+	}
+	if !key.DeleteAt.IsZero() {
+		ret.DeleteAt = &key.DeleteAt
+	}
+	return ret
+}
+
+// GetDnssecKeys returns all keys of the zone which are not removed, ordered by activate time
+func (self *SDnsZone) GetDnssecKeys() ([]SDnsZoneKey, error) {
+	q := DnsZoneKeyManager.Query().Equals("dns_zone_id", self.Id).Asc("activate_at")
+	keys := []SDnsZoneKey{}
+	err := db.FetchModelObjects(DnsZoneKeyManager, q, &keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	now := time.Now()
+	ret := []SDnsZoneKey{}
+	for i := range keys {
+		if keys[i].GetState(now) != api.DNSSEC_KEY_STATE_REMOVED {
+			ret = append(ret, keys[i])
+		}
+	}
+	return ret, nil
+}
+
+// getCurrentDnssecKeys returns the keys of keyType which are not going to retire
+func (self *SDnsZone) getCurrentDnssecKeys(keyType string) ([]SDnsZoneKey, error) {
+	keys, err := self.GetDnssecKeys()
+	if err != nil {
+		return nil, err
+	}
+	ret := []SDnsZoneKey{}
+	for i := range keys {
+		if keys[i].KeyType == keyType && keys[i].RetireAt.IsZero() {
+			ret = append(ret, keys[i])
+		}
+	}
+	return ret, nil
+}
+
+// GetDnssecDs returns the DS records of KSKs, which should be added to the parent zone
+func (self *SDnsZone) GetDnssecDs(keys []SDnsZoneKey) []string {
+	now := time.Now()
+	ret := []string{}
+	for i := range keys {
+		if keys[i].KeyType != api.DNSSEC_KEY_TYPE_KSK || !keys[i].RetireAt.IsZero() || keys[i].GetState(now) == api.DNSSEC_KEY_STATE_REMOVED {
+			continue
+		}
+		ds := keys[i].GetDnskey(self.Name).ToDS(dns.SHA256)
+		if ds != nil {
+			ret = append(ret, ds.String())
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (self *SDnsZone) removeDnssecKeys(ctx context.Context) error {
+	keys := &purgePair{manager: DnsZoneKeyManager, key: "id", q: DnsZoneKeyManager.Query("id").Equals("dns_zone_id", self.Id)}
+	return keys.purgeAll(ctx)
+}
+
+// PurgeRemovedDnssecKeys deletes the keys which are no longer published
+func (manager *SDnsZoneKeyManager) PurgeRemovedDnssecKeys(ctx context.Context) error {
+	q := manager.Query("id").IsNotNull("delete_at").LT("delete_at", time.Now())
+	keys := &purgePair{manager: manager, key: "id", q: q}
+	return keys.purgeAll(ctx)
+}
+
+// rolloverDnssecKey starts a rollover of keyType, a ZSK is rolled over by
+// pre-publishing the new key, a KSK by double signing until the old KSK is
+// retired explicitly after the DS of the new KSK is added to the parent zone
+func (self *SDnsZone) rolloverDnssecKey(ctx context.Context, keyType string) (*SDnsZoneKey, error) {
+	olds, err := self.getCurrentDnssecKeys(keyType)
+	if err != nil {
+		return nil, errors.Wrap(err, "getCurrentDnssecKeys")
+	}
+	now := time.Now()
+	if keyType == api.DNSSEC_KEY_TYPE_KSK {
+		return DnsZoneKeyManager.newKey(ctx, self, keyType, now, now)
+	}
+	for i := range olds {
+		if olds[i].ActivateAt.After(now) {
+			return nil, httperrors.NewConflictError("zsk %d of %s is still pending activation", olds[i].KeyTag, self.Name)
+		}
+	}
+	activateAt := now.Add(dnssecKeyPropagationDelay)
+	key, err := DnsZoneKeyManager.newKey(ctx, self, keyType, now, activateAt)
+	if err != nil {
+		return nil, err
+	}
+	for i := range olds {
+		err = olds[i].retire(ctx, activateAt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "retire key %s", olds[i].Id)
+		}
+	}
+	return key, nil
+}
+
+func (self *SDnsZone) retireDnssecKey(ctx context.Context, keyId string) (*SDnsZoneKey, error) {
+	keys, err := self.getCurrentDnssecKeys(api.DNSSEC_KEY_TYPE_KSK)
+	if err != nil {
+		return nil, errors.Wrap(err, "getCurrentDnssecKeys")
+	}
+	var key *SDnsZoneKey
+	for i := range keys {
+		if keys[i].Id == keyId || fmt.Sprintf("%d", keys[i].KeyTag) == keyId {
+			key = &keys[i]
+		}
+	}
+	if key == nil {
+		return nil, httperrors.NewResourceNotFoundError("active ksk %s of %s not found", keyId, self.Name)
+	}
+	if len(keys) < 2 {
+		return nil, httperrors.NewConflictError("cannot retire the last ksk of %s, rollover it first", self.Name)
+	}
+	err = key.retire(ctx, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(err, "retire")
+	}
+	return key, nil
+}
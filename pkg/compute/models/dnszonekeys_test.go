@@ -0,0 +1,93 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+func TestDnsZoneKeyState(t *testing.T) {
+	now := time.Now()
+	key := SDnsZoneKey{
+		PublishAt:  now.Add(-2 * time.Hour),
+		ActivateAt: now.Add(time.Hour),
+	}
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{now.Add(-3 * time.Hour), ""},
+		{now, api.DNSSEC_KEY_STATE_PUBLISHED},
+		{now.Add(2 * time.Hour), api.DNSSEC_KEY_STATE_ACTIVE},
+	}
+	for _, c := range cases {
+		if got := key.GetState(c.at); got != c.want {
+			t.Errorf("state at %s: want %q got %q", c.at, c.want, got)
+		}
+	}
+	key.RetireAt = now.Add(3 * time.Hour)
+	key.DeleteAt = key.RetireAt.Add(dnssecKeyPropagationDelay)
+	if got := key.GetState(now.Add(4 * time.Hour)); got != api.DNSSEC_KEY_STATE_RETIRED {
+		t.Errorf("want retired got %q", got)
+	}
+	if key.IsPublished(key.DeleteAt) {
+		t.Errorf("key should not be published after delete_at")
+	}
+}
+
+func TestGenerateDnssecKey(t *testing.T) {
+	for _, algo := range api.DNSSEC_ALGORITHMS {
+		for _, keyType := range []string{api.DNSSEC_KEY_TYPE_KSK, api.DNSSEC_KEY_TYPE_ZSK} {
+			key, err := generateDnssecKey("example.com", algo, keyType)
+			if err != nil {
+				t.Fatalf("generate %s %s: %v", algo, keyType, err)
+			}
+			dnskey := key.GetDnskey("example.com")
+			if int(dnskey.KeyTag()) != key.KeyTag {
+				t.Errorf("%s %s: key tag mismatch", algo, keyType)
+			}
+			if isKsk := dnskey.Flags&dns.SEP != 0; isKsk != (keyType == api.DNSSEC_KEY_TYPE_KSK) {
+				t.Errorf("%s %s: invalid flags %d", algo, keyType, dnskey.Flags)
+			}
+			signer, err := key.GetSigner("example.com")
+			if err != nil {
+				t.Fatalf("%s %s GetSigner: %v", algo, keyType, err)
+			}
+			rrset := []dns.RR{&dns.A{
+				Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+				A:   []byte{10, 0, 0, 1},
+			}}
+			sig := &dns.RRSIG{
+				Hdr:        dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 300},
+				Algorithm:  dnskey.Algorithm,
+				KeyTag:     dnskey.KeyTag(),
+				SignerName: dnskey.Hdr.Name,
+				Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+				Expiration: uint32(time.Now().Add(time.Hour).Unix()),
+			}
+			if err := sig.Sign(signer, rrset); err != nil {
+				t.Fatalf("%s %s Sign: %v", algo, keyType, err)
+			}
+			if err := sig.Verify(dnskey, rrset); err != nil {
+				t.Errorf("%s %s Verify: %v", algo, keyType, err)
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
 
 	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
@@ -40,6 +41,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
 )
 
@@ -72,6 +74,13 @@ type SDnsZone struct {
 
 	ZoneType    string `width:"32" charset:"ascii" nullable:"false" list:"domain" create:"domain_required"`
 	ProductType string `width:"32" charset:"ascii" nullable:"false" list:"domain" create:"domain_optional"`
+
+	// 是否启用DNSSEC签名, 仅region-dns提供解析的本地区域支持
+	DnssecEnabled bool `nullable:"false" default:"false" list:"user"`
+	// DNSSEC签名算法
+	DnssecAlgorithm string `width:"32" charset:"ascii" nullable:"true" list:"user"`
+	// ZSK自动轮换周期(天)
+	DnssecZskLifetimeDays int `nullable:"false" default:"0" list:"user"`
 }
 
 // 创建
@@ -364,9 +373,12 @@ func (self *SDnsZone) RealDelete(ctx context.Context, userCred mcclient.TokenCre
 	dnsVpcs := DnsZoneVpcManager.Query("row_id").Equals("dns_zone_id", self.Id)
 	records := DnsRecordManager.Query("id").Equals("dns_zone_id", self.Id)
 
+	keys := DnsZoneKeyManager.Query("id").Equals("dns_zone_id", self.Id)
+
 	pairs := []purgePair{
 		{manager: DnsZoneVpcManager, key: "row_id", q: dnsVpcs},
 		{manager: DnsRecordManager, key: "id", q: records},
+		{manager: DnsZoneKeyManager, key: "id", q: keys},
 	}
 	for i := range pairs {
 		err := pairs[i].purgeAll(ctx)
@@ -633,6 +645,201 @@ func (self *SDnsZone) PerformRemoveVpcs(ctx context.Context, userCred mcclient.T
 	return nil, self.StartDnsZoneRemoveVpcsTask(ctx, userCred, input.VpcIds, "")
 }
 
+func (self *SDnsZone) validateDnssec() error {
+	if len(self.ManagerId) > 0 {
+		return httperrors.NewUnsupportOperationError("dnssec is only supported by local dns zones")
+	}
+	if self.Status != api.DNS_ZONE_STATUS_AVAILABLE {
+		return httperrors.NewInvalidStatusError("dns zone %s can not change dnssec in status %s", self.Name, self.Status)
+	}
+	return nil
+}
+
+// 启用DNSSEC
+func (self *SDnsZone) PerformEnableDnssec(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneEnableDnssecInput) (jsonutils.JSONObject, error) {
+	err := self.validateDnssec()
+	if err != nil {
+		return nil, err
+	}
+	if self.DnssecEnabled {
+		return nil, httperrors.NewInvalidStatusError("dnssec of %s is already enabled", self.Name)
+	}
+	if len(input.Algorithm) == 0 {
+		input.Algorithm = api.DNSSEC_ALGORITHM_ECDSAP256SHA256
+	}
+	if _, ok := dnssecAlgorithms[input.Algorithm]; !ok {
+		return nil, httperrors.NewInputParameterError("invalid algorithm %s, supported %s", input.Algorithm, api.DNSSEC_ALGORITHMS)
+	}
+	if input.ZskLifetimeDays < 0 {
+		return nil, httperrors.NewInputParameterError("invalid zsk_lifetime_days %d", input.ZskLifetimeDays)
+	}
+
+	lockman.LockObject(ctx, self)
+	defer lockman.ReleaseObject(ctx, self)
+
+	// keys left by a previous disable are useless with another algorithm
+	err = self.removeDnssecKeys(ctx)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrap(err, "removeDnssecKeys"))
+	}
+	_, err = db.Update(self, func() error {
+		self.DnssecAlgorithm = input.Algorithm
+		self.DnssecZskLifetimeDays = input.ZskLifetimeDays
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	now := time.Now()
+	for _, keyType := range []string{api.DNSSEC_KEY_TYPE_KSK, api.DNSSEC_KEY_TYPE_ZSK} {
+		_, err = DnsZoneKeyManager.newKey(ctx, self, keyType, now, now)
+		if err != nil {
+			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "new %s", keyType))
+		}
+	}
+	_, err = db.Update(self, func() error {
+		self.DnssecEnabled = true
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, jsonutils.Marshal(input), userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_ENABLE_DNSSEC, input, userCred, true)
+	return nil, nil
+}
+
+// 关闭DNSSEC, 关闭前需要先删除上级域中的DS记录, 否则解析将无法通过校验
+func (self *SDnsZone) PerformDisableDnssec(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneDisableDnssecInput) (jsonutils.JSONObject, error) {
+	if !self.DnssecEnabled {
+		return nil, nil
+	}
+	lockman.LockObject(ctx, self)
+	defer lockman.ReleaseObject(ctx, self)
+
+	_, err := db.Update(self, func() error {
+		self.DnssecEnabled = false
+		return nil
+	})
+	if err != nil {
+		return nil, httperrors.NewGeneralError(err)
+	}
+	err = self.removeDnssecKeys(ctx)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrap(err, "removeDnssecKeys"))
+	}
+	db.OpsLog.LogEvent(self, db.ACT_UPDATE, jsonutils.Marshal(input), userCred)
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_DISABLE_DNSSEC, input, userCred, true)
+	return nil, nil
+}
+
+// 轮换DNSSEC密钥
+func (self *SDnsZone) PerformRolloverDnssecKey(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneRolloverDnssecKeyInput) (jsonutils.JSONObject, error) {
+	err := self.validateDnssec()
+	if err != nil {
+		return nil, err
+	}
+	if !self.DnssecEnabled {
+		return nil, httperrors.NewInvalidStatusError("dnssec of %s is not enabled", self.Name)
+	}
+	if !utils.IsInStringArray(input.KeyType, []string{api.DNSSEC_KEY_TYPE_KSK, api.DNSSEC_KEY_TYPE_ZSK}) {
+		return nil, httperrors.NewInputParameterError("invalid key_type %q", input.KeyType)
+	}
+	lockman.LockObject(ctx, self)
+	defer lockman.ReleaseObject(ctx, self)
+
+	key, err := self.rolloverDnssecKey(ctx, input.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_ROLLOVER_DNSSEC_KEY, input, userCred, true)
+	return jsonutils.Marshal(key.GetDetails(self.Name, time.Now())), nil
+}
+
+// 停用KSK, 用于KSK轮换时上级域DS记录更新后停用旧的KSK
+func (self *SDnsZone) PerformRetireDnssecKey(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.DnsZoneRetireDnssecKeyInput) (jsonutils.JSONObject, error) {
+	if !self.DnssecEnabled {
+		return nil, httperrors.NewInvalidStatusError("dnssec of %s is not enabled", self.Name)
+	}
+	if len(input.KeyId) == 0 {
+		return nil, httperrors.NewMissingParameterError("key_id")
+	}
+	lockman.LockObject(ctx, self)
+	defer lockman.ReleaseObject(ctx, self)
+
+	key, err := self.retireDnssecKey(ctx, input.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	logclient.AddActionLogWithContext(ctx, self, logclient.ACT_RETIRE_DNSSEC_KEY, input, userCred, true)
+	return jsonutils.Marshal(key.GetDetails(self.Name, time.Now())), nil
+}
+
+// 获取DNSSEC密钥及DS记录
+func (self *SDnsZone) GetDetailsDnssec(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (api.DnsZoneDnssecDetails, error) {
+	ret := api.DnsZoneDnssecDetails{
+		Enabled:         self.DnssecEnabled,
+		Algorithm:       self.DnssecAlgorithm,
+		ZskLifetimeDays: self.DnssecZskLifetimeDays,
+		Keys:            []api.DnsZoneDnssecKey{},
+		Ds:              []string{},
+	}
+	if !self.DnssecEnabled {
+		return ret, nil
+	}
+	keys, err := self.GetDnssecKeys()
+	if err != nil {
+		return ret, errors.Wrap(err, "GetDnssecKeys")
+	}
+	now := time.Now()
+	for i := range keys {
+		ret.Keys = append(ret.Keys, keys[i].GetDetails(self.Name, now))
+	}
+	ret.Ds = self.GetDnssecDs(keys)
+	return ret, nil
+}
+
+// AutoRolloverDnssecKeys rolls over the ZSKs which exceed the lifetime of
+// the zone, and purges keys no longer published
+func (manager *SDnsZoneManager) AutoRolloverDnssecKeys(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := manager.Query().IsTrue("dnssec_enabled").GT("dnssec_zsk_lifetime_days", 0).IsNullOrEmpty("manager_id")
+	zones := []SDnsZone{}
+	err := db.FetchModelObjects(manager, q, &zones)
+	if err != nil {
+		log.Errorf("fetch dnssec enabled zones error: %v", err)
+		return
+	}
+	for i := range zones {
+		zone := &zones[i]
+		func() {
+			lockman.LockObject(ctx, zone)
+			defer lockman.ReleaseObject(ctx, zone)
+
+			keys, err := zone.getCurrentDnssecKeys(api.DNSSEC_KEY_TYPE_ZSK)
+			if err != nil {
+				log.Errorf("get zsk of %s error: %v", zone.Name, err)
+				return
+			}
+			lifetime := time.Duration(zone.DnssecZskLifetimeDays) * 24 * time.Hour
+			for j := range keys {
+				if time.Since(keys[j].ActivateAt) < lifetime {
+					return
+				}
+			}
+			key, err := zone.rolloverDnssecKey(ctx, api.DNSSEC_KEY_TYPE_ZSK)
+			if err != nil {
+				log.Errorf("rollover zsk of %s error: %v", zone.Name, err)
+				return
+			}
+			log.Infof("rollover zsk of %s, new key tag %d", zone.Name, key.KeyTag)
+		}()
+	}
+	err = DnsZoneKeyManager.PurgeRemovedDnssecKeys(ctx)
+	if err != nil {
+		log.Errorf("PurgeRemovedDnssecKeys error: %v", err)
+	}
+}
+
 func (manager *SDnsZoneManager) GetPropertyCapability(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	return jsonutils.Marshal(cloudprovider.GetDnsCapabilities()), nil
 }
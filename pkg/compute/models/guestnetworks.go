@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
@@ -786,6 +787,29 @@ func (manager *SGuestnetworkManager) GetGuestByAddress(address string, projectId
 	return &guests[0]
 }
 
+// GetGuestnetworkByAddress returns the guest nic with the address, the address
+// spaces of vpcs can overlap, so the nic is not returned if the address is
+// used by more than one of them
+func (manager *SGuestnetworkManager) GetGuestnetworkByAddress(address string) (*SGuestnetwork, error) {
+	ipField := "ip_addr"
+	if regutils.MatchIP6Addr(address) {
+		ipField = "ip6_addr"
+	}
+	q := manager.Query().Equals(ipField, address)
+	gns := make([]SGuestnetwork, 0)
+	err := db.FetchModelObjects(manager, q, &gns)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	if len(gns) == 0 {
+		return nil, errors.Wrap(sql.ErrNoRows, address)
+	}
+	if len(gns) > 1 {
+		return nil, errors.Wrapf(cloudprovider.ErrDuplicateId, "%d guest nics with address %s", len(gns), address)
+	}
+	return &gns[0], nil
+}
+
 func (gn *SGuestnetwork) GetDetailedString() string {
 	network, err := gn.GetNetwork()
 	if err != nil {
@@ -0,0 +1,227 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	napi "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/notifyclient"
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
+	"yunion.io/x/onecloud/pkg/util/redfish"
+	_ "yunion.io/x/onecloud/pkg/util/redfish/loader"
+)
+
+var (
+	// the BMC drivers to fence the hosts, replaced by the tests
+	newFenceRedfishDriver = func(ctx context.Context, info types.SIPMIInfo) redfish.IRedfishDriver {
+		return redfish.NewRedfishDriver(ctx, "https://"+info.IpAddr, info.Username, info.Password, false)
+	}
+	newFenceIpmiExecutor = func(info types.SIPMIInfo) ipmitool.IPMIExecutor {
+		return ipmitool.NewLanPlusIPMI(info.IpAddr, info.Username, info.Password)
+	}
+
+	fenceRetryInterval       = 10 * time.Second
+	fencePowerStatusInterval = 5 * time.Second
+)
+
+// getFencePolicy returns the fencing policy and the action on fencing failure
+// of the host, zone metadata takes precedence over the service options
+func (host *SHost) getFencePolicy(ctx context.Context) (string, string) {
+	zonePolicy, zoneFailAction := "", ""
+	zone, err := host.GetZone()
+	if err != nil {
+		log.Errorf("host %s GetZone: %s", host.Name, err)
+	} else {
+		zonePolicy = zone.GetMetadata(ctx, api.ZONEMETA_HOST_FENCE_POLICY, nil)
+		zoneFailAction = zone.GetMetadata(ctx, api.ZONEMETA_HOST_FENCE_FAIL_ACTION, nil)
+	}
+	return fencePolicyOf(zonePolicy, zoneFailAction)
+}
+
+func fencePolicyOf(zonePolicy, zoneFailAction string) (string, string) {
+	policy := options.Options.DefaultHostFencePolicy
+	failAction := options.Options.DefaultHostFenceFailAction
+	if utils.IsInStringArray(zonePolicy, api.HOST_FENCE_POLICIES) {
+		policy = zonePolicy
+	}
+	if utils.IsInStringArray(zoneFailAction, api.HOST_FENCE_FAIL_ACTIONS) {
+		failAction = zoneFailAction
+	}
+	if !utils.IsInStringArray(policy, api.HOST_FENCE_POLICIES) {
+		policy = api.HOST_FENCE_POLICY_NONE
+	}
+	if !utils.IsInStringArray(failAction, api.HOST_FENCE_FAIL_ACTIONS) {
+		failAction = api.HOST_FENCE_FAIL_ACTION_ABORT
+	}
+	return policy, failAction
+}
+
+// fenceOnHostDown isolates the unhealthy host from the shared storages by
+// powering it off or power cycling it through its BMC, so that the guests
+// can't be running on two hosts at the same time after they are restarted
+// on other hosts. It returns whether the guests of the host can be restarted.
+func (host *SHost) fenceOnHostDown(ctx context.Context, userCred mcclient.TokenCredential) bool {
+	policy, failAction := host.getFencePolicy(ctx)
+	if policy == api.HOST_FENCE_POLICY_NONE {
+		return true
+	}
+
+	result := api.HostFenceResult{
+		Policy:   policy,
+		FencedAt: time.Now().UTC(),
+	}
+	method, err := retryFence(options.Options.HostFenceRetryCount, func() (string, error) {
+		return host.fenceByBMC(ctx, policy)
+	})
+	result.Method = method
+	if err != nil {
+		result.Status = api.HOST_FENCE_STATUS_FAILED
+		result.Reason = err.Error()
+	} else {
+		result.Status = api.HOST_FENCE_STATUS_SUCCESS
+	}
+
+	if err := host.SetMetadata(ctx, api.HOSTMETA_LAST_FENCE, jsonutils.Marshal(result), userCred); err != nil {
+		log.Errorf("host %s set fence result: %s", host.Name, err)
+	}
+	if result.Status == api.HOST_FENCE_STATUS_SUCCESS {
+		log.Infof("host %s fenced by %s %s", host.Name, method, policy)
+		db.OpsLog.LogEvent(host, db.ACT_HOST_FENCE, result, userCred)
+		logclient.AddActionLogWithContext(ctx, host, logclient.ACT_HOST_FENCE, result, userCred, true)
+		return true
+	}
+
+	log.Errorf("host %s fence by %s failed: %s, fail action %s", host.Name, policy, result.Reason, failAction)
+	db.OpsLog.LogEvent(host, db.ACT_HOST_FENCE_FAIL, result, userCred)
+	logclient.AddActionLogWithContext(ctx, host, logclient.ACT_HOST_FENCE, result, userCred, false)
+	// the guests are left down on abort, or may run on two hosts on continue, both need the admin
+	ndata := jsonutils.Marshal(host).(*jsonutils.JSONDict)
+	ndata.Set("reason", jsonutils.NewString(fmt.Sprintf("fence by %s failed: %s, fail action %s", policy, result.Reason, failAction)))
+	notifyclient.SystemExceptionNotifyWithResult(ctx, napi.ActionSystemException, HostManager.Keyword(), napi.ResultFailed, ndata)
+	return failAction == api.HOST_FENCE_FAIL_ACTION_CONTINUE
+}
+
+// retryFence retries the fencing on failure, the BMC may be busy or
+// unreachable for a while as the host is down
+func retryFence(retries int, fence func() (string, error)) (string, error) {
+	for tried := 0; ; tried++ {
+		method, err := fence()
+		if err == nil || tried >= retries || errors.Cause(err) == errors.ErrNotFound {
+			return method, err
+		}
+		log.Warningf("fence by %s failed: %s, retry after %s", method, err, fenceRetryInterval)
+		time.Sleep(fenceRetryInterval)
+	}
+}
+
+func (host *SHost) fenceByBMC(ctx context.Context, policy string) (string, error) {
+	info, err := host.GetIpmiInfo()
+	if err != nil {
+		return "", errors.Wrap(err, "GetIpmiInfo")
+	}
+	if len(info.IpAddr) == 0 || len(info.Username) == 0 || len(info.Password) == 0 {
+		return "", errors.Wrap(errors.ErrNotFound, "no BMC info of host")
+	}
+	passwd, err := utils.DescryptAESBase64(host.Id, info.Password)
+	if err != nil {
+		return "", errors.Wrap(err, "DescryptAESBase64")
+	}
+	info.Password = passwd
+
+	timeout := time.Duration(options.Options.HostFenceTimeoutSeconds) * time.Second
+	if info.RedfishApi {
+		return api.HOST_FENCE_METHOD_REDFISH, fenceByRedfish(ctx, info, policy, timeout)
+	}
+	return api.HOST_FENCE_METHOD_IPMI, fenceByIpmitool(info, policy, timeout)
+}
+
+func fenceByRedfish(ctx context.Context, info types.SIPMIInfo, policy string, timeout time.Duration) error {
+	drv := newFenceRedfishDriver(ctx, info)
+	if drv == nil {
+		return errors.Errorf("no redfish driver for %s", info.IpAddr)
+	}
+	if policy == api.HOST_FENCE_POLICY_POWER_CYCLE {
+		// PowerCycle is not supported by all vendors
+		err := drv.Reset(ctx, "PowerCycle")
+		if err != nil {
+			err = drv.Reset(ctx, "ForceRestart")
+			if err != nil {
+				return errors.Wrap(err, "redfish reset ForceRestart")
+			}
+		}
+		return nil
+	}
+	err := drv.Reset(ctx, "ForceOff")
+	if err != nil {
+		return errors.Wrap(err, "redfish reset ForceOff")
+	}
+	return waitFencedPowerOff(timeout, func() (string, error) {
+		_, sysInfo, err := drv.GetSystemInfo(ctx)
+		return sysInfo.PowerState, err
+	})
+}
+
+func fenceByIpmitool(info types.SIPMIInfo, policy string, timeout time.Duration) error {
+	cli := newFenceIpmiExecutor(info)
+	if policy == api.HOST_FENCE_POLICY_POWER_CYCLE {
+		// power cycle fails if the chassis is off, which is fenced already
+		if status, err := ipmitool.GetChassisPowerStatus(cli); err == nil && status == types.POWER_STATUS_OFF {
+			return nil
+		}
+		err := ipmitool.DoPowerCycle(cli)
+		if err != nil {
+			return errors.Wrap(err, "ipmitool power cycle")
+		}
+		return nil
+	}
+	err := ipmitool.DoHardShutdown(cli)
+	if err != nil {
+		return errors.Wrap(err, "ipmitool power off")
+	}
+	return waitFencedPowerOff(timeout, func() (string, error) {
+		return ipmitool.GetChassisPowerStatus(cli)
+	})
+}
+
+func waitFencedPowerOff(timeout time.Duration, getPowerStatus func() (string, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := getPowerStatus()
+		if err == nil && status == types.POWER_STATUS_OFF {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return errors.Wrap(err, "get power status")
+			}
+			return errors.Errorf("host still powered %s after %s", status, timeout)
+		}
+		time.Sleep(fencePowerStatusInterval)
+	}
+}
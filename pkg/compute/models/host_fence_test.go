@@ -0,0 +1,272 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/baremetal/utils/ipmitool"
+	"yunion.io/x/onecloud/pkg/cloudcommon/types"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/util/redfish"
+)
+
+// fakeIpmiExecutor answers the power status by the statuses in turn, the
+// last one is kept
+type fakeIpmiExecutor struct {
+	powerStatus []string
+	commands    []string
+}
+
+func (e *fakeIpmiExecutor) GetMode() string {
+	return "fake"
+}
+
+func (e *fakeIpmiExecutor) ExecuteCommand(args ...string) ([]string, error) {
+	cmd := strings.Join(args, " ")
+	e.commands = append(e.commands, cmd)
+	if cmd == "chassis power status" {
+		status := e.powerStatus[0]
+		if len(e.powerStatus) > 1 {
+			e.powerStatus = e.powerStatus[1:]
+		}
+		return []string{"Chassis Power is " + status}, nil
+	}
+	return []string{}, nil
+}
+
+type fakeRedfishDriver struct {
+	redfish.IRedfishDriver
+
+	unsupported []string
+	powerState  string
+	resets      []string
+}
+
+func (d *fakeRedfishDriver) Reset(ctx context.Context, action string) error {
+	d.resets = append(d.resets, action)
+	if utils.IsInStringArray(action, d.unsupported) {
+		return errors.Errorf("reset action %s not supported", action)
+	}
+	return nil
+}
+
+func (d *fakeRedfishDriver) GetSystemInfo(ctx context.Context) (string, redfish.SSystemInfo, error) {
+	return "", redfish.SSystemInfo{PowerState: d.powerState}, nil
+}
+
+func setupFenceTest(t *testing.T) {
+	pollInterval, retryInterval := fencePowerStatusInterval, fenceRetryInterval
+	newRedfishDriver, newIpmiExecutor := newFenceRedfishDriver, newFenceIpmiExecutor
+	fencePowerStatusInterval, fenceRetryInterval = time.Millisecond, time.Millisecond
+	t.Cleanup(func() {
+		fencePowerStatusInterval, fenceRetryInterval = pollInterval, retryInterval
+		newFenceRedfishDriver, newFenceIpmiExecutor = newRedfishDriver, newIpmiExecutor
+	})
+}
+
+func TestFencePolicyOf(t *testing.T) {
+	defer func(policy, failAction string) {
+		options.Options.DefaultHostFencePolicy, options.Options.DefaultHostFenceFailAction = policy, failAction
+	}(options.Options.DefaultHostFencePolicy, options.Options.DefaultHostFenceFailAction)
+
+	cases := []struct {
+		name              string
+		defaultPolicy     string
+		defaultFailAction string
+		zonePolicy        string
+		zoneFailAction    string
+		wantPolicy        string
+		wantFailAction    string
+	}{
+		{"defaults", "poweroff", "continue", "", "", "poweroff", "continue"},
+		{"zone overrides", "none", "abort", "powercycle", "continue", "powercycle", "continue"},
+		{"invalid zone metadata", "poweroff", "abort", "reboot", "ignore", "poweroff", "abort"},
+		{"invalid options", "reboot", "ignore", "", "", "none", "abort"},
+	}
+	for _, c := range cases {
+		options.Options.DefaultHostFencePolicy = c.defaultPolicy
+		options.Options.DefaultHostFenceFailAction = c.defaultFailAction
+		policy, failAction := fencePolicyOf(c.zonePolicy, c.zoneFailAction)
+		if policy != c.wantPolicy || failAction != c.wantFailAction {
+			t.Errorf("%s: want %s %s got %s %s", c.name, c.wantPolicy, c.wantFailAction, policy, failAction)
+		}
+	}
+}
+
+func TestWaitFencedPowerOff(t *testing.T) {
+	setupFenceTest(t)
+
+	polls := 0
+	err := waitFencedPowerOff(time.Second, func() (string, error) {
+		polls++
+		if polls < 3 {
+			return types.POWER_STATUS_ON, nil
+		}
+		return types.POWER_STATUS_OFF, nil
+	})
+	if err != nil || polls != 3 {
+		t.Errorf("power off after %d polls: %v", polls, err)
+	}
+
+	err = waitFencedPowerOff(10*time.Millisecond, func() (string, error) {
+		return types.POWER_STATUS_ON, nil
+	})
+	if err == nil {
+		t.Errorf("host kept powered on should fail")
+	}
+
+	err = waitFencedPowerOff(10*time.Millisecond, func() (string, error) {
+		return "", errors.Errorf("BMC unreachable")
+	})
+	if err == nil || !strings.Contains(err.Error(), "BMC unreachable") {
+		t.Errorf("unreachable BMC should fail with its error, got %v", err)
+	}
+}
+
+func TestFenceByIpmitool(t *testing.T) {
+	setupFenceTest(t)
+
+	cases := []struct {
+		name        string
+		policy      string
+		powerStatus []string
+		wantCmds    []string
+		wantErr     bool
+	}{
+		{
+			name:        "power off",
+			policy:      api.HOST_FENCE_POLICY_POWER_OFF,
+			powerStatus: []string{"on", "off"},
+			wantCmds:    []string{"chassis power off", "chassis power status", "chassis power status"},
+		},
+		{
+			name:        "power off timeout",
+			policy:      api.HOST_FENCE_POLICY_POWER_OFF,
+			powerStatus: []string{"on"},
+			wantErr:     true,
+		},
+		{
+			name:        "power cycle",
+			policy:      api.HOST_FENCE_POLICY_POWER_CYCLE,
+			powerStatus: []string{"on"},
+			wantCmds:    []string{"chassis power status", "chassis power cycle"},
+		},
+		{
+			name:        "power cycle of powered off host",
+			policy:      api.HOST_FENCE_POLICY_POWER_CYCLE,
+			powerStatus: []string{"off"},
+			wantCmds:    []string{"chassis power status"},
+		},
+	}
+	for _, c := range cases {
+		executor := &fakeIpmiExecutor{powerStatus: c.powerStatus}
+		newFenceIpmiExecutor = func(info types.SIPMIInfo) ipmitool.IPMIExecutor {
+			return executor
+		}
+		err := fenceByIpmitool(types.SIPMIInfo{}, c.policy, 20*time.Millisecond)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: want error %v got %v", c.name, c.wantErr, err)
+		}
+		if c.wantCmds != nil && strings.Join(executor.commands, ",") != strings.Join(c.wantCmds, ",") {
+			t.Errorf("%s: want commands %v got %v", c.name, c.wantCmds, executor.commands)
+		}
+	}
+}
+
+func TestFenceByRedfish(t *testing.T) {
+	setupFenceTest(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		name        string
+		policy      string
+		unsupported []string
+		powerState  string
+		wantResets  []string
+		wantErr     bool
+	}{
+		{"power off", api.HOST_FENCE_POLICY_POWER_OFF, nil, types.POWER_STATUS_OFF, []string{"ForceOff"}, false},
+		{"power off timeout", api.HOST_FENCE_POLICY_POWER_OFF, nil, types.POWER_STATUS_ON, []string{"ForceOff"}, true},
+		{"power off unsupported", api.HOST_FENCE_POLICY_POWER_OFF, []string{"ForceOff"}, types.POWER_STATUS_ON, []string{"ForceOff"}, true},
+		{"power cycle", api.HOST_FENCE_POLICY_POWER_CYCLE, nil, types.POWER_STATUS_ON, []string{"PowerCycle"}, false},
+		{"power cycle fallback", api.HOST_FENCE_POLICY_POWER_CYCLE, []string{"PowerCycle"}, types.POWER_STATUS_ON, []string{"PowerCycle", "ForceRestart"}, false},
+		{"power cycle unsupported", api.HOST_FENCE_POLICY_POWER_CYCLE, []string{"PowerCycle", "ForceRestart"}, types.POWER_STATUS_ON, []string{"PowerCycle", "ForceRestart"}, true},
+	}
+	for _, c := range cases {
+		drv := &fakeRedfishDriver{unsupported: c.unsupported, powerState: c.powerState}
+		newFenceRedfishDriver = func(ctx context.Context, info types.SIPMIInfo) redfish.IRedfishDriver {
+			return drv
+		}
+		err := fenceByRedfish(ctx, types.SIPMIInfo{}, c.policy, 20*time.Millisecond)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: want error %v got %v", c.name, c.wantErr, err)
+		}
+		if strings.Join(drv.resets, ",") != strings.Join(c.wantResets, ",") {
+			t.Errorf("%s: want resets %v got %v", c.name, c.wantResets, drv.resets)
+		}
+	}
+}
+
+func TestRetryFence(t *testing.T) {
+	setupFenceTest(t)
+	ctx := context.Background()
+
+	// the BMC of the down host accepts the reset after failures
+	drv := &fakeRedfishDriver{unsupported: []string{"ForceOff"}, powerState: types.POWER_STATUS_OFF}
+	newFenceRedfishDriver = func(ctx context.Context, info types.SIPMIInfo) redfish.IRedfishDriver {
+		return drv
+	}
+	tried := 0
+	fence := func() (string, error) {
+		tried++
+		if tried == 3 {
+			drv.unsupported = nil
+		}
+		return api.HOST_FENCE_METHOD_REDFISH, fenceByRedfish(ctx, types.SIPMIInfo{}, api.HOST_FENCE_POLICY_POWER_OFF, time.Second)
+	}
+	method, err := retryFence(2, fence)
+	if err != nil || tried != 3 || method != api.HOST_FENCE_METHOD_REDFISH {
+		t.Errorf("fence succeeds on the last retry, tried %d: %s %v", tried, method, err)
+	}
+
+	// all the retries fail, the fail action is taken then
+	drv.unsupported = []string{"ForceOff"}
+	tried = 0
+	_, err = retryFence(1, func() (string, error) {
+		tried++
+		return api.HOST_FENCE_METHOD_REDFISH, fenceByRedfish(ctx, types.SIPMIInfo{}, api.HOST_FENCE_POLICY_POWER_OFF, time.Second)
+	})
+	if err == nil || tried != 2 {
+		t.Errorf("fence should fail after %d tries: %v", tried, err)
+	}
+
+	// no BMC to retry
+	tried = 0
+	_, err = retryFence(2, func() (string, error) {
+		tried++
+		return "", errors.Wrap(errors.ErrNotFound, "no BMC info of host")
+	})
+	if errors.Cause(err) != errors.ErrNotFound || tried != 1 {
+		t.Errorf("fence without BMC should not be retried, tried %d: %v", tried, err)
+	}
+}
@@ -6098,6 +6098,10 @@ func (host *SHost) OnHostDown(ctx context.Context, userCred mcclient.TokenCreden
 
 	logclient.AddActionLogWithContext(ctx, host, logclient.ACT_OFFLINE, map[string]string{"reason": "host down"}, userCred, false)
 	host.SyncCleanSchedDescCache()
+	if !host.fenceOnHostDown(ctx, userCred) {
+		log.Errorf("host %s fencing failed, skip rescuing guests", hostname)
+		return
+	}
 	host.switchWithBackup(ctx, userCred)
 	host.migrateOnHostDown(ctx, userCred)
 }
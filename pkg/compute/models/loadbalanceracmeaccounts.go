@@ -0,0 +1,166 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/lockman"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	acmeutil "yunion.io/x/onecloud/pkg/util/acme"
+)
+
+// SLoadbalancerAcmeAccountManager manages the ACME accounts used to issue
+// loadbalancer certificates, one account per directory and email, accounts
+// are only accessed internally
+type SLoadbalancerAcmeAccountManager struct {
+	db.SStandaloneAnonResourceBaseManager
+}
+
+var LoadbalancerAcmeAccountManager *SLoadbalancerAcmeAccountManager
+
+func init() {
+	LoadbalancerAcmeAccountManager = &SLoadbalancerAcmeAccountManager{
+		SStandaloneAnonResourceBaseManager: db.NewStandaloneAnonResourceBaseManager(
+			SLoadbalancerAcmeAccount{},
+			"loadbalancer_acme_accounts_tbl",
+			"loadbalancer_acme_account",
+			"loadbalancer_acme_accounts",
+		),
+	}
+	LoadbalancerAcmeAccountManager.SetVirtualObject(LoadbalancerAcmeAccountManager)
+}
+
+type SLoadbalancerAcmeAccount struct {
+	db.SStandaloneAnonResourceBase
+
+	DirectoryUrl string `width:"256" charset:"ascii" nullable:"false" index:"true"`
+	Email        string `width:"128" charset:"utf8" nullable:"false"`
+	AccountUrl   string `width:"256" charset:"ascii" nullable:"true"`
+	// 加密的账号私钥
+	PrivateKey string `charset:"ascii" nullable:"false"`
+}
+
+func newAcmeHttpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{}
+	if len(options.Options.AcmeCaFile) > 0 {
+		pem, err := ioutil.ReadFile(options.Options.AcmeCaFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read acme ca file %s", options.Options.AcmeCaFile)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate found in %s", options.Options.AcmeCaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// GetClient returns an ACME client of the registered account, the account
+// is registered on first use
+func (manager *SLoadbalancerAcmeAccountManager) GetClient(ctx context.Context, directoryUrl, email string) (*acme.Client, error) {
+	lockman.LockRawObject(ctx, manager.Keyword(), directoryUrl+"/"+email)
+	defer lockman.ReleaseRawObject(ctx, manager.Keyword(), directoryUrl+"/"+email)
+
+	httpCli, err := newAcmeHttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	account := &SLoadbalancerAcmeAccount{}
+	account.SetModelManager(manager, account)
+	q := manager.Query().Equals("directory_url", directoryUrl).Equals("email", email)
+	cnt, err := q.CountWithError()
+	if err != nil {
+		return nil, errors.Wrap(err, "CountWithError")
+	}
+	if cnt > 0 {
+		err = q.First(account)
+		if err != nil {
+			return nil, errors.Wrap(err, "First")
+		}
+		keyPem, err := utils.DescryptAESBase64(account.Id, account.PrivateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "decrypt account key")
+		}
+		key, err := acmeutil.ParseAccountKey(keyPem)
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseAccountKey")
+		}
+		cli := acmeutil.NewClient(directoryUrl, key, account.AccountUrl, httpCli)
+		if len(account.AccountUrl) > 0 {
+			return cli, nil
+		}
+		accountUrl, err := acmeutil.Register(ctx, cli, email)
+		if err != nil {
+			return nil, errors.Wrap(err, "Register")
+		}
+		_, err = db.Update(account, func() error {
+			account.AccountUrl = accountUrl
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "update account url")
+		}
+		return cli, nil
+	}
+
+	key, err := acmeutil.GenerateAccountKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "GenerateAccountKey")
+	}
+	cli := acmeutil.NewClient(directoryUrl, key, "", httpCli)
+	account.AccountUrl, err = acmeutil.Register(ctx, cli, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "Register")
+	}
+	keyPem, err := acmeutil.MarshalAccountKey(key)
+	if err != nil {
+		return nil, err
+	}
+	account.Id = db.DefaultUUIDGenerator()
+	account.DirectoryUrl = directoryUrl
+	account.Email = email
+	account.PrivateKey, err = utils.EncryptAESBase64(account.Id, keyPem)
+	if err != nil {
+		return nil, errors.Wrap(err, "EncryptAESBase64")
+	}
+	err = manager.TableSpec().Insert(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "Insert")
+	}
+	return cli, nil
+}
@@ -0,0 +1,549 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/utils"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
+	"yunion.io/x/onecloud/pkg/cloudcommon/validators"
+	"yunion.io/x/onecloud/pkg/compute/options"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	acmeutil "yunion.io/x/onecloud/pkg/util/acme"
+)
+
+const (
+	// http-01 challenges are published through lbagent config push, wait at
+	// most this long for them to be reachable before asking the ACME server
+	acmeHttp01SelfCheckTimeout = 60 * time.Second
+	acmeMaxDomains             = 100
+)
+
+func (lbcert *SLoadbalancerCertificate) IsAcme() bool {
+	return len(lbcert.AcmeDirectoryUrl) > 0
+}
+
+func (lbcert *SLoadbalancerCertificate) GetAcmeDomains() []string {
+	return strings.Fields(lbcert.AcmeDomains)
+}
+
+func (man *SLoadbalancerCertificateManager) validateAcmeInput(ctx context.Context, userCred mcclient.TokenCredential, input *api.LoadbalancerCertificateCreateInput) error {
+	acmeInput := input.Acme
+	if len(acmeInput.DirectoryUrl) == 0 {
+		return httperrors.NewMissingParameterError("acme.directory_url")
+	}
+	u, err := url.Parse(acmeInput.DirectoryUrl)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return httperrors.NewInputParameterError("invalid acme directory url %s", acmeInput.DirectoryUrl)
+	}
+	if len(acmeInput.Email) > 0 && !regutils.MatchEmail(acmeInput.Email) {
+		return httperrors.NewInputParameterError("invalid acme email %s", acmeInput.Email)
+	}
+	if len(acmeInput.ChallengeType) == 0 {
+		acmeInput.ChallengeType = api.LB_CERT_ACME_CHALLENGE_HTTP01
+	}
+	if !api.LB_CERT_ACME_CHALLENGE_TYPES.Has(acmeInput.ChallengeType) {
+		return httperrors.NewInputParameterError("invalid acme challenge type %s, want %s", acmeInput.ChallengeType, api.LB_CERT_ACME_CHALLENGE_TYPES)
+	}
+	if len(acmeInput.KeyType) == 0 {
+		acmeInput.KeyType = api.LB_CERT_ACME_KEY_EC256
+	}
+	if !api.LB_CERT_ACME_KEY_TYPES.Has(acmeInput.KeyType) {
+		return httperrors.NewInputParameterError("invalid acme key type %s, want %s", acmeInput.KeyType, api.LB_CERT_ACME_KEY_TYPES)
+	}
+	if acmeInput.RenewBeforeDays == 0 {
+		acmeInput.RenewBeforeDays = api.LB_CERT_ACME_RENEW_BEFORE_DAYS
+	}
+	if acmeInput.RenewBeforeDays < 1 || acmeInput.RenewBeforeDays > 365 {
+		return httperrors.NewOutOfRangeError("acme renew_before_days should be between 1 and 365")
+	}
+
+	domains := []string{}
+	for _, domain := range acmeInput.Domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if len(domain) == 0 || utils.IsInStringArray(domain, domains) {
+			continue
+		}
+		if !regutils.MatchDomainName(strings.TrimPrefix(domain, "*.")) {
+			return httperrors.NewInputParameterError("invalid domain %s", domain)
+		}
+		if strings.HasPrefix(domain, "*.") && acmeInput.ChallengeType != api.LB_CERT_ACME_CHALLENGE_DNS01 {
+			return httperrors.NewInputParameterError("wildcard domain %s requires %s challenge", domain, api.LB_CERT_ACME_CHALLENGE_DNS01)
+		}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return httperrors.NewMissingParameterError("acme.domains")
+	}
+	if len(domains) > acmeMaxDomains {
+		return httperrors.NewOutOfRangeError("at most %d domains are allowed", acmeMaxDomains)
+	}
+	if acmeInput.ChallengeType == api.LB_CERT_ACME_CHALLENGE_DNS01 {
+		for _, domain := range domains {
+			err := validateAcmeDnsZone(ctx, userCred, domain)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	input.AcmeDirectoryUrl = acmeInput.DirectoryUrl
+	input.AcmeEmail = acmeInput.Email
+	input.AcmeDomains = strings.Join(domains, " ")
+	input.AcmeChallengeType = acmeInput.ChallengeType
+	input.AcmeKeyType = acmeInput.KeyType
+	input.AcmeRenewBeforeDays = acmeInput.RenewBeforeDays
+	return nil
+}
+
+// fetchAcmeDnsZones returns the zones the dns-01 record of the domain can be
+// created in, the most specific zone first
+func fetchAcmeDnsZones(domain string) ([]SDnsZone, error) {
+	labels := strings.Split(strings.TrimPrefix(domain, "*."), ".")
+	names := []string{}
+	for i := 0; i < len(labels)-1; i++ {
+		names = append(names, strings.Join(labels[i:], "."))
+	}
+	q := DnsZoneManager.Query().In("name", names).IsTrue("enabled")
+	zones := []SDnsZone{}
+	err := db.FetchModelObjects(DnsZoneManager, q, &zones)
+	if err != nil {
+		return nil, errors.Wrap(err, "FetchModelObjects")
+	}
+	sort.SliceStable(zones, func(i, j int) bool {
+		return len(zones[i].Name) > len(zones[j].Name)
+	})
+	return zones, nil
+}
+
+// validateAcmeDnsZone makes sure the user owns a zone of the domain, so that
+// dns-01 records are never created in zones of others
+func validateAcmeDnsZone(ctx context.Context, userCred mcclient.TokenCredential, domain string) error {
+	zones, err := fetchAcmeDnsZones(domain)
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	if len(zones) > 0 {
+		zoneId := zones[0].Id
+		_, err := validators.ValidateModel(ctx, userCred, DnsZoneManager, &zoneId)
+		if err != nil {
+			return err
+		}
+		return nil
+	}
+	return httperrors.NewResourceNotFoundError("no dns zone found for domain %s", domain)
+}
+
+func (lbcert *SLoadbalancerCertificate) StartAcmeIssueTask(ctx context.Context, userCred mcclient.TokenCredential, parentTaskId string) error {
+	task, err := taskman.TaskManager.NewTask(ctx, "LoadbalancerCertificateAcmeIssueTask", lbcert, userCred, nil, parentTaskId, "", nil)
+	if err != nil {
+		return errors.Wrapf(err, "NewTask")
+	}
+	lbcert.SetStatus(ctx, userCred, api.LB_CERT_STATUS_ISSUING, "")
+	_, err = db.Update(lbcert, func() error {
+		lbcert.AcmeLastAttemptAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update acme last attempt")
+	}
+	return task.ScheduleRun(nil)
+}
+
+// 重新签发ACME证书
+func (lbcert *SLoadbalancerCertificate) PerformAcmeRenew(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.LoadbalancerCertificateAcmeRenewInput) (jsonutils.JSONObject, error) {
+	if !lbcert.IsAcme() {
+		return nil, httperrors.NewUnsupportOperationError("certificate %s is not issued by acme", lbcert.Name)
+	}
+	if lbcert.Status != apis.STATUS_AVAILABLE && lbcert.Status != api.LB_CERT_STATUS_ISSUE_FAILED {
+		return nil, httperrors.NewInvalidStatusError("can not renew certificate in status %s", lbcert.Status)
+	}
+	return nil, lbcert.StartAcmeIssueTask(ctx, userCred, "")
+}
+
+func (lbcert *SLoadbalancerCertificate) needAcmeRenew(now time.Time) bool {
+	if lbcert.Status == api.LB_CERT_STATUS_ISSUE_FAILED {
+		retry := time.Duration(options.Options.AcmeRetryIntervalHours) * time.Hour
+		if now.Sub(lbcert.AcmeLastAttemptAt) < retry {
+			return false
+		}
+	}
+	if len(lbcert.Certificate) == 0 {
+		return true
+	}
+	days := lbcert.AcmeRenewBeforeDays
+	if days <= 0 {
+		days = api.LB_CERT_ACME_RENEW_BEFORE_DAYS
+	}
+	return now.Add(time.Duration(days) * 24 * time.Hour).After(lbcert.NotAfter)
+}
+
+// AutoRenewAcmeCertificates renews the ACME certificates about to expire,
+// failed renewals are retried after AcmeRetryIntervalHours
+func (man *SLoadbalancerCertificateManager) AutoRenewAcmeCertificates(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := man.Query().IsNotEmpty("acme_directory_url").In("status", []string{apis.STATUS_AVAILABLE, api.LB_CERT_STATUS_ISSUE_FAILED})
+	certs := []SLoadbalancerCertificate{}
+	err := db.FetchModelObjects(man, q, &certs)
+	if err != nil {
+		log.Errorf("fetch acme certificates error: %v", err)
+		return
+	}
+	now := time.Now()
+	for i := range certs {
+		lbcert := &certs[i]
+		if !lbcert.needAcmeRenew(now) {
+			continue
+		}
+		err := lbcert.StartAcmeIssueTask(ctx, userCred, "")
+		if err != nil {
+			log.Errorf("start renewing acme certificate %s error: %v", lbcert.Name, err)
+		}
+	}
+}
+
+type sAcmePendingChallenge struct {
+	authzUrl  string
+	challenge *acme.Challenge
+}
+
+// IssueAcmeCertificate orders a certificate from the ACME server and stores
+// it on success, lbagent picks it up with the next config push
+func (lbcert *SLoadbalancerCertificate) IssueAcmeCertificate(ctx context.Context, userCred mcclient.TokenCredential) error {
+	timeout := time.Duration(options.Options.AcmeChallengeTimeoutSeconds) * time.Second
+	issueCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cli, err := LoadbalancerAcmeAccountManager.GetClient(issueCtx, lbcert.AcmeDirectoryUrl, lbcert.AcmeEmail)
+	if err != nil {
+		return errors.Wrap(err, "get acme client")
+	}
+	domains := lbcert.GetAcmeDomains()
+	order, err := cli.AuthorizeOrder(issueCtx, acme.DomainIDs(domains...))
+	if err != nil {
+		return errors.Wrap(err, "AuthorizeOrder")
+	}
+
+	solver := &sAcmeSolver{lbcert: lbcert}
+	defer solver.cleanup(ctx, userCred)
+
+	pending := []sAcmePendingChallenge{}
+	for _, authzUrl := range order.AuthzURLs {
+		authz, err := cli.GetAuthorization(issueCtx, authzUrl)
+		if err != nil {
+			return errors.Wrap(err, "GetAuthorization")
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		chal := findAcmeChallenge(authz, lbcert.AcmeChallengeType)
+		if chal == nil {
+			return errors.Errorf("no %s challenge offered for %s", lbcert.AcmeChallengeType, authz.Identifier.Value)
+		}
+		err = solver.present(issueCtx, userCred, cli, authz.Identifier.Value, chal.Token)
+		if err != nil {
+			return errors.Wrapf(err, "present %s challenge of %s", lbcert.AcmeChallengeType, authz.Identifier.Value)
+		}
+		pending = append(pending, sAcmePendingChallenge{authzUrl: authzUrl, challenge: chal})
+	}
+	err = solver.wait(issueCtx)
+	if err != nil {
+		return err
+	}
+	for i := range pending {
+		_, err := cli.Accept(issueCtx, pending[i].challenge)
+		if err != nil {
+			return errors.Wrap(err, "Accept")
+		}
+	}
+	for i := range pending {
+		_, err := cli.WaitAuthorization(issueCtx, pending[i].authzUrl)
+		if err != nil {
+			return errors.Wrap(err, "WaitAuthorization")
+		}
+	}
+	order, err = cli.WaitOrder(issueCtx, order.URI)
+	if err != nil {
+		return errors.Wrap(err, "WaitOrder")
+	}
+
+	key, keyPem, err := generateAcmeCertKey(lbcert.AcmeKeyType)
+	if err != nil {
+		return err
+	}
+	csr, err := acmeutil.NewCSR(key, domains)
+	if err != nil {
+		return errors.Wrap(err, "NewCSR")
+	}
+	der, _, err := cli.CreateOrderCert(issueCtx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return errors.Wrap(err, "CreateOrderCert")
+	}
+	chain, err := acmeutil.EncodeCertificateChain(der)
+	if err != nil {
+		return err
+	}
+	return lbcert.setAcmeCertificate(ctx, userCred, string(chain), keyPem)
+}
+
+// findAcmeChallenge returns the challenge of the given type, or nil
+func findAcmeChallenge(authz *acme.Authorization, typ string) *acme.Challenge {
+	for _, chal := range authz.Challenges {
+		if chal.Type == typ {
+			return chal
+		}
+	}
+	return nil
+}
+
+func (lbcert *SLoadbalancerCertificate) setAcmeCertificate(ctx context.Context, userCred mcclient.TokenCredential, cert, pkey string) error {
+	info, err := parseLoadbalancerCertificate(cert, pkey)
+	if err != nil {
+		return errors.Wrap(err, "parse issued certificate")
+	}
+	diff, err := db.Update(lbcert, func() error {
+		lbcert.SCertificateResourceBase = *info
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "update certificate")
+	}
+	db.OpsLog.LogEvent(lbcert, db.ACT_UPDATE, diff, userCred)
+	return nil
+}
+
+func generateAcmeCertKey(keyType string) (crypto.Signer, string, error) {
+	switch keyType {
+	case api.LB_CERT_ACME_KEY_RSA2048:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "generate rsa key")
+		}
+		keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		return key, string(keyPem), nil
+	default:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "generate ecdsa key")
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "MarshalECPrivateKey")
+		}
+		keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		return key, string(keyPem), nil
+	}
+}
+
+// sAcmeSolver publishes the challenge responses, http-01 responses are
+// served by lbagent and dns-01 responses are TXT records of dns zones
+type sAcmeSolver struct {
+	lbcert *SLoadbalancerCertificate
+
+	challenges api.LoadbalancerAcmeChallenges
+	records    []*SDnsRecord
+	waitDns    bool
+}
+
+func (solver *sAcmeSolver) present(ctx context.Context, userCred mcclient.TokenCredential, cli *acme.Client, domain, token string) error {
+	if solver.lbcert.AcmeChallengeType == api.LB_CERT_ACME_CHALLENGE_DNS01 {
+		value, err := cli.DNS01ChallengeRecord(token)
+		if err != nil {
+			return errors.Wrap(err, "DNS01ChallengeRecord")
+		}
+		return solver.createDnsRecord(ctx, userCred, domain, value)
+	}
+	keyAuth, err := cli.HTTP01ChallengeResponse(token)
+	if err != nil {
+		return errors.Wrap(err, "HTTP01ChallengeResponse")
+	}
+	solver.challenges = append(solver.challenges, api.LoadbalancerAcmeChallenge{
+		Domain:           domain,
+		Token:            token,
+		KeyAuthorization: keyAuth,
+	})
+	return nil
+}
+
+func (solver *sAcmeSolver) createDnsRecord(ctx context.Context, userCred mcclient.TokenCredential, domain, value string) error {
+	zones, err := fetchAcmeDnsZones(domain)
+	if err != nil {
+		return err
+	}
+	if len(zones) == 0 {
+		return errors.Wrapf(errors.ErrNotFound, "dns zone of %s", domain)
+	}
+	zone := &zones[0]
+	record := &SDnsRecord{}
+	record.SetModelManager(DnsRecordManager, record)
+	record.DnsZoneId = zone.Id
+	record.Name = strings.TrimSuffix(strings.TrimSuffix(acmeutil.DNS01RecordName(domain), zone.Name), ".")
+	record.DnsType = string(cloudprovider.DnsTypeTXT)
+	record.DnsValue = value
+	record.TTL = 60
+	record.PolicyType = string(cloudprovider.DnsPolicyTypeSimple)
+	record.Enabled = tristate.True
+	record.Status = api.DNS_RECORDSET_STATUS_CREATING
+	record.Description = fmt.Sprintf("acme challenge of loadbalancer certificate %s", solver.lbcert.Name)
+	err = DnsRecordManager.TableSpec().Insert(ctx, record)
+	if err != nil {
+		return errors.Wrap(err, "insert dns record")
+	}
+	solver.records = append(solver.records, record)
+	if len(zone.ManagerId) > 0 {
+		solver.waitDns = true
+	}
+	return record.StartCreateTask(ctx, userCred, "")
+}
+
+// wait blocks until the challenge responses are published
+func (solver *sAcmeSolver) wait(ctx context.Context) error {
+	if len(solver.challenges) > 0 {
+		lbcert := solver.lbcert
+		_, err := db.Update(lbcert, func() error {
+			lbcert.AcmeChallenges = solver.challenges
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "publish http-01 challenges")
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, acmeHttp01SelfCheckTimeout)
+		defer cancel()
+		for _, chal := range solver.challenges {
+			err := waitAcmeHttp01(checkCtx, chal)
+			if err != nil {
+				// the domain may not resolve to the loadbalancer from the region,
+				// leave it to the ACME server
+				log.Warningf("self check http-01 challenge of %s: %v", chal.Domain, err)
+			}
+		}
+	}
+	for _, record := range solver.records {
+		err := waitAcmeDnsRecord(ctx, record.Id)
+		if err != nil {
+			return err
+		}
+	}
+	if solver.waitDns {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(options.Options.AcmeDnsPropagationSeconds) * time.Second):
+		}
+	}
+	return nil
+}
+
+func (solver *sAcmeSolver) cleanup(ctx context.Context, userCred mcclient.TokenCredential) {
+	if len(solver.challenges) > 0 {
+		lbcert := solver.lbcert
+		_, err := db.Update(lbcert, func() error {
+			lbcert.AcmeChallenges = nil
+			return nil
+		})
+		if err != nil {
+			log.Errorf("clear http-01 challenges of %s: %v", lbcert.Name, err)
+		}
+	}
+	for _, record := range solver.records {
+		err := record.StartDeleteTask(ctx, userCred, "")
+		if err != nil {
+			log.Errorf("delete dns-01 record %s: %v", record.Name, err)
+		}
+	}
+}
+
+func waitAcmeHttp01(ctx context.Context, chal api.LoadbalancerAcmeChallenge) error {
+	cli := &http.Client{Timeout: 5 * time.Second}
+	u := "http://" + chal.Domain + acmeutil.HTTP01ChallengePath + chal.Token
+	for {
+		err := func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := cli.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(string(body)) != chal.KeyAuthorization {
+				return errors.Errorf("%s: unexpected response status %d", u, resp.StatusCode)
+			}
+			return nil
+		}()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func waitAcmeDnsRecord(ctx context.Context, recordId string) error {
+	for {
+		obj, err := DnsRecordManager.FetchById(recordId)
+		if err != nil {
+			return errors.Wrapf(err, "fetch dns record %s", recordId)
+		}
+		record := obj.(*SDnsRecord)
+		switch record.Status {
+		case api.DNS_RECORDSET_STATUS_AVAILABLE:
+			return nil
+		case api.DNS_ZONE_STATUS_CREATE_FAILE:
+			return errors.Errorf("create dns-01 record %s failed", record.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "wait dns-01 record %s", record.Name)
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/compute/options"
+)
+
+func TestLoadbalancerCertificateNeedAcmeRenew(t *testing.T) {
+	options.Options.AcmeRetryIntervalHours = 6
+	now := time.Now()
+	day := 24 * time.Hour
+	cases := []struct {
+		name        string
+		status      string
+		certificate string
+		notAfter    time.Time
+		renewBefore int
+		lastAttempt time.Time
+		want        bool
+	}{
+		{"not issued", api.LB_CERT_STATUS_ISSUE_FAILED, "", time.Time{}, 30, now.Add(-7 * time.Hour), true},
+		{"failed recently", api.LB_CERT_STATUS_ISSUE_FAILED, "", time.Time{}, 30, now.Add(-time.Hour), false},
+		{"valid", apis.STATUS_AVAILABLE, "cert", now.Add(60 * day), 30, now.Add(-30 * day), false},
+		{"about to expire", apis.STATUS_AVAILABLE, "cert", now.Add(20 * day), 30, now.Add(-70 * day), true},
+		{"default renew before days", apis.STATUS_AVAILABLE, "cert", now.Add(29 * day), 0, now.Add(-61 * day), true},
+		{"renew failed recently", api.LB_CERT_STATUS_ISSUE_FAILED, "cert", now.Add(20 * day), 30, now.Add(-time.Hour), false},
+	}
+	for _, c := range cases {
+		lbcert := &SLoadbalancerCertificate{}
+		lbcert.Status = c.status
+		lbcert.Certificate = c.certificate
+		lbcert.NotAfter = c.notAfter
+		lbcert.AcmeRenewBeforeDays = c.renewBefore
+		lbcert.AcmeLastAttemptAt = c.lastAttempt
+		if got := lbcert.needAcmeRenew(now); got != c.want {
+			t.Errorf("%s: want %v, got %v", c.name, c.want, got)
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"strings"
+	"time"
 
 	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
@@ -75,6 +76,19 @@ type SLoadbalancerCertificate struct {
 	SCloudregionResourceBase
 
 	db.SCertificateResourceBase
+
+	// ACME服务目录地址, 非空时证书由ACME自动签发和续期
+	AcmeDirectoryUrl string `width:"256" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	AcmeEmail        string `width:"128" charset:"utf8" nullable:"true" list:"user" create:"optional"`
+	// 空格分隔的证书域名
+	AcmeDomains         string `width:"1024" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	AcmeChallengeType   string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	AcmeKeyType         string `width:"16" charset:"ascii" nullable:"true" list:"user" create:"optional"`
+	AcmeRenewBeforeDays int    `nullable:"false" default:"0" list:"user" create:"optional"`
+	// 等待lbagent响应的http-01验证
+	AcmeChallenges api.LoadbalancerAcmeChallenges `length:"long" charset:"utf8" nullable:"true" list:"user"`
+	// 最近一次签发的时间
+	AcmeLastAttemptAt time.Time `nullable:"true" list:"user"`
 }
 
 func (lbcert *SLoadbalancerCertificate) ValidateUpdateData(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input *api.LoadbalancerCertificateUpdateInput) (*api.LoadbalancerCertificateUpdateInput, error) {
@@ -356,33 +370,33 @@ func (man *SLoadbalancerCertificateManager) ValidateCreateData(
 	query jsonutils.JSONObject,
 	input *api.LoadbalancerCertificateCreateInput,
 ) (*api.LoadbalancerCertificateCreateInput, error) {
-	if len(input.Certificate) == 0 {
-		return nil, httperrors.NewMissingParameterError("certificate")
-	}
-	if len(input.PrivateKey) == 0 {
-		return nil, httperrors.NewMissingParameterError("private_key")
-	}
-	_, err := tls.X509KeyPair([]byte(input.Certificate), []byte(input.PrivateKey))
-	if err != nil {
-		return nil, err
-	}
-	p, _ := pem.Decode([]byte(input.Certificate))
-	c, err := x509.ParseCertificate(p.Bytes)
-	if err != nil {
-		return nil, err
-	}
-	input.SubjectAlternativeNames = strings.Join(c.DNSNames, " ")
-	input.SignatureAlgorithm = c.SignatureAlgorithm.String()
-	d := sha256.Sum256(c.Raw)
-	input.Fingerprint = api.LB_TLS_CERT_FINGERPRINT_ALGO_SHA256 + ":" + hex.EncodeToString(d[:])
-	input.CommonName = c.Subject.CommonName
-	input.NotBefore = c.NotBefore
-	input.NotAfter = c.NotAfter
-	switch pub := c.PublicKey.(type) {
-	case *rsa.PublicKey:
-		input.PublicKeyBitLen = pub.N.BitLen()
-	case *ecdsa.PublicKey:
-		input.PublicKeyBitLen = pub.X.BitLen()
+	var err error
+	if input.Acme != nil {
+		if len(input.Certificate) > 0 || len(input.PrivateKey) > 0 {
+			return nil, httperrors.NewConflictError("certificate and private_key are issued by acme")
+		}
+		err = man.validateAcmeInput(ctx, userCred, input)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if len(input.Certificate) == 0 {
+			return nil, httperrors.NewMissingParameterError("certificate")
+		}
+		if len(input.PrivateKey) == 0 {
+			return nil, httperrors.NewMissingParameterError("private_key")
+		}
+		info, err := parseLoadbalancerCertificate(input.Certificate, input.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		input.SubjectAlternativeNames = info.SubjectAlternativeNames
+		input.SignatureAlgorithm = info.SignatureAlgorithm
+		input.Fingerprint = info.Fingerprint
+		input.CommonName = info.CommonName
+		input.NotBefore = info.NotBefore
+		input.NotAfter = info.NotAfter
+		input.PublicKeyBitLen = info.PublicKeyBitLen
 	}
 	input.SharableVirtualResourceCreateInput, err = man.SSharableVirtualResourceBaseManager.ValidateCreateData(ctx, userCred, ownerId, query, input.SharableVirtualResourceCreateInput)
 	if err != nil {
@@ -409,12 +423,54 @@ func (man *SLoadbalancerCertificateManager) ValidateCreateData(
 			return nil, httperrors.NewConflictError("conflict region %s and cloudprovider %s", region.Name, provider.Name)
 		}
 	}
+	if input.Acme != nil {
+		if input.CloudregionId != api.DEFAULT_REGION_ID || len(input.ManagerId) > 0 {
+			return nil, httperrors.NewNotSupportedError("acme is only supported by local certificates")
+		}
+		input.Status = api.LB_CERT_STATUS_ISSUING
+	}
 
 	return input, nil
 }
 
+// parseLoadbalancerCertificate checks the certificate matches the private key
+// and returns the derived attributes
+func parseLoadbalancerCertificate(cert, pkey string) (*db.SCertificateResourceBase, error) {
+	_, err := tls.X509KeyPair([]byte(cert), []byte(pkey))
+	if err != nil {
+		return nil, err
+	}
+	p, _ := pem.Decode([]byte(cert))
+	c, err := x509.ParseCertificate(p.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	info := &db.SCertificateResourceBase{
+		Certificate: cert,
+		PrivateKey:  pkey,
+	}
+	info.SubjectAlternativeNames = strings.Join(c.DNSNames, " ")
+	info.SignatureAlgorithm = c.SignatureAlgorithm.String()
+	d := sha256.Sum256(c.Raw)
+	info.Fingerprint = api.LB_TLS_CERT_FINGERPRINT_ALGO_SHA256 + ":" + hex.EncodeToString(d[:])
+	info.CommonName = c.Subject.CommonName
+	info.NotBefore = c.NotBefore
+	info.NotAfter = c.NotAfter
+	switch pub := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.PublicKeyBitLen = pub.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.PublicKeyBitLen = pub.X.BitLen()
+	}
+	return info, nil
+}
+
 func (self *SLoadbalancerCertificate) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	self.SSharableVirtualResourceBase.PostCreate(ctx, userCred, ownerId, query, data)
+	if self.IsAcme() {
+		self.StartAcmeIssueTask(ctx, userCred, "")
+		return
+	}
 	self.StartCreateTask(ctx, userCred, "")
 }
 
@@ -360,10 +360,13 @@ func (man *SLoadbalancerListenerManager) ValidateCreateData(ctx context.Context,
 		if len(input.CertificateId) == 0 {
 			return nil, httperrors.NewMissingParameterError("certificate_id")
 		}
-		_, err := validators.ValidateModel(ctx, userCred, LoadbalancerCertificateManager, &input.CertificateId)
+		certObj, err := validators.ValidateModel(ctx, userCred, LoadbalancerCertificateManager, &input.CertificateId)
 		if err != nil {
 			return nil, err
 		}
+		if cert := certObj.(*SLoadbalancerCertificate); cert.IsAcme() && len(cert.Certificate) == 0 {
+			return nil, httperrors.NewResourceNotReadyError("certificate %s is not issued yet", cert.Name)
+		}
 	}
 	input, err = region.GetDriver().ValidateCreateLoadbalancerListenerData(ctx, userCred, ownerId, input, lb, lbbg)
 	if err != nil {
@@ -449,10 +452,13 @@ func (lblis *SLoadbalancerListener) ValidateUpdateData(ctx context.Context, user
 		}
 	}
 	if lblis.ListenerType == api.LB_LISTENER_TYPE_HTTPS && input.CertificateId != nil && len(*input.CertificateId) > 0 {
-		_, err = validators.ValidateModel(ctx, userCred, LoadbalancerCertificateManager, input.CertificateId)
+		certObj, err := validators.ValidateModel(ctx, userCred, LoadbalancerCertificateManager, input.CertificateId)
 		if err != nil {
 			return nil, err
 		}
+		if cert := certObj.(*SLoadbalancerCertificate); cert.IsAcme() && len(cert.Certificate) == 0 {
+			return nil, httperrors.NewResourceNotReadyError("certificate %s is not issued yet", cert.Name)
+		}
 	}
 	input.StatusStandaloneResourceBaseUpdateInput, err = lblis.SStatusStandaloneResourceBase.ValidateUpdateData(ctx, userCred, query, input.StatusStandaloneResourceBaseUpdateInput)
 	if err != nil {
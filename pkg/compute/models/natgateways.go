@@ -748,6 +748,15 @@ func (self *SNatGateway) GetVpc() (*SVpc, error) {
 	return vpc.(*SVpc), nil
 }
 
+// IsOvnNatGateway 本地 OneCloud VPC 的 NAT 网关, 其 SNAT/DNAT 规则由 vpcagent 下发到 OVN
+func (self *SNatGateway) IsOvnNatGateway() bool {
+	vpc, err := self.GetVpc()
+	if err != nil {
+		return false
+	}
+	return vpc.Id != api.DEFAULT_VPC_ID && len(vpc.ManagerId) == 0
+}
+
 func (self *SNatGateway) GetINatGateway(ctx context.Context) (cloudprovider.ICloudNatGateway, error) {
 	vpc, err := self.GetVpc()
 	if err != nil {
@@ -17,12 +17,15 @@ package models
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"sort"
 	"strings"
 
 	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/util/netutils"
+	"yunion.io/x/pkg/util/secrules"
 	"yunion.io/x/sqlchemy"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
@@ -30,6 +33,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/logclient"
 	"yunion.io/x/onecloud/pkg/util/stringutils2"
 )
 
@@ -63,6 +67,9 @@ type SVpcPeeringConnection struct {
 	PeerVpcId        string `width:"36" charset:"ascii" nullable:"true" list:"domain" create:"required" json:"peer_vpc_id"`
 	PeerAccountId    string `width:"36" charset:"ascii" nullable:"true" list:"domain"`
 	Bandwidth        int    `nullable:"false" default:"0" list:"user" create:"optional"`
+
+	// 本地 OVN vpc 对等连接两端逻辑路由器的互联网段(/30)起始地址
+	OvnInterIpAddr string `width:"16" charset:"ascii" nullable:"true" list:"domain"`
 }
 
 func (manager *SVpcPeeringConnectionManager) GetContextManagers() [][]db.IModelManager {
@@ -143,8 +150,18 @@ func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 	}
 	peerVpc := _peerVpc.(*SVpc)
 
+	if len(vpc.ManagerId) == 0 && len(peerVpc.ManagerId) == 0 {
+		err := manager.validateOvnVpcPeering(vpc, peerVpc)
+		if err != nil {
+			return input, err
+		}
+		input.VpcId = vpc.Id
+		input.PeerVpcId = peerVpc.Id
+		input.Bandwidth = 0
+		return input, nil
+	}
 	if len(vpc.ManagerId) == 0 || len(peerVpc.ManagerId) == 0 {
-		return input, httperrors.NewInputParameterError("Only public cloud support vpcpeering")
+		return input, httperrors.NewNotSupportedError("peering between onecloud vpc and public cloud vpc is not supported")
 	}
 
 	// get account,providerFactory
@@ -161,31 +178,9 @@ func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 
 	// check vpc ip range overlap
 	if !factory.IsSupportVpcPeeringVpcCidrOverlap() {
-		vpcIpv4Ranges := []netutils.IPV4AddrRange{}
-		peervpcIpv4Ranges := []netutils.IPV4AddrRange{}
-		vpcCidrBlocks := strings.Split(vpc.CidrBlock, ",")
-		peervpcCidrBlocks := strings.Split(peerVpc.CidrBlock, ",")
-		for i := range vpcCidrBlocks {
-			vpcIpv4Range, err := netutils.NewIPV4Prefix(vpcCidrBlocks[i])
-			if err != nil {
-				return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", vpcCidrBlocks[i]))
-			}
-			vpcIpv4Ranges = append(vpcIpv4Ranges, vpcIpv4Range.ToIPRange())
-		}
-
-		for i := range peervpcCidrBlocks {
-			peervpcIpv4Range, err := netutils.NewIPV4Prefix(peervpcCidrBlocks[i])
-			if err != nil {
-				return input, httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", peervpcCidrBlocks[i]))
-			}
-			peervpcIpv4Ranges = append(peervpcIpv4Ranges, peervpcIpv4Range.ToIPRange())
-		}
-		for i := range vpcIpv4Ranges {
-			for j := range peervpcIpv4Ranges {
-				if vpcIpv4Ranges[i].IsOverlap(peervpcIpv4Ranges[j]) {
-					return input, httperrors.NewNotSupportedError("ipv4 range overlap")
-				}
-			}
+		err := checkVpcPeeringCidrOverlap(vpc, peerVpc)
+		if err != nil {
+			return input, err
 		}
 	}
 
@@ -218,6 +213,189 @@ func (manager *SVpcPeeringConnectionManager) ValidateCreateData(
 	return input, nil
 }
 
+func checkVpcPeeringCidrOverlap(vpc, peerVpc *SVpc) error {
+	vpcIpv4Ranges := []netutils.IPV4AddrRange{}
+	peervpcIpv4Ranges := []netutils.IPV4AddrRange{}
+	vpcCidrBlocks := strings.Split(vpc.CidrBlock, ",")
+	peervpcCidrBlocks := strings.Split(peerVpc.CidrBlock, ",")
+	for i := range vpcCidrBlocks {
+		vpcIpv4Range, err := netutils.NewIPV4Prefix(vpcCidrBlocks[i])
+		if err != nil {
+			return httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", vpcCidrBlocks[i]))
+		}
+		vpcIpv4Ranges = append(vpcIpv4Ranges, vpcIpv4Range.ToIPRange())
+	}
+
+	for i := range peervpcCidrBlocks {
+		peervpcIpv4Range, err := netutils.NewIPV4Prefix(peervpcCidrBlocks[i])
+		if err != nil {
+			return httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", peervpcCidrBlocks[i]))
+		}
+		peervpcIpv4Ranges = append(peervpcIpv4Ranges, peervpcIpv4Range.ToIPRange())
+	}
+	for i := range vpcIpv4Ranges {
+		for j := range peervpcIpv4Ranges {
+			if vpcIpv4Ranges[i].IsOverlap(peervpcIpv4Ranges[j]) {
+				return httperrors.NewNotSupportedError("ipv4 range overlap")
+			}
+		}
+	}
+	return nil
+}
+
+// 本地 OVN vpc 之间的对等连接由 vpcagent 以逻辑路由器互联及静态路由实现
+func (manager *SVpcPeeringConnectionManager) validateOvnVpcPeering(vpc, peerVpc *SVpc) error {
+	// 两端逻辑路由器只能有一条互联, 反向的对等连接同样视为已存在
+	q := manager.Query()
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.AND(
+			sqlchemy.Equals(q.Field("vpc_id"), vpc.Id),
+			sqlchemy.Equals(q.Field("peer_vpc_id"), peerVpc.Id),
+		),
+		sqlchemy.AND(
+			sqlchemy.Equals(q.Field("vpc_id"), peerVpc.Id),
+			sqlchemy.Equals(q.Field("peer_vpc_id"), vpc.Id),
+		),
+	))
+	peerings := []SVpcPeeringConnection{}
+	err := db.FetchModelObjects(manager, q, &peerings)
+	if err != nil {
+		return httperrors.NewGeneralError(err)
+	}
+	err = checkOvnVpcPeering(vpc, peerVpc, peerings)
+	if err != nil {
+		return err
+	}
+
+	// 对端流量到达虚机时由其安全组规则(OVN ACL)按网段放行
+	for _, pair := range [][2]*SVpc{{vpc, peerVpc}, {peerVpc, vpc}} {
+		secgroups, err := pair[0].getOvnPeeringSecgroups()
+		if err != nil {
+			return err
+		}
+		err = checkOvnPeeringSecgroups(pair[0], pair[1], secgroups)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOvnVpcPeering 检查两端 vpc 能否建立对等连接, peerings 为两端之间已有的对等连接
+func checkOvnVpcPeering(vpc, peerVpc *SVpc, peerings []SVpcPeeringConnection) error {
+	if vpc.Id == peerVpc.Id {
+		return httperrors.NewInputParameterError("vpc %s can not peer with itself", vpc.Name)
+	}
+	if vpc.Id == api.DEFAULT_VPC_ID || peerVpc.Id == api.DEFAULT_VPC_ID {
+		return httperrors.NewNotSupportedError("default vpc does not support vpc peering")
+	}
+	if vpc.CloudregionId != peerVpc.CloudregionId {
+		return httperrors.NewNotSupportedError("onecloud vpc peering across regions is not supported")
+	}
+	// 路由按网段转发, 两端地址不能重叠
+	err := checkVpcPeeringCidrOverlap(vpc, peerVpc)
+	if err != nil {
+		return err
+	}
+	for i := range peerings {
+		if (peerings[i].VpcId == vpc.Id && peerings[i].PeerVpcId == peerVpc.Id) ||
+			(peerings[i].VpcId == peerVpc.Id && peerings[i].PeerVpcId == vpc.Id) {
+			return httperrors.NewNotSupportedError("vpc %s and vpc %s have already connected", vpc.Name, peerVpc.Name)
+		}
+	}
+	return nil
+}
+
+// 获取 vpc 内虚机使用的安全组及其规则
+func (self *SVpc) getOvnPeeringSecgroups() (map[*SSecurityGroup][]SSecurityGroupRule, error) {
+	wireQ := WireManager.Query("id").Equals("vpc_id", self.Id).SubQuery()
+	networkQ := NetworkManager.Query("id").In("wire_id", wireQ).SubQuery()
+	guestQ := GuestnetworkManager.Query("guest_id").In("network_id", networkQ).SubQuery()
+	secgrpQ := GuestManager.Query("secgrp_id").In("id", guestQ).SubQuery()
+	guestsecgrpQ := GuestsecgroupManager.Query("secgroup_id").In("guest_id", guestQ).SubQuery()
+
+	q := SecurityGroupManager.Query()
+	q = q.Filter(sqlchemy.OR(
+		sqlchemy.In(q.Field("id"), secgrpQ),
+		sqlchemy.In(q.Field("id"), guestsecgrpQ),
+	))
+	secgroups := []SSecurityGroup{}
+	err := db.FetchModelObjects(SecurityGroupManager, q, &secgroups)
+	if err != nil {
+		return nil, httperrors.NewGeneralError(errors.Wrapf(err, "fetch security groups of vpc %s", self.Name))
+	}
+	ret := map[*SSecurityGroup][]SSecurityGroupRule{}
+	for i := range secgroups {
+		rules, err := secgroups[i].GetSecurityRules()
+		if err != nil {
+			return nil, httperrors.NewGeneralError(errors.Wrapf(err, "fetch rules of security group %s", secgroups[i].Name))
+		}
+		ret[&secgroups[i]] = rules
+	}
+	return ret, nil
+}
+
+// checkOvnPeeringSecgroups 要求 vpc 内虚机所用安全组均由 vpcagent 下发, 且其入方向规则放行对端 vpc 的网段
+func checkOvnPeeringSecgroups(vpc, peerVpc *SVpc, secgroups map[*SSecurityGroup][]SSecurityGroupRule) error {
+	peerRanges := []netutils.IPV4AddrRange{}
+	for _, cidr := range strings.Split(peerVpc.CidrBlock, ",") {
+		prefix, err := netutils.NewIPV4Prefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return httperrors.NewGeneralError(errors.Wrapf(err, "convert vpc cidr %s to ipv4range error", cidr))
+		}
+		peerRanges = append(peerRanges, prefix.ToIPRange())
+	}
+	denied := []string{}
+	for secgroup, rules := range secgroups {
+		if len(secgroup.ManagerId) > 0 {
+			return httperrors.NewNotSupportedError("security group %s of vpc %s is not a onecloud security group", secgroup.Name, vpc.Name)
+		}
+		if len(secgroup.VpcId) > 0 && secgroup.VpcId != vpc.Id {
+			return httperrors.NewNotSupportedError("security group %s used in vpc %s belongs to another vpc", secgroup.Name, vpc.Name)
+		}
+		for _, peerRange := range peerRanges {
+			if !secgroupRulesAllowRange(rules, peerRange) {
+				denied = append(denied, fmt.Sprintf("%s(%s)", secgroup.Name, peerRange.String()))
+			}
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return httperrors.NewNotSupportedError("security groups of vpc %s do not allow ingress from peer vpc %s: %s",
+			vpc.Name, peerVpc.Name, strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// secgroupRulesAllowRange 按优先级从高到低匹配覆盖整个地址段的入方向规则;
+// 仅针对部分协议的拒绝规则不会阻断全部流量, 继续匹配低优先级的规则
+func secgroupRulesAllowRange(rules []SSecurityGroupRule, ipRange netutils.IPV4AddrRange) bool {
+	rules = append([]SSecurityGroupRule{}, rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Direction != string(secrules.SecurityRuleIngress) {
+			continue
+		}
+		if cidr := strings.TrimSpace(rule.CIDR); len(cidr) > 0 {
+			if !strings.Contains(cidr, "/") {
+				cidr += "/32"
+			}
+			prefix, err := netutils.NewIPV4Prefix(cidr)
+			if err != nil || !prefix.ToIPRange().ContainsRange(ipRange) {
+				continue
+			}
+		}
+		if rule.Action == string(secrules.SecurityRuleAllow) {
+			return true
+		}
+		if rule.Protocol == secrules.PROTO_ANY || len(rule.Protocol) == 0 {
+			return false
+		}
+	}
+	return false
+}
+
 func (self *SVpcPeeringConnection) PostCreate(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	params := jsonutils.NewDict()
 	task, err := taskman.TaskManager.NewTask(ctx, "VpcPeeringConnectionCreateTask", self, userCred, params, "", "", nil)
@@ -392,6 +570,87 @@ func (self *SVpcPeeringConnection) SyncWithCloudPeerConnection(ctx context.Conte
 	return nil
 }
 
+// IsOvnVpcPeering 本地 OneCloud vpc 之间的对等连接, 由 vpcagent 下发到 OVN
+func (self *SVpcPeeringConnection) IsOvnVpcPeering() bool {
+	vpc, err := self.GetVpc()
+	if err != nil {
+		return false
+	}
+	return len(vpc.ManagerId) == 0
+}
+
+// 分配互联地址; 跨域的对等连接需对端 vpc 所属域接受后才生效
+func (self *SVpcPeeringConnection) SetupOvnPeering(ctx context.Context, userCred mcclient.TokenCredential) error {
+	vpc, err := self.GetVpc()
+	if err != nil {
+		return errors.Wrapf(err, "GetVpc")
+	}
+	peerVpc, err := self.GetPeerVpc()
+	if err != nil {
+		return errors.Wrapf(err, "GetPeerVpc")
+	}
+
+	VpcPeeringConnectionManager.lockAllocOvnInterIpAddr(ctx)
+	defer VpcPeeringConnectionManager.unlockAllocOvnInterIpAddr(ctx)
+
+	addr := self.OvnInterIpAddr
+	if len(addr) == 0 {
+		addr, err = VpcPeeringConnectionManager.allocOvnInterIpAddr(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "allocOvnInterIpAddr")
+		}
+	}
+	_, err = db.Update(self, func() error {
+		self.OvnInterIpAddr = addr
+		self.PeerAccountId = peerVpc.DomainId
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "db.Update")
+	}
+
+	return self.SetStatus(ctx, userCred, ovnPeeringStatus(vpc, peerVpc), "")
+}
+
+// 同域的对等连接直接生效, 跨域的需对端 vpc 所属域接受
+func ovnPeeringStatus(vpc, peerVpc *SVpc) string {
+	if vpc.DomainId != peerVpc.DomainId {
+		return api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT
+	}
+	return api.VPC_PEERING_CONNECTION_STATUS_ACTIVE
+}
+
+// 接受对等连接
+func (vpc *SVpc) PerformAcceptPeering(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.VpcAcceptPeeringInput) (jsonutils.JSONObject, error) {
+	if len(input.VpcPeeringConnectionId) == 0 {
+		return nil, httperrors.NewMissingParameterError("vpc_peering_connection_id")
+	}
+	// 对等连接归属发起方的域, 对端域的用户通过其 vpc 接受
+	_peer, err := VpcPeeringConnectionManager.FetchById(input.VpcPeeringConnectionId)
+	if err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, httperrors.NewResourceNotFoundError2("vpc_peering_connection", input.VpcPeeringConnectionId)
+		}
+		return nil, httperrors.NewGeneralError(err)
+	}
+	peer := _peer.(*SVpcPeeringConnection)
+	if peer.PeerVpcId != vpc.Id {
+		return nil, httperrors.NewInputParameterError("vpc peering connection %s does not peer with vpc %s", peer.Name, vpc.Name)
+	}
+	if len(vpc.ManagerId) > 0 {
+		return nil, httperrors.NewNotSupportedError("accepting public cloud vpc peering connection is not supported")
+	}
+	if peer.Status != api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT {
+		return nil, httperrors.NewInvalidStatusError("vpc peering connection %s status %s is not %s", peer.Name, peer.Status, api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT)
+	}
+	err = peer.SetStatus(ctx, userCred, api.VPC_PEERING_CONNECTION_STATUS_ACTIVE, "accepted")
+	if err != nil {
+		return nil, errors.Wrapf(err, "SetStatus")
+	}
+	logclient.AddSimpleActionLog(peer, logclient.ACT_ACCEPT_PEERING, vpc.Id, userCred, true)
+	return nil, nil
+}
+
 func (self *SVpcPeeringConnection) GetVpc() (*SVpc, error) {
 	vpc, err := VpcManager.FetchById(self.VpcId)
 	if err != nil {
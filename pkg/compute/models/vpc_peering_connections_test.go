@@ -0,0 +1,207 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/netutils"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+)
+
+func testOvnVpc(id, domainId, cidr string) *SVpc {
+	vpc := &SVpc{CidrBlock: cidr}
+	vpc.Id = id
+	vpc.Name = id
+	vpc.DomainId = domainId
+	vpc.CloudregionId = api.DEFAULT_REGION_ID
+	return vpc
+}
+
+func testVpcPeering(vpcId, peerVpcId string) SVpcPeeringConnection {
+	peering := SVpcPeeringConnection{}
+	peering.VpcId = vpcId
+	peering.PeerVpcId = peerVpcId
+	return peering
+}
+
+func TestCheckOvnVpcPeering(t *testing.T) {
+	var (
+		vpc0 = testOvnVpc("vpc0", "default", "192.168.0.0/16")
+		vpc1 = testOvnVpc("vpc1", "default", "10.0.0.0/16,10.1.0.0/16")
+		vpc2 = testOvnVpc("vpc2", "default", "192.168.1.0/24")
+		vpc3 = testOvnVpc("vpc3", "default", "172.16.0.0/16")
+	)
+	vpc3.CloudregionId = "region1"
+
+	cases := []struct {
+		name     string
+		vpc      *SVpc
+		peerVpc  *SVpc
+		peerings []SVpcPeeringConnection
+		wantErr  string
+	}{
+		{"ok", vpc0, vpc1, nil, ""},
+		{"itself", vpc0, vpc0, nil, "itself"},
+		{"default vpc", testOvnVpc(api.DEFAULT_VPC_ID, "default", "0.0.0.0/0"), vpc1, nil, "default vpc"},
+		{"across regions", vpc0, vpc3, nil, "across regions"},
+		{"cidr overlap", vpc0, vpc2, nil, "overlap"},
+		{"duplicate", vpc0, vpc1, []SVpcPeeringConnection{testVpcPeering("vpc0", "vpc1")}, "already connected"},
+		{"reverse", vpc0, vpc1, []SVpcPeeringConnection{testVpcPeering("vpc1", "vpc0")}, "already connected"},
+		{"other peering", vpc0, vpc1, []SVpcPeeringConnection{testVpcPeering("vpc0", "vpc2")}, ""},
+	}
+	for _, c := range cases {
+		err := checkOvnVpcPeering(c.vpc, c.peerVpc, c.peerings)
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: want error %q got %v", c.name, c.wantErr, err)
+		}
+	}
+}
+
+func TestOvnPeeringStatus(t *testing.T) {
+	vpc0 := testOvnVpc("vpc0", "domain0", "192.168.0.0/16")
+	vpc1 := testOvnVpc("vpc1", "domain0", "10.0.0.0/16")
+	vpc2 := testOvnVpc("vpc2", "domain1", "10.1.0.0/16")
+	if status := ovnPeeringStatus(vpc0, vpc1); status != api.VPC_PEERING_CONNECTION_STATUS_ACTIVE {
+		t.Errorf("peering in the same domain got status %s", status)
+	}
+	if status := ovnPeeringStatus(vpc0, vpc2); status != api.VPC_PEERING_CONNECTION_STATUS_PENDING_ACCEPT {
+		t.Errorf("peering across domains got status %s", status)
+	}
+}
+
+func testSecgroupRule(direction, cidr, action, protocol string, priority int) SSecurityGroupRule {
+	return SSecurityGroupRule{
+		Direction: direction,
+		CIDR:      cidr,
+		Action:    action,
+		Protocol:  protocol,
+		Priority:  priority,
+	}
+}
+
+func TestCheckOvnPeeringSecgroups(t *testing.T) {
+	vpc := testOvnVpc("vpc0", "default", "192.168.0.0/16")
+	peerVpc := testOvnVpc("vpc1", "default", "10.0.0.0/16,10.1.0.0/16")
+	secgroup := func(name, managerId, vpcId string) *SSecurityGroup {
+		secgroup := &SSecurityGroup{}
+		secgroup.Name = name
+		secgroup.ManagerId = managerId
+		secgroup.VpcId = vpcId
+		return secgroup
+	}
+
+	cases := []struct {
+		name    string
+		secgrp  *SSecurityGroup
+		rules   []SSecurityGroupRule
+		wantErr string
+	}{
+		{
+			name:   "allow any",
+			secgrp: secgroup("sg0", "", ""),
+			rules:  []SSecurityGroupRule{testSecgroupRule("in", "", "allow", "any", 1)},
+		},
+		{
+			name:   "allow peer cidrs",
+			secgrp: secgroup("sg0", "", "vpc0"),
+			rules: []SSecurityGroupRule{
+				testSecgroupRule("in", "10.0.0.0/15", "allow", "tcp", 10),
+				testSecgroupRule("in", "", "deny", "any", 1),
+			},
+		},
+		{
+			name:   "protocol deny does not block",
+			secgrp: secgroup("sg0", "", ""),
+			rules: []SSecurityGroupRule{
+				testSecgroupRule("in", "", "deny", "udp", 100),
+				testSecgroupRule("in", "10.0.0.0/8", "allow", "any", 10),
+			},
+		},
+		{
+			name:   "partially allowed",
+			secgrp: secgroup("sg0", "", ""),
+			rules: []SSecurityGroupRule{
+				testSecgroupRule("in", "10.0.0.0/16", "allow", "any", 10),
+				testSecgroupRule("out", "", "allow", "any", 10),
+			},
+			wantErr: "sg0(10.1.0.0-10.1.255.255)",
+		},
+		{
+			name:   "denied by higher priority",
+			secgrp: secgroup("sg0", "", ""),
+			rules: []SSecurityGroupRule{
+				testSecgroupRule("in", "", "allow", "any", 1),
+				testSecgroupRule("in", "10.0.0.0/8", "deny", "any", 100),
+			},
+			wantErr: "do not allow ingress",
+		},
+		{
+			name:    "no rules",
+			secgrp:  secgroup("sg0", "", ""),
+			wantErr: "do not allow ingress",
+		},
+		{
+			name:    "public cloud",
+			secgrp:  secgroup("sg0", "manager0", ""),
+			rules:   []SSecurityGroupRule{testSecgroupRule("in", "", "allow", "any", 1)},
+			wantErr: "not a onecloud security group",
+		},
+		{
+			name:    "another vpc",
+			secgrp:  secgroup("sg0", "", "vpc2"),
+			rules:   []SSecurityGroupRule{testSecgroupRule("in", "", "allow", "any", 1)},
+			wantErr: "belongs to another vpc",
+		},
+	}
+	for _, c := range cases {
+		err := checkOvnPeeringSecgroups(vpc, peerVpc, map[*SSecurityGroup][]SSecurityGroupRule{c.secgrp: c.rules})
+		if len(c.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+			t.Errorf("%s: want error %q got %v", c.name, c.wantErr, err)
+		}
+	}
+}
+
+func TestNextOvnInterIpAddr(t *testing.T) {
+	addr, err := nextOvnInterIpAddr(nil)
+	if err != nil || addr != "100.65.64.0" {
+		t.Errorf("first inter addr: %s %v", addr, err)
+	}
+	addr, err = nextOvnInterIpAddr([]string{"100.65.64.0", "100.65.64.8"})
+	if err != nil || addr != "100.65.64.4" {
+		t.Errorf("inter addr after used ones: %s %v", addr, err)
+	}
+
+	used := []string{}
+	step := netutils.IPV4Addr(1 << (32 - api.VpcPeeringIPMask))
+	for i := api.VpcPeeringIPStart(); i <= api.VpcPeeringIPEnd(); i += step {
+		used = append(used, i.String())
+	}
+	_, err = nextOvnInterIpAddr(used)
+	if errors.Cause(err) != errMappedIpExhausted {
+		t.Errorf("inter addrs should be exhausted, got %v", err)
+	}
+}
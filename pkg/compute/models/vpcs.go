@@ -258,6 +258,10 @@ func (svpc *SVpc) ValidateDeleteCondition(ctx context.Context, info *api.VpcDeta
 	if info.RequestVpcPeerCount > 0 {
 		return httperrors.NewNotEmptyError("VPC not empty, please delete vpc peering first")
 	}
+	// 本地 vpc 作为对端时, 对等连接同样占用其逻辑路由器
+	if len(svpc.ManagerId) == 0 && info.AcceptVpcPeerCount > 0 {
+		return httperrors.NewNotEmptyError("VPC not empty, please delete vpc peering first")
+	}
 
 	return svpc.SEnabledStatusInfrasResourceBase.ValidateDeleteCondition(ctx, nil)
 }
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/netutils"
 	"yunion.io/x/pkg/utils"
 
 	api "yunion.io/x/onecloud/pkg/apis/compute"
@@ -32,6 +33,9 @@ const (
 
 	LOCK_CLASS_hosts_mapped_addr = "hosts-mapped-addr"
 	LOCK_OBJ_hosts_mapped_addr   = "the-addr"
+
+	LOCK_CLASS_vpc_peering_connections_inter_addr = "vpc-peering-connections-inter-addr"
+	LOCK_OBJ_vpc_peering_connections_inter_addr   = "the-addr"
 )
 
 func (man *SGuestnetworkManager) lockAllocMappedAddr(ctx context.Context) {
@@ -109,3 +113,46 @@ func (man *SHostManager) allocOvnMappedIpAddr(ctx context.Context) (string, erro
 	}
 	return "", errors.Wrap(errMappedIpExhausted, "hosts")
 }
+
+func (man *SVpcPeeringConnectionManager) lockAllocOvnInterIpAddr(ctx context.Context) {
+	lockman.LockRawObject(ctx, LOCK_CLASS_vpc_peering_connections_inter_addr, LOCK_OBJ_vpc_peering_connections_inter_addr)
+}
+
+func (man *SVpcPeeringConnectionManager) unlockAllocOvnInterIpAddr(ctx context.Context) {
+	lockman.ReleaseRawObject(ctx, LOCK_CLASS_vpc_peering_connections_inter_addr, LOCK_OBJ_vpc_peering_connections_inter_addr)
+}
+
+func (man *SVpcPeeringConnectionManager) allocOvnInterIpAddr(ctx context.Context) (string, error) {
+	var (
+		used []string
+		ip   string
+	)
+
+	q := man.Query("ovn_inter_ip_addr").IsNotEmpty("ovn_inter_ip_addr")
+	rows, err := q.Rows()
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := rows.Scan(&ip); err != nil {
+			return "", errors.Wrap(err, "scan vpc peering inter ip")
+		}
+		used = append(used, ip)
+	}
+	return nextOvnInterIpAddr(used)
+}
+
+// 互联网段按 /30 分配, 返回第一个未被占用的网段起始地址
+func nextOvnInterIpAddr(used []string) (string, error) {
+	sip := api.VpcPeeringIPStart()
+	eip := api.VpcPeeringIPEnd()
+	step := netutils.IPV4Addr(1 << (32 - api.VpcPeeringIPMask))
+	for i := sip; i <= eip; i += step {
+		s := i.String()
+		if !utils.IsInStringArray(s, used) {
+			return s, nil
+		}
+	}
+	return "", errors.Wrap(errMappedIpExhausted, "vpc peering connections")
+}
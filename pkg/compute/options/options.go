@@ -52,6 +52,11 @@ type ComputeOptions struct {
 
 	LoadbalancerPendingDeleteCheckInterval int `default:"3600" help:"Interval between checks of pending deleted loadbalancer objects, defaults to 1h"`
 
+	AcmeCaFile                  string `help:"CA file to verify the ACME server, e.g. the root of a pebble test server"`
+	AcmeChallengeTimeoutSeconds int    `default:"300" help:"Timeout of an ACME certificate issuance, defaults to 5 minutes"`
+	AcmeDnsPropagationSeconds   int    `default:"60" help:"Seconds to wait for dns-01 records of cloud dns zones to propagate"`
+	AcmeRetryIntervalHours      int    `default:"6" help:"Interval to retry the failed automatic renewal of ACME certificates"`
+
 	ImageCacheStoragePolicy string `default:"least_used" choices:"best_fit|least_used" help:"Policy to choose storage for image cache, best_fit or least_used"`
 	MetricsRetentionDays    int32  `default:"30" help:"Retention days for monitoring metrics in influxdb"`
 
@@ -158,10 +163,20 @@ type ComputeOptions struct {
 	EnableHostHealthCheck bool `help:"enable host health check" default:"false"`
 	HostHealthTimeout     int  `help:"second of wait host reconnect" default:"60"`
 
+	HostCallbackTaskStageTimeoutSeconds int `help:"seconds that a guest start, stop or syncstatus task waits for the callback of the host before it is cancelled, 0 means no limit" default:"1800"`
+
+	DefaultHostFencePolicy     string `help:"fence the unhealthy host by its BMC before restarting its guests on other hosts, can be overridden by zone metadata host_fence_policy" default:"none" choices:"none|poweroff|powercycle"`
+	DefaultHostFenceFailAction string `help:"what to do with the guests of the unhealthy host if fencing fails, can be overridden by zone metadata host_fence_fail_action" default:"abort" choices:"abort|continue"`
+	HostFenceTimeoutSeconds    int    `help:"seconds to wait for the fenced host powered off" default:"60"`
+	HostFenceRetryCount        int    `help:"times to retry fencing the unhealthy host before taking the fail action" default:"2"`
+
 	GuestTemplateCheckInterval int `help:"interval between two consecutive inspections of Guest Template in hour unit" default:"12"`
 
 	ReconcileGuestBackupIntervalSeconds int `help:"interval reconcile guest bakcups" default:"30"`
 
+	DiskBackupMaxChainLength   int `help:"max incremental or differential backups based on a full disk backup, a new full backup is taken when reached" default:"6"`
+	DiskBackupFullIntervalDays int `help:"days after which a new full disk backup is taken instead of an incremental or differential one, 0 means never" default:"7"`
+
 	EnableAutoRenameProject bool `help:"when it set true, auto create project will rename when cloud project name changed" default:"false"`
 
 	SyncStorageCapacityUsedIntervalMinutes int  `help:"interval sync storage capacity used" default:"20"`
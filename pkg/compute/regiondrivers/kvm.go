@@ -1149,8 +1149,39 @@ func (self *SKVMRegionDriver) RequestSyncSnapshotStatus(ctx context.Context, use
 	return nil
 }
 
+func (self *SKVMRegionDriver) ValidateCreateNatGateway(ctx context.Context, userCred mcclient.TokenCredential, input api.NatgatewayCreateInput) (api.NatgatewayCreateInput, error) {
+	if input.VpcId == api.DEFAULT_VPC_ID {
+		return input, httperrors.NewUnsupportOperationError("nat gateway is not supported in default vpc")
+	}
+	_vpc, err := models.VpcManager.FetchById(input.VpcId)
+	if err != nil {
+		return input, errors.Wrapf(err, "VpcManager.FetchById(%s)", input.VpcId)
+	}
+	vpc := _vpc.(*models.SVpc)
+	// NAT 网关流量经由 eipgw 出入, vpc 须开启 eip 外部访问模式
+	if !vpc.IsSupportAssociateEip() {
+		return input, httperrors.NewUnsupportOperationError("vpc %s external access mode %s does not support nat gateway", vpc.Name, vpc.ExternalAccessMode)
+	}
+	if len(input.Duration) > 0 {
+		return input, httperrors.NewUnsupportOperationError("nat gateway of onecloud vpc does not support billing cycle")
+	}
+	return input, nil
+}
+
 func (self *SKVMRegionDriver) RequestAssociateEipForNAT(ctx context.Context, userCred mcclient.TokenCredential, nat *models.SNatGateway, eip *models.SElasticip, task taskman.ITask) error {
-	return errors.Wrapf(cloudprovider.ErrNotSupported, "RequestAssociateEipForNAT")
+	opts := api.ElasticipAssociateInput{
+		InstanceType: api.EIP_ASSOCIATE_TYPE_NAT_GATEWAY,
+		InstanceId:   nat.Id,
+	}
+	return eip.StartEipAssociateTask(ctx, userCred, jsonutils.Marshal(opts).(*jsonutils.JSONDict), task.GetTaskId())
+}
+
+func (self *SKVMRegionDriver) RequestSyncNatGatewayStatus(ctx context.Context, userCred mcclient.TokenCredential, natgateway *models.SNatGateway, task taskman.ITask) error {
+	// 状态由 vpcagent 在 OVN 规则下发完成后回写
+	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
+		return nil, nil
+	})
+	return nil
 }
 
 func (self *SKVMRegionDriver) ValidateCacheSecgroup(ctx context.Context, userCred mcclient.TokenCredential, secgroup *models.SSecurityGroup, vpc *models.SVpc, classic bool) error {
@@ -1337,6 +1368,9 @@ func (self *SKVMRegionDriver) RequestCreateBackup(ctx context.Context, backup *m
 	if err != nil {
 		return errors.Wrap(err, "unable to get storage")
 	}
+	if backup.IsBitmapBackup() {
+		return self.requestCreateBitmapBackup(ctx, backup, backupStorage, disk, guest, task)
+	}
 	snapshotObj, err := models.SnapshotManager.FetchById(snapshotId)
 	if err != nil {
 		return errors.Wrap(err, "fetch snapshot")
@@ -1367,6 +1401,33 @@ func (self *SKVMRegionDriver) RequestCreateBackup(ctx context.Context, backup *m
 	return nil
 }
 
+// requestCreateBitmapBackup backups the disk of the running guest by the dirty
+// bitmap tracking the changes since the last backup in the backup chain
+func (self *SKVMRegionDriver) requestCreateBitmapBackup(ctx context.Context, backup *models.SDiskBackup, backupStorage *models.SBackupStorage, disk *models.SDisk, guest *models.SGuest, task taskman.ITask) error {
+	host, err := guest.GetHost()
+	if err != nil {
+		return errors.Wrap(err, "unable to get host")
+	}
+	url := fmt.Sprintf("%s/servers/%s/disk-backup", host.ManagerUri, guest.Id)
+	body := jsonutils.NewDict()
+	body.Set("disk_id", jsonutils.NewString(disk.Id))
+	body.Set("backup_id", jsonutils.NewString(backup.GetId()))
+	body.Set("backup_mode", jsonutils.NewString(backup.BackupMode))
+	body.Set("bitmap", jsonutils.NewString(api.DISK_BACKUP_BITMAP_NAME))
+	body.Set("backup_storage_id", jsonutils.NewString(backupStorage.GetId()))
+	accessInfo, err := backupStorage.GetAccessInfo()
+	if err != nil {
+		return errors.Wrap(err, "GetAccessInfo")
+	}
+	body.Set("backup_storage_access_info", jsonutils.Marshal(accessInfo))
+	header := task.GetTaskRequestHeader()
+	_, _, err = httputils.JSONRequest(httputils.GetDefaultClient(), ctx, "POST", url, header, body, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to backup")
+	}
+	return nil
+}
+
 func (self *SKVMRegionDriver) RequestAssociateEip(ctx context.Context, userCred mcclient.TokenCredential, eip *models.SElasticip, input api.ElasticipAssociateInput, obj db.IStatusStandaloneModel, task taskman.ITask) error {
 	taskman.LocalTaskRun(task, func() (jsonutils.JSONObject, error) {
 		if err := eip.AssociateInstance(ctx, userCred, input.InstanceType, obj); err != nil {
@@ -1388,6 +1449,8 @@ func (self *SKVMRegionDriver) RequestAssociateEip(ctx context.Context, userCred
 			if err != nil {
 				return nil, err
 			}
+		case api.EIP_ASSOCIATE_TYPE_NAT_GATEWAY:
+			// snat/dnat 规则由 vpcagent 下发, 无需额外处理
 		default:
 			return nil, errors.Wrapf(cloudprovider.ErrNotSupported, "instance type %s", input.InstanceType)
 		}
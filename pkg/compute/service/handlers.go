@@ -18,6 +18,7 @@ import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/proxy"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/quotas"
@@ -52,6 +53,7 @@ func InitHandlers(app *appsrv.Application) {
 	specs.AddSpecHandler("", app)
 	sshkeys.AddSshKeysHandler("", app)
 	taskman.AddTaskHandler("", app)
+	cronman.AddCronJobHandler("", app)
 	misc.AddMiscHandler("", app)
 
 	app_common.ExportOptionsHandler(app, &options.Options)
@@ -60,6 +62,7 @@ func InitHandlers(app *appsrv.Application) {
 		taskman.TaskManager,
 		taskman.SubTaskManager,
 		taskman.TaskObjectManager,
+		taskman.TaskReplicaManager,
 		db.UserCacheManager,
 		db.TenantCacheManager,
 		db.SharedResourceManager,
@@ -98,6 +101,11 @@ func InitHandlers(app *appsrv.Application) {
 		models.CloudimageManager,
 
 		models.WafRuleStatementManager,
+
+		models.DnsZoneKeyManager,
+
+		models.LoadbalancerAcmeAccountManager,
+
 		models.BillingResourceCheckManager,
 
 		models.SnapshotPolicyDiskManager,
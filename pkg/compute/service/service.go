@@ -152,6 +152,9 @@ func StartServiceWithJobs(jobs func(cron *cronman.SCronJobManager)) {
 		cron := cronman.InitCronJobManager(true, options.Options.CronJobWorkerCount)
 		cron.AddJobAtIntervals("CleanPendingDeleteServers", time.Duration(opts.PendingDeleteCheckSeconds)*time.Second, models.GuestManager.CleanPendingDeleteServers)
 		cron.AddJobAtIntervals("CleanPendingDeleteDisks", time.Duration(opts.PendingDeleteCheckSeconds)*time.Second, models.DiskManager.CleanPendingDeleteDisks)
+		cron.AddJobAtIntervals("AutoRolloverDnssecKeys", time.Hour, models.DnsZoneManager.AutoRolloverDnssecKeys)
+		cron.AddJobAtIntervals("AutoRenewAcmeCertificates", time.Hour, models.LoadbalancerCertificateManager.AutoRenewAcmeCertificates)
+		cron.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
 		if opts.PrepaidExpireCheck {
 			cron.AddJobAtIntervals("CleanExpiredPrepaidServers", time.Duration(opts.PrepaidExpireCheckSeconds)*time.Second, models.GuestManager.DeleteExpiredPrepaidServers)
 		}
@@ -206,7 +209,14 @@ func StartServiceWithJobs(jobs func(cron *cronman.SCronJobManager)) {
 
 		go cron.Start2(ctx, electObj)
 	}
+
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(ctx)
 	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(ctx); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
 		go cronFunc()
 	}
 
@@ -71,7 +71,8 @@ func (self *DiskBackupCreateTask) taksSuccess(ctx context.Context, backup *model
 
 func (self *DiskBackupCreateTask) OnInit(ctx context.Context, obj db.IStandaloneModel, data jsonutils.JSONObject) {
 	backup := obj.(*models.SDiskBackup)
-	if self.Params.Contains("snapshot_id") {
+	if self.Params.Contains("snapshot_id") || backup.IsBitmapBackup() {
+		// backups in a backup chain are taken from the running guest by dirty bitmap, no snapshot needed
 		self.OnSnapshot(ctx, backup, nil)
 		return
 	}
@@ -121,18 +122,29 @@ func (self *DiskBackupCreateTask) OnSnapshotFailed(ctx context.Context, backup *
 func (self *DiskBackupCreateTask) OnSave(ctx context.Context, backup *models.SDiskBackup, data jsonutils.JSONObject) {
 	// cleanup snapshot
 	snapshotId, _ := self.Params.GetString("snapshot_id")
-	self.SetStage("OnCleanupSnapshot", nil)
-	snapshotModel, err := models.SnapshotManager.FetchById(snapshotId)
-	if err != nil {
-		self.taskFailed(ctx, backup, jsonutils.NewString(err.Error()), api.BACKUP_STATUS_CLEANUP_SNAPSHOT_FAILED)
-		return
-	}
 	log.Infof("data from RequestCreateBackup: %s", data)
 	sizeMb, _ := data.Int("size_mb")
 	db.Update(backup, func() error {
 		backup.SizeMb = int(sizeMb)
 		return nil
 	})
+	if backupMode, _ := data.GetString("backup_mode"); len(backupMode) > 0 {
+		err := backup.RebaseBackupChain(ctx, self.UserCred, backupMode)
+		if err != nil {
+			self.taskFailed(ctx, backup, jsonutils.NewString(err.Error()), api.BACKUP_STATUS_SAVE_FAILED)
+			return
+		}
+	}
+	if len(snapshotId) == 0 {
+		self.taksSuccess(ctx, backup, nil)
+		return
+	}
+	self.SetStage("OnCleanupSnapshot", nil)
+	snapshotModel, err := models.SnapshotManager.FetchById(snapshotId)
+	if err != nil {
+		self.taskFailed(ctx, backup, jsonutils.NewString(err.Error()), api.BACKUP_STATUS_CLEANUP_SNAPSHOT_FAILED)
+		return
+	}
 	snapshot := snapshotModel.(*models.SSnapshot)
 	err = snapshot.StartSnapshotDeleteTask(ctx, self.UserCred, false, self.GetId())
 	if err != nil {
@@ -143,6 +155,10 @@ func (self *DiskBackupCreateTask) OnSave(ctx context.Context, backup *models.SDi
 
 func (self *DiskBackupCreateTask) OnSaveFailed(ctx context.Context, backup *models.SDiskBackup, data jsonutils.JSONObject) {
 	snapshotId, _ := self.Params.GetString("snapshot_id")
+	if len(snapshotId) == 0 {
+		self.taskFailed(ctx, backup, data, api.BACKUP_STATUS_SAVE_FAILED)
+		return
+	}
 	snapshotModel, err := models.SnapshotManager.FetchById(snapshotId)
 	if err != nil {
 		log.Errorf("unable to cleanup snapshot: %s", err.Error())
@@ -178,6 +194,28 @@ func (self *DiskBackupCreateTask) OnCleanupSnapshotFailed(ctx context.Context, b
 	self.taskFailed(ctx, backup, data, api.BACKUP_STATUS_CLEANUP_SNAPSHOT_FAILED)
 }
 
+// PrepareResume waits for the host saving the backup after the region restarts,
+// the snapshot taken before the restart is used if it is ready
+func (self *DiskBackupCreateTask) PrepareResume(ctx context.Context, stage string) (jsonutils.JSONObject, error) {
+	switch stage {
+	case "OnSave":
+		return nil, nil
+	case "OnCleanupSnapshot":
+		return jsonutils.NewDict(), nil
+	case "OnSnapshot":
+		snapshotId, _ := self.Params.GetString("snapshot_id")
+		snapshot, err := models.SnapshotManager.FetchById(snapshotId)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetch snapshot %s", snapshotId)
+		}
+		if status := snapshot.(*models.SSnapshot).Status; status != api.SNAPSHOT_READY {
+			return nil, errors.Errorf("snapshot %s is %s", snapshotId, status)
+		}
+		return jsonutils.NewDict(), nil
+	}
+	return nil, errors.Wrapf(errors.ErrNotSupported, "stage %s", stage)
+}
+
 func (self *DiskBackupCreateTask) CreateSnapshot(ctx context.Context, diskBackup *models.SDiskBackup) (*models.SSnapshot, error) {
 	disk, err := diskBackup.GetDisk()
 	if err != nil {
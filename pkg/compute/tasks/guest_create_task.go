@@ -305,6 +305,28 @@ func (self *GuestCreateTask) OnSyncStatusComplete(ctx context.Context, obj db.IS
 	self.TaskComplete(ctx, guest)
 }
 
+// PrepareResume re-drives the stages that are safe to run again after the region
+// restarts, the stages waiting for subtasks are resumed by the subtasks
+func (self *GuestCreateTask) PrepareResume(ctx context.Context, stage string) (jsonutils.JSONObject, error) {
+	guest := self.GetObject().(*models.SGuest)
+	switch stage {
+	case "OnWaitGuestNetworksReady", "OnAutoStartGuest", "OnSyncStatusComplete":
+		return jsonutils.NewDict(), nil
+	case "OnDiskPrepared":
+		disks, err := guest.GetDisks()
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetDisks")
+		}
+		for i := range disks {
+			if disks[i].Status != api.DISK_READY {
+				return nil, errors.Errorf("disk %s is %s", disks[i].Name, disks[i].Status)
+			}
+		}
+		return jsonutils.NewDict(), nil
+	}
+	return nil, errors.Wrapf(errors.ErrNotSupported, "stage %s", stage)
+}
+
 func (self *GuestCreateTask) TaskComplete(ctx context.Context, guest *models.SGuest) {
 	db.OpsLog.LogEvent(guest, db.ACT_ALLOCATE, "", self.UserCred)
 	logclient.AddActionLogWithContext(ctx, guest, logclient.ACT_ALLOCATE, "", self.UserCred, true)
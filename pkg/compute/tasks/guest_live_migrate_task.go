@@ -714,6 +714,21 @@ func (task *GuestMigrateTask) updateInstanceSnapshotMemory(ctx context.Context,
 	return nil
 }
 
+// PrepareResume keeps the migration waiting for the callbacks of the hosts after
+// the region restarts, the image caching and the stages after the guest has been
+// moved to the target host are re-driven
+func (task *GuestMigrateTask) PrepareResume(ctx context.Context, stage string) (jsonutils.JSONObject, error) {
+	switch stage {
+	case "OnSrcPrepareComplete", "OnMigrateConfAndDiskComplete", "OnStartDestComplete",
+		"OnLiveMigrateComplete", "OnResumeDestGuestComplete", "OnResumeSourceGuestComplete":
+		return nil, nil
+	case "OnStartCacheImages", "OnCachedCdromComplete",
+		"OnUndeployOldHostSucc", "OnGuestStartSucc", "OnUndeploySrcGuestComplete", "OnGuestSyncStatus":
+		return jsonutils.NewDict(), nil
+	}
+	return nil, errors.Wrapf(errors.ErrNotSupported, "stage %s", stage)
+}
+
 func (task *GuestMigrateTask) TaskComplete(ctx context.Context, guest *models.SGuest) {
 	if err := task.updateInstanceSnapshotMemory(ctx, guest); err != nil {
 		task.TaskFailed(ctx, guest, jsonutils.NewString(err.Error()))
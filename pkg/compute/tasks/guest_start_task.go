@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -93,6 +94,20 @@ func (self *GuestStartTask) OnStartCompleteFailed(ctx context.Context, obj db.IS
 	self.SetStageFailed(ctx, err)
 }
 
+// GetTimeout implements taskman.ITimeoutTask
+func (self *GuestStartTask) GetTimeout() time.Duration {
+	return 0
+}
+
+// GetStageTimeout limits the time waiting for the host starting the guest, the
+// guest is set to start_fail when the task is cancelled on timeout
+func (self *GuestStartTask) GetStageTimeout(stage string) time.Duration {
+	if stage == "OnStartComplete" {
+		return hostCallbackStageTimeout()
+	}
+	return 0
+}
+
 func (self *GuestStartTask) taskComplete(ctx context.Context, guest *models.SGuest) {
 	models.HostManager.ClearSchedDescCache(guest.HostId)
 	self.SetStageComplete(ctx, nil)
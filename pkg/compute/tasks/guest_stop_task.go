@@ -16,6 +16,7 @@ package tasks
 
 import (
 	"context"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
@@ -87,6 +88,20 @@ func (self *GuestStopTask) OnGuestStopTaskCompleteFailed(ctx context.Context, gu
 	logclient.AddActionLogWithStartable(self, guest, logclient.ACT_VM_STOP, reason.String(), self.UserCred, false)
 }
 
+// GetTimeout implements taskman.ITimeoutTask
+func (self *GuestStopTask) GetTimeout() time.Duration {
+	return 0
+}
+
+// GetStageTimeout limits the time waiting for the host stopping the guest, the
+// guest is set to stop_fail when the task is cancelled on timeout
+func (self *GuestStopTask) GetStageTimeout(stage string) time.Duration {
+	if stage == "OnGuestStopTaskComplete" {
+		return hostCallbackStageTimeout()
+	}
+	return 0
+}
+
 type GuestStopAndFreezeTask struct {
 	SGuestBaseTask
 }
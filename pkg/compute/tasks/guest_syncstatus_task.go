@@ -17,6 +17,7 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
@@ -108,6 +109,20 @@ func (self *GuestSyncstatusTask) OnGetStatusComplete(ctx context.Context, obj db
 	// logclient.AddActionLog(guest, logclient.ACT_VM_SYNC_STATUS, "", self.UserCred, true)
 }
 
+// GetTimeout implements taskman.ITimeoutTask
+func (self *GuestSyncstatusTask) GetTimeout() time.Duration {
+	return 0
+}
+
+// GetStageTimeout limits the time waiting for the host reporting the status, the
+// guest is set to unknown when the task is cancelled on timeout
+func (self *GuestSyncstatusTask) GetStageTimeout(stage string) time.Duration {
+	if stage == "OnGetStatusComplete" {
+		return hostCallbackStageTimeout()
+	}
+	return 0
+}
+
 func (self *GuestSyncstatusTask) OnGetStatusCompleteFailed(ctx context.Context, obj db.IStandaloneModel, err jsonutils.JSONObject) {
 	guest := obj.(*models.SGuest)
 	guest.SetStatus(ctx, self.UserCred, api.VM_UNKNOWN, err.String())
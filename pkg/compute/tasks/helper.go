@@ -14,8 +14,20 @@
 
 package tasks
 
-import "yunion.io/x/jsonutils"
+import (
+	"time"
+
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/compute/options"
+)
 
 func jsonErrorObj(err error) jsonutils.JSONObject {
 	return jsonutils.NewString(err.Error())
 }
+
+// hostCallbackStageTimeout returns the deadline duration of the stages waiting for
+// the callbacks of the hosts
+func hostCallbackStageTimeout() time.Duration {
+	return time.Duration(options.Options.HostCallbackTaskStageTimeoutSeconds) * time.Second
+}
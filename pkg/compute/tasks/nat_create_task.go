@@ -46,6 +46,13 @@ func (self *NatGatewayCreateTask) taskFailed(ctx context.Context, nat *models.SN
 	self.SetStageFailed(ctx, jsonutils.NewString(err.Error()))
 }
 
+// OnCancel releases the pending usage of the eip when the task is cancelled or timed out
+func (self *NatGatewayCreateTask) OnCancel(ctx context.Context, reason string) {
+	nat := self.GetObject().(*models.SNatGateway)
+	nat.SetStatus(ctx, self.UserCred, api.NAT_STATUS_CREATE_FAILED, reason)
+	ClearTaskPendingRegionUsage(ctx, self)
+}
+
 func (self *NatGatewayCreateTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
 	nat := obj.(*models.SNatGateway)
 
@@ -61,6 +68,12 @@ func (self *NatGatewayCreateTask) OnInit(ctx context.Context, obj db.IStandalone
 		return
 	}
 
+	if nat.IsOvnNatGateway() {
+		// OVN 中的 NAT 规则由 vpcagent 下发
+		self.OnCreateNatGatewayCreateComplete(ctx, nat, nil)
+		return
+	}
+
 	opts.VpcId = vpc.ExternalId
 
 	if len(nat.NetworkId) > 0 {
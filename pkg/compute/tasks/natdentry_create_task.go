@@ -87,6 +87,13 @@ func (self *SNatDEntryCreateTask) OnAssociateEipComplete(ctx context.Context, dn
 		self.taskFailed(ctx, dnat, errors.Wrapf(err, "dnat.GetNatgateway"))
 		return
 	}
+	if nat.IsOvnNatGateway() {
+		// 规则由 vpcagent 下发到 OVN 后回写 available 状态
+		logclient.AddActionLogWithStartable(self, nat, logclient.ACT_NAT_CREATE_DNAT, nil, self.UserCred, true)
+		logclient.AddActionLogWithStartable(self, dnat, logclient.ACT_ALLOCATE, nil, self.UserCred, true)
+		self.SetStageComplete(ctx, nil)
+		return
+	}
 	iNat, err := nat.GetINatGateway(ctx)
 	if err != nil {
 		self.taskFailed(ctx, dnat, errors.Wrapf(err, "nat.GetINatGateway"))
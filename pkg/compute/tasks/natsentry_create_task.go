@@ -87,6 +87,12 @@ func (self *SNatSEntryCreateTask) OnAssociateEipComplete(ctx context.Context, sn
 		self.taskFailed(ctx, snat, errors.Wrapf(err, "snat.GetNatgateway"))
 		return
 	}
+	if nat.IsOvnNatGateway() {
+		// 规则由 vpcagent 下发到 OVN 后回写 available 状态
+		logclient.AddActionLogWithStartable(self, nat, logclient.ACT_NAT_CREATE_SNAT, nil, self.UserCred, true)
+		self.SetStageComplete(ctx, nil)
+		return
+	}
 	iNat, err := nat.GetINatGateway(ctx)
 	if err != nil {
 		self.taskFailed(ctx, snat, errors.Wrapf(err, "nat.GetINatGateway"))
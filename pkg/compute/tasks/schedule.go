@@ -90,6 +90,12 @@ func (self *SSchedTask) OnScheduleFailed(ctx context.Context, reason jsonutils.J
 	self.SetStageFailed(ctx, reason)
 }
 
+// OnCancel releases the pending usages held by the task when it is cancelled or timed out
+func (self *SSchedTask) OnCancel(ctx context.Context, reason string) {
+	ClearTaskPendingUsage(ctx, self)
+	ClearTaskPendingRegionUsage(ctx, self)
+}
+
 func StartScheduleObjects(
 	ctx context.Context,
 	task IScheduleTask,
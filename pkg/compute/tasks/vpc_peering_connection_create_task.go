@@ -58,6 +58,17 @@ func (self *VpcPeeringConnectionCreateTask) OnInit(ctx context.Context, obj db.I
 		return
 	}
 
+	if peer.IsOvnVpcPeering() {
+		// 逻辑路由器互联及路由由 vpcagent 下发
+		err := peer.SetupOvnPeering(ctx, self.GetUserCred())
+		if err != nil {
+			self.taskFailed(ctx, peer, errors.Wrapf(err, "SetupOvnPeering"))
+			return
+		}
+		self.taskComplete(ctx, peer)
+		return
+	}
+
 	iVpc, err := vpc.GetIVpc(ctx)
 	if err != nil {
 		self.taskFailed(ctx, peer, errors.Wrapf(err, "GetIVpc"))
@@ -58,6 +58,12 @@ func (self *VpcPeeringConnectionDeleteTask) OnInit(ctx context.Context, obj db.I
 		return
 	}
 
+	if peer.IsOvnVpcPeering() {
+		// OVN 中的互联端口及路由由 vpcagent 清理
+		self.taskComplete(ctx, peer)
+		return
+	}
+
 	iVpc, err := vpc.GetIVpc(ctx)
 	if err != nil {
 		self.taskFailed(ctx, peer, errors.Wrapf(err, "GetIVpc"))
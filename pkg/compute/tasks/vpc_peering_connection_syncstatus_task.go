@@ -51,6 +51,16 @@ func (self *VpcPeeringConnectionSyncstatusTask) OnInit(ctx context.Context, obj
 		return
 	}
 
+	if peer.IsOvnVpcPeering() {
+		originStatus, _ := self.GetParams().GetString("origin_status")
+		if len(originStatus) == 0 {
+			originStatus = api.VPC_PEERING_CONNECTION_STATUS_UNKNOWN
+		}
+		peer.SetStatus(ctx, self.UserCred, originStatus, "")
+		self.SetStageComplete(ctx, nil)
+		return
+	}
+
 	extVpc, err := svpc.GetIVpc(ctx)
 	if err != nil {
 		self.taskFail(ctx, peer, errors.Wrap(err, "svpc.GetIVpc()"))
@@ -17,6 +17,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/devtool/models"
@@ -25,11 +26,13 @@ import (
 func InitHandlers(app *appsrv.Application) {
 	db.InitAllManagers()
 	taskman.AddTaskHandler("", app)
+	cronman.AddCronJobHandler("", app)
 
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
 		taskman.SubTaskManager,
 		taskman.TaskObjectManager,
+		taskman.TaskReplicaManager,
 		db.SharedResourceManager,
 		db.UserCacheManager,
 		db.TenantCacheManager,
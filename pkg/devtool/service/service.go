@@ -16,6 +16,7 @@ package service
 
 import (
 	"os"
+	"time"
 
 	"yunion.io/x/log"
 	_ "yunion.io/x/sqlchemy/backends"
@@ -24,6 +25,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon"
 	app_common "yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/devtool/models"
 	"yunion.io/x/onecloud/pkg/devtool/options"
@@ -56,6 +58,16 @@ func StartService() {
 
 	models.InitializeCronjobs(app.GetContext())
 
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(app.GetContext())
+	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(app.GetContext()); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
+		models.DevToolCronManager.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
+	}
+
 	app_common.ServeForeverWithCleanup(app, baseOpts, func() {
 		cloudcommon.CloseDB()
 	})
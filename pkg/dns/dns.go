@@ -43,6 +43,7 @@ import (
 	"yunion.io/x/onecloud/pkg/compute/models"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/util/dnsutils"
 )
 
 const (
@@ -65,6 +66,11 @@ var (
 		dns.TypeSRV:   "SRV",
 		dns.TypeSOA:   "SOA",
 		dns.TypeNS:    "NS",
+		dns.TypeCAA:   "CAA",
+		dns.TypeNAPTR: "NAPTR",
+
+		dnsutils.TypeSVCB:  dnsutils.DnsTypeSVCB,
+		dnsutils.TypeHTTPS: dnsutils.DnsTypeHTTPS,
 	}
 )
 
@@ -91,10 +97,14 @@ type SRegionDNS struct {
 	// K8sManager *k8s.SKubeClusterManager
 
 	primaryZoneLabelCount int
+
+	dnssec *sDnssecZones
 }
 
 func New() *SRegionDNS {
-	r := &SRegionDNS{}
+	r := &SRegionDNS{
+		dnssec: newDnssecZones(),
+	}
 	return r
 }
 
@@ -149,13 +159,19 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 	opt := plugin.Options{}
 	state := request.Request{W: w, Req: rmsg, Context: ctx}
 	zone := plugin.Zones(r.Zones).Matches(state.Name())
+	signedZone := r.dnssec.getZone(state.Name())
+	if signedZone != nil {
+		if ok, rcode, err := r.serveSigned(signedZone, state); ok {
+			return rcode, err
+		}
+	}
 	switch state.QType() {
 	case dns.TypeA:
 		records, err = plugin.A(r, zone, state, nil, opt)
 	case dns.TypeAAAA:
 		records, err = plugin.AAAA(r, zone, state, nil, opt)
 	case dns.TypeTXT:
-		records, err = plugin.TXT(r, zone, state, opt)
+		records, err = r.txtRecords(zone, state, opt)
 	case dns.TypeCNAME:
 		records, err = plugin.CNAME(r, zone, state, opt)
 	case dns.TypePTR:
@@ -166,7 +182,13 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 		records, extra, err = plugin.SRV(r, zone, state, opt)
 	case dns.TypeSOA:
 		records, err = plugin.SOA(r, zone, state, opt)
+	case dns.TypeCAA, dns.TypeNAPTR, dnsutils.TypeSVCB, dnsutils.TypeHTTPS:
+		records, err = r.rawRecords(zone, state, opt)
 	case dns.TypeNS:
+		if signedZone != nil && state.Name() == signedZone.name {
+			records = []dns.RR{signedZone.ns(r.MinTTL(state))}
+			break
+		}
 		if state.Name() == zone {
 			records, extra, err = plugin.NS(r, zone, state, opt)
 			break
@@ -178,6 +200,19 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 		_, err = plugin.A(r, zone, state, nil, opt)
 	}
 
+	if signedZone != nil && err != errRefused {
+		// region-dns is authoritative for signed zones
+		if len(records) > 0 {
+			return r.writeSigned(signedZone, state, records, extra)
+		}
+		if err == nil || err == errCallNext || err == errNotFound {
+			return r.writeSignedNegative(signedZone, state)
+		}
+		// resolvers cache the signed negative answer as a proof of nonexistence,
+		// never send it when the records are unknown
+		return plugin.BackendError(r, zone, dns.RcodeServerFailure, state, err, opt)
+	}
+
 	if err == errCallNext {
 		if r.Fall.Through(state.Name()) {
 			return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, rmsg)
@@ -189,6 +224,11 @@ func (r *SRegionDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, rmsg *d
 		return plugin.BackendError(r, zone, dns.RcodeNameError, state, err, opt)
 	}
 
+	if err != nil {
+		log.Errorf("lookup %s %s: %v", state.Type(), state.Name(), err)
+		return plugin.BackendError(r, zone, dns.RcodeServerFailure, state, err, opt)
+	}
+
 	if len(records) == 0 {
 		return plugin.BackendError(r, zone, dns.RcodeNameError, state, err, opt)
 	}
@@ -367,18 +407,65 @@ func (r *SRegionDNS) Name() string {
 	return PluginName
 }
 
-func (r *SRegionDNS) queryLocalDnsRecords(req *recordRequest) []msg.Service {
-	var (
-		projId = req.ProjectId()
-		getTtl = func(ttl int64) uint32 {
-			if ttl == 0 {
-				return defaultTTL
-			}
-			return uint32(ttl)
+// rawRecords looks up the records whose rdata can not be expressed by msg.Service
+func (r *SRegionDNS) rawRecords(zone string, state request.Request, opt plugin.Options) ([]dns.RR, error) {
+	req := parseRequest(state)
+	if r.InCloudOnly && !req.srcInCloud {
+		// deny external request
+		return nil, errRefused
+	}
+	if !r.isMyDomain(req) {
+		rrs := r.queryLocalDnsRRs(req)
+		if len(rrs) > 0 {
+			return rrs, nil
 		}
-	)
+	}
+	// Do a fake A lookup, so we can distinguish between NODATA and NXDOMAIN
+	_, err := plugin.A(r, zone, state, nil, opt)
+	return nil, err
+}
+
+// txtRecords looks up the TXT records of local zones, e.g. acme dns-01
+// challenges, and falls back to the dns-version record
+func (r *SRegionDNS) txtRecords(zone string, state request.Request, opt plugin.Options) ([]dns.RR, error) {
+	req := parseRequest(state)
+	if (!r.InCloudOnly || req.srcInCloud) && !r.isMyDomain(req) {
+		rrs := r.queryLocalDnsRRs(req)
+		if len(rrs) > 0 {
+			return rrs, nil
+		}
+	}
+	return plugin.TXT(r, zone, state, opt)
+}
+
+func getTtl(ttl int64) uint32 {
+	if ttl == 0 {
+		return defaultTTL
+	}
+	return uint32(ttl)
+}
+
+func (r *SRegionDNS) queryLocalDnsRRs(req *recordRequest) []dns.RR {
+	records, err := models.DnsRecordManager.QueryDns(req.QuerySource(), req.Name(), req.Type())
+	if err != nil {
+		log.Errorf("QueryDns %s %s error: %v", req.Type(), req.Name(), err)
+		return nil
+	}
+	rrs := make([]dns.RR, 0, len(records))
+	for i := range records {
+		rr, err := dnsutils.NewRR(req.state.QName(), getTtl(records[i].TTL), req.Type(), records[i].DnsValue)
+		if err != nil {
+			log.Errorf("Invalid %s records %q: %v", req.Type(), records[i].DnsValue, err)
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs
+}
+
+func (r *SRegionDNS) queryLocalDnsRecords(req *recordRequest) []msg.Service {
 	recs := make([]msg.Service, 0)
-	records, err := models.DnsRecordManager.QueryDns(projId, req.Name(), req.Type())
+	records, err := models.DnsRecordManager.QueryDns(req.QuerySource(), req.Name(), req.Type())
 	if err != nil {
 		log.Errorf("QueryDns %s %s error: %v", req.Type(), req.Name(), err)
 		return nil
@@ -0,0 +1,389 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dns
+
+import (
+	"crypto"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/compute"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/compute/models"
+	"yunion.io/x/onecloud/pkg/util/dnsutils"
+)
+
+const (
+	// interval to reload signed zones and keys from region db
+	dnssecRefreshInterval = 30 * time.Second
+
+	// answers are signed online, signatures are valid from one hour ago
+	// to tolerate clock skew of resolvers
+	dnssecSignatureInception  = time.Hour
+	dnssecSignatureExpiration = 7 * 24 * time.Hour
+)
+
+type sDnssecKey struct {
+	models.SDnsZoneKey
+
+	dnskey *dns.DNSKEY
+	signer crypto.Signer
+}
+
+// sSignedZone is a local dns zone with dnssec enabled
+type sSignedZone struct {
+	id   string
+	name string
+	keys []*sDnssecKey
+	// record types of the names relative to the zone, for the NSEC type
+	// bitmaps, they are reloaded along with the zone
+	dnsTypes map[string][]uint16
+}
+
+type sDnssecZones struct {
+	lock  sync.RWMutex
+	zones map[string]*sSignedZone
+	// parsed keys are reused across refreshes
+	keys map[string]*sDnssecKey
+
+	refreshing  int32
+	refreshedAt time.Time
+}
+
+func newDnssecZones() *sDnssecZones {
+	return &sDnssecZones{
+		zones: map[string]*sSignedZone{},
+		keys:  map[string]*sDnssecKey{},
+	}
+}
+
+func (dz *sDnssecZones) refresh() error {
+	q := models.DnsZoneManager.Query().IsNullOrEmpty("manager_id").IsTrue("enabled").IsTrue("dnssec_enabled")
+	zones := []models.SDnsZone{}
+	err := db.FetchModelObjects(models.DnsZoneManager, q, &zones)
+	if err != nil {
+		return errors.Wrap(err, "fetch dnssec zones")
+	}
+	signedZones := map[string]*sSignedZone{}
+	keys := map[string]*sDnssecKey{}
+	for i := range zones {
+		zone := &sSignedZone{
+			id:   zones[i].Id,
+			name: dns.Fqdn(strings.ToLower(zones[i].Name)),
+		}
+		zone.dnsTypes, err = fetchZoneDnsTypes(zone.id)
+		if err != nil {
+			return errors.Wrapf(err, "fetch record types of %s", zones[i].Name)
+		}
+		dbKeys, err := zones[i].GetDnssecKeys()
+		if err != nil {
+			return errors.Wrapf(err, "GetDnssecKeys of %s", zones[i].Name)
+		}
+		for j := range dbKeys {
+			key := &sDnssecKey{SDnsZoneKey: dbKeys[j]}
+			dz.lock.RLock()
+			cached, ok := dz.keys[key.Id]
+			dz.lock.RUnlock()
+			if ok {
+				key.dnskey, key.signer = cached.dnskey, cached.signer
+			} else {
+				key.dnskey = key.GetDnskey(zone.name)
+				key.signer, err = key.GetSigner(zone.name)
+				if err != nil {
+					log.Errorf("dnssec key %s of %s: %v", key.Id, zone.name, err)
+					continue
+				}
+			}
+			keys[key.Id] = key
+			zone.keys = append(zone.keys, key)
+		}
+		signedZones[zone.name] = zone
+	}
+
+	dz.lock.Lock()
+	defer dz.lock.Unlock()
+
+	dz.zones = signedZones
+	dz.keys = keys
+	dz.refreshedAt = time.Now()
+	return nil
+}
+
+func fetchZoneDnsTypes(zoneId string) (map[string][]uint16, error) {
+	nameTypes, err := models.DnsRecordManager.QueryZoneDnsTypes(zoneId)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryZoneDnsTypes")
+	}
+	ret := make(map[string][]uint16, len(nameTypes))
+	for name, dnsTypes := range nameTypes {
+		for _, t := range dnsTypes {
+			if rrtype, ok := dnsutils.StringToType(t); ok {
+				ret[name] = append(ret[name], rrtype)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// getZone returns the signed zone of qname, zones are reloaded in
+// background so that queries are never blocked by the region db
+func (dz *sDnssecZones) getZone(qname string) *sSignedZone {
+	dz.lock.RLock()
+	refreshedAt := dz.refreshedAt
+	dz.lock.RUnlock()
+	if time.Since(refreshedAt) > dnssecRefreshInterval && atomic.CompareAndSwapInt32(&dz.refreshing, 0, 1) {
+		refresh := func() {
+			defer atomic.StoreInt32(&dz.refreshing, 0)
+			if err := dz.refresh(); err != nil {
+				log.Errorf("refresh dnssec zones: %v", err)
+			}
+		}
+		if refreshedAt.IsZero() {
+			refresh()
+		} else {
+			go refresh()
+		}
+	}
+
+	dz.lock.RLock()
+	defer dz.lock.RUnlock()
+
+	name := strings.ToLower(dns.Fqdn(qname))
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		if zone, ok := dz.zones[name[off:]]; ok {
+			return zone
+		}
+	}
+	return nil
+}
+
+func (z *sSignedZone) soa(serial, minTTL uint32) *dns.SOA {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   z.name,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    minTTL,
+		},
+		Ns:      defaultNSName + z.name,
+		Mbox:    "hostmaster." + z.name,
+		Serial:  serial,
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  minTTL,
+	}
+}
+
+// ns returns the NS record of the zone apex, the name server is the same
+// as the one of the SOA record
+func (z *sSignedZone) ns(ttl uint32) *dns.NS {
+	return &dns.NS{
+		Hdr: dns.RR_Header{
+			Name:   z.name,
+			Rrtype: dns.TypeNS,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Ns: defaultNSName + z.name,
+	}
+}
+
+func (z *sSignedZone) dnskeys(now time.Time) []dns.RR {
+	ret := []dns.RR{}
+	for _, key := range z.keys {
+		if key.IsPublished(now) {
+			ret = append(ret, dns.Copy(key.dnskey))
+		}
+	}
+	return ret
+}
+
+// rrsets groups records by owner name and type, the order of records is kept
+func rrsets(rrs []dns.RR) [][]dns.RR {
+	ret := [][]dns.RR{}
+	idx := map[string]int{}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		key := strings.ToLower(hdr.Name) + "/" + dns.TypeToString[hdr.Rrtype]
+		if i, ok := idx[key]; ok {
+			ret[i] = append(ret[i], rr)
+			continue
+		}
+		idx[key] = len(ret)
+		ret = append(ret, []dns.RR{rr})
+	}
+	return ret
+}
+
+// sign appends RRSIGs to the rrsets in the zone, DNSKEY rrset is signed by
+// KSKs and others by ZSKs
+func (z *sSignedZone) sign(rrs []dns.RR, now time.Time) []dns.RR {
+	ret := make([]dns.RR, 0, len(rrs)*2)
+	for _, set := range rrsets(rrs) {
+		ret = append(ret, set...)
+		hdr := set[0].Header()
+		if hdr.Rrtype == dns.TypeRRSIG || hdr.Rrtype == dns.TypeOPT || !dns.IsSubDomain(z.name, strings.ToLower(hdr.Name)) {
+			continue
+		}
+		// records of a rrset must have the same ttl to be validated
+		ttl := hdr.Ttl
+		for _, rr := range set {
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		for _, rr := range set {
+			rr.Header().Ttl = ttl
+		}
+		isKsk := hdr.Rrtype == dns.TypeDNSKEY
+		for _, key := range z.keys {
+			if (key.KeyType == api.DNSSEC_KEY_TYPE_KSK) != isKsk || !key.IsActive(now) {
+				continue
+			}
+			sig := &dns.RRSIG{
+				Hdr: dns.RR_Header{
+					Name:   hdr.Name,
+					Rrtype: dns.TypeRRSIG,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				Algorithm:  key.dnskey.Algorithm,
+				KeyTag:     key.dnskey.KeyTag(),
+				SignerName: z.name,
+				Inception:  uint32(now.Add(-dnssecSignatureInception).Unix()),
+				Expiration: uint32(now.Add(dnssecSignatureExpiration).Unix()),
+			}
+			err := sig.Sign(key.signer, set)
+			if err != nil {
+				log.Errorf("sign %s %s by key %d: %v", hdr.Name, dns.TypeToString[hdr.Rrtype], sig.KeyTag, err)
+				continue
+			}
+			ret = append(ret, sig)
+		}
+	}
+	return ret
+}
+
+// nsec returns the minimal NSEC covering only qname, aka "black lies",
+// so that no other names in the zone can be walked. The type bitmap lists
+// the types existing at qname except qtype
+func (z *sSignedZone) nsec(qname string, qtype uint16, ttl uint32) *dns.NSEC {
+	qname = strings.ToLower(dns.Fqdn(qname))
+	types := map[uint16]bool{
+		dns.TypeRRSIG: true,
+		dns.TypeNSEC:  true,
+	}
+	if qname == z.name {
+		types[dns.TypeSOA] = true
+		types[dns.TypeNS] = true
+		types[dns.TypeDNSKEY] = true
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(qname, z.name), ".")
+	if len(name) == 0 {
+		name = "@"
+	}
+	for _, rrtype := range z.dnsTypes[name] {
+		types[rrtype] = true
+	}
+	delete(types, qtype)
+	bitmap := make([]uint16, 0, len(types))
+	for t := range types {
+		bitmap = append(bitmap, t)
+	}
+	sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+	return &dns.NSEC{
+		Hdr: dns.RR_Header{
+			Name:   qname,
+			Rrtype: dns.TypeNSEC,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		NextDomain: "\\000." + qname,
+		TypeBitMap: bitmap,
+	}
+}
+
+// writeSigned writes a positive answer of a signed zone
+func (r *SRegionDNS) writeSigned(zone *sSignedZone, state request.Request, answer, extra []dns.RR) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative, m.RecursionAvailable = true, true
+	m.Answer = answer
+	m.Extra = extra
+	if state.Do() {
+		now := time.Now()
+		m.Answer = zone.sign(m.Answer, now)
+		m.Extra = zone.sign(m.Extra, now)
+	}
+	state.SizeAndDo(m)
+	m = state.Scrub(m)
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// writeSignedNegative writes a NODATA answer for both non-existing names and
+// types, a signed NXDOMAIN would require NSEC to cover the real neighbours
+func (r *SRegionDNS) writeSignedNegative(zone *sSignedZone, state request.Request) (int, error) {
+	minTTL := r.MinTTL(state)
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative, m.RecursionAvailable = true, true
+	m.Ns = []dns.RR{zone.soa(r.Serial(state), minTTL)}
+	if state.Do() {
+		m.Ns = append(m.Ns, zone.nsec(state.Name(), state.QType(), minTTL))
+		m.Ns = zone.sign(m.Ns, time.Now())
+	}
+	state.SizeAndDo(m)
+	m = state.Scrub(m)
+	state.W.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// serveSigned answers the queries of signed zones which can not be looked
+// up from region records, returns false if the query should be looked up as usual
+func (r *SRegionDNS) serveSigned(zone *sSignedZone, state request.Request) (bool, int, error) {
+	var (
+		rcode int
+		err   error
+	)
+	switch qtype := state.QType(); {
+	case qtype == dns.TypeDNSKEY && state.Name() == zone.name:
+		rcode, err = r.writeSigned(zone, state, zone.dnskeys(time.Now()), nil)
+	case qtype == dns.TypeSOA && state.Name() == zone.name:
+		rcode, err = r.writeSigned(zone, state, []dns.RR{zone.soa(r.Serial(state), r.MinTTL(state))}, nil)
+	case qtype == dns.TypeNS && state.Name() == zone.name:
+		// looked up as usual and signed with the answer
+		return false, 0, nil
+	case qtype == dns.TypeDNSKEY || qtype == dns.TypeSOA || qtype == dns.TypeNS:
+		rcode, err = r.writeSignedNegative(zone, state)
+	default:
+		if _, ok := DNSTypeMap[qtype]; ok {
+			return false, 0, nil
+		}
+		// validating resolvers treat a refused answer of signed zones as bogus
+		rcode, err = r.writeSignedNegative(zone, state)
+	}
+	return true, rcode, err
+}
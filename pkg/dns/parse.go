@@ -15,11 +15,14 @@
 package dns
 
 import (
+	"database/sql"
 	"strings"
 
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
 
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/tristate"
 
 	"yunion.io/x/onecloud/pkg/compute/models"
@@ -30,6 +33,7 @@ type recordRequest struct {
 	// domainSegs   []string
 	srcProjectId string
 	srcInCloud   bool
+	srcVpcIds    []string
 	// network      *models.SNetwork
 }
 
@@ -48,12 +52,28 @@ func parseRequest(state request.Request) *recordRequest {
 	// Order matters here, we want to find the srcIP project as accurately
 	// as possible
 
-	if guest := models.GuestnetworkManager.GetGuestByAddress(srcIP, ""); guest != nil {
-		r.srcProjectId = guest.ProjectId
+	// the project and vpc are resolved from the guest nic sending the query,
+	// an address shared by the nics of overlapping vpcs matches none of them
+	gn, err := models.GuestnetworkManager.GetGuestnetworkByAddress(srcIP)
+	if err != nil && errors.Cause(err) != sql.ErrNoRows {
+		log.Debugf("GetGuestnetworkByAddress %s: %v", srcIP, err)
 		r.srcInCloud = true
-	} else if _, err := models.NetworkManager.GetOnPremiseNetworkOfIP(srcIP, "", tristate.None); err == nil {
+	} else if gn != nil {
+		r.srcInCloud = true
+		if guest := gn.GetGuest(); guest != nil {
+			r.srcProjectId = guest.ProjectId
+		}
+		if network, _ := gn.GetNetwork(); network != nil {
+			if vpc, _ := network.GetVpc(); vpc != nil {
+				r.srcVpcIds = []string{vpc.Id}
+			}
+		}
+	} else if network, err := models.NetworkManager.GetOnPremiseNetworkOfIP(srcIP, "", tristate.None); err == nil {
 		// r.srcProjectId = "" // no specific project
 		r.srcInCloud = true
+		if vpc, _ := network.GetVpc(); vpc != nil {
+			r.srcVpcIds = []string{vpc.Id}
+		}
 	}
 	return r
 }
@@ -103,6 +123,15 @@ func (r recordRequest) SrcInCloud() bool {
 	return r.srcInCloud
 }
 
+// QuerySource returns the source used to pick the view of dns records
+func (r recordRequest) QuerySource() *models.SDnsQuerySource {
+	return &models.SDnsQuerySource{
+		ProjectId: r.srcProjectId,
+		Ip:        r.SrcIP4(),
+		VpcIds:    r.srcVpcIds,
+	}
+}
+
 /*type K8sQueryInfo struct {
 	ServiceName string
 	Namespace   string
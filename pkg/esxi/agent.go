@@ -138,6 +138,7 @@ func Start(app *appsrv.Application) error {
 		return err
 	}
 	EsxiAgent.AddImageCacheHandler("", app)
+	cronman.AddCronJobHandler("", app)
 	return nil
 }
 
@@ -0,0 +1,276 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/shirou/gopsutil/mem"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/hostman/monitor"
+	"yunion.io/x/onecloud/pkg/hostman/options"
+)
+
+// SGuestBalloonState is the latest memory balloon state of a running guest
+type SGuestBalloonState struct {
+	// actual memory size of the guest in MB, excluding the memory held by balloon
+	ActualMb int64
+	// guest memory stats reported by the balloon driver, nil if not reported yet
+	Stats *monitor.BalloonStats
+	// target memory size in MB requested by the reclaimer, 0 means never requested
+	TargetMb  int64
+	UpdatedAt time.Time
+}
+
+func (s *SKVMGuestInstance) GetBalloonState() *SGuestBalloonState {
+	state, _ := s.balloonState.Load().(*SGuestBalloonState)
+	return state
+}
+
+func (s *SKVMGuestInstance) updateBalloonState(update func(state *SGuestBalloonState)) {
+	s.balloonLock.Lock()
+	defer s.balloonLock.Unlock()
+	state := SGuestBalloonState{}
+	if old := s.GetBalloonState(); old != nil {
+		state = *old
+	}
+	update(&state)
+	state.UpdatedAt = time.Now()
+	s.balloonState.Store(&state)
+}
+
+func (s *SKVMGuestInstance) isBalloonAvailable() bool {
+	return s.Desc != nil && s.Desc.Balloon != nil && s.IsRunning() && s.IsMonitorAlive()
+}
+
+func (s *SKVMGuestInstance) refreshBalloonState() {
+	if !s.isBalloonAvailable() {
+		return
+	}
+	s.Monitor.QueryBalloon(func(actualMb int64, err error) {
+		if err != nil {
+			log.Debugf("guest %s query balloon: %s", s.GetName(), err)
+			return
+		}
+		s.updateBalloonState(func(state *SGuestBalloonState) {
+			state.ActualMb = actualMb
+		})
+	})
+	s.Monitor.GetBalloonStats(s.Desc.Balloon.Id, func(stats *monitor.BalloonStats, err error) {
+		if err != nil {
+			log.Debugf("guest %s get balloon stats: %s", s.GetName(), err)
+			return
+		}
+		s.updateBalloonState(func(state *SGuestBalloonState) {
+			state.Stats = stats
+		})
+	})
+}
+
+func (s *SKVMGuestInstance) setBalloonTarget(targetMb int64) {
+	if !s.isBalloonAvailable() {
+		return
+	}
+	s.Monitor.SetBalloon(targetMb, func(res string) {
+		if len(res) > 0 {
+			log.Errorf("guest %s set balloon to %dMB: %s", s.GetName(), targetMb, res)
+			return
+		}
+		log.Infof("guest %s set balloon to %dMB", s.GetName(), targetMb)
+		s.updateBalloonState(func(state *SGuestBalloonState) {
+			state.TargetMb = targetMb
+		})
+	})
+}
+
+// StartMemoryBalloonManager polls the balloon stats of the guests periodically,
+// and reclaims memory from idle guests when the host memory is overcommitted
+func (m *SGuestManager) StartMemoryBalloonManager() {
+	interval := options.HostOptions.BalloonStatsPollingIntervalSeconds
+	if interval <= 0 {
+		interval = 10
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				debug.PrintStack()
+				log.Errorf("Memory balloon manager failed %s", r)
+			}
+		}()
+		var lastReclaim time.Time
+		for {
+			time.Sleep(time.Duration(interval) * time.Second)
+
+			m.refreshBalloonStates()
+			if options.HostOptions.EnableMemoryBalloonReclaim &&
+				time.Since(lastReclaim) >= time.Duration(options.HostOptions.MemoryBalloonReclaimIntervalSeconds)*time.Second {
+				lastReclaim = time.Now()
+				m.balloonReclaim()
+			}
+		}
+	}()
+}
+
+func (m *SGuestManager) refreshBalloonStates() {
+	m.Servers.Range(func(k, v interface{}) bool {
+		if guest, ok := v.(*SKVMGuestInstance); ok {
+			guest.refreshBalloonState()
+		}
+		return true
+	})
+}
+
+func (m *SGuestManager) balloonReclaim() {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		log.Errorf("balloon reclaim get host memory: %s", err)
+		return
+	}
+	guestMap := map[string]*SKVMGuestInstance{}
+	guests := []sBalloonGuest{}
+	m.Servers.Range(func(k, v interface{}) bool {
+		guest, ok := v.(*SKVMGuestInstance)
+		if !ok || !guest.isBalloonAvailable() {
+			return true
+		}
+		// memory of guests with passthrough devices is pinned by vfio
+		if len(guest.Desc.IsolatedDevices) > 0 {
+			return true
+		}
+		state := guest.GetBalloonState()
+		if state == nil || state.ActualMb <= 0 || state.Stats == nil || state.Stats.LastUpdate == 0 {
+			// balloon driver not loaded in guest
+			return true
+		}
+		guestMap[guest.GetId()] = guest
+		guests = append(guests, sBalloonGuest{
+			Id:       guest.GetId(),
+			MemMb:    guest.Desc.Mem,
+			ActualMb: state.ActualMb,
+			FreeMb:   getBalloonGuestFreeMb(state.Stats),
+		})
+		return true
+	})
+	policy := sBalloonPolicy{
+		ThresholdPercent: options.HostOptions.MemoryBalloonReclaimThresholdPercent,
+		IdleFreePercent:  options.HostOptions.MemoryBalloonIdleFreePercent,
+		MinGuestPercent:  options.HostOptions.MemoryBalloonMinGuestPercent,
+	}
+	targets := policy.calcTargets(int64(vm.Total/1024/1024), int64(vm.Available/1024/1024), guests)
+	for id, targetMb := range targets {
+		guestMap[id].setBalloonTarget(targetMb)
+	}
+}
+
+// free memory in MB reported by guest, prefer available memory which includes
+// the reclaimable page caches
+func getBalloonGuestFreeMb(stats *monitor.BalloonStats) int64 {
+	free := stats.Stats.AvailableMemory
+	if free < 0 {
+		free = stats.Stats.FreeMemory
+	}
+	if free < 0 {
+		return 0
+	}
+	return free / 1024 / 1024
+}
+
+type sBalloonGuest struct {
+	Id string
+	// configured memory size
+	MemMb    int64
+	ActualMb int64
+	FreeMb   int64
+}
+
+func (g sBalloonGuest) isIdle(idleFreePercent int) bool {
+	return g.FreeMb*100 >= g.ActualMb*int64(idleFreePercent)
+}
+
+type sBalloonPolicy struct {
+	ThresholdPercent int
+	IdleFreePercent  int
+	MinGuestPercent  int
+}
+
+// calcTargets returns the balloon targets in MB of the guests to change.
+// When host available memory is below the threshold, the idle guests give up
+// half of their free memory each round, the most idle first, until the
+// shortage is filled. When host available memory is above twice the threshold,
+// the ballooned guests are given memory back, the busy ones first.
+func (p sBalloonPolicy) calcTargets(hostTotalMb, hostAvailMb int64, guests []sBalloonGuest) map[string]int64 {
+	targets := map[string]int64{}
+	thresholdMb := hostTotalMb * int64(p.ThresholdPercent) / 100
+	if thresholdMb <= 0 {
+		return targets
+	}
+	if hostAvailMb < thresholdMb {
+		need := thresholdMb - hostAvailMb
+		idles := []sBalloonGuest{}
+		for _, g := range guests {
+			if g.isIdle(p.IdleFreePercent) {
+				idles = append(idles, g)
+			}
+		}
+		sort.Slice(idles, func(i, j int) bool {
+			return idles[i].FreeMb*idles[j].ActualMb > idles[j].FreeMb*idles[i].ActualMb
+		})
+		for _, g := range idles {
+			if need <= 0 {
+				break
+			}
+			minMb := g.MemMb * int64(p.MinGuestPercent) / 100
+			reclaim := g.FreeMb / 2
+			if reclaim > g.ActualMb-minMb {
+				reclaim = g.ActualMb - minMb
+			}
+			if reclaim > need {
+				reclaim = need
+			}
+			if reclaim <= 0 {
+				continue
+			}
+			targets[g.Id] = g.ActualMb - reclaim
+			need -= reclaim
+		}
+	} else if hostAvailMb > thresholdMb*2 {
+		budget := hostAvailMb - thresholdMb*2
+		ballooned := []sBalloonGuest{}
+		for _, g := range guests {
+			if g.ActualMb < g.MemMb {
+				ballooned = append(ballooned, g)
+			}
+		}
+		sort.SliceStable(ballooned, func(i, j int) bool {
+			return !ballooned[i].isIdle(p.IdleFreePercent) && ballooned[j].isIdle(p.IdleFreePercent)
+		})
+		for _, g := range ballooned {
+			if budget <= 0 {
+				break
+			}
+			release := g.MemMb - g.ActualMb
+			if release > budget {
+				release = budget
+			}
+			targets[g.Id] = g.ActualMb + release
+			budget -= release
+		}
+	}
+	return targets
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package guestman
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBalloonPolicyCalcTargets(t *testing.T) {
+	policy := sBalloonPolicy{
+		ThresholdPercent: 15,
+		IdleFreePercent:  40,
+		MinGuestPercent:  50,
+	}
+	cases := []struct {
+		name        string
+		hostTotalMb int64
+		hostAvailMb int64
+		guests      []sBalloonGuest
+		want        map[string]int64
+	}{
+		{
+			name:        "enough memory",
+			hostTotalMb: 100000,
+			hostAvailMb: 20000,
+			guests: []sBalloonGuest{
+				{Id: "idle", MemMb: 8192, ActualMb: 8192, FreeMb: 6000},
+			},
+			want: map[string]int64{},
+		},
+		{
+			name:        "reclaim from most idle guest first",
+			hostTotalMb: 100000,
+			hostAvailMb: 14000,
+			guests: []sBalloonGuest{
+				{Id: "busy", MemMb: 8192, ActualMb: 8192, FreeMb: 1000},
+				{Id: "idle", MemMb: 8192, ActualMb: 8192, FreeMb: 4000},
+				{Id: "idlest", MemMb: 8192, ActualMb: 8192, FreeMb: 6000},
+			},
+			want: map[string]int64{"idlest": 7192},
+		},
+		{
+			name:        "reclaim from several guests",
+			hostTotalMb: 100000,
+			hostAvailMb: 10000,
+			guests: []sBalloonGuest{
+				{Id: "busy", MemMb: 8192, ActualMb: 8192, FreeMb: 1000},
+				{Id: "idle", MemMb: 8192, ActualMb: 8192, FreeMb: 4000},
+				{Id: "idlest", MemMb: 8192, ActualMb: 8192, FreeMb: 6000},
+			},
+			want: map[string]int64{"idlest": 5192, "idle": 6192},
+		},
+		{
+			name:        "keep minimal guest memory",
+			hostTotalMb: 100000,
+			hostAvailMb: 5000,
+			guests: []sBalloonGuest{
+				{Id: "idle", MemMb: 8192, ActualMb: 4500, FreeMb: 4000},
+			},
+			want: map[string]int64{"idle": 4096},
+		},
+		{
+			name:        "between thresholds",
+			hostTotalMb: 100000,
+			hostAvailMb: 25000,
+			guests: []sBalloonGuest{
+				{Id: "ballooned", MemMb: 8192, ActualMb: 4096, FreeMb: 3000},
+			},
+			want: map[string]int64{},
+		},
+		{
+			name:        "give memory back to busy guest first",
+			hostTotalMb: 100000,
+			hostAvailMb: 35000,
+			guests: []sBalloonGuest{
+				{Id: "idle", MemMb: 8192, ActualMb: 4096, FreeMb: 3000},
+				{Id: "busy", MemMb: 8192, ActualMb: 4096, FreeMb: 100},
+				{Id: "full", MemMb: 8192, ActualMb: 8192, FreeMb: 100},
+			},
+			want: map[string]int64{"busy": 8192, "idle": 5000},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := policy.calcTargets(c.hostTotalMb, c.hostAvailMb, c.guests)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
@@ -127,6 +127,7 @@ type SGuestHardwareDesc struct {
 
 	// Random Number Generator Device
 	Rng       *SGuestRng       `json:",omitempty"`
+	Balloon   *SGuestBalloon   `json:",omitempty"`
 	Qga       *SGuestQga       `json:",omitempty"`
 	Pvpanic   *SGuestPvpanic   `json:",omitempty"`
 	IsaSerial *SGuestIsaSerial `json:",omitempty"`
@@ -246,6 +247,10 @@ type SGuestRng struct {
 	RngRandom *Object
 }
 
+type SGuestBalloon struct {
+	*PCIDevice `json:",omitempty"`
+}
+
 type SoundCard struct {
 	*PCIDevice `json:",omitempty"`
 	Codec      *Codec
@@ -73,6 +73,7 @@ func AddGuestTaskHandler(prefix string, app *appsrv.Application) {
 			"io-throttle":              guestIoThrottle,
 			"snapshot":                 guestSnapshot,
 			"delete-snapshot":          guestDeleteSnapshot,
+			"disk-backup":              guestDiskBackup,
 			"reload-disk-snapshot":     guestReloadDiskSnapshot,
 			"src-prepare-migrate":      guestSrcPrepareMigrate,
 			"dest-prepare-migrate":     guestDestPrepareMigrate,
@@ -666,6 +667,53 @@ func guestSnapshot(ctx context.Context, userCred mcclient.TokenCredential, sid s
 	return nil, nil
 }
 
+func guestDiskBackup(ctx context.Context, userCred mcclient.TokenCredential, sid string, body jsonutils.JSONObject) (interface{}, error) {
+	backupInfo := &storageman.SDiskBackup{}
+	err := body.Unmarshal(backupInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "JsonUnmarshal")
+	}
+	if len(backupInfo.BackupId) == 0 {
+		return nil, httperrors.NewMissingParameterError("backup_id")
+	}
+	if len(backupInfo.BackupStorageId) == 0 {
+		return nil, httperrors.NewMissingParameterError("backup_storage_id")
+	}
+	if len(backupInfo.Bitmap) == 0 {
+		return nil, httperrors.NewMissingParameterError("bitmap")
+	}
+	diskId, err := body.GetString("disk_id")
+	if err != nil {
+		return nil, httperrors.NewMissingParameterError("disk_id")
+	}
+	guest, ok := guestman.GetGuestManager().GetServer(sid)
+	if !ok {
+		return nil, httperrors.NewNotFoundError("guest %s not found", sid)
+	}
+
+	var disk storageman.IDisk
+	disks := guest.GetDesc().Disks
+	for _, d := range disks {
+		if diskId == d.DiskId {
+			disk, err = storageman.GetManager().GetDiskById(diskId)
+			if err != nil {
+				return nil, errors.Wrapf(err, "GetDiskById(%s)", diskId)
+			}
+			break
+		}
+	}
+	if disk == nil {
+		return nil, httperrors.NewNotFoundError("Disk not found")
+	}
+	backupInfo.UserCred = userCred
+	hostutils.DelayTask(ctx, guestman.GetGuestManager().DoDiskBitmapBackup, &guestman.SDiskBitmapBackup{
+		SDiskBackup: backupInfo,
+		Sid:         sid,
+		Disk:        disk,
+	})
+	return nil, nil
+}
+
 func guestDeleteSnapshot(ctx context.Context, userCred mcclient.TokenCredential, sid string, body jsonutils.JSONObject) (interface{}, error) {
 	deleteSnapshot, err := body.GetString("delete_snapshot")
 	if err != nil {
@@ -142,6 +142,12 @@ type SDiskBackup struct {
 	Disk       storageman.IDisk
 }
 
+type SDiskBitmapBackup struct {
+	*storageman.SDiskBackup
+	Sid  string
+	Disk storageman.IDisk
+}
+
 type SDeleteDiskSnapshot struct {
 	Sid             string
 	DeleteSnapshot  string
@@ -347,6 +347,7 @@ func (m *SGuestManager) OnLoadExistingGuestsComplete() {
 	}
 
 	go m.verifyDirtyServers()
+	m.StartMemoryBalloonManager()
 
 	if !options.HostOptions.EnableCpuBinding {
 		m.ClenaupCpuset()
@@ -1255,6 +1256,18 @@ func (m *SGuestManager) DoSnapshot(ctx context.Context, params interface{}) (jso
 	return guest.DoSnapshot(ctx, snapshotParams)
 }
 
+func (m *SGuestManager) DoDiskBitmapBackup(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
+	backupParams, ok := params.(*SDiskBitmapBackup)
+	if !ok {
+		return nil, hostutils.ParamsError
+	}
+	guest, ok := m.GetKVMServer(backupParams.Sid)
+	if !ok {
+		return nil, httperrors.NewNotFoundError("guest %s not found", backupParams.Sid)
+	}
+	return guest.ExecDiskBitmapBackupTask(ctx, backupParams)
+}
+
 func (m *SGuestManager) DeleteSnapshot(ctx context.Context, params interface{}) (jsonutils.JSONObject, error) {
 	delParams, ok := params.(*SDeleteDiskSnapshot)
 	if !ok {
@@ -3016,3 +3016,161 @@ func (t *SGuestLiveChangeDisk) onReopenImageSuccess(res string) {
 	}
 	hostutils.TaskComplete(t.ctx, jsonutils.Marshal(resp))
 }
+
+/**
+ *  GuestDiskBitmapBackupTask
+**/
+
+// SGuestDiskBitmapBackupTask backups a disk of the running guest by drive
+// backup with the dirty bitmap of the disk, a full backup recreates the
+// bitmap, an incremental or differential one copies the dirty clusters only
+type SGuestDiskBitmapBackupTask struct {
+	*SKVMGuestInstance
+
+	ctx    context.Context
+	params *SDiskBitmapBackup
+
+	drive      string
+	format     string
+	sizeMb     int
+	backupMode string
+	syncMode   string
+	tmpDir     string
+	targetPath string
+}
+
+func NewGuestDiskBitmapBackupTask(ctx context.Context, guest *SKVMGuestInstance, params *SDiskBitmapBackup) (*SGuestDiskBitmapBackupTask, error) {
+	task := &SGuestDiskBitmapBackupTask{
+		SKVMGuestInstance: guest,
+		ctx:               ctx,
+		params:            params,
+	}
+	for _, disk := range guest.Desc.Disks {
+		if disk.DiskId == params.Disk.GetId() {
+			task.drive = fmt.Sprintf("drive_%d", disk.Index)
+			task.format = disk.Format
+			task.sizeMb = disk.Size
+			break
+		}
+	}
+	if len(task.drive) == 0 {
+		return nil, errors.Errorf("failed found disk %s of guest %s", params.Disk.GetId(), guest.GetName())
+	}
+	task.backupMode = params.BackupMode
+	switch params.BackupMode {
+	case api.DISK_BACKUP_MODE_FULL:
+		task.syncMode = "full"
+	case api.DISK_BACKUP_MODE_INCREMENTAL:
+		task.syncMode = "incremental"
+	case api.DISK_BACKUP_MODE_DIFFERENTIAL:
+		task.syncMode = "bitmap"
+	default:
+		return nil, errors.Errorf("unsupported backup mode %s", params.BackupMode)
+	}
+	return task, nil
+}
+
+func (s *SGuestDiskBitmapBackupTask) Start() {
+	var err error
+	s.tmpDir, err = storageman.EnsureBackupDir()
+	if err != nil {
+		hostutils.TaskFailed(s.ctx, fmt.Sprintf("EnsureBackupDir: %s", err))
+		return
+	}
+	s.targetPath = path.Join(s.tmpDir, s.params.BackupId)
+	img, err := qemuimg.NewQemuImage(s.targetPath)
+	if err != nil {
+		s.taskFailed(fmt.Sprintf("NewQemuImage %s: %s", s.targetPath, err))
+		return
+	}
+	err = img.CreateQcow2(s.sizeMb, true, "", "", "", "")
+	if err != nil {
+		s.taskFailed(fmt.Sprintf("create backup image %s: %s", s.targetPath, err))
+		return
+	}
+	if _, loaded := s.diskBackupTasks.LoadOrStore(s.drive, s); loaded {
+		s.taskFailed(fmt.Sprintf("disk %s is in backup", s.params.Disk.GetId()))
+		return
+	}
+	if s.syncMode == "full" {
+		s.startFullBackup()
+	} else {
+		// the bitmap is gone if the guest was restarted with a raw image or
+		// by a qemu without persistent bitmaps, check it before backup
+		s.Monitor.GetBlocks(s.onGetBlocks)
+	}
+}
+
+func (s *SGuestDiskBitmapBackupTask) onGetBlocks(blocks []monitor.QemuBlock) {
+	if blocks == nil {
+		s.diskBackupTasks.Delete(s.drive)
+		s.taskFailed(fmt.Sprintf("query block of %s failed", s.GetName()))
+		return
+	}
+	for i := range blocks {
+		if blocks[i].Device != s.drive {
+			continue
+		}
+		bitmap := blocks[i].GetDirtyBitmap(s.params.Bitmap)
+		if bitmap != nil && bitmap.IsUsable() {
+			s.startBackup()
+			return
+		}
+		log.Warningf("dirty bitmap %s of guest %s drive %s is missing or unusable, take full backup instead of %s", s.params.Bitmap, s.GetName(), s.drive, s.backupMode)
+		s.backupMode = api.DISK_BACKUP_MODE_FULL
+		s.syncMode = "full"
+		s.startFullBackup()
+		return
+	}
+	s.diskBackupTasks.Delete(s.drive)
+	s.taskFailed(fmt.Sprintf("drive %s of guest %s not found", s.drive, s.GetName()))
+}
+
+func (s *SGuestDiskBitmapBackupTask) startFullBackup() {
+	// drop the bitmap of the previous backup chain, it is recreated along with the full backup
+	s.Monitor.BlockDirtyBitmapRemove(s.drive, s.params.Bitmap, func(res string) {
+		if len(res) > 0 {
+			log.Infof("remove dirty bitmap %s of %s: %s", s.params.Bitmap, s.drive, res)
+		}
+		s.startBackup()
+	})
+}
+
+func (s *SGuestDiskBitmapBackupTask) startBackup() {
+	// only bitmaps on qcow2 images survive the restart of the guest
+	persistent := s.format == "qcow2"
+	s.Monitor.DriveBackupWithBitmap(s.onBackupStarted, s.drive, s.targetPath, s.syncMode, "qcow2", s.params.Bitmap, persistent)
+}
+
+func (s *SGuestDiskBitmapBackupTask) onBackupStarted(res string) {
+	if len(res) > 0 {
+		s.diskBackupTasks.Delete(s.drive)
+		s.taskFailed(fmt.Sprintf("drive backup %s: %s", s.drive, res))
+		return
+	}
+	log.Infof("guest %s drive %s %s backup started", s.GetName(), s.drive, s.params.BackupMode)
+}
+
+func (s *SGuestDiskBitmapBackupTask) onBackupJobCompleted(errMsg string) {
+	s.diskBackupTasks.Delete(s.drive)
+	if len(errMsg) > 0 {
+		s.taskFailed(fmt.Sprintf("drive backup %s: %s", s.drive, errMsg))
+		return
+	}
+	sizeMb, err := storageman.SaveBitmapBackup(s.ctx, s.targetPath, s.params.SDiskBackup)
+	storageman.CleanupDirOrFile(s.tmpDir)
+	if err != nil {
+		hostutils.TaskFailed(s.ctx, fmt.Sprintf("SaveBitmapBackup: %s", err))
+		return
+	}
+	data := jsonutils.NewDict()
+	data.Set("size_mb", jsonutils.NewInt(int64(sizeMb)))
+	// the mode actually taken, a full backup starts a new backup chain
+	data.Set("backup_mode", jsonutils.NewString(s.backupMode))
+	hostutils.TaskComplete(s.ctx, data)
+}
+
+func (s *SGuestDiskBitmapBackupTask) taskFailed(reason string) {
+	storageman.CleanupDirOrFile(s.tmpDir)
+	hostutils.TaskFailed(s.ctx, reason)
+}
@@ -22,6 +22,7 @@ import (
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/hostman/guestman/desc"
 	"yunion.io/x/onecloud/pkg/hostman/guestman/qemu"
 	"yunion.io/x/onecloud/pkg/hostman/monitor"
@@ -96,6 +97,7 @@ func (s *SKVMGuestInstance) initGuestDevicesDesc(pciRoot, pciBridge *desc.PCICon
 	s.initIsolatedDevices(pciRoot, pciBridge)
 	s.initUsbController(pciRoot)
 	s.initRandomDevice(pciRoot, options.HostOptions.EnableVirtioRngDevice)
+	s.initBalloonDevice(pciRoot, s.isMemoryBalloonEnabled())
 	s.initQgaDesc()
 	s.initPvpanicDesc()
 	s.initIsaSerialDesc()
@@ -278,6 +280,40 @@ func (s *SKVMGuestInstance) initRandomDevice(pciRoot *desc.PCIController, enable
 	}
 }
 
+func (s *SKVMGuestInstance) isMemoryBalloonEnabled() bool {
+	// memory backed by hugepages can't be reclaimed by balloon
+	if s.manager.host.IsHugepagesEnabled() {
+		return false
+	}
+	switch s.Desc.Metadata[computeapi.VM_METADATA_ENABLE_MEMORY_BALLOON] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return options.HostOptions.EnableVirtioBalloonDevice
+}
+
+func (s *SKVMGuestInstance) initBalloonDevice(pciRoot *desc.PCIController, enableBalloon bool) {
+	if !enableBalloon {
+		return
+	}
+
+	s.Desc.Balloon = &desc.SGuestBalloon{
+		PCIDevice: desc.NewPCIDevice(pciRoot.CType, "virtio-balloon-pci", "balloon0"),
+	}
+	s.Desc.Balloon.Options = map[string]string{
+		// give memory back to guest instead of triggering oom killer in guest
+		"deflate-on-oom": "on",
+	}
+	if options.HostOptions.BalloonStatsPollingIntervalSeconds > 0 {
+		s.Desc.Balloon.Options["guest-stats-polling-interval"] = strconv.Itoa(options.HostOptions.BalloonStatsPollingIntervalSeconds)
+	}
+	if options.HostOptions.EnableBalloonFreePageReporting {
+		s.Desc.Balloon.Options["free-page-reporting"] = "on"
+	}
+}
+
 func (s *SKVMGuestInstance) initUsbController(pciRoot *desc.PCIController) {
 	contType := s.getUsbControllerType()
 	s.Desc.Usb = &desc.UsbController{
@@ -714,6 +750,13 @@ func (s *SKVMGuestInstance) ensurePciAddresses() error {
 		}
 	}
 
+	if s.Desc.Balloon != nil {
+		err = s.ensureDevicePciAddress(s.Desc.Balloon.PCIDevice, -1, nil)
+		if err != nil {
+			return errors.Wrap(err, "ensure balloon device pci address")
+		}
+	}
+
 	anonymousPCIDevs := s.Desc.AnonymousPCIDevs[:0]
 	for i := 0; i < len(s.Desc.AnonymousPCIDevs); i++ {
 		if s.isMachineDefaultAddress(s.Desc.AnonymousPCIDevs[i].PCIAddr) {
@@ -880,6 +923,15 @@ func (s *SKVMGuestInstance) initGuestDescFromExistingGuest(
 			if err != nil {
 				return errors.Wrap(err, "ensure random device pci address")
 			}
+		case "balloon0":
+			if s.Desc.Balloon == nil {
+				s.initBalloonDevice(pciRoot, true)
+			}
+			s.Desc.Balloon.PCIAddr = pciAddr
+			err = s.ensureDevicePciAddress(s.Desc.Balloon.PCIDevice, -1, nil)
+			if err != nil {
+				return errors.Wrap(err, "ensure balloon device pci address")
+			}
 		case "usb":
 			if s.Desc.Usb == nil {
 				s.initUsbController(pciRoot)
@@ -1027,6 +1079,16 @@ func (s *SKVMGuestInstance) initGuestDescFromExistingGuest(
 					if err != nil {
 						return errors.Wrap(err, "ensure random device pci address")
 					}
+				case class == 255 && vendor == 6900 && device == 4098: // 0x00ff, 1af4:1002  memory balloon device (legacy)
+					if s.Desc.Balloon == nil {
+						s.initBalloonDevice(pciRoot, true)
+					}
+
+					s.Desc.Balloon.PCIAddr = pciAddr
+					err = s.ensureDevicePciAddress(s.Desc.Balloon.PCIDevice, -1, nil)
+					if err != nil {
+						return errors.Wrap(err, "ensure balloon device pci address")
+					}
 				case class == 1920 && vendor == 6900 && device == 4099: // 0x0780, 1af4:1003  console device (legacy)
 					if s.Desc.VirtioSerial == nil {
 						s.initVirtioSerial(pciRoot)
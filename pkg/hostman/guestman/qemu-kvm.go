@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -101,6 +102,11 @@ type SKVMInstanceRuntime struct {
 
 	StartupTask *SGuestResumeTask
 	MigrateTask *SGuestLiveMigrateTask
+	// drive -> *SGuestDiskBitmapBackupTask
+	diskBackupTasks sync.Map
+
+	balloonLock  sync.Mutex
+	balloonState atomic.Value
 
 	pciUninitialized bool
 	pciAddrs         *desc.SGuestPCIAddresses
@@ -1072,8 +1078,15 @@ func (s *SKVMGuestInstance) StartMonitor(ctx context.Context, cb func(), isScrip
 
 func (s *SKVMGuestInstance) onReceiveQMPEvent(event *monitor.Event) {
 	switch event.Event {
-	case `"BLOCK_JOB_READY"`, `"BLOCK_JOB_COMPLETED"`:
+	case `"BLOCK_JOB_READY"`:
 		s.eventBlockJobReady(event)
+	case `"BLOCK_JOB_COMPLETED"`, `"BLOCK_JOB_CANCELLED"`:
+		if s.eventDiskBackupCompleted(event) {
+			return
+		}
+		if event.Event == `"BLOCK_JOB_COMPLETED"` {
+			s.eventBlockJobReady(event)
+		}
 	case `"BLOCK_JOB_ERROR"`:
 		s.eventBlockJobError(event)
 	case `"GUEST_PANICKED"`:
@@ -1105,7 +1118,29 @@ func (s *SKVMGuestInstance) eventMigration(event *monitor.Event) {
 	}
 }
 
+// eventDiskBackupCompleted hands the end of a backup job over to the disk
+// backup task started it, returns false if the job is not a disk backup
+func (s *SKVMGuestInstance) eventDiskBackupCompleted(event *monitor.Event) bool {
+	device, _ := event.Data["device"].(string)
+	task, ok := s.diskBackupTasks.Load(device)
+	if !ok {
+		return false
+	}
+	errMsg, _ := event.Data["error"].(string)
+	if event.Event == `"BLOCK_JOB_CANCELLED"` {
+		errMsg = "backup job cancelled"
+	}
+	task.(*SGuestDiskBitmapBackupTask).onBackupJobCompleted(errMsg)
+	return true
+}
+
 func (s *SKVMGuestInstance) eventBlockJobError(event *monitor.Event) {
+	if device, _ := event.Data["device"].(string); len(device) > 0 {
+		if _, ok := s.diskBackupTasks.Load(device); ok {
+			// the backup job fails and reports the error on completion
+			return
+		}
+	}
 	if s.MigrateTask != nil {
 		s.MigrateTask.onMigrateReceivedBlockJobError(event.String())
 	} else {
@@ -3161,6 +3196,18 @@ func (s *SKVMGuestInstance) ExecDiskSnapshotTask(
 	}
 }
 
+func (s *SKVMGuestInstance) ExecDiskBitmapBackupTask(ctx context.Context, params *SDiskBitmapBackup) (jsonutils.JSONObject, error) {
+	if !s.IsRunning() || s.Monitor == nil {
+		return nil, errors.Errorf("guest %s is not running", s.GetName())
+	}
+	task, err := NewGuestDiskBitmapBackupTask(ctx, s, params)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewGuestDiskBitmapBackupTask")
+	}
+	task.Start()
+	return nil, nil
+}
+
 func (s *SKVMGuestInstance) StaticSaveSnapshot(
 	ctx context.Context, disk storageman.IDisk, snapshotId string,
 	encryptKey string, encFormat qemuimg.TEncryptFormat, encAlg seclib2.TSymEncAlg,
@@ -865,6 +865,11 @@ func GenerateStartOptions(
 		opts = append(opts, getRNGRandomOptions(input.GuestDesc.Rng)...)
 	}
 
+	// memory balloon device
+	if input.GuestDesc.Balloon != nil {
+		opts = append(opts, generatePCIDeviceOption(input.GuestDesc.Balloon.PCIDevice))
+	}
+
 	// serial device
 	if input.GuestDesc.IsaSerial != nil {
 		opts = append(opts, generateISASerialOptions(input.GuestDesc.IsaSerial)...)
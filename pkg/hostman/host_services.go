@@ -156,6 +156,7 @@ func (host *SHostService) initHandlers(app *appsrv.Application) {
 	podhandlers.AddPodHandlers("", app)
 	//kubehandlers.AddKubeAgentHandler("", app)
 	hosthandler.AddHostHandler("", app)
+	cronman.AddCronJobHandler("", app)
 
 	app_common.ExportOptionsHandler(app, &options.HostOptions)
 }
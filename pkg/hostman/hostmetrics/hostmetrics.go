@@ -210,6 +210,7 @@ func (s *SGuestMonitorCollector) GetGuests() map[string]*SGuestMonitor {
 				gm.TenantId = guest.GetDesc().TenantId
 				gm.DomainId = guest.GetDesc().DomainId
 				gm.ProjectDomain = guest.GetDesc().ProjectDomain
+				gm.balloon = guest.GetBalloonState()
 
 				gms[guestId] = gm
 			}
@@ -398,6 +399,7 @@ type GuestMetrics struct {
 	VmMem      *MemMetric     `json:"vm_mem"`
 	VmNetio    []*NetIOMetric `json:"vm_netio"`
 	VmDiskio   *DiskIOMetric  `json:"vm_diskio"`
+	VmBalloon  *BalloonMetric `json:"vm_balloon,omitempty"`
 	PodMetrics *PodMetrics    `json:"pod_metrics"`
 }
 
@@ -415,7 +417,15 @@ func (d *GuestMetrics) toVmTelegrafData(tagStr string) []string {
 	res = append(res, fmt.Sprintf("%s,%s %s", "vm_mem", tagStr, d.mapToStatStr(d.VmMem.ToMap())))
 	res = append(res, fmt.Sprintf("%s,%s %s", "vm_diskio", tagStr, d.mapToStatStr(d.VmDiskio.ToMap())))
 	for i := range d.VmNetio {
-		res = append(res, fmt.Sprintf("%s,%s %s", "vm_netio", tagStr, d.mapToStatStr(d.VmNetio[i].ToMap())))
+		// 每块网卡一个序列, 否则同一时间点的多块网卡的数据会相互覆盖
+		netTagStr := tagStr
+		if ifname := d.VmNetio[i].Meta.Ifname; len(ifname) > 0 {
+			netTagStr = fmt.Sprintf("%s,ifname=%s", tagStr, ifname)
+		}
+		res = append(res, fmt.Sprintf("%s,%s %s", "vm_netio", netTagStr, d.mapToStatStr(d.VmNetio[i].ToMap())))
+	}
+	if d.VmBalloon != nil {
+		res = append(res, fmt.Sprintf("%s,%s %s", "vm_balloon", tagStr, d.mapToStatStr(d.VmBalloon.ToMap())))
 	}
 	return res
 }
@@ -453,6 +463,7 @@ func (s *SGuestMonitorCollector) collectGuestMetrics(gm *SGuestMonitor, prevUsag
 	gmData.VmMem = gm.Mem()
 	gmData.VmDiskio = gm.Diskio()
 	gmData.VmNetio = gm.Netio()
+	gmData.VmBalloon = gm.Balloon()
 
 	netio1 := gmData.VmNetio
 	netio2 := prevUsage.VmNetio
@@ -540,6 +551,7 @@ type SGuestMonitor struct {
 	DomainId       string
 	ProjectDomain  string
 	podStat        *stats.PodStats
+	balloon        *guestman.SGuestBalloonState
 }
 
 func NewGuestMonitor(name, id string, pid int, nics []*desc.SGuestNetwork, cpuCount int) (*SGuestMonitor, error) {
@@ -891,6 +903,67 @@ func (m *SGuestMonitor) Mem() *MemMetric {
 	return ret
 }
 
+// Balloon returns the memory stats reported by the virtio-balloon driver in guest,
+// nil if the guest has no balloon device or the driver is not loaded
+func (m *SGuestMonitor) Balloon() *BalloonMetric {
+	if m.balloon == nil || m.balloon.Stats == nil || m.balloon.Stats.LastUpdate == 0 {
+		return nil
+	}
+	stats := m.balloon.Stats.Stats
+	return &BalloonMetric{
+		ActualMb:     m.balloon.ActualMb,
+		TotalMb:      bytesToMb(stats.TotalMemory),
+		FreeMb:       bytesToMb(stats.FreeMemory),
+		AvailableMb:  bytesToMb(stats.AvailableMemory),
+		DiskCachesMb: bytesToMb(stats.DiskCaches),
+		SwapIn:       stats.SwapIn,
+		SwapOut:      stats.SwapOut,
+		MajorFaults:  stats.MajorFaults,
+		MinorFaults:  stats.MinorFaults,
+	}
+}
+
+// stats not supported by guest are reported as -1
+func bytesToMb(val int64) int64 {
+	if val < 0 {
+		return val
+	}
+	return val / 1024 / 1024
+}
+
+type BalloonMetric struct {
+	ActualMb     int64 `json:"actual_mb"`
+	TotalMb      int64 `json:"total_mb"`
+	FreeMb       int64 `json:"free_mb"`
+	AvailableMb  int64 `json:"available_mb"`
+	DiskCachesMb int64 `json:"disk_caches_mb"`
+	SwapIn       int64 `json:"swap_in"`
+	SwapOut      int64 `json:"swap_out"`
+	MajorFaults  int64 `json:"major_faults"`
+	MinorFaults  int64 `json:"minor_faults"`
+}
+
+func (m *BalloonMetric) ToMap() map[string]interface{} {
+	ret := map[string]interface{}{
+		"actual_mb": m.ActualMb,
+	}
+	for k, v := range map[string]int64{
+		"total_mb":       m.TotalMb,
+		"free_mb":        m.FreeMb,
+		"available_mb":   m.AvailableMb,
+		"disk_caches_mb": m.DiskCachesMb,
+		"swap_in":        m.SwapIn,
+		"swap_out":       m.SwapOut,
+		"major_faults":   m.MajorFaults,
+		"minor_faults":   m.MinorFaults,
+	} {
+		if v >= 0 {
+			ret[k] = v
+		}
+	}
+	return ret
+}
+
 type MemMetric struct {
 	RSS         uint64  `json:"rss"`
 	VMS         uint64  `json:"vms"`
@@ -431,6 +431,31 @@ func (m *HmpMonitor) DriveBackup(callback StringCallback, drive, target, syncMod
 	m.Query(cmd, callback)
 }
 
+func (m *HmpMonitor) DriveBackupWithBitmap(callback StringCallback, drive, target, syncMode, format, bitmap string, persistent bool) {
+	go callback("hmp not support drive backup with dirty bitmap")
+}
+
+func (m *HmpMonitor) BlockDirtyBitmapAdd(node, name string, persistent bool, callback StringCallback) {
+	go callback("hmp not support command block-dirty-bitmap-add")
+}
+
+func (m *HmpMonitor) BlockDirtyBitmapRemove(node, name string, callback StringCallback) {
+	go callback("hmp not support command block-dirty-bitmap-remove")
+}
+
+func (m *HmpMonitor) SetBalloon(sizeMB int64, callback StringCallback) {
+	cmd := fmt.Sprintf("balloon %d", sizeMB)
+	m.Query(cmd, callback)
+}
+
+func (m *HmpMonitor) QueryBalloon(callback QueryBalloonCallback) {
+	go callback(0, errors.Errorf("unsupport query balloon"))
+}
+
+func (m *HmpMonitor) GetBalloonStats(devId string, callback BalloonStatsCallback) {
+	go callback(nil, errors.Errorf("unsupport get balloon stats"))
+}
+
 func (m *HmpMonitor) BlockStream(drive string, callback StringCallback) {
 	var (
 		speed = 500 // limit 500 MB/s
@@ -50,6 +50,17 @@ type BlockJob struct {
 	SpeedMbps float64
 }
 
+type QemuDirtyBitmap struct {
+	Name        string
+	Recording   bool
+	Busy        bool
+	Persistent  bool
+	Granularity int64
+	Count       int64
+	// active|disabled|frozen|locked, replaced by recording and busy since qemu 4.0
+	Status string
+}
+
 type QemuBlock struct {
 	IoStatus  string `json:"io-status"`
 	Device    string
@@ -58,7 +69,9 @@ type QemuBlock struct {
 	Qdev      string
 	TrayOpen  bool
 	Type      string
-	Inserted  struct {
+	// dirty bitmaps before qemu 4.2
+	DirtyBitmaps []QemuDirtyBitmap `json:"dirty-bitmaps"`
+	Inserted     struct {
 		Ro               bool
 		Drv              string
 		Encrypted        bool
@@ -102,7 +115,28 @@ type QemuBlock struct {
 				VirtualSize int64 `json:"virtual-size"`
 			} `json:"backing-image"`
 		}
+		DirtyBitmaps []QemuDirtyBitmap `json:"dirty-bitmaps"`
+	}
+}
+
+// GetDirtyBitmap returns the dirty bitmap of the block by name, nil if not found
+func (b *QemuBlock) GetDirtyBitmap(name string) *QemuDirtyBitmap {
+	for _, bitmaps := range [][]QemuDirtyBitmap{b.Inserted.DirtyBitmaps, b.DirtyBitmaps} {
+		for i := range bitmaps {
+			if bitmaps[i].Name == name {
+				return &bitmaps[i]
+			}
+		}
 	}
+	return nil
+}
+
+// IsUsable tells whether the bitmap is tracking the writes and not used by another block job
+func (bm *QemuDirtyBitmap) IsUsable() bool {
+	if len(bm.Status) > 0 {
+		return bm.Status == "active"
+	}
+	return bm.Recording && !bm.Busy
 }
 
 type MigrationInfo struct {
@@ -148,6 +182,33 @@ type MigrationStatus string
 
 type MigrateStatsCallback func(*MigrationInfo, error)
 
+// BalloonGuestStats implements the guest memory statistics reported by the
+// virtio-balloon driver, value -1 means the stat is not supported by the guest
+type BalloonGuestStats struct {
+	SwapIn          int64 `json:"stat-swap-in"`
+	SwapOut         int64 `json:"stat-swap-out"`
+	MajorFaults     int64 `json:"stat-major-faults"`
+	MinorFaults     int64 `json:"stat-minor-faults"`
+	FreeMemory      int64 `json:"stat-free-memory"`
+	TotalMemory     int64 `json:"stat-total-memory"`
+	AvailableMemory int64 `json:"stat-available-memory"`
+	DiskCaches      int64 `json:"stat-disk-caches"`
+	HtlbPgalloc     int64 `json:"stat-htlb-pgalloc"`
+	HtlbPgfail      int64 `json:"stat-htlb-pgfail"`
+}
+
+// BalloonStats implements the guest-stats property of the virtio-balloon device
+type BalloonStats struct {
+	// unix timestamp of the last update, 0 means the stats are never updated
+	LastUpdate int64             `json:"last-update"`
+	Stats      BalloonGuestStats `json:"stats"`
+}
+
+// QueryBalloonCallback is called with the actual memory size of the guest in MB
+type QueryBalloonCallback func(actualMB int64, err error)
+
+type BalloonStatsCallback func(*BalloonStats, error)
+
 type blockSizeByte int64
 
 func (self blockSizeByte) String() string {
@@ -225,6 +286,9 @@ type Monitor interface {
 	BlockStream(drive string, callback StringCallback)
 	DriveMirror(callback StringCallback, drive, target, syncMode, format string, unmap, blockReplication bool, speed int64)
 	DriveBackup(callback StringCallback, drive, target, syncMode, format string)
+	DriveBackupWithBitmap(callback StringCallback, drive, target, syncMode, format, bitmap string, persistent bool)
+	BlockDirtyBitmapAdd(node, name string, persistent bool, callback StringCallback)
+	BlockDirtyBitmapRemove(node, name string, callback StringCallback)
 	BlockJobComplete(drive string, cb StringCallback)
 	BlockReopenImage(drive, newImagePath, format string, cb StringCallback)
 	SnapshotBlkdev(drive, newImagePath, format string, reuse bool, cb StringCallback)
@@ -249,6 +313,10 @@ type Monitor interface {
 	BlockIoThrottle(driveName string, bps, iops int64, callback StringCallback)
 	CancelBlockJob(driveName string, force bool, callback StringCallback)
 
+	SetBalloon(sizeMB int64, callback StringCallback)
+	QueryBalloon(callback QueryBalloonCallback)
+	GetBalloonStats(devId string, callback BalloonStatsCallback)
+
 	NetdevAdd(id, netType string, params map[string]string, callback StringCallback)
 	NetdevDel(id string, callback StringCallback)
 
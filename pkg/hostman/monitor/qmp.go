@@ -819,6 +819,155 @@ func (m *QmpMonitor) DriveBackup(callback StringCallback, drive, target, syncMod
 	m.Query(cmd, cb)
 }
 
+// DriveBackupWithBitmap starts a drive-backup job working with the dirty bitmap
+// of the drive, syncMode is one of:
+//   - full: a full backup, the bitmap is created in the same transaction, so it
+//     tracks exactly the writes after the backup point
+//   - incremental: copies the clusters dirtied since the last backup, the bitmap
+//     is cleared when the job succeeds
+//   - bitmap: copies the clusters dirtied since the bitmap was created and keeps
+//     the bitmap untouched, which makes a differential backup
+func (m *QmpMonitor) DriveBackupWithBitmap(callback StringCallback, drive, target, syncMode, format, bitmap string, persistent bool) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		backupArgs = map[string]interface{}{
+			"device": drive,
+			"target": target,
+			"mode":   "existing",
+			"sync":   syncMode,
+			"format": format,
+		}
+	)
+	switch syncMode {
+	case "full":
+		cmd := &Command{
+			Execute: "transaction",
+			Args: map[string]interface{}{
+				"actions": []interface{}{
+					map[string]interface{}{
+						"type": "block-dirty-bitmap-add",
+						"data": map[string]interface{}{
+							"node":       drive,
+							"name":       bitmap,
+							"persistent": persistent,
+						},
+					},
+					map[string]interface{}{
+						"type": "drive-backup",
+						"data": backupArgs,
+					},
+				},
+			},
+		}
+		m.Query(cmd, cb)
+		return
+	case "bitmap":
+		backupArgs["bitmap-mode"] = "never"
+	}
+	backupArgs["bitmap"] = bitmap
+	cmd := &Command{
+		Execute: "drive-backup",
+		Args:    backupArgs,
+	}
+	m.Query(cmd, cb)
+}
+
+func (m *QmpMonitor) BlockDirtyBitmapAdd(node, name string, persistent bool, callback StringCallback) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		cmd = &Command{
+			Execute: "block-dirty-bitmap-add",
+			Args: map[string]interface{}{
+				"node":       node,
+				"name":       name,
+				"persistent": persistent,
+			},
+		}
+	)
+	m.Query(cmd, cb)
+}
+
+func (m *QmpMonitor) BlockDirtyBitmapRemove(node, name string, callback StringCallback) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		cmd = &Command{
+			Execute: "block-dirty-bitmap-remove",
+			Args: map[string]interface{}{
+				"node": node,
+				"name": name,
+			},
+		}
+	)
+	m.Query(cmd, cb)
+}
+
+func (m *QmpMonitor) SetBalloon(sizeMB int64, callback StringCallback) {
+	var (
+		cb = func(res *Response) {
+			callback(m.actionResult(res))
+		}
+		cmd = &Command{
+			Execute: "balloon",
+			Args:    map[string]interface{}{"value": sizeMB * 1024 * 1024},
+		}
+	)
+	m.Query(cmd, cb)
+}
+
+func (m *QmpMonitor) QueryBalloon(callback QueryBalloonCallback) {
+	var (
+		cmd = &Command{Execute: "query-balloon"}
+		cb  = func(res *Response) {
+			if res.ErrorVal != nil {
+				callback(0, errors.Errorf(res.ErrorVal.Error()))
+				return
+			}
+			info := struct {
+				Actual int64 `json:"actual"`
+			}{}
+			err := json.Unmarshal(res.Return, &info)
+			if err != nil {
+				callback(0, err)
+				return
+			}
+			callback(info.Actual/1024/1024, nil)
+		}
+	)
+	m.Query(cmd, cb)
+}
+
+func (m *QmpMonitor) GetBalloonStats(devId string, callback BalloonStatsCallback) {
+	var (
+		cmd = &Command{
+			Execute: "qom-get",
+			Args: map[string]interface{}{
+				"path":     fmt.Sprintf("/machine/peripheral/%s", devId),
+				"property": "guest-stats",
+			},
+		}
+		cb = func(res *Response) {
+			if res.ErrorVal != nil {
+				callback(nil, errors.Errorf(res.ErrorVal.Error()))
+				return
+			}
+			stats := new(BalloonStats)
+			err := json.Unmarshal(res.Return, stats)
+			if err != nil {
+				callback(nil, err)
+				return
+			}
+			callback(stats, nil)
+		}
+	)
+	m.Query(cmd, cb)
+}
+
 func (m *QmpMonitor) BlockStream(drive string, callback StringCallback) {
 	var (
 		speed = 5 * 100 * 1024 * 1024 // limit 500 MB/s
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 )
 
@@ -43,3 +44,47 @@ func TestQmpMonitor_Connect(t *testing.T) {
 	m.Disconnect()
 	time.Sleep(3 * time.Second)
 }
+
+func TestQemuBlock_GetDirtyBitmap(t *testing.T) {
+	// output of query-block by qemu 2.x and 4.2+
+	res := `[
+	{"device": "drive_0", "dirty-bitmaps": [{"name": "onecloud-backup", "status": "active", "count": 0, "granularity": 65536}],
+	 "inserted": {"drv": "qcow2", "file": "/opt/cloud/disk0"}},
+	{"device": "drive_1", "inserted": {"drv": "qcow2", "file": "/opt/cloud/disk1",
+	 "dirty-bitmaps": [{"name": "onecloud-backup", "recording": true, "busy": true, "persistent": true, "count": 0, "granularity": 65536}]}},
+	{"device": "drive_2", "inserted": {"drv": "qcow2", "file": "/opt/cloud/disk2",
+	 "dirty-bitmaps": [{"name": "onecloud-backup", "recording": true, "busy": false, "persistent": true, "count": 4096, "granularity": 65536}]}},
+	{"device": "drive_3", "inserted": {"drv": "raw", "file": "/opt/cloud/disk3"}}
+]`
+	jr, err := jsonutils.ParseString(res)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	blocks := []QemuBlock{}
+	if err := jr.Unmarshal(&blocks); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	cases := []struct {
+		device string
+		found  bool
+		usable bool
+	}{
+		{"drive_0", true, true},
+		{"drive_1", true, false},
+		{"drive_2", true, true},
+		{"drive_3", false, false},
+	}
+	for i, c := range cases {
+		bitmap := blocks[i].GetDirtyBitmap("onecloud-backup")
+		if blocks[i].Device != c.device || (bitmap != nil) != c.found {
+			t.Errorf("%s: bitmap %#v found %v", c.device, bitmap, c.found)
+			continue
+		}
+		if bitmap != nil && bitmap.IsUsable() != c.usable {
+			t.Errorf("%s: bitmap %#v usable %v", c.device, bitmap, c.usable)
+		}
+	}
+	if blocks[2].GetDirtyBitmap("other") != nil {
+		t.Errorf("unexpected bitmap other")
+	}
+}
@@ -199,6 +199,16 @@ type SHostOptions struct {
 
 	EnableVirtioRngDevice bool `help:"enable qemu virtio-rng device" default:"true"`
 
+	EnableVirtioBalloonDevice          bool `help:"enable qemu virtio-balloon device by default, can be overridden by guest metadata enable_memory_balloon" default:"false"`
+	EnableBalloonFreePageReporting     bool `help:"enable free page reporting of virtio-balloon device, requires qemu 5.1 or later" default:"false"`
+	BalloonStatsPollingIntervalSeconds int  `help:"interval of guest memory stats polling of virtio-balloon device" default:"10"`
+
+	EnableMemoryBalloonReclaim           bool `help:"reclaim memory from idle guests by virtio-balloon when host memory is overcommitted" default:"false"`
+	MemoryBalloonReclaimIntervalSeconds  int  `help:"interval of checking host memory for balloon reclaim" default:"60"`
+	MemoryBalloonReclaimThresholdPercent int  `help:"start reclaiming when host available memory is below this percent" default:"15"`
+	MemoryBalloonIdleFreePercent         int  `help:"guest is idle if its free memory is above this percent" default:"40"`
+	MemoryBalloonMinGuestPercent         int  `help:"never shrink guest memory below this percent" default:"50"`
+
 	RestrictQemuImgConvertWorker bool `help:"restrict qemu-img convert worker" default:"false"`
 
 	DefaultLiveMigrateDowntime float32 `help:"allow downtime in seconds for live migrate" default:"5.0"`
@@ -123,6 +123,55 @@ func doBackupDisk(ctx context.Context, snapshotPath string, diskBackup *SDiskBac
 	return newImageSizeMb, nil
 }
 
+// SaveBitmapBackup saves the image exported from the running guest by the
+// dirty bitmap to the backup storage, an incremental or differential image
+// only holds the changed clusters and has no backing file, the backing chain
+// is rebuilt on restoring
+func SaveBitmapBackup(ctx context.Context, backupPath string, diskBackup *SDiskBackup) (int, error) {
+	img, err := qemuimg.NewQemuImage(backupPath)
+	if err != nil {
+		return 0, errors.Wrap(err, "NewQemuImage")
+	}
+	backupStorage, err := backupstorage.GetBackupStorage(diskBackup.BackupStorageId, diskBackup.BackupStorageAccessInfo)
+	if err != nil {
+		return 0, errors.Wrap(err, "GetBackupStorage")
+	}
+	err = backupStorage.SaveBackupFrom(ctx, backupPath, diskBackup.BackupId)
+	if err != nil {
+		return 0, errors.Wrap(err, "SaveBackupFrom")
+	}
+	return img.GetActualSizeMB(), nil
+}
+
+// restoreBackupChain downloads the backups the backup depends on and links
+// them with the backup as a qcow2 backing chain
+func restoreBackupChain(ctx context.Context, backupStorage backupstorage.IBackupStorage, backupTmpDir string, chain []string, backupPath string) error {
+	backingPath := ""
+	for _, backupId := range append(chain, "") {
+		chainPath := backupPath
+		if len(backupId) > 0 {
+			chainPath = path.Join(backupTmpDir, backupId)
+			err := backupStorage.RestoreBackupTo(ctx, chainPath, backupId)
+			if err != nil {
+				return errors.Wrapf(err, "RestoreBackupTo %s", backupId)
+			}
+		}
+		if len(backingPath) > 0 {
+			img, err := qemuimg.NewQemuImage(chainPath)
+			if err != nil {
+				return errors.Wrapf(err, "NewQemuImage %s", chainPath)
+			}
+			// only link the images, the clusters absent in the image are in the backing file
+			err = img.Rebase(backingPath, true)
+			if err != nil {
+				return errors.Wrapf(err, "rebase %s to %s", chainPath, backingPath)
+			}
+		}
+		backingPath = chainPath
+	}
+	return nil
+}
+
 type IDiskCreator interface {
 	CreateRawDisk(ctx context.Context, disk IDisk, input *SDiskCreateByDiskinfo) (jsonutils.JSONObject, error)
 }
@@ -147,6 +196,12 @@ func doRestoreDisk(ctx context.Context, dc IDiskCreator, input *SDiskCreateByDis
 	}
 
 	backupInput := diskInfo.Backup
+	if len(backupInput.BackupChain) > 0 {
+		err = restoreBackupChain(ctx, backupStorage, backupTmpDir, backupInput.BackupChain, backupPath)
+		if err != nil {
+			return errors.Wrap(err, "restoreBackupChain")
+		}
+	}
 
 	if backupInput.BackupAsTar != nil {
 		return doRestoreTarDisk(ctx, dc, disk, input, destImgPath, backupPath)
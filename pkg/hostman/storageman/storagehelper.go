@@ -82,6 +82,10 @@ type SDiskBackup struct {
 
 	EncryptKeyId string `json:"encrypt_key_id"`
 
+	// mode and dirty bitmap of the backups taken from the running guest
+	BackupMode string `json:"backup_mode"`
+	Bitmap     string `json:"bitmap"`
+
 	UserCred mcclient.TokenCredential
 }
 
@@ -154,6 +154,10 @@ func NotAcceptableError(ctx context.Context, w http.ResponseWriter, msg string,
 	JsonClientError(ctx, w, NewNotAcceptableError(msg, params...))
 }
 
+func TooManyRequestsError(ctx context.Context, w http.ResponseWriter, msg string, params ...interface{}) {
+	JsonClientError(ctx, w, NewTooManyRequestsError(msg, params...))
+}
+
 func InvalidInputError(ctx context.Context, w http.ResponseWriter, msg string, params ...interface{}) {
 	JsonClientError(ctx, w, NewInputParameterError(msg, params...))
 }
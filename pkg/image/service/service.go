@@ -35,6 +35,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/cachesync"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/hostman/hostdeployer/deployclient"
 	"yunion.io/x/onecloud/pkg/image/drivers/s3"
@@ -139,7 +140,14 @@ func StartService() {
 		go models.CheckImages()
 	}
 
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(context.Background())
 	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(context.Background()); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
+
 		cachesync.StartTenantCacheSync(opts.TenantCacheExpireSeconds)
 
 		cron := cronman.InitCronJobManager(true, options.Options.CronJobWorkerCount)
@@ -149,6 +157,7 @@ func StartService() {
 			time.Duration(options.Options.PendingDeleteCheckSeconds)*time.Second, models.GuestImageManager.CleanPendingDeleteImages)
 
 		cron.AddJobEveryFewHour("AutoPurgeSplitable", 4, 30, 0, db.AutoPurgeSplitable, false)
+		cron.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
 
 		cron.Start()
 	}
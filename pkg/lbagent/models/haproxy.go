@@ -20,6 +20,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -29,6 +31,7 @@ import (
 	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
 	compute_models "yunion.io/x/onecloud/pkg/compute/models"
 	agentutils "yunion.io/x/onecloud/pkg/lbagent/utils"
+	"yunion.io/x/onecloud/pkg/util/acme"
 )
 
 var haproxyConfigErrNop = errors.Error("nop haproxy config snippet")
@@ -557,10 +560,37 @@ func (b *LoadbalancerCorpus) genHaproxyConfigHttp(buf *bytes.Buffer, listener *L
 		// nothing to serve
 		return haproxyConfigErrNop
 	}
+	if acmeLines := b.haproxyAcmeHttp01Lines(); len(acmeLines) > 0 {
+		// answer challenges before redirects and dispatching
+		data["rules"] = append(acmeLines, ruleLines...)
+	}
 	err = haproxyConfigTmpl.ExecuteTemplate(buf, "httpListen", data)
 	return err
 }
 
+// acme tokens and key authorizations are base64url encoded, anything else
+// is dropped to keep the config intact
+var haproxyAcmeHttp01Reg = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)?$`)
+
+// haproxyAcmeHttp01Lines returns the rules answering pending http-01
+// challenges of certificates issued by acme
+func (b *LoadbalancerCorpus) haproxyAcmeHttp01Lines() []string {
+	lines := []string{}
+	for _, lbcert := range b.LoadbalancerCertificates {
+		for _, chal := range lbcert.AcmeChallenges {
+			if !haproxyAcmeHttp01Reg.MatchString(chal.Token) || !haproxyAcmeHttp01Reg.MatchString(chal.KeyAuthorization) {
+				log.Warningf("ignore invalid acme challenge of certificate %s", lbcert.Id)
+				continue
+			}
+			line := fmt.Sprintf("http-request return status 200 content-type text/plain string %q if { path %s%s }",
+				chal.KeyAuthorization, acme.HTTP01ChallengePath, chal.Token)
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
 func (b *LoadbalancerCorpus) genHaproxyConfigTcp(buf *bytes.Buffer, listener *LoadbalancerListener, opts *AgentParams) error {
 	lb := listener.loadbalancer
 	data, err := b.genHaproxyConfigCommon(lb, listener, opts)
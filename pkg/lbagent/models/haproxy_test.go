@@ -0,0 +1,52 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+
+	computeapi "yunion.io/x/onecloud/pkg/apis/compute"
+	compute_models "yunion.io/x/onecloud/pkg/compute/models"
+)
+
+func TestLoadbalancerCorpus_haproxyAcmeHttp01Lines(t *testing.T) {
+	cert := &compute_models.SLoadbalancerCertificate{}
+	cert.Id = "cert"
+	cert.AcmeChallenges = computeapi.LoadbalancerAcmeChallenges{
+		{Domain: "b.example.com", Token: "tok-b", KeyAuthorization: "tok-b.thumb"},
+		{Domain: "a.example.com", Token: "tok_a", KeyAuthorization: "tok_a.thumb"},
+		{Domain: "c.example.com", Token: "tok c", KeyAuthorization: "tok\" }"},
+	}
+	b := &LoadbalancerCorpus{
+		ModelSets: &ModelSets{
+			LoadbalancerCertificates: LoadbalancerCertificates{
+				"cert": {SLoadbalancerCertificate: cert},
+			},
+		},
+	}
+	got := b.haproxyAcmeHttp01Lines()
+	want := []string{
+		`http-request return status 200 content-type text/plain string "tok-b.thumb" if { path /.well-known/acme-challenge/tok-b }`,
+		`http-request return status 200 content-type text/plain string "tok_a.thumb" if { path /.well-known/acme-challenge/tok_a }`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d lines, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: want %s, got %s", i, want[i], got[i])
+		}
+	}
+}
@@ -17,6 +17,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/logger/models"
 	"yunion.io/x/onecloud/pkg/logger/options"
@@ -28,6 +29,8 @@ func initHandlers(app *appsrv.Application) {
 	models.InitActionLog()
 	models.InitBaremetalEvent()
 
+	cronman.AddCronJobHandler("", app)
+
 	for _, manager := range []db.IModelManager{
 		db.UserCacheManager,
 		db.TenantCacheManager,
@@ -65,25 +65,35 @@ func (c *sAccessKeyCache) Verify(cli *mcclient.Client, req http.Request, virtual
 		return nil, errors.Wrap(err, "s3auth.DecodeAccessKeyRequestV2")
 	}
 
+	token, err := c.verifyAccessKeyRequest(cli, req, aksk)
+	if err != nil {
+		return nil, err
+	}
+	return token.Token, nil
+}
+
+// verifyAccessKeyRequest verifies the signature of an already decoded
+// request, e.g. the one signed in the query string of a presigned URL
+func (c *sAccessKeyCache) verifyAccessKeyRequest(cli *mcclient.Client, req http.Request, aksk s3auth.IAccessKeySecretRequest) (*mcclient.SAkskTokenCredential, error) {
 	token, found := c.getToken(aksk.GetAccessKey())
 	if found {
 		if token.Token.IsValid() && token.AccessKeySecret.IsValid() {
-			err = aksk.Verify(token.AccessKeySecret.Secret)
+			err := aksk.Verify(token.AccessKeySecret.Secret)
 			if err != nil {
 				return nil, errors.Wrap(err, "aksk.Verify")
 			}
-			return token.Token, nil
+			return token, nil
 		} else {
 			c.deleteToken(aksk.GetAccessKey())
 		}
 	}
 
-	token, err = cli.VerifyRequest(req, aksk, virtualHost)
+	token, err := cli.VerifyRequest(req, aksk, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "cli.VerifyRequest")
 	}
 
 	c.addToken(token)
 
-	return token.Token, nil
+	return token, nil
 }
@@ -17,6 +17,7 @@ package auth
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/appctx"
@@ -74,6 +75,10 @@ func AuthenticateWithDelayDecision(f appsrv.FilterHandler, delayDecision bool) a
 		}
 		ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_AUTH_TOKEN, token)
 
+		if !CheckRateLimit(ctx, w, r, token) {
+			return
+		}
+
 		if taskId := r.Header.Get(mcclient.TASK_ID); taskId != "" {
 			ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_TASK_ID, taskId)
 		}
@@ -85,6 +90,20 @@ func AuthenticateWithDelayDecision(f appsrv.FilterHandler, delayDecision bool) a
 	}
 }
 
+// CheckRateLimit rejects the request with 429 Too Many Requests if the user or
+// the project of the token exceeds the rate limits of the application
+func CheckRateLimit(ctx context.Context, w http.ResponseWriter, r *http.Request, token mcclient.TokenCredential) bool {
+	if len(token.GetUserId()) == 0 {
+		return true
+	}
+	ok, wait := appsrv.CheckRateLimit(ctx, w, r, token.GetUserId(), token.GetUserName(), token.GetProjectId(), token.HasSystemAdminPrivilege())
+	if !ok {
+		httperrors.TooManyRequestsError(ctx, w, "too many requests, retry after %s", wait.Round(time.Millisecond))
+		return false
+	}
+	return true
+}
+
 func FetchUserCredential(ctx context.Context, filter func(mcclient.TokenCredential) mcclient.TokenCredential) mcclient.TokenCredential {
 	tokenValue := ctx.Value(appctx.APP_CONTEXT_KEY_AUTH_TOKEN)
 	if tokenValue != nil {
@@ -38,6 +38,7 @@ import (
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/util/rbacutils"
 	"yunion.io/x/onecloud/pkg/util/seclib2"
+	"yunion.io/x/onecloud/pkg/util/tracing"
 )
 
 var listenerWorker *appsrv.SWorkerManager
@@ -166,14 +167,57 @@ func FixContext(ctx context.Context) context.Context {
 	return ctx
 }
 
+// startClientSpan starts a span of a request to another service and injects
+// its W3C trace context into the request headers
+func startClientSpan(ctx context.Context, method httputils.THttpMethod, urlStr string, header http.Header) (context.Context, *tracing.SSpan, http.Header) {
+	ctx, span := tracing.StartSpan(ctx, fmt.Sprintf("HTTP %s", method), tracing.SpanKindClient)
+	if span == nil {
+		return ctx, nil, header
+	}
+	span.SetAttribute("http.method", string(method))
+	span.SetAttribute("http.url", urlStr)
+	return ctx, span, tracing.Inject(ctx, header)
+}
+
+func endClientSpan(span *tracing.SSpan, status int, err error) {
+	if span == nil {
+		return
+	}
+	if status > 0 {
+		span.SetHTTPStatus(status)
+	}
+	span.SetError(err)
+	span.End()
+}
+
 func (client *Client) rawRequest(ctx context.Context, endpoint string, token string, method httputils.THttpMethod, url string, header http.Header, body io.Reader) (*http.Response, error) {
 	ctx = FixContext(ctx)
-	return httputils.Request(client.httpconn, ctx, method, joinUrl(endpoint, url), getDefaultHeader(header, token), body, client.debug)
+	urlStr := joinUrl(endpoint, url)
+	ctx, span, header := startClientSpan(ctx, method, urlStr, getDefaultHeader(header, token))
+	resp, err := httputils.Request(client.httpconn, ctx, method, urlStr, header, body, client.debug)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	endClientSpan(span, status, err)
+	return resp, err
 }
 
 func (client *Client) jsonRequest(ctx context.Context, endpoint string, token string, method httputils.THttpMethod, url string, header http.Header, body jsonutils.JSONObject) (http.Header, jsonutils.JSONObject, error) {
 	ctx = FixContext(ctx)
-	return httputils.JSONRequest(client.httpconn, ctx, method, joinUrl(endpoint, url), getDefaultHeader(header, token), body, client.debug)
+	urlStr := joinUrl(endpoint, url)
+	ctx, span, header := startClientSpan(ctx, method, urlStr, getDefaultHeader(header, token))
+	respHeader, respBody, err := httputils.JSONRequest(client.httpconn, ctx, method, urlStr, header, body, client.debug)
+	status := 0
+	if err != nil {
+		if je, ok := err.(*httputils.JSONClientError); ok {
+			status = je.Code
+		}
+	} else {
+		status = http.StatusOK
+	}
+	endClientSpan(span, status, err)
+	return respHeader, respBody, err
 }
 
 func (client *Client) _authV3(domainName, uname, passwd, projectId, projectName, projectDomain, token string, aCtx SAuthContext) (TokenCredential, error) {
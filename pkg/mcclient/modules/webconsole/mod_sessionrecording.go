@@ -0,0 +1,61 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
+)
+
+var (
+	SessionRecordings *SessionRecordingManager
+)
+
+func init() {
+	SessionRecordings = NewSessionRecordingManager()
+
+	modulebase.Register(SessionRecordings)
+}
+
+type SessionRecordingManager struct {
+	modulebase.ResourceManager
+}
+
+func NewSessionRecordingManager() *SessionRecordingManager {
+	return &SessionRecordingManager{
+		modulebase.ResourceManager{
+			BaseManager: *modulebase.NewBaseManager("webconsole", "", "webconsole", []string{
+				"id", "name", "status", "session_id", "obj_id", "obj_type", "obj_name", "user", "login_user", "protocol",
+				"started_at", "ended_at", "duration", "width", "height", "size_bytes", "tenant",
+			}, []string{"backend", "storage_path"}),
+			Keyword: "session_recording", KeywordPlural: "session_recordings",
+		},
+	}
+}
+
+// Download returns the asciicast content of a recording
+func (m *SessionRecordingManager) Download(s *mcclient.ClientSession, id string) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/%s/%s/download", m.URLPath(), url.PathEscape(id))
+	resp, err := modulebase.RawRequest(m.ResourceManager, s, "GET", path, nil, nil)
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.Body, nil
+	}
+	_, _, err = s.ParseJSONResponse("", resp, err)
+	return nil, err
+}
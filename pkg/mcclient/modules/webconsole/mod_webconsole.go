@@ -20,6 +20,7 @@ import (
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/printutils"
 
 	compute_api "yunion.io/x/onecloud/pkg/apis/compute"
 	webconsole_api "yunion.io/x/onecloud/pkg/apis/webconsole"
@@ -219,3 +220,32 @@ func (m WebConsoleManager) DoClimcSshConnect(s *mcclient.ClientSession, ip strin
 	body.Set("webconsole", data)
 	return m.DoConnect(s, "climc", "shell", "", body)
 }
+
+// ListSessions lists the live terminal sessions which can be shared
+func (m WebConsoleManager) ListSessions(s *mcclient.ClientSession, params jsonutils.JSONObject) (*printutils.ListResult, error) {
+	path := "/webconsole/sessions"
+	if params != nil {
+		if qs := params.QueryString(); len(qs) > 0 {
+			path = fmt.Sprintf("%s?%s", path, qs)
+		}
+	}
+	ret, err := modulebase.Get(m.ResourceManager, s, path, "webconsole")
+	if err != nil {
+		return nil, err
+	}
+	result := &printutils.ListResult{}
+	err = ret.Unmarshal(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	return result, nil
+}
+
+// DoSessionAction performs share, unshare, attach, message or terminate on a live session
+func (m WebConsoleManager) DoSessionAction(s *mcclient.ClientSession, id, action string, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	body := jsonutils.NewDict()
+	if params != nil {
+		body.Set("webconsole", params)
+	}
+	return m.DoConnect(s, "sessions", id, action, body)
+}
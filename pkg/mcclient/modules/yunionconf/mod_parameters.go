@@ -51,6 +51,11 @@ func (m *ParametersManager) GetWidgetSettings(s *mcclient.ClientSession, params
 	return m.getParametersRpc(s, "widget-settings", params)
 }
 
+// GetRateLimitSettings returns the API rate limits shared by all services
+func (m *ParametersManager) GetRateLimitSettings(s *mcclient.ClientSession, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	return m.getParametersRpc(s, "rate-limits", params)
+}
+
 func (m *ParametersManager) getParametersRpc(s *mcclient.ClientSession, key string, params jsonutils.JSONObject) (jsonutils.JSONObject, error) {
 	adminSession := auth.GetAdminSession(context.Background(), "")
 	p := jsonutils.NewDict()
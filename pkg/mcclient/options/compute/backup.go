@@ -59,6 +59,7 @@ type DiskBackupCreateOptions struct {
 	AsTarContainerId string   `help:"container id of tar process"`
 	AsTarIncludeFile []string `help:"include file path of tar process"`
 	AsTarExcludeFile []string `help:"exclude file path of tar process"`
+	BackupMode       string   `help:"backup mode" choices:"full|incremental|differential"`
 
 	DISKID          string `help:"disk id" json:"disk_id"`
 	BACKUPSTORAGEID string `help:"back storage id" json:"backup_storage_id"`
@@ -69,6 +70,7 @@ func (opts *DiskBackupCreateOptions) Params() (jsonutils.JSONObject, error) {
 		DiskId:          opts.DISKID,
 		BackupStorageId: opts.BACKUPSTORAGEID,
 		BackupAsTar:     new(computeapi.DiskBackupAsTarInput),
+		BackupMode:      opts.BackupMode,
 	}
 	input.Name = opts.NAME
 	input.Description = opts.Desc
@@ -32,12 +32,12 @@ func (opts *DnsRecordListOptions) Params() (jsonutils.JSONObject, error) {
 type DnsRecordCreateOptions struct {
 	options.EnabledStatusCreateOptions
 	DNS_ZONE_ID string `help:"Dns Zone Id"`
-	DNS_TYPE    string `choices:"A|AAAA|CAA|CNAME|MX|NS|SRV|SOA|TXT|PTR|DS|DNSKEY|IPSECKEY|NAPTR|SPF|SSHFP|TLSA|REDIRECT_URL|FORWARD_URL"`
+	DNS_TYPE    string `choices:"A|AAAA|CAA|CNAME|MX|NS|SRV|SOA|TXT|PTR|DS|DNSKEY|IPSECKEY|NAPTR|SPF|SSHFP|TLSA|SVCB|HTTPS|REDIRECT_URL|FORWARD_URL"`
 	DNS_VALUE   string `help:"Dns Value"`
 	Ttl         int64  `help:"Dns ttl" default:"300"`
 	MxPriority  int64  `help:"dns mx type mxpriority"`
-	PolicyType  string `choices:"Simple|ByCarrier|ByGeoLocation|BySearchEngine|IpRange|Weighted|Failover|MultiValueAnswer|Latency"`
-	PolicyValue string `help:"Dns Traffic policy value"`
+	PolicyType  string `choices:"Simple|ByCarrier|ByGeoLocation|BySearchEngine|IpRange|Weighted|Failover|MultiValueAnswer|Latency|ByVpc"`
+	PolicyValue string `help:"Dns Traffic policy value, comma separated cidrs for IpRange and vpc ids for ByVpc of local dns zones"`
 }
 
 func (opts *DnsRecordCreateOptions) Params() (jsonutils.JSONObject, error) {
@@ -59,12 +59,12 @@ func (opts *DnsRecordIdOptions) Params() (jsonutils.JSONObject, error) {
 
 type DnsRecordUpdateOptions struct {
 	options.BaseUpdateOptions
-	DnsType     string `choices:"A|AAAA|CAA|CNAME|MX|NS|SRV|SOA|TXT|PRT|DS|DNSKEY|IPSECKEY|NAPTR|SPF|SSHFP|TLSA|REDIRECT_URL|FORWARD_URL"`
+	DnsType     string `choices:"A|AAAA|CAA|CNAME|MX|NS|SRV|SOA|TXT|PRT|DS|DNSKEY|IPSECKEY|NAPTR|SPF|SSHFP|TLSA|SVCB|HTTPS|REDIRECT_URL|FORWARD_URL"`
 	DnsValue    string
 	Ttl         *int64
 	MxPriority  *int64 `help:"dns mx type mxpriority"`
-	PolicyType  string `choices:"Simple|ByCarrier|ByGeoLocation|BySearchEngine|IpRange|Weighted|Failover|MultiValueAnswer|Latency"`
-	PolicyValue string `help:"Dns Traffic policy value"`
+	PolicyType  string `choices:"Simple|ByCarrier|ByGeoLocation|BySearchEngine|IpRange|Weighted|Failover|MultiValueAnswer|Latency|ByVpc"`
+	PolicyValue string `help:"Dns Traffic policy value, comma separated cidrs for IpRange and vpc ids for ByVpc of local dns zones"`
 }
 
 func (opts *DnsRecordUpdateOptions) Params() (jsonutils.JSONObject, error) {
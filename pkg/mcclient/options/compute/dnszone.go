@@ -83,3 +83,31 @@ type DnsZoneRemoveVpcsOptions struct {
 func (opts *DnsZoneRemoveVpcsOptions) Params() (jsonutils.JSONObject, error) {
 	return jsonutils.Marshal(map[string]string{"vpc_ids": opts.VPC_IDS}), nil
 }
+
+type DnsZoneEnableDnssecOptions struct {
+	SDnsZoneIdOptions
+	Algorithm       string `help:"signing algorithm" choices:"RSASHA256|ECDSAP256SHA256|ECDSAP384SHA384|ED25519" default:"ECDSAP256SHA256"`
+	ZskLifetimeDays int    `help:"automatically rollover zsk after days, 0 to disable"`
+}
+
+func (opts *DnsZoneEnableDnssecOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(opts)
+}
+
+type DnsZoneRolloverDnssecKeyOptions struct {
+	SDnsZoneIdOptions
+	KEY_TYPE string `help:"type of key to rollover" choices:"ksk|zsk"`
+}
+
+func (opts *DnsZoneRolloverDnssecKeyOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"key_type": opts.KEY_TYPE}), nil
+}
+
+type DnsZoneRetireDnssecKeyOptions struct {
+	SDnsZoneIdOptions
+	KEY string `help:"id or key tag of the old ksk, retire it after the ds of the new ksk is added to parent zone"`
+}
+
+func (opts *DnsZoneRetireDnssecKeyOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"key_id": opts.KEY}), nil
+}
@@ -57,8 +57,15 @@ type LoadbalancerCertificateCreateOptions struct {
 	Manager string `json:"manager_id"`
 	Region  string `json:"cloudregion"`
 
-	Cert string `required:"true" json:"-" help:"path to certificate file"`
-	Pkey string `required:"true" json:"-" help:"path to private key file"`
+	Cert string `json:"-" help:"path to certificate file"`
+	Pkey string `json:"-" help:"path to private key file"`
+
+	AcmeDirectoryUrl    string   `json:"-" help:"issue the certificate by acme, e.g. https://acme-v02.api.letsencrypt.org/directory"`
+	AcmeEmail           string   `json:"-" help:"contact email of the acme account"`
+	AcmeDomain          []string `json:"-" help:"domains of the acme certificate"`
+	AcmeChallengeType   string   `json:"-" help:"acme challenge type" choices:"http-01|dns-01"`
+	AcmeKeyType         string   `json:"-" help:"key type of the acme certificate" choices:"ec256|rsa2048"`
+	AcmeRenewBeforeDays int      `json:"-" help:"days to renew the acme certificate before expiry"`
 }
 
 func (opts *LoadbalancerCertificateCreateOptions) Params() (jsonutils.JSONObject, error) {
@@ -74,6 +81,19 @@ func (opts *LoadbalancerCertificateCreateOptions) Params() (jsonutils.JSONObject
 
 	params.Update(sp)
 
+	if len(opts.AcmeDirectoryUrl) > 0 {
+		acme := jsonutils.Marshal(map[string]interface{}{
+			"directory_url":     opts.AcmeDirectoryUrl,
+			"email":             opts.AcmeEmail,
+			"domains":           opts.AcmeDomain,
+			"challenge_type":    opts.AcmeChallengeType,
+			"key_type":          opts.AcmeKeyType,
+			"renew_before_days": opts.AcmeRenewBeforeDays,
+		})
+		params.Set("acme", acme)
+		return params, nil
+	}
+
 	paramsCertKey, err := loadbalancerCertificateLoadFiles(opts.Cert, opts.Pkey, false)
 	if err != nil {
 		return nil, err
@@ -448,6 +448,8 @@ type ServerCreateOptionalOptions struct {
 	CpuSockets     int    `help:"Cpu sockets"`
 	EnableMemclean bool   `help:"clean guest memory after guest exit" json:"enable_memclean"`
 
+	EnableMemoryBalloon bool `help:"add virtio-balloon device to reclaim idle guest memory" json:"enable_memory_balloon"`
+
 	Keypair          string   `help:"SSH Keypair"`
 	Password         string   `help:"Default user password"`
 	LoginAccount     string   `help:"Guest login account"`
@@ -584,6 +586,8 @@ func (opts *ServerCreateOptionalOptions) OptionalParams() (*computeapi.ServerCre
 		GuestImageID:       opts.GuestImageID,
 		Secgroups:          opts.Secgroups,
 		EnableMemclean:     opts.EnableMemclean,
+
+		EnableMemoryBalloon: opts.EnableMemoryBalloon,
 	}
 
 	params.ProjectId = opts.Project
@@ -113,3 +113,55 @@ func (o *CommonAlertCreateOptions) Params() (jsonutils.JSONObject, error) {
 	ret := input.ToCommonAlertCreateInput(&o.CommonAlertCreateBaseInput)
 	return ret.JSON(ret), nil
 }
+
+type CommonAlertPromQLCreateOptions struct {
+	apis.Meta
+	monitor.CommonAlertCreateBaseInput
+
+	NAME       string  `help:"Name of the alert"`
+	EXPR       string  `help:"PromQL/MetricsQL expression evaluated by VictoriaMetrics, e.g. 'cpu_usage_active{res_type=\"host\"} > 90'"`
+	ResType    string  `help:"Resource type of the expression result, e.g. 'host', 'guest'"`
+	Reducer    string  `help:"Metric query reducer" choices:"avg|sum|min|max|count|last|median" default:"last"`
+	Comparator string  `help:"Evaluator compare, empty means every series returned by expression is firing" choices:">=|<=|==|>|<"`
+	Threshold  float64 `help:"Alert threshold"`
+	Period     string  `help:"Exec period of alert e.g. '1m', '5m'" default:"1m"`
+
+	EvaluatorType   string    `help:"Evaluator of the reduced series" choices:"seasonal_deviation|linear_forecast|holt_winters_forecast|rate_of_change"`
+	EvaluatorParams []float64 `help:"Extra params of evaluator, e.g. forecast horizon seconds"`
+	From            string    `help:"Query range of the expression e.g. '5m', '1h'" default:"5m"`
+	Interval        string    `help:"Query step of the expression e.g. '1m'"`
+	// 报警级别
+	Level string `json:"level"`
+}
+
+func (o *CommonAlertPromQLCreateOptions) Params() (jsonutils.JSONObject, error) {
+	input := monitor.CommonAlertCreateInput{
+		CommonMetricInputQuery: monitor.CommonMetricInputQuery{
+			From:     o.From,
+			Interval: o.Interval,
+			MetricQuery: []*monitor.CommonAlertQuery{
+				{
+					AlertQuery: &monitor.AlertQuery{
+						From:    o.From,
+						Expr:    o.EXPR,
+						ResType: o.ResType,
+					},
+					Reduce:          o.Reducer,
+					Comparator:      o.Comparator,
+					Threshold:       o.Threshold,
+					EvaluatorType:   o.EvaluatorType,
+					EvaluatorParams: o.EvaluatorParams,
+					ConditionType:   monitor.METRIC_QUERY_TYPE_PROMQL,
+				},
+			},
+		},
+		AlertCreateInput: monitor.AlertCreateInput{
+			Name:  o.NAME,
+			Level: o.Level,
+		},
+		CommonAlertCreateBaseInput: o.CommonAlertCreateBaseInput,
+		Period:                     o.Period,
+	}
+	input.Meta = o.Meta
+	return jsonutils.Marshal(input), nil
+}
@@ -15,6 +15,7 @@
 package monitor
 
 import (
+	"strconv"
 	"strings"
 
 	"yunion.io/x/jsonutils"
@@ -26,7 +27,7 @@ import (
 
 type MigrationAlertListOptions struct {
 	options.BaseListOptions
-	MetricType string `help:"Migration alert metric type" choices:"cpu.usage_active|mem.available"`
+	MetricType string `help:"Migration alert metric type" choices:"cpu.usage_active|mem.available|diskio.read_iops|diskio.write_iops|net.bps_recv|net.bps_sent"`
 }
 
 func (o *MigrationAlertListOptions) Params() (jsonutils.JSONObject, error) {
@@ -47,12 +48,14 @@ func (o *MigrationAlertShowOptions) GetId() string {
 
 type MigrationAlertCreateOptions struct {
 	NAME        string   `help:"Name of the migration alert"`
-	METRIC      string   `help:"Metric type" choices:"cpu.usage_active.gt|mem.available.lt"`
+	METRIC      string   `help:"Metric type" choices:"cpu.usage_active.gt|mem.available.lt|diskio.read_iops.gt|diskio.write_iops.gt|net.bps_recv.gt|net.bps_sent.gt"`
 	THRESHOLD   float64  `help:"Metric threshold"`
 	Period      string   `help:"Period of execution, e.g. '5m', '1h'" default:"5m"`
 	SourceHost  []string `help:"Source hosts' id or name"`
 	SourceGuest []string `help:"Source guests's id or name"`
 	TargetHost  []string `help:"Target hosts' id or name"`
+	MetricLimit []string `help:"Other metric limits of target host, e.g. 'net.bps_recv:1000000000'"`
+	DryRun      bool     `help:"Only generate migration plan, apply it by apply-plan"`
 }
 
 func (o *MigrationAlertCreateOptions) parseMetric(m string) (monitor.MigrationAlertMetricType, error) {
@@ -87,6 +90,21 @@ func (o *MigrationAlertCreateOptions) Params() (jsonutils.JSONObject, error) {
 	if len(o.TargetHost) != 0 {
 		input.MigrationSettings.Target.HostIds = o.TargetHost
 	}
+	for _, limit := range o.MetricLimit {
+		parts := strings.Split(limit, ":")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("Invalid metric limit %q", limit)
+		}
+		threshold, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse metric limit %q threshold", limit)
+		}
+		input.MigrationSettings.MetricLimits = append(input.MigrationSettings.MetricLimits, monitor.MigrationAlertSettingsMetricLimit{
+			MetricType: monitor.MigrationAlertMetricType(parts[0]),
+			Threshold:  threshold,
+		})
+	}
+	input.MigrationSettings.DryRun = o.DryRun
 
 	return input.JSON(input), nil
 }
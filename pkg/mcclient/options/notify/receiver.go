@@ -39,6 +39,25 @@ type ReceiverCreateOptions struct {
 	Mobile              string   `help:"mobile of receiver"`
 	MobileAreaCode      string   `help:"area code of mobile"`
 	EnabledContactTypes []string `help:"enabled contact type"`
+	ReceiverQuietHoursOptions
+}
+
+type ReceiverQuietHoursOptions struct {
+	QuietHoursStart string `help:"start of quiet hours in HH:MM, quiet hours are disabled if start equals end"`
+	QuietHoursEnd   string `help:"end of quiet hours in HH:MM"`
+	TimeZone        string `help:"time zone of quiet hours, e.g. Asia/Shanghai"`
+}
+
+func (rq *ReceiverQuietHoursOptions) update(d *jsonutils.JSONDict) {
+	if len(rq.QuietHoursStart) > 0 {
+		d.Set("quiet_hours_start", jsonutils.NewString(rq.QuietHoursStart))
+	}
+	if len(rq.QuietHoursEnd) > 0 {
+		d.Set("quiet_hours_end", jsonutils.NewString(rq.QuietHoursEnd))
+	}
+	if len(rq.TimeZone) > 0 {
+		d.Set("time_zone", jsonutils.NewString(rq.TimeZone))
+	}
 }
 
 func (rc *ReceiverCreateOptions) Params() (jsonutils.JSONObject, error) {
@@ -48,6 +67,7 @@ func (rc *ReceiverCreateOptions) Params() (jsonutils.JSONObject, error) {
 	d.Set("enabled_contact_types", jsonutils.NewStringArray(rc.EnabledContactTypes))
 	d.Add(jsonutils.NewString(rc.Mobile), "international_mobile", "mobile")
 	d.Add(jsonutils.NewString(rc.MobileAreaCode), "international_mobile", "area_code")
+	rc.ReceiverQuietHoursOptions.update(d)
 	return d, nil
 }
 
@@ -73,6 +93,7 @@ type SreceiverUpdateOptions struct {
 	Mobile             string   `help:"mobile of receiver"`
 	MobileAreaCode     string   `help:"area code of mobile"`
 	EnabledContactType []string `help:"enabled contact type"`
+	ReceiverQuietHoursOptions
 }
 
 func (ru *ReceiverUpdateOptions) Params() (jsonutils.JSONObject, error) {
@@ -85,6 +106,7 @@ func (ru *ReceiverUpdateOptions) Params() (jsonutils.JSONObject, error) {
 		d.Add(jsonutils.NewString(ru.Mobile), "international_mobile", "mobile")
 		d.Add(jsonutils.NewString(ru.MobileAreaCode), "international_mobile", "area_code")
 	}
+	ru.ReceiverQuietHoursOptions.update(d)
 	return d, nil
 }
 
@@ -94,7 +116,7 @@ type ReceiverTriggerVerifyOptions struct {
 }
 
 type SreceiverTriggerVerifyOptions struct {
-	ContactType string `help:"Contact type to trigger verify" choices:"email|mobile"`
+	ContactType string `help:"Contact type to trigger verify" choices:"email|mobile|dingtalk|feishu|workwx|slack|teams|telegram"`
 }
 
 func (rt *ReceiverTriggerVerifyOptions) Params() (jsonutils.JSONObject, error) {
@@ -116,8 +138,8 @@ type ReceiverVerifyOptions struct {
 }
 
 type SreceiverVerifyOptions struct {
-	ContactType string `help:"Contact type to trigger verify" choices:"email|mobile"`
-	Token       string `help:"Token from verify message sent to you"`
+	ContactType string `help:"Contact type to trigger verify" choices:"email|mobile|telegram"`
+	Token       string `help:"Token from verify message sent to you, not required for telegram"`
 }
 
 func (rv *ReceiverVerifyOptions) Params() (jsonutils.JSONObject, error) {
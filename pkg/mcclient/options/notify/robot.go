@@ -25,7 +25,7 @@ import (
 type RobotListOptions struct {
 	options.BaseListOptions
 	Lang    string
-	Type    string `choices:"feishu|dingtalk|workwx|webhook"`
+	Type    string `choices:"feishu|dingtalk|workwx|slack|teams|telegram|webhook"`
 	Enabled *bool
 }
 
@@ -35,7 +35,7 @@ func (rl *RobotListOptions) Params() (jsonutils.JSONObject, error) {
 
 type RobotCreateOptions struct {
 	NAME        string
-	Type        string `choices:"feishu|dingtalk|workwx|webhook"`
+	Type        string `choices:"feishu|dingtalk|workwx|slack|teams|telegram|webhook"`
 	Address     string
 	Lang        string
 	Header      string
@@ -44,7 +44,9 @@ type SubscriberCreateOptions struct {
 	Robot                 string   `help:"required if type is 'robot'"`
 	Scope                 string   `positional:"true"`
 	// minutes
-	GroupTimes int
+	GroupTimes        int
+	EscalationLevel   int `help:"escalation level, subscribers with level greater than 0 only receive fatal notifications not acknowledged in time"`
+	EscalationMinutes int `help:"minutes to wait for acknowledgement before escalating to this level"`
 }
 
 func (sc *SubscriberCreateOptions) Params() (jsonutils.JSONObject, error) {
@@ -82,7 +84,9 @@ type SubscriberChangeOptions struct {
 	RoleScope string
 	Robot     string
 	// minutes
-	GroupTimes *int
+	GroupTimes        *int
+	EscalationLevel   *int `help:"escalation level, subscribers with level greater than 0 only receive fatal notifications not acknowledged in time"`
+	EscalationMinutes *int `help:"minutes to wait for acknowledgement before escalating to this level"`
 }
 
 func (ssr *SubscriberChangeOptions) Params() (jsonutils.JSONObject, error) {
@@ -121,6 +121,15 @@ func (opts *VpcStatusOptions) Params() (jsonutils.JSONObject, error) {
 	return jsonutils.Marshal(map[string]string{"status": opts.STATUS}), nil
 }
 
+type VpcAcceptPeeringOptions struct {
+	VpcIdOptions
+	VPC_PEERING_CONNECTION_ID string `help:"ID of the vpc peering connection to accept" json:"vpc_peering_connection_id"`
+}
+
+func (opts *VpcAcceptPeeringOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"vpc_peering_connection_id": opts.VPC_PEERING_CONNECTION_ID}), nil
+}
+
 type VpcChangeOwnerOptions struct {
 	VpcIdOptions
 	ProjectDomain string `json:"project_domain" help:"target domain"`
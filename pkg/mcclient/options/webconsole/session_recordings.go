@@ -0,0 +1,59 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type SessionRecordingListOptions struct {
+	options.BaseListOptions
+
+	SessionId []string `help:"filter by webconsole session id"`
+	ObjId     []string `help:"filter by id of the connected object"`
+	ObjType   []string `help:"filter by type of the connected object"`
+	ObjName   []string `help:"filter by name of the connected object"`
+	UserId    string   `help:"filter by id or name of the user who opened the session, for admin only"`
+	LoginUser []string `help:"filter by login user of the connected object"`
+	Protocol  []string `help:"filter by session protocol"`
+	Since     string   `help:"recordings started after this time, e.g. 2023-05-01T00:00:00Z"`
+	Until     string   `help:"recordings started before this time, e.g. 2023-05-02T00:00:00Z"`
+}
+
+func (opts *SessionRecordingListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.ListStructToParams(opts)
+}
+
+type SessionRecordingIdOptions struct {
+	options.BaseIdOptions
+}
+
+type SessionRecordingReplayOptions struct {
+	options.BaseIdOptions
+
+	Offset int `help:"index of the first event to return"`
+	Limit  int `help:"max number of events to return"`
+}
+
+func (opts *SessionRecordingReplayOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(opts)
+}
+
+type SessionRecordingDownloadOptions struct {
+	ID     string `help:"ID or name of session recording"`
+	Output string `help:"file to save the recording, default is <ID>.cast" short-token:"o"`
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconsole
+
+import (
+	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/mcclient/options"
+)
+
+type SessionListOptions struct {
+	Admin bool `help:"list sessions of all users, require admin privilege"`
+}
+
+func (opts *SessionListOptions) Params() (jsonutils.JSONObject, error) {
+	return options.StructToParams(opts)
+}
+
+type SessionIdOptions struct {
+	ID string `help:"ID of webconsole session"`
+}
+
+type SessionShareOptions struct {
+	SessionIdOptions
+
+	USER string `help:"ID or name of the invited user"`
+	Mode string `help:"share mode" choices:"read|write" default:"read"`
+}
+
+func (opts *SessionShareOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"user": opts.USER, "mode": opts.Mode}), nil
+}
+
+type SessionUnshareOptions struct {
+	SessionIdOptions
+
+	USER string `help:"ID or name of the invited user"`
+}
+
+func (opts *SessionUnshareOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"user": opts.USER}), nil
+}
+
+type SessionAttachOptions struct {
+	SessionIdOptions
+
+	Mode string `help:"attach mode, default is the mode of the invitation" choices:"read|write"`
+}
+
+func (opts *SessionAttachOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"mode": opts.Mode}), nil
+}
+
+type SessionMessageOptions struct {
+	SessionIdOptions
+
+	MESSAGE string `help:"message shown on the terminals of the session"`
+}
+
+func (opts *SessionMessageOptions) Params() (jsonutils.JSONObject, error) {
+	return jsonutils.Marshal(map[string]string{"message": opts.MESSAGE}), nil
+}
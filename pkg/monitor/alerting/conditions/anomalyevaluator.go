@@ -0,0 +1,366 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/validators"
+)
+
+const (
+	defaultSeasons            = 4
+	defaultSeasonPeriodHours  = 24 * 7
+	defaultHoltSmoothFactor   = 0.5
+	defaultHoltTrendFactor    = 0.5
+	defaultRateOfChangeSecond = 60
+)
+
+// SeriesAlertEvaluator 需要整个时间序列才能判断是否触发的 evaluator, 比如趋势预测和变化率
+type SeriesAlertEvaluator interface {
+	AlertEvaluator
+	EvalSeries(series *monitor.TimeSeries) bool
+}
+
+// BaselineAlertEvaluator 需要历史同期数据作为基线的 evaluator,
+// BaselineOffsets 返回各个历史窗口相对于当前查询窗口的偏移
+type BaselineAlertEvaluator interface {
+	AlertEvaluator
+	BaselineOffsets() []time.Duration
+	EvalBaseline(reducedValue *float64, baseline []float64) bool
+}
+
+// evalDirection 返回比较方向, gt 为 1, lt 为 -1, 其它为 0 表示双向
+func evalDirection(cond *monitor.Condition) int {
+	if len(cond.Operators) == 0 {
+		return 0
+	}
+	switch cond.Operators[0] {
+	case "gt":
+		return 1
+	case "lt":
+		return -1
+	}
+	return 0
+}
+
+func directionString(direction int) string {
+	switch direction {
+	case 1:
+		return ">"
+	case -1:
+		return "<"
+	}
+	return "<>"
+}
+
+func crossThreshold(direction int, val, threshold float64) bool {
+	if direction < 0 {
+		return val <= threshold
+	}
+	return val >= threshold
+}
+
+func getParam(cond *monitor.Condition, idx int, defVal float64) float64 {
+	if len(cond.Params) > idx && cond.Params[idx] != 0 {
+		return cond.Params[idx]
+	}
+	return defVal
+}
+
+type seriesPoint struct {
+	// 秒
+	timestamp float64
+	value     float64
+}
+
+// validSeriesPoints 返回按时间排序后的有效点, 时间单位转换为秒
+func validSeriesPoints(series *monitor.TimeSeries) []seriesPoint {
+	points := make([]seriesPoint, 0, len(series.Points))
+	for _, p := range series.Points {
+		if !p.IsValid() {
+			continue
+		}
+		points = append(points, seriesPoint{
+			timestamp: p.Timestamp() / 1000,
+			value:     p.Value(),
+		})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].timestamp < points[j].timestamp
+	})
+	return points
+}
+
+// seasonalDeviationEvaluator 将当前值和过去若干个周期同一时间窗口的值做比较,
+// 偏离历史均值超过 Threshold 个标准差(z-score)时触发
+//
+// Params: [z-score 阈值, 周期数(默认 4), 周期小时数(默认 168, 即按周)]
+// Operators: [gt|lt], 为空时双向判断
+type seasonalDeviationEvaluator struct {
+	Threshold float64
+	Seasons   int
+	Period    time.Duration
+	Direction int
+}
+
+func newSeasonalDeviationEvaluator(cond *monitor.Condition) (*seasonalDeviationEvaluator, error) {
+	if len(cond.Params) == 0 || cond.Params[0] <= 0 {
+		return nil, errors.Wrap(validators.ErrMissingParameterThreshold, "seasonal deviation z-score must be positive")
+	}
+	seasons := int(getParam(cond, 1, defaultSeasons))
+	if seasons < 2 {
+		return nil, errors.Errorf("seasonal deviation needs at least 2 seasons, got %d", seasons)
+	}
+	periodHours := getParam(cond, 2, defaultSeasonPeriodHours)
+	if periodHours <= 0 {
+		return nil, errors.Errorf("invalid seasonal period %v hours", periodHours)
+	}
+	return &seasonalDeviationEvaluator{
+		Threshold: cond.Params[0],
+		Seasons:   seasons,
+		Period:    time.Duration(periodHours * float64(time.Hour)),
+		Direction: evalDirection(cond),
+	}, nil
+}
+
+func (e *seasonalDeviationEvaluator) BaselineOffsets() []time.Duration {
+	offsets := make([]time.Duration, e.Seasons)
+	for i := range offsets {
+		offsets[i] = time.Duration(i+1) * e.Period
+	}
+	return offsets
+}
+
+func (e *seasonalDeviationEvaluator) Eval(reducedValue *float64) bool {
+	// 没有历史基线无法判断
+	return false
+}
+
+func (e *seasonalDeviationEvaluator) EvalBaseline(reducedValue *float64, baseline []float64) bool {
+	if reducedValue == nil || len(baseline) < 2 {
+		return false
+	}
+	mean := 0.0
+	for _, v := range baseline {
+		mean += v
+	}
+	mean /= float64(len(baseline))
+	variance := 0.0
+	for _, v := range baseline {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(baseline)))
+
+	diff := *reducedValue - mean
+	var score float64
+	if stddev == 0 {
+		if diff == 0 {
+			return false
+		}
+		score = math.Inf(1)
+		if diff < 0 {
+			score = math.Inf(-1)
+		}
+	} else {
+		score = diff / stddev
+	}
+	switch e.Direction {
+	case 1:
+		return score >= e.Threshold
+	case -1:
+		return score <= -e.Threshold
+	}
+	return math.Abs(score) >= e.Threshold
+}
+
+func (e *seasonalDeviationEvaluator) String() string {
+	return fmt.Sprintf("%s %s %.2f sigma of last %d seasons(%s)", monitor.EVALUATOR_SEASONAL_DEVIATION, directionString(e.Direction), e.Threshold, e.Seasons, e.Period)
+}
+
+// forecastEvaluator 根据序列的趋势预测 Horizon 之后的值, 预测值越过 Threshold 时触发
+//
+// linear_forecast Params: [阈值, 预测时长(秒)]
+// holt_winters_forecast Params: [阈值, 预测时长(秒), 平滑系数(默认 0.5), 趋势系数(默认 0.5)]
+// Operators: [gt|lt], 默认 gt
+type forecastEvaluator struct {
+	Type         string
+	Threshold    float64
+	Horizon      time.Duration
+	SmoothFactor float64
+	TrendFactor  float64
+	Direction    int
+}
+
+func newForecastEvaluator(cond *monitor.Condition) (*forecastEvaluator, error) {
+	if len(cond.Params) < 2 {
+		return nil, errors.Wrapf(validators.ErrMissingParameterThreshold, "%s needs threshold and horizon", cond.Type)
+	}
+	if cond.Params[1] <= 0 {
+		return nil, errors.Errorf("invalid forecast horizon %v seconds", cond.Params[1])
+	}
+	e := &forecastEvaluator{
+		Type:      cond.Type,
+		Threshold: cond.Params[0],
+		Horizon:   time.Duration(cond.Params[1] * float64(time.Second)),
+		Direction: evalDirection(cond),
+	}
+	if e.Direction == 0 {
+		e.Direction = 1
+	}
+	if cond.Type == monitor.EVALUATOR_HOLT_WINTERS_FORECAST {
+		e.SmoothFactor = getParam(cond, 2, defaultHoltSmoothFactor)
+		e.TrendFactor = getParam(cond, 3, defaultHoltTrendFactor)
+		if e.SmoothFactor <= 0 || e.SmoothFactor >= 1 {
+			return nil, errors.Errorf("invalid smoothing factor %v, should be in (0, 1)", e.SmoothFactor)
+		}
+		if e.TrendFactor <= 0 || e.TrendFactor >= 1 {
+			return nil, errors.Errorf("invalid trend factor %v, should be in (0, 1)", e.TrendFactor)
+		}
+	}
+	return e, nil
+}
+
+func (e *forecastEvaluator) Eval(reducedValue *float64) bool {
+	return false
+}
+
+func (e *forecastEvaluator) EvalSeries(series *monitor.TimeSeries) bool {
+	predicted, ok := e.Forecast(series)
+	if !ok {
+		return false
+	}
+	// 趋势为线性外推, 预测值越过阈值即表示在 Horizon 内会越过阈值
+	return crossThreshold(e.Direction, predicted, e.Threshold)
+}
+
+// Forecast 返回最后一个点之后 Horizon 时刻的预测值
+func (e *forecastEvaluator) Forecast(series *monitor.TimeSeries) (float64, bool) {
+	points := validSeriesPoints(series)
+	if len(points) < 2 {
+		return 0, false
+	}
+	if e.Type == monitor.EVALUATOR_HOLT_WINTERS_FORECAST {
+		return holtForecast(points, e.SmoothFactor, e.TrendFactor, e.Horizon)
+	}
+	return linearForecast(points, e.Horizon)
+}
+
+func (e *forecastEvaluator) String() string {
+	return fmt.Sprintf("%s(%s) %s %.2f", e.Type, e.Horizon, directionString(e.Direction), e.Threshold)
+}
+
+// linearForecast 使用最小二乘法拟合直线
+func linearForecast(points []seriesPoint, horizon time.Duration) (float64, bool) {
+	n := float64(len(points))
+	base := points[0].timestamp
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := p.timestamp - base
+		sumX += x
+		sumY += p.value
+		sumXY += x * p.value
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	x := points[len(points)-1].timestamp - base + horizon.Seconds()
+	return intercept + slope*x, true
+}
+
+// holtForecast 使用二次指数平滑(Holt 线性趋势), 与 PromQL 的 holt_winters 一致
+func holtForecast(points []seriesPoint, sf, tf float64, horizon time.Duration) (float64, bool) {
+	step := (points[len(points)-1].timestamp - points[0].timestamp) / float64(len(points)-1)
+	if step <= 0 {
+		return 0, false
+	}
+	level := points[0].value
+	trend := points[1].value - points[0].value
+	for i := 1; i < len(points); i++ {
+		prevLevel := level
+		level = sf*points[i].value + (1-sf)*(level+trend)
+		trend = tf*(level-prevLevel) + (1-tf)*trend
+	}
+	return level + trend*horizon.Seconds()/step, true
+}
+
+// rateOfChangeEvaluator 计算序列首尾两个有效点之间的变化率, 变化率越过 Threshold 时触发
+//
+// Params: [阈值, 变化率的时间单位(秒, 默认 60 即每分钟)]
+// Operators: [gt|lt], 默认 gt
+type rateOfChangeEvaluator struct {
+	Threshold float64
+	Per       time.Duration
+	Direction int
+}
+
+func newRateOfChangeEvaluator(cond *monitor.Condition) (*rateOfChangeEvaluator, error) {
+	if len(cond.Params) == 0 {
+		return nil, errors.Wrap(validators.ErrMissingParameterThreshold, "rate of change")
+	}
+	per := getParam(cond, 1, defaultRateOfChangeSecond)
+	if per <= 0 {
+		return nil, errors.Errorf("invalid rate of change unit %v seconds", per)
+	}
+	e := &rateOfChangeEvaluator{
+		Threshold: cond.Params[0],
+		Per:       time.Duration(per * float64(time.Second)),
+		Direction: evalDirection(cond),
+	}
+	if e.Direction == 0 {
+		e.Direction = 1
+	}
+	return e, nil
+}
+
+func (e *rateOfChangeEvaluator) Eval(reducedValue *float64) bool {
+	return false
+}
+
+func (e *rateOfChangeEvaluator) EvalSeries(series *monitor.TimeSeries) bool {
+	rate, ok := e.Rate(series)
+	if !ok {
+		return false
+	}
+	return crossThreshold(e.Direction, rate, e.Threshold)
+}
+
+// Rate 返回每 Per 时间的变化量
+func (e *rateOfChangeEvaluator) Rate(series *monitor.TimeSeries) (float64, bool) {
+	points := validSeriesPoints(series)
+	if len(points) < 2 {
+		return 0, false
+	}
+	first, last := points[0], points[len(points)-1]
+	duration := last.timestamp - first.timestamp
+	if duration <= 0 {
+		return 0, false
+	}
+	return (last.value - first.value) / duration * e.Per.Seconds(), true
+}
+
+func (e *rateOfChangeEvaluator) String() string {
+	return fmt.Sprintf("%s(per %s) %s %.2f", monitor.EVALUATOR_RATE_OF_CHANGE, e.Per, directionString(e.Direction), e.Threshold)
+}
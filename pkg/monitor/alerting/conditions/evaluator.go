@@ -42,6 +42,18 @@ func (e *noValueEvaluator) String() string {
 	return "no_data"
 }
 
+// hasValueEvaluator 用于 PromQL 表达式自带比较运算的情况,
+// 表达式返回的每条有值的序列都视为触发
+type hasValueEvaluator struct{}
+
+func (e *hasValueEvaluator) Eval(reducedValue *float64) bool {
+	return reducedValue != nil
+}
+
+func (e *hasValueEvaluator) String() string {
+	return "has_value"
+}
+
 type thresholdEvaluator struct {
 	Type      string
 	Threshold float64
@@ -141,9 +153,22 @@ func NewAlertEvaluator(cond *monitor.Condition) (AlertEvaluator, error) {
 		return newRangedEvaluator(cond)
 	}
 
+	switch typ {
+	case monitor.EVALUATOR_SEASONAL_DEVIATION:
+		return newSeasonalDeviationEvaluator(cond)
+	case monitor.EVALUATOR_LINEAR_FORECAST, monitor.EVALUATOR_HOLT_WINTERS_FORECAST:
+		return newForecastEvaluator(cond)
+	case monitor.EVALUATOR_RATE_OF_CHANGE:
+		return newRateOfChangeEvaluator(cond)
+	}
+
 	if typ == "no_value" {
 		return &noValueEvaluator{}, nil
 	}
 
+	if typ == "has_value" {
+		return &hasValueEvaluator{}, nil
+	}
+
 	return nil, errors.Wrapf(validators.ErrInvalidEvaluatorType, "type: %s", typ)
 }
@@ -16,6 +16,7 @@ package conditions
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 
@@ -65,4 +66,152 @@ func TestEvalutors(t *testing.T) {
 			So(evaluator.Eval(nil), ShouldBeTrue)
 		})
 	})
+	Convey("has_value", t, func() {
+		Convey("should be true if series have values", func() {
+			So(evalutorScenario("has_value", nil, 0), ShouldBeTrue)
+		})
+
+		Convey("should be false when the series have no value", func() {
+			evaluator, err := NewAlertEvaluator(&monitor.Condition{Type: "has_value"})
+			So(err, ShouldBeNil)
+			So(evaluator.Eval(nil), ShouldBeFalse)
+		})
+	})
+}
+
+// newTestSeries 生成每分钟一个点的序列, 时间单位为毫秒
+func newTestSeries(values ...float64) *monitor.TimeSeries {
+	series := &monitor.TimeSeries{
+		Name: "test time series",
+	}
+	for i := range values {
+		series.Points = append(series.Points, monitor.NewTimePointByVal(values[i], float64(1699414400000+i*60000)))
+	}
+	return series
+}
+
+func newTestAlertEvaluator(typ string, params []float64, operators ...string) AlertEvaluator {
+	evaluator, err := NewAlertEvaluator(&monitor.Condition{Type: typ, Params: params, Operators: operators})
+	So(err, ShouldBeNil)
+	return evaluator
+}
+
+func TestAdvancedEvalutors(t *testing.T) {
+	Convey("seasonal_deviation", t, func() {
+		evaluator := newTestAlertEvaluator(monitor.EVALUATOR_SEASONAL_DEVIATION, []float64{3})
+		be, ok := evaluator.(BaselineAlertEvaluator)
+		So(ok, ShouldBeTrue)
+		So(be.BaselineOffsets(), ShouldResemble, []time.Duration{
+			168 * time.Hour, 336 * time.Hour, 504 * time.Hour, 672 * time.Hour,
+		})
+
+		baseline := []float64{10, 12, 8, 10}
+		val := 20.0
+		So(be.EvalBaseline(&val, baseline), ShouldBeTrue)
+		val = 11
+		So(be.EvalBaseline(&val, baseline), ShouldBeFalse)
+		val = 0
+		So(be.EvalBaseline(&val, baseline), ShouldBeTrue)
+
+		Convey("should only alert above baseline with gt operator", func() {
+			be := newTestAlertEvaluator(monitor.EVALUATOR_SEASONAL_DEVIATION, []float64{3, 2, 24}, "gt").(BaselineAlertEvaluator)
+			So(be.BaselineOffsets(), ShouldResemble, []time.Duration{24 * time.Hour, 48 * time.Hour})
+			val := 0.0
+			So(be.EvalBaseline(&val, baseline), ShouldBeFalse)
+			val = 20
+			So(be.EvalBaseline(&val, baseline), ShouldBeTrue)
+		})
+
+		Convey("should not alert without enough baseline", func() {
+			val := 100.0
+			So(be.EvalBaseline(&val, []float64{10}), ShouldBeFalse)
+			So(be.EvalBaseline(nil, baseline), ShouldBeFalse)
+		})
+
+		Convey("should alert when baseline is flat and value differs", func() {
+			val := 10.5
+			So(be.EvalBaseline(&val, []float64{10, 10, 10}), ShouldBeTrue)
+			val = 10
+			So(be.EvalBaseline(&val, []float64{10, 10, 10}), ShouldBeFalse)
+		})
+
+		Convey("should reject invalid params", func() {
+			_, err := NewAlertEvaluator(&monitor.Condition{Type: monitor.EVALUATOR_SEASONAL_DEVIATION})
+			So(err, ShouldNotBeNil)
+			_, err = NewAlertEvaluator(&monitor.Condition{Type: monitor.EVALUATOR_SEASONAL_DEVIATION, Params: []float64{3, 1}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("linear_forecast", t, func() {
+		// 每分钟增长 1, 当前值 14
+		series := newTestSeries(10, 11, 12, 13, 14)
+
+		Convey("should alert when forecast crosses threshold within horizon", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_LINEAR_FORECAST, []float64{20, 600}).(SeriesAlertEvaluator)
+			So(se.EvalSeries(series), ShouldBeTrue)
+		})
+
+		Convey("should not alert when forecast is below threshold", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_LINEAR_FORECAST, []float64{20, 300}).(SeriesAlertEvaluator)
+			So(se.EvalSeries(series), ShouldBeFalse)
+		})
+
+		Convey("should alert on decreasing series with lt operator", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_LINEAR_FORECAST, []float64{0, 3600}, "lt").(SeriesAlertEvaluator)
+			So(se.EvalSeries(newTestSeries(100, 90, 80, 70)), ShouldBeTrue)
+			So(se.EvalSeries(series), ShouldBeFalse)
+		})
+
+		Convey("should not alert with less than two points", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_LINEAR_FORECAST, []float64{20, 600}).(SeriesAlertEvaluator)
+			So(se.EvalSeries(newTestSeries(100)), ShouldBeFalse)
+		})
+
+		Convey("should reject missing horizon", func() {
+			_, err := NewAlertEvaluator(&monitor.Condition{Type: monitor.EVALUATOR_LINEAR_FORECAST, Params: []float64{20}})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("holt_winters_forecast", t, func() {
+		series := newTestSeries(10, 11, 12, 13, 14)
+		evaluator := newTestAlertEvaluator(monitor.EVALUATOR_HOLT_WINTERS_FORECAST, []float64{20, 600, 0.5, 0.5})
+		fe := evaluator.(*forecastEvaluator)
+		predicted, ok := fe.Forecast(series)
+		So(ok, ShouldBeTrue)
+		So(predicted, ShouldAlmostEqual, 24, 0.0001)
+		So(fe.EvalSeries(series), ShouldBeTrue)
+
+		_, err := NewAlertEvaluator(&monitor.Condition{Type: monitor.EVALUATOR_HOLT_WINTERS_FORECAST, Params: []float64{20, 600, 1.5}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("rate_of_change", t, func() {
+		// 4 分钟增长 20, 每分钟 5
+		series := newTestSeries(10, 15, 20, 25, 30)
+
+		re := newTestAlertEvaluator(monitor.EVALUATOR_RATE_OF_CHANGE, []float64{5}).(*rateOfChangeEvaluator)
+		rate, ok := re.Rate(series)
+		So(ok, ShouldBeTrue)
+		So(rate, ShouldAlmostEqual, 5, 0.0001)
+		So(re.EvalSeries(series), ShouldBeTrue)
+
+		Convey("should use per seconds param", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_RATE_OF_CHANGE, []float64{200, 3600}).(SeriesAlertEvaluator)
+			So(se.EvalSeries(series), ShouldBeTrue)
+			se = newTestAlertEvaluator(monitor.EVALUATOR_RATE_OF_CHANGE, []float64{400, 3600}).(SeriesAlertEvaluator)
+			So(se.EvalSeries(series), ShouldBeFalse)
+		})
+
+		Convey("should alert on drop with lt operator", func() {
+			se := newTestAlertEvaluator(monitor.EVALUATOR_RATE_OF_CHANGE, []float64{-2}, "lt").(SeriesAlertEvaluator)
+			So(se.EvalSeries(newTestSeries(30, 25, 20)), ShouldBeTrue)
+			So(se.EvalSeries(series), ShouldBeFalse)
+		})
+
+		Convey("reduced value only should never fire", func() {
+			So(evalutorScenario(monitor.EVALUATOR_RATE_OF_CHANGE, []float64{5}, 100), ShouldBeFalse)
+		})
+	})
 }
@@ -0,0 +1,97 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/alerting"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+	"yunion.io/x/onecloud/pkg/monitor/validators"
+)
+
+func init() {
+	alerting.RegisterCondition(monitor.METRIC_QUERY_TYPE_PROMQL, func(model *monitor.AlertCondition, index int) (alerting.Condition, error) {
+		return newPromQLQueryCondition(model, index)
+	})
+}
+
+// PromQLQueryCondition 直接将 PromQL/MetricsQL 表达式交给数据源执行,
+// 结果序列的 label 作为 EvalMatch 的 tags, 其余的 reduce 和 evaluate 流程与 QueryCondition 一致
+type PromQLQueryCondition struct {
+	*QueryCondition
+
+	Expr string
+}
+
+func (c *PromQLQueryCondition) handleRequest(ctx context.Context, ds *tsdb.DataSource, req *tsdb.TsdbQuery) (*tsdb.Response, error) {
+	var step time.Duration
+	if len(c.Query.Model.Interval) != 0 {
+		step, _ = time.ParseDuration(c.Query.Model.Interval)
+	}
+	ret, err := tsdb.HandlePromQLRequest(ctx, ds, c.Expr, req.TimeRange, step)
+	if err != nil {
+		return nil, err
+	}
+	ret.RefId = "A"
+	return &tsdb.Response{
+		Results: map[string]*tsdb.QueryResult{
+			ret.RefId: ret,
+		},
+	}, nil
+}
+
+func newPromQLQueryCondition(model *monitor.AlertCondition, index int) (*PromQLQueryCondition, error) {
+	q := model.Query
+	if err := validators.ValidatePromQLConditionQuery(q); err != nil {
+		return nil, errors.Wrapf(err, "condition %d", index)
+	}
+
+	reducer, err := NewAlertReducer(&model.Reducer)
+	if err != nil {
+		return nil, fmt.Errorf("error in condition %v: %v", index, err)
+	}
+	evaluator, err := NewAlertEvaluator(&model.Evaluator)
+	if err != nil {
+		return nil, fmt.Errorf("error in condition %v: %v", index, err)
+	}
+	operator := model.Operator
+	if operator == "" {
+		operator = "and"
+	}
+
+	cond := &PromQLQueryCondition{
+		QueryCondition: &QueryCondition{
+			Index: index,
+			Query: AlertQuery{
+				Model: q.Model,
+				From:  q.From,
+				To:    q.To,
+			},
+			Reducer:   reducer,
+			Evaluator: evaluator,
+			Operator:  operator,
+			ResType:   q.ResType,
+		},
+		Expr: q.Expr,
+	}
+	cond.HandleRequest = cond.handleRequest
+	return cond, nil
+}
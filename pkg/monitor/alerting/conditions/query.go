@@ -18,7 +18,9 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
@@ -153,6 +155,14 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 	seriesList := ret.series
 	metas := ret.metas
 
+	var baselines map[string][]float64
+	if be, ok := c.Evaluator.(BaselineAlertEvaluator); ok {
+		baselines, err = c.queryBaselines(context, timeRange, be)
+		if err != nil {
+			return nil, errors.Wrap(err, "query baselines")
+		}
+	}
+
 	emptySeriesCount := 0
 	evalMatchCount := 0
 	var matches []*monitor.EvalMatch
@@ -163,6 +173,8 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 	//	return nil, errors.Wrap(err, "GetQueryResources err")
 	//}
 	for _, series := range seriesList {
+		// 填充资源信息前记录序列的 key, 用于匹配历史基线
+		key := getSeriesKey(series)
 		if len(c.ResType) != 0 {
 			isLatestOfSerie, resource := c.serieIsLatestResource(nil, series)
 			if !isLatestOfSerie {
@@ -171,7 +183,7 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 			c.FillSerieByResourceField(resource, series)
 		}
 		reducedValue, valStrArr := c.Reducer.Reduce(series)
-		evalMatch := c.Evaluator.Eval(reducedValue)
+		evalMatch := c.evalSeries(series, reducedValue, baselines[key])
 
 		if reducedValue == nil {
 			emptySeriesCount++
@@ -239,6 +251,56 @@ func (c *QueryCondition) Eval(context *alerting.EvalContext) (*alerting.Conditio
 	}, nil
 }
 
+func (c *QueryCondition) evalSeries(series *monitor.TimeSeries, reducedValue *float64, baseline []float64) bool {
+	switch e := c.Evaluator.(type) {
+	case BaselineAlertEvaluator:
+		return e.EvalBaseline(reducedValue, baseline)
+	case SeriesAlertEvaluator:
+		return e.EvalSeries(series)
+	}
+	return c.Evaluator.Eval(reducedValue)
+}
+
+// queryBaselines 查询历史同期窗口的数据, 按序列 key 返回各个窗口 reduce 之后的值
+func (c *QueryCondition) queryBaselines(evalCtx *alerting.EvalContext, timeRange *tsdb.TimeRange, e BaselineAlertEvaluator) (map[string][]float64, error) {
+	from := timeRange.MustGetFrom()
+	to := timeRange.MustGetTo()
+	ret := make(map[string][]float64)
+	for _, offset := range e.BaselineOffsets() {
+		tr := tsdb.NewTimeRange(
+			strconv.FormatInt(from.Add(-offset).UnixNano()/int64(time.Millisecond), 10),
+			strconv.FormatInt(to.Add(-offset).UnixNano()/int64(time.Millisecond), 10),
+		)
+		qr, err := c.executeQuery(evalCtx, tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "query baseline of offset %s", offset)
+		}
+		for _, series := range qr.series {
+			val, _ := c.Reducer.Reduce(series)
+			if val == nil {
+				continue
+			}
+			key := getSeriesKey(series)
+			ret[key] = append(ret[key], *val)
+		}
+	}
+	return ret, nil
+}
+
+func getSeriesKey(series *monitor.TimeSeries) string {
+	keys := make([]string, 0, len(series.Tags))
+	for k := range series.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys)+1)
+	pairs = append(pairs, series.Name)
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, series.Tags[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
 func (c *QueryCondition) serieIsLatestResource(resources map[string]jsonutils.JSONObject, series *monitor.TimeSeries) (bool, jsonutils.JSONObject) {
 	tagId := monitor.MEASUREMENT_TAG_ID[c.ResType]
 	if len(tagId) == 0 {
@@ -285,7 +347,7 @@ func (c *QueryCondition) NewEvalMatch(context *alerting.EvalContext, series moni
 	if err != nil {
 		return nil, errors.Wrap(err, "GetAlert to NewEvalMatch error")
 	}
-	evalMatch.Metric = fmt.Sprintf("%s.%s", alertDetails.Measurement, alertDetails.Field)
+	evalMatch.Metric = alertDetails.GetMetric()
 	queryKeyInfo := ""
 	if len(alertDetails.MeasurementDisplayName) > 0 && len(alertDetails.FieldDescription.DisplayName) > 0 {
 		queryKeyInfo = fmt.Sprintf("%s.%s", alertDetails.MeasurementDisplayName, alertDetails.FieldDescription.DisplayName)
@@ -304,8 +366,15 @@ func (c *QueryCondition) NewEvalMatch(context *alerting.EvalContext, series moni
 	c.FetchCustomizeEvalMatch(context, evalMatch, alertDetails)
 	//c.newRuleDescription(context, alertDetails)
 	//evalMatch.Condition = c.GenerateFormatCond(meta, queryKeyInfo).String()
-	msg := fmt.Sprintf("%s.%s %s %s", alertDetails.Measurement, alertDetails.Field,
+	msg := fmt.Sprintf("%s %s %s", evalMatch.Metric,
 		alertDetails.Comparator, alerting.RationalizeValueFromUnit(alertDetails.Threshold, evalMatch.Unit, ""))
+	if alertDetails.ConditionType == monitor.METRIC_QUERY_TYPE_PROMQL && len(alertDetails.Comparator) == 0 {
+		// PromQL 表达式自带比较运算
+		msg = evalMatch.Metric
+	}
+	if len(alertDetails.EvaluatorType) != 0 {
+		msg = fmt.Sprintf("%s %s", evalMatch.Metric, c.Evaluator.String())
+	}
 	if len(context.Rule.Message) == 0 {
 		context.Rule.Message = msg
 	}
@@ -182,7 +182,7 @@ func newRuleDescription(rule *Rule, alertDetails *monitor.CommonAlertMetricDetai
 	ruleDes := RuleDescription{
 		AlertRecordRule: monitor.AlertRecordRule{
 			ResType:         alertDetails.ResType,
-			Metric:          fmt.Sprintf("%s.%s", alertDetails.Measurement, alertDetails.Field),
+			Metric:          alertDetails.GetMetric(),
 			Measurement:     alertDetails.Measurement,
 			Database:        alertDetails.DB,
 			MeasurementDesc: alertDetails.MeasurementDisplayName,
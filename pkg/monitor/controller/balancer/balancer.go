@@ -41,9 +41,14 @@ func init() {
 	for _, drv := range []IMetricDriver{
 		newMemAvailable(),
 		newCPUUsageActive(),
+		newDiskReadIOPS(),
+		newDiskWriteIOPS(),
+		newNetBpsRecv(),
+		newNetBpsSent(),
 	} {
 		GetMetricDrivers().register(drv)
 	}
+	models.GetMigrationAlertManager().SetPlanExecutor(ExecutePlan)
 }
 
 var (
@@ -94,6 +99,8 @@ type ICondition interface {
 	// GetSourceThresholdDelta must > 0
 	GetSourceThresholdDelta(threshold float64, srcHost IHost) float64
 	IsFitTarget(settings *monitor.MigrationAlertSettings, t ITarget, c ICandidate) error
+	// GetTargetLoad 返回迁移后目标宿主机相对于阈值的负载，用于多个目标之间打分
+	GetTargetLoad(t ITarget, c ICandidate) float64
 }
 
 type Rules struct {
@@ -102,6 +109,8 @@ type Rules struct {
 	Source         *SourceRule
 	Target         *TargetRule
 	ResultMustPair bool
+
+	guards []*metricGuard
 }
 
 func (r *Rules) GetAlert() *models.SMigrationAlert {
@@ -176,10 +185,15 @@ func NewRules(_ *alerting.EvalContext, m *monitor.EvalMatch, alert *models.SMigr
 	if err != nil {
 		return nil, errors.Wrapf(err, "Get Condtion")
 	}
+	guards, err := newMetricGuards(drv, srcHostObj, targetHosts, cds, ds, msettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "newMetricGuards")
+	}
 	rs := &Rules{
 		Alert:          alert,
 		Condtion:       cond,
 		ResultMustPair: resultMustPair,
+		guards:         guards,
 	}
 	rs.Source = NewSourceRule(srcHost, cds)
 	rs.Target = NewTargetRule(targetHosts)
@@ -416,6 +430,17 @@ func DoBalance(ctx context.Context, s *mcclient.ClientSession, rules *Rules, rec
 		return err
 	}
 
+	settings, _ := rules.GetAlert().GetMigrationSettings()
+	if settings != nil && settings.DryRun {
+		// 只记录迁移计划，等待确认后通过 apply-plan 执行
+		plan := newMigrationPlan(rules, rst)
+		if err := rules.GetAlert().SetMigratePlan(plan); err != nil {
+			return errors.Wrap(err, "SetMigratePlan")
+		}
+		recorder.Record(s.GetToken(), rules.GetAlert(), plan, EventActionMigratePlan)
+		return nil
+	}
+
 	if err := doMigrate(ctx, s, rules, rst, recorder); err != nil {
 		return errors.Wrapf(err, "do migrate for result %#v", rst)
 	}
@@ -430,6 +455,21 @@ type result struct {
 type resultPair struct {
 	source ICandidate
 	target ITarget
+	// targetScore and targetExpectedScore are current of target before and after selected
+	targetScore         float64
+	targetExpectedScore float64
+	// load is the expected load of target, metricLoads are the loads of metric limits
+	load        float64
+	metricLoads map[string]float64
+}
+
+// PlanBalance 计算迁移计划但不执行迁移
+func PlanBalance(rules *Rules) (*monitor.MigrationPlan, error) {
+	rst, err := findResult(rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "find result to migrate")
+	}
+	return newMigrationPlan(rules, rst), nil
 }
 
 func findResult(rules *Rules) (*result, error) {
@@ -445,7 +485,7 @@ func findResult(rules *Rules) (*result, error) {
 
 	// 将找到的虚拟机分配到对应的宿主机，形成 1-1 配对
 	settings, _ := rules.GetAlert().GetMigrationSettings()
-	return pairMigratResult(settings, rules.Source, rules.Target, rules.Condtion, rules.ResultMustPair)
+	return pairMigratResult(settings, rules.Source, rules.Target, rules.Condtion, rules.guards, rules.ResultMustPair)
 }
 
 type IResource interface {
@@ -539,24 +579,67 @@ func findCandidates(src *SourceRule, cond ICondition) ([]ICandidate, error) {
 	return findFitCandidates(cds, delta)
 }
 
-func findFitTarget(settings *monitor.MigrationAlertSettings, c ICandidate, tr *TargetRule, targets iTargets, cond ICondition) (ITarget, error) {
+type fitTarget struct {
+	target      ITarget
+	load        float64
+	metricLoads map[string]float64
+}
+
+func (t *fitTarget) totalLoad() float64 {
+	total := t.load
+	for _, load := range t.metricLoads {
+		total += load
+	}
+	return total
+}
+
+func findFitTarget(settings *monitor.MigrationAlertSettings, c ICandidate, tr *TargetRule, targets iTargets, cond ICondition, guards []*metricGuard) (*fitTarget, error) {
 	// sort targets
 	sort.Sort(targets)
-	var errs []error
+	var (
+		errs []error
+		fit  *fitTarget
+	)
 	for i := range targets {
 		target := targets[i]
-		if err := cond.IsFitTarget(settings, target, c); err == nil {
-			return target, nil
-		} else {
+		ft, err := newFitTarget(settings, target, c, cond, guards)
+		if err != nil {
 			errs = append(errs, err)
+			continue
+		}
+		// 所有检查指标负载之和最小的目标宿主机最合适
+		if fit == nil || ft.totalLoad() < fit.totalLoad() {
+			fit = ft
 		}
 	}
-	return nil, errors.NewAggregate(errs)
+	if fit == nil {
+		return nil, errors.NewAggregate(errs)
+	}
+	return fit, nil
+}
+
+func newFitTarget(settings *monitor.MigrationAlertSettings, target ITarget, c ICandidate, cond ICondition, guards []*metricGuard) (*fitTarget, error) {
+	if err := cond.IsFitTarget(settings, target, c); err != nil {
+		return nil, err
+	}
+	ft := &fitTarget{
+		target:      target,
+		load:        cond.GetTargetLoad(target, c),
+		metricLoads: make(map[string]float64),
+	}
+	for _, guard := range guards {
+		load, err := guard.isFitTarget(settings, target, c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "host:%s:guest:%s", target.GetName(), c.GetName())
+		}
+		ft.metricLoads[string(guard.metricType)] = load
+	}
+	return ft, nil
 }
 
 func pairMigratResult(
 	settings *monitor.MigrationAlertSettings,
-	src *SourceRule, target *TargetRule, cond ICondition, mustPair bool) (*result, error) {
+	src *SourceRule, target *TargetRule, cond ICondition, guards []*metricGuard, mustPair bool) (*result, error) {
 	// all guests of source host to migrate
 	gsts := src.Candidates
 
@@ -564,7 +647,7 @@ func pairMigratResult(
 	hosts := target.Items
 	errs := []error{}
 	for _, gst := range gsts {
-		host, err := findFitTarget(settings, gst, target, hosts, cond)
+		fit, err := findFitTarget(settings, gst, target, hosts, cond, guards)
 		if err != nil {
 			err = errors.Wrapf(err, "not found target for guest %s on %s", gst.GetName(), gst.GetHostName())
 			if mustPair {
@@ -574,11 +657,20 @@ func pairMigratResult(
 				continue
 			}
 		}
+		host := fit.target
+		pair := &resultPair{
+			source:      gst,
+			target:      host,
+			targetScore: host.GetCurrent(),
+			load:        fit.load,
+			metricLoads: fit.metricLoads,
+		}
 		host.Selected(gst)
-		pairs = append(pairs, &resultPair{
-			source: gst,
-			target: host,
-		})
+		for _, guard := range guards {
+			guard.selected(host, gst)
+		}
+		pair.targetExpectedScore = host.GetCurrent()
+		pairs = append(pairs, pair)
 	}
 	if len(gsts) != len(pairs) {
 		if mustPair {
@@ -620,11 +712,14 @@ func doMigrate(ctx context.Context, s *mcclient.ClientSession, rules *Rules, rst
 }
 
 func doMigrateByPair(s *mcclient.ClientSession, pair *resultPair) (jsonutils.JSONObject, error) {
-	gst := pair.source
+	return doLiveMigrate(s, pair.source.GetId(), pair.target.GetId())
+}
+
+func doLiveMigrate(s *mcclient.ClientSession, guestId string, hostId string) (jsonutils.JSONObject, error) {
 	trueObj := true
 	input := &compute_options.ServerLiveMigrateOptions{
-		ID:              gst.GetId(),
-		PreferHost:      pair.target.GetId(),
+		ID:              guestId,
+		PreferHost:      hostId,
 		SkipCpuCheck:    &trueObj,
 		SkipKernelCheck: &trueObj,
 	}
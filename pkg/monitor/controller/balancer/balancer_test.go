@@ -19,6 +19,8 @@ import (
 	"testing"
 
 	"yunion.io/x/jsonutils"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
 )
 
 type floatC float64
@@ -112,3 +114,249 @@ func Test_findFitCandidates(t *testing.T) {
 		})
 	}
 }
+
+func newTestIOTarget(t *testing.T, id string, current float64) ITarget {
+	host, err := newTargetIOHost(jsonutils.Marshal(map[string]interface{}{
+		"id":        id,
+		"name":      id,
+		"mem_size":  1024,
+		"cpu_count": 4,
+	}))
+	if err != nil {
+		t.Fatalf("newTargetIOHost %s: %v", id, err)
+	}
+	host.SetCurrent(current)
+	return host
+}
+
+func newTestIOCandidate(t *testing.T, id string, score float64) ICandidate {
+	res, err := newGuestResource(jsonutils.Marshal(map[string]string{
+		"id":   id,
+		"name": id,
+	}), "src")
+	if err != nil {
+		t.Fatalf("newGuestResource %s: %v", id, err)
+	}
+	return &ioCandidate{
+		guestResource: res,
+		score:         score,
+	}
+}
+
+func Test_pairMigratResult(t *testing.T) {
+	type host struct {
+		id      string
+		current float64
+		// net is the current of guard metric
+		net float64
+	}
+	tests := []struct {
+		name       string
+		hosts      []host
+		guestScore float64
+		guestNet   float64
+		withGuard  bool
+		wantTarget string
+		wantErr    bool
+	}{
+		{
+			name: "least loaded host without metric limits",
+			hosts: []host{
+				{id: "h1", current: 20, net: 95},
+				{id: "h2", current: 10, net: 95},
+			},
+			guestScore: 5,
+			guestNet:   10,
+			wantTarget: "h2",
+		},
+		{
+			name: "metric limit exceeded on least loaded host",
+			hosts: []host{
+				{id: "h1", current: 20, net: 10},
+				{id: "h2", current: 10, net: 95},
+			},
+			guestScore: 5,
+			guestNet:   10,
+			withGuard:  true,
+			wantTarget: "h1",
+		},
+		{
+			name: "lowest total load",
+			hosts: []host{
+				{id: "h1", current: 10, net: 80},
+				{id: "h2", current: 20, net: 10},
+			},
+			guestScore: 5,
+			guestNet:   10,
+			withGuard:  true,
+			wantTarget: "h2",
+		},
+		{
+			name: "metric limit exceeded on all hosts",
+			hosts: []host{
+				{id: "h1", current: 20, net: 95},
+				{id: "h2", current: 10, net: 95},
+			},
+			guestScore: 5,
+			guestNet:   10,
+			withGuard:  true,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &metricGuard{
+				metricType: "net.bps_recv",
+				cond:       newIOCond(100),
+				targets:    make(map[string]ITarget),
+				candidates: make(map[string]ICandidate),
+			}
+			targets := make([]ITarget, 0)
+			for _, h := range tt.hosts {
+				targets = append(targets, newTestIOTarget(t, h.id, h.current))
+				guard.targets[h.id] = newTestIOTarget(t, h.id, h.net)
+			}
+			gst := newTestIOCandidate(t, "g1", tt.guestScore)
+			guard.candidates["g1"] = newTestIOCandidate(t, "g1", tt.guestNet)
+			guards := []*metricGuard{}
+			if tt.withGuard {
+				guards = append(guards, guard)
+			}
+
+			rst, err := pairMigratResult(
+				&monitor.MigrationAlertSettings{},
+				NewSourceRule(newTestIOTarget(t, "src", 100), []ICandidate{gst}),
+				NewTargetRule(targets), newIOCond(100), guards, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pairMigratResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			pair := rst.pairs[0]
+			if pair.target.GetId() != tt.wantTarget {
+				t.Errorf("pairMigratResult() target = %s, want %s", pair.target.GetId(), tt.wantTarget)
+			}
+			if pair.targetExpectedScore != pair.targetScore+tt.guestScore {
+				t.Errorf("pairMigratResult() expected score = %f, want %f", pair.targetExpectedScore, pair.targetScore+tt.guestScore)
+			}
+			if tt.withGuard {
+				for _, h := range tt.hosts {
+					if h.id != tt.wantTarget {
+						continue
+					}
+					if got := guard.targets[h.id].GetCurrent(); got != h.net+tt.guestNet {
+						t.Errorf("guard target current = %f, want %f", got, h.net+tt.guestNet)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_recheckPlanItems(t *testing.T) {
+	type item struct {
+		guest  string
+		target string
+	}
+	tests := []struct {
+		name    string
+		targets map[string]float64
+		items   []item
+		wantErr bool
+	}{
+		{
+			name:    "targets still fit",
+			targets: map[string]float64{"h1": 50, "h2": 60},
+			items:   []item{{"g1", "h1"}, {"g2", "h2"}},
+		},
+		{
+			name:    "target overloaded after plan created",
+			targets: map[string]float64{"h1": 90, "h2": 60},
+			items:   []item{{"g1", "h1"}},
+			wantErr: true,
+		},
+		{
+			name:    "target overloaded by previous items",
+			targets: map[string]float64{"h1": 60, "h2": 60},
+			items:   []item{{"g1", "h1"}, {"g2", "h1"}},
+			wantErr: true,
+		},
+		{
+			name:    "guest not a candidate any more",
+			targets: map[string]float64{"h1": 50},
+			items:   []item{{"g3", "h1"}},
+			wantErr: true,
+		},
+		{
+			name:    "target not available any more",
+			targets: map[string]float64{"h1": 50},
+			items:   []item{{"g1", "h3"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := make([]ITarget, 0)
+			for id, current := range tt.targets {
+				targets = append(targets, newTestIOTarget(t, id, current))
+			}
+			cds := []ICandidate{
+				newTestIOCandidate(t, "g1", 20),
+				newTestIOCandidate(t, "g2", 20),
+			}
+			plan := &monitor.MigrationPlan{}
+			for _, it := range tt.items {
+				plan.Items = append(plan.Items, monitor.MigrationPlanItem{
+					GuestId:      it.guest,
+					TargetHostId: it.target,
+				})
+			}
+			err := recheckPlanItems(
+				&monitor.MigrationAlertSettings{}, plan,
+				NewSourceRule(newTestIOTarget(t, "src", 100), cds),
+				NewTargetRule(targets), newIOCond(100), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("recheckPlanItems() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newHostMetricsFromSeries(t *testing.T) {
+	series := func(id, nic string, recv, sent float64) *monitor.TimeSeries {
+		return &monitor.TimeSeries{
+			Tags: map[string]string{"host_id": id, "interface": nic},
+			Points: monitor.TimeSeriesPoints{
+				monitor.TimePoint{&recv, &sent, float64(0)},
+			},
+		}
+	}
+	ss := monitor.TimeSeriesSlice{
+		series("h1", "eth0", 10, 1),
+		series("h1", "eth1", 20, 2),
+		series("h1", "lo", 100, 100),
+		series("h1", "br0", 30, 3),
+		series("h1", "vnet0", 40, 4),
+		series("h2", "ens3", 5, 6),
+	}
+	query := &TsdbQuery{
+		Fields:       []string{"bps_recv", "bps_sent"},
+		DeviceTag:    "interface",
+		DeviceFilter: isPhysicalNic,
+	}
+	hs := newHostMetricsFromSeries(ss, "host_id", query)
+	want := map[string]map[string]float64{
+		"h1": {"bps_recv": 30, "bps_sent": 3},
+		"h2": {"bps_recv": 5, "bps_sent": 6},
+	}
+	for id, values := range want {
+		m := hs.Get(id)
+		if m == nil {
+			t.Fatalf("host %s not found", id)
+		}
+		if !reflect.DeepEqual(m.Values, values) {
+			t.Errorf("host %s values = %v, want %v", id, m.Values, values)
+		}
+	}
+}
@@ -116,6 +116,11 @@ func (m *cpuCondition) IsFitTarget(settings *monitor.MigrationAlertSettings, t I
 	return errors.Errorf("host:%s:current(%f) + guest:%s:score(%f) >= threshold(%f)", t.GetName(), t.GetCurrent(), c.GetName(), c.GetScore(), m.GetThreshold())
 }
 
+func (m *cpuCondition) GetTargetLoad(t ITarget, c ICandidate) float64 {
+	tCPUCnt := t.(*targetCPUHost).GetCPUCount()
+	return loadRatio(t.GetCurrent()+c.(*cpuCandidate).getTargetScore(tCPUCnt), m.GetThreshold())
+}
+
 // cpuCandidate implements ICandidate
 type cpuCandidate struct {
 	*guestResource
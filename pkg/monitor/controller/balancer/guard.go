@@ -0,0 +1,163 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+// metricGuard 在选择目标宿主机时检查 MetricLimits 里的其它指标，
+// 避免迁移解决了报警指标却让目标宿主机的其它指标过载
+type metricGuard struct {
+	metricType monitor.MigrationAlertMetricType
+	cond       ICondition
+	// targets and candidates are indexed by resource id
+	targets    map[string]ITarget
+	candidates map[string]ICandidate
+}
+
+func newMetricGuards(
+	drv IMetricDriver,
+	srcHostObj jsonutils.JSONObject,
+	targets []ITarget,
+	cds []ICandidate,
+	ds *tsdb.DataSource,
+	ms *monitor.MigrationAlertSettings,
+) ([]*metricGuard, error) {
+	if ms == nil {
+		return nil, nil
+	}
+	guards := make([]*metricGuard, 0)
+	for _, limit := range ms.MetricLimits {
+		if limit.MetricType == drv.GetType() {
+			continue
+		}
+		gDrv, err := GetMetricDrivers().Get(limit.MetricType)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get metric limit driver")
+		}
+		guard, err := newMetricGuard(gDrv, limit.Threshold, srcHostObj, targets, cds, ds)
+		if err != nil {
+			return nil, errors.Wrapf(err, "new metric guard %s", limit.MetricType)
+		}
+		guards = append(guards, guard)
+	}
+	return guards, nil
+}
+
+func newMetricGuard(
+	drv IMetricDriver,
+	threshold float64,
+	srcHostObj jsonutils.JSONObject,
+	targets []ITarget,
+	cds []ICandidate,
+	ds *tsdb.DataSource,
+) (*metricGuard, error) {
+	srcHost, err := drv.GetTarget(srcHostObj)
+	if err != nil {
+		return nil, errors.Wrap(err, "get source host")
+	}
+	guard := &metricGuard{
+		metricType: drv.GetType(),
+		cond:       newGuardCond(drv.GetType(), threshold),
+		targets:    make(map[string]ITarget),
+		candidates: make(map[string]ICandidate),
+	}
+
+	hosts := make([]IResource, 0)
+	for _, t := range targets {
+		gt, err := drv.GetTarget(t.GetObject())
+		if err != nil {
+			return nil, errors.Wrapf(err, "get target %s", t.GetName())
+		}
+		hosts = append(hosts, gt)
+	}
+	if len(hosts) != 0 {
+		metrics, err := InfluxdbQuery(ds, "host_id", hosts, drv.GetTsdbQuery())
+		if err != nil {
+			return nil, errors.Wrap(err, "InfluxdbQuery target hosts metrics")
+		}
+		for _, host := range hosts {
+			m := metrics.Get(host.GetId())
+			if m == nil {
+				log.Warningf("metric %s of host %s not found, skip checking it", guard.metricType, host.GetName())
+				continue
+			}
+			if err := drv.SetHostCurrent(host.(IHost), m.Values); err != nil {
+				return nil, errors.Wrapf(err, "SetHostCurrent %q", host.GetName())
+			}
+			guard.targets[host.GetId()] = host.(ITarget)
+		}
+	}
+
+	for _, c := range cds {
+		gc, err := drv.GetCandidate(c.GetObject(), srcHost, ds)
+		if err != nil {
+			log.Warningf("metric %s of guest %s not found, skip checking it: %v", guard.metricType, c.GetName(), err)
+			continue
+		}
+		guard.candidates[c.GetId()] = gc
+	}
+	return guard, nil
+}
+
+func newGuardCond(t monitor.MigrationAlertMetricType, threshold float64) ICondition {
+	switch t {
+	case monitor.MigrationAlertMetricTypeCPUUsageActive:
+		return newCPUCond(threshold)
+	case monitor.MigrationAlertMetricTypeMemAvailable:
+		return newMemoryCond(threshold)
+	default:
+		return newIOCond(threshold)
+	}
+}
+
+func (g *metricGuard) getTargetAndCandidate(t ITarget, c ICandidate) (ITarget, ICandidate, bool) {
+	gt, ok := g.targets[t.GetId()]
+	if !ok {
+		return nil, nil, false
+	}
+	gc, ok := g.candidates[c.GetId()]
+	if !ok {
+		return nil, nil, false
+	}
+	return gt, gc, true
+}
+
+// isFitTarget returns the expected load of target host after candidate migrated to
+func (g *metricGuard) isFitTarget(settings *monitor.MigrationAlertSettings, t ITarget, c ICandidate) (float64, error) {
+	gt, gc, ok := g.getTargetAndCandidate(t, c)
+	if !ok {
+		// metric not collected, can't check it
+		return 0, nil
+	}
+	if err := g.cond.IsFitTarget(settings, gt, gc); err != nil {
+		return 0, errors.Wrapf(err, "metric limit %s", g.metricType)
+	}
+	return g.cond.GetTargetLoad(gt, gc), nil
+}
+
+func (g *metricGuard) selected(t ITarget, c ICandidate) {
+	gt, gc, ok := g.getTargetAndCandidate(t, c)
+	if !ok {
+		return
+	}
+	gt.Selected(gc)
+}
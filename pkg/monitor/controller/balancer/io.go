@@ -0,0 +1,254 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"regexp"
+	"strings"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+// ioMetric is the driver of host disk iops or network bandwidth,
+// the guest on source host brings the same amount of load to target host
+type ioMetric struct {
+	metricType monitor.MigrationAlertMetricType
+	hostQuery  *TsdbQuery
+	guestQuery *TsdbQuery
+}
+
+func newIOMetric(t monitor.MigrationAlertMetricType, hostQuery *TsdbQuery, guestQuery *TsdbQuery) IMetricDriver {
+	return &ioMetric{
+		metricType: t,
+		hostQuery:  hostQuery,
+		guestQuery: guestQuery,
+	}
+}
+
+var (
+	// 分区和虚拟块设备的读写已经计入所在的磁盘
+	virtualDiskRegexp = regexp.MustCompile(`^(loop|ram|dm-|sr|nbd|zram|md)`)
+	partitionRegexp   = regexp.MustCompile(`^((sd|vd|hd|xvd)[a-z]+\d+|(nvme\d+n|mmcblk)\d+p\d+)$`)
+
+	// 回环, 网桥, bond 和虚拟机的 tap 网卡上的流量已经计入物理网卡
+	virtualNicPrefixes = []string{"lo", "br", "bond", "team", "vnet", "tap", "veth", "virbr", "docker", "ovs-", "genev_sys", "vxlan_sys"}
+)
+
+func isPhysicalDisk(name string) bool {
+	return len(name) > 0 && !virtualDiskRegexp.MatchString(name) && !partitionRegexp.MatchString(name)
+}
+
+func isPhysicalNic(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, prefix := range virtualNicPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func newDiskIOMetric(t monitor.MigrationAlertMetricType, field string) IMetricDriver {
+	return newIOMetric(t,
+		&TsdbQuery{
+			Database:     monitor.METRIC_DATABASE_TELE,
+			Measurement:  "diskio",
+			Fields:       []string{field},
+			DeviceTag:    "name",
+			DeviceFilter: isPhysicalDisk,
+		},
+		&TsdbQuery{
+			Database:    monitor.METRIC_DATABASE_TELE,
+			Measurement: "vm_diskio",
+			Fields:      []string{field},
+		},
+	)
+}
+
+func newNetIOMetric(t monitor.MigrationAlertMetricType, field string) IMetricDriver {
+	return newIOMetric(t,
+		&TsdbQuery{
+			Database:     monitor.METRIC_DATABASE_TELE,
+			Measurement:  "net",
+			Fields:       []string{field},
+			DeviceTag:    "interface",
+			DeviceFilter: isPhysicalNic,
+		},
+		&TsdbQuery{
+			Database:    monitor.METRIC_DATABASE_TELE,
+			Measurement: "vm_netio",
+			Fields:      []string{field},
+			DeviceTag:   "ifname",
+		},
+	)
+}
+
+func newDiskReadIOPS() IMetricDriver {
+	return newDiskIOMetric(monitor.MigrationAlertMetricTypeDiskReadIOPS, "read_iops")
+}
+
+func newDiskWriteIOPS() IMetricDriver {
+	return newDiskIOMetric(monitor.MigrationAlertMetricTypeDiskWriteIOPS, "write_iops")
+}
+
+func newNetBpsRecv() IMetricDriver {
+	return newNetIOMetric(monitor.MigrationAlertMetricTypeNetBpsRecv, "bps_recv")
+}
+
+func newNetBpsSent() IMetricDriver {
+	return newNetIOMetric(monitor.MigrationAlertMetricTypeNetBpsSent, "bps_sent")
+}
+
+func (m *ioMetric) GetType() monitor.MigrationAlertMetricType {
+	return m.metricType
+}
+
+func (m *ioMetric) GetTsdbQuery() *TsdbQuery {
+	return m.hostQuery
+}
+
+func (m *ioMetric) GetCandidate(obj jsonutils.JSONObject, host IHost, ds *tsdb.DataSource) (ICandidate, error) {
+	return newIOCandidate(obj, host, ds, m.guestQuery)
+}
+
+func (m *ioMetric) SetHostCurrent(host IHost, vals map[string]float64) error {
+	return setHostCurrent(host, vals, m.hostQuery.Fields[0])
+}
+
+func (m *ioMetric) GetTarget(host jsonutils.JSONObject) (ITarget, error) {
+	return newTargetIOHost(host)
+}
+
+func (m *ioMetric) GetCondition(s *monitor.AlertSetting) (ICondition, error) {
+	t, err := GetAlertSettingThreshold(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetAlertSettingThreshold")
+	}
+	return newIOCond(t), nil
+}
+
+// ioCondition implements ICondition
+type ioCondition struct {
+	value float64
+}
+
+func newIOCond(val float64) ICondition {
+	return &ioCondition{
+		value: val,
+	}
+}
+
+func (c *ioCondition) GetThreshold() float64 {
+	return c.value
+}
+
+func (c *ioCondition) GetSourceThresholdDelta(threshold float64, host IHost) float64 {
+	return host.GetCurrent() - threshold
+}
+
+func (c *ioCondition) IsFitTarget(_ *monitor.MigrationAlertSettings, t ITarget, cd ICandidate) error {
+	tScore := t.GetCurrent() + cd.GetScore()
+	if tScore < c.GetThreshold() {
+		return nil
+	}
+	return errors.Errorf("host:%s:current(%f) + guest:%s:score(%f) >= threshold(%f)", t.GetName(), t.GetCurrent(), cd.GetName(), cd.GetScore(), c.GetThreshold())
+}
+
+func (c *ioCondition) GetTargetLoad(t ITarget, cd ICandidate) float64 {
+	return loadRatio(t.GetCurrent()+cd.GetScore(), c.GetThreshold())
+}
+
+// ioCandidate implements ICandidate
+type ioCandidate struct {
+	*guestResource
+	score float64
+}
+
+func newIOCandidate(gst jsonutils.JSONObject, host IHost, ds *tsdb.DataSource, query *TsdbQuery) (ICandidate, error) {
+	res, err := newGuestResource(gst, host.GetName())
+	if err != nil {
+		return nil, errors.Wrap(err, "newGuestResource")
+	}
+
+	metrics, err := InfluxdbQuery(ds, "vm_id", []IResource{res}, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "InfluxdbQuery guest %q(%q)", res.GetName(), res.GetId())
+	}
+	metric := metrics.Get(res.GetId())
+	if metric == nil {
+		return nil, errors.Errorf("not found resource %q metric from %#v", res.GetId(), metrics.indexes)
+	}
+	return &ioCandidate{
+		guestResource: res,
+		score:         metric.Values[query.Fields[0]],
+	}, nil
+}
+
+func (c *ioCandidate) GetScore() float64 {
+	return c.score
+}
+
+type ioHost struct {
+	*HostResource
+	value float64
+}
+
+func newIOHost(obj jsonutils.JSONObject) (IHost, error) {
+	host, err := newHostResource(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "newHostResource")
+	}
+	return &ioHost{
+		HostResource: host,
+	}, nil
+}
+
+func (h *ioHost) GetCurrent() float64 {
+	return h.value
+}
+
+func (h *ioHost) SetCurrent(val float64) IHost {
+	h.value = val
+	return h
+}
+
+func (h *ioHost) Compare(oh IHost) bool {
+	return h.GetCurrent() < oh.GetCurrent()
+}
+
+type targetIOHost struct {
+	IHost
+}
+
+func newTargetIOHost(obj jsonutils.JSONObject) (ITarget, error) {
+	host, err := newIOHost(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "newIOHost")
+	}
+	return &targetIOHost{
+		IHost: host,
+	}, nil
+}
+
+func (ts *targetIOHost) Selected(c ICandidate) ITarget {
+	ts.SetCurrent(ts.GetCurrent() + c.GetScore())
+	return ts
+}
@@ -15,6 +15,8 @@
 package balancer
 
 import (
+	"math"
+
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
@@ -108,6 +110,15 @@ func (m *memCondition) IsFitTarget(settings *monitor.MigrationAlertSettings, t I
 	return errors.Errorf("host:%s:current(%f) - guest:%s:score(%f) <= threshold(%f)", t.GetName(), t.GetCurrent(), c.GetName(), c.GetScore(), m.GetThreshold())
 }
 
+func (m *memCondition) GetTargetLoad(t ITarget, c ICandidate) float64 {
+	// mem.available 剩余越少负载越高
+	left := t.GetCurrent() - c.GetScore()
+	if left <= 0 {
+		return math.Inf(1)
+	}
+	return loadRatio(m.GetThreshold(), left)
+}
+
 // memCandidate implements ICandidate
 type memCandidate struct {
 	*guestResource
@@ -0,0 +1,164 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package balancer
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/monitor/models"
+)
+
+func newMigrationPlan(rules *Rules, rst *result) *monitor.MigrationPlan {
+	alert := rules.GetAlert()
+	srcHost := rules.Source.Host
+	plan := &monitor.MigrationPlan{
+		MetricType:     alert.GetMetricType(),
+		Threshold:      rules.Condtion.GetThreshold(),
+		SourceHostId:   srcHost.GetId(),
+		SourceHostName: srcHost.GetName(),
+		SourceScore:    srcHost.GetCurrent(),
+		Items:          make([]monitor.MigrationPlanItem, 0, len(rst.pairs)),
+		CreatedAt:      time.Now(),
+	}
+	for _, pair := range rst.pairs {
+		loads := map[string]float64{
+			string(alert.GetMetricType()): pair.load,
+		}
+		for k, v := range pair.metricLoads {
+			loads[k] = v
+		}
+		plan.Items = append(plan.Items, monitor.MigrationPlanItem{
+			GuestId:             pair.source.GetId(),
+			GuestName:           pair.source.GetName(),
+			GuestScore:          pair.source.GetScore(),
+			SourceHostId:        srcHost.GetId(),
+			SourceHostName:      srcHost.GetName(),
+			TargetHostId:        pair.target.GetId(),
+			TargetHostName:      pair.target.GetName(),
+			TargetScore:         pair.targetScore,
+			TargetExpectedScore: pair.targetExpectedScore,
+			MetricLoads:         loads,
+		})
+	}
+	return plan
+}
+
+func newPlanMigrateNote(item *monitor.MigrationPlanItem) (*models.MigrateNote, error) {
+	obj, err := models.GetMigrationAlertManager().GetGuestByIdOrName(item.GuestId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get guest %s(%s)", item.GuestName, item.GuestId)
+	}
+	hostId, _ := obj.GetString("host_id")
+	if hostId != item.SourceHostId {
+		return nil, errors.Errorf("guest %s(%s) not in source host %s, current %s", item.GuestName, item.GuestId, item.SourceHostId, hostId)
+	}
+	gst := new(models.MigrateNoteGuest)
+	if err := obj.Unmarshal(gst); err != nil {
+		return nil, errors.Wrap(err, "Unmarshal guest")
+	}
+	gst.Host = item.SourceHostName
+	gst.Score = item.GuestScore
+	return &models.MigrateNote{
+		Guest: gst,
+		Target: &models.MigrateNoteTarget{
+			Id:    item.TargetHostId,
+			Name:  item.TargetHostName,
+			Score: item.TargetExpectedScore,
+		},
+	}, nil
+}
+
+// recheckPlanItems 用当前的指标重新检查计划中的每一项, 虚拟机仍然可以迁移,
+// 目标宿主机仍然满足报警指标和 MetricLimits 的限制时才能执行
+func recheckPlanItems(settings *monitor.MigrationAlertSettings, plan *monitor.MigrationPlan, src *SourceRule, tr *TargetRule, cond ICondition, guards []*metricGuard) error {
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		var cd ICandidate
+		for _, c := range src.Candidates {
+			if c.GetId() == item.GuestId {
+				cd = c
+				break
+			}
+		}
+		if cd == nil {
+			return errors.Errorf("guest %s(%s) is not a migration candidate of host %s any more", item.GuestName, item.GuestId, item.SourceHostName)
+		}
+		var target ITarget
+		for _, t := range tr.Items {
+			if t.GetId() == item.TargetHostId {
+				target = t
+				break
+			}
+		}
+		if target == nil {
+			return errors.Errorf("host %s(%s) is not a migration target any more", item.TargetHostName, item.TargetHostId)
+		}
+		if _, err := newFitTarget(settings, target, cd, cond, guards); err != nil {
+			return errors.Wrapf(err, "host %s does not fit guest %s any more", item.TargetHostName, item.GuestName)
+		}
+		// 之前的项迁移后目标宿主机的负载
+		target.Selected(cd)
+		for _, guard := range guards {
+			guard.selected(target, cd)
+		}
+	}
+	return nil
+}
+
+func recheckPlan(alert *models.SMigrationAlert, plan *monitor.MigrationPlan) error {
+	drv, err := GetMetricDrivers().Get(alert.GetMetricType())
+	if err != nil {
+		return errors.Wrap(err, "get metric driver")
+	}
+	match := &monitor.EvalMatch{
+		Tags: map[string]string{"host_id": plan.SourceHostId},
+	}
+	rules, err := NewRules(nil, match, alert, drv, false)
+	if err != nil {
+		return errors.Wrap(err, "NewRules")
+	}
+	settings, _ := alert.GetMigrationSettings()
+	return recheckPlanItems(settings, plan, rules.Source, rules.Target, rules.Condtion, rules.guards)
+}
+
+// ExecutePlan 执行 dry_run 模式下生成并经过确认的迁移计划
+func ExecutePlan(ctx context.Context, s *mcclient.ClientSession, alert *models.SMigrationAlert, plan *monitor.MigrationPlan) error {
+	if err := recheckPlan(alert, plan); err != nil {
+		return errors.Wrap(err, "recheck migration plan")
+	}
+	recorder := NewRecorder()
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		note, err := newPlanMigrateNote(item)
+		if err != nil {
+			return errors.Wrap(err, "newPlanMigrateNote")
+		}
+		if _, err := doLiveMigrate(s, item.GuestId, item.TargetHostId); err != nil {
+			err = errors.Wrapf(err, "live migrate %s to %s", item.GuestName, item.TargetHostName)
+			recorder.RecordMigrateError(s.GetToken(), alert, note, err)
+			return err
+		}
+		if err := recorder.RecordMigrate(ctx, s, alert, note); err != nil {
+			log.Errorf("RecordMigrate %s to %s error: %v", item.GuestName, item.TargetHostName, err)
+		}
+	}
+	return nil
+}
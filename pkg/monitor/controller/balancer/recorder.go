@@ -40,6 +40,7 @@ const (
 	EventActionMigrateSuccess = "migrate_success"
 	EventActionMigrateFail    = "migrate_fail"
 	EventActionMigrateError   = "migrate_error"
+	EventActionMigratePlan    = "migrate_plan"
 )
 
 type IRecorder interface {
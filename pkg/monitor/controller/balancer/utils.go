@@ -16,6 +16,7 @@ package balancer
 
 import (
 	"context"
+	"math"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
@@ -58,6 +59,10 @@ type TsdbQuery struct {
 	Database    string
 	Measurement string
 	Fields      []string
+	// DeviceTag 是按设备上报的指标(如磁盘和网卡)的设备标签, 同一资源各设备的值相加
+	DeviceTag string
+	// DeviceFilter 返回 false 的设备不计入资源的值
+	DeviceFilter func(device string) bool
 }
 
 func InfluxdbQuery(
@@ -75,27 +80,52 @@ func InfluxdbQuery(
 		ids = append(ids, h.GetId())
 	}
 	q.Where().IN(idKey, ids)
-	q.GroupBy().TAG(idKey).FILL_NULL()
+	groupBy := q.GroupBy().TAG(idKey)
+	if len(query.DeviceTag) > 0 {
+		groupBy = groupBy.TAG(query.DeviceTag)
+	}
+	groupBy.FILL_NULL()
 	qCtx := q.ToTsdbQuery()
 
 	resp, err := tsdb.HandleRequest(context.Background(), ds, qCtx)
 	if err != nil {
 		return nil, errors.Wrap(err, "TSDB endpoint Query")
 	}
+	return newHostMetricsFromSeries(resp.Results[""].Series, idKey, query), nil
+}
 
-	ss := resp.Results[""].Series
-	ms := make([]*HostMetric, len(ss))
-	for i, s := range ss {
-		m := &HostMetric{
-			Id:     s.Tags[idKey],
-			Values: make(map[string]float64),
+// newHostMetricsFromSeries 每个序列是一个资源(或资源的一个设备)的平均值,
+// 同一资源各设备的平均值相加得到资源的值
+func newHostMetricsFromSeries(ss api.TimeSeriesSlice, idKey string, query *TsdbQuery) *HostMetrics {
+	ms := make([]*HostMetric, 0, len(ss))
+	indexes := make(map[string]*HostMetric)
+	for _, s := range ss {
+		if len(query.DeviceTag) > 0 && query.DeviceFilter != nil && !query.DeviceFilter(s.Tags[query.DeviceTag]) {
+			continue
+		}
+		id := s.Tags[idKey]
+		m, ok := indexes[id]
+		if !ok {
+			m = &HostMetric{
+				Id:     id,
+				Values: make(map[string]float64),
+			}
+			indexes[id] = m
+			ms = append(ms, m)
+		}
+		if len(s.Points) == 0 {
+			continue
 		}
 		for j, f := range query.Fields {
-			m.Values[f] = *(s.Points[0][j].(*float64))
+			if j >= len(s.Points[0]) {
+				break
+			}
+			if val, ok := s.Points[0][j].(*float64); ok && val != nil {
+				m.Values[f] += *val
+			}
 		}
-		ms[i] = m
 	}
-	return NewHostMetrics(ms), nil
+	return NewHostMetrics(ms)
 }
 
 func GetAlertSettingThreshold(s *api.AlertSetting) (float64, error) {
@@ -104,3 +134,14 @@ func GetAlertSettingThreshold(s *api.AlertSetting) (float64, error) {
 	}
 	return s.Conditions[0].Evaluator.Params[0], nil
 }
+
+// loadRatio 计算 val 相对于阈值的比例，大于等于 1 表示超过阈值
+func loadRatio(val float64, threshold float64) float64 {
+	if threshold <= 0 {
+		if val <= 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return val / threshold
+}
@@ -309,6 +309,9 @@ func (dash *SAlertPanel) ValidateUpdateData(
 			ownerId = userCred
 		}
 		scope, _ := data.GetString("scope")
+		if len(scope) == 0 {
+			scope = string(dash.GetResourceScope())
+		}
 		err = CommonAlertManager.ValidateMetricQuery(metricQuery, scope, ownerId)
 		if err != nil {
 			return data, errors.Wrap(err, "metric query error")
@@ -44,6 +44,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/mcclient/modules/notify"
 	"yunion.io/x/onecloud/pkg/mcclient/modules/yunionconf"
+	"yunion.io/x/onecloud/pkg/monitor/datasource"
 	merrors "yunion.io/x/onecloud/pkg/monitor/errors"
 	"yunion.io/x/onecloud/pkg/monitor/options"
 	"yunion.io/x/onecloud/pkg/monitor/validators"
@@ -218,14 +219,8 @@ func (man *SCommonAlertManager) ValidateCreateData(
 		return data, merrors.NewArgIsEmptyErr("metric_query")
 	} else {
 		for _, query := range data.CommonMetricInputQuery.MetricQuery {
-			if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
-				query.Comparator = "=="
-			}
-			if !utils.IsInStringArray(getQueryEvalType(query.Comparator), validators.EvaluatorDefaultTypes) {
-				return data, httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
-			}
-			if _, ok := monitor.AlertReduceFunc[query.Reduce]; !ok {
-				return data, httperrors.NewInputParameterError("the reduce is illegal: %s", query.Reduce)
+			if err := validateCommonAlertQuery(query); err != nil {
+				return data, err
 			}
 			/*if query.Threshold == 0 {
 				return data, httperrors.NewInputParameterError("threshold is meaningless")
@@ -293,6 +288,36 @@ func (man *SCommonAlertManager) genName(ctx context.Context, ownerId mcclient.II
 	return name, nil
 }
 
+func validateCommonAlertQuery(query *monitor.CommonAlertQuery) error {
+	if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
+		query.Comparator = "=="
+	}
+	if query.ConditionType == monitor.METRIC_QUERY_TYPE_PROMQL {
+		if len(query.Reduce) == 0 {
+			query.Reduce = "last"
+		}
+	}
+	if len(query.EvaluatorType) != 0 {
+		if !utils.IsInStringArray(query.EvaluatorType, validators.EvaluatorAdvancedTypes) {
+			return httperrors.NewInputParameterError("the evaluator_type is illegal: %s", query.EvaluatorType)
+		}
+		if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
+			return httperrors.NewInputParameterError("evaluator_type %s is not supported by %s", query.EvaluatorType, query.ConditionType)
+		}
+	}
+	// PromQL 表达式自带比较运算时可以不设置 comparator, 表达式返回的序列都视为触发
+	// 异常检测不设置 comparator 时双向判断, 趋势预测和变化率默认判断是否高于阈值
+	if len(query.Comparator) != 0 || (query.ConditionType != monitor.METRIC_QUERY_TYPE_PROMQL && len(query.EvaluatorType) == 0) {
+		if !utils.IsInStringArray(getQueryEvalType(query.Comparator), validators.EvaluatorDefaultTypes) {
+			return httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
+		}
+	}
+	if _, ok := monitor.AlertReduceFunc[query.Reduce]; !ok {
+		return httperrors.NewInputParameterError("the reduce is illegal: %s", query.Reduce)
+	}
+	return nil
+}
+
 func (man *SCommonAlertManager) ValidateMetricQuery(metricRequest *monitor.CommonMetricInputQuery, scope string, ownerId mcclient.IIdentityProvider) error {
 	for _, q := range metricRequest.MetricQuery {
 		metriInputQuery := monitor.MetricQueryInput{
@@ -300,6 +325,15 @@ func (man *SCommonAlertManager) ValidateMetricQuery(metricRequest *monitor.Commo
 			To:       metricRequest.To,
 			Interval: metricRequest.Interval,
 		}
+		if q.ConditionType == monitor.METRIC_QUERY_TYPE_PROMQL {
+			if err := validatePromQLScope(scope); err != nil {
+				return err
+			}
+			if err := man.validatePromQLQuery(q.AlertQuery, &metriInputQuery); err != nil {
+				return err
+			}
+			continue
+		}
 		setDefaultValue(q.AlertQuery, &metriInputQuery, scope, ownerId)
 		err := UnifiedMonitorManager.ValidateInputQuery(q.AlertQuery, &metriInputQuery)
 		if err != nil {
@@ -309,6 +343,60 @@ func (man *SCommonAlertManager) ValidateMetricQuery(metricRequest *monitor.Commo
 	return nil
 }
 
+// validatePromQLScope PromQL 表达式不会像其他条件一样加上 tenant_id/domain_id 的过滤,
+// 项目和域范围的报警使用时可以查询到其他项目的数据, 因此只允许系统范围的报警使用
+func validatePromQLScope(scope string) error {
+	if rbacscope.TRbacScope(scope) != rbacscope.ScopeSystem {
+		return httperrors.NewForbiddenError("PromQL condition is only allowed in alerts of system scope, not %q", scope)
+	}
+	return nil
+}
+
+func (man *SCommonAlertManager) validatePromQLQuery(query *monitor.AlertQuery, input *monitor.MetricQueryInput) error {
+	if query == nil {
+		return merrors.NewArgIsEmptyErr("expr")
+	}
+	if query.From == "" {
+		query.From = input.From
+	}
+	if query.From == "" {
+		query.From = "1h"
+	}
+	if query.To == "" {
+		query.To = input.To
+	}
+	if query.To == "" {
+		query.To = "now"
+	}
+	if query.Model.Interval == "" {
+		query.Model.Interval = input.Interval
+	}
+	if query.Model.Interval != "" {
+		if _, err := time.ParseDuration(query.Model.Interval); err != nil {
+			return httperrors.NewInputParameterError("Invalid interval format: %s", query.Model.Interval)
+		}
+	}
+	if query.Model.Database == "" {
+		query.Model.Database = TELEGRAF_DATABASE
+	}
+	if err := validators.ValidatePromQLConditionQuery(*query); err != nil {
+		return err
+	}
+	if len(query.ResType) != 0 {
+		if _, ok := monitor.MEASUREMENT_TAG_ID[query.ResType]; !ok {
+			return httperrors.NewInputParameterError("unsupported res_type %s", query.ResType)
+		}
+	}
+	ds, err := datasource.GetDefaultSource(query.Model.Database)
+	if err != nil {
+		return errors.Wrap(err, "GetDefaultSource")
+	}
+	if ds.Type != monitor.DataSourceTypeVictoriaMetrics {
+		return httperrors.NewNotSupportedError("PromQL condition is not supported by data source %s", ds.Type)
+	}
+	return nil
+}
+
 func (alert *SCommonAlert) setAlertType(ctx context.Context, userCred mcclient.TokenCredential, alertType string) error {
 	return alert.SetMetadata(ctx, CommonAlertMetadataAlertType, alertType, userCred)
 }
@@ -808,6 +896,22 @@ func getCommonAlertMetricDetailsFromCondition(cond *monitor.AlertCondition,
 	if len(cond.Evaluator.Params) != 0 {
 		metricDetails.Threshold = cond.Evaluator.Params[0]
 	}
+	if utils.IsInStringArray(cond.Evaluator.Type, validators.EvaluatorAdvancedTypes) {
+		metricDetails.EvaluatorType = cond.Evaluator.Type
+		if len(cond.Evaluator.Params) > 1 {
+			metricDetails.EvaluatorParams = cond.Evaluator.Params[1:]
+		}
+		if len(cond.Evaluator.Operators) != 0 {
+			switch cond.Evaluator.Operators[0] {
+			case "gt":
+				metricDetails.Comparator = ">="
+			case "eq":
+				metricDetails.Comparator = "=="
+			case "lt":
+				metricDetails.Comparator = "<="
+			}
+		}
+	}
 	metricDetails.Reduce = cond.Reducer.Type
 
 	metricDetails.ConditionType = cond.Type
@@ -817,6 +921,13 @@ func getCommonAlertMetricDetailsFromCondition(cond *monitor.AlertCondition,
 	}
 
 	q := cond.Query
+	if metricDetails.ConditionType == monitor.METRIC_QUERY_TYPE_PROMQL {
+		metricDetails.Field = q.Expr
+		metricDetails.ResType = q.ResType
+		metricDetails.DB = q.Model.Database
+		metricDetails.Operator = cond.Operator
+		return
+	}
 	measurement := q.Model.Measurement
 	field := ""
 	for i, sel := range q.Model.Selects {
@@ -950,6 +1061,11 @@ func (man *SCommonAlertManager) toAlertCreatInput(input monitor.CommonAlertCreat
 				Params: []float64{fieldOperatorThreshold(metricquery.FieldOpt, metricquery.Threshold)}},
 			Operator: "and",
 		}
+		if len(metricquery.EvaluatorType) != 0 {
+			condition.Evaluator = getAdvancedEvaluator(metricquery)
+		} else if conditionType == monitor.METRIC_QUERY_TYPE_PROMQL && len(metricquery.Comparator) == 0 {
+			condition.Evaluator = monitor.Condition{Type: "has_value"}
+		}
 		if metricquery.Operator != "" {
 			if !sets.NewString("and", "or").Has(metricquery.Operator) {
 				return *ret, httperrors.NewInputParameterError("invalid operator %s", metricquery.Operator)
@@ -964,6 +1080,21 @@ func (man *SCommonAlertManager) toAlertCreatInput(input monitor.CommonAlertCreat
 	return *ret, nil
 }
 
+func getAdvancedEvaluator(metricquery *monitor.CommonAlertQuery) monitor.Condition {
+	threshold := metricquery.Threshold
+	if metricquery.EvaluatorType != monitor.EVALUATOR_SEASONAL_DEVIATION {
+		threshold = fieldOperatorThreshold(metricquery.FieldOpt, threshold)
+	}
+	evaluator := monitor.Condition{
+		Type:   metricquery.EvaluatorType,
+		Params: append([]float64{threshold}, metricquery.EvaluatorParams...),
+	}
+	if typ := getQueryEvalType(metricquery.Comparator); len(typ) != 0 {
+		evaluator.Operators = []string{typ}
+	}
+	return evaluator
+}
+
 func fieldOperatorThreshold(opt string, threshold float64) float64 {
 	if opt == monitor.CommonAlertFieldOpt_Division && threshold > 1 {
 		return threshold / float64(100)
@@ -1021,14 +1152,8 @@ func (alert *SCommonAlert) ValidateUpdateData(
 			if err != nil {
 				return data, errors.Wrap(err, "metric_query Unmarshal error")
 			}
-			if query.ConditionType == monitor.METRIC_QUERY_TYPE_NO_DATA {
-				query.Comparator = "=="
-			}
-			if !utils.IsInStringArray(getQueryEvalType(query.Comparator), validators.EvaluatorDefaultTypes) {
-				return data, httperrors.NewInputParameterError("the Comparator is illegal: %s", query.Comparator)
-			}
-			if _, ok := monitor.AlertReduceFunc[query.Reduce]; !ok {
-				return data, httperrors.NewInputParameterError("the reduce is illegal: %s", query.Reduce)
+			if err := validateCommonAlertQuery(query); err != nil {
+				return data, err
 			}
 			/*if query.Threshold == 0 {
 				return data, httperrors.NewInputParameterError("threshold is meaningless")
@@ -1046,6 +1171,9 @@ func (alert *SCommonAlert) ValidateUpdateData(
 			return data, errors.Wrap(err, "metric_query Unmarshal error")
 		}
 		scope, _ := data.GetString("scope")
+		if len(scope) == 0 {
+			scope = string(alert.GetResourceScope())
+		}
 		ownerId := CommonAlertManager.GetOwnerId(ctx, userCred, data)
 		err = CommonAlertManager.ValidateMetricQuery(metricQuery, scope, ownerId)
 		if err != nil {
@@ -1245,9 +1373,28 @@ func (alert *SCommonAlert) PerformSetScope(ctx context.Context, userCred mcclien
 			}
 		}
 	}
+	if len(domainId) > 0 || len(projectId) > 0 {
+		if err := alert.validatePromQLConditionsScope(); err != nil {
+			return nil, err
+		}
+	}
 	return db.PerformSetScope(ctx, alert, userCred, data)
 }
 
+// validatePromQLConditionsScope 含有 PromQL 条件的报警不能设置为项目或域范围
+func (alert *SCommonAlert) validatePromQLConditionsScope() error {
+	details, err := alert.GetCommonAlertMetricDetails()
+	if err != nil {
+		return errors.Wrap(err, "GetCommonAlertMetricDetails")
+	}
+	for _, detail := range details {
+		if detail.ConditionType == monitor.METRIC_QUERY_TYPE_PROMQL {
+			return httperrors.NewForbiddenError("alert with PromQL condition can only be of system scope")
+		}
+	}
+	return nil
+}
+
 func (manager *SCommonAlertManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
 	var err error
 	q, err = manager.SStatusStandaloneResourceBaseManager.QueryDistinctExtraField(q, field)
@@ -1296,14 +1443,23 @@ func (alert *SCommonAlert) PerformConfig(ctx context.Context, userCred mcclient.
 			alert.Frequency = int64(freq / time.Second)
 		}
 		setting, _ := alert.GetSettings()
+		evaluator := &setting.Conditions[0].Evaluator
+		isAdvanced := utils.IsInStringArray(evaluator.Type, validators.EvaluatorAdvancedTypes)
 		if len(comparator) != 0 {
-			setting.Conditions[0].Evaluator.Type = getQueryEvalType(comparator)
-
+			if isAdvanced {
+				evaluator.Operators = []string{getQueryEvalType(comparator)}
+			} else {
+				evaluator.Type = getQueryEvalType(comparator)
+			}
 		}
 		if len(threshold) != 0 {
 			val, _ := strconv.ParseFloat(threshold, 64)
 			fmt.Println(threshold)
-			setting.Conditions[0].Evaluator.Params = []float64{fieldOperatorThreshold("", val)}
+			if isAdvanced && len(evaluator.Params) != 0 {
+				evaluator.Params[0] = val
+			} else {
+				evaluator.Params = []float64{fieldOperatorThreshold("", val)}
+			}
 		}
 		alert.Settings = jsonutils.Marshal(setting)
 		return nil
@@ -1395,6 +1551,10 @@ func (alert *SCommonAlert) UpdateMonitorResourceJoint(ctx context.Context, userC
 	var resType string
 	setting, _ := alert.GetSettings()
 	for _, con := range setting.Conditions {
+		if con.Type == monitor.METRIC_QUERY_TYPE_PROMQL && len(con.Query.ResType) != 0 {
+			resType = con.Query.ResType
+			continue
+		}
 		measurement, _ := MetricMeasurementManager.GetCache().Get(con.Query.Model.Measurement)
 		if measurement == nil {
 			resType = monitor.METRIC_RES_TYPE_HOST
@@ -1491,12 +1651,20 @@ func (alert *SCommonAlert) UpdateResType() error {
 	if len(setting.Conditions) == 0 {
 		return nil
 	}
-	measurement, _ := MetricMeasurementManager.GetCache().Get(setting.Conditions[0].Query.Model.Measurement)
-	if measurement == nil {
+	cond := setting.Conditions[0]
+	resType := cond.Query.ResType
+	if cond.Type != monitor.METRIC_QUERY_TYPE_PROMQL {
+		measurement, _ := MetricMeasurementManager.GetCache().Get(cond.Query.Model.Measurement)
+		if measurement == nil {
+			return nil
+		}
+		resType = measurement.ResType
+	}
+	if len(resType) == 0 {
 		return nil
 	}
 	_, err := db.Update(alert, func() error {
-		alert.ResType = measurement.ResType
+		alert.ResType = resType
 		return nil
 	})
 	if err != nil {
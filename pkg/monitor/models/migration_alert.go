@@ -21,12 +21,15 @@ import (
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/sets"
 	"yunion.io/x/sqlchemy"
 
 	"yunion.io/x/onecloud/pkg/apis/monitor"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/monitor/options"
 )
 
 var (
@@ -48,8 +51,17 @@ func GetMigrationAlertManager() *SMigrationAlertManager {
 	return migrationAlertMan
 }
 
+// MigrationPlanExecutor 执行已确认的迁移计划
+type MigrationPlanExecutor func(ctx context.Context, s *mcclient.ClientSession, alert *SMigrationAlert, plan *monitor.MigrationPlan) error
+
 type SMigrationAlertManager struct {
 	SAlertManager
+
+	planExecutor MigrationPlanExecutor
+}
+
+func (m *SMigrationAlertManager) SetPlanExecutor(e MigrationPlanExecutor) {
+	m.planExecutor = e
 }
 
 type SMigrationAlert struct {
@@ -57,6 +69,8 @@ type SMigrationAlert struct {
 
 	MetricType   string               `create:"admin_required" list:"admin" get:"admin"`
 	MigrateNotes jsonutils.JSONObject `nullable:"true" list:"admin" get:"admin" update:"admin" create:"admin_optional"`
+	// MigratePlan 是 dry_run 模式下生成的待确认迁移计划
+	MigratePlan jsonutils.JSONObject `nullable:"true" list:"admin" get:"admin"`
 }
 
 type MigrateNoteGuest struct {
@@ -136,6 +150,19 @@ func (m *SMigrationAlertManager) ValidateMigrationSettings(s *monitor.MigrationA
 			return errors.Wrap(err, "validate target")
 		}
 	}
+	limitTypes := sets.NewString()
+	for _, limit := range s.MetricLimits {
+		if err := monitor.IsValidMigrationAlertMetricType(limit.MetricType); err != nil {
+			return httperrors.NewInputParameterError("Invalid metric_limits metric_type %v", err)
+		}
+		if limitTypes.Has(string(limit.MetricType)) {
+			return httperrors.NewDuplicateIdError("metric_limits metric_type", string(limit.MetricType))
+		}
+		if limit.Threshold <= 0 {
+			return httperrors.NewInputParameterError("metric_limits %s threshold must be greater than 0", limit.MetricType)
+		}
+		limitTypes.Insert(string(limit.MetricType))
+	}
 	return nil
 }
 
@@ -290,6 +317,64 @@ func (alert *SMigrationAlert) IsInMigrationProcess() (bool, map[string]MigrateNo
 	return true, nil, nil
 }
 
+func (alert *SMigrationAlert) GetMigratePlan() (*monitor.MigrationPlan, error) {
+	if alert.MigratePlan == nil {
+		return nil, nil
+	}
+	plan := new(monitor.MigrationPlan)
+	if err := alert.MigratePlan.Unmarshal(plan); err != nil {
+		return nil, errors.Wrap(err, "Unmarshal")
+	}
+	return plan, nil
+}
+
+func (alert *SMigrationAlert) SetMigratePlan(plan *monitor.MigrationPlan) error {
+	_, err := db.Update(alert, func() error {
+		if plan == nil {
+			alert.MigratePlan = nil
+		} else {
+			alert.MigratePlan = jsonutils.Marshal(plan)
+		}
+		return nil
+	})
+	return err
+}
+
+func (alert *SMigrationAlert) PerformApplyPlan(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	plan, err := alert.GetMigratePlan()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetMigratePlan")
+	}
+	if plan == nil || len(plan.Items) == 0 {
+		return nil, httperrors.NewNotFoundError("No migration plan to apply")
+	}
+	// 负载已经变化的旧计划不再执行, 等待重新生成
+	maxAge := time.Duration(options.Options.MigrationPlanMaxAgeMinutes) * time.Minute
+	if maxAge > 0 && time.Since(plan.CreatedAt) > maxAge {
+		return nil, httperrors.NewInvalidStatusError("Migration plan created at %s is older than %s", plan.CreatedAt.Format(time.RFC3339), maxAge)
+	}
+	executor := GetMigrationAlertManager().planExecutor
+	if executor == nil {
+		return nil, httperrors.NewNotImplementedError("migration plan executor not registered")
+	}
+	alerts, err := GetMigrationAlertManager().GetInMigrationAlerts()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetInMigrationAlerts")
+	}
+	if len(alerts) != 0 {
+		return nil, httperrors.NewConflictError("Others migration alerts in process")
+	}
+	// 计划只能执行一次，执行前先清除
+	if err := alert.SetMigratePlan(nil); err != nil {
+		return nil, errors.Wrap(err, "clean migrate plan")
+	}
+	s := auth.GetSession(ctx, userCred, options.Options.Region)
+	if err := executor(ctx, s, alert, plan); err != nil {
+		return nil, errors.Wrap(err, "execute migration plan")
+	}
+	return nil, nil
+}
+
 func (alert *SMigrationAlert) GetMigrationSettings() (*monitor.MigrationAlertSettings, error) {
 	if alert.CustomizeConfig == nil {
 		return nil, errors.Errorf("CustomizeConfig is nil")
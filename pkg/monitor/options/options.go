@@ -38,6 +38,7 @@ type AlerterOptions struct {
 	WorkerCheckInterval int `default:"180"`
 
 	AutoMigrationMustPair      bool `default:"false" help:"result of auto migration source guests and target hosts must be paired"`
+	MigrationPlanMaxAgeMinutes int  `default:"30" help:"migration plans of dry run older than this can not be applied"`
 	DisableQuerySignatureCheck bool `default:"true" help:"disable query signature check"`
 }
 
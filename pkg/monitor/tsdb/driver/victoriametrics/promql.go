@@ -0,0 +1,88 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package victoriametrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/influxdata/promql/v2/pkg/labels"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/apis/monitor"
+	"yunion.io/x/onecloud/pkg/monitor/tsdb"
+)
+
+const (
+	defaultPromQLStep = time.Minute
+)
+
+// QueryPromQL implements tsdb.TsdbPromQLQueryEndpoint.
+func (vm *vmAdapter) QueryPromQL(ctx context.Context, ds *tsdb.DataSource, expr string, timeRange *tsdb.TimeRange, step time.Duration) (*tsdb.QueryResult, error) {
+	cli, err := NewClient(ds.Url)
+	if err != nil {
+		return nil, errors.Wrap(err, "New VM client")
+	}
+	httpCli, err := ds.GetHttpClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "GetHttpClient of data source")
+	}
+	var vmTr *TimeRange
+	if timeRange != nil {
+		vmTr = NewTimeRange(timeRange.GetFromAsSecondsEpoch(), timeRange.GetToAsSecondsEpoch())
+	}
+	if step <= 0 {
+		step = defaultPromQLStep
+	}
+
+	start := time.Now()
+	defer func() {
+		log.Debugf("promQL: %s, elapsed: %s", expr, time.Now().Sub(start))
+	}()
+
+	resp, err := cli.QueryRange(ctx, httpCli, expr, step, vmTr, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "query VM range by: %s", expr)
+	}
+	return translatePromQLResponse(expr, resp), nil
+}
+
+// translatePromQLResponse converts each result of a raw PromQL expression to a time series,
+// the labels of result except __name__ are kept as tags.
+func translatePromQLResponse(expr string, resp *Response) *tsdb.QueryResult {
+	queryRes := tsdb.NewQueryResult()
+	queryRes.Meta = monitor.QueryResultMeta{
+		RawQuery: expr,
+	}
+	for _, result := range resp.Data.Result {
+		metricName := result.Metric[labels.MetricName]
+		if metricName == "" {
+			metricName = "value"
+		}
+		tags := make(map[string]string)
+		for key, val := range reviseTags(result.Metric) {
+			if key == labels.MetricName {
+				continue
+			}
+			tags[key] = val
+		}
+		points := transValuesToTSDBPoints(result.Values)
+		ts := tsdb.NewTimeSeries(metricName, tsdb.FormatRawName(0, metricName, nil, tags), []string{metricName, "time"}, points, tags)
+		queryRes.Series = append(queryRes.Series, ts)
+	}
+	return queryRes
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package victoriametrics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func Test_translatePromQLResponse(t *testing.T) {
+	resp := &Response{
+		Status: "success",
+		Data: ResponseData{
+			ResultType: "matrix",
+			Result: []ResponseDataResult{
+				{
+					Metric: map[string]string{
+						"__name__": "cpu_usage_active",
+						"host_id":  "7f3c1d0e",
+						"host":     "node+01",
+					},
+					Values: []ResponseDataResultValue{
+						{json.Number("1699414400"), "12.5"},
+						{json.Number("1699414460"), "95"},
+					},
+				},
+				{
+					Metric: map[string]string{
+						"host_id": "8a2b5f11",
+					},
+					Values: []ResponseDataResultValue{
+						{json.Number("1699414460"), "1"},
+					},
+				},
+			},
+		},
+	}
+	ret := translatePromQLResponse("cpu_usage_active > 90", resp)
+	if ret.Meta.RawQuery != "cpu_usage_active > 90" {
+		t.Errorf("raw query = %q", ret.Meta.RawQuery)
+	}
+	if len(ret.Series) != 2 {
+		t.Fatalf("series count = %d, want 2", len(ret.Series))
+	}
+
+	s0 := ret.Series[0]
+	if s0.Name != "cpu_usage_active" {
+		t.Errorf("series[0] name = %q", s0.Name)
+	}
+	wantTags := map[string]string{
+		"host_id": "7f3c1d0e",
+		"host":    "node 01",
+	}
+	if !reflect.DeepEqual(s0.Tags, wantTags) {
+		t.Errorf("series[0] tags = %v, want %v", s0.Tags, wantTags)
+	}
+	if len(s0.Points) != 2 {
+		t.Fatalf("series[0] points count = %d, want 2", len(s0.Points))
+	}
+	if v := s0.Points[1].Value(); v != 95 {
+		t.Errorf("series[0] last value = %v, want 95", v)
+	}
+	if ts := s0.Points[1].Timestamp(); ts != 1699414460000 {
+		t.Errorf("series[0] last timestamp = %v", ts)
+	}
+
+	s1 := ret.Series[1]
+	if s1.Name != "value" {
+		t.Errorf("series[1] name = %q, want value", s1.Name)
+	}
+	if !reflect.DeepEqual(s1.Columns, []string{"value", "time"}) {
+		t.Errorf("series[1] columns = %v", s1.Columns)
+	}
+}
@@ -16,6 +16,7 @@ package tsdb
 
 import (
 	"context"
+	"time"
 
 	"yunion.io/x/pkg/errors"
 
@@ -27,6 +28,12 @@ type TsdbQueryEndpoint interface {
 	FilterMeasurement(ctx context.Context, ds *DataSource, from, to string, ms *monitor.InfluxMeasurement, tagFilter *monitor.MetricQueryTag) (*monitor.InfluxMeasurement, error)
 }
 
+// TsdbPromQLQueryEndpoint is implemented by data sources which can evaluate
+// PromQL/MetricsQL expressions directly, e.g. VictoriaMetrics.
+type TsdbPromQLQueryEndpoint interface {
+	QueryPromQL(ctx context.Context, ds *DataSource, expr string, timeRange *TimeRange, step time.Duration) (*QueryResult, error)
+}
+
 var registry map[string]GetTsdbQueryEndpointFn
 
 type GetTsdbQueryEndpointFn func(dsInfo *DataSource) (TsdbQueryEndpoint, error)
@@ -37,6 +44,7 @@ func init() {
 
 var (
 	ErrorNotFoundExecutorDataSource error = errors.Error("Not find executor for data source")
+	ErrorPromQLNotSupported         error = errors.Error("Data source not support PromQL")
 )
 
 func getDataSourceFunc(dsType string) (GetTsdbQueryEndpointFn, error) {
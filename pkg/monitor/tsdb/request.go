@@ -16,6 +16,9 @@ package tsdb
 
 import (
 	"context"
+	"time"
+
+	"yunion.io/x/pkg/errors"
 )
 
 type HandleRequestFunc func(ctx context.Context, dsInfo *DataSource, req *TsdbQuery) (*Response, error)
@@ -28,3 +31,15 @@ func HandleRequest(ctx context.Context, dsInfo *DataSource, req *TsdbQuery) (*Re
 
 	return endpoint.Query(ctx, dsInfo, req)
 }
+
+func HandlePromQLRequest(ctx context.Context, dsInfo *DataSource, expr string, timeRange *TimeRange, step time.Duration) (*QueryResult, error) {
+	endpoint, err := GetTsdbQueryEndpointFor(dsInfo)
+	if err != nil {
+		return nil, err
+	}
+	promEndpoint, ok := endpoint.(TsdbPromQLQueryEndpoint)
+	if !ok {
+		return nil, errors.Wrapf(ErrorPromQLNotSupported, "type: %s", dsInfo.Type)
+	}
+	return promEndpoint.QueryPromQL(ctx, dsInfo, expr, timeRange, step)
+}
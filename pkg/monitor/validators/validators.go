@@ -36,8 +36,14 @@ const (
 )
 
 var (
-	EvaluatorDefaultTypes = []string{"gt", "lt", "eq"}
-	EvaluatorRangedTypes  = []string{"within_range", "outside_range"}
+	EvaluatorDefaultTypes  = []string{"gt", "lt", "eq"}
+	EvaluatorRangedTypes   = []string{"within_range", "outside_range"}
+	EvaluatorAdvancedTypes = []string{
+		monitor.EVALUATOR_SEASONAL_DEVIATION,
+		monitor.EVALUATOR_LINEAR_FORECAST,
+		monitor.EVALUATOR_HOLT_WINTERS_FORECAST,
+		monitor.EVALUATOR_RATE_OF_CHANGE,
+	}
 
 	CommonAlertType = []string{
 		monitor.CommonAlertNomalAlertType,
@@ -47,7 +53,7 @@ var (
 	CommonAlertReducerFieldOpts = []string{"/"}
 	CommonAlertNotifyTypes      = []string{"email", "mobile", "dingtalk", "webconsole", "feishu"}
 
-	ConditionTypes = []string{"query", "nodata_query"}
+	ConditionTypes = []string{"query", "nodata_query", monitor.METRIC_QUERY_TYPE_PROMQL}
 )
 
 func ValidateAlertCreateInput(input monitor.AlertCreateInput) error {
@@ -67,7 +73,11 @@ func ValidateAlertCondition(input monitor.AlertCondition) error {
 	if err := ValidateAlertConditionType(condType); err != nil {
 		return err
 	}
-	if err := ValidateAlertConditionQuery(input.Query); err != nil {
+	if condType == monitor.METRIC_QUERY_TYPE_PROMQL {
+		if err := ValidatePromQLConditionQuery(input.Query); err != nil {
+			return err
+		}
+	} else if err := ValidateAlertConditionQuery(input.Query); err != nil {
 		return err
 	}
 	if err := ValidateAlertConditionReducer(input.Reducer); err != nil {
@@ -98,6 +108,16 @@ func ValidateAlertConditionQuery(input monitor.AlertQuery) error {
 	return nil
 }
 
+func ValidatePromQLConditionQuery(input monitor.AlertQuery) error {
+	if err := ValidateFromAndToValue(input); err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(input.Expr)) == 0 {
+		return merrors.NewArgIsEmptyErr("expr")
+	}
+	return nil
+}
+
 func ValidateAlertQueryModel(input monitor.MetricQuery) error {
 	if len(input.Selects) == 0 {
 		return merrors.NewArgIsEmptyErr("select")
@@ -153,7 +173,10 @@ func ValidateAlertConditionEvaluator(input monitor.Condition) error {
 	if utils.IsInStringArray(typ, EvaluatorRangedTypes) {
 		return ValidateAlertConditionRangedEvaluator(input)
 	}
-	if typ != "no_value" {
+	if utils.IsInStringArray(typ, EvaluatorAdvancedTypes) {
+		return ValidateAlertConditionAdvancedEvaluator(input)
+	}
+	if typ != "no_value" && typ != "has_value" {
 		return errors.Wrapf(ErrInvalidEvaluatorType, "type: %s", typ)
 	}
 	return nil
@@ -186,6 +209,28 @@ func ValidateAlertConditionRangedEvaluator(input monitor.Condition) error {
 	return nil
 }
 
+func ValidateAlertConditionAdvancedEvaluator(input monitor.Condition) error {
+	if len(input.Params) == 0 {
+		return errors.Wrapf(ErrMissingParameterThreshold, "Evaluator %s", input.Type)
+	}
+	switch input.Type {
+	case monitor.EVALUATOR_LINEAR_FORECAST, monitor.EVALUATOR_HOLT_WINTERS_FORECAST:
+		if len(input.Params) < 2 || input.Params[1] <= 0 {
+			return httperrors.NewInputParameterError("evaluator %s requires positive forecast horizon", input.Type)
+		}
+	case monitor.EVALUATOR_SEASONAL_DEVIATION:
+		if input.Params[0] <= 0 {
+			return httperrors.NewInputParameterError("evaluator %s requires positive z-score threshold", input.Type)
+		}
+	}
+	for _, op := range input.Operators {
+		if !utils.IsInStringArray(op, EvaluatorDefaultTypes) {
+			return httperrors.NewInputParameterError("invalid evaluator %s operator %s", input.Type, op)
+		}
+	}
+	return nil
+}
+
 // HumanThresholdType converts a threshold "type" string to a string that matches the UI
 // so errors are less confusing.
 func HumanThresholdType(typ string) string {
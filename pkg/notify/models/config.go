@@ -246,7 +246,7 @@ func (c *SConfig) StartRepullSubcontactTask(ctx context.Context, userCred mcclie
 }
 
 var sortedCTypes = []string{
-	api.WEBCONSOLE, api.EMAIL, api.MOBILE, api.DINGTALK, api.FEISHU, api.WORKWX,
+	api.WEBCONSOLE, api.EMAIL, api.MOBILE, api.DINGTALK, api.FEISHU, api.WORKWX, api.SLACK, api.TEAMS, api.TELEGRAM,
 }
 
 func sortContactType(ctypes []string) []string {
@@ -81,6 +81,11 @@ type SNotification struct {
 	EventId    string    `width:"128" nullable:"true"`
 
 	SendTimes int
+
+	// 确认时间
+	AckedAt time.Time `nullable:"true" list:"user" get:"user"`
+	// 确认人
+	AckedBy string `width:"128" charset:"utf8" nullable:"true" list:"user" get:"user"`
 }
 
 const (
@@ -218,7 +223,7 @@ func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred
 		return output, nil
 	}
 	receiverIds := make(map[string]uint32)
-	receiverIds1, err := SubscriberManager.getReceiversSent(ctx, topic.Id, input.ProjectDomainId, input.ProjectId)
+	receiverIds1, err := SubscriberManager.getReceiversSent(ctx, topic.Id, input.ProjectDomainId, input.ProjectId, 0)
 	if err != nil {
 		return output, errors.Wrap(err, "unable to get receive")
 	}
@@ -227,7 +232,7 @@ func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred
 	}
 	// robot
 	robots := make(map[string]uint32)
-	_robots, err := SubscriberManager.robot(topic.Id, input.ProjectDomainId, input.ProjectId)
+	_robots, err := SubscriberManager.robot(topic.Id, input.ProjectDomainId, input.ProjectId, 0)
 	if err != nil {
 		if errors.Cause(err) != errors.ErrNotFound {
 			return output, errors.Wrapf(err, "unable fetch robot of subscription %q", topic.Id)
@@ -238,28 +243,6 @@ func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred
 		}
 	}
 
-	var webhookRobots []string
-	realRobot := make(map[string]uint32)
-	if len(robots) > 0 {
-		robotList := []string{}
-		for robot := range robots {
-			robotList = append(robotList, robot)
-		}
-		rs, err := RobotManager.FetchByIdOrNames(ctx, robotList...)
-		if err != nil {
-			return output, errors.Wrap(err, "unable to get robots")
-		}
-
-		webhookRobots = make([]string, 0, 1)
-		for i := range rs {
-			if rs[i].Type == api.ROBOT_TYPE_WEBHOOK {
-				webhookRobots = append(webhookRobots, rs[i].Id)
-			} else {
-				realRobot[rs[i].Id] = robots[rs[i].Id]
-			}
-		}
-	}
-
 	message := jsonutils.Marshal(input.ResourceDetails).String()
 
 	// append default receiver
@@ -299,11 +282,49 @@ func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred
 		return output, errors.Wrap(err, "unable to create Event")
 	}
 
+	output.FailedList, err = nm.dispatch(ctx, userCred, contactTypes, topic, realReceiverIds, webconsoleContacts.UnsortedList(), robots, input.Priority, event.GetId())
+	if err != nil {
+		return output, err
+	}
+	if input.Priority == api.NOTIFICATION_PRIORITY_CRITICAL {
+		err = NotificationEscalationManager.Start(ctx, event.GetId(), topic.Id, input.ProjectDomainId, input.ProjectId, userCred.GetProjectDomainId())
+		if err != nil {
+			log.Errorf("unable to start escalation of event %s: %v", event.GetId(), err)
+		}
+	}
+	return output, nil
+}
+
+// dispatch 按联系方式创建通知并发送给接收人和机器人
+func (nm *SNotificationManager) dispatch(ctx context.Context, userCred mcclient.TokenCredential, contactTypes []string, topic *STopic, realReceiverIds map[string]uint32, webconsoleContacts []string, robots map[string]uint32, priority, eventId string) ([]api.FailedElem, error) {
+	var webhookRobots []string
+	realRobot := make(map[string]uint32)
+	if len(robots) > 0 {
+		robotList := []string{}
+		for robot := range robots {
+			robotList = append(robotList, robot)
+		}
+		rs, err := RobotManager.FetchByIdOrNames(ctx, robotList...)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get robots")
+		}
+
+		webhookRobots = make([]string, 0, 1)
+		for i := range rs {
+			if rs[i].Type == api.ROBOT_TYPE_WEBHOOK {
+				webhookRobots = append(webhookRobots, rs[i].Id)
+			} else {
+				realRobot[rs[i].Id] = robots[rs[i].Id]
+			}
+		}
+	}
+
+	failedList := []api.FailedElem{}
 	if nm.needWebconsole([]STopic{*topic}) {
 		// webconsole
-		err = nm.create(ctx, userCred, api.WEBCONSOLE, realReceiverIds, webconsoleContacts.UnsortedList(), input.Priority, event.GetId(), topic.GetId(), topic.Type)
+		err := nm.create(ctx, userCred, api.WEBCONSOLE, realReceiverIds, webconsoleContacts, priority, eventId, topic.GetId(), topic.Type)
 		if err != nil {
-			output.FailedList = append(output.FailedList, api.FailedElem{
+			failedList = append(failedList, api.FailedElem{
 				ContactType: api.WEBCONSOLE,
 				Reason:      err.Error(),
 			})
@@ -314,30 +335,30 @@ func (nm *SNotificationManager) PerformEventNotify(ctx context.Context, userCred
 		if ct == api.MOBILE {
 			continue
 		}
-		err := nm.create(ctx, userCred, ct, realReceiverIds, nil, input.Priority, event.GetId(), topic.GetId(), topic.Type)
+		err := nm.create(ctx, userCred, ct, realReceiverIds, nil, priority, eventId, topic.GetId(), topic.Type)
 		if err != nil {
-			output.FailedList = append(output.FailedList, api.FailedElem{
+			failedList = append(failedList, api.FailedElem{
 				ContactType: ct,
 				Reason:      err.Error(),
 			})
 		}
 	}
-	err = nm.createWithWebhookRobots(ctx, userCred, webhookRobots, input.Priority, event.GetId(), topic.Type)
+	err := nm.createWithWebhookRobots(ctx, userCred, webhookRobots, priority, eventId, topic.Type)
 	if err != nil {
-		output.FailedList = append(output.FailedList, api.FailedElem{
+		failedList = append(failedList, api.FailedElem{
 			ContactType: api.WEBHOOK,
 			Reason:      err.Error(),
 		})
 	}
 	// robot
-	err = nm.createWithRobots(ctx, userCred, realRobot, input.Priority, event.GetId(), topic.Type)
+	err = nm.createWithRobots(ctx, userCred, realRobot, priority, eventId, topic.Type)
 	if err != nil {
-		output.FailedList = append(output.FailedList, api.FailedElem{
+		failedList = append(failedList, api.FailedElem{
 			ContactType: api.ROBOT,
 			Reason:      err.Error(),
 		})
 	}
-	return output, nil
+	return failedList, nil
 }
 
 func (nm *SNotificationManager) PerformContactNotify(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.NotificationManagerContactNotifyInput) (api.NotificationManagerEventNotifyOutput, error) {
@@ -615,7 +636,7 @@ func (nm *SNotificationManager) FetchCustomizeColumns(
 }
 
 func (n *SNotification) ReceiverNotificationsNotOK() ([]SReceiverNotification, error) {
-	rnq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotEquals("status", api.RECEIVER_NOTIFICATION_OK)
+	rnq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotIn("status", []string{api.RECEIVER_NOTIFICATION_OK, api.RECEIVER_NOTIFICATION_DEFERRED})
 	rns := make([]SReceiverNotification, 0, 1)
 	err := db.FetchModelObjects(ReceiverNotificationManager, rnq, &rns)
 	if err == sql.ErrNoRows {
@@ -786,9 +807,52 @@ func (nm *SNotificationManager) ListItemFilter(ctx context.Context, q *sqlchemy.
 	if len(input.TopicType) > 0 {
 		q = q.Equals("topic_type", input.TopicType)
 	}
+	if input.Acknowledged != nil {
+		if *input.Acknowledged {
+			q = q.IsNotNull("acked_at")
+		} else {
+			q = q.IsNull("acked_at")
+		}
+	}
 	return q, nil
 }
 
+// 确认通知, 同一事件的通知会被一起确认并停止升级
+func (n *SNotification) PerformAcknowledge(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input api.NotificationAcknowledgeInput) (jsonutils.JSONObject, error) {
+	if !n.AckedAt.IsZero() {
+		return nil, nil
+	}
+	ns := []SNotification{}
+	if len(n.EventId) > 0 {
+		q := NotificationManager.Query().Equals("event_id", n.EventId).IsNull("acked_at")
+		err := db.FetchModelObjects(NotificationManager, q, &ns)
+		if err != nil {
+			return nil, errors.Wrap(err, "fetch notifications of event")
+		}
+	} else {
+		ns = append(ns, *n)
+	}
+	now := time.Now()
+	for i := range ns {
+		_, err := db.Update(&ns[i], func() error {
+			ns[i].AckedAt = now
+			ns[i].AckedBy = userCred.GetUserName()
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "update notification %s", ns[i].Id)
+		}
+	}
+	if len(n.EventId) > 0 {
+		err := NotificationEscalationManager.Acknowledge(ctx, n.EventId, userCred.GetUserName())
+		if err != nil {
+			return nil, errors.Wrap(err, "acknowledge escalation")
+		}
+	}
+	logclient.AddActionLogWithContext(ctx, n, logclient.ACT_ACKNOWLEDGE, "", userCred, true)
+	return nil, nil
+}
+
 func (nm *SNotificationManager) ReSend(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
 	timeLimit := time.Now().Add(-time.Duration(options.Options.ReSendScope) * time.Second * 2).Format("2006-01-02 15:04:05")
 	q := nm.Query().GT("created_at", timeLimit).In("status", []string{api.NOTIFICATION_STATUS_FAILED, api.NOTIFICATION_STATUS_PART_OK}).LT("send_times", options.Options.MaxSendTimes)
@@ -812,7 +876,7 @@ func (nm *SNotificationManager) ReSend(ctx context.Context, userCred mcclient.To
 func (n *SNotification) GetNotOKReceivers() ([]SReceiver, error) {
 	ret := []SReceiver{}
 	q := ReceiverManager.Query().IsTrue("enabled")
-	sq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotEquals("status", api.RECEIVER_NOTIFICATION_OK).Equals("receiver_type", api.RECEIVER_TYPE_USER).SubQuery()
+	sq := ReceiverNotificationManager.Query().Equals("notification_id", n.Id).NotIn("status", []string{api.RECEIVER_NOTIFICATION_OK, api.RECEIVER_NOTIFICATION_DEFERRED}).Equals("receiver_type", api.RECEIVER_TYPE_USER).SubQuery()
 	q = q.Join(sq, sqlchemy.Equals(q.Field("id"), sq.Field("receiver_id")))
 	err := db.FetchModelObjects(ReceiverManager, q, &ret)
 	return ret, err
@@ -868,6 +932,12 @@ func (n *SNotification) GetTemplate(ctx context.Context, topicId, lang string, n
 			out.GroupKey += keyValue
 		}
 	}
+	// 未配置聚合字段时, 同一资源的相同事件视为重复事件
+	if len(groupKeys) == 0 {
+		if id, _ := msg.GetString("id"); len(id) > 0 {
+			out.GroupKey = event.String() + id
+		}
+	}
 	if lang == "" {
 		lang = getLangSuffix(ctx)
 	}
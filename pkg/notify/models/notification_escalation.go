@@ -0,0 +1,179 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
+)
+
+type SNotificationEscalationManager struct {
+	db.SModelBaseManager
+}
+
+var NotificationEscalationManager *SNotificationEscalationManager
+
+func init() {
+	NotificationEscalationManager = &SNotificationEscalationManager{
+		SModelBaseManager: db.NewModelBaseManager(
+			SNotificationEscalation{},
+			"notification_escalation_tbl",
+			"notification_escalation",
+			"notification_escalations",
+		),
+	}
+	NotificationEscalationManager.SetVirtualObject(NotificationEscalationManager)
+}
+
+// fatal 级别事件的升级状态, 事件在超时前未被确认时通知下一级别的订阅者
+type SNotificationEscalation struct {
+	db.SModelBase
+
+	EventId         string `width:"128" charset:"ascii" nullable:"false" primary:"true"`
+	TopicId         string `width:"128" charset:"ascii" nullable:"false"`
+	ProjectDomainId string `width:"128" charset:"ascii" nullable:"true"`
+	ProjectId       string `width:"128" charset:"ascii" nullable:"true"`
+	// 发送通知时使用的联系方式所在的域
+	DomainId string `width:"128" charset:"ascii" nullable:"true"`
+	// 已经通知到的级别
+	Level uint32 `nullable:"false" default:"0"`
+	// 下一次升级的时间
+	EscalateAt time.Time `nullable:"true" index:"true"`
+	Status     string    `width:"16" charset:"ascii" nullable:"false" index:"true"`
+	AckedAt    time.Time `nullable:"true"`
+	AckedBy    string    `width:"128" charset:"utf8" nullable:"true"`
+	CreatedAt  time.Time `nullable:"false" created_at:"true"`
+}
+
+// Start 在存在第一级升级订阅时记录事件的升级状态
+func (nem *SNotificationEscalationManager) Start(ctx context.Context, eventId, topicId, projectDomainId, projectId, domainId string) error {
+	minutes, ok, err := SubscriberManager.escalationMinutes(topicId, projectDomainId, projectId, 1)
+	if err != nil {
+		return errors.Wrap(err, "escalationMinutes")
+	}
+	if !ok {
+		return nil
+	}
+	escalation := &SNotificationEscalation{
+		EventId:         eventId,
+		TopicId:         topicId,
+		ProjectDomainId: projectDomainId,
+		ProjectId:       projectId,
+		DomainId:        domainId,
+		EscalateAt:      time.Now().Add(time.Duration(minutes) * time.Minute),
+		Status:          api.NOTIFICATION_ESCALATION_PENDING,
+	}
+	return nem.TableSpec().Insert(ctx, escalation)
+}
+
+func (nem *SNotificationEscalationManager) Acknowledge(ctx context.Context, eventId, user string) error {
+	q := nem.Query().Equals("event_id", eventId).Equals("status", api.NOTIFICATION_ESCALATION_PENDING)
+	escalations := []SNotificationEscalation{}
+	err := db.FetchModelObjects(nem, q, &escalations)
+	if err != nil {
+		return errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range escalations {
+		_, err := db.Update(&escalations[i], func() error {
+			escalations[i].Status = api.NOTIFICATION_ESCALATION_ACKED
+			escalations[i].AckedAt = time.Now()
+			escalations[i].AckedBy = user
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, "update escalation")
+		}
+	}
+	return nil
+}
+
+// Escalate 将超时未确认的 fatal 级别事件通知给下一级别的订阅者
+func (nem *SNotificationEscalationManager) Escalate(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := nem.Query().Equals("status", api.NOTIFICATION_ESCALATION_PENDING).LE("escalate_at", time.Now())
+	escalations := []SNotificationEscalation{}
+	err := db.FetchModelObjects(nem, q, &escalations)
+	if err != nil {
+		log.Errorf("fetch pending escalations: %v", err)
+		return
+	}
+	for i := range escalations {
+		err := escalations[i].escalate(ctx, userCred)
+		if err != nil {
+			log.Errorf("escalate event %s: %v", escalations[i].EventId, err)
+		}
+	}
+}
+
+func (ne *SNotificationEscalation) escalate(ctx context.Context, userCred mcclient.TokenCredential) error {
+	level := ne.Level + 1
+	err := ne.notify(ctx, userCred, level)
+	if err != nil {
+		// 通知失败时同样推进到下一级别, 避免反复通知同一级别
+		log.Errorf("notify escalation level %d of event %s: %v", level, ne.EventId, err)
+	}
+	minutes, ok, err := SubscriberManager.escalationMinutes(ne.TopicId, ne.ProjectDomainId, ne.ProjectId, level+1)
+	if err != nil {
+		return errors.Wrap(err, "escalationMinutes")
+	}
+	_, err = db.Update(ne, func() error {
+		ne.Level = level
+		if ok {
+			ne.EscalateAt = time.Now().Add(time.Duration(minutes) * time.Minute)
+		} else {
+			ne.Status = api.NOTIFICATION_ESCALATION_DONE
+		}
+		return nil
+	})
+	return err
+}
+
+func (ne *SNotificationEscalation) notify(ctx context.Context, userCred mcclient.TokenCredential, level uint32) error {
+	topicObj, err := TopicManager.FetchById(ne.TopicId)
+	if err != nil {
+		return errors.Wrapf(err, "fetch topic %s", ne.TopicId)
+	}
+	topic := topicObj.(*STopic)
+	receivers, err := SubscriberManager.getReceiversSent(ctx, ne.TopicId, ne.ProjectDomainId, ne.ProjectId, level)
+	if err != nil {
+		return errors.Wrap(err, "getReceiversSent")
+	}
+	robots, err := SubscriberManager.robot(ne.TopicId, ne.ProjectDomainId, ne.ProjectId, level)
+	if err != nil {
+		return errors.Wrap(err, "robot")
+	}
+	contactTypes, err := ConfigManager.allContactType(ne.DomainId)
+	if err != nil {
+		return errors.Wrap(err, "allContactType")
+	}
+	webconsoleContacts := make([]string, 0, len(receivers))
+	for id := range receivers {
+		webconsoleContacts = append(webconsoleContacts, id)
+	}
+	failedList, err := NotificationManager.dispatch(ctx, userCred, contactTypes, topic, receivers, webconsoleContacts, robots, api.NOTIFICATION_PRIORITY_CRITICAL, ne.EventId)
+	if err != nil {
+		return err
+	}
+	for _, failed := range failedList {
+		log.Errorf("escalate event %s by %s: %s", ne.EventId, failed.ContactType, failed.Reason)
+	}
+	return nil
+}
@@ -18,15 +18,18 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/tristate"
 	"yunion.io/x/sqlchemy"
 
 	apis "yunion.io/x/onecloud/pkg/apis/notify"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/mcclient"
 )
 
 type SNotificationGroupManager struct {
@@ -47,7 +50,7 @@ func init() {
 	NotificationGroupManager.SetVirtualObject(NotificationGroupManager)
 }
 
-// 站内信
+// 消息汇总队列, 用于重复消息去重和免打扰时段内的消息延迟发送
 type SNotificationGroup struct {
 	db.SModelBase
 
@@ -64,25 +67,36 @@ type SNotificationGroup struct {
 	Contact     string `width:"128" nullable:"false" create:"required" list:"user" get:"user"`
 	CreatedAt   time.Time
 	DomainId    string `width:"128" nullable:"false" create:"required" list:"user" get:"user"`
+
+	NotificationId string `width:"128" charset:"ascii" nullable:"true" list:"user" get:"user"`
+	// 汇总消息的发送时间
+	SendAfter time.Time `nullable:"true" index:"true" list:"user" get:"user"`
+	// 是否已经发送, 已发送的记录仅用于标记聚合窗口
+	Sent tristate.TriState `default:"false" list:"user" get:"user"`
 }
 
-func (ng *SNotificationGroupManager) TaskCreate(ctx context.Context, contactType string, args apis.SendParams) error {
+var notificationGroupLock sync.Mutex
+
+func (ng *SNotificationGroupManager) TaskCreate(ctx context.Context, contactType, notificationId string, args apis.SendParams, sendAfter time.Time, sent bool) error {
 	if contactType == apis.WEBCONSOLE {
 		return nil
 	}
 	insertNotificationGroup := SNotificationGroup{
-		Id:          db.DefaultUUIDGenerator(),
-		ContactType: contactType,
-		Body:        args.Body,
-		Header:      args.Header,
-		MsgKey:      args.MsgKey,
-		ReceiverId:  args.ReceiverId,
-		Title:       args.Title,
-		Message:     args.Message,
-		GroupKey:    args.GroupKey,
-		Contact:     args.Receivers.Contact,
-		CreatedAt:   time.Now(),
-		DomainId:    args.DomainId,
+		Id:             db.DefaultUUIDGenerator(),
+		ContactType:    contactType,
+		Body:           args.Body,
+		Header:         args.Header,
+		MsgKey:         args.MsgKey,
+		ReceiverId:     args.ReceiverId,
+		Title:          args.Title,
+		Message:        args.Message,
+		GroupKey:       args.GroupKey,
+		Contact:        args.Receivers.Contact,
+		CreatedAt:      time.Now(),
+		DomainId:       args.DomainId,
+		NotificationId: notificationId,
+		SendAfter:      sendAfter,
+		Sent:           tristate.NewFromBool(sent),
 	}
 	if contactType == apis.EMAIL {
 		insertNotificationGroup.Title = args.EmailMsg.Subject
@@ -92,57 +106,161 @@ func (ng *SNotificationGroupManager) TaskCreate(ctx context.Context, contactType
 	return NotificationGroupManager.TableSpec().Insert(ctx, &insertNotificationGroup)
 }
 
-func (ng *SNotificationGroupManager) TaskSend(ctx context.Context, input apis.SNotificationGroupSearchInput) (*apis.SendParams, error) {
-	q := ng.Query()
-	q = q.Between("created_at", input.StartTime, input.EndTime)
-	q = q.Equals("group_key", input.GroupKey)
-	q = q.Equals("receiver_id", input.ReceiverId)
-	q = q.Equals("contact_type", input.ContactType)
+// windowEnd 返回仍未结束的聚合窗口的结束时间
+func (ng *SNotificationGroupManager) windowEnd(groupKey, receiverId, contactType string, now time.Time) (time.Time, error) {
+	q := ng.Query().Equals("group_key", groupKey).Equals("receiver_id", receiverId).Equals("contact_type", contactType).GT("send_after", now)
+	q = q.Desc("send_after").Limit(1)
+	ngs := []SNotificationGroup{}
+	err := db.FetchModelObjects(ng, q, &ngs)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "fetch notification groups")
+	}
+	if len(ngs) == 0 {
+		return time.Time{}, nil
+	}
+	return ngs[0].SendAfter, nil
+}
+
+// Dispatch 发送消息, 处于免打扰时段或者聚合窗口内的消息会加入汇总队列稍后发送, 此时返回 true
+func (ng *SNotificationGroupManager) Dispatch(ctx context.Context, driver ISenderDriver, contactType, notificationId string, args apis.SendParams, quietUntil time.Time) (bool, error) {
+	if contactType == apis.WEBCONSOLE {
+		return false, driver.Send(ctx, args)
+	}
+	if !quietUntil.IsZero() {
+		args.GroupKey = apis.NOTIFICATION_GROUP_KEY_QUIET_HOURS
+		err := ng.TaskCreate(ctx, contactType, notificationId, args, quietUntil, false)
+		if err != nil {
+			return false, errors.Wrap(err, "defer notification to the end of quiet hours")
+		}
+		return true, nil
+	}
+	if len(args.GroupKey) == 0 || args.GroupTimes == 0 {
+		return false, driver.Send(ctx, args)
+	}
+
+	notificationGroupLock.Lock()
+	defer notificationGroupLock.Unlock()
+
+	now := time.Now()
+	end, err := ng.windowEnd(args.GroupKey, args.ReceiverId, contactType, now)
+	if err != nil {
+		return false, err
+	}
+	if !end.IsZero() {
+		err = ng.TaskCreate(ctx, contactType, notificationId, args, end, false)
+		if err != nil {
+			return false, errors.Wrap(err, "add notification to digest")
+		}
+		return true, nil
+	}
+	err = driver.Send(ctx, args)
+	if err != nil {
+		return false, err
+	}
+	// 发送成功后开启聚合窗口, 窗口内的重复消息在窗口结束时汇总发送
+	err = ng.TaskCreate(ctx, contactType, notificationId, args, now.Add(time.Duration(args.GroupTimes)*time.Minute), true)
+	if err != nil {
+		log.Errorf("unable to open digest window for %s: %v", args.GroupKey, err)
+	}
+	return false, nil
+}
+
+// FlushDigests 发送到期的汇总消息
+func (ng *SNotificationGroupManager) FlushDigests(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	q := ng.Query().LE("send_after", time.Now()).Asc("created_at")
 	ngs := []SNotificationGroup{}
 	err := db.FetchModelObjects(ng, q, &ngs)
 	if err != nil {
-		return nil, errors.Wrap(err, "fetch notification groups")
+		log.Errorf("fetch due notification groups: %v", err)
+		return
 	}
-	if len(ngs) <= 1 {
-		return nil, errors.Wrapf(errors.ErrNotFound, "notification groups just found :%d", len(ngs))
+	groups := map[string][]SNotificationGroup{}
+	keys := []string{}
+	for i := range ngs {
+		key := strings.Join([]string{ngs[i].GroupKey, ngs[i].ReceiverId, ngs[i].ContactType}, "/")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], ngs[i])
+	}
+	for _, key := range keys {
+		err := ng.flush(ctx, groups[key])
+		if err != nil {
+			log.Errorf("flush notification group %s: %v", key, err)
+		}
 	}
-	sendParams := &apis.SendParams{
+}
+
+func (ng *SNotificationGroupManager) flush(ctx context.Context, ngs []SNotificationGroup) error {
+	deleteIds := []string{}
+	pending := []SNotificationGroup{}
+	for i := range ngs {
+		deleteIds = append(deleteIds, fmt.Sprintf("'%s'", ngs[i].Id))
+		if !ngs[i].Sent.Bool() {
+			pending = append(pending, ngs[i])
+		}
+	}
+	defer func() {
+		_, err := sqlchemy.Exec(fmt.Sprintf("delete from %s where id in (%s) ", ng.TableSpec().Name(), strings.Join(deleteIds, ",")))
+		if err != nil {
+			log.Errorln("clean notification_groups err:", err)
+		}
+	}()
+	if len(pending) == 0 {
+		return nil
+	}
+	err := sendDigest(ctx, pending)
+	for i := range pending {
+		e := ReceiverNotificationManager.afterDigest(ctx, pending[i].NotificationId, pending[i].ReceiverId, err)
+		if e != nil {
+			log.Errorf("update receiver notification of %s: %v", pending[i].NotificationId, e)
+		}
+	}
+	return err
+}
+
+// sendDigest 通过对应渠道发送汇总消息, 机器人的渠道为 <type>-robot
+func sendDigest(ctx context.Context, ngs []SNotificationGroup) error {
+	contactType := ngs[0].ContactType
+	driver := GetDriver(contactType)
+	if driver == nil {
+		return errors.Wrapf(errors.ErrNotSupported, "contact type %s", contactType)
+	}
+	return driver.Send(ctx, digestSendParams(ngs))
+}
+
+// digestSendParams 将多条消息合并为一条汇总消息
+func digestSendParams(ngs []SNotificationGroup) apis.SendParams {
+	title := ngs[0].Title
+	if len(ngs) > 1 {
+		title = fmt.Sprintf("%s (+%d)", title, len(ngs)-1)
+	}
+	sendParams := apis.SendParams{
 		Body:       ngs[0].Body,
 		Header:     ngs[0].Header,
 		MsgKey:     ngs[0].MsgKey,
-		Title:      ngs[0].Title,
+		Title:      title,
 		ReceiverId: ngs[0].ReceiverId,
 		Receivers: apis.SNotifyReceiver{
 			Contact: ngs[0].Contact,
 		},
 		DomainId: ngs[0].DomainId,
 	}
-	msg := ""
 	joinStr := " \n"
-	sendParams.Message = msg
-	if input.ContactType == apis.EMAIL {
+	if ngs[0].ContactType == apis.EMAIL {
 		joinStr = " <br>"
 	}
-	deleteIds := []string{}
+	msg := ""
 	for _, ng := range ngs {
 		msg += fmt.Sprintf("%s %s", ng.Message, joinStr)
-		deleteIds = append(deleteIds, fmt.Sprintf("'%s'", ng.Id))
 	}
-
-	defer func() {
-		_, err = sqlchemy.Exec(fmt.Sprintf("delete from %s where id in (%s) ", ng.TableSpec().Name(), strings.Join(deleteIds, ",")))
-		if err != nil {
-			log.Errorln("clean notification_groups err:", err)
-		}
-	}()
-
 	sendParams.Message = msg
-	if input.ContactType == apis.EMAIL {
+	if ngs[0].ContactType == apis.EMAIL {
 		sendParams.EmailMsg = apis.SEmailMessage{
 			Subject: sendParams.Title,
 			Body:    msg,
 			To:      []string{ngs[0].Contact},
 		}
 	}
-	return sendParams, nil
+	return sendParams
 }
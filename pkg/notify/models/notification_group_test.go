@@ -0,0 +1,73 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+)
+
+type fakeSenderDriver struct {
+	ISenderDriver
+
+	senderType string
+	sent       []api.SendParams
+}
+
+func (d *fakeSenderDriver) GetSenderType() string {
+	return d.senderType
+}
+
+func (d *fakeSenderDriver) Send(ctx context.Context, args api.SendParams) error {
+	d.sent = append(d.sent, args)
+	return nil
+}
+
+func TestSendRobotDigest(t *testing.T) {
+	drv := &fakeSenderDriver{senderType: "slack-robot"}
+	Register(drv)
+	defer delete(driverTable, drv.senderType)
+
+	header := jsonutils.Marshal(map[string]string{"X-Token": "t"})
+	ngs := []SNotificationGroup{
+		{ContactType: "slack-robot", Contact: "https://hooks.slack.com/services/x", ReceiverId: "robot", Header: header, MsgKey: "text", Title: "disk full", Message: "vdb"},
+		{ContactType: "slack-robot", Contact: "https://hooks.slack.com/services/x", ReceiverId: "robot", Header: header, MsgKey: "text", Title: "disk full", Message: "vdc"},
+	}
+	err := sendDigest(context.Background(), ngs)
+	if err != nil {
+		t.Fatalf("send robot digest: %v", err)
+	}
+	if len(drv.sent) != 1 {
+		t.Fatalf("want 1 digest sent, got %d", len(drv.sent))
+	}
+	sent := drv.sent[0]
+	if sent.Receivers.Contact != ngs[0].Contact || sent.ReceiverId != "robot" || sent.MsgKey != "text" || sent.Header != header {
+		t.Errorf("robot settings not kept in digest: %#v", sent)
+	}
+	if sent.Title != "disk full (+1)" || sent.Message != "vdb  \nvdc  \n" {
+		t.Errorf("unexpected digest %q %q", sent.Title, sent.Message)
+	}
+
+	ngs[0].ContactType = "feishu-robot"
+	err = sendDigest(context.Background(), ngs)
+	if errors.Cause(err) != errors.ErrNotSupported {
+		t.Errorf("digest without driver should fail with not supported, got %v", err)
+	}
+}
@@ -34,6 +34,19 @@ type ISenderDriver interface {
 	RegisterConfig(config SConfig)
 }
 
+// IEmailContactDriver 通过邮箱查找联系人的渠道（如slack、teams），拉取子联系人时使用邮箱而不是手机号
+type IEmailContactDriver interface {
+	ContactByEmail(ctx context.Context, email, domainId string) (string, error)
+}
+
+// IBindContactDriver 无法通过手机号或邮箱查找联系人的渠道（如telegram），
+// 需要用户打开BindUrl返回的链接，向机器人发送验证码完成绑定
+type IBindContactDriver interface {
+	BindUrl(ctx context.Context, token, domainId string) (string, error)
+	// 根据用户发送给机器人的验证码查找联系人，未找到时返回errors.ErrNotFound
+	ContactByToken(ctx context.Context, token, domainId string) (string, error)
+}
+
 var (
 	driverTable = make(map[string]ISenderDriver)
 )
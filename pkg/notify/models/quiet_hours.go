@@ -0,0 +1,100 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/notify/options"
+)
+
+// parseClock 解析 HH:MM 格式的时间, 返回距离零点的分钟数
+func parseClock(clock string) (int, error) {
+	var hour, minute int
+	n, err := fmt.Sscanf(clock, "%d:%d", &hour, &minute)
+	if err != nil || n != 2 || len(clock) != 5 {
+		return 0, errors.Wrapf(errors.ErrInvalidFormat, "clock %q", clock)
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, errors.Wrapf(errors.ErrInvalidFormat, "clock %q", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+func loadTimeZone(tz string) *time.Location {
+	if len(tz) == 0 {
+		tz = options.Options.TimeZone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func validateQuietHours(start, end, tz string) error {
+	if len(start) == 0 && len(end) == 0 {
+		return nil
+	}
+	if len(start) == 0 || len(end) == 0 {
+		return httperrors.NewInputParameterError("quiet_hours_start and quiet_hours_end should be set together")
+	}
+	if _, err := parseClock(start); err != nil {
+		return httperrors.NewInputParameterError("invalid quiet_hours_start %q, should be HH:MM", start)
+	}
+	if _, err := parseClock(end); err != nil {
+		return httperrors.NewInputParameterError("invalid quiet_hours_end %q, should be HH:MM", end)
+	}
+	if len(tz) > 0 {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return httperrors.NewInputParameterError("invalid time_zone %q", tz)
+		}
+	}
+	return nil
+}
+
+// quietHoursEnd 判断 now 是否处于免打扰时段内, 是则返回免打扰时段的结束时间
+// 开始时间晚于结束时间表示跨天, 例如 22:00-08:00
+func quietHoursEnd(start, end, tz string, now time.Time) (time.Time, bool) {
+	s, err := parseClock(start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	e, err := parseClock(end)
+	if err != nil || s == e {
+		return time.Time{}, false
+	}
+	local := now.In(loadTimeZone(tz))
+	cur := local.Hour()*60 + local.Minute()
+	day := 0
+	if s < e {
+		if cur < s || cur >= e {
+			return time.Time{}, false
+		}
+	} else {
+		if cur < s && cur >= e {
+			return time.Time{}, false
+		}
+		if cur >= s {
+			day = 1
+		}
+	}
+	y, m, d := local.Date()
+	return time.Date(y, m, d+day, e/60, e%60, 0, 0, local.Location()), true
+}
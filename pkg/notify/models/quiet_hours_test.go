@@ -0,0 +1,112 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+	cases := []struct {
+		name       string
+		start, end string
+		tz         string
+		now        time.Time
+		want       time.Time
+		quiet      bool
+	}{
+		{
+			name:  "overnight before midnight",
+			start: "22:00", end: "08:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 23, 30, 0, 0, shanghai),
+			want:  time.Date(2023, 3, 2, 8, 0, 0, 0, shanghai),
+			quiet: true,
+		},
+		{
+			name:  "overnight after midnight",
+			start: "22:00", end: "08:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 3, 0, 0, 0, shanghai),
+			want:  time.Date(2023, 3, 1, 8, 0, 0, 0, shanghai),
+			quiet: true,
+		},
+		{
+			name:  "overnight daytime",
+			start: "22:00", end: "08:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 8, 0, 0, 0, shanghai),
+			quiet: false,
+		},
+		{
+			name:  "same day window",
+			start: "12:00", end: "14:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 13, 0, 0, 0, shanghai),
+			want:  time.Date(2023, 3, 1, 14, 0, 0, 0, shanghai),
+			quiet: true,
+		},
+		{
+			name:  "time zone of receiver",
+			start: "22:00", end: "08:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 15, 0, 0, 0, time.UTC),
+			want:  time.Date(2023, 3, 2, 8, 0, 0, 0, shanghai),
+			quiet: true,
+		},
+		{
+			name:  "disabled",
+			start: "00:00", end: "00:00", tz: "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 0, 0, 0, 0, shanghai),
+			quiet: false,
+		},
+		{
+			name:  "not configured",
+			tz:    "Asia/Shanghai",
+			now:   time.Date(2023, 3, 1, 0, 0, 0, 0, shanghai),
+			quiet: false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, quiet := quietHoursEnd(c.start, c.end, c.tz, c.now)
+			if quiet != c.quiet {
+				t.Fatalf("quiet = %v, want %v", quiet, c.quiet)
+			}
+			if quiet && !got.Equal(c.want) {
+				t.Fatalf("end = %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateQuietHours(t *testing.T) {
+	for _, c := range []struct {
+		start, end, tz string
+		ok             bool
+	}{
+		{"", "", "", true},
+		{"22:00", "08:00", "Asia/Shanghai", true},
+		{"22:00", "", "", false},
+		{"24:00", "08:00", "", false},
+		{"8:00", "09:00", "", false},
+		{"22:00", "08:00", "Nowhere/City", false},
+	} {
+		err := validateQuietHours(c.start, c.end, c.tz)
+		if (err == nil) != c.ok {
+			t.Errorf("validateQuietHours(%q, %q, %q) = %v", c.start, c.end, c.tz, err)
+		}
+	}
+}
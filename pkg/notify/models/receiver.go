@@ -55,11 +55,17 @@ var (
 		api.DINGTALK,
 		api.FEISHU,
 		api.WORKWX,
+		api.SLACK,
+		api.TEAMS,
+		api.TELEGRAM,
 	}
 	RobotContactTypes = []string{
 		api.FEISHU_ROBOT,
 		api.DINGTALK_ROBOT,
 		api.WORKWX_ROBOT,
+		api.SLACK_ROBOT,
+		api.TEAMS_ROBOT,
+		api.TELEGRAM_ROBOT,
 	}
 	SystemConfigContactTypes = append(
 		RobotContactTypes,
@@ -107,6 +113,13 @@ type SReceiver struct {
 	// swagger:ignore
 	VerifiedMobile tristate.TriState `default:"false" update:"user"`
 
+	// 免打扰开始时间, 格式 HH:MM, 免打扰时段内非 fatal 级别的消息会延迟到时段结束后汇总发送
+	QuietHoursStart string `width:"5" charset:"ascii" nullable:"true" create:"optional" update:"user" get:"user" list:"user"`
+	// 免打扰结束时间, 格式 HH:MM
+	QuietHoursEnd string `width:"5" charset:"ascii" nullable:"true" create:"optional" update:"user" get:"user" list:"user"`
+	// 免打扰时段所在时区, 为空时使用服务配置的时区
+	TimeZone string `width:"64" charset:"ascii" nullable:"true" create:"optional" update:"user" get:"user" list:"user"`
+
 	// swagger:ignore
 	// subContactCache map[string]*SSubContact `json:"-"`
 }
@@ -153,6 +166,10 @@ func (rm *SReceiverManager) ValidateCreateData(ctx context.Context, userCred mcc
 			return input, httperrors.NewInputParameterError("invalid enabled contact type %s", cType)
 		}
 	}
+	err = validateQuietHours(input.QuietHoursStart, input.QuietHoursEnd, input.TimeZone)
+	if err != nil {
+		return input, err
+	}
 	return input, nil
 }
 
@@ -630,9 +647,29 @@ func (r *SReceiver) ValidateUpdateData(ctx context.Context, userCred mcclient.To
 		}
 	}
 
+	quiet := input.SReceiverQuietHoursInput
+	if len(quiet.QuietHoursStart) == 0 {
+		quiet.QuietHoursStart = r.QuietHoursStart
+	}
+	if len(quiet.QuietHoursEnd) == 0 {
+		quiet.QuietHoursEnd = r.QuietHoursEnd
+	}
+	if len(quiet.TimeZone) == 0 {
+		quiet.TimeZone = r.TimeZone
+	}
+	err = validateQuietHours(quiet.QuietHoursStart, quiet.QuietHoursEnd, quiet.TimeZone)
+	if err != nil {
+		return input, err
+	}
+
 	return input, nil
 }
 
+// QuietUntil 返回联系人当前所处免打扰时段的结束时间
+func (r *SReceiver) QuietUntil(now time.Time) (time.Time, bool) {
+	return quietHoursEnd(r.QuietHoursStart, r.QuietHoursEnd, r.TimeZone, now)
+}
+
 func (r *SReceiver) PreUpdate(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) {
 	r.SVirtualResourceBase.PreUpdate(ctx, userCred, query, data)
 	originEmailEnable, originMobileEnable := r.EnabledEmail, r.EnabledMobile
@@ -715,7 +752,7 @@ func (r *SReceiver) PostUpdate(ctx context.Context, userCred mcclient.TokenCrede
 }
 
 func (r *SReceiver) StartSubcontactPullTask(ctx context.Context, userCred mcclient.TokenCredential, contactTypes []string, parentTaskId string) error {
-	if len(r.Mobile) == 0 {
+	if len(r.Mobile) == 0 && len(r.Email) == 0 {
 		return nil
 	}
 	r.SetStatus(ctx, userCred, api.RECEIVER_STATUS_PULLING, "")
@@ -835,20 +872,28 @@ func (r *SReceiver) PerformTriggerVerify(ctx context.Context, userCred mcclient.
 	if len(input.ContactType) == 0 {
 		return nil, httperrors.NewMissingParameterError("contact_type")
 	}
-	if !utils.IsInStringArray(input.ContactType, []string{api.EMAIL, api.MOBILE, api.DINGTALK, api.FEISHU, api.WORKWX}) {
+	if !utils.IsInStringArray(input.ContactType, []string{api.EMAIL, api.MOBILE, api.DINGTALK, api.FEISHU, api.WORKWX, api.SLACK, api.TEAMS, api.TELEGRAM}) {
 		return nil, httperrors.NewInputParameterError("not support such contact type %q", input.ContactType)
 	}
 	driver := GetDriver(input.ContactType)
 	if driver.IsPullType() {
 		return nil, r.StartSubcontactPullTask(ctx, userCred, []string{input.ContactType}, "")
 	}
-	_, err := VerificationManager.Create(ctx, r.Id, input.ContactType)
+	verification, err := VerificationManager.Create(ctx, r.Id, input.ContactType)
 	/*if err == ErrVerifyFrequently {
 		return nil, httperrors.NewForbiddenError("Send verify message too frequently, please try again later")
 	}*/
 	if err != nil {
 		return nil, errors.Wrap(err, "VerifyManager.Create")
 	}
+	// 由用户向机器人发送验证码完成绑定
+	if bindDriver, ok := driver.(IBindContactDriver); ok {
+		bindUrl, err := bindDriver.BindUrl(ctx, verification.Token, r.DomainId)
+		if err != nil {
+			return nil, errors.Wrap(err, "BindUrl")
+		}
+		return jsonutils.Marshal(api.ReceiverTriggerVerifyOutput{BindUrl: bindUrl}), nil
+	}
 
 	params := jsonutils.Marshal(input).(*jsonutils.JSONDict)
 	task, err := taskman.TaskManager.NewTask(ctx, "VerificationSendTask", r, userCred, params, "", "")
@@ -862,7 +907,7 @@ func (r *SReceiver) PerformVerify(ctx context.Context, userCred mcclient.TokenCr
 	if len(input.ContactType) == 0 {
 		return nil, httperrors.NewMissingParameterError("contact_type")
 	}
-	if !utils.IsInStringArray(input.ContactType, []string{api.EMAIL, api.MOBILE}) {
+	if !utils.IsInStringArray(input.ContactType, []string{api.EMAIL, api.MOBILE, api.TELEGRAM}) {
 		return nil, httperrors.NewInputParameterError("not support such contact type %q", input.ContactType)
 	}
 	verification, err := VerificationManager.Get(r.Id, input.ContactType)
@@ -872,6 +917,16 @@ func (r *SReceiver) PerformVerify(ctx context.Context, userCred mcclient.TokenCr
 	if verification.CreatedAt.Add(time.Duration(options.Options.VerifyValidInterval) * time.Minute).Before(time.Now()) {
 		return nil, httperrors.NewForbiddenError("The validation expires, please retrieve the verification code again")
 	}
+	if bindDriver, ok := GetDriver(input.ContactType).(IBindContactDriver); ok {
+		contact, err := bindDriver.ContactByToken(ctx, verification.Token, r.DomainId)
+		if err != nil {
+			if errors.Cause(err) == errors.ErrNotFound {
+				return nil, httperrors.NewInputParameterError("verification code has not been sent to the bot yet")
+			}
+			return nil, errors.Wrap(err, "ContactByToken")
+		}
+		return nil, r.bindSubContact(ctx, input.ContactType, contact)
+	}
 	if verification.Token != input.Token {
 		return nil, httperrors.NewInputParameterError("wrong token")
 	}
@@ -889,6 +944,37 @@ func (r *SReceiver) PerformVerify(ctx context.Context, userCred mcclient.TokenCr
 	return nil, err
 }
 
+// 保存用户绑定的联系人并标记为已验证
+func (r *SReceiver) bindSubContact(ctx context.Context, contactType, contact string) error {
+	subs, err := r.GetSubContacts()
+	if err != nil {
+		return errors.Wrap(err, "GetSubContacts")
+	}
+	for i := range subs {
+		if subs[i].Type == contactType {
+			_, err := db.Update(&subs[i], func() error {
+				subs[i].Contact = contact
+				subs[i].ParentContactType = contactType
+				subs[i].Enabled = tristate.True
+				subs[i].Verified = tristate.True
+				subs[i].VerifiedNote = ""
+				return nil
+			})
+			return err
+		}
+	}
+	sub := &SSubContact{
+		ReceiverID:        r.Id,
+		Type:              contactType,
+		Contact:           contact,
+		ParentContactType: contactType,
+		Enabled:           tristate.True,
+		Verified:          tristate.True,
+	}
+	sub.SetModelManager(SubContactManager, sub)
+	return SubContactManager.TableSpec().Insert(ctx, sub)
+}
+
 func (r *SReceiver) PerformEnable(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, input apis.PerformEnableInput) (jsonutils.JSONObject, error) {
 	err := db.EnabledPerformEnable(r, ctx, userCred, true)
 	if err != nil {
@@ -1103,7 +1189,7 @@ func (r *SReceiver) GetContact(cType string) (string, error) {
 		return r.Mobile, nil
 	case api.WEBCONSOLE:
 		return r.Id, nil
-	case api.FEISHU_ROBOT, api.DINGTALK_ROBOT, api.WORKWX_ROBOT:
+	case api.FEISHU_ROBOT, api.DINGTALK_ROBOT, api.WORKWX_ROBOT, api.SLACK_ROBOT, api.TEAMS_ROBOT, api.TELEGRAM_ROBOT:
 		return r.Mobile, nil
 	default:
 		subs, _ := r.GetSubContacts()
@@ -216,6 +216,35 @@ func (rn *SReceiverNotification) AfterSend(ctx context.Context, success bool, re
 	return err
 }
 
+// Defer 消息加入汇总队列, 稍后随汇总消息一起发送
+func (rn *SReceiverNotification) Defer(ctx context.Context) error {
+	_, err := db.Update(rn, func() error {
+		rn.Status = api.RECEIVER_NOTIFICATION_DEFERRED
+		return nil
+	})
+	return err
+}
+
+func (rnm *SReceiverNotificationManager) afterDigest(ctx context.Context, notificationId, receiverId string, sendErr error) error {
+	q := rnm.Query().Equals("notification_id", notificationId).Equals("receiver_id", receiverId).Equals("status", api.RECEIVER_NOTIFICATION_DEFERRED)
+	rns := []SReceiverNotification{}
+	err := db.FetchModelObjects(rnm, q, &rns)
+	if err != nil {
+		return errors.Wrap(err, "FetchModelObjects")
+	}
+	for i := range rns {
+		if sendErr != nil {
+			err = rns[i].AfterSend(ctx, false, sendErr.Error())
+		} else {
+			err = rns[i].AfterSend(ctx, true, "")
+		}
+		if err != nil {
+			return errors.Wrap(err, "AfterSend")
+		}
+	}
+	return nil
+}
+
 type IReceiver interface {
 	IsRobot() bool
 	IsReceiver() bool
@@ -68,7 +68,7 @@ type SRobot struct {
 	UseTemplate tristate.TriState    `default:"false" list:"domain" update:"user" create:"admin_optional"`
 }
 
-var RobotList = []string{api.FEISHU_ROBOT, api.DINGTALK_ROBOT, api.WORKWX_ROBOT, api.WEBHOOK, api.WEBHOOK_ROBOT}
+var RobotList = []string{api.FEISHU_ROBOT, api.DINGTALK_ROBOT, api.WORKWX_ROBOT, api.SLACK_ROBOT, api.TEAMS_ROBOT, api.TELEGRAM_ROBOT, api.WEBHOOK, api.WEBHOOK_ROBOT}
 
 func (rm *SRobotManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input api.RobotCreateInput) (api.RobotCreateInput, error) {
 	var err error
@@ -91,6 +91,10 @@ type SSubscriber struct {
 	DomainId                string `width:"128" charset:"ascii" nullable:"false" create:"optional"`
 	// minutes
 	GroupTimes uint32 `nullable:"true" list:"user"  update:"user"`
+	// 升级级别, 0 表示直接接收通知, 大于 0 时仅接收上一级别通知后未被确认的 fatal 级别通知
+	EscalationLevel uint32 `nullable:"false" default:"0" list:"user" get:"user" create:"optional"`
+	// 上一级别通知后超过多少分钟未确认则升级到本级别, 单位分钟
+	EscalationMinutes uint32 `nullable:"false" default:"0" list:"user" get:"user" create:"optional"`
 }
 
 func (sm *SSubscriberManager) validateReceivers(ctx context.Context, receivers []string) ([]string, error) {
@@ -234,6 +238,11 @@ func (sm *SSubscriberManager) ValidateCreateData(ctx context.Context, userCred m
 			return input, httperrors.NewInputParameterError("invalidate group_times %d", input.GroupTimes)
 		}
 	}
+	if input.EscalationLevel != nil && *input.EscalationLevel > 0 {
+		if input.EscalationMinutes == nil || *input.EscalationMinutes == 0 {
+			return input, httperrors.NewMissingParameterError("escalation_minutes")
+		}
+	}
 	return input, nil
 }
 
@@ -324,6 +333,26 @@ func (s *SSubscriber) PerformChange(ctx context.Context, userCred mcclient.Token
 			return nil, errors.Wrap(err, "unable to update subscriber group_times")
 		}
 	}
+	if input.EscalationLevel != nil || input.EscalationMinutes != nil {
+		level, minutes := s.EscalationLevel, s.EscalationMinutes
+		if input.EscalationLevel != nil {
+			level = *input.EscalationLevel
+		}
+		if input.EscalationMinutes != nil {
+			minutes = *input.EscalationMinutes
+		}
+		if level > 0 && minutes == 0 {
+			return nil, httperrors.NewMissingParameterError("escalation_minutes")
+		}
+		_, err := db.Update(s, func() error {
+			s.EscalationLevel = level
+			s.EscalationMinutes = minutes
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to update subscriber escalation")
+		}
+	}
 
 	return nil, nil
 }
@@ -450,8 +479,8 @@ func (s *SSubscriber) roleIdentification(ctx context.Context) (api.Identificatio
 	return ret, nil
 }
 
-func (srm *SSubscriberManager) robot(tid, projectDomainId, projectId string) (map[string]uint32, error) {
-	srs, err := srm.findSuitableOnes(tid, projectDomainId, projectId, api.SUBSCRIBER_TYPE_ROBOT)
+func (srm *SSubscriberManager) robot(tid, projectDomainId, projectId string, level uint32) (map[string]uint32, error) {
+	srs, err := srm.findSuitableOnes(tid, projectDomainId, projectId, level, api.SUBSCRIBER_TYPE_ROBOT)
 	if err != nil {
 		return nil, err
 	}
@@ -463,8 +492,8 @@ func (srm *SSubscriberManager) robot(tid, projectDomainId, projectId string) (ma
 	return robotIds, nil
 }
 
-func (srm *SSubscriberManager) findSuitableOnes(tid, projectDomainId, projectId string, types ...string) ([]SSubscriber, error) {
-	q := srm.Query().Equals("topic_id", tid).IsTrue("enabled")
+func (srm *SSubscriberManager) findSuitableOnes(tid, projectDomainId, projectId string, level uint32, types ...string) ([]SSubscriber, error) {
+	q := srm.Query().Equals("topic_id", tid).IsTrue("enabled").Equals("escalation_level", level)
 	q = q.Filter(sqlchemy.OR(
 		sqlchemy.AND(
 			sqlchemy.Equals(q.Field("resource_scope"), api.SUBSCRIBER_SCOPE_PROJECT),
@@ -491,9 +520,27 @@ func (srm *SSubscriberManager) findSuitableOnes(tid, projectDomainId, projectId
 	return srs, nil
 }
 
+// escalationMinutes 返回升级到 level 级别需要等待的分钟数, 没有该级别的订阅时返回 false
+func (srm *SSubscriberManager) escalationMinutes(tid, projectDomainId, projectId string, level uint32) (uint32, bool, error) {
+	srs, err := srm.findSuitableOnes(tid, projectDomainId, projectId, level)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(srs) == 0 {
+		return 0, false, nil
+	}
+	minutes := srs[0].EscalationMinutes
+	for i := range srs {
+		if srs[i].EscalationMinutes < minutes {
+			minutes = srs[i].EscalationMinutes
+		}
+	}
+	return minutes, true, nil
+}
+
 // TODO: Use cache to increase speed
-func (srm *SSubscriberManager) getReceiversSent(ctx context.Context, tid string, projectDomainId string, projectId string) (map[string]uint32, error) {
-	srs, err := srm.findSuitableOnes(tid, projectDomainId, projectId, api.SUBSCRIBER_TYPE_RECEIVER, api.SUBSCRIBER_TYPE_ROLE)
+func (srm *SSubscriberManager) getReceiversSent(ctx context.Context, tid string, projectDomainId string, projectId string, level uint32) (map[string]uint32, error) {
+	srs, err := srm.findSuitableOnes(tid, projectDomainId, projectId, level, api.SUBSCRIBER_TYPE_RECEIVER, api.SUBSCRIBER_TYPE_ROLE)
 	if err != nil {
 		return nil, err
 	}
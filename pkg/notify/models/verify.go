@@ -19,9 +19,11 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/stringutils"
 
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/httperrors"
@@ -57,7 +59,11 @@ type SVerification struct {
 
 var ErrVerifyFrequently = errors.Wrap(httperrors.ErrTooManyRequests, "Send validation messages too frequently")
 
-func (vm *SVerificationManager) generateVerifyToken() string {
+func (vm *SVerificationManager) generateVerifyToken(contactType string) string {
+	// 绑定链接中的token不需要用户输入，使用足够长的随机串避免被猜中
+	if _, ok := GetDriver(contactType).(IBindContactDriver); ok {
+		return strings.ReplaceAll(stringutils.UUID4(), "-", "")
+	}
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	token := fmt.Sprintf("%06v", rnd.Int31n(1000000))
 	return token
@@ -73,7 +79,7 @@ func (vm *SVerificationManager) Create(ctx context.Context, receiverId, contactT
 		ret = &SVerification{
 			ReceiverId:  receiverId,
 			ContactType: contactType,
-			Token:       vm.generateVerifyToken(),
+			Token:       vm.generateVerifyToken(contactType),
 		}
 		err := vm.TableSpec().Insert(ctx, ret)
 		if err != nil {
@@ -85,7 +91,7 @@ func (vm *SVerificationManager) Create(ctx context.Context, receiverId, contactT
 			return nil, ErrVerifyFrequently
 		}
 		_, err := db.Update(ret, func() error {
-			ret.Token = vm.generateVerifyToken()
+			ret.Token = vm.generateVerifyToken(contactType)
 			ret.CreatedAt = now
 			ret.UpdatedAt = now
 			return nil
@@ -37,6 +37,9 @@ type NotifyOption struct {
 
 	SyncReceiverIntervalMinutes int  `help:"interval to sync receivers from keystone, in minutes" default:"30"`
 	EnableWatchUser             bool `help:"use etcd to watch user" default:"false"`
+
+	SenderMaxRetries           int `help:"max retries of slack, teams and telegram requests when rate limited or server error" default:"3"`
+	SenderMaxRetryAfterSeconds int `help:"max seconds to wait before retrying a rate limited request" default:"60"`
 }
 
 var Options NotifyOption
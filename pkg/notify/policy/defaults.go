@@ -73,6 +73,13 @@ var (
 					Action:   PolicyActionPerform,
 					Result:   rbacutils.Allow,
 				},
+				{
+					Service:  api.SERVICE_TYPE,
+					Resource: "notifications",
+					Action:   PolicyActionPerform,
+					Extra:    []string{"acknowledge"},
+					Result:   rbacutils.Allow,
+				},
 			},
 		},
 		{
@@ -44,6 +44,20 @@ const (
 	ApiWorkwxSendMessage = "https://qyapi.weixin.qq.com/cgi-bin/message/send?"
 	// 飞书使用手机号或邮箱获取用户ID
 	ApiFetchUserID = "https://open.feishu.cn/open-apis/user/v1/batch_get_id?"
+	// slack发送消息
+	ApiSlackPostMessage = "https://slack.com/api/chat.postMessage"
+	// slack使用邮箱获取用户ID
+	ApiSlackLookupByEmail = "https://slack.com/api/users.lookupByEmail?"
+	// slack校验bot token
+	ApiSlackAuthTest = "https://slack.com/api/auth.test"
+	// teams获取token
+	ApiTeamsGetToken = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	// teams使用邮箱获取用户ID
+	ApiTeamsGetUser = "https://graph.microsoft.com/v1.0/users/%s?$select=id"
+	// teams默认的bot服务地址
+	ApiTeamsServiceUrl = "https://smba.trafficmanager.net/teams/"
+	// telegram bot api地址
+	ApiTelegram = "https://api.telegram.org"
 )
 
 var (
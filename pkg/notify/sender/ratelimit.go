@@ -0,0 +1,227 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/notify/options"
+)
+
+// slack、teams、telegram的接口都有按会话的频率限制，超限时返回429以及建议的重试时间，
+// 这里按目标做简单的限速，并在收到429或5xx时退避重试
+
+var (
+	ErrRateLimited = errors.Wrap(httperrors.ErrTooManyRequests, "rate limited by remote server")
+
+	// 首次退避时间，之后按指数增长
+	imBackoffBase = time.Second
+
+	imLimiterLock      sync.Mutex
+	imLimiters         = map[string]*sImLimiter{}
+	imLimiterLastSweep time.Time
+)
+
+const (
+	// 同一个会话每秒最多发送一条消息，允许少量突发
+	imSendRate  = rate.Limit(1)
+	imSendBurst = 3
+
+	// 超过该时间未使用的限速器会被清理，此时其令牌早已补满，重新创建不影响限速
+	imLimiterIdleTimeout = 10 * time.Minute
+)
+
+type sImLimiter struct {
+	*rate.Limiter
+	lastUsed time.Time
+}
+
+type sImResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (resp *sImResponse) JSON() (jsonutils.JSONObject, error) {
+	obj, err := jsonutils.Parse(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse response %q", string(resp.Body))
+	}
+	return obj, nil
+}
+
+func (resp *sImResponse) IsOK() bool {
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// 等待目标会话的发送配额
+func waitImQuota(ctx context.Context, key string) error {
+	imLimiterLock.Lock()
+	now := time.Now()
+	if now.Sub(imLimiterLastSweep) > imLimiterIdleTimeout {
+		sweepImLimiters(now)
+	}
+	limiter, ok := imLimiters[key]
+	if !ok {
+		limiter = &sImLimiter{Limiter: rate.NewLimiter(imSendRate, imSendBurst)}
+		imLimiters[key] = limiter
+	}
+	limiter.lastUsed = now
+	imLimiterLock.Unlock()
+	return limiter.Wait(ctx)
+}
+
+// 清理空闲的限速器，调用方需持有imLimiterLock
+func sweepImLimiters(now time.Time) {
+	for key, limiter := range imLimiters {
+		if now.Sub(limiter.lastUsed) > imLimiterIdleTimeout {
+			delete(imLimiters, key)
+		}
+	}
+	imLimiterLastSweep = now
+}
+
+// 请求地址中带有token(telegram)或本身就是密钥(slack、teams的webhook)，日志和错误中只保留协议和主机
+func redactImUri(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || len(u.Host) == 0 {
+		return "***"
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// url.Error中带有完整的请求地址，替换为脱敏后的地址
+func redactImError(err error, redacted string) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return &url.Error{Op: urlErr.Op, URL: redacted, Err: urlErr.Err}
+	}
+	return err
+}
+
+// 解析建议的重试时间，优先使用Retry-After头，其次是telegram响应体中的parameters.retry_after
+func (resp *sImResponse) retryAfter() time.Duration {
+	if v := resp.Header.Get("Retry-After"); len(v) > 0 {
+		if sec, err := strconv.Atoi(v); err == nil {
+			return time.Duration(sec) * time.Second
+		}
+		if at, err := http.ParseTime(v); err == nil {
+			return time.Until(at)
+		}
+	}
+	if obj, err := jsonutils.Parse(resp.Body); err == nil {
+		if sec, err := obj.Int("parameters", "retry_after"); err == nil {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	return 0
+}
+
+func imBackoff(attempt int) time.Duration {
+	d := imBackoffBase << uint(attempt)
+	return d + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// 发送slack、teams、telegram请求，遇到429或5xx时退避重试，其余状态码由调用方处理
+func sendImRequest(ctx context.Context, method httputils.THttpMethod, uri string, header http.Header, body jsonutils.JSONObject) (*sImResponse, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	var data []byte
+	if body != nil {
+		data = []byte(body.String())
+		if len(header.Get("Content-Type")) == 0 {
+			header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+	}
+	return sendImRawRequest(ctx, method, uri, header, data)
+}
+
+func sendImFormRequest(ctx context.Context, uri string, form url.Values) (*sImResponse, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return sendImRawRequest(ctx, httputils.POST, uri, header, []byte(form.Encode()))
+}
+
+func sendImRawRequest(ctx context.Context, method httputils.THttpMethod, uri string, header http.Header, data []byte) (*sImResponse, error) {
+	maxRetryAfter := time.Duration(options.Options.SenderMaxRetryAfterSeconds) * time.Second
+	redacted := redactImUri(uri)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, string(method), uri, bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(redactImError(err, redacted), "NewRequest")
+		}
+		req.Header = header.Clone()
+		if options.Options.DebugRequest {
+			log.Debugf("%s %s %s", method, redacted, string(data))
+		}
+		httpResp, err := cli.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(redactImError(err, redacted), "http request")
+		}
+		respBody, err := ioutil.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "read response")
+		}
+		resp := &sImResponse{
+			StatusCode: httpResp.StatusCode,
+			Header:     httpResp.Header,
+			Body:       respBody,
+		}
+		if options.Options.DebugRequest {
+			log.Debugf("response %d %s", resp.StatusCode, string(respBody))
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if attempt >= options.Options.SenderMaxRetries {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				return resp, errors.Wrapf(ErrRateLimited, "%s after %d retries", redacted, attempt)
+			}
+			return resp, nil
+		}
+		wait := imBackoff(attempt)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if after := resp.retryAfter(); after > 0 {
+				if after > maxRetryAfter {
+					return resp, errors.Wrapf(ErrRateLimited, "%s, retry after %s", redacted, after)
+				}
+				wait = after
+			}
+		}
+		log.Warningf("request %s got status %d, retry after %s", redacted, resp.StatusCode, wait)
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/notify/options"
+)
+
+func TestSendImRequestRetry(t *testing.T) {
+	options.Options.SenderMaxRetries = 2
+	options.Options.SenderMaxRetryAfterSeconds = 5
+	imBackoffBase = time.Millisecond
+
+	cases := []struct {
+		name     string
+		statuses []int
+		header   string
+		body     string
+		want     int
+		calls    int
+		limited  bool
+	}{
+		{
+			name:     "retry after header",
+			statuses: []int{429, 200},
+			header:   "0",
+			want:     200,
+			calls:    2,
+		},
+		{
+			name:     "telegram retry_after",
+			statuses: []int{429, 200},
+			body:     `{"ok":false,"error_code":429,"parameters":{"retry_after":0}}`,
+			want:     200,
+			calls:    2,
+		},
+		{
+			name:     "server error",
+			statuses: []int{502, 503, 200},
+			want:     200,
+			calls:    3,
+		},
+		{
+			name:     "retries exhausted",
+			statuses: []int{429, 429, 429, 200},
+			want:     429,
+			calls:    3,
+			limited:  true,
+		},
+		{
+			name:     "retry after too long",
+			statuses: []int{429, 200},
+			header:   "3600",
+			want:     429,
+			calls:    1,
+			limited:  true,
+		},
+		{
+			name:     "client error",
+			statuses: []int{404, 200},
+			want:     404,
+			calls:    1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := c.statuses[calls]
+				calls++
+				if status == http.StatusTooManyRequests && len(c.header) > 0 {
+					w.Header().Set("Retry-After", c.header)
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(c.body))
+			}))
+			defer srv.Close()
+
+			resp, err := sendImRequest(context.Background(), httputils.POST, srv.URL, nil, jsonutils.NewDict())
+			if c.limited {
+				if errors.Cause(err) != httperrors.ErrTooManyRequests {
+					t.Fatalf("want rate limited error, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("sendImRequest: %v", err)
+			}
+			if resp.StatusCode != c.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, c.want)
+			}
+			if calls != c.calls {
+				t.Errorf("calls = %d, want %d", calls, c.calls)
+			}
+		})
+	}
+}
+
+func TestSendImRequestRedact(t *testing.T) {
+	options.Options.SenderMaxRetries = 0
+	token := "123456:secret-token"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := sendImRequest(context.Background(), httputils.POST, srv.URL+"/bot"+token+"/sendMessage", nil, nil)
+	if err == nil || strings.Contains(err.Error(), token) {
+		t.Errorf("rate limited error should not contain token: %v", err)
+	}
+
+	// 连接失败时的url.Error
+	srv.Close()
+	_, err = sendImRequest(context.Background(), httputils.POST, srv.URL+"/bot"+token+"/sendMessage", nil, nil)
+	if err == nil || strings.Contains(err.Error(), token) {
+		t.Errorf("request error should not contain token: %v", err)
+	}
+}
+
+func TestSweepImLimiters(t *testing.T) {
+	now := time.Now()
+	imLimiterLock.Lock()
+	defer imLimiterLock.Unlock()
+	imLimiters = map[string]*sImLimiter{
+		"idle":   {lastUsed: now.Add(-imLimiterIdleTimeout - time.Second)},
+		"active": {lastUsed: now.Add(-time.Second)},
+	}
+	sweepImLimiters(now)
+	if _, ok := imLimiters["idle"]; ok {
+		t.Errorf("idle limiter should be evicted")
+	}
+	if _, ok := imLimiters["active"]; !ok {
+		t.Errorf("active limiter should be kept")
+	}
+}
+
+func TestFormatSlackMessage(t *testing.T) {
+	got := formatSlackMessage("Disk <vdb> full", "usage > 90% & rising")
+	want := "*Disk &lt;vdb&gt; full*\nusage &gt; 90% &amp; rising"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
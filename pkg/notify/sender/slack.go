@@ -0,0 +1,174 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+type SSlackSender struct {
+	config map[string]api.SNotifyConfigContent
+}
+
+func (slackSender *SSlackSender) GetSenderType() string {
+	return api.SLACK
+}
+
+// slack的mrkdwn格式只需要转义&、<、>
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func formatSlackMessage(title, message string) string {
+	if len(title) == 0 {
+		return slackEscaper.Replace(message)
+	}
+	return fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(title), slackEscaper.Replace(message))
+}
+
+func (slackSender *SSlackSender) Send(ctx context.Context, args api.SendParams) error {
+	token, err := slackSender.getBotToken(args.DomainId)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"channel": args.Receivers.Contact,
+		"text":    formatSlackMessage(args.Title, args.Message),
+	}
+	err = waitImQuota(ctx, fmt.Sprintf("%s-%s", api.SLACK, args.Receivers.Contact))
+	if err != nil {
+		return err
+	}
+	_, err = slackSender.request(ctx, token, httputils.POST, ApiSlackPostMessage, jsonutils.Marshal(body))
+	if err != nil {
+		return errors.Wrap(err, "slack chat.postMessage")
+	}
+	return nil
+}
+
+func (slackSender *SSlackSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	if len(config.BotToken) == 0 {
+		return "bot_token is required", api.ErrIncompleteConfig
+	}
+	_, err := slackSender.request(ctx, config.BotToken, httputils.POST, ApiSlackAuthTest, nil)
+	if err != nil {
+		return "invalid bot_token", err
+	}
+	return "", nil
+}
+
+func (slackSender *SSlackSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+// 根据用户邮箱获取slack用户ID，需要bot具有users:read.email权限
+func (slackSender *SSlackSender) ContactByEmail(ctx context.Context, email, domainId string) (string, error) {
+	token, err := slackSender.getBotToken(domainId)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("email", email)
+	resp, err := slackSender.request(ctx, token, httputils.GET, ApiSlackLookupByEmail+params.Encode(), nil)
+	if err != nil {
+		if strings.Contains(err.Error(), "users_not_found") {
+			return "", errors.Wrap(api.ErrNoSuchEmail, email)
+		}
+		return "", errors.Wrap(err, "slack users.lookupByEmail")
+	}
+	return resp.GetString("user", "id")
+}
+
+// slack web api总是返回200，通过ok字段判断是否成功
+func (slackSender *SSlackSender) request(ctx context.Context, token string, method httputils.THttpMethod, uri string, body jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	resp, err := sendImRequest(ctx, method, uri, header, body)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := resp.JSON()
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := obj.Bool("ok"); ok {
+		return obj, nil
+	}
+	msg, _ := obj.GetString("error")
+	if len(msg) == 0 {
+		msg = obj.String()
+	}
+	switch msg {
+	case "not_authed", "invalid_auth", "account_inactive", "token_revoked", "token_expired", "missing_scope":
+		return nil, errors.Wrap(api.ErrIncompleteConfig, msg)
+	}
+	return nil, errors.Error(msg)
+}
+
+func (slackSender *SSlackSender) getBotToken(domainId string) (string, error) {
+	config, ok := models.ConfigMap[fmt.Sprintf("%s-%s", api.SLACK, domainId)]
+	if !ok || config.Content == nil {
+		return "", errors.Wrapf(errors.ErrNotSupported, "contact-type:%s,domain_id:%s is missing config", api.SLACK, domainId)
+	}
+	if len(config.Content.BotToken) == 0 {
+		return "", errors.Wrap(api.ErrIncompleteConfig, "bot_token is empty")
+	}
+	return config.Content.BotToken, nil
+}
+
+func (slackSender *SSlackSender) IsPersonal() bool {
+	return true
+}
+
+func (slackSender *SSlackSender) IsRobot() bool {
+	return false
+}
+
+func (slackSender *SSlackSender) IsValid() bool {
+	return len(slackSender.config) > 0
+}
+
+func (slackSender *SSlackSender) IsPullType() bool {
+	return true
+}
+
+func (slackSender *SSlackSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (slackSender *SSlackSender) RegisterConfig(config models.SConfig) {
+	models.ConfigMap[fmt.Sprintf("%s-%s", config.Type, config.DomainId)] = config
+}
+
+// bot token长期有效，无需获取
+func (slackSender *SSlackSender) GetAccessToken(ctx context.Context, domainId string) error {
+	return nil
+}
+
+func init() {
+	models.Register(&SSlackSender{
+		config: map[string]api.SNotifyConfigContent{},
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+type SSlackRobotSender struct {
+	config map[string]api.SNotifyConfigContent
+}
+
+func (slackRobotSender *SSlackRobotSender) GetSenderType() string {
+	return api.SLACK_ROBOT
+}
+
+// 校验incoming webhook地址，形如 https://hooks.slack.com/services/T000/B000/XXXX
+func validateImWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return errors.Wrap(InvalidWebhook, webhook)
+	}
+	return nil
+}
+
+func (slackRobotSender *SSlackRobotSender) Send(ctx context.Context, args api.SendParams) error {
+	webhook := args.Receivers.Contact
+	err := validateImWebhook(webhook)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"text": formatSlackMessage(args.Title, args.Message),
+	}
+	err = waitImQuota(ctx, webhook)
+	if err != nil {
+		return err
+	}
+	resp, err := sendImRequest(ctx, httputils.POST, webhook, nil, jsonutils.Marshal(body))
+	if err != nil {
+		return errors.Wrap(err, "slack webhook")
+	}
+	if resp.IsOK() {
+		return nil
+	}
+	msg := strings.TrimSpace(string(resp.Body))
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		// invalid_token、no_service、channel_not_found、channel_is_archived等
+		return errors.Wrap(ErrNoSuchWebhook, msg)
+	}
+	return errors.Errorf("slack webhook status %d: %s", resp.StatusCode, msg)
+}
+
+func (slackRobotSender *SSlackRobotSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (slackRobotSender *SSlackRobotSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (slackRobotSender *SSlackRobotSender) IsPersonal() bool {
+	return true
+}
+
+func (slackRobotSender *SSlackRobotSender) IsRobot() bool {
+	return true
+}
+
+func (slackRobotSender *SSlackRobotSender) IsValid() bool {
+	return len(slackRobotSender.config) > 0
+}
+
+func (slackRobotSender *SSlackRobotSender) IsPullType() bool {
+	return true
+}
+
+func (slackRobotSender *SSlackRobotSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (slackRobotSender *SSlackRobotSender) GetAccessToken(ctx context.Context, key string) error {
+	return nil
+}
+
+func (slackRobotSender *SSlackRobotSender) RegisterConfig(config models.SConfig) {
+}
+
+func init() {
+	models.Register(&SSlackRobotSender{
+		config: map[string]api.SNotifyConfigContent{},
+	})
+}
@@ -0,0 +1,270 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+const (
+	teamsBotScope   = "https://api.botframework.com/.default"
+	teamsGraphScope = "https://graph.microsoft.com/.default"
+)
+
+// 通过Bot Framework向用户发送一对一消息，联系人为用户的Azure AD object id，
+// 需要bot已经在用户的Teams中安装
+type STeamsSender struct {
+	config map[string]api.SNotifyConfigContent
+}
+
+func (teamsSender *STeamsSender) GetSenderType() string {
+	return api.TEAMS
+}
+
+func (teamsSender *STeamsSender) Send(ctx context.Context, args api.SendParams) error {
+	err := waitImQuota(ctx, fmt.Sprintf("%s-%s", api.TEAMS, args.Receivers.Contact))
+	if err != nil {
+		return err
+	}
+	conf, err := teamsSender.getConfig(args.DomainId)
+	if err != nil {
+		return err
+	}
+	// 与用户的一对一会话，重复创建返回同一个会话
+	member := args.Receivers.Contact
+	if !strings.HasPrefix(member, "29:") && !strings.HasPrefix(member, "8:") {
+		member = "8:orgid:" + member
+	}
+	conversation := map[string]interface{}{
+		"bot": map[string]interface{}{
+			"id": "28:" + conf.AppId,
+		},
+		"members": []interface{}{
+			map[string]interface{}{"id": member},
+		},
+		"channelData": map[string]interface{}{
+			"tenant": map[string]interface{}{"id": conf.TenantId},
+		},
+		"tenantId": conf.TenantId,
+		"isGroup":  false,
+	}
+	resp, err := teamsSender.botRequest(ctx, args.DomainId, teamsSender.serviceUrl(conf, "v3/conversations"), jsonutils.Marshal(conversation))
+	if err != nil {
+		return errors.Wrap(err, "create conversation")
+	}
+	conversationId, err := resp.GetString("id")
+	if err != nil {
+		return errors.Wrapf(err, "create conversation result: %s", resp)
+	}
+	activity := map[string]interface{}{
+		"type":        "message",
+		"summary":     args.Title,
+		"attachments": teamsCardAttachments(args.Title, args.Message),
+	}
+	_, err = teamsSender.botRequest(ctx, args.DomainId, teamsSender.serviceUrl(conf, fmt.Sprintf("v3/conversations/%s/activities", url.PathEscape(conversationId))), jsonutils.Marshal(activity))
+	if err != nil {
+		return errors.Wrap(err, "send activity")
+	}
+	return nil
+}
+
+func (teamsSender *STeamsSender) serviceUrl(conf *api.SNotifyConfigContent, path string) string {
+	serviceUrl := conf.ServiceUrl
+	if len(serviceUrl) == 0 {
+		serviceUrl = ApiTeamsServiceUrl
+	}
+	return strings.TrimSuffix(serviceUrl, "/") + "/" + path
+}
+
+// token过期时重新获取一次
+func (teamsSender *STeamsSender) botRequest(ctx context.Context, domainId, uri string, body jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	conf, err := teamsSender.getConfig(domainId)
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.AccessToken) == 0 {
+		err = teamsSender.GetAccessToken(ctx, domainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; ; i++ {
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+conf.AccessToken)
+		resp, err := sendImRequest(ctx, httputils.POST, uri, header, body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && i == 0 {
+			err = teamsSender.GetAccessToken(ctx, domainId)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !resp.IsOK() {
+			msg := strings.TrimSpace(string(resp.Body))
+			if resp.StatusCode == http.StatusForbidden {
+				// bot未在用户的Teams中安装或者不属于该租户
+				return nil, errors.Wrap(api.ErrIncompleteConfig, msg)
+			}
+			return nil, errors.Errorf("status %d: %s", resp.StatusCode, msg)
+		}
+		if len(resp.Body) == 0 {
+			return jsonutils.NewDict(), nil
+		}
+		return resp.JSON()
+	}
+}
+
+func (teamsSender *STeamsSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	if len(config.AppId) == 0 || len(config.AppSecret) == 0 || len(config.TenantId) == 0 {
+		return "app_id, app_secret and tenant_id are required", api.ErrIncompleteConfig
+	}
+	_, err := teamsSender.getAccessToken(ctx, config.TenantId, config.AppId, config.AppSecret, teamsBotScope)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "AADSTS90002"):
+			return "invalid tenant_id", err
+		case strings.Contains(err.Error(), "AADSTS700016"):
+			return "invalid app_id", err
+		case strings.Contains(err.Error(), "AADSTS7000215"):
+			return "invalid app_secret", err
+		}
+		return "", err
+	}
+	return "", nil
+}
+
+func (teamsSender *STeamsSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+// 通过Microsoft Graph根据邮箱(UPN)查找用户的object id，需要应用具有User.Read.All权限
+func (teamsSender *STeamsSender) ContactByEmail(ctx context.Context, email, domainId string) (string, error) {
+	conf, err := teamsSender.getConfig(domainId)
+	if err != nil {
+		return "", err
+	}
+	token, err := teamsSender.getAccessToken(ctx, conf.TenantId, conf.AppId, conf.AppSecret, teamsGraphScope)
+	if err != nil {
+		return "", errors.Wrap(err, "get graph token")
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	resp, err := sendImRequest(ctx, httputils.GET, fmt.Sprintf(ApiTeamsGetUser, url.PathEscape(email)), header, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "get user")
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return "", errors.Wrap(api.ErrNoSuchEmail, email)
+	case http.StatusForbidden:
+		return "", errors.Wrap(api.ErrIncompleteConfig, "User.Read.All permission is required")
+	}
+	if !resp.IsOK() {
+		return "", errors.Errorf("get user status %d: %s", resp.StatusCode, string(resp.Body))
+	}
+	obj, err := resp.JSON()
+	if err != nil {
+		return "", err
+	}
+	return obj.GetString("id")
+}
+
+func (teamsSender *STeamsSender) IsPersonal() bool {
+	return true
+}
+
+func (teamsSender *STeamsSender) IsRobot() bool {
+	return false
+}
+
+func (teamsSender *STeamsSender) IsValid() bool {
+	return len(teamsSender.config) > 0
+}
+
+func (teamsSender *STeamsSender) IsPullType() bool {
+	return true
+}
+
+func (teamsSender *STeamsSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (teamsSender *STeamsSender) RegisterConfig(config models.SConfig) {
+	models.ConfigMap[fmt.Sprintf("%s-%s", config.Type, config.DomainId)] = config
+}
+
+func (teamsSender *STeamsSender) getConfig(domainId string) (*api.SNotifyConfigContent, error) {
+	config, ok := models.ConfigMap[fmt.Sprintf("%s-%s", api.TEAMS, domainId)]
+	if !ok || config.Content == nil {
+		return nil, errors.Wrapf(errors.ErrNotSupported, "contact-type:%s,domain_id:%s is missing config", api.TEAMS, domainId)
+	}
+	return config.Content, nil
+}
+
+// 获取bot framework的token
+func (teamsSender *STeamsSender) GetAccessToken(ctx context.Context, domainId string) error {
+	conf, err := teamsSender.getConfig(domainId)
+	if err != nil {
+		return err
+	}
+	token, err := teamsSender.getAccessToken(ctx, conf.TenantId, conf.AppId, conf.AppSecret, teamsBotScope)
+	if err != nil {
+		return errors.Wrap(err, "teams getAccessToken")
+	}
+	conf.AccessToken = token
+	return nil
+}
+
+func (teamsSender *STeamsSender) getAccessToken(ctx context.Context, tenantId, appId, appSecret, scope string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", appId)
+	form.Set("client_secret", appSecret)
+	form.Set("scope", scope)
+	resp, err := sendImFormRequest(ctx, fmt.Sprintf(ApiTeamsGetToken, url.PathEscape(tenantId)), form)
+	if err != nil {
+		return "", err
+	}
+	obj, err := resp.JSON()
+	if err != nil {
+		return "", err
+	}
+	if !resp.IsOK() {
+		desc, _ := obj.GetString("error_description")
+		return "", errors.Errorf("get token status %d: %s", resp.StatusCode, desc)
+	}
+	return obj.GetString("access_token")
+}
+
+func init() {
+	models.Register(&STeamsSender{
+		config: map[string]api.SNotifyConfigContent{},
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+type STeamsRobotSender struct {
+	config map[string]api.SNotifyConfigContent
+}
+
+func (teamsRobotSender *STeamsRobotSender) GetSenderType() string {
+	return api.TEAMS_ROBOT
+}
+
+// 将标题和内容转换为Adaptive Card，内容按行拆分为TextBlock以保留换行
+func formatTeamsCard(title, message string) map[string]interface{} {
+	blocks := []interface{}{}
+	if len(title) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type":   "TextBlock",
+			"text":   title,
+			"weight": "Bolder",
+			"size":   "Medium",
+			"wrap":   true,
+		})
+	}
+	for _, line := range strings.Split(message, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type":    "TextBlock",
+			"text":    line,
+			"wrap":    true,
+			"spacing": "None",
+		})
+	}
+	return map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"version": "1.4",
+		"body":    blocks,
+	}
+}
+
+func teamsCardAttachments(title, message string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     formatTeamsCard(title, message),
+		},
+	}
+}
+
+// 支持Teams的Workflows webhook以及旧版的Incoming Webhook连接器
+func (teamsRobotSender *STeamsRobotSender) Send(ctx context.Context, args api.SendParams) error {
+	webhook := args.Receivers.Contact
+	err := validateImWebhook(webhook)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"type":        "message",
+		"attachments": teamsCardAttachments(args.Title, args.Message),
+	}
+	err = waitImQuota(ctx, webhook)
+	if err != nil {
+		return err
+	}
+	resp, err := sendImRequest(ctx, httputils.POST, webhook, nil, jsonutils.Marshal(body))
+	if err != nil {
+		return errors.Wrap(err, "teams webhook")
+	}
+	msg := strings.TrimSpace(string(resp.Body))
+	if resp.IsOK() {
+		// 旧版连接器投递失败时仍然返回200，错误信息在响应体中
+		if strings.Contains(msg, "failed") {
+			if strings.Contains(msg, "429") {
+				return errors.Wrap(ErrRateLimited, msg)
+			}
+			return errors.Error(msg)
+		}
+		return nil
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return errors.Wrap(ErrNoSuchWebhook, msg)
+	}
+	return errors.Errorf("teams webhook status %d: %s", resp.StatusCode, msg)
+}
+
+func (teamsRobotSender *STeamsRobotSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (teamsRobotSender *STeamsRobotSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (teamsRobotSender *STeamsRobotSender) IsPersonal() bool {
+	return true
+}
+
+func (teamsRobotSender *STeamsRobotSender) IsRobot() bool {
+	return true
+}
+
+func (teamsRobotSender *STeamsRobotSender) IsValid() bool {
+	return len(teamsRobotSender.config) > 0
+}
+
+func (teamsRobotSender *STeamsRobotSender) IsPullType() bool {
+	return true
+}
+
+func (teamsRobotSender *STeamsRobotSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (teamsRobotSender *STeamsRobotSender) GetAccessToken(ctx context.Context, key string) error {
+	return nil
+}
+
+func (teamsRobotSender *STeamsRobotSender) RegisterConfig(config models.SConfig) {
+}
+
+func init() {
+	models.Register(&STeamsRobotSender{
+		config: map[string]api.SNotifyConfigContent{},
+	})
+}
@@ -0,0 +1,311 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/httputils"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+const (
+	// telegram单条消息最多4096个字符
+	telegramMaxMessageLength = 4000
+	// getUpdates单次最多返回100条更新
+	telegramGetUpdatesLimit = 100
+	// 收到的绑定消息缓存时间，超过验证码有效期即可
+	telegramBindMessageExpire = time.Hour
+)
+
+// telegram无法通过手机号或邮箱查找用户，需要用户打开绑定链接向bot发送验证码，
+// 之后通过getUpdates找到用户的chat id，因此bot不能设置webhook
+type STelegramSender struct {
+	config map[string]api.SNotifyConfigContent
+
+	lock sync.Mutex
+	// bot token -> 收到的绑定消息
+	bindMessages map[string]*sTelegramBindMessages
+}
+
+type sTelegramBindChat struct {
+	chatId     string
+	receivedAt time.Time
+}
+
+// telegram只保留未确认的更新，getUpdates带上offset才会确认之前读取的更新，
+// 否则每次只能读到最早的100条，因此读取到的绑定消息需要缓存到被查找为止
+type sTelegramBindMessages struct {
+	lock   sync.Mutex
+	offset int64
+	// 验证码 -> 发送者
+	chats map[string]sTelegramBindChat
+}
+
+func newTelegramBindMessages() *sTelegramBindMessages {
+	return &sTelegramBindMessages{
+		chats: map[string]sTelegramBindChat{},
+	}
+}
+
+// collect 记录getUpdates返回的私聊消息并推进offset，返回更新的数量
+func (msgs *sTelegramBindMessages) collect(resp jsonutils.JSONObject, now time.Time) int {
+	updates, _ := resp.GetArray("result")
+	for _, update := range updates {
+		updateId, err := update.Int("update_id")
+		if err != nil {
+			continue
+		}
+		if updateId >= msgs.offset {
+			msgs.offset = updateId + 1
+		}
+		chatType, _ := update.GetString("message", "chat", "type")
+		if chatType != "private" {
+			continue
+		}
+		chatId, err := update.Int("message", "chat", "id")
+		if err != nil {
+			continue
+		}
+		text, _ := update.GetString("message", "text")
+		text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "/start "))
+		if len(text) == 0 {
+			continue
+		}
+		msgs.chats[text] = sTelegramBindChat{chatId: fmt.Sprintf("%d", chatId), receivedAt: now}
+	}
+	for token, chat := range msgs.chats {
+		if now.Sub(chat.receivedAt) > telegramBindMessageExpire {
+			delete(msgs.chats, token)
+		}
+	}
+	return len(updates)
+}
+
+// take 返回发送验证码的chat id，每条绑定消息只能使用一次
+func (msgs *sTelegramBindMessages) take(token string) (string, bool) {
+	chat, ok := msgs.chats[token]
+	if !ok {
+		return "", false
+	}
+	delete(msgs.chats, token)
+	return chat.chatId, true
+}
+
+func (telegramSender *STelegramSender) getBindMessages(botToken string) *sTelegramBindMessages {
+	telegramSender.lock.Lock()
+	defer telegramSender.lock.Unlock()
+	msgs, ok := telegramSender.bindMessages[botToken]
+	if !ok {
+		msgs = newTelegramBindMessages()
+		telegramSender.bindMessages[botToken] = msgs
+	}
+	return msgs
+}
+
+func (telegramSender *STelegramSender) GetSenderType() string {
+	return api.TELEGRAM
+}
+
+// 使用HTML格式发送，标题加粗，内容转义
+func formatTelegramMessage(title, message string) string {
+	if runes := []rune(message); len(runes) > telegramMaxMessageLength {
+		message = string(runes[:telegramMaxMessageLength]) + "..."
+	}
+	if len(title) == 0 {
+		return html.EscapeString(message)
+	}
+	return fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(title), html.EscapeString(message))
+}
+
+func telegramApiUrl(serviceUrl, botToken, method string) string {
+	if len(serviceUrl) == 0 {
+		serviceUrl = ApiTelegram
+	}
+	return fmt.Sprintf("%s/bot%s/%s", strings.TrimSuffix(serviceUrl, "/"), botToken, method)
+}
+
+// telegram bot api通过ok字段判断是否成功，失败时description为错误信息
+func telegramRequest(ctx context.Context, uri string, body jsonutils.JSONObject) (jsonutils.JSONObject, error) {
+	resp, err := sendImRequest(ctx, httputils.POST, uri, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := resp.JSON()
+	if err != nil {
+		return nil, errors.Wrapf(err, "status %d", resp.StatusCode)
+	}
+	if ok, _ := obj.Bool("ok"); ok {
+		return obj, nil
+	}
+	desc, _ := obj.GetString("description")
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, errors.Wrap(api.ErrIncompleteConfig, desc)
+	case http.StatusNotFound:
+		return nil, errors.Wrap(ErrNoSuchWebhook, desc)
+	}
+	return nil, errors.Errorf("status %d: %s", resp.StatusCode, desc)
+}
+
+func telegramSendMessage(ctx context.Context, uri string, chatId string, threadId string, title, message string) error {
+	body := jsonutils.NewDict()
+	body.Set("chat_id", jsonutils.NewString(chatId))
+	if len(threadId) > 0 {
+		body.Set("message_thread_id", jsonutils.NewString(threadId))
+	}
+	body.Set("text", jsonutils.NewString(formatTelegramMessage(title, message)))
+	body.Set("parse_mode", jsonutils.NewString("HTML"))
+	body.Set("disable_web_page_preview", jsonutils.JSONTrue)
+	err := waitImQuota(ctx, fmt.Sprintf("%s-%s", api.TELEGRAM, chatId))
+	if err != nil {
+		return err
+	}
+	_, err = telegramRequest(ctx, uri, body)
+	if err != nil {
+		return errors.Wrap(err, "telegram sendMessage")
+	}
+	return nil
+}
+
+func (telegramSender *STelegramSender) Send(ctx context.Context, args api.SendParams) error {
+	conf, err := telegramSender.getConfig(args.DomainId)
+	if err != nil {
+		return err
+	}
+	return telegramSendMessage(ctx, telegramApiUrl(conf.ServiceUrl, conf.BotToken, "sendMessage"), args.Receivers.Contact, "", args.Title, args.Message)
+}
+
+func (telegramSender *STelegramSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	if len(config.BotToken) == 0 {
+		return "bot_token is required", api.ErrIncompleteConfig
+	}
+	_, err := telegramRequest(ctx, telegramApiUrl(config.ServiceUrl, config.BotToken, "getMe"), nil)
+	if err != nil {
+		return "invalid bot_token", err
+	}
+	return "", nil
+}
+
+func (telegramSender *STelegramSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+// 用户打开链接后点击Start，会向bot发送"/start <token>"
+func (telegramSender *STelegramSender) BindUrl(ctx context.Context, token, domainId string) (string, error) {
+	conf, err := telegramSender.getConfig(domainId)
+	if err != nil {
+		return "", err
+	}
+	me, err := telegramRequest(ctx, telegramApiUrl(conf.ServiceUrl, conf.BotToken, "getMe"), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "telegram getMe")
+	}
+	username, err := me.GetString("result", "username")
+	if err != nil {
+		return "", errors.Wrapf(err, "getMe result: %s", me)
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s", username, url.QueryEscape(token)), nil
+}
+
+// 在bot收到的私聊消息中查找验证码，返回发送者的chat id
+func (telegramSender *STelegramSender) ContactByToken(ctx context.Context, token, domainId string) (string, error) {
+	conf, err := telegramSender.getConfig(domainId)
+	if err != nil {
+		return "", err
+	}
+	msgs := telegramSender.getBindMessages(conf.BotToken)
+	msgs.lock.Lock()
+	defer msgs.lock.Unlock()
+
+	for {
+		body := jsonutils.NewDict()
+		body.Set("allowed_updates", jsonutils.NewStringArray([]string{"message"}))
+		body.Set("limit", jsonutils.NewInt(telegramGetUpdatesLimit))
+		if msgs.offset > 0 {
+			body.Set("offset", jsonutils.NewInt(msgs.offset))
+		}
+		resp, err := telegramRequest(ctx, telegramApiUrl(conf.ServiceUrl, conf.BotToken, "getUpdates"), body)
+		if err != nil {
+			return "", errors.Wrap(err, "telegram getUpdates")
+		}
+		if msgs.collect(resp, time.Now()) < telegramGetUpdatesLimit {
+			break
+		}
+	}
+	if chatId, ok := msgs.take(token); ok {
+		return chatId, nil
+	}
+	return "", errors.Wrap(errors.ErrNotFound, "verification code not received by telegram bot")
+}
+
+func (telegramSender *STelegramSender) IsPersonal() bool {
+	return true
+}
+
+func (telegramSender *STelegramSender) IsRobot() bool {
+	return false
+}
+
+func (telegramSender *STelegramSender) IsValid() bool {
+	return len(telegramSender.config) > 0
+}
+
+func (telegramSender *STelegramSender) IsPullType() bool {
+	return false
+}
+
+func (telegramSender *STelegramSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (telegramSender *STelegramSender) RegisterConfig(config models.SConfig) {
+	models.ConfigMap[fmt.Sprintf("%s-%s", config.Type, config.DomainId)] = config
+}
+
+func (telegramSender *STelegramSender) getConfig(domainId string) (*api.SNotifyConfigContent, error) {
+	config, ok := models.ConfigMap[fmt.Sprintf("%s-%s", api.TELEGRAM, domainId)]
+	if !ok || config.Content == nil {
+		return nil, errors.Wrapf(errors.ErrNotSupported, "contact-type:%s,domain_id:%s is missing config", api.TELEGRAM, domainId)
+	}
+	if len(config.Content.BotToken) == 0 {
+		return nil, errors.Wrap(api.ErrIncompleteConfig, "bot_token is empty")
+	}
+	return config.Content, nil
+}
+
+// bot token长期有效，无需获取
+func (telegramSender *STelegramSender) GetAccessToken(ctx context.Context, domainId string) error {
+	return nil
+}
+
+func init() {
+	models.Register(&STelegramSender{
+		config:       map[string]api.SNotifyConfigContent{},
+		bindMessages: map[string]*sTelegramBindMessages{},
+	})
+}
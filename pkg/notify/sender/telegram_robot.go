@@ -0,0 +1,101 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/notify"
+	"yunion.io/x/onecloud/pkg/notify/models"
+)
+
+type STelegramRobotSender struct {
+	config map[string]api.SNotifyConfigContent
+}
+
+func (telegramRobotSender *STelegramRobotSender) GetSenderType() string {
+	return api.TELEGRAM_ROBOT
+}
+
+// 机器人地址形如 https://api.telegram.org/bot<token>/sendMessage?chat_id=<chat_id>[&message_thread_id=<id>]，
+// chat_id可以是群组或频道的id，也可以是@channelusername
+func parseTelegramRobotAddress(address string) (uri string, chatId string, threadId string, err error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 || !strings.HasSuffix(u.Path, "/sendMessage") {
+		return "", "", "", errors.Wrap(InvalidWebhook, address)
+	}
+	query := u.Query()
+	chatId = query.Get("chat_id")
+	if len(chatId) == 0 {
+		return "", "", "", errors.Wrap(InvalidWebhook, "missing chat_id")
+	}
+	threadId = query.Get("message_thread_id")
+	u.RawQuery = ""
+	return u.String(), chatId, threadId, nil
+}
+
+func (telegramRobotSender *STelegramRobotSender) Send(ctx context.Context, args api.SendParams) error {
+	uri, chatId, threadId, err := parseTelegramRobotAddress(args.Receivers.Contact)
+	if err != nil {
+		return err
+	}
+	return telegramSendMessage(ctx, uri, chatId, threadId, args.Title, args.Message)
+}
+
+func (telegramRobotSender *STelegramRobotSender) ValidateConfig(ctx context.Context, config api.NotifyConfig) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (telegramRobotSender *STelegramRobotSender) ContactByMobile(ctx context.Context, mobile, domainId string) (string, error) {
+	return "", cloudprovider.ErrNotImplemented
+}
+
+func (telegramRobotSender *STelegramRobotSender) IsPersonal() bool {
+	return true
+}
+
+func (telegramRobotSender *STelegramRobotSender) IsRobot() bool {
+	return true
+}
+
+func (telegramRobotSender *STelegramRobotSender) IsValid() bool {
+	return len(telegramRobotSender.config) > 0
+}
+
+func (telegramRobotSender *STelegramRobotSender) IsPullType() bool {
+	return true
+}
+
+func (telegramRobotSender *STelegramRobotSender) IsSystemConfigContactType() bool {
+	return true
+}
+
+func (telegramRobotSender *STelegramRobotSender) GetAccessToken(ctx context.Context, key string) error {
+	return nil
+}
+
+func (telegramRobotSender *STelegramRobotSender) RegisterConfig(config models.SConfig) {
+}
+
+func init() {
+	models.Register(&STelegramRobotSender{
+		config: map[string]api.SNotifyConfigContent{},
+	})
+}
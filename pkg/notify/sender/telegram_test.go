@@ -0,0 +1,79 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sender
+
+import (
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+)
+
+func TestFormatTelegramMessage(t *testing.T) {
+	got := formatTelegramMessage("<b>Alert</b>", "a < b & c")
+	want := "<b>&lt;b&gt;Alert&lt;/b&gt;</b>\na &lt; b &amp; c"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseTelegramRobotAddress(t *testing.T) {
+	uri, chatId, threadId, err := parseTelegramRobotAddress("https://api.telegram.org/bot123:abc/sendMessage?chat_id=-100123&message_thread_id=7")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if uri != "https://api.telegram.org/bot123:abc/sendMessage" || chatId != "-100123" || threadId != "7" {
+		t.Errorf("got %q %q %q", uri, chatId, threadId)
+	}
+	for _, addr := range []string{
+		"https://api.telegram.org/bot123:abc/sendMessage",
+		"https://api.telegram.org/bot123:abc/getMe?chat_id=1",
+		"http://api.telegram.org/bot123:abc/sendMessage?chat_id=1",
+	} {
+		if _, _, _, err := parseTelegramRobotAddress(addr); errors.Cause(err) != InvalidWebhook {
+			t.Errorf("%s: want InvalidWebhook, got %v", addr, err)
+		}
+	}
+}
+
+func TestTelegramBindMessages(t *testing.T) {
+	now := time.Now()
+	msgs := newTelegramBindMessages()
+	resp, _ := jsonutils.ParseString(`{"ok":true,"result":[
+		{"update_id":1,"message":{"text":"/start 123456","chat":{"id":-1001,"type":"group"}}},
+		{"update_id":2,"message":{"text":"/start 654321","chat":{"id":42,"type":"private"}}},
+		{"update_id":3,"message":{"text":"123456","chat":{"id":43,"type":"private"}}}
+	]}`)
+	if n := msgs.collect(resp, now.Add(-2*telegramBindMessageExpire)); n != 3 || msgs.offset != 4 {
+		t.Fatalf("got %d updates, offset %d, want 3 and 4", n, msgs.offset)
+	}
+	resp, _ = jsonutils.ParseString(`{"ok":true,"result":[
+		{"update_id":4,"message":{"text":"/start abcdef","chat":{"id":44,"type":"private"}}}
+	]}`)
+	if n := msgs.collect(resp, now); n != 1 || msgs.offset != 5 {
+		t.Fatalf("got %d updates, offset %d, want 1 and 5", n, msgs.offset)
+	}
+	// the messages received before the expiration are dropped
+	if chatId, ok := msgs.take("123456"); ok {
+		t.Errorf("expired message is taken by %s", chatId)
+	}
+	if chatId, ok := msgs.take("abcdef"); !ok || chatId != "44" {
+		t.Errorf("got %q %v, want 44", chatId, ok)
+	}
+	if _, ok := msgs.take("abcdef"); ok {
+		t.Errorf("message is taken twice")
+	}
+}
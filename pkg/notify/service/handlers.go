@@ -17,6 +17,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	"yunion.io/x/onecloud/pkg/notify/models"
@@ -38,10 +39,12 @@ func InitHandlers(app *appsrv.Application) {
 	db.AddScopeResourceCountHandler(API_VERSION, app)
 
 	taskman.AddTaskHandler(API_VERSION, app)
+	cronman.AddCronJobHandler(API_VERSION, app)
 	for _, manager := range []db.IModelManager{
 		taskman.TaskManager,
 		taskman.SubTaskManager,
 		taskman.TaskObjectManager,
+		taskman.TaskReplicaManager,
 
 		db.UserCacheManager,
 		db.TenantCacheManager,
@@ -51,6 +54,7 @@ func InitHandlers(app *appsrv.Application) {
 		models.VerificationManager,
 		models.EventManager,
 		models.EmailQueueStatusManager,
+		models.NotificationEscalationManager,
 	} {
 		db.RegisterModelManager(manager)
 	}
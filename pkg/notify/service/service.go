@@ -27,6 +27,7 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/app"
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db/taskman"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
 	"yunion.io/x/onecloud/pkg/notify/models"
 	"yunion.io/x/onecloud/pkg/notify/options"
@@ -61,6 +62,15 @@ func StartService() {
 	db.EnsureAppSyncDB(applicaion, dbOpts, models.InitDB)
 	defer cloudcommon.CloseDB()
 
+	taskman.RegisterTaskStageCollector()
+	taskman.StartReplicaHeartbeat(applicaion.GetContext())
+	if !opts.IsSlaveNode {
+		// resume or fail the unfinished tasks left by the dead replicas of the service
+		if err := taskman.TaskManager.ResumeTasks(applicaion.GetContext()); err != nil {
+			log.Errorf("ResumeTasks fail: %s", err)
+		}
+	}
+
 	if options.Options.EnableWatchUser {
 		err := models.ReceiverManager.StartWatchUserInKeystone()
 		if err != nil {
@@ -75,7 +85,10 @@ func StartService() {
 	// wrapped func to resend notifications
 	cron.AddJobAtIntervals("ReSendNotifications", time.Duration(opts.ReSendScope)*time.Second, models.NotificationManager.ReSend)
 	cron.AddJobEveryFewHour("AutoPurgeSplitable", 4, 30, 0, db.AutoPurgeSplitable, false)
+	cron.AddJobAtIntervals("CheckTimeoutTasks", time.Duration(opts.TaskTimeoutCheckIntervalSeconds)*time.Second, taskman.TaskManager.CheckTimeoutTasks)
 	cron.AddJobEveryFewDays("InitReceiverProject", 7, 0, 0, 0, models.InitReceiverProject, true)
+	cron.AddJobAtIntervals("FlushNotificationDigests", time.Minute, models.NotificationGroupManager.FlushDigests)
+	cron.AddJobAtIntervals("EscalateNotifications", time.Minute, models.NotificationEscalationManager.Escalate)
 
 	cron.Start()
 
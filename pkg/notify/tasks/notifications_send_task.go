@@ -19,7 +19,6 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"yunion.io/x/jsonutils"
@@ -64,13 +63,6 @@ type ReceiverSpec struct {
 	rNotificaion *models.SReceiverNotification
 }
 
-var notificationSendMap sync.Map
-var notificationGroupLock sync.Mutex
-
-func init() {
-	notificationGroupLock = sync.Mutex{}
-}
-
 func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandaloneModel, body jsonutils.JSONObject) {
 	notification := obj.(*models.SNotification)
 	if notification.Status == apis.NOTIFICATION_STATUS_OK {
@@ -214,7 +206,7 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 			rn.rNotificaion.BeforeSend(ctx, now)
 		}
 		// send
-		fds, err := self.batchSend(ctx, notification, receivers, p)
+		fds, deferred, err := self.batchSend(ctx, notification, receivers, p)
 		if err != nil {
 			for _, r := range receivers {
 				sendFail(r.rNotificaion, err.Error())
@@ -227,6 +219,11 @@ func (self *NotificationSendTask) OnInit(ctx context.Context, obj db.IStandalone
 			sendFail(fd.rNotificaion, fd.Reason)
 			failedRnIds[fd.rNotificaion.RowId] = struct{}{}
 		}
+		// deferred notify will be updated after the digest is sent
+		for _, r := range deferred {
+			r.rNotificaion.Defer(ctx)
+			failedRnIds[r.rNotificaion.RowId] = struct{}{}
+		}
 		// after send for successful notify
 		for _, r := range receivers {
 			if _, ok := failedRnIds[r.rNotificaion.RowId]; ok {
@@ -253,23 +250,24 @@ type FailedReceiverSpec struct {
 	Reason string
 }
 
-func (notificationSendTask *NotificationSendTask) batchSend(ctx context.Context, notification *models.SNotification, receivers []ReceiverSpec, params apis.SendParams) (fails []FailedReceiverSpec, err error) {
+func (notificationSendTask *NotificationSendTask) batchSend(ctx context.Context, notification *models.SNotification, receivers []ReceiverSpec, params apis.SendParams) (fails []FailedReceiverSpec, deferred []ReceiverSpec, err error) {
 	if notification.ContactType == apis.WEBCONSOLE {
 		return
 	}
 	for i := range receivers {
+		isDeferred := false
 		if receivers[i].receiver.IsRobot() {
 			robot := receivers[i].receiver.(*models.SRobot)
-			driver := models.GetDriver(fmt.Sprintf("%s-robot", robot.Type))
+			// 机器人的汇总消息按机器人类型的渠道发送
+			robotType := fmt.Sprintf("%s-robot", robot.Type)
+			driver := models.GetDriver(robotType)
 			params.Receivers.Contact = robot.Address
 			params.Header = robot.Header
 			params.Body = robot.Body
 			params.MsgKey = robot.MsgKey
 			params.GroupTimes = uint(receivers[i].rNotificaion.GroupTimes)
-			err = driver.Send(ctx, params)
-			if err != nil {
-				fails = append(fails, FailedReceiverSpec{ReceiverSpec: receivers[i], Reason: err.Error()})
-			}
+			params.ReceiverId = robot.Id
+			isDeferred, err = models.NotificationGroupManager.Dispatch(ctx, driver, robotType, notification.Id, params, time.Time{})
 		} else if receivers[i].receiver.IsReceiver() {
 			receiver := receivers[i].receiver.(*models.SReceiver)
 			params.Receivers.Contact, _ = receiver.GetContact(notification.ContactType)
@@ -289,69 +287,25 @@ func (notificationSendTask *NotificationSendTask) batchSend(ctx context.Context,
 			}
 			params.ReceiverId = receiver.Id
 			params.SendTime = time.Now().Truncate(time.Second)
-			if len(params.GroupKey) > 0 && params.GroupTimes > 0 {
-				notificationGroupLock.Lock()
-				if _, ok := notificationSendMap.Load(params.GroupKey + receiver.Id + notification.ContactType); ok {
-					err = models.NotificationGroupManager.TaskCreate(ctx, notification.ContactType, params)
-				} else {
-					err = models.NotificationGroupManager.TaskCreate(ctx, notification.ContactType, params)
-					if err != nil {
-						fails = append(fails, FailedReceiverSpec{ReceiverSpec: receivers[i], Reason: err.Error()})
-					}
-					err = driver.Send(ctx, params)
-					createTimeTicker(ctx, driver, params, receiver.Id, notification.ContactType)
+			// fatal 级别的消息不受免打扰时段限制
+			var quietUntil time.Time
+			if notification.Priority != apis.NOTIFICATION_PRIORITY_CRITICAL {
+				if end, ok := receiver.QuietUntil(params.SendTime); ok {
+					quietUntil = end
 				}
-				notificationGroupLock.Unlock()
-			} else {
-				err = driver.Send(ctx, params)
-			}
-			if err != nil {
-				fails = append(fails, FailedReceiverSpec{ReceiverSpec: receivers[i], Reason: err.Error()})
 			}
+			isDeferred, err = models.NotificationGroupManager.Dispatch(ctx, driver, notification.ContactType, notification.Id, params, quietUntil)
 		} else {
 			receiver := receivers[i].receiver.(*models.SContact)
 			params.Receivers.Contact, _ = receiver.GetContact(notification.ContactType)
 			driver := models.GetDriver(notification.ContactType)
 			err = driver.Send(ctx, params)
-			if err != nil {
-				fails = append(fails, FailedReceiverSpec{ReceiverSpec: receivers[i], Reason: err.Error()})
-			}
 		}
-	}
-	return fails, nil
-}
-
-func createTimeTicker(ctx context.Context, driver models.ISenderDriver, params apis.SendParams, receiverId, contactType string) {
-	// 创建一个计时器，每秒触发一次
-	// params.GroupTimes = 5
-	timer := time.NewTicker(time.Duration(params.GroupTimes) * time.Minute)
-	notificationSendMap.Store(params.GroupKey+receiverId+contactType, apis.SNotificationGroupSearchInput{
-		GroupKey:    params.GroupKey,
-		ReceiverId:  receiverId,
-		ContactType: contactType,
-		StartTime:   params.SendTime,
-		EndTime:     params.SendTime.Add(time.Duration(params.GroupTimes) * time.Minute),
-	})
-
-	// 启动一个goroutine来处理计时器触发的事件
-	go func() {
-		for {
-			// 等待计时器触发的事件
-			<-timer.C
-			// 处理计时器触发的事件
-			arrValue, ok := notificationSendMap.Load(params.GroupKey + receiverId + contactType)
-			if !ok {
-				return
-			}
-			input := arrValue.(apis.SNotificationGroupSearchInput)
-			// 组装聚合后的消息
-			sendParams, err := models.NotificationGroupManager.TaskSend(ctx, input)
-			if err != nil {
-				log.Errorln("TaskSend err:", err)
-				return
-			}
-			driver.Send(ctx, *sendParams)
-			notificationSendMap.Delete(params.GroupKey + receiverId + contactType)
+		if err != nil {
+			fails = append(fails, FailedReceiverSpec{ReceiverSpec: receivers[i], Reason: err.Error()})
+		} else if isDeferred {
+			deferred = append(deferred, receivers[i])
 		}
-	}()
+	}
+	return fails, deferred, nil
 }
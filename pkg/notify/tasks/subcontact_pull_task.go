@@ -39,6 +39,13 @@ var PullContactType = []string{
 	apis.DINGTALK,
 	apis.FEISHU,
 	apis.WORKWX,
+	apis.SLACK,
+	apis.TEAMS,
+}
+
+// 由用户主动绑定的渠道，这里只负责启用和禁用
+var BindContactType = []string{
+	apis.TELEGRAM,
 }
 
 var UserContactType = []string{
@@ -50,6 +57,9 @@ var allContactTypes = []string{
 	apis.DINGTALK,
 	apis.FEISHU,
 	apis.WORKWX,
+	apis.SLACK,
+	apis.TEAMS,
+	apis.TELEGRAM,
 	apis.EMAIL,
 	apis.MOBILE,
 }
@@ -73,7 +83,7 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 	failedReasons := make([]string, 0)
 	// pull contacts
 	receiver := obj.(*models.SReceiver)
-	if len(receiver.Mobile) == 0 {
+	if len(receiver.Mobile) == 0 && len(receiver.Email) == 0 {
 		self.SetStageComplete(ctx, nil)
 		return
 	}
@@ -83,9 +93,12 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 	if strings.HasPrefix(mobile, "+86 ") {
 		mobile = strings.TrimSpace(mobile[4:])
 	}
-	params := map[string]string{
-		"email":  receiver.Email,
-		"mobile": receiver.Mobile,
+	params := map[string]string{}
+	if len(receiver.Email) > 0 {
+		params["email"] = receiver.Email
+	}
+	if len(receiver.Mobile) > 0 {
+		params["mobile"] = receiver.Mobile
 	}
 	_, err := identity.UsersV3.Update(s, receiver.Id, jsonutils.Marshal(params))
 	if err != nil {
@@ -104,13 +117,28 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 			// 常规渠道
 			if utils.IsInStringArray(contactType, PullContactType) {
 				content := ""
+				parentContactType := apis.MOBILE
 				driver := models.GetDriver(contactType)
-				content, err = driver.ContactByMobile(ctx, mobile, self.UserCred.GetDomainId())
+				if emailDriver, ok := driver.(models.IEmailContactDriver); ok {
+					parentContactType = apis.EMAIL
+					if len(receiver.Email) == 0 {
+						err = errors.Wrap(apis.ErrNoSuchEmail, "email is empty")
+					} else {
+						content, err = emailDriver.ContactByEmail(ctx, receiver.Email, self.UserCred.GetDomainId())
+					}
+				} else if len(mobile) == 0 {
+					err = errors.Wrap(apis.ErrNoSuchMobile, "mobile is empty")
+				} else {
+					content, err = driver.ContactByMobile(ctx, mobile, self.UserCred.GetDomainId())
+				}
 				if err != nil {
 					var reason string
 					if errors.Cause(err) == apis.ErrNoSuchMobile {
 						receiver.MarkContactTypeUnVerified(ctx, contactType, apis.ErrNoSuchMobile.Error())
 						reason = fmt.Sprintf("%q: no such mobile %s", contactType, receiver.Mobile)
+					} else if errors.Cause(err) == apis.ErrNoSuchEmail {
+						receiver.MarkContactTypeUnVerified(ctx, contactType, apis.ErrNoSuchEmail.Error())
+						reason = fmt.Sprintf("%q: no such email %s", contactType, receiver.Email)
 					} else if errors.Cause(err) == apis.ErrIncompleteConfig {
 						receiver.MarkContactTypeUnVerified(ctx, contactType, apis.ErrIncompleteConfig.Error())
 						reason = fmt.Sprintf("%q: %v", contactType, err)
@@ -141,7 +169,7 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 					ReceiverID:        receiver.Id,
 					Type:              contactType,
 					Contact:           content,
-					ParentContactType: "mobile",
+					ParentContactType: parentContactType,
 					Enabled:           tristate.True,
 				})
 				if err != nil {
@@ -150,6 +178,12 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 				}
 				receiver.SetContact(contactType, content)
 				receiver.MarkContactTypeVerified(ctx, contactType)
+			} else if utils.IsInStringArray(contactType, BindContactType) {
+				err = self.enableBindSubcontact(ctx, receiver, contactType, true)
+				if err != nil {
+					failedReasons = append(failedReasons, fmt.Sprintf("%q: %v", contactType, err))
+					continue
+				}
 			} else {
 				_, err := db.Update(receiver, func() error {
 					if contactType == apis.MOBILE {
@@ -194,6 +228,12 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 					failedReasons = append(failedReasons, err.Error())
 					continue
 				}
+			} else if utils.IsInStringArray(contactType, BindContactType) {
+				err = self.enableBindSubcontact(ctx, receiver, contactType, false)
+				if err != nil {
+					failedReasons = append(failedReasons, err.Error())
+					continue
+				}
 			} else {
 				_, err := db.Update(receiver, func() error {
 					if contactType == apis.MOBILE {
@@ -222,3 +262,24 @@ func (self *SubcontactPullTask) OnInit(ctx context.Context, obj db.IStandaloneMo
 	logclient.AddActionLogWithContext(ctx, receiver, logclient.ACT_PULL_SUBCONTACT, "", self.UserCred, true)
 	self.SetStageComplete(ctx, nil)
 }
+
+// 启用或禁用用户已经绑定的联系人，启用时若尚未绑定则需要先通过trigger-verify绑定
+func (self *SubcontactPullTask) enableBindSubcontact(ctx context.Context, receiver *models.SReceiver, contactType string, enabled bool) error {
+	subs, err := receiver.GetSubContacts()
+	if err != nil {
+		return errors.Wrap(err, "GetSubContacts")
+	}
+	for i := range subs {
+		if subs[i].Type != contactType {
+			continue
+		}
+		if enabled {
+			return subs[i].Enable()
+		}
+		return subs[i].Disable()
+	}
+	if enabled {
+		return receiver.MarkContactTypeUnVerified(ctx, contactType, "not bound yet, please trigger verify first")
+	}
+	return nil
+}
@@ -16,10 +16,14 @@ package handlers
 
 import (
 	"context"
+	"net/http"
 
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/s3cli"
 
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/s3gateway/models"
 )
@@ -32,6 +36,34 @@ func headBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	return nil
 }
 
+// createBucket creates the bucket in the cloud provider configured for the
+// gateway, AWS S3 replies 200 without body on success
+func createBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, r *http.Request, w http.ResponseWriter) error {
+	conf := s3cli.CreateBucketConfiguration{}
+	err := appsrv.FetchXml(r, &conf)
+	if err != nil {
+		return errors.Wrap(httperrors.ErrBadRequest, "FetchXml")
+	}
+	bucket, err := models.BucketManager.Create(ctx, userCred, bucketName, conf.Location)
+	if err != nil {
+		return errors.Wrap(err, "models.BucketManager.Create")
+	}
+	acl := r.Header.Get(http.CanonicalHeaderKey("x-amz-acl"))
+	if len(acl) > 0 {
+		iBucket, err := bucket.GetIBucket(ctx, userCred)
+		if err != nil {
+			return errors.Wrap(err, "bucket.GetIBucket")
+		}
+		err = iBucket.SetAcl(cloudprovider.TBucketACLType(acl))
+		if err != nil {
+			return errors.Wrap(err, "iBucket.SetAcl")
+		}
+	}
+	w.Header().Set("Location", "/"+bucketName)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
 func removeBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket string) error {
 	return models.BucketManager.DeleteByName(ctx, userCred, bucket)
 }
@@ -21,7 +21,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/minio/minio-go/pkg/s3utils"
+	"github.com/minio/minio-go/v6/pkg/s3utils"
 
 	"yunion.io/x/cloudmux/pkg/cloudprovider"
 	"yunion.io/x/jsonutils"
@@ -36,6 +36,7 @@ import (
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/s3gateway/models"
 	"yunion.io/x/onecloud/pkg/s3gateway/options"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3sign"
 )
 
 func InitHandlers(app *appsrv.Application) {
@@ -53,12 +54,17 @@ func InitHandlers(app *appsrv.Application) {
 
 func s3HandlerTimeoutInfo(info *appsrv.SHandlerInfo, r *http.Request) time.Duration {
 	o, _ := getObjectRequest(r)
+	rawQuery := s3sign.RemovePresignQuery(r.URL.RawQuery)
 	if len(o.Bucket) > 0 && len(o.Key) > 0 {
-		if r.Method == http.MethodGet && len(r.URL.RawQuery) == 0 {
+		if r.Method == http.MethodGet && len(rawQuery) == 0 {
 			return 2 * time.Hour
-		} else if r.Method == http.MethodPut && (len(r.URL.RawQuery) == 0 || strings.Contains(r.URL.RawQuery, "partNumber=")) {
+		} else if r.Method == http.MethodPut && (len(rawQuery) == 0 || strings.Contains(rawQuery, "partNumber=")) {
+			return 2 * time.Hour
+		} else if r.Method == http.MethodPost && strings.Contains(rawQuery, "select") {
 			return 2 * time.Hour
 		}
+	} else if isPostPolicyRequest(r, o) {
+		return 2 * time.Hour
 	}
 	return time.Duration(0)
 }
@@ -157,6 +163,9 @@ func readBucket(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	} else if query.Contains("acl") {
 		resp, err := bucketAcl(ctx, userCred, bucketName)
 		return resp, nil, err
+	} else if query.Contains("post-policy") {
+		resp, err := postPolicyForm(ctx, fetchObjectRequest(ctx), query, r)
+		return resp, nil, err
 	} else if query.Contains("analytics") {
 
 	} else if query.Contains("cors") {
@@ -227,6 +236,9 @@ func readObject(ctx context.Context, userCred mcclient.TokenCredential, bucketNa
 	if query.Contains("acl") {
 		resp, err := objectAcl(ctx, userCred, bucketName, objKey)
 		return resp, nil, err
+	} else if query.Contains("presign") {
+		resp, err := presignObject(ctx, fetchObjectRequest(ctx), query, r)
+		return resp, nil, err
 	} else if query.Contains("legal-hold") {
 
 	} else if query.Contains("retention") {
@@ -372,11 +384,6 @@ func postObject(ctx context.Context, userCred mcclient.TokenCredential, bucket s
 			return nil, nil, errors.Wrap(httperrors.ErrBadRequest, "FetchXml")
 		}
 		return completeMultipartUpload(ctx, userCred, r.Header, bucket, key, uploadId, &request)
-	} else if query.Contains("select") {
-		// select object
-		return selectObject(ctx, userCred, r.Header, bucket, key)
-	} else {
-		// upload object by form POST
 	}
 	return nil, nil, NotImplemented(ctx, "not implemented")
 }
@@ -389,7 +396,14 @@ func postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		// do nothing
 	} else if len(o.Bucket) > 0 && len(o.Key) == 0 {
 		// bucket post
-		// do nothing
+		if isPostPolicyRequest(r, o) {
+			// upload object by browser form POST
+			err := postObjectByForm(ctx, o, r, w)
+			if err != nil {
+				SendGeneralError(ctx, w, err)
+			}
+			return
+		}
 	} else {
 		// object post
 		query, err := jsonutils.ParseQueryString(r.URL.RawQuery)
@@ -397,6 +411,14 @@ func postHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 			SendError(ctx, w, BadRequest(ctx, err.Error()))
 			return
 		}
+		if query.Contains("select") {
+			// select object, the result is streamed
+			err := selectObject(ctx, userCred, o.Bucket, o.Key, r, w)
+			if err != nil {
+				SendGeneralError(ctx, w, err)
+			}
+			return
+		}
 		resp, respHdr, err := postObject(ctx, userCred, o.Bucket, o.Key, query, r)
 		if err != nil {
 			SendGeneralError(ctx, w, err)
@@ -446,8 +468,7 @@ func putBucket(ctx context.Context, userCred mcclient.TokenCredential, bucket st
 	} else if query.Contains("website") {
 
 	} else {
-		// create bucket
-		return nil, nil, NotSupported(ctx, "Not supported")
+		// create bucket, which has been handled
 	}
 	return nil, nil, NotImplemented(ctx, "not implemented")
 }
@@ -487,6 +508,14 @@ func putHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
 		// no bucket
 	} else if len(o.Bucket) > 0 && len(o.Key) == 0 {
 		// bucket put
+		if len(r.URL.RawQuery) == 0 {
+			// create bucket
+			err := createBucket(ctx, userCred, o.Bucket, r, w)
+			if err != nil {
+				SendGeneralError(ctx, w, err)
+			}
+			return
+		}
 		query, err := jsonutils.ParseQueryString(r.URL.RawQuery)
 		if err != nil {
 			SendError(ctx, w, BadRequest(ctx, err.Error()))
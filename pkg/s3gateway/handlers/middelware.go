@@ -17,17 +17,21 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/appctx"
 	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/s3auth"
 
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3sign"
 )
 
 const (
-	S3_OBJECT_REQUEST = appctx.AppContextKey("S3_OBJECT_REQUEST")
+	S3_OBJECT_REQUEST    = appctx.AppContextKey("S3_OBJECT_REQUEST")
+	S3_ACCESS_KEY_SECRET = appctx.AppContextKey("S3_ACCESS_KEY_SECRET")
 )
 
 func s3authenticate(f appsrv.FilterHandler) appsrv.FilterHandler {
@@ -40,12 +44,33 @@ func s3authenticate(f appsrv.FilterHandler) appsrv.FilterHandler {
 			return
 		}
 		ctx = context.WithValue(ctx, S3_OBJECT_REQUEST, o)
-		userCred, err := auth.VerifyRequest(*r, o.VirtualHost)
+		if isPostPolicyRequest(r, o) {
+			// authenticated by the policy in the form
+			f(ctx, w, r)
+			return
+		}
+		var aksk s3auth.IAccessKeySecretRequest
+		presigned := s3sign.IsPresignedRequest(r.URL.Query())
+		if presigned {
+			aksk, err = s3sign.DecodePresignedRequest(*r, time.Now())
+		} else {
+			aksk, err = s3auth.DecodeAccessKeyRequest(*r, o.VirtualHost)
+		}
+		if err != nil {
+			SendError(ctx, w, Unauthenticated(ctx, err.Error()))
+			return
+		}
+		cred, err := auth.VerifyAccessKeyRequest(*r, aksk)
 		if err != nil {
 			SendError(ctx, w, Unauthenticated(ctx, err.Error()))
 			return
 		}
-		ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_AUTH_TOKEN, userCred)
+		ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_AUTH_TOKEN, cred.Token)
+		if presigned {
+			r.URL.RawQuery = s3sign.RemovePresignQuery(r.URL.RawQuery)
+		} else {
+			ctx = context.WithValue(ctx, S3_ACCESS_KEY_SECRET, &cred.AccessKeySecret)
+		}
 
 		f(ctx, w, r)
 	}
@@ -0,0 +1,226 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6/pkg/s3utils"
+
+	"yunion.io/x/pkg/appctx"
+	"yunion.io/x/pkg/errors"
+
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/s3gateway/options"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3sign"
+)
+
+const (
+	// total size of the form fields before the file
+	MAX_POST_FORM_FIELDS_BYTES = 64 * 1024
+)
+
+// form fields saved as the headers of the object
+var postFormObjectHeaders = []string{
+	"cache-control",
+	"content-type",
+	"content-disposition",
+	"content-encoding",
+	"expires",
+	"x-amz-storage-class",
+}
+
+type SPostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// isPostPolicyRequest tells whether the request is a browser based upload,
+// which is authenticated by the signed policy in the form instead of the
+// Authorization header
+func isPostPolicyRequest(r *http.Request, o SObjectRequest) bool {
+	if r.Method != http.MethodPost || len(o.Bucket) == 0 || len(o.Key) > 0 || len(r.Header.Get("Authorization")) > 0 {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// readPostForm reads the form fields till the file, the names of the fields
+// are in lower case
+func readPostForm(r *http.Request) (map[string]string, *multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "MultipartReader")
+	}
+	fields := map[string]string{}
+	size := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, nil, errors.Error("missing file in form")
+		} else if err != nil {
+			return nil, nil, errors.Wrap(err, "NextPart")
+		}
+		name := strings.ToLower(part.FormName())
+		if name == s3sign.POST_FORM_FILE_FIELD {
+			return fields, part, nil
+		}
+		val, err := ioutil.ReadAll(io.LimitReader(part, int64(MAX_POST_FORM_FIELDS_BYTES-size+1)))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "read field %s", name)
+		}
+		size += len(val)
+		if size > MAX_POST_FORM_FIELDS_BYTES {
+			return nil, nil, errors.Errorf("form fields exceed %d bytes", MAX_POST_FORM_FIELDS_BYTES)
+		}
+		fields[name] = string(val)
+	}
+}
+
+// postObjectByForm uploads the object by the browser based POST form signed
+// by a SigV4 POST policy
+func postObjectByForm(ctx context.Context, o SObjectRequest, r *http.Request, w http.ResponseWriter) error {
+	fields, file, err := readPostForm(r)
+	if err != nil {
+		return BadRequest(ctx, err.Error())
+	}
+	aksk, err := s3sign.DecodePostPolicyRequest(fields)
+	if err != nil {
+		return Unauthenticated(ctx, err.Error())
+	}
+	cred, err := auth.VerifyAccessKeyRequest(*r, aksk)
+	if err != nil {
+		return Unauthenticated(ctx, err.Error())
+	}
+	userCred := cred.Token
+	ctx = context.WithValue(ctx, appctx.APP_CONTEXT_KEY_AUTH_TOKEN, userCred)
+
+	policy, err := s3sign.ParsePostPolicy(fields["policy"])
+	if err != nil {
+		return BadRequest(ctx, err.Error())
+	}
+	err = policy.Check(o.Bucket, fields, time.Now())
+	if err != nil {
+		return Forbidden(ctx, err.Error())
+	}
+	key := strings.Replace(fields["key"], s3sign.POST_FORM_FILENAME_VAR, file.FileName(), -1)
+	err = s3utils.CheckValidObjectName(key)
+	if err != nil {
+		return BadRequest(ctx, err.Error())
+	}
+
+	// the size of the file is unknown till it is read through, so spool it
+	// to a temporary file before uploading
+	maxSize := options.Options.PostObjectMaxSizeMb * 1024 * 1024
+	if policy.MaxLength >= 0 && policy.MaxLength < maxSize {
+		maxSize = policy.MaxLength
+	}
+	tmpFile, err := ioutil.TempFile("", "s3gateway-post-")
+	if err != nil {
+		return errors.Wrap(err, "ioutil.TempFile")
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+	size, err := io.Copy(tmpFile, io.LimitReader(file, maxSize+1))
+	if err != nil {
+		return errors.Wrap(err, "save form file")
+	}
+	if size > maxSize {
+		return errors.Wrapf(httperrors.ErrBadRequest, "file size exceeds %d bytes", maxSize)
+	}
+	err = policy.CheckContentLength(size)
+	if err != nil {
+		return BadRequest(ctx, err.Error())
+	}
+	_, err = tmpFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return errors.Wrap(err, "tmpFile.Seek")
+	}
+
+	hdr := http.Header{}
+	for k, v := range fields {
+		if strings.HasPrefix(k, "x-amz-meta-") {
+			hdr.Set(k, v)
+		}
+	}
+	for _, k := range postFormObjectHeaders {
+		if v, ok := fields[k]; ok {
+			hdr.Set(k, v)
+		}
+	}
+	if acl, ok := fields["acl"]; ok {
+		hdr.Set(http.CanonicalHeaderKey("x-amz-acl"), acl)
+	}
+	hdr.Set(http.CanonicalHeaderKey("Content-Length"), strconv.FormatInt(size, 10))
+	respHdr, err := uploadObject(ctx, userCred, o.Bucket, key, hdr, tmpFile, "", 0)
+	if err != nil {
+		return errors.Wrap(err, "uploadObject")
+	}
+
+	etag := respHdr.Get("ETag")
+	location := getRequestUrl(r, o, key).String()
+	if redirect := fields["success_action_redirect"]; len(redirect) > 0 {
+		u, err := url.Parse(redirect)
+		if err == nil {
+			query := u.Query()
+			query.Set("bucket", o.Bucket)
+			query.Set("key", key)
+			query.Set("etag", etag)
+			u.RawQuery = query.Encode()
+			w.Header().Set("ETag", etag)
+			http.Redirect(w, r, u.String(), http.StatusSeeOther)
+			return nil
+		}
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Location", location)
+	switch fields["success_action_status"] {
+	case "200":
+		w.WriteHeader(http.StatusOK)
+	case "201":
+		resp, err := xml.Marshal(&SPostResponse{
+			Location: location,
+			Bucket:   o.Bucket,
+			Key:      key,
+			ETag:     etag,
+		})
+		if err != nil {
+			return errors.Wrap(err, "xml.Marshal")
+		}
+		w.Header().Set("Content-Type", "application/xml;charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(xml.Header))
+		w.Write(resp)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
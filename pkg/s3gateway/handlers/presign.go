@@ -0,0 +1,172 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/identity"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3sign"
+)
+
+const (
+	DEFAULT_PRESIGN_EXPIRES = 3600
+)
+
+type SPresignedUrl struct {
+	XMLName xml.Name `xml:"PresignedUrl"`
+	Method  string   `xml:"Method"`
+	Url     string   `xml:"Url"`
+	Expires string   `xml:"Expires"`
+}
+
+type SPostPolicyFormField struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+type SPostPolicyForm struct {
+	XMLName xml.Name               `xml:"PostPolicyForm"`
+	Url     string                 `xml:"Url"`
+	Expires string                 `xml:"Expires"`
+	Fields  []SPostPolicyFormField `xml:"Field"`
+}
+
+// fetchAccessKeySecret returns the secret of the access key signing the
+// request, which is used to sign the presigned URLs and POST policies on
+// behalf of the user. It is not available for the requests authenticated by
+// presigned URLs, so that a presigned URL can not be extended by itself
+func fetchAccessKeySecret(ctx context.Context) (*api.SAccessKeySecretInfo, error) {
+	val := ctx.Value(S3_ACCESS_KEY_SECRET)
+	if gotypes.IsNil(val) {
+		return nil, errors.Wrap(httperrors.ErrForbidden, "presign requires a request signed by access key secret")
+	}
+	return val.(*api.SAccessKeySecretInfo), nil
+}
+
+func getRequestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// getRequestUrl returns the URL of the bucket or object in the same style,
+// virtual host or path, as the request
+func getRequestUrl(r *http.Request, o SObjectRequest, key string) *url.URL {
+	u := &url.URL{
+		Scheme: getRequestScheme(r),
+		Host:   r.Host,
+	}
+	if o.VirtualHost {
+		u.Path = "/" + key
+	} else {
+		u.Path = "/" + o.Bucket
+		if len(key) > 0 {
+			u.Path += "/" + key
+		}
+	}
+	return u
+}
+
+func fetchPresignExpires(query jsonutils.JSONObject) time.Duration {
+	expires, _ := query.Int("expires")
+	if expires <= 0 {
+		expires = DEFAULT_PRESIGN_EXPIRES
+	}
+	return time.Duration(expires) * time.Second
+}
+
+// presignObject generates the presigned URL of the object:
+//
+//	GET /<bucket>/<key>?presign&method=GET|PUT|HEAD&expires=<seconds>
+func presignObject(ctx context.Context, o SObjectRequest, query jsonutils.JSONObject, r *http.Request) (*SPresignedUrl, error) {
+	secret, err := fetchAccessKeySecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	method, _ := query.GetString("method")
+	method = strings.ToUpper(method)
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	if !utils.IsInStringArray(method, []string{http.MethodGet, http.MethodPut, http.MethodHead}) {
+		return nil, errors.Wrapf(httperrors.ErrBadRequest, "unsupported method %s", method)
+	}
+	expires := fetchPresignExpires(query)
+	urlStr, err := s3sign.PresignV4(method, getRequestUrl(r, o, o.Key), secret.AccessKey, secret.Secret, expires)
+	if err != nil {
+		return nil, errors.Wrap(httperrors.ErrBadRequest, err.Error())
+	}
+	return &SPresignedUrl{
+		Method:  method,
+		Url:     urlStr,
+		Expires: time.Now().Add(expires).UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// postPolicyForm generates the signed form fields for the browser based
+// uploads to the bucket:
+//
+//	GET /<bucket>?post-policy&key=<key>&key-starts-with=true&max-size=<bytes>&expires=<seconds>
+func postPolicyForm(ctx context.Context, o SObjectRequest, query jsonutils.JSONObject, r *http.Request) (*SPostPolicyForm, error) {
+	secret, err := fetchAccessKeySecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	input := s3sign.SPostPolicyInput{
+		Bucket:  o.Bucket,
+		Expires: fetchPresignExpires(query),
+	}
+	input.Key, _ = query.GetString("key")
+	input.KeyStartsWith = jsonutils.QueryBoolean(query, "key-starts-with", false)
+	if len(input.Key) == 0 && !input.KeyStartsWith {
+		return nil, errors.Wrap(httperrors.ErrBadRequest, "missing key")
+	}
+	input.MinLength, _ = query.Int("min-size")
+	input.MaxLength, _ = query.Int("max-size")
+	input.SuccessActionStatus, _ = query.GetString("success-action-status")
+	fields, err := s3sign.SignPostPolicy(input, secret.AccessKey, secret.Secret, time.Now())
+	if err != nil {
+		return nil, errors.Wrap(httperrors.ErrBadRequest, err.Error())
+	}
+	form := &SPostPolicyForm{
+		Url:     getRequestUrl(r, o, "").String(),
+		Expires: time.Now().Add(input.Expires).UTC().Format(time.RFC3339),
+	}
+	for _, k := range []string{
+		"key", "success_action_status", "policy",
+		"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-signature",
+	} {
+		if val, ok := fields[k]; ok {
+			form.Fields = append(form.Fields, SPostPolicyFormField{Name: k, Value: val})
+		}
+	}
+	return form, nil
+}
@@ -18,11 +18,50 @@ import (
 	"context"
 	"net/http"
 
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
 	"yunion.io/x/s3cli"
 
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/s3gateway/models"
+	"yunion.io/x/onecloud/pkg/s3gateway/s3select"
 )
 
-func selectObject(ctx context.Context, userCred mcclient.TokenCredential, hdr http.Header, bucket string, key string) (*s3cli.InitiateMultipartUploadResult, http.Header, error) {
-	return nil, nil, NotImplemented(ctx, "")
+// selectObject runs the S3 Select SQL over the object in the gateway, so that
+// it works for all the backend providers, the result is streamed to w in the
+// AWS event stream format
+func selectObject(ctx context.Context, userCred mcclient.TokenCredential, bucketName string, key string, r *http.Request, w http.ResponseWriter) error {
+	request := s3cli.SelectObjectOptions{}
+	err := appsrv.FetchXml(r, &request)
+	if err != nil {
+		return errors.Wrap(httperrors.ErrBadRequest, "FetchXml")
+	}
+	selector, err := s3select.NewSelector(&request)
+	if err != nil {
+		return errors.Wrap(httperrors.ErrBadRequest, err.Error())
+	}
+	bucket, err := models.BucketManager.GetByName(ctx, userCred, bucketName)
+	if err != nil {
+		return errors.Wrap(err, "models.BucketManager.GetByName")
+	}
+	iBucket, err := bucket.GetIBucket(ctx, userCred)
+	if err != nil {
+		return errors.Wrap(err, "bucket.GetIBucket")
+	}
+	stream, err := iBucket.GetObject(ctx, key, nil)
+	if err != nil {
+		return errors.Wrap(err, "iBucket.GetObject")
+	}
+	defer stream.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	err = selector.Run(stream, w)
+	if err != nil {
+		// the error has been sent to client in the event stream
+		log.Errorf("select object %s/%s: %s", bucketName, key, err)
+	}
+	return nil
 }
@@ -16,6 +16,7 @@ package models
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,9 +26,11 @@ import (
 	"yunion.io/x/pkg/gotypes"
 	"yunion.io/x/s3cli"
 
+	api "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/mcclient"
 	modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
+	"yunion.io/x/onecloud/pkg/s3gateway/options"
 	"yunion.io/x/onecloud/pkg/s3gateway/session"
 	"yunion.io/x/onecloud/pkg/util/hashcache"
 )
@@ -162,6 +165,88 @@ func (manager *SBucketManagerDelegate) DeleteByName(ctx context.Context, userCre
 	return nil
 }
 
+// Create creates the bucket in the cloud provider of DefaultBucketCloudprovider
+// through the region service, and waits until it is ready. The cloud region
+// is chosen by the location constraint of the request, or
+// DefaultBucketCloudregion if no location is specified
+func (manager *SBucketManagerDelegate) Create(ctx context.Context, userCred mcclient.TokenCredential, name string, location string) (*SBucketDelegate, error) {
+	if len(options.Options.DefaultBucketCloudprovider) == 0 {
+		return nil, errors.Wrap(httperrors.ErrNotSupported, "no cloud provider is configured for bucket creation")
+	}
+	s := session.GetSession(ctx, userCred)
+	regionId, err := manager.getCreateRegionId(s, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "getCreateRegionId")
+	}
+	input := api.BucketCreateInput{}
+	input.Name = name
+	input.CloudproviderId = options.Options.DefaultBucketCloudprovider
+	input.CloudregionId = regionId
+	result, err := modules.Buckets.Create(s, jsonutils.Marshal(input))
+	if err != nil {
+		return nil, errors.Wrap(err, "modules.Buckets.Create")
+	}
+	bucketId, _ := result.GetString("id")
+	bucket, err := manager.waitBucketReady(s, bucketId)
+	if err != nil {
+		return nil, errors.Wrap(err, "waitBucketReady")
+	}
+	manager.buckets.AtomicSet(bucket.Name, bucket)
+	return bucket, nil
+}
+
+func (manager *SBucketManagerDelegate) getCreateRegionId(s *mcclient.ClientSession, location string) (string, error) {
+	if len(location) == 0 && len(options.Options.DefaultBucketCloudregion) > 0 {
+		return options.Options.DefaultBucketCloudregion, nil
+	}
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString(options.Options.DefaultBucketCloudprovider), "cloudprovider_id")
+	params.Add(jsonutils.NewInt(1000), "limit")
+	result, err := modules.Cloudregions.List(s, params)
+	if err != nil {
+		return "", errors.Wrap(err, "modules.Cloudregions.List")
+	}
+	regionIds := []string{}
+	for i := range result.Data {
+		id, _ := result.Data[i].GetString("id")
+		extId, _ := result.Data[i].GetString("external_id")
+		if len(location) > 0 && (extId == location || strings.HasSuffix(extId, "/"+location)) {
+			return id, nil
+		}
+		regionIds = append(regionIds, id)
+	}
+	if len(location) > 0 {
+		return "", errors.Wrapf(httperrors.ErrBadRequest, "invalid location constraint %s", location)
+	}
+	if len(regionIds) != 1 {
+		return "", errors.Wrapf(httperrors.ErrBadRequest, "location constraint is required for %d regions", len(regionIds))
+	}
+	return regionIds[0], nil
+}
+
+func (manager *SBucketManagerDelegate) waitBucketReady(s *mcclient.ClientSession, id string) (*SBucketDelegate, error) {
+	timeout := time.Duration(options.Options.BucketCreateTimeoutSeconds) * time.Second
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(2 * time.Second) {
+		result, err := modules.Buckets.Get(s, id, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "modules.Buckets.Get")
+		}
+		status, _ := result.GetString("status")
+		switch status {
+		case api.BUCKET_STATUS_READY:
+			bucket := &SBucketDelegate{}
+			err = result.Unmarshal(bucket)
+			if err != nil {
+				return nil, errors.Wrap(err, "result.Unmarshal")
+			}
+			return bucket, nil
+		case api.BUCKET_STATUS_CREATE_FAIL:
+			return nil, errors.Wrapf(httperrors.ErrInvalidStatus, "bucket %s create failed", id)
+		}
+	}
+	return nil, errors.Wrapf(httperrors.ErrTimeout, "bucket %s is not ready in %s", id, timeout)
+}
+
 func (manager *SBucketManagerDelegate) Invalidate(name string) {
 	manager.buckets.AtomicRemove(name)
 }
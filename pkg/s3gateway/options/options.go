@@ -22,6 +22,12 @@ type SS3GatewayOptions struct {
 	common_options.CommonOptions
 
 	DomainName string `help:"s3 domain name"`
+
+	DefaultBucketCloudprovider string `help:"cloud provider (id or name) where the buckets created through the gateway are placed"`
+	DefaultBucketCloudregion   string `help:"cloud region (id or name) of the buckets created without location constraint"`
+	BucketCreateTimeoutSeconds int    `help:"seconds to wait for a new bucket to be ready" default:"120"`
+
+	PostObjectMaxSizeMb int64 `help:"max size of the file uploaded by browser form POST" default:"5120"`
 }
 
 var (
@@ -0,0 +1,368 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	castInt    = "INT"
+	castFloat  = "FLOAT"
+	castString = "STRING"
+	castBool   = "BOOL"
+)
+
+// sExpr evaluates to a value of nil, bool, int64, float64, string, or the
+// nested []interface{} and map[string]interface{} of JSON records
+type sExpr interface {
+	eval(rec IRecord) interface{}
+}
+
+type sLiteral struct {
+	val interface{}
+}
+
+func (e *sLiteral) eval(rec IRecord) interface{} {
+	return e.val
+}
+
+type sColumn struct {
+	path []string
+}
+
+func (e *sColumn) eval(rec IRecord) interface{} {
+	val, _ := rec.Get(e.path)
+	return val
+}
+
+type sLogical struct {
+	op    string
+	left  sExpr
+	right sExpr
+}
+
+func (e *sLogical) eval(rec IRecord) interface{} {
+	left := isTrue(e.left.eval(rec))
+	if e.op == "AND" {
+		return left && isTrue(e.right.eval(rec))
+	}
+	return left || isTrue(e.right.eval(rec))
+}
+
+type sNot struct {
+	expr sExpr
+}
+
+func (e *sNot) eval(rec IRecord) interface{} {
+	val := e.expr.eval(rec)
+	if val == nil {
+		return nil
+	}
+	return !isTrue(val)
+}
+
+type sCompare struct {
+	op    string
+	left  sExpr
+	right sExpr
+}
+
+func (e *sCompare) eval(rec IRecord) interface{} {
+	cmp, ok := compareValues(e.left.eval(rec), e.right.eval(rec))
+	if !ok {
+		// comparing with NULL or incompatible types
+		return nil
+	}
+	switch e.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return nil
+}
+
+type sIsNull struct {
+	expr sExpr
+	not  bool
+}
+
+func (e *sIsNull) eval(rec IRecord) interface{} {
+	isNull := e.expr.eval(rec) == nil
+	return isNull != e.not
+}
+
+type sLike struct {
+	expr    sExpr
+	pattern *regexp.Regexp
+}
+
+func (e *sLike) eval(rec IRecord) interface{} {
+	val := e.expr.eval(rec)
+	if val == nil {
+		return nil
+	}
+	return e.pattern.MatchString(toString(val))
+}
+
+type sIn struct {
+	expr sExpr
+	list []sExpr
+}
+
+func (e *sIn) eval(rec IRecord) interface{} {
+	val := e.expr.eval(rec)
+	if val == nil {
+		return nil
+	}
+	for _, item := range e.list {
+		if cmp, ok := compareValues(val, item.eval(rec)); ok && cmp == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+type sCast struct {
+	expr sExpr
+	typ  string
+}
+
+func (e *sCast) eval(rec IRecord) interface{} {
+	val := e.expr.eval(rec)
+	if val == nil {
+		return nil
+	}
+	switch e.typ {
+	case castInt:
+		if f, ok := toNumber(val); ok {
+			return int64(f)
+		}
+	case castFloat:
+		if f, ok := toNumber(val); ok {
+			return f
+		}
+	case castString:
+		return toString(val)
+	case castBool:
+		switch v := val.(type) {
+		case bool:
+			return v
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// sAggregate accumulates the values of the matched records, the result is
+// evaluated after all records are processed
+type sAggregate struct {
+	fn  string
+	arg sExpr
+
+	count int64
+	sum   float64
+	// min or max
+	extreme interface{}
+}
+
+func (e *sAggregate) accumulate(rec IRecord) {
+	if e.arg == nil {
+		e.count++
+		return
+	}
+	val := e.arg.eval(rec)
+	if val == nil {
+		return
+	}
+	switch e.fn {
+	case "COUNT":
+		e.count++
+	case "SUM", "AVG":
+		if f, ok := toNumber(val); ok {
+			e.sum += f
+			e.count++
+		}
+	case "MIN", "MAX":
+		if e.extreme == nil {
+			e.extreme = val
+		} else if cmp, ok := compareValues(val, e.extreme); ok && ((e.fn == "MIN" && cmp < 0) || (e.fn == "MAX" && cmp > 0)) {
+			e.extreme = val
+		}
+	}
+}
+
+func (e *sAggregate) eval(rec IRecord) interface{} {
+	switch e.fn {
+	case "COUNT":
+		return e.count
+	case "SUM":
+		if e.count == 0 {
+			return nil
+		}
+		return e.sum
+	case "AVG":
+		if e.count == 0 {
+			return nil
+		}
+		return e.sum / float64(e.count)
+	}
+	return e.extreme
+}
+
+// walkExpr calls fn on expr and all its sub expressions
+func walkExpr(expr sExpr, fn func(sExpr)) {
+	if expr == nil {
+		return
+	}
+	fn(expr)
+	switch e := expr.(type) {
+	case *sLogical:
+		walkExpr(e.left, fn)
+		walkExpr(e.right, fn)
+	case *sNot:
+		walkExpr(e.expr, fn)
+	case *sCompare:
+		walkExpr(e.left, fn)
+		walkExpr(e.right, fn)
+	case *sIsNull:
+		walkExpr(e.expr, fn)
+	case *sLike:
+		walkExpr(e.expr, fn)
+	case *sIn:
+		walkExpr(e.expr, fn)
+		for _, item := range e.list {
+			walkExpr(item, fn)
+		}
+	case *sCast:
+		walkExpr(e.expr, fn)
+	case *sAggregate:
+		walkExpr(e.arg, fn)
+	}
+}
+
+func hasAggregate(expr sExpr) bool {
+	found := false
+	walkExpr(expr, func(e sExpr) {
+		if _, ok := e.(*sAggregate); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+func isTrue(val interface{}) bool {
+	b, ok := val.(bool)
+	return ok && b
+}
+
+func toNumber(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+func isNumber(val interface{}) bool {
+	switch val.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func toString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(val)
+	return string(data)
+}
+
+// compareValues compares two values, numbers are compared numerically, and
+// strings from CSV records are converted to numbers when compared with numbers.
+// false is returned if either value is NULL or the types are not comparable.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if isNumber(a) || isNumber(b) {
+		fa, oka := toNumber(a)
+		fb, okb := toNumber(b)
+		if !oka || !okb {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if ba, ok := a.(bool); ok {
+		bb, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case ba == bb:
+			return 0, true
+		case !ba:
+			return -1, true
+		}
+		return 1, true
+	}
+	sa, oka := a.(string)
+	sb, okb := b.(string)
+	if !oka || !okb {
+		return 0, false
+	}
+	return strings.Compare(sa, sb), true
+}
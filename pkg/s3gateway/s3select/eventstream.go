@@ -0,0 +1,102 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"hash/crc32"
+
+	"yunion.io/x/s3cli"
+)
+
+// The response of SelectObjectContent is in the AWS event stream encoding,
+// each message is:
+//
+//	total length (4) | headers length (4) | prelude crc (4) | headers | payload | message crc (4)
+//
+// and each header is:
+//
+//	name length (1) | name | value type (1), 7 for string | value length (2) | value
+
+const eventHeaderValueTypeString = 7
+
+type sEventHeader struct {
+	name  string
+	value string
+}
+
+func encodeEventMessage(headers []sEventHeader, payload []byte) []byte {
+	var hdrBuf bytes.Buffer
+	for _, h := range headers {
+		hdrBuf.WriteByte(byte(len(h.name)))
+		hdrBuf.WriteString(h.name)
+		hdrBuf.WriteByte(eventHeaderValueTypeString)
+		binary.Write(&hdrBuf, binary.BigEndian, uint16(len(h.value)))
+		hdrBuf.WriteString(h.value)
+	}
+
+	totalLen := 4 + 4 + 4 + hdrBuf.Len() + len(payload) + 4
+	msg := make([]byte, totalLen)
+	binary.BigEndian.PutUint32(msg[0:4], uint32(totalLen))
+	binary.BigEndian.PutUint32(msg[4:8], uint32(hdrBuf.Len()))
+	binary.BigEndian.PutUint32(msg[8:12], crc32.ChecksumIEEE(msg[0:8]))
+	pos := 12 + copy(msg[12:], hdrBuf.Bytes())
+	pos += copy(msg[pos:], payload)
+	binary.BigEndian.PutUint32(msg[pos:], crc32.ChecksumIEEE(msg[:pos]))
+	return msg
+}
+
+func recordsMessage(payload []byte) []byte {
+	return encodeEventMessage([]sEventHeader{
+		{":event-type", "Records"},
+		{":content-type", "application/octet-stream"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+func statsMessage(stats s3cli.StatsMessage) []byte {
+	payload, _ := xml.Marshal(stats)
+	return encodeEventMessage([]sEventHeader{
+		{":event-type", "Stats"},
+		{":content-type", "text/xml"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+func progressMessage(stats s3cli.StatsMessage) []byte {
+	payload, _ := xml.Marshal(s3cli.ProgressMessage{StatsMessage: stats})
+	return encodeEventMessage([]sEventHeader{
+		{":event-type", "Progress"},
+		{":content-type", "text/xml"},
+		{":message-type", "event"},
+	}, payload)
+}
+
+func endMessage() []byte {
+	return encodeEventMessage([]sEventHeader{
+		{":event-type", "End"},
+		{":message-type", "event"},
+	}, nil)
+}
+
+func errorMessage(code, message string) []byte {
+	return encodeEventMessage([]sEventHeader{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}, nil)
+}
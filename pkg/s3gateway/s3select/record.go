@@ -0,0 +1,363 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/s3cli"
+)
+
+// IRecord is a row of the input object
+type IRecord interface {
+	// Get returns the value of the column path, false if the column doesn't exist
+	Get(path []string) (interface{}, bool)
+	// Fields returns all the columns of the record in order, for SELECT *
+	Fields() ([]string, []interface{})
+}
+
+type iRecordReader interface {
+	// Read returns io.EOF when there are no more records
+	Read() (IRecord, error)
+}
+
+type sCSVRecord struct {
+	header []string
+	values []string
+}
+
+func (r *sCSVRecord) Get(path []string) (interface{}, bool) {
+	if len(path) != 1 {
+		return nil, false
+	}
+	name := path[0]
+	if strings.HasPrefix(name, "_") {
+		if idx, err := strconv.Atoi(name[1:]); err == nil {
+			if idx < 1 || idx > len(r.values) {
+				return nil, false
+			}
+			return r.values[idx-1], true
+		}
+	}
+	for i := range r.header {
+		if r.header[i] == name && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	for i := range r.header {
+		if strings.EqualFold(r.header[i], name) && i < len(r.values) {
+			return r.values[i], true
+		}
+	}
+	return nil, false
+}
+
+func (r *sCSVRecord) Fields() ([]string, []interface{}) {
+	names := make([]string, len(r.values))
+	values := make([]interface{}, len(r.values))
+	for i := range r.values {
+		if i < len(r.header) {
+			names[i] = r.header[i]
+		} else {
+			names[i] = fmt.Sprintf("_%d", i+1)
+		}
+		values[i] = r.values[i]
+	}
+	return names, values
+}
+
+type sCSVReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(input io.Reader, opts *s3cli.CSVInputOptions) (*sCSVReader, error) {
+	if opts.QuoteCharacter != "" && opts.QuoteCharacter != `"` {
+		return nil, errors.Errorf("unsupported QuoteCharacter %q", opts.QuoteCharacter)
+	}
+	switch opts.RecordDelimiter {
+	case "", "\n", "\r\n":
+	default:
+		return nil, errors.Errorf("unsupported RecordDelimiter %q", opts.RecordDelimiter)
+	}
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = false
+	if opts.FieldDelimiter != "" {
+		r, size := utf8.DecodeRuneInString(opts.FieldDelimiter)
+		if size != len(opts.FieldDelimiter) {
+			return nil, errors.Errorf("FieldDelimiter %q must be a single character", opts.FieldDelimiter)
+		}
+		reader.Comma = r
+	}
+	if opts.Comments != "" {
+		r, size := utf8.DecodeRuneInString(opts.Comments)
+		if size != len(opts.Comments) {
+			return nil, errors.Errorf("Comments %q must be a single character", opts.Comments)
+		}
+		reader.Comment = r
+	}
+	r := &sCSVReader{reader: reader}
+	switch strings.ToUpper(string(opts.FileHeaderInfo)) {
+	case s3cli.CSVFileHeaderInfoUse, s3cli.CSVFileHeaderInfoIgnore:
+		header, err := reader.Read()
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "read CSV header")
+		}
+		if strings.EqualFold(string(opts.FileHeaderInfo), s3cli.CSVFileHeaderInfoUse) {
+			r.header = header
+		}
+	case "", string(s3cli.CSVFileHeaderInfoNone):
+	default:
+		return nil, errors.Errorf("invalid FileHeaderInfo %s", opts.FileHeaderInfo)
+	}
+	return r, nil
+}
+
+func (r *sCSVReader) Read() (IRecord, error) {
+	values, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return &sCSVRecord{header: r.header, values: values}, nil
+}
+
+type sJSONRecord struct {
+	raw    json.RawMessage
+	object map[string]interface{}
+}
+
+func (r *sJSONRecord) Get(path []string) (interface{}, bool) {
+	var val interface{} = r.object
+	for _, key := range path {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		val, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+func (r *sJSONRecord) Fields() ([]string, []interface{}) {
+	names := make([]string, 0, len(r.object))
+	for k := range r.object {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i := range names {
+		values[i] = r.object[names[i]]
+	}
+	return names, values
+}
+
+// sJSONReader reads the JSON values one by one, which works for both
+// JSON Lines and a document of concatenated values. The elements of a
+// top level array are read as records.
+type sJSONReader struct {
+	decoder *json.Decoder
+	pending []json.RawMessage
+}
+
+func newJSONReader(input io.Reader, opts *s3cli.JSONInputOptions) (*sJSONReader, error) {
+	switch strings.ToUpper(string(opts.Type)) {
+	case "", string(s3cli.JSONDocumentType), s3cli.JSONLinesType:
+	default:
+		return nil, errors.Errorf("invalid JSON Type %s", opts.Type)
+	}
+	return &sJSONReader{decoder: json.NewDecoder(input)}, nil
+}
+
+func (r *sJSONReader) Read() (IRecord, error) {
+	for len(r.pending) == 0 {
+		raw := json.RawMessage{}
+		err := r.decoder.Decode(&raw)
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, errors.Wrap(err, "decode JSON")
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '[' {
+			err := json.Unmarshal(raw, &r.pending)
+			if err != nil {
+				return nil, errors.Wrap(err, "decode JSON array")
+			}
+		} else {
+			r.pending = append(r.pending, raw)
+		}
+	}
+	raw := r.pending[0]
+	r.pending = r.pending[1:]
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var val interface{}
+	if err := decoder.Decode(&val); err != nil {
+		return nil, errors.Wrap(err, "decode JSON record")
+	}
+	rec := &sJSONRecord{raw: raw}
+	if obj, ok := normalizeJSONValue(val).(map[string]interface{}); ok {
+		rec.object = obj
+	} else {
+		// scalar values are accessed by _1
+		rec.object = map[string]interface{}{"_1": normalizeJSONValue(val)}
+		rec.raw = nil
+	}
+	return rec, nil
+}
+
+// normalizeJSONValue converts json.Number to int64 or float64
+func normalizeJSONValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeJSONValue(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeJSONValue(v[i])
+		}
+	}
+	return val
+}
+
+type iRecordWriter interface {
+	// raw is the original JSON record for SELECT * of JSON input, may be nil
+	Write(buf *bytes.Buffer, names []string, values []interface{}, raw json.RawMessage) error
+}
+
+type sCSVWriter struct {
+	fieldDelimiter  string
+	recordDelimiter string
+	quote           string
+	quoteEscape     string
+	quoteAlways     bool
+}
+
+func newCSVWriter(opts *s3cli.CSVOutputOptions) *sCSVWriter {
+	w := &sCSVWriter{
+		fieldDelimiter:  opts.FieldDelimiter,
+		recordDelimiter: opts.RecordDelimiter,
+		quote:           opts.QuoteCharacter,
+		quoteEscape:     opts.QuoteEscapeCharacter,
+		quoteAlways:     strings.EqualFold(string(opts.QuoteFields), string(s3cli.CSVQuoteFieldsAlways)),
+	}
+	if w.fieldDelimiter == "" {
+		w.fieldDelimiter = ","
+	}
+	if w.recordDelimiter == "" {
+		w.recordDelimiter = "\n"
+	}
+	if w.quote == "" {
+		w.quote = `"`
+	}
+	if w.quoteEscape == "" {
+		w.quoteEscape = w.quote
+	}
+	return w
+}
+
+func (w *sCSVWriter) Write(buf *bytes.Buffer, names []string, values []interface{}, raw json.RawMessage) error {
+	for i, val := range values {
+		if i > 0 {
+			buf.WriteString(w.fieldDelimiter)
+		}
+		str := toString(val)
+		if w.quoteAlways || strings.Contains(str, w.fieldDelimiter) || strings.Contains(str, w.quote) ||
+			strings.ContainsAny(str, "\r\n") || strings.Contains(str, w.recordDelimiter) {
+			buf.WriteString(w.quote)
+			buf.WriteString(strings.ReplaceAll(str, w.quote, w.quoteEscape+w.quote))
+			buf.WriteString(w.quote)
+		} else {
+			buf.WriteString(str)
+		}
+	}
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
+
+type sJSONWriter struct {
+	recordDelimiter string
+}
+
+func newJSONWriter(opts *s3cli.JSONOutputOptions) *sJSONWriter {
+	w := &sJSONWriter{recordDelimiter: opts.RecordDelimiter}
+	if w.recordDelimiter == "" {
+		w.recordDelimiter = "\n"
+	}
+	return w
+}
+
+func (w *sJSONWriter) Write(buf *bytes.Buffer, names []string, values []interface{}, raw json.RawMessage) error {
+	if raw != nil {
+		if err := json.Compact(buf, raw); err != nil {
+			return errors.Wrap(err, "json.Compact")
+		}
+		buf.WriteString(w.recordDelimiter)
+		return nil
+	}
+	// keep the order of the projections
+	buf.WriteByte('{')
+	for i := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(names[i])
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(values[i])
+		if err != nil {
+			return errors.Wrapf(err, "marshal %s", names[i])
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	buf.WriteString(w.recordDelimiter)
+	return nil
+}
+
+// sCountingReader counts the bytes read through it
+type sCountingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *sCountingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
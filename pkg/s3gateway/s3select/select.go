@@ -0,0 +1,232 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/s3cli"
+)
+
+const (
+	// flush the records to client when the buffered output exceeds this size
+	recordsFlushSize = 64 * 1024
+)
+
+// SSelector runs a SelectObjectContent request against an object stream
+type SSelector struct {
+	req   *s3cli.SelectObjectOptions
+	query *sQuery
+}
+
+// NewSelector validates the request and parses the SQL expression, the errors
+// are returned before any response is sent
+func NewSelector(req *s3cli.SelectObjectOptions) (*SSelector, error) {
+	if req.ExpressionType != "" && !strings.EqualFold(string(req.ExpressionType), string(s3cli.QueryExpressionTypeSQL)) {
+		return nil, errors.Errorf("unsupported ExpressionType %s", req.ExpressionType)
+	}
+	input := req.InputSerialization
+	if input.Parquet != nil {
+		return nil, errors.Errorf("Parquet input is not supported")
+	}
+	if (input.CSV == nil) == (input.JSON == nil) {
+		return nil, errors.Errorf("exactly one of CSV and JSON input serialization is required")
+	}
+	switch strings.ToUpper(string(input.CompressionType)) {
+	case "", string(s3cli.SelectCompressionNONE), s3cli.SelectCompressionGZIP, s3cli.SelectCompressionBZIP:
+	default:
+		return nil, errors.Errorf("unsupported CompressionType %s", input.CompressionType)
+	}
+	output := req.OutputSerialization
+	if (output.CSV == nil) == (output.JSON == nil) {
+		return nil, errors.Errorf("exactly one of CSV and JSON output serialization is required")
+	}
+	query, err := parseQuery(req.Expression)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse expression")
+	}
+	return &SSelector{req: req, query: query}, nil
+}
+
+// newRecordReader returns the record reader of the input, and the counter of
+// the uncompressed bytes
+func (s *SSelector) newRecordReader(input io.Reader) (iRecordReader, *sCountingReader, error) {
+	switch strings.ToUpper(string(s.req.InputSerialization.CompressionType)) {
+	case s3cli.SelectCompressionGZIP:
+		gz, err := gzip.NewReader(input)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "gzip.NewReader")
+		}
+		input = gz
+	case s3cli.SelectCompressionBZIP:
+		input = bzip2.NewReader(input)
+	}
+	processed := &sCountingReader{reader: input}
+	var reader iRecordReader
+	var err error
+	if s.req.InputSerialization.CSV != nil {
+		reader, err = newCSVReader(processed, s.req.InputSerialization.CSV)
+	} else {
+		reader, err = newJSONReader(processed, s.req.InputSerialization.JSON)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, processed, nil
+}
+
+func (s *SSelector) newRecordWriter() iRecordWriter {
+	if s.req.OutputSerialization.CSV != nil {
+		return newCSVWriter(s.req.OutputSerialization.CSV)
+	}
+	return newJSONWriter(s.req.OutputSerialization.JSON)
+}
+
+type sEventWriter struct {
+	w     io.Writer
+	err   error
+	stats s3cli.StatsMessage
+}
+
+func (w *sEventWriter) write(msg []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(msg)
+	if f, ok := w.w.(http.Flusher); ok && w.err == nil {
+		f.Flush()
+	}
+}
+
+// Run reads the records from the object stream and writes the matched records
+// to w in event stream messages, ended by the Stats and End messages. If an
+// error occurs during processing, an error message is sent instead and the
+// error is returned.
+func (s *SSelector) Run(object io.Reader, w io.Writer) error {
+	scanned := &sCountingReader{reader: object}
+	ew := &sEventWriter{w: w}
+	err := s.run(scanned, ew)
+	if err == nil {
+		err = ew.err
+	}
+	if err != nil {
+		ew.write(errorMessage("InternalError", err.Error()))
+		return err
+	}
+	ew.write(statsMessage(ew.stats))
+	ew.write(endMessage())
+	return ew.err
+}
+
+func (s *SSelector) run(scanned *sCountingReader, ew *sEventWriter) error {
+	reader, processed, err := s.newRecordReader(scanned)
+	if err != nil {
+		return err
+	}
+	writer := s.newRecordWriter()
+
+	var buf bytes.Buffer
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		ew.stats.BytesReturned += int64(buf.Len())
+		ew.write(recordsMessage(buf.Bytes()))
+		buf.Reset()
+		ew.stats.BytesScanned = scanned.count
+		ew.stats.BytesProcessed = processed.count
+		if s.req.RequestProgress.Enabled {
+			ew.write(progressMessage(ew.stats))
+		}
+	}
+
+	var returned int64
+	for s.query.limit < 0 || returned < s.query.limit || s.query.aggregate {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "read record")
+		}
+		if s.query.where != nil && !isTrue(s.query.where.eval(rec)) {
+			continue
+		}
+		if s.query.aggregate {
+			for _, proj := range s.query.projections {
+				walkExpr(proj.expr, func(e sExpr) {
+					if agg, ok := e.(*sAggregate); ok {
+						agg.accumulate(rec)
+					}
+				})
+			}
+			continue
+		}
+		if err := s.writeRecord(&buf, writer, rec); err != nil {
+			return err
+		}
+		returned++
+		if buf.Len() >= recordsFlushSize {
+			flush()
+			if ew.err != nil {
+				return ew.err
+			}
+		}
+	}
+	if s.query.aggregate {
+		if err := s.writeRecord(&buf, writer, sEmptyRecord{}); err != nil {
+			return err
+		}
+	}
+	flush()
+	ew.stats.BytesScanned = scanned.count
+	ew.stats.BytesProcessed = processed.count
+	return nil
+}
+
+func (s *SSelector) writeRecord(buf *bytes.Buffer, writer iRecordWriter, rec IRecord) error {
+	if s.query.projections == nil {
+		names, values := rec.Fields()
+		var raw []byte
+		if jrec, ok := rec.(*sJSONRecord); ok {
+			raw = jrec.raw
+		}
+		return writer.Write(buf, names, values, raw)
+	}
+	names := make([]string, len(s.query.projections))
+	values := make([]interface{}, len(s.query.projections))
+	for i, proj := range s.query.projections {
+		names[i] = proj.name
+		values[i] = proj.expr.eval(rec)
+	}
+	return writer.Write(buf, names, values, nil)
+}
+
+// sEmptyRecord is used to evaluate the aggregate projections after all records are processed
+type sEmptyRecord struct{}
+
+func (r sEmptyRecord) Get(path []string) (interface{}, bool) {
+	return nil, false
+}
+
+func (r sEmptyRecord) Fields() ([]string, []interface{}) {
+	return nil, nil
+}
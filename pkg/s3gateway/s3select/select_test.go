@@ -0,0 +1,179 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"yunion.io/x/s3cli"
+)
+
+const testCSV = `name,age,city
+alice,30,Beijing
+bob,25,Shanghai
+carol,41,"Beijing, Haidian"
+dave,,Shenzhen
+`
+
+const testJSONLines = `{"name":"alice","age":30,"addr":{"city":"Beijing"}}
+{"name":"bob","age":25,"addr":{"city":"Shanghai"}}
+{"name":"carol","age":41.5,"addr":{"city":"Beijing"}}
+`
+
+// decodeEvents decodes the event stream, and returns the concatenated records
+// payload and the event types
+func decodeEvents(t *testing.T, data []byte) (string, []string) {
+	var records bytes.Buffer
+	events := []string{}
+	for len(data) > 0 {
+		totalLen := binary.BigEndian.Uint32(data[0:4])
+		hdrLen := binary.BigEndian.Uint32(data[4:8])
+		if crc32.ChecksumIEEE(data[0:8]) != binary.BigEndian.Uint32(data[8:12]) {
+			t.Fatalf("invalid prelude crc")
+		}
+		msg := data[:totalLen]
+		if crc32.ChecksumIEEE(msg[:totalLen-4]) != binary.BigEndian.Uint32(msg[totalLen-4:]) {
+			t.Fatalf("invalid message crc")
+		}
+		headers := map[string]string{}
+		hdr := msg[12 : 12+hdrLen]
+		for len(hdr) > 0 {
+			nameLen := int(hdr[0])
+			name := string(hdr[1 : 1+nameLen])
+			valLen := int(binary.BigEndian.Uint16(hdr[2+nameLen : 4+nameLen]))
+			headers[name] = string(hdr[4+nameLen : 4+nameLen+valLen])
+			hdr = hdr[4+nameLen+valLen:]
+		}
+		if headers[":message-type"] == "error" {
+			events = append(events, "error:"+headers[":error-message"])
+		} else {
+			events = append(events, headers[":event-type"])
+		}
+		if headers[":event-type"] == "Records" {
+			records.Write(msg[12+hdrLen : totalLen-4])
+		}
+		data = data[totalLen:]
+	}
+	return records.String(), events
+}
+
+func runSelect(t *testing.T, req *s3cli.SelectObjectOptions, input []byte) string {
+	selector, err := NewSelector(req)
+	if err != nil {
+		t.Fatalf("NewSelector %q: %s", req.Expression, err)
+	}
+	var out bytes.Buffer
+	if err := selector.Run(bytes.NewReader(input), &out); err != nil {
+		t.Fatalf("Run %q: %s", req.Expression, err)
+	}
+	records, events := decodeEvents(t, out.Bytes())
+	if len(events) < 2 || events[len(events)-2] != "Stats" || events[len(events)-1] != "End" {
+		t.Fatalf("unexpected events %v", events)
+	}
+	return records
+}
+
+func csvRequest(expr string) *s3cli.SelectObjectOptions {
+	req := &s3cli.SelectObjectOptions{Expression: expr, ExpressionType: s3cli.QueryExpressionTypeSQL}
+	req.InputSerialization.CSV = &s3cli.CSVInputOptions{FileHeaderInfo: s3cli.CSVFileHeaderInfoUse}
+	req.OutputSerialization.CSV = &s3cli.CSVOutputOptions{}
+	return req
+}
+
+func TestSelectCSV(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"SELECT * FROM S3Object", "alice,30,Beijing\nbob,25,Shanghai\ncarol,41,\"Beijing, Haidian\"\ndave,,Shenzhen\n"},
+		{"select s.name, s._2 from s3object s where s.age > 26", "alice,30\ncarol,41\n"},
+		{"SELECT name FROM S3Object WHERE CAST(age AS INT) BETWEEN 25 AND 30 LIMIT 1", "alice\n"},
+		{"SELECT name FROM S3Object s WHERE s.city LIKE 'Beijing%' AND NOT s.name = 'alice'", "carol\n"},
+		{"SELECT name FROM S3Object WHERE name IN ('bob', 'dave') OR age >= 41", "bob\ncarol\ndave\n"},
+		{"SELECT name FROM S3Object WHERE age = ''", "dave\n"},
+		{"SELECT COUNT(*), MAX(CAST(age AS INT)), AVG(age) FROM S3Object WHERE city <> 'Shenzhen'", "3,41,32\n"},
+		{"SELECT name FROM S3Object LIMIT 0", ""},
+	}
+	for _, c := range cases {
+		got := runSelect(t, csvRequest(c.expr), []byte(testCSV))
+		if got != c.want {
+			t.Errorf("%s: want %q, got %q", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestSelectJSON(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(testJSONLines))
+	zw.Close()
+
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{"SELECT * FROM S3Object s WHERE s.addr.city = 'Shanghai'", `{"name":"bob","age":25,"addr":{"city":"Shanghai"}}` + "\n"},
+		{"SELECT s.name AS n, s.addr.city FROM S3Object s WHERE s.age > 26", `{"n":"alice","city":"Beijing"}` + "\n" + `{"n":"carol","city":"Beijing"}` + "\n"},
+		{"SELECT SUM(s.age) total FROM S3Object s", `{"total":96.5}` + "\n"},
+		{"SELECT s.name FROM S3Object s WHERE s.phone IS NULL LIMIT 2", `{"name":"alice"}` + "\n" + `{"name":"bob"}` + "\n"},
+	}
+	for _, c := range cases {
+		req := &s3cli.SelectObjectOptions{Expression: c.expr}
+		req.InputSerialization.CompressionType = s3cli.SelectCompressionGZIP
+		req.InputSerialization.JSON = &s3cli.JSONInputOptions{Type: s3cli.JSONLinesType}
+		req.OutputSerialization.JSON = &s3cli.JSONOutputOptions{}
+		got := runSelect(t, req, gz.Bytes())
+		if got != c.want {
+			t.Errorf("%s: want %q, got %q", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestParseQueryError(t *testing.T) {
+	for _, expr := range []string{
+		"SELECT * FROM table",
+		"SELECT name, COUNT(*) FROM S3Object",
+		"SELECT * FROM S3Object WHERE COUNT(*) > 1",
+		"SELECT * FROM S3Object LIMIT x",
+		"SELECT * FROM S3Object WHERE name = 'abc",
+		"SELECT name FROM S3Object WHERE",
+	} {
+		if _, err := parseQuery(expr); err == nil {
+			t.Errorf("expect error for %q", expr)
+		}
+	}
+}
+
+func TestSelectInvalidInput(t *testing.T) {
+	req := csvRequest("SELECT * FROM S3Object")
+	req.InputSerialization.CompressionType = s3cli.SelectCompressionGZIP
+	selector, err := NewSelector(req)
+	if err != nil {
+		t.Fatalf("NewSelector: %s", err)
+	}
+	var out bytes.Buffer
+	if err := selector.Run(strings.NewReader(testCSV), &out); err == nil {
+		t.Fatalf("expect error of invalid gzip input")
+	}
+	_, events := decodeEvents(t, out.Bytes())
+	if len(events) != 1 || !strings.HasPrefix(events[0], "error:") {
+		t.Errorf("expect error event, got %v", events)
+	}
+}
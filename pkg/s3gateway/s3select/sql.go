@@ -0,0 +1,608 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3select
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"yunion.io/x/pkg/errors"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	// double quoted identifier, never a keyword
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type sToken struct {
+	typ tokenType
+	val string
+	pos int
+}
+
+func (t sToken) String() string {
+	if t.typ == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at %d", t.val, t.pos)
+}
+
+func (t sToken) isKeyword(kw string) bool {
+	return t.typ == tokenIdent && strings.EqualFold(t.val, kw)
+}
+
+func (t sToken) isOp(op string) bool {
+	return t.typ == tokenOp && t.val == op
+}
+
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "IN": true, "IS": true,
+	"NULL": true, "TRUE": true, "FALSE": true, "BETWEEN": true, "CAST": true,
+}
+
+func tokenize(sql string) ([]sToken, error) {
+	tokens := []sToken{}
+	runes := []rune(sql)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'':
+			// string literal, '' is an escaped quote
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes); j++ {
+				if runes[j] == '\'' {
+					if j+1 < len(runes) && runes[j+1] == '\'' {
+						sb.WriteRune('\'')
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, sToken{typ: tokenString, val: sb.String(), pos: i})
+			i = j + 1
+		case c == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+			}
+			if j >= len(runes) {
+				return nil, errors.Errorf("unterminated quoted identifier at %d", i)
+			}
+			tokens = append(tokens, sToken{typ: tokenQuotedIdent, val: string(runes[i+1 : j]), pos: i})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for ; j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E'); j++ {
+			}
+			tokens = append(tokens, sToken{typ: tokenNumber, val: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for ; j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_'); j++ {
+			}
+			tokens = append(tokens, sToken{typ: tokenIdent, val: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "<=", ">=", "<>", "!=":
+					op = two
+				}
+			}
+			if !strings.Contains("*,().=<>!-[]", op[:1]) || op == "!" {
+				return nil, errors.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, sToken{typ: tokenOp, val: op, pos: i})
+			i += len([]rune(op))
+		}
+	}
+	tokens = append(tokens, sToken{typ: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+type sProjection struct {
+	expr sExpr
+	name string
+}
+
+// sQuery is a parsed S3 Select statement:
+//
+//	SELECT * | expr [[AS] alias], ... FROM S3Object[[*]] [[AS] alias] [WHERE cond] [LIMIT n]
+type sQuery struct {
+	// nil means SELECT *
+	projections []sProjection
+	where       sExpr
+	// -1 means no limit
+	limit int64
+	// whether projections are aggregate functions
+	aggregate bool
+}
+
+type sParser struct {
+	tokens []sToken
+	pos    int
+	alias  string
+}
+
+func (p *sParser) peek() sToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sParser) next() sToken {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sParser) expectKeyword(kw string) error {
+	if t := p.next(); !t.isKeyword(kw) {
+		return errors.Errorf("expect %s, got %s", kw, t)
+	}
+	return nil
+}
+
+func (p *sParser) expectOp(op string) error {
+	if t := p.next(); !t.isOp(op) {
+		return errors.Errorf("expect %q, got %s", op, t)
+	}
+	return nil
+}
+
+func (p *sParser) isIdent(t sToken) bool {
+	return t.typ == tokenQuotedIdent || (t.typ == tokenIdent && !sqlKeywords[strings.ToUpper(t.val)])
+}
+
+func parseQuery(sql string) (*sQuery, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &sParser{tokens: tokens}
+	// the table alias is needed to resolve the column references in projections,
+	// so parse the FROM clause first
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	projStart := p.pos
+	for ; p.pos < len(p.tokens) && !p.peek().isKeyword("FROM"); p.pos++ {
+		if p.peek().typ == tokenEOF {
+			return nil, errors.Errorf("missing FROM clause")
+		}
+	}
+	p.next()
+	if err := p.parseFrom(); err != nil {
+		return nil, err
+	}
+	tailStart := p.pos
+
+	q := &sQuery{limit: -1}
+	p.pos = projStart
+	if p.peek().isOp("*") {
+		p.next()
+	} else {
+		for {
+			proj, err := p.parseProjection(len(q.projections))
+			if err != nil {
+				return nil, err
+			}
+			q.projections = append(q.projections, proj)
+			if !p.peek().isOp(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if !p.peek().isKeyword("FROM") {
+		return nil, errors.Errorf("unexpected %s in projections", p.peek())
+	}
+
+	p.pos = tailStart
+	if p.peek().isKeyword("WHERE") {
+		p.next()
+		q.where, err = p.parseExpr()
+		if err != nil {
+			return nil, errors.Wrap(err, "WHERE")
+		}
+		if hasAggregate(q.where) {
+			return nil, errors.Errorf("aggregate functions are not allowed in WHERE clause")
+		}
+	}
+	if p.peek().isKeyword("LIMIT") {
+		p.next()
+		t := p.next()
+		if t.typ != tokenNumber {
+			return nil, errors.Errorf("expect number after LIMIT, got %s", t)
+		}
+		q.limit, err = strconv.ParseInt(t.val, 10, 64)
+		if err != nil || q.limit < 0 {
+			return nil, errors.Errorf("invalid LIMIT %s", t.val)
+		}
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, errors.Errorf("unexpected %s", t)
+	}
+
+	for i, proj := range q.projections {
+		isAgg := hasAggregate(proj.expr)
+		if i == 0 {
+			q.aggregate = isAgg
+		} else if isAgg != q.aggregate {
+			return nil, errors.Errorf("aggregate and non-aggregate projections can't be mixed")
+		}
+	}
+	return q, nil
+}
+
+func (p *sParser) parseFrom() error {
+	t := p.next()
+	if t.typ != tokenIdent || !strings.EqualFold(t.val, "S3Object") {
+		return errors.Errorf("expect S3Object after FROM, got %s", t)
+	}
+	if p.peek().isOp("[") {
+		p.next()
+		if err := p.expectOp("*"); err != nil {
+			return err
+		}
+		if err := p.expectOp("]"); err != nil {
+			return err
+		}
+	}
+	if p.peek().isKeyword("AS") {
+		p.next()
+		if !p.isIdent(p.peek()) {
+			return errors.Errorf("expect alias after AS, got %s", p.peek())
+		}
+	}
+	if p.isIdent(p.peek()) {
+		p.alias = p.next().val
+	}
+	return nil
+}
+
+func (p *sParser) parseProjection(idx int) (sProjection, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return sProjection{}, err
+	}
+	proj := sProjection{expr: expr}
+	if p.peek().isKeyword("AS") {
+		p.next()
+		if !p.isIdent(p.peek()) {
+			return proj, errors.Errorf("expect alias after AS, got %s", p.peek())
+		}
+		proj.name = p.next().val
+	} else if p.isIdent(p.peek()) {
+		proj.name = p.next().val
+	} else if col, ok := expr.(*sColumn); ok {
+		proj.name = col.path[len(col.path)-1]
+	} else {
+		proj.name = fmt.Sprintf("_%d", idx+1)
+	}
+	return proj, nil
+}
+
+func (p *sParser) parseExpr() (sExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &sLogical{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseAnd() (sExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &sLogical{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *sParser) parseNot() (sExpr, error) {
+	if p.peek().isKeyword("NOT") {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &sNot{expr: expr}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *sParser) parsePredicate() (sExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.typ == tokenOp {
+		switch t.val {
+		case "=", "!=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			op := t.val
+			if op == "<>" {
+				op = "!="
+			}
+			return &sCompare{op: op, left: left, right: right}, nil
+		}
+		return left, nil
+	}
+	if t.isKeyword("IS") {
+		p.next()
+		not := false
+		if p.peek().isKeyword("NOT") {
+			p.next()
+			not = true
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return &sIsNull{expr: left, not: not}, nil
+	}
+	not := false
+	if t.isKeyword("NOT") {
+		p.next()
+		not = true
+		t = p.peek()
+	}
+	var expr sExpr
+	switch {
+	case t.isKeyword("LIKE"):
+		p.next()
+		pt := p.next()
+		if pt.typ != tokenString {
+			return nil, errors.Errorf("expect string pattern after LIKE, got %s", pt)
+		}
+		expr = &sLike{expr: left, pattern: likeToRegexp(pt.val)}
+	case t.isKeyword("IN"):
+		p.next()
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		in := &sIn{expr: left}
+		for {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, item)
+			if !p.peek().isOp(",") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		expr = in
+	case t.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		expr = &sLogical{
+			op:    "AND",
+			left:  &sCompare{op: ">=", left: left, right: low},
+			right: &sCompare{op: "<=", left: left, right: high},
+		}
+	default:
+		if not {
+			return nil, errors.Errorf("unexpected %s after NOT", t)
+		}
+		return left, nil
+	}
+	if not {
+		expr = &sNot{expr: expr}
+	}
+	return expr, nil
+}
+
+func (p *sParser) parseOperand() (sExpr, error) {
+	t := p.next()
+	switch {
+	case t.typ == tokenString:
+		return &sLiteral{val: t.val}, nil
+	case t.typ == tokenNumber:
+		return parseNumberLiteral(t.val, false)
+	case t.isOp("-"):
+		nt := p.next()
+		if nt.typ != tokenNumber {
+			return nil, errors.Errorf("expect number after '-', got %s", nt)
+		}
+		return parseNumberLiteral(nt.val, true)
+	case t.isOp("("):
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case t.isKeyword("NULL"):
+		return &sLiteral{val: nil}, nil
+	case t.isKeyword("TRUE"):
+		return &sLiteral{val: true}, nil
+	case t.isKeyword("FALSE"):
+		return &sLiteral{val: false}, nil
+	case t.isKeyword("CAST"):
+		return p.parseCast()
+	case t.typ == tokenIdent && p.peek().isOp("("):
+		return p.parseAggregate(t)
+	case p.isIdent(t):
+		return p.parseColumn(t)
+	}
+	return nil, errors.Errorf("unexpected %s", t)
+}
+
+func parseNumberLiteral(val string, neg bool) (sExpr, error) {
+	if neg {
+		val = "-" + val
+	}
+	if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return &sLiteral{val: i}, nil
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return nil, errors.Errorf("invalid number %s", val)
+	}
+	return &sLiteral{val: f}, nil
+}
+
+func (p *sParser) parseCast() (sExpr, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	typ := strings.ToUpper(t.val)
+	switch typ {
+	case "INT", "INTEGER", "BIGINT":
+		typ = castInt
+	case "FLOAT", "DOUBLE", "DECIMAL", "NUMERIC", "REAL":
+		typ = castFloat
+	case "STRING", "VARCHAR", "CHAR", "TEXT":
+		typ = castString
+	case "BOOL", "BOOLEAN":
+		typ = castBool
+	default:
+		return nil, errors.Errorf("unsupported CAST type %s", t)
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return &sCast{expr: expr, typ: typ}, nil
+}
+
+func (p *sParser) parseAggregate(t sToken) (sExpr, error) {
+	fn := strings.ToUpper(t.val)
+	switch fn {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+	default:
+		return nil, errors.Errorf("unsupported function %s", t)
+	}
+	p.next()
+	agg := &sAggregate{fn: fn}
+	if p.peek().isOp("*") {
+		if fn != "COUNT" {
+			return nil, errors.Errorf("%s(*) is not supported", fn)
+		}
+		p.next()
+	} else {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if hasAggregate(arg) {
+			return nil, errors.Errorf("nested aggregate function %s", t)
+		}
+		agg.arg = arg
+	}
+	if err := p.expectOp(")"); err != nil {
+		return nil, err
+	}
+	return agg, nil
+}
+
+// parseColumn parses a column reference, e.g. s._1, s.name, name, s."first name", s.a.b
+func (p *sParser) parseColumn(t sToken) (sExpr, error) {
+	path := []string{t.val}
+	for p.peek().isOp(".") {
+		p.next()
+		nt := p.next()
+		if nt.typ != tokenIdent && nt.typ != tokenQuotedIdent {
+			return nil, errors.Errorf("expect column name after '.', got %s", nt)
+		}
+		path = append(path, nt.val)
+	}
+	if len(path) > 1 && (path[0] == p.alias || strings.EqualFold(path[0], "S3Object")) {
+		path = path[1:]
+	}
+	return &sColumn{path: path}, nil
+}
+
+// likeToRegexp translates SQL LIKE pattern, % matches any characters and _
+// matches a single character
+func likeToRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	for _, c := range pattern {
+		switch c {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
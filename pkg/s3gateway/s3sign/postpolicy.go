@@ -0,0 +1,276 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sign
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6/pkg/s3signer"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/s3auth"
+)
+
+const (
+	POST_POLICY_EXPIRATION_FORMAT = "2006-01-02T15:04:05.000Z"
+
+	POST_POLICY_OP_EQ                   = "eq"
+	POST_POLICY_OP_STARTS_WITH          = "starts-with"
+	POST_POLICY_OP_CONTENT_LENGTH_RANGE = "content-length-range"
+
+	// the uploaded file must be the last field of the form
+	POST_FORM_FILE_FIELD = "file"
+	// ${filename} in the key is replaced by the name of the uploaded file
+	POST_FORM_FILENAME_VAR = "${filename}"
+)
+
+// fields that need not to be covered by the policy conditions
+var postPolicyExemptFields = map[string]bool{
+	"policy":          true,
+	"x-amz-signature": true,
+	"file":            true,
+}
+
+type SPostPolicyCondition struct {
+	Op string
+	// lower case field name without the leading $
+	Field string
+	Value string
+}
+
+// SPostPolicy is the decoded policy document of a browser based POST upload
+type SPostPolicy struct {
+	Expiration time.Time
+	Conditions []SPostPolicyCondition
+
+	// limits of the file size by content-length-range, negative means no limit
+	MinLength int64
+	MaxLength int64
+}
+
+// ParsePostPolicy decodes the base64 encoded policy document
+func ParsePostPolicy(policyBase64 string) (*SPostPolicy, error) {
+	data, err := base64.StdEncoding.DecodeString(policyBase64)
+	if err != nil {
+		return nil, errors.Wrap(err, "base64 decode policy")
+	}
+	obj, err := jsonutils.Parse(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse policy")
+	}
+	policy := &SPostPolicy{
+		MinLength: -1,
+		MaxLength: -1,
+	}
+	expStr, _ := obj.GetString("expiration")
+	policy.Expiration, err = time.Parse(time.RFC3339, expStr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid expiration %q", expStr)
+	}
+	conds, err := obj.GetArray("conditions")
+	if err != nil {
+		return nil, errors.Wrap(err, "missing conditions")
+	}
+	for _, cond := range conds {
+		switch c := cond.(type) {
+		case *jsonutils.JSONDict:
+			// {"bucket": "mybucket"} is the same as ["eq", "$bucket", "mybucket"]
+			for k, v := range c.Value() {
+				val, err := v.GetString()
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid condition %s", cond)
+				}
+				policy.Conditions = append(policy.Conditions, SPostPolicyCondition{
+					Op:    POST_POLICY_OP_EQ,
+					Field: strings.ToLower(k),
+					Value: val,
+				})
+			}
+		case *jsonutils.JSONArray:
+			items, _ := c.GetArray()
+			if len(items) != 3 {
+				return nil, errors.Errorf("invalid condition %s", cond)
+			}
+			op, _ := items[0].GetString()
+			op = strings.ToLower(op)
+			switch op {
+			case POST_POLICY_OP_CONTENT_LENGTH_RANGE:
+				policy.MinLength, err = items[1].Int()
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid condition %s", cond)
+				}
+				policy.MaxLength, err = items[2].Int()
+				if err != nil || policy.MaxLength < policy.MinLength {
+					return nil, errors.Errorf("invalid condition %s", cond)
+				}
+			case POST_POLICY_OP_EQ, POST_POLICY_OP_STARTS_WITH:
+				field, _ := items[1].GetString()
+				if !strings.HasPrefix(field, "$") {
+					return nil, errors.Errorf("invalid field %q in condition %s", field, cond)
+				}
+				val, err := items[2].GetString()
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid condition %s", cond)
+				}
+				policy.Conditions = append(policy.Conditions, SPostPolicyCondition{
+					Op:    op,
+					Field: strings.ToLower(field[1:]),
+					Value: val,
+				})
+			default:
+				return nil, errors.Errorf("unsupported condition %s", cond)
+			}
+		default:
+			return nil, errors.Errorf("invalid condition %s", cond)
+		}
+	}
+	return policy, nil
+}
+
+// Check checks the form fields, whose names are in lower case, against the
+// policy. As AWS S3 does, every field except the signature, the file and the
+// ones prefixed by x-ignore- must be covered by a condition
+func (policy *SPostPolicy) Check(bucket string, fields map[string]string, now time.Time) error {
+	if now.After(policy.Expiration) {
+		return errors.Wrapf(ErrExpired, "policy expired at %s", policy.Expiration)
+	}
+	covered := map[string]bool{}
+	for _, cond := range policy.Conditions {
+		val, ok := fields[cond.Field]
+		if cond.Field == "bucket" {
+			val, ok = bucket, true
+		}
+		if !ok {
+			return errors.Wrapf(ErrPolicyViolation, "missing field %s", cond.Field)
+		}
+		switch cond.Op {
+		case POST_POLICY_OP_EQ:
+			if val != cond.Value {
+				return errors.Wrapf(ErrPolicyViolation, "%s should be %q", cond.Field, cond.Value)
+			}
+		case POST_POLICY_OP_STARTS_WITH:
+			if !strings.HasPrefix(val, cond.Value) {
+				return errors.Wrapf(ErrPolicyViolation, "%s should start with %q", cond.Field, cond.Value)
+			}
+		}
+		covered[cond.Field] = true
+	}
+	for k := range fields {
+		if postPolicyExemptFields[k] || strings.HasPrefix(k, "x-ignore-") || covered[k] {
+			continue
+		}
+		return errors.Wrapf(ErrPolicyViolation, "field %s is not allowed by the policy", k)
+	}
+	return nil
+}
+
+// CheckContentLength checks the size of the uploaded file
+func (policy *SPostPolicy) CheckContentLength(size int64) error {
+	if policy.MinLength >= 0 && size < policy.MinLength {
+		return errors.Wrapf(ErrPolicyViolation, "file size %d is less than %d", size, policy.MinLength)
+	}
+	if policy.MaxLength >= 0 && size > policy.MaxLength {
+		return errors.Wrapf(ErrPolicyViolation, "file size %d is larger than %d", size, policy.MaxLength)
+	}
+	return nil
+}
+
+// DecodePostPolicyRequest decodes the SigV4 signature of the POST form, the
+// string to sign is the base64 encoded policy itself
+func DecodePostPolicyRequest(fields map[string]string) (*s3auth.SAccessKeyRequestV4, error) {
+	if algo := fields["x-amz-algorithm"]; algo != SIGN_V4_ALGORITHM {
+		return nil, errors.Errorf("unsupported signing algorithm %q", algo)
+	}
+	policy := fields["policy"]
+	if len(policy) == 0 {
+		return nil, errors.Error("missing policy")
+	}
+	signDate, err := time.Parse(ISO8601_DATE_FORMAT, fields["x-amz-date"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid x-amz-date %q", fields["x-amz-date"])
+	}
+	aksk := s3auth.NewV4Request()
+	aksk.AccessKey, aksk.Location, err = decodeCredential(fields["x-amz-credential"], signDate)
+	if err != nil {
+		return nil, errors.Wrap(err, "decodeCredential")
+	}
+	aksk.Signature = fields["x-amz-signature"]
+	aksk.SignDate = signDate
+	aksk.Request = policy
+	return &aksk, aksk.Validate()
+}
+
+type SPostPolicyInput struct {
+	Bucket string
+	// the exact object key, or the prefix of the key if KeyStartsWith
+	Key           string
+	KeyStartsWith bool
+
+	// limits of the file size, no limit if MaxLength is not positive
+	MinLength int64
+	MaxLength int64
+
+	// 200, 201 or 204
+	SuccessActionStatus string
+
+	Expires time.Duration
+}
+
+// SignPostPolicy generates the policy and returns the form fields to be
+// submitted along with the file
+func SignPostPolicy(input SPostPolicyInput, accessKey, secret string, now time.Time) (map[string]string, error) {
+	if input.Expires < time.Second || input.Expires > MAX_PRESIGN_EXPIRES {
+		return nil, errors.Errorf("expires should be between 1 second and %s", MAX_PRESIGN_EXPIRES)
+	}
+	now = now.UTC()
+	fields := map[string]string{
+		"x-amz-algorithm":  SIGN_V4_ALGORITHM,
+		"x-amz-credential": s3signer.GetCredential(accessKey, DEFAULT_LOCATION, now),
+		"x-amz-date":       now.Format(ISO8601_DATE_FORMAT),
+	}
+	conds := jsonutils.NewArray()
+	conds.Add(jsonutils.Marshal(map[string]string{"bucket": input.Bucket}))
+	if input.KeyStartsWith {
+		conds.Add(jsonutils.NewStringArray([]string{POST_POLICY_OP_STARTS_WITH, "$key", input.Key}))
+		fields["key"] = input.Key + POST_FORM_FILENAME_VAR
+	} else {
+		conds.Add(jsonutils.NewStringArray([]string{POST_POLICY_OP_EQ, "$key", input.Key}))
+		fields["key"] = input.Key
+	}
+	if len(input.SuccessActionStatus) > 0 {
+		conds.Add(jsonutils.NewStringArray([]string{POST_POLICY_OP_EQ, "$success_action_status", input.SuccessActionStatus}))
+		fields["success_action_status"] = input.SuccessActionStatus
+	}
+	if input.MaxLength > 0 {
+		conds.Add(jsonutils.NewArray(
+			jsonutils.NewString(POST_POLICY_OP_CONTENT_LENGTH_RANGE),
+			jsonutils.NewInt(input.MinLength),
+			jsonutils.NewInt(input.MaxLength),
+		))
+	}
+	for _, k := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date"} {
+		conds.Add(jsonutils.NewStringArray([]string{POST_POLICY_OP_EQ, "$" + k, fields[k]}))
+	}
+	policy := jsonutils.NewDict()
+	policy.Add(jsonutils.NewString(now.Add(input.Expires).Format(POST_POLICY_EXPIRATION_FORMAT)), "expiration")
+	policy.Add(conds, "conditions")
+
+	fields["policy"] = base64.StdEncoding.EncodeToString([]byte(policy.String()))
+	fields["x-amz-signature"] = s3signer.PostPresignSignatureV4(fields["policy"], now, secret, DEFAULT_LOCATION)
+	return fields, nil
+}
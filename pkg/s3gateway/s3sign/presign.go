@@ -0,0 +1,204 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3sign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v6/pkg/s3signer"
+	"github.com/minio/minio-go/v6/pkg/s3utils"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/s3auth"
+	"yunion.io/x/pkg/utils"
+)
+
+const (
+	SIGN_V4_ALGORITHM   = "AWS4-HMAC-SHA256"
+	ISO8601_DATE_FORMAT = "20060102T150405Z"
+	YYYYMMDD            = "20060102"
+
+	// location in the credential scope of the signatures generated by the
+	// gateway, any location is accepted when verifying
+	DEFAULT_LOCATION = "us-east-1"
+
+	// the same limit as AWS S3
+	MAX_PRESIGN_EXPIRES = 7 * 24 * time.Hour
+
+	// allowed clock skew between the client and the gateway
+	MAX_CLOCK_SKEW = 15 * time.Minute
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	ErrExpired         = errors.Error("request has expired")
+	ErrPolicyViolation = errors.Error("policy condition failed")
+)
+
+var presignQueryKeys = []string{
+	"X-Amz-Algorithm",
+	"X-Amz-Credential",
+	"X-Amz-Date",
+	"X-Amz-Expires",
+	"X-Amz-SignedHeaders",
+	"X-Amz-Signature",
+	"X-Amz-Security-Token",
+}
+
+// IsPresignedRequest tells whether the request is authenticated by the
+// signature in the query string
+func IsPresignedRequest(query url.Values) bool {
+	return len(query.Get("X-Amz-Signature")) > 0
+}
+
+// RemovePresignQuery strips the authentication parameters of a presigned URL
+// from the raw query, so that the request can be handled as a normal one
+func RemovePresignQuery(rawQuery string) string {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !IsPresignedRequest(query) {
+		return rawQuery
+	}
+	for _, k := range presignQueryKeys {
+		query.Del(k)
+	}
+	return query.Encode()
+}
+
+// decodeCredential decodes the credential of the form
+// <access_key>/<yyyymmdd>/<location>/s3/aws4_request
+func decodeCredential(cred string, signDate time.Time) (string, string, error) {
+	parts := strings.Split(cred, "/")
+	if len(parts) != 5 || len(parts[0]) == 0 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return "", "", errors.Errorf("invalid credential %q", cred)
+	}
+	if parts[1] != signDate.Format(YYYYMMDD) {
+		return "", "", errors.Errorf("credential date %s mismatch with %s", parts[1], signDate.Format(YYYYMMDD))
+	}
+	return parts[0], parts[2], nil
+}
+
+func getScope(location string, t time.Time) string {
+	return strings.Join([]string{t.Format(YYYYMMDD), location, "s3", "aws4_request"}, "/")
+}
+
+func getStringToSign(t time.Time, location string, canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	return strings.Join([]string{
+		SIGN_V4_ALGORITHM,
+		t.Format(ISO8601_DATE_FORMAT),
+		getScope(location, t),
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+}
+
+func getCanonicalHeaders(req http.Request, signedHeaders []string) string {
+	var buf bytes.Buffer
+	for _, k := range signedHeaders {
+		buf.WriteString(k)
+		buf.WriteByte(':')
+		if k == "host" {
+			host := req.Host
+			if len(host) == 0 {
+				host = req.URL.Host
+			}
+			buf.WriteString(host)
+		} else {
+			for i, v := range req.Header[http.CanonicalHeaderKey(k)] {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(strings.Join(strings.Fields(v), " "))
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// DecodePresignedRequest decodes and checks the SigV4 query string
+// authentication of a presigned URL, the returned request carries the
+// string to sign, which is verified by keystone with the secret of the key
+func DecodePresignedRequest(req http.Request, now time.Time) (*s3auth.SAccessKeyRequestV4, error) {
+	query := req.URL.Query()
+	if algo := query.Get("X-Amz-Algorithm"); algo != SIGN_V4_ALGORITHM {
+		return nil, errors.Errorf("unsupported presign algorithm %q", algo)
+	}
+	signDate, err := time.Parse(ISO8601_DATE_FORMAT, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid X-Amz-Date %q", query.Get("X-Amz-Date"))
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires <= 0 || time.Duration(expires)*time.Second > MAX_PRESIGN_EXPIRES {
+		return nil, errors.Errorf("invalid X-Amz-Expires %q", query.Get("X-Amz-Expires"))
+	}
+	if now.Add(MAX_CLOCK_SKEW).Before(signDate) {
+		return nil, errors.Errorf("request is signed in the future at %s", signDate)
+	}
+	if now.After(signDate.Add(time.Duration(expires) * time.Second)) {
+		return nil, errors.Wrapf(ErrExpired, "signed at %s, expires in %d seconds", signDate, expires)
+	}
+
+	aksk := s3auth.NewV4Request()
+	aksk.AccessKey, aksk.Location, err = decodeCredential(query.Get("X-Amz-Credential"), signDate)
+	if err != nil {
+		return nil, errors.Wrap(err, "decodeCredential")
+	}
+	aksk.SignedHeaders = strings.Split(strings.ToLower(query.Get("X-Amz-SignedHeaders")), ";")
+	sort.Strings(aksk.SignedHeaders)
+	if !utils.IsInStringArray("host", aksk.SignedHeaders) {
+		return nil, errors.Error("host is not signed")
+	}
+	aksk.Signature = query.Get("X-Amz-Signature")
+	aksk.SignDate = signDate
+
+	query.Del("X-Amz-Signature")
+	hashedPayload := req.Header.Get("X-Amz-Content-Sha256")
+	if len(hashedPayload) == 0 {
+		hashedPayload = unsignedPayload
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3utils.EncodePath(req.URL.Path),
+		strings.Replace(query.Encode(), "+", "%20", -1),
+		getCanonicalHeaders(req, aksk.SignedHeaders),
+		strings.Join(aksk.SignedHeaders, ";"),
+		hashedPayload,
+	}, "\n")
+	aksk.Request = getStringToSign(signDate, aksk.Location, canonicalRequest)
+	return &aksk, aksk.Validate()
+}
+
+// PresignV4 returns the URL of the request signed in the query string, which
+// is valid for expires
+func PresignV4(method string, u *url.URL, accessKey, secret string, expires time.Duration) (string, error) {
+	if expires < time.Second || expires > MAX_PRESIGN_EXPIRES {
+		return "", errors.Errorf("expires should be between 1 second and %s", MAX_PRESIGN_EXPIRES)
+	}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "http.NewRequest")
+	}
+	signed := s3signer.PreSignV4(*req, accessKey, secret, "", DEFAULT_LOCATION, int64(expires/time.Second))
+	return signed.URL.String(), nil
+}
@@ -17,6 +17,7 @@ package service
 import (
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/scheduledtask/models"
 )
@@ -25,6 +26,7 @@ func InitHandlers(app *appsrv.Application) {
 	db.InitAllManagers()
 	db.RegistUserCredCacheUpdater()
 	db.AddScopeResourceCountHandler("", app)
+	cronman.AddCronJobHandler("", app)
 
 	for _, manager := range []db.IModelManager{
 		db.UserCacheManager,
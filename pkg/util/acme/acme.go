@@ -0,0 +1,122 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package acme provides the account and challenge helpers used to issue
+// certificates with golang.org/x/crypto/acme
+package acme
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// HTTP01ChallengePath is the path prefix the http-01 responder must serve
+	HTTP01ChallengePath = "/.well-known/acme-challenge/"
+
+	// DNS01ChallengeLabel is the label prepended to the domain for dns-01
+	DNS01ChallengeLabel = "_acme-challenge"
+)
+
+// NewClient returns the client of the account key, the account url is used
+// as the JWS kid once the account is registered
+func NewClient(directoryUrl string, key *ecdsa.PrivateKey, accountUrl string, httpClient *http.Client) *acme.Client {
+	return &acme.Client{
+		DirectoryURL: directoryUrl,
+		Key:          key,
+		KID:          acme.KeyID(accountUrl),
+		HTTPClient:   httpClient,
+	}
+}
+
+// Register creates the account of the client key, or looks up the existing
+// one, and returns the account url
+func Register(ctx context.Context, cli *acme.Client, email string) (string, error) {
+	account := &acme.Account{}
+	if len(email) > 0 {
+		account.Contact = []string{"mailto:" + email}
+	}
+	_, err := cli.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return "", errors.Wrap(err, "Register")
+	}
+	if len(cli.KID) == 0 {
+		return "", errors.Error("Register: missing account location")
+	}
+	return string(cli.KID), nil
+}
+
+// EncodeCertificateChain returns the PEM encoded chain of the DER encoded
+// certificates issued by the server
+func EncodeCertificateChain(der [][]byte) ([]byte, error) {
+	if len(der) == 0 {
+		return nil, errors.Wrap(errors.ErrEmpty, "certificate chain")
+	}
+	chain := []byte{}
+	for i := range der {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der[i]})...)
+	}
+	return chain, nil
+}
+
+// DNS01RecordName returns the TXT record name of the domain, with the
+// wildcard label stripped
+func DNS01RecordName(domain string) string {
+	return DNS01ChallengeLabel + "." + strings.TrimPrefix(domain, "*.")
+}
+
+// NewCSR creates a DER encoded certificate request for the domains, the first
+// one is used as common name
+func NewCSR(key crypto.Signer, domains []string) ([]byte, error) {
+	if len(domains) == 0 {
+		return nil, errors.ErrEmpty
+	}
+	tmpl := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}
+	return x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+}
+
+func GenerateAccountKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func MarshalAccountKey(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", errors.Wrap(err, "MarshalECPrivateKey")
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})), nil
+}
+
+func ParseAccountKey(data string) (*ecdsa.PrivateKey, error) {
+	blk, _ := pem.Decode([]byte(data))
+	if blk == nil {
+		return nil, errors.Error("invalid pem account key")
+	}
+	return x509.ParseECPrivateKey(blk.Bytes)
+}
@@ -0,0 +1,526 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+var errAccountExists = fmt.Errorf("account exists")
+
+const problemBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+// the wire objects served by the fake server
+
+type testProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+type testIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type testOrder struct {
+	URL string `json:"-"`
+
+	Status         string           `json:"status"`
+	Identifiers    []testIdentifier `json:"identifiers"`
+	Authorizations []string         `json:"authorizations"`
+	Finalize       string           `json:"finalize"`
+	Certificate    string           `json:"certificate,omitempty"`
+}
+
+type testChallenge struct {
+	Type   string       `json:"type"`
+	URL    string       `json:"url"`
+	Token  string       `json:"token"`
+	Status string       `json:"status"`
+	Error  *testProblem `json:"error,omitempty"`
+}
+
+type testAuthorization struct {
+	URL string `json:"-"`
+
+	Identifier testIdentifier   `json:"identifier"`
+	Status     string           `json:"status"`
+	Wildcard   bool             `json:"wildcard,omitempty"`
+	Challenges []*testChallenge `json:"challenges"`
+}
+
+func (authz *testAuthorization) findChallenge(typ string) *testChallenge {
+	for _, chal := range authz.Challenges {
+		if chal.Type == typ {
+			return chal
+		}
+	}
+	return nil
+}
+
+const (
+	challengeTypeHTTP01 = "http-01"
+	challengeTypeDNS01  = "dns-01"
+)
+
+// fakeAcmeServer is a minimal in-process ACME server verifying the JWS
+// signatures, nonces and key authorizations sent by the client
+type fakeAcmeServer struct {
+	*httptest.Server
+
+	t      *testing.T
+	lock   sync.Mutex
+	serial int
+	nonces map[string]bool
+
+	accountKey *ecdsa.PublicKey
+	badNonce   bool
+
+	orders map[string]*testOrder
+	authzs map[string]*testAuthorization
+	certs  map[string][]byte
+
+	// validate is called when a challenge is accepted
+	validate func(chal *testChallenge, domain string, keyAuth string) bool
+
+	caKey  *ecdsa.PrivateKey
+	caCert *x509.Certificate
+}
+
+func newFakeAcmeServer(t *testing.T) *fakeAcmeServer {
+	s := &fakeAcmeServer{
+		t:      t,
+		nonces: map[string]bool{},
+		orders: map[string]*testOrder{},
+		authzs: map[string]*testAuthorization{},
+		certs:  map[string][]byte{},
+	}
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake acme ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	s.caKey = caKey
+	s.caCert, _ = x509.ParseCertificate(der)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fakeAcmeServer) newNonce(w http.ResponseWriter) {
+	s.serial++
+	nonce := fmt.Sprintf("nonce-%d", s.serial)
+	s.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+func (s *fakeAcmeServer) problem(w http.ResponseWriter, status int, typ, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&testProblem{Type: typ, Detail: detail, Status: status})
+}
+
+func (s *fakeAcmeServer) reply(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// verify checks the JWS and returns the decoded payload
+func (s *fakeAcmeServer) verify(r *http.Request) ([]byte, error) {
+	body, _ := ioutil.ReadAll(r.Body)
+	jws := struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}{}
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, err
+	}
+	phdr, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	hdr := struct {
+		Alg   string            `json:"alg"`
+		Nonce string            `json:"nonce"`
+		Url   string            `json:"url"`
+		Kid   string            `json:"kid"`
+		Jwk   map[string]string `json:"jwk"`
+	}{}
+	if err := json.Unmarshal(phdr, &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Alg != "ES256" {
+		return nil, fmt.Errorf("unexpected alg %s", hdr.Alg)
+	}
+	if hdr.Url != s.URL+r.URL.Path {
+		return nil, fmt.Errorf("url mismatch %s", hdr.Url)
+	}
+	if !s.nonces[hdr.Nonce] {
+		return nil, fmt.Errorf("badNonce")
+	}
+	delete(s.nonces, hdr.Nonce)
+	var pub *ecdsa.PublicKey
+	if r.URL.Path == "/new-account" {
+		if hdr.Jwk == nil || len(hdr.Kid) > 0 {
+			return nil, fmt.Errorf("newAccount must use jwk")
+		}
+		x, _ := base64.RawURLEncoding.DecodeString(hdr.Jwk["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(hdr.Jwk["y"])
+		pub = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	} else {
+		if hdr.Kid != s.URL+"/acct/1" || hdr.Jwk != nil {
+			return nil, fmt.Errorf("request must use kid")
+		}
+		pub = s.accountKey
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("bad signature length")
+	}
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return nil, fmt.Errorf("bad signature")
+	}
+	if r.URL.Path == "/new-account" {
+		if s.accountKey != nil && s.accountKey.Equal(pub) {
+			return nil, errAccountExists
+		}
+		s.accountKey = pub
+	}
+	return base64.RawURLEncoding.DecodeString(jws.Payload)
+}
+
+func (s *fakeAcmeServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.newNonce(w)
+	switch {
+	case r.URL.Path == "/dir":
+		s.reply(w, http.StatusOK, map[string]string{
+			"newNonce":   s.URL + "/nonce",
+			"newAccount": s.URL + "/new-account",
+			"newOrder":   s.URL + "/new-order",
+		})
+		return
+	case r.URL.Path == "/nonce":
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	payload, err := s.verify(r)
+	if err == errAccountExists {
+		w.Header().Set("Location", s.URL+"/acct/1")
+		s.reply(w, http.StatusOK, map[string]string{"status": acme.StatusValid})
+		return
+	}
+	if err != nil {
+		if err.Error() == "badNonce" {
+			s.problem(w, http.StatusBadRequest, problemBadNonce, "bad nonce")
+		} else {
+			s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:malformed", err.Error())
+		}
+		return
+	}
+	path := r.URL.Path
+	switch {
+	case path == "/new-account":
+		w.Header().Set("Location", s.URL+"/acct/1")
+		s.reply(w, http.StatusCreated, map[string]string{"status": acme.StatusValid})
+	case path == "/new-order":
+		if s.badNonce {
+			s.badNonce = false
+			s.problem(w, http.StatusBadRequest, problemBadNonce, "injected bad nonce")
+			return
+		}
+		req := struct {
+			Identifiers []testIdentifier `json:"identifiers"`
+		}{}
+		json.Unmarshal(payload, &req)
+		id := fmt.Sprintf("%d", len(s.orders)+1)
+		order := &testOrder{
+			URL:         s.URL + "/order/" + id,
+			Status:      acme.StatusPending,
+			Identifiers: req.Identifiers,
+			Finalize:    s.URL + "/finalize/" + id,
+		}
+		for i, ident := range req.Identifiers {
+			aid := fmt.Sprintf("%s-%d", id, i)
+			authz := &testAuthorization{
+				URL:        s.URL + "/authz/" + aid,
+				Identifier: testIdentifier{Type: "dns", Value: strings.TrimPrefix(ident.Value, "*.")},
+				Status:     acme.StatusPending,
+				Wildcard:   strings.HasPrefix(ident.Value, "*."),
+				Challenges: []*testChallenge{
+					{Type: challengeTypeHTTP01, URL: s.URL + "/chal/" + aid + "/http", Token: "token-http-" + aid, Status: acme.StatusPending},
+					{Type: challengeTypeDNS01, URL: s.URL + "/chal/" + aid + "/dns", Token: "token-dns-" + aid, Status: acme.StatusPending},
+				},
+			}
+			s.authzs[aid] = authz
+			order.Authorizations = append(order.Authorizations, authz.URL)
+		}
+		s.orders[id] = order
+		w.Header().Set("Location", order.URL)
+		s.reply(w, http.StatusCreated, order)
+	case strings.HasPrefix(path, "/authz/"):
+		s.reply(w, http.StatusOK, s.authzs[strings.TrimPrefix(path, "/authz/")])
+	case strings.HasPrefix(path, "/chal/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/chal/"), "/")
+		authz := s.authzs[parts[0]]
+		chal := authz.findChallenge(map[string]string{"http": challengeTypeHTTP01, "dns": challengeTypeDNS01}[parts[1]])
+		if s.validate(chal, authz.Identifier.Value, chal.Token+"."+s.thumbprint()) {
+			chal.Status = acme.StatusValid
+			authz.Status = acme.StatusValid
+		} else {
+			chal.Status = acme.StatusInvalid
+			chal.Error = &testProblem{Type: "urn:ietf:params:acme:error:unauthorized", Detail: "key authorization mismatch"}
+			authz.Status = acme.StatusInvalid
+		}
+		s.reply(w, http.StatusOK, chal)
+	case strings.HasPrefix(path, "/order/"):
+		order := s.orders[strings.TrimPrefix(path, "/order/")]
+		s.refreshOrder(order)
+		s.reply(w, http.StatusOK, order)
+	case strings.HasPrefix(path, "/finalize/"):
+		id := strings.TrimPrefix(path, "/finalize/")
+		order := s.orders[id]
+		s.refreshOrder(order)
+		if order.Status != acme.StatusReady {
+			s.problem(w, http.StatusForbidden, "urn:ietf:params:acme:error:orderNotReady", "order not ready")
+			return
+		}
+		req := struct {
+			Csr string `json:"csr"`
+		}{}
+		json.Unmarshal(payload, &req)
+		der, _ := base64.RawURLEncoding.DecodeString(req.Csr)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			s.problem(w, http.StatusBadRequest, "urn:ietf:params:acme:error:badCSR", err.Error())
+			return
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(s.serial)),
+			Subject:      csr.Subject,
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		cert, err := x509.CreateCertificate(rand.Reader, tmpl, s.caCert, csr.PublicKey, s.caKey)
+		if err != nil {
+			s.problem(w, http.StatusInternalServerError, "urn:ietf:params:acme:error:serverInternal", err.Error())
+			return
+		}
+		chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCert.Raw})...)
+		s.certs[id] = chain
+		order.Status = acme.StatusValid
+		order.Certificate = s.URL + "/cert/" + id
+		s.reply(w, http.StatusOK, order)
+	case strings.HasPrefix(path, "/cert/"):
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.certs[strings.TrimPrefix(path, "/cert/")])
+	default:
+		s.problem(w, http.StatusNotFound, "urn:ietf:params:acme:error:malformed", "not found")
+	}
+}
+
+// thumbprint is the RFC 7638 thumbprint of the account key, computed by hand
+// to check the key authorizations of the client
+func (s *fakeAcmeServer) thumbprint() string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	s.accountKey.X.FillBytes(x)
+	s.accountKey.Y.FillBytes(y)
+	jwk := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, base64.RawURLEncoding.EncodeToString(x), base64.RawURLEncoding.EncodeToString(y))
+	sum := sha256.Sum256([]byte(jwk))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (s *fakeAcmeServer) refreshOrder(order *testOrder) {
+	if order.Status != acme.StatusPending {
+		return
+	}
+	for _, u := range order.Authorizations {
+		authz := s.authzs[strings.TrimPrefix(u, s.URL+"/authz/")]
+		switch authz.Status {
+		case acme.StatusValid:
+		case acme.StatusInvalid:
+			order.Status = acme.StatusInvalid
+			return
+		default:
+			return
+		}
+	}
+	order.Status = acme.StatusReady
+}
+
+func issueWithClient(t *testing.T, srv *fakeAcmeServer, chalType string, domains []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	key, err := GenerateAccountKey()
+	if err != nil {
+		t.Fatalf("GenerateAccountKey: %v", err)
+	}
+	cli := NewClient(srv.URL+"/dir", key, "", srv.Client())
+	accountUrl, err := Register(ctx, cli, "admin@example.com")
+	if err != nil {
+		return nil, err
+	}
+	// registering the key again returns the existing account
+	cli = NewClient(srv.URL+"/dir", key, "", srv.Client())
+	if existing, err := Register(ctx, cli, "admin@example.com"); err != nil || existing != accountUrl {
+		t.Fatalf("register existing account: %s %v", existing, err)
+	}
+	order, err := cli.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range order.AuthzURLs {
+		authz, err := cli.GetAuthorization(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		var chal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == chalType {
+				chal = c
+			}
+		}
+		if chal == nil {
+			t.Fatalf("no %s challenge", chalType)
+		}
+		if _, err := cli.Accept(ctx, chal); err != nil {
+			return nil, err
+		}
+		if _, err := cli.WaitAuthorization(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	order, err = cli.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, err
+	}
+	certKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	csr, err := NewCSR(certKey, domains)
+	if err != nil {
+		t.Fatalf("NewCSR: %v", err)
+	}
+	der, _, err := cli.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeCertificateChain(der)
+}
+
+func TestClientIssue(t *testing.T) {
+	srv := newFakeAcmeServer(t)
+	defer srv.Close()
+
+	srv.badNonce = true
+	srv.validate = func(chal *testChallenge, domain string, keyAuth string) bool {
+		return chal.Type == challengeTypeHTTP01
+	}
+	chain, err := issueWithClient(t, srv, challengeTypeHTTP01, []string{"www.example.com", "example.com"})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	blk, rest := pem.Decode(chain)
+	if blk == nil {
+		t.Fatalf("empty chain")
+	}
+	cert, err := x509.ParseCertificate(blk.Bytes)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	if cert.Subject.CommonName != "www.example.com" || len(cert.DNSNames) != 2 {
+		t.Errorf("unexpected cert subject %s, names %v", cert.Subject.CommonName, cert.DNSNames)
+	}
+	if blk, _ := pem.Decode(rest); blk == nil {
+		t.Errorf("missing issuer in chain")
+	}
+}
+
+func TestClientChallengeFailure(t *testing.T) {
+	srv := newFakeAcmeServer(t)
+	defer srv.Close()
+
+	srv.validate = func(chal *testChallenge, domain string, keyAuth string) bool {
+		return false
+	}
+	_, err := issueWithClient(t, srv, challengeTypeDNS01, []string{"*.example.com"})
+	if err == nil {
+		t.Fatalf("expect challenge failure")
+	}
+	if !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestKeyAuthorization(t *testing.T) {
+	srv := newFakeAcmeServer(t)
+	defer srv.Close()
+
+	key, _ := GenerateAccountKey()
+	srv.accountKey = &key.PublicKey
+	cli := NewClient("", key, "", nil)
+	keyAuth, err := cli.HTTP01ChallengeResponse("token")
+	if err != nil {
+		t.Fatalf("HTTP01ChallengeResponse: %v", err)
+	}
+	if keyAuth != "token."+srv.thumbprint() {
+		t.Errorf("unexpected key authorization %s", keyAuth)
+	}
+	if DNS01RecordName("*.example.com") != "_acme-challenge.example.com" {
+		t.Errorf("unexpected dns-01 record name %s", DNS01RecordName("*.example.com"))
+	}
+
+	pemKey, err := MarshalAccountKey(key)
+	if err != nil {
+		t.Fatalf("MarshalAccountKey: %v", err)
+	}
+	key2, err := ParseAccountKey(pemKey)
+	if err != nil || !key2.Equal(key) {
+		t.Errorf("account key round trip failed: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// ref: https://docs.asciinema.org/manual/asciicast/v2/
+
+const (
+	VERSION = 2
+
+	DEFAULT_WIDTH  = 80
+	DEFAULT_HEIGHT = 24
+)
+
+type TEventType string
+
+const (
+	EventOutput = TEventType("o")
+	EventInput  = TEventType("i")
+	EventMarker = TEventType("m")
+	EventResize = TEventType("r")
+)
+
+type SHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type SEvent struct {
+	// 距离录制开始的秒数
+	Time float64
+	Type TEventType
+	Data string
+}
+
+func (e SEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+func (e *SEvent) UnmarshalJSON(data []byte) error {
+	parts := []json.RawMessage{}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.Wrap(err, "unmarshal event")
+	}
+	if len(parts) != 3 {
+		return errors.Wrapf(errors.ErrInvalidFormat, "event should have 3 elements, got %d", len(parts))
+	}
+	if err := json.Unmarshal(parts[0], &e.Time); err != nil {
+		return errors.Wrap(err, "unmarshal event time")
+	}
+	if err := json.Unmarshal(parts[1], &e.Type); err != nil {
+		return errors.Wrap(err, "unmarshal event type")
+	}
+	if err := json.Unmarshal(parts[2], &e.Data); err != nil {
+		return errors.Wrap(err, "unmarshal event data")
+	}
+	return nil
+}
+
+// SWriter writes an asciicast v2 stream, the header is written on creation
+// and each event is written as a single line afterwards
+type SWriter struct {
+	w     *bufio.Writer
+	start time.Time
+	size  int64
+	lock  sync.Mutex
+
+	// trailing bytes of an incomplete utf8 sequence, per event type
+	pending map[TEventType][]byte
+}
+
+func NewWriter(w io.Writer, header SHeader, start time.Time) (*SWriter, error) {
+	header.Version = VERSION
+	if header.Width <= 0 {
+		header.Width = DEFAULT_WIDTH
+	}
+	if header.Height <= 0 {
+		header.Height = DEFAULT_HEIGHT
+	}
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+	writer := &SWriter{
+		w:       bufio.NewWriter(w),
+		start:   start,
+		pending: map[TEventType][]byte{},
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal header")
+	}
+	if err := writer.writeLine(line); err != nil {
+		return nil, errors.Wrap(err, "write header")
+	}
+	return writer, nil
+}
+
+func (w *SWriter) writeLine(line []byte) error {
+	n, err := w.w.Write(append(line, '\n'))
+	w.size += int64(n)
+	return err
+}
+
+// Size returns the number of bytes written so far
+func (w *SWriter) Size() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.size
+}
+
+func (w *SWriter) WriteOutput(data []byte) error {
+	return w.WriteEvent(time.Since(w.start), EventOutput, data)
+}
+
+func (w *SWriter) WriteInput(data []byte) error {
+	return w.WriteEvent(time.Since(w.start), EventInput, data)
+}
+
+func (w *SWriter) WriteResize(cols, rows int) error {
+	return w.WriteEvent(time.Since(w.start), EventResize, []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+func (w *SWriter) WriteEvent(elapsed time.Duration, evType TEventType, data []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if pending := w.pending[evType]; len(pending) > 0 {
+		data = append(pending, data...)
+		delete(w.pending, evType)
+	}
+	if tail := incompleteUtf8Tail(data); tail > 0 {
+		w.pending[evType] = append([]byte{}, data[len(data)-tail:]...)
+		data = data[:len(data)-tail]
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	ev := SEvent{
+		Time: math.Round(elapsed.Seconds()*1e6) / 1e6,
+		Type: evType,
+		Data: string(data),
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrap(err, "marshal event")
+	}
+	return w.writeLine(line)
+}
+
+func (w *SWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.w.Flush()
+}
+
+// incompleteUtf8Tail returns the length of a truncated multi-byte utf8
+// sequence at the end of data, which should be merged with the next chunk
+func incompleteUtf8Tail(data []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		idx := len(data) - i
+		if !utf8.RuneStart(data[idx]) {
+			continue
+		}
+		if utf8.FullRune(data[idx:]) {
+			return 0
+		}
+		return i
+	}
+	return 0
+}
+
+type SReader struct {
+	r      *bufio.Reader
+	Header SHeader
+}
+
+func NewReader(r io.Reader) (*SReader, error) {
+	reader := &SReader{
+		r: bufio.NewReader(r),
+	}
+	line, err := reader.readLine()
+	if err != nil {
+		return nil, errors.Wrap(err, "read header")
+	}
+	if err := json.Unmarshal(line, &reader.Header); err != nil {
+		return nil, errors.Wrap(err, "unmarshal header")
+	}
+	if reader.Header.Version != VERSION {
+		return nil, errors.Wrapf(errors.ErrNotSupported, "asciicast version %d", reader.Header.Version)
+	}
+	return reader, nil
+}
+
+func (r *SReader) readLine() ([]byte, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Next returns the next event, io.EOF is returned at the end of stream
+func (r *SReader) Next() (*SEvent, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	ev := &SEvent{}
+	if err := json.Unmarshal(line, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
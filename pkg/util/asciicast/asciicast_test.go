@@ -0,0 +1,104 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	start := time.Unix(1700000000, 0)
+	w, err := NewWriter(buf, SHeader{Width: 120, Height: 32, Title: "test"}, start)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	zh := []byte("中文")
+	for _, ev := range []struct {
+		elapsed time.Duration
+		evType  TEventType
+		data    []byte
+	}{
+		{time.Millisecond * 100, EventOutput, []byte("$ ")},
+		{time.Millisecond * 200, EventInput, []byte("ls\r")},
+		{time.Millisecond * 300, EventOutput, zh[:4]},
+		{time.Millisecond * 400, EventOutput, zh[4:]},
+		{time.Second, EventResize, []byte("80x24")},
+	} {
+		if err := w.WriteEvent(ev.elapsed, ev.evType, ev.data); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if w.Size() != int64(buf.Len()) {
+		t.Errorf("size %d != %d", w.Size(), buf.Len())
+	}
+
+	r, err := NewReader(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	wantHeader := SHeader{Version: VERSION, Width: 120, Height: 32, Timestamp: 1700000000, Title: "test"}
+	if !reflect.DeepEqual(r.Header, wantHeader) {
+		t.Errorf("header %#v != %#v", r.Header, wantHeader)
+	}
+	want := []SEvent{
+		{0.1, EventOutput, "$ "},
+		{0.2, EventInput, "ls\r"},
+		{0.3, EventOutput, "中"},
+		{0.4, EventOutput, "文"},
+		{1, EventResize, "80x24"},
+	}
+	got := []SEvent{}
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, *ev)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events %#v != %#v", got, want)
+	}
+}
+
+func TestIncompleteUtf8Tail(t *testing.T) {
+	emoji := []byte("😀")
+	for _, c := range []struct {
+		data []byte
+		want int
+	}{
+		{[]byte("abc"), 0},
+		{[]byte("中"), 0},
+		{[]byte("中")[:2], 2},
+		{append([]byte("a"), emoji[:3]...), 3},
+		{emoji, 0},
+		{[]byte{}, 0},
+	} {
+		if got := incompleteUtf8Tail(c.data); got != c.want {
+			t.Errorf("incompleteUtf8Tail(%q) = %d, want %d", c.data, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/sqlchemy"
+)
+
+type sTestPgModel struct {
+	Id            int64                `primary:"true" auto_increment:"true"`
+	Name          string               `width:"64" charset:"utf8" nullable:"false" index:"true"`
+	Notes         string               `charset:"utf8" nullable:"true"`
+	Enabled       tristate.TriState    `default:"true"`
+	IsSystem      bool                 `nullable:"false" default:"false"`
+	IsPublic      *bool                `nullable:"false" default:"true"`
+	Status        string               `width:"36" charset:"ascii" nullable:"false" default:"init"`
+	Size          int                  `nullable:"false" default:"-1"`
+	Ratio         float64              `nullable:"true"`
+	Price         float64              `width:"10" precision:"2" nullable:"true"`
+	Ip            string               `width:"16" charset:"ascii" nullable:"true"`
+	Metadata      jsonutils.JSONObject `nullable:"true"`
+	Tags          map[string]string    `nullable:"true"`
+	CreatedAt     time.Time            `nullable:"false" created_at:"true"`
+	UpdateVersion int                  `nullable:"false" default:"0" auto_version:"true"`
+	DeletedAt     time.Time            `nullable:"true"`
+}
+
+const testDBName = sqlchemy.DBName("pgtest")
+
+var testBackend = &SPostgreSQLBackend{}
+
+func init() {
+	// sql.Open does not connect to the server
+	conn, err := sql.Open(DriverName, "postgres://localhost/test")
+	if err != nil {
+		panic(err)
+	}
+	sqlchemy.SetDBWithNameBackend(conn, testDBName, PostgreSQLBackend)
+}
+
+func newTestTableSpec(name string) *sqlchemy.STableSpec {
+	return sqlchemy.NewTableSpecFromStructWithDBName(sTestPgModel{}, name, testDBName)
+}
+
+var testPgColumns = []struct {
+	def  string
+	info sSqlColumnInfo
+}{
+	{
+		def:  `"id" BIGINT GENERATED BY DEFAULT AS IDENTITY (START WITH 1) NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "id", DataType: "bigint", IsNullable: "NO", IsIdentity: "YES", IdentityStart: "1", IsPrimary: true},
+	},
+	{
+		def:  `"name" VARCHAR(64) DEFAULT '' NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "name", DataType: "character varying", IsNullable: "NO", CharacterMaximumLength: 64, ColumnDefault: "''::character varying"},
+	},
+	{
+		def:  `"notes" TEXT`,
+		info: sSqlColumnInfo{ColumnName: "notes", DataType: "text", IsNullable: "YES"},
+	},
+	{
+		def:  `"enabled" SMALLINT DEFAULT 1`,
+		info: sSqlColumnInfo{ColumnName: "enabled", DataType: "smallint", IsNullable: "YES", ColumnDefault: "1"},
+	},
+	{
+		def:  `"is_system" SMALLINT DEFAULT 0 NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "is_system", DataType: "smallint", IsNullable: "NO", ColumnDefault: "0"},
+	},
+	{
+		def:  `"is_public" SMALLINT DEFAULT 1 NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "is_public", DataType: "smallint", IsNullable: "NO", ColumnDefault: "1"},
+	},
+	{
+		def:  `"status" VARCHAR(36) DEFAULT 'init' NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "status", DataType: "character varying", IsNullable: "NO", CharacterMaximumLength: 36, ColumnDefault: "'init'::character varying"},
+	},
+	{
+		def:  `"size" INTEGER DEFAULT -1 NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "size", DataType: "integer", IsNullable: "NO", ColumnDefault: "'-1'::integer"},
+	},
+	{
+		def:  `"ratio" DOUBLE PRECISION`,
+		info: sSqlColumnInfo{ColumnName: "ratio", DataType: "double precision", IsNullable: "YES"},
+	},
+	{
+		def:  `"price" NUMERIC(10, 2)`,
+		info: sSqlColumnInfo{ColumnName: "price", DataType: "numeric", IsNullable: "YES", NumericPrecision: 10, NumericScale: 2},
+	},
+	{
+		def:  `"ip" VARCHAR(16)`,
+		info: sSqlColumnInfo{ColumnName: "ip", DataType: "character varying", IsNullable: "YES", CharacterMaximumLength: 16},
+	},
+	{
+		def:  `"metadata" TEXT`,
+		info: sSqlColumnInfo{ColumnName: "metadata", DataType: "text", IsNullable: "YES"},
+	},
+	{
+		def:  `"tags" TEXT`,
+		info: sSqlColumnInfo{ColumnName: "tags", DataType: "text", IsNullable: "YES"},
+	},
+	{
+		def:  `"created_at" TIMESTAMP(0) NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "created_at", DataType: "timestamp without time zone", IsNullable: "NO"},
+	},
+	{
+		def:  `"update_version" INTEGER DEFAULT 0 NOT NULL`,
+		info: sSqlColumnInfo{ColumnName: "update_version", DataType: "integer", IsNullable: "NO", ColumnDefault: "0"},
+	},
+	{
+		def:  `"deleted_at" TIMESTAMP(0)`,
+		info: sSqlColumnInfo{ColumnName: "deleted_at", DataType: "timestamp without time zone", IsNullable: "YES"},
+	},
+}
+
+func TestColumnDefinitions(t *testing.T) {
+	ts := newTestTableSpec("test_pg_column_definitions")
+	cols := ts.Columns()
+	if len(cols) != len(testPgColumns) {
+		t.Fatalf("got %d columns, want %d", len(cols), len(testPgColumns))
+	}
+	for i, c := range testPgColumns {
+		if got := cols[i].DefinitionString(); got != c.def {
+			t.Errorf("column %d definition\n got: %s\nwant: %s", i, got, c.def)
+		}
+		// the column fetched from information_schema should be identical, so that sync does nothing
+		info := c.info
+		info.TableName = ts.Name()
+		spec := info.toColumnSpec()
+		if spec == nil {
+			t.Errorf("%s: unsupported column info", c.info.ColumnName)
+			continue
+		}
+		if got := spec.DefinitionString(); got != c.def || spec.IsPrimary() != cols[i].IsPrimary() {
+			t.Errorf("fetched column %s definition\n got: %s primary %v\nwant: %s primary %v", c.info.ColumnName, got, spec.IsPrimary(), c.def, cols[i].IsPrimary())
+		}
+	}
+	if got := getAutoIncrementColumn(ts.Name()); got != "id" {
+		t.Errorf("auto increment column = %q, want id", got)
+	}
+}
+
+func TestGetCreateSQLs(t *testing.T) {
+	ts := newTestTableSpec("test_pg_create")
+	sqls := testBackend.GetCreateSQLs(ts)
+	if len(sqls) != 2 {
+		t.Fatalf("got %d sqls, want 2: %v", len(sqls), sqls)
+	}
+	want := `CREATE TABLE IF NOT EXISTS "test_pg_create" (` + testPgColumns[0].def
+	if sqls[0][:len(want)] != want {
+		t.Errorf("create table sql %s should start with %s", sqls[0], want)
+	}
+	wantSuffix := `, PRIMARY KEY ("id"))`
+	if sqls[0][len(sqls[0])-len(wantSuffix):] != wantSuffix {
+		t.Errorf("create table sql %s should end with %s", sqls[0], wantSuffix)
+	}
+	wantIndex := `CREATE INDEX IF NOT EXISTS "ix_test_pg_create_name" ON "test_pg_create" ("name")`
+	if sqls[1] != wantIndex {
+		t.Errorf("create index sql\n got: %s\nwant: %s", sqls[1], wantIndex)
+	}
+}
+
+func TestCommitTableChangeSQL(t *testing.T) {
+	ts := newTestTableSpec("test_pg_alter")
+	newCol := func(info sSqlColumnInfo) sqlchemy.IColumnSpec {
+		info.TableName = ts.Name()
+		return info.toColumnSpec()
+	}
+	oldStatus := newCol(sSqlColumnInfo{ColumnName: "status", DataType: "character varying", IsNullable: "YES", CharacterMaximumLength: 16})
+	newStatus := newCol(sSqlColumnInfo{ColumnName: "status", DataType: "text", IsNullable: "NO", ColumnDefault: "'init'::text"})
+	oldSize := newCol(sSqlColumnInfo{ColumnName: "size", DataType: "integer", IsNullable: "NO", ColumnDefault: "0"})
+	newSize := newCol(sSqlColumnInfo{ColumnName: "size", DataType: "bigint", IsNullable: "YES"})
+	removed := newCol(sSqlColumnInfo{ColumnName: "extra", DataType: "integer", IsNullable: "NO"})
+	added := newCol(sSqlColumnInfo{ColumnName: "ratio", DataType: "double precision", IsNullable: "YES"})
+
+	sqls := testBackend.CommitTableChangeSQL(ts, sqlchemy.STableChanges{
+		RemoveColumns: []sqlchemy.IColumnSpec{removed},
+		UpdatedColumns: []sqlchemy.SUpdateColumnSpec{
+			{OldCol: oldStatus, NewCol: newStatus},
+			{OldCol: oldSize, NewCol: newSize},
+		},
+		AddColumns: []sqlchemy.IColumnSpec{added},
+	})
+	want := []string{
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "extra" DROP NOT NULL`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "status" TYPE TEXT USING "status"::TEXT`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "status" SET DEFAULT 'init'`,
+		`UPDATE "test_pg_alter" SET "status" = 'init' WHERE "status" IS NULL`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "status" SET NOT NULL`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "size" DROP DEFAULT`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "size" TYPE BIGINT USING "size"::BIGINT`,
+		`ALTER TABLE "test_pg_alter" ALTER COLUMN "size" DROP NOT NULL`,
+		`ALTER TABLE "test_pg_alter" ADD COLUMN "ratio" DOUBLE PRECISION`,
+	}
+	if !reflect.DeepEqual(sqls, want) {
+		t.Errorf("CommitTableChangeSQL\n got: %#v\nwant: %#v", sqls, want)
+	}
+}
+
+func TestPrepareInsertOrUpdateSQL(t *testing.T) {
+	ts := newTestTableSpec("test_pg_upsert")
+	sql, vals := testBackend.PrepareInsertOrUpdateSQL(ts,
+		[]string{`"id"`, `"name"`, `"update_version"`},
+		[]string{"?", "?", "?"},
+		[]string{`"id"`},
+		[]string{`"name" = ?`, `"update_version" = "update_version" + 1`},
+		[]interface{}{1, "a", 0},
+		[]interface{}{"a"},
+	)
+	want := `INSERT INTO "test_pg_upsert" ("id", "name", "update_version") VALUES (?, ?, ?) ON CONFLICT ("id") DO UPDATE SET "name" = ?, "update_version" = "test_pg_upsert"."update_version" + 1`
+	if sql != want {
+		t.Errorf("upsert sql\n got: %s\nwant: %s", sql, want)
+	}
+	if len(vals) != 4 {
+		t.Errorf("upsert values %v should have 4 values", vals)
+	}
+}
+
+func TestParseColumnDefault(t *testing.T) {
+	cases := map[string]string{
+		"":                                 "",
+		"NULL::character varying":          "",
+		"''::character varying":            "",
+		"'it''s'::text":                    "it's",
+		"'a::b'::character varying(16)":    "a::b",
+		"'-1'::integer":                    "-1",
+		"(-1)":                             "-1",
+		"0":                                "0",
+		"0.5":                              "0.5",
+		"'2023-01-02 00:00:00'::timestamp": "2023-01-02 00:00:00",
+	}
+	for in, want := range cases {
+		if got := parseColumnDefault(in); got != want {
+			t.Errorf("parseColumnDefault(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestToCharFormat(t *testing.T) {
+	cases := map[string]string{
+		"%Y-%m-%d %h-00-00": `YYYY-MM-DD HH12-"00"-"00"`,
+		"%Y-%m-%d":          "YYYY-MM-DD",
+		"%Y-%m":             "YYYY-MM",
+		"%Y":                "YYYY",
+		"%H:%i:%s.%f":       "HH24:MI:SS.US",
+		"%Y year %%":        `YYYY "year" "%"`,
+	}
+	for in, want := range cases {
+		if got := toCharFormat(in); got != want {
+			t.Errorf("toCharFormat(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
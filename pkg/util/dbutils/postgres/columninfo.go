@@ -0,0 +1,210 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+)
+
+type sSqlColumnInfo struct {
+	TableName              string `json:"table_name"`
+	ColumnName             string `json:"column_name"`
+	DataType               string `json:"data_type"`
+	IsNullable             string `json:"is_nullable"`
+	CharacterMaximumLength int    `json:"character_maximum_length"`
+	NumericPrecision       int    `json:"numeric_precision"`
+	NumericScale           int    `json:"numeric_scale"`
+	DatetimePrecision      int    `json:"datetime_precision"`
+	ColumnDefault          string `json:"column_default"`
+	IsIdentity             string `json:"is_identity"`
+	IdentityStart          string `json:"identity_start"`
+	IsPrimary              bool   `json:"is_primary"`
+}
+
+func fetchTableColInfo(ts sqlchemy.ITableSpec) ([]sSqlColumnInfo, error) {
+	sqlStr := fmt.Sprintf("SELECT column_name, data_type, is_nullable, character_maximum_length, numeric_precision, numeric_scale, datetime_precision, column_default, is_identity, identity_start FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s' ORDER BY ordinal_position", ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "column_name", "data_type", "is_nullable", "character_maximum_length", "numeric_precision", "numeric_scale", "datetime_precision", "column_default", "is_identity", "identity_start")
+	infos := make([]sSqlColumnInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, errors.Wrap(err, "query")
+	}
+
+	indexes, err := fetchTableIndexes(ts)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetchTableIndexes")
+	}
+	primaries := make(map[string]bool)
+	for _, idx := range indexes {
+		if idx.isPrimary {
+			for _, col := range idx.colnames {
+				primaries[col] = true
+			}
+			break
+		}
+	}
+
+	for i := range infos {
+		infos[i].TableName = ts.Name()
+		infos[i].IsPrimary = primaries[infos[i].ColumnName]
+	}
+	return infos, nil
+}
+
+type sPostgreSQLTableIndex struct {
+	isPrimary bool
+	indexName string
+	colnames  []string
+}
+
+func fetchTableIndexes(ts sqlchemy.ITableSpec) (map[string]sPostgreSQLTableIndex, error) {
+	type sIndexInfo struct {
+		IndexName  string `json:"index_name"`
+		ColumnName string `json:"column_name"`
+		IsPrimary  bool   `json:"is_primary"`
+	}
+	sqlStr := fmt.Sprintf(`SELECT i.relname AS index_name, a.attname AS column_name, x.indisprimary::int AS is_primary FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid JOIN pg_attribute a ON a.attrelid = x.indrelid AND a.attnum = ANY(x.indkey) WHERE x.indrelid = to_regclass('"%s"')`, ts.Name())
+	query := ts.Database().NewRawQuery(sqlStr, "index_name", "column_name", "is_primary")
+	infos := make([]sIndexInfo, 0)
+	err := query.All(&infos)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]sPostgreSQLTableIndex)
+	for _, info := range infos {
+		if idx, ok := ret[info.IndexName]; ok {
+			idx.colnames = append(idx.colnames, info.ColumnName)
+			ret[info.IndexName] = idx
+		} else {
+			ret[info.IndexName] = sPostgreSQLTableIndex{
+				isPrimary: info.IsPrimary,
+				indexName: info.IndexName,
+				colnames:  []string{info.ColumnName},
+			}
+		}
+	}
+	return ret, nil
+}
+
+func fetchPrimaryKeyName(ts sqlchemy.ITableSpec) string {
+	indexes, err := fetchTableIndexes(ts)
+	if err != nil {
+		log.Errorf("fetchTableIndexes of %s fail %s", ts.Name(), err)
+	}
+	for _, idx := range indexes {
+		if idx.isPrimary {
+			return idx.indexName
+		}
+	}
+	// the name given by postgres to the primary key declared in CREATE TABLE
+	return fmt.Sprintf("%s_pkey", ts.Name())
+}
+
+var castSuffix = regexp.MustCompile(`^(.*)::[a-zA-Z_ ]+(\([0-9, ]*\))?(\[\])?$`)
+
+// parseColumnDefault converts the column_default expression of information_schema,
+// e.g. 'abc'::character varying or (-1), to the value as of the default tag
+func parseColumnDefault(def string) string {
+	def = strings.TrimSpace(def)
+	if len(def) == 0 || strings.HasPrefix(strings.ToUpper(def), "NULL") {
+		return ""
+	}
+	for {
+		if m := castSuffix.FindStringSubmatch(def); m != nil {
+			def = strings.TrimSpace(m[1])
+		} else if strings.HasPrefix(def, "(") && strings.HasSuffix(def, ")") {
+			def = strings.TrimSpace(def[1 : len(def)-1])
+		} else {
+			break
+		}
+	}
+	if len(def) >= 2 && strings.HasPrefix(def, "'") && strings.HasSuffix(def, "'") {
+		def = strings.ReplaceAll(def[1:len(def)-1], "''", "'")
+	}
+	return def
+}
+
+func (info *sSqlColumnInfo) toColumnSpec() sqlchemy.IColumnSpec {
+	tagmap := make(map[string]string)
+
+	nullable := info.IsNullable == "YES"
+	if nullable {
+		tagmap[sqlchemy.TAG_NULLABLE] = "true"
+	} else {
+		tagmap[sqlchemy.TAG_NULLABLE] = "false"
+	}
+	if info.IsPrimary {
+		tagmap[sqlchemy.TAG_PRIMARY] = "true"
+	} else {
+		tagmap[sqlchemy.TAG_PRIMARY] = "false"
+	}
+	def := parseColumnDefault(info.ColumnDefault)
+	if len(def) > 0 {
+		tagmap[sqlchemy.TAG_DEFAULT] = def
+	}
+	switch info.DataType {
+	case "character varying", "character":
+		sqltype := "TEXT"
+		if info.CharacterMaximumLength > 0 {
+			sqltype = "VARCHAR"
+			tagmap[sqlchemy.TAG_WIDTH] = fmt.Sprintf("%d", info.CharacterMaximumLength)
+		}
+		c := NewTextColumn(info.ColumnName, sqltype, tagmap, false)
+		return &c
+	case "text":
+		c := NewTextColumn(info.ColumnName, "TEXT", tagmap, false)
+		return &c
+	case "smallint":
+		if nullable {
+			// tristate is always nullable
+			delete(tagmap, sqlchemy.TAG_NULLABLE)
+			c := NewTristateColumn(info.TableName, info.ColumnName, tagmap, false)
+			return &c
+		}
+		c := NewBooleanColumn(info.ColumnName, tagmap, def == "1")
+		return &c
+	case "integer", "bigint":
+		if info.IsIdentity == "YES" {
+			if len(info.IdentityStart) > 0 {
+				tagmap[sqlchemy.TAG_AUTOINCREMENT] = info.IdentityStart
+			} else {
+				tagmap[sqlchemy.TAG_AUTOINCREMENT] = "true"
+			}
+		}
+		c := NewIntegerColumn(info.ColumnName, strings.ToUpper(info.DataType), tagmap, false)
+		return &c
+	case "real", "double precision":
+		c := NewFloatColumn(info.ColumnName, strings.ToUpper(info.DataType), tagmap, false)
+		return &c
+	case "numeric":
+		tagmap[sqlchemy.TAG_WIDTH] = fmt.Sprintf("%d", info.NumericPrecision)
+		tagmap[sqlchemy.TAG_PRECISION] = fmt.Sprintf("%d", info.NumericScale)
+		c := NewDecimalColumn(info.ColumnName, tagmap, false)
+		return &c
+	case "timestamp without time zone", "timestamp with time zone":
+		tagmap[sqlchemy.TAG_PRECISION] = fmt.Sprintf("%d", info.DatetimePrecision)
+		c := NewDateTimeColumn(info.ColumnName, tagmap, false)
+		return &c
+	default:
+		log.Errorf("unsupported column data type %s", info.DataType)
+		return nil
+	}
+}
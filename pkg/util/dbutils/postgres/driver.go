@@ -0,0 +1,459 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// DriverName is the name of database/sql driver to use with the PostgreSQL backend of sqlchemy,
+// it wraps lib/pq and translates the SQL generated by sqlchemy, which is in the dialect of MySQL,
+// e.g. placeholders of ?, boolean literals of 1 and 0, and LastInsertId of inserts
+const DriverName = "postgres_sqlchemy"
+
+func init() {
+	sql.Register(DriverName, &sDriver{})
+}
+
+type iPqConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+type iPqStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+type sDriver struct{}
+
+func (d *sDriver) Open(name string) (driver.Conn, error) {
+	conn, err := pq.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	pqConn, ok := conn.(iPqConn)
+	if !ok {
+		conn.Close()
+		return nil, errors.Errorf("unsupported connection %T", conn)
+	}
+	return &sConn{conn: pqConn}, nil
+}
+
+type sConn struct {
+	conn iPqConn
+}
+
+func (c *sConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query, returning := rewriteSQL(query)
+	stmt, err := c.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	pqStmt, ok := stmt.(iPqStmt)
+	if !ok {
+		stmt.Close()
+		return nil, errors.Errorf("unsupported statement %T", stmt)
+	}
+	return &sStmt{stmt: pqStmt, returning: returning}, nil
+}
+
+func (c *sConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *sConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	query, returning := rewriteSQL(query)
+	if returning {
+		rows, err := c.conn.QueryContext(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return fetchReturningResult(rows)
+	}
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *sConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, _ = rewriteSQL(query)
+	rows, err := c.conn.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sRows{Rows: rows}, nil
+}
+
+func (c *sConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
+
+func (c *sConn) ResetSession(ctx context.Context) error {
+	return c.conn.ResetSession(ctx)
+}
+
+func (c *sConn) IsValid() bool {
+	return c.conn.IsValid()
+}
+
+// CheckNamedValue converts the arguments to what the columns of the backend store,
+// as lib/pq sends all arguments as untyped text
+func (c *sConn) CheckNamedValue(nv *driver.NamedValue) error {
+	val, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	switch v := val.(type) {
+	case bool:
+		// boolean columns are SMALLINT
+		val = boolToInt(v)
+	case time.Time:
+		// the time columns are TIMESTAMP WITHOUT TIME ZONE of UTC
+		val = v.UTC()
+	case []byte:
+		// otherwise sent as bytea
+		val = string(v)
+	}
+	nv.Value = val
+	return nil
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type sStmt struct {
+	stmt iPqStmt
+
+	// the statement is an INSERT appended with RETURNING the identity column
+	returning bool
+}
+
+func (s *sStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *sStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *sStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.returning {
+		rows, err := s.stmt.Query(args)
+		if err != nil {
+			return nil, err
+		}
+		return fetchReturningResult(rows)
+	}
+	return s.stmt.Exec(args)
+}
+
+func (s *sStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.returning {
+		rows, err := s.stmt.QueryContext(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return fetchReturningResult(rows)
+	}
+	return s.stmt.ExecContext(ctx, args)
+}
+
+func (s *sStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, err := s.stmt.Query(args)
+	if err != nil {
+		return nil, err
+	}
+	return &sRows{Rows: rows}, nil
+}
+
+func (s *sStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := s.stmt.QueryContext(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return &sRows{Rows: rows}, nil
+}
+
+// sRows converts the boolean values, e.g. of the expressions, to 1 or 0 as MySQL does,
+// sqlchemy takes any string but "0" as true
+type sRows struct {
+	driver.Rows
+}
+
+func (r *sRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil {
+		return err
+	}
+	for i := range dest {
+		if b, ok := dest[i].(bool); ok {
+			dest[i] = boolToInt(b)
+		}
+	}
+	return nil
+}
+
+type sReturningResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r *sReturningResult) LastInsertId() (int64, error) {
+	if r.rowsAffected == 0 {
+		return 0, errors.Wrap(errors.ErrNotFound, "no row inserted")
+	}
+	return r.lastInsertId, nil
+}
+
+func (r *sReturningResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func fetchReturningResult(rows driver.Rows) (driver.Result, error) {
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	result := &sReturningResult{}
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if result.rowsAffected == 0 && len(dest) > 0 {
+			result.lastInsertId, _ = dest[0].(int64)
+		}
+		result.rowsAffected++
+	}
+	return result, nil
+}
+
+type tokenType int
+
+const (
+	tokenSpace tokenType = iota
+	tokenWord
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenParam
+	tokenComment
+	tokenOther
+)
+
+type sToken struct {
+	typ  tokenType
+	text string
+}
+
+// tokenize splits the SQL into tokens, so that the rewrite never touches the string literals,
+// the quoted identifiers and the comments
+func tokenize(query string) []sToken {
+	tokens := make([]sToken, 0)
+	isWordChar := func(b byte) bool {
+		return b == '_' || b == '$' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80
+	}
+	// quotedEnd returns the end of the text quoted by q starting at i, a doubled q is escaped
+	quotedEnd := func(i int, q byte) int {
+		for j := i + 1; j < len(query); j++ {
+			if query[j] == q {
+				if j+1 < len(query) && query[j+1] == q {
+					j++
+					continue
+				}
+				return j + 1
+			}
+		}
+		return len(query)
+	}
+	for i := 0; i < len(query); {
+		b := query[i]
+		j := i + 1
+		typ := tokenOther
+		switch {
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			for j < len(query) && (query[j] == ' ' || query[j] == '\t' || query[j] == '\n' || query[j] == '\r') {
+				j++
+			}
+			typ = tokenSpace
+		case b == '\'':
+			j = quotedEnd(i, '\'')
+			typ = tokenString
+		case b == '"' || b == '`':
+			j = quotedEnd(i, b)
+			typ = tokenIdent
+		case b == '-' && strings.HasPrefix(query[i:], "--"):
+			j = strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query)
+			} else {
+				j += i + 1
+			}
+			typ = tokenComment
+		case b == '/' && strings.HasPrefix(query[i:], "/*"):
+			j = strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				j = len(query)
+			} else {
+				j += i + 4
+			}
+			typ = tokenComment
+		case b == '?':
+			typ = tokenParam
+		case b >= '0' && b <= '9':
+			for j < len(query) && ((query[j] >= '0' && query[j] <= '9') || query[j] == '.') {
+				j++
+			}
+			typ = tokenNumber
+		case isWordChar(b):
+			for j < len(query) && isWordChar(query[j]) {
+				j++
+			}
+			typ = tokenWord
+		}
+		tokens = append(tokens, sToken{typ: typ, text: query[i:j]})
+		i = j
+	}
+	return tokens
+}
+
+// the keywords followed by a condition
+var conditionKeywords = map[string]bool{
+	"WHERE":  true,
+	"ON":     true,
+	"HAVING": true,
+	"AND":    true,
+	"OR":     true,
+	"NOT":    true,
+	"WHEN":   true,
+}
+
+// isBooleanLiteral tells whether the number token at i is a condition, e.g. the "1" of WHERE 1 or
+// the "0" of "AND (0)", which is generated by sqlchemy for the true/false conditions
+// and an empty IN, and must be a boolean for postgres
+func isBooleanLiteral(tokens []sToken, i int) bool {
+	if tokens[i].text != "0" && tokens[i].text != "1" {
+		return false
+	}
+	// sibling returns the index of the previous or next token of i, ignoring spaces and comments
+	sibling := func(i int, step int) int {
+		for j := i + step; j >= 0 && j < len(tokens); j += step {
+			if tokens[j].typ != tokenSpace && tokens[j].typ != tokenComment {
+				return j
+			}
+		}
+		return -1
+	}
+	isConditionStart := func(j int) bool {
+		return j >= 0 && tokens[j].typ == tokenWord && conditionKeywords[strings.ToUpper(tokens[j].text)]
+	}
+	prev, next := sibling(i, -1), sibling(i, 1)
+	if prev < 0 {
+		return false
+	}
+	if tokens[prev].text == "(" && next >= 0 && tokens[next].text == ")" {
+		// AND (0)
+		prevPrev := sibling(prev, -1)
+		return prevPrev < 0 || tokens[prevPrev].text == "(" || isConditionStart(prevPrev)
+	}
+	// WHERE 1 ORDER BY ...
+	return isConditionStart(prev) && (next < 0 || tokens[next].text == ")" || tokens[next].typ == tokenWord)
+}
+
+var insertIntoRegexp = regexp.MustCompile(`(?i)^\s*INSERT\s+INTO\s+"([^"]+)"`)
+
+// rewriteSQL translates the SQL generated by sqlchemy to the dialect of postgres,
+// returns the new SQL and whether RETURNING the identity column is appended to an INSERT
+func rewriteSQL(query string) (string, bool) {
+	tokens := tokenize(query)
+	var buf strings.Builder
+	params := 0
+	hasReturning := false
+	for i, tok := range tokens {
+		switch tok.typ {
+		case tokenParam:
+			params++
+			fmt.Fprintf(&buf, "$%d", params)
+		case tokenIdent:
+			if tok.text[0] == '`' {
+				buf.WriteByte('"')
+				buf.WriteString(strings.Trim(tok.text, "`"))
+				buf.WriteByte('"')
+			} else {
+				buf.WriteString(tok.text)
+			}
+		case tokenNumber:
+			if isBooleanLiteral(tokens, i) {
+				if tok.text == "1" {
+					buf.WriteString("TRUE")
+				} else {
+					buf.WriteString("FALSE")
+				}
+			} else {
+				buf.WriteString(tok.text)
+			}
+		case tokenWord:
+			if strings.EqualFold(tok.text, "RETURNING") {
+				hasReturning = true
+			}
+			buf.WriteString(tok.text)
+		default:
+			buf.WriteString(tok.text)
+		}
+	}
+	query = buf.String()
+	if hasReturning {
+		return query, false
+	}
+	if m := insertIntoRegexp.FindStringSubmatch(query); m != nil {
+		if col := getAutoIncrementColumn(m[1]); len(col) > 0 {
+			return fmt.Sprintf(`%s RETURNING "%s"`, strings.TrimRight(strings.TrimSpace(query), ";"), col), true
+		}
+	}
+	return query, false
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func TestRewriteSQL(t *testing.T) {
+	autoIncrementColumns.Store("test_rewrite_logs", "id")
+	defer autoIncrementColumns.Delete("test_rewrite_logs")
+
+	cases := []struct {
+		in        string
+		want      string
+		returning bool
+	}{
+		{
+			in:   `SELECT "t1"."id" FROM "guests" AS "t1" WHERE ("t1"."name" = ?) AND ("t1"."deleted" = ?) LIMIT 20 OFFSET 10`,
+			want: `SELECT "t1"."id" FROM "guests" AS "t1" WHERE ("t1"."name" = $1) AND ("t1"."deleted" = $2) LIMIT 20 OFFSET 10`,
+		},
+		{
+			// empty IN and the false condition
+			in:   `SELECT * FROM "guests" AS "t1" WHERE ("t1"."deleted" = 0) AND (0)`,
+			want: `SELECT * FROM "guests" AS "t1" WHERE ("t1"."deleted" = 0) AND (FALSE)`,
+		},
+		{
+			in:   `SELECT * FROM "guests" AS "t1" WHERE NOT (1) OR ((0) AND ("t1"."id" IN (1)))`,
+			want: `SELECT * FROM "guests" AS "t1" WHERE NOT (TRUE) OR ((FALSE) AND ("t1"."id" IN (1)))`,
+		},
+		{
+			in:   `SELECT * FROM "guests" AS "t1" JOIN "hosts" AS "t2" ON 1 WHERE 0 ORDER BY "t1"."id"`,
+			want: `SELECT * FROM "guests" AS "t1" JOIN "hosts" AS "t2" ON TRUE WHERE FALSE ORDER BY "t1"."id"`,
+		},
+		{
+			in:   `SELECT COUNT(1), SUM(CASE WHEN (1) THEN 1 ELSE 0 END) FROM "guests" AS "t1"`,
+			want: `SELECT COUNT(1), SUM(CASE WHEN (TRUE) THEN 1 ELSE 0 END) FROM "guests" AS "t1"`,
+		},
+		{
+			// literals and identifiers are untouched, backticks are quoted
+			in:   "SELECT `name` FROM `guests` WHERE `description` = 'is ? (1)' AND \"what?\" = ? -- where 1\n",
+			want: "SELECT \"name\" FROM \"guests\" WHERE \"description\" = 'is ? (1)' AND \"what?\" = $1 -- where 1\n",
+		},
+		{
+			in:        `INSERT INTO "test_rewrite_logs" ("obj_id", "notes") VALUES (?, ?)`,
+			want:      `INSERT INTO "test_rewrite_logs" ("obj_id", "notes") VALUES ($1, $2) RETURNING "id"`,
+			returning: true,
+		},
+		{
+			in:   `INSERT INTO "test_rewrite_logs" ("id") VALUES (?) RETURNING "id"`,
+			want: `INSERT INTO "test_rewrite_logs" ("id") VALUES ($1) RETURNING "id"`,
+		},
+		{
+			in:   `INSERT INTO "guests" ("id", "name") VALUES (?, ?)`,
+			want: `INSERT INTO "guests" ("id", "name") VALUES ($1, $2)`,
+		},
+	}
+	for _, c := range cases {
+		got, returning := rewriteSQL(c.in)
+		if got != c.want || returning != c.returning {
+			t.Errorf("rewriteSQL(%s)\n got: %s %v\nwant: %s %v", c.in, got, returning, c.want, c.returning)
+		}
+	}
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tm := time.Date(2023, 1, 2, 11, 4, 5, 0, loc)
+	cases := []struct {
+		in   interface{}
+		want driver.Value
+	}{
+		{true, int64(1)},
+		{false, int64(0)},
+		{tm, tm.UTC()},
+		{[]byte("abc"), "abc"},
+		{uint8(3), int64(3)},
+	}
+	conn := &sConn{}
+	for _, c := range cases {
+		nv := &driver.NamedValue{Value: c.in}
+		if err := conn.CheckNamedValue(nv); err != nil {
+			t.Errorf("CheckNamedValue(%v): %v", c.in, err)
+			continue
+		}
+		if got, ok := nv.Value.(time.Time); ok {
+			if !got.Equal(c.want.(time.Time)) || got.Location() != time.UTC {
+				t.Errorf("CheckNamedValue(%v) = %v, want %v", c.in, got, c.want)
+			}
+		} else if nv.Value != c.want {
+			t.Errorf("CheckNamedValue(%v) = %#v, want %#v", c.in, nv.Value, c.want)
+		}
+	}
+}
+
+func TestIsPostgreSQL(t *testing.T) {
+	pgConn, _ := sql.Open(DriverName, "postgres://localhost/test")
+	defer pgConn.Close()
+	sqlchemy.SetDBWithNameBackend(pgConn, "test_is_pg", PostgreSQLBackend)
+	if !IsPostgreSQL("test_is_pg") {
+		t.Errorf("database opened by %s should be PostgreSQL", DriverName)
+	}
+	if IsPostgreSQL("test_no_such_db") {
+		t.Errorf("unknown database should not be PostgreSQL")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/sqlchemy"
+)
+
+func quoteString(str string) string {
+	str = strings.ReplaceAll(str, "'", "''")
+	return "'" + strings.ReplaceAll(str, "%", "%%") + "'"
+}
+
+// GROUP_CONCAT2 represents the SQL function GROUP_CONCAT
+func (pg *SPostgreSQLBackend) GROUP_CONCAT2(name string, sep string, field sqlchemy.IQueryField) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, true, fmt.Sprintf("STRING_AGG(CAST(%%s AS TEXT), %s)", quoteString(sep)), field)
+}
+
+// INET_ATON represents the SQL function INET_ATON, a string not of an IPv4 address gives NULL
+func (pg *SPostgreSQLBackend) INET_ATON(field sqlchemy.IQueryField) sqlchemy.IQueryField {
+	expr := `CASE WHEN %s ~ '^[0-9]{1,3}(\.[0-9]{1,3}){3}$' THEN `
+	vars := []sqlchemy.IQueryField{field}
+	for i := 1; i <= 4; i++ {
+		if i > 1 {
+			expr += " + "
+		}
+		expr += fmt.Sprintf("CAST(SPLIT_PART(%%s, '.', %d) AS BIGINT) * %d", i, 1<<(8*(4-i)))
+		vars = append(vars, field)
+	}
+	expr += " END"
+	return sqlchemy.NewFunctionField("", false, expr, vars...)
+}
+
+// cast field to string
+func (pg *SPostgreSQLBackend) CASTString(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS TEXT)`, field)
+}
+
+// cast field to integer
+func (pg *SPostgreSQLBackend) CASTInt(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS BIGINT)`, field)
+}
+
+// cast field to float
+func (pg *SPostgreSQLBackend) CASTFloat(field sqlchemy.IQueryField, fieldname string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(fieldname, false, `CAST(%s AS DOUBLE PRECISION)`, field)
+}
+
+// TIMESTAMPADD represents a SQL function TimestampAdd
+func (pg *SPostgreSQLBackend) TIMESTAMPADD(name string, field sqlchemy.IQueryField, offsetSeconds int) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, false, fmt.Sprintf(`(%%s + INTERVAL '%d seconds')`, offsetSeconds), field)
+}
+
+// DATE_FORMAT represents a SQL function DATE_FORMAT, the format is of MySQL
+func (pg *SPostgreSQLBackend) DATE_FORMAT(name string, field sqlchemy.IQueryField, format string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, false, fmt.Sprintf(`TO_CHAR(%%s, %s)`, quoteString(toCharFormat(format))), field)
+}
+
+// REPLACE represents the SQL function REPLACE
+func (pg *SPostgreSQLBackend) REPLACE(name string, field sqlchemy.IQueryField, old string, new string) sqlchemy.IQueryField {
+	return sqlchemy.NewFunctionField(name, false, fmt.Sprintf(`REPLACE(%%s, %s, %s)`, quoteString(old), quoteString(new)), field)
+}
+
+// DATEDIFF represents SQL function of DATEDIFF, the difference of field2 - field1 in unit
+func (pg *SPostgreSQLBackend) DATEDIFF(unit string, field1, field2 sqlchemy.IQueryField) sqlchemy.IQueryField {
+	seconds := 1
+	switch strings.ToLower(unit) {
+	case "minute":
+		seconds = 60
+	case "hour":
+		seconds = 3600
+	case "day":
+		seconds = 86400
+	case "week":
+		seconds = 86400 * 7
+	}
+	return sqlchemy.NewFunctionField("", false, fmt.Sprintf(`FLOOR(EXTRACT(EPOCH FROM (%%s - %%s)) / %d)`, seconds), field2, field1)
+}
+
+// mysqlDateFormats maps the specifiers of MySQL DATE_FORMAT to the template patterns of TO_CHAR
+var mysqlDateFormats = map[byte]string{
+	'Y': "YYYY",
+	'y': "YY",
+	'm': "MM",
+	'c': "FMMM",
+	'd': "DD",
+	'e': "FMDD",
+	'H': "HH24",
+	'k': "FMHH24",
+	'h': "HH12",
+	'I': "HH12",
+	'l': "FMHH12",
+	'i': "MI",
+	's': "SS",
+	'S': "SS",
+	'f': "US",
+	'p': "AM",
+	'j': "DDD",
+	'M': "FMMonth",
+	'b': "Mon",
+	'W': "FMDay",
+	'a': "Dy",
+	'T': "HH24:MI:SS",
+}
+
+// toCharFormat converts a MySQL DATE_FORMAT format to a TO_CHAR format,
+// the literal text is double quoted so that it is not taken as a template pattern
+func toCharFormat(format string) string {
+	var out strings.Builder
+	var literal strings.Builder
+	flushLiteral := func() {
+		if literal.Len() > 0 {
+			out.WriteByte('"')
+			out.WriteString(literal.String())
+			out.WriteByte('"')
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			i++
+			if pattern, ok := mysqlDateFormats[format[i]]; ok {
+				flushLiteral()
+				out.WriteString(pattern)
+			} else {
+				literal.WriteByte(format[i])
+			}
+			continue
+		}
+		switch format[i] {
+		case '-', ':', ' ', '/', '.', ',':
+			flushLiteral()
+			out.WriteByte(format[i])
+		case '"':
+			literal.WriteString(`\"`)
+		default:
+			literal.WriteByte(format[i])
+		}
+	}
+	flushLiteral()
+	return out.String()
+}
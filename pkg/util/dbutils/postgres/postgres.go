@@ -0,0 +1,287 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/tristate"
+	"yunion.io/x/pkg/util/regutils"
+	"yunion.io/x/pkg/utils"
+	"yunion.io/x/sqlchemy"
+)
+
+// PostgreSQLBackend is the backend name of PostgreSQL
+const PostgreSQLBackend = sqlchemy.DBBackendName("PostgreSQL")
+
+func init() {
+	sqlchemy.RegisterBackend(&SPostgreSQLBackend{})
+}
+
+// IsPostgreSQL returns whether the database of the name is connected by the driver of this package
+func IsPostgreSQL(name sqlchemy.DBName) bool {
+	db := sqlchemy.GetDBWithName(name)
+	if db == nil || db.DB() == nil {
+		return false
+	}
+	_, ok := db.DB().Driver().(*sDriver)
+	return ok
+}
+
+// autoIncrementColumns records the identity column of each table, the driver
+// returns the value of the column as LastInsertId after insert
+var autoIncrementColumns sync.Map
+
+func getAutoIncrementColumn(table string) string {
+	if col, ok := autoIncrementColumns.Load(table); ok {
+		return col.(string)
+	}
+	return ""
+}
+
+type SPostgreSQLBackend struct {
+	sqlchemy.SBaseBackend
+}
+
+func (pg *SPostgreSQLBackend) Name() sqlchemy.DBBackendName {
+	return PostgreSQLBackend
+}
+
+// CanUpdate returns wether the backend supports update
+func (pg *SPostgreSQLBackend) CanUpdate() bool {
+	return true
+}
+
+// CanInsert returns wether the backend supports Insert
+func (pg *SPostgreSQLBackend) CanInsert() bool {
+	return true
+}
+
+func (pg *SPostgreSQLBackend) CanSupportRowAffected() bool {
+	return true
+}
+
+// CanInsertOrUpdate returns weather the backend supports InsertOrUpdate
+func (pg *SPostgreSQLBackend) CanInsertOrUpdate() bool {
+	return true
+}
+
+func (pg *SPostgreSQLBackend) InsertOrUpdateSQLTemplate() string {
+	// use PrepareInsertOrUpdateSQL instead
+	return ""
+}
+
+func (pg *SPostgreSQLBackend) DropIndexSQLTemplate() string {
+	return `DROP INDEX "{{ .Index }}"`
+}
+
+func (pg *SPostgreSQLBackend) InsertSQLTemplate() string {
+	return `INSERT INTO "{{ .Table }}" ({{ .Columns }}) VALUES ({{ .Values }})`
+}
+
+func (pg *SPostgreSQLBackend) UpdateSQLTemplate() string {
+	return `UPDATE "{{ .Table }}" SET {{ .Columns }} WHERE {{ .Conditions }}`
+}
+
+func (pg *SPostgreSQLBackend) PrepareInsertOrUpdateSQL(ts sqlchemy.ITableSpec, insertColNames []string, insertFields []string, onPrimaryCols []string, updateSetCols []string, insertValues []interface{}, updateValues []interface{}) (string, []interface{}) {
+	sqlTemp := `INSERT INTO "{{ .Table }}" ({{ .Columns }}) VALUES ({{ .Values }}) ON CONFLICT ({{ .PrimaryKeys }}) DO UPDATE SET {{ .SetValues }}`
+	if len(updateSetCols) == 0 {
+		sqlTemp = `INSERT INTO "{{ .Table }}" ({{ .Columns }}) VALUES ({{ .Values }}) ON CONFLICT ({{ .PrimaryKeys }}) DO NOTHING`
+	}
+	setValues := make([]string, 0, len(updateSetCols))
+	for _, setCol := range updateSetCols {
+		// the columns referred by the new value, e.g. "update_version" = "update_version" + 1,
+		// are ambiguous with the EXCLUDED row, qualify them with the table name
+		equalPos := strings.Index(setCol, "=")
+		key := strings.TrimSpace(setCol[0:equalPos])
+		val := strings.TrimSpace(setCol[equalPos+1:])
+		val = strings.ReplaceAll(val, key, fmt.Sprintf(`"%s".%s`, ts.Name(), key))
+		setValues = append(setValues, fmt.Sprintf("%s = %s", key, val))
+	}
+	values := make([]interface{}, 0, len(insertValues)+len(updateValues))
+	values = append(values, insertValues...)
+	values = append(values, updateValues...)
+	sql := sqlchemy.TemplateEval(sqlTemp, struct {
+		Table       string
+		Columns     string
+		Values      string
+		PrimaryKeys string
+		SetValues   string
+	}{
+		Table:       ts.Name(),
+		Columns:     strings.Join(insertColNames, ", "),
+		Values:      strings.Join(insertFields, ", "),
+		PrimaryKeys: strings.Join(onPrimaryCols, ", "),
+		SetValues:   strings.Join(setValues, ", "),
+	})
+	return sql, values
+}
+
+func (pg *SPostgreSQLBackend) GetTableSQL() string {
+	return `SELECT tablename AS "name" FROM pg_tables WHERE schemaname = current_schema()`
+}
+
+func (pg *SPostgreSQLBackend) DropTableSQL(table string) string {
+	return fmt.Sprintf(`DROP TABLE "%s"`, table)
+}
+
+func (pg *SPostgreSQLBackend) CurrentUTCTimeStampString() string {
+	return "(NOW() AT TIME ZONE 'UTC')"
+}
+
+func (pg *SPostgreSQLBackend) CurrentTimeStampString() string {
+	return "LOCALTIMESTAMP"
+}
+
+func (pg *SPostgreSQLBackend) CaseInsensitiveLikeString() string {
+	return "ILIKE"
+}
+
+func (pg *SPostgreSQLBackend) RegexpWhereClause(cond *sqlchemy.SRegexpConition) string {
+	return fmt.Sprintf("%s ~ %s", cond.GetLeft().Reference(), sqlchemy.VarConditionWhereClause(cond.GetRight()))
+}
+
+func (pg *SPostgreSQLBackend) GetCreateSQLs(ts sqlchemy.ITableSpec) []string {
+	cols := make([]string, 0)
+	primaries := make([]string, 0)
+	for _, c := range ts.Columns() {
+		cols = append(cols, c.DefinitionString())
+		if c.IsPrimary() {
+			primaries = append(primaries, fmt.Sprintf(`"%s"`, c.Name()))
+		}
+	}
+	if len(primaries) > 0 {
+		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaries, ", ")))
+	}
+	sqls := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" (%s)`, ts.Name(), strings.Join(cols, ", ")),
+	}
+	for _, idx := range ts.Indexes() {
+		sqls = append(sqls, createIndexSQL(ts, idx))
+	}
+	return sqls
+}
+
+func (pg *SPostgreSQLBackend) IsSupportIndexAndContraints() bool {
+	return true
+}
+
+func (pg *SPostgreSQLBackend) FetchTableColumnSpecs(ts sqlchemy.ITableSpec) ([]sqlchemy.IColumnSpec, error) {
+	infos, err := fetchTableColInfo(ts)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetchTableColInfo")
+	}
+	specs := make([]sqlchemy.IColumnSpec, 0)
+	for _, info := range infos {
+		specs = append(specs, info.toColumnSpec())
+	}
+	return specs, nil
+}
+
+func (pg *SPostgreSQLBackend) FetchIndexesAndConstraints(ts sqlchemy.ITableSpec) ([]sqlchemy.STableIndex, []sqlchemy.STableConstraint, error) {
+	indexes, err := fetchTableIndexes(ts)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "fetchTableIndexes")
+	}
+	retIdxes := make([]sqlchemy.STableIndex, 0)
+	for k := range indexes {
+		if indexes[k].isPrimary {
+			continue
+		}
+		retIdxes = append(retIdxes, sqlchemy.NewTableIndex(ts, indexes[k].indexName, indexes[k].colnames, false))
+	}
+	return retIdxes, nil, nil
+}
+
+func getTextSqlType(tagmap map[string]string) (string, map[string]string) {
+	var width int
+	var sqltype string
+	tagmap, widthStr, _ := utils.TagPop(tagmap, sqlchemy.TAG_WIDTH)
+	if len(widthStr) > 0 && regutils.MatchInteger(widthStr) {
+		width, _ = strconv.Atoi(widthStr)
+	}
+	if width == 0 {
+		sqltype = "TEXT"
+	} else {
+		tagmap[sqlchemy.TAG_WIDTH] = widthStr
+		sqltype = "VARCHAR"
+	}
+	return sqltype, tagmap
+}
+
+func (pg *SPostgreSQLBackend) GetColumnSpecByFieldType(table *sqlchemy.STableSpec, fieldType reflect.Type, fieldname string, tagmap map[string]string, isPointer bool) sqlchemy.IColumnSpec {
+	switch fieldType {
+	case tristate.TriStateType:
+		col := NewTristateColumn(table.Name(), fieldname, tagmap, isPointer)
+		return &col
+	case gotypes.TimeType:
+		col := NewDateTimeColumn(fieldname, tagmap, isPointer)
+		return &col
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewTextColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return newIntegerColumn(table, fieldname, "INTEGER", tagmap, isPointer)
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return newIntegerColumn(table, fieldname, "SMALLINT", tagmap, isPointer)
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return newIntegerColumn(table, fieldname, "BIGINT", tagmap, isPointer)
+	case reflect.Bool:
+		col := NewBooleanColumn(fieldname, tagmap, isPointer)
+		return &col
+	case reflect.Float32, reflect.Float64:
+		if _, ok := tagmap[sqlchemy.TAG_WIDTH]; ok {
+			col := NewDecimalColumn(fieldname, tagmap, isPointer)
+			return &col
+		}
+		colType := "REAL"
+		if fieldType == gotypes.Float64Type {
+			colType = "DOUBLE PRECISION"
+		}
+		col := NewFloatColumn(fieldname, colType, tagmap, isPointer)
+		return &col
+	case reflect.Map, reflect.Slice:
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewCompoundColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	}
+	if fieldType.Implements(gotypes.ISerializableType) {
+		sqltype, tagmap := getTextSqlType(tagmap)
+		col := NewCompoundColumn(fieldname, sqltype, tagmap, isPointer)
+		return &col
+	}
+	return nil
+}
+
+func newIntegerColumn(table *sqlchemy.STableSpec, fieldname string, sqltype string, tagmap map[string]string, isPointer bool) sqlchemy.IColumnSpec {
+	col := NewIntegerColumn(fieldname, sqltype, tagmap, isPointer)
+	if col.IsAutoIncrement() {
+		autoIncrementColumns.Store(table.Name(), col.Name())
+	}
+	return &col
+}
+
+func (pg *SPostgreSQLBackend) QuoteChar() string {
+	return "\""
+}
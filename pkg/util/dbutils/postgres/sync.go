@@ -0,0 +1,182 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"yunion.io/x/log"
+	"yunion.io/x/sqlchemy"
+)
+
+func (pg *SPostgreSQLBackend) CommitTableChangeSQL(ts sqlchemy.ITableSpec, changes sqlchemy.STableChanges) []string {
+	ret := make([]string, 0)
+
+	for _, idx := range changes.RemoveIndexes {
+		sql := fmt.Sprintf(`DROP INDEX "%s"`, idx.Name())
+		ret = append(ret, sql)
+		log.Infof("%s;", sql)
+	}
+
+	alters := make([]string, 0)
+
+	// first check if primary key is modifed
+	changePrimary := false
+	for _, col := range changes.RemoveColumns {
+		if col.IsPrimary() {
+			changePrimary = true
+			break
+		}
+	}
+	if !changePrimary {
+		for _, cols := range changes.UpdatedColumns {
+			if cols.OldCol.IsPrimary() != cols.NewCol.IsPrimary() {
+				changePrimary = true
+				break
+			}
+		}
+	}
+	if !changePrimary {
+		for _, col := range changes.AddColumns {
+			if col.IsPrimary() {
+				changePrimary = true
+				break
+			}
+		}
+	}
+	// in case of a primary key change, we first need to drop the primary key constraint,
+	// if the table has one
+	if changePrimary {
+		oldHasPrimary := false
+		for _, col := range changes.OldColumns {
+			if col.IsPrimary() {
+				oldHasPrimary = true
+				break
+			}
+		}
+		if oldHasPrimary {
+			alters = append(alters, fmt.Sprintf(`ALTER TABLE "%s" DROP CONSTRAINT "%s"`, ts.Name(), fetchPrimaryKeyName(ts)))
+		}
+	}
+
+	/* IGNORE DROP STATEMENT */
+	for _, col := range changes.RemoveColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s"`, ts.Name(), col.Name())
+		log.Debugf("skip %s", sql)
+		// ignore drop statement
+		// if the column is an identity column, then need to drop the identity,
+		// so that the insert without the column is still possible
+		if col.IsAutoIncrement() {
+			log.Errorf("column %s is IDENTITY, drop IDENTITY attribute", col.Name())
+			alters = append(alters, fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP IDENTITY IF EXISTS`, ts.Name(), col.Name()))
+		}
+		// if the column is not nullable but no default
+		// then need to drop the not-nullable attribute
+		if !col.IsNullable() && (col.Default() == "" || col.IsAutoIncrement()) {
+			log.Errorf("column %s is not nullable but no default, drop not nullable attribute", col.Name())
+			alters = append(alters, fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" DROP NOT NULL`, ts.Name(), col.Name()))
+		}
+	}
+	for _, cols := range changes.UpdatedColumns {
+		alters = append(alters, alterColumnSQLs(ts, cols.OldCol, cols.NewCol)...)
+	}
+	for _, col := range changes.AddColumns {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN %s`, ts.Name(), col.DefinitionString())
+		alters = append(alters, sql)
+	}
+	if changePrimary {
+		primaries := make([]string, 0)
+		for _, c := range ts.Columns() {
+			if c.IsPrimary() {
+				primaries = append(primaries, fmt.Sprintf(`"%s"`, c.Name()))
+			}
+		}
+		if len(primaries) > 0 {
+			sql := fmt.Sprintf(`ALTER TABLE "%s" ADD PRIMARY KEY (%s)`, ts.Name(), strings.Join(primaries, ", "))
+			alters = append(alters, sql)
+		}
+	}
+
+	if len(alters) > 0 {
+		ret = append(ret, alters...)
+		log.Infof("%s", strings.Join(alters, ";\n"))
+	}
+
+	for _, idx := range changes.AddIndexes {
+		sql := createIndexSQL(ts, idx)
+		ret = append(ret, sql)
+		log.Infof("%s", sql)
+	}
+
+	return ret
+}
+
+// alterColumnSQLs returns the statements to change oldCol to newCol, postgres has
+// no MODIFY COLUMN, each attribute of a column is altered separately
+func alterColumnSQLs(ts sqlchemy.ITableSpec, oldCol, newCol sqlchemy.IColumnSpec) []string {
+	alters := make([]string, 0)
+	alterColumn := func(format string, args ...interface{}) {
+		sql := fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" `, ts.Name(), newCol.Name())
+		alters = append(alters, sql+fmt.Sprintf(format, args...))
+	}
+
+	if oldCol.Name() != newCol.Name() {
+		// rename
+		alters = append(alters, fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s"`, ts.Name(), oldCol.Name(), newCol.Name()))
+	}
+
+	typeChanged := oldCol.ColType() != newCol.ColType()
+	if typeChanged {
+		// the old default may not be casted to the new type
+		if hasDefault(oldCol) {
+			alterColumn("DROP DEFAULT")
+		}
+		alterColumn(`TYPE %s USING "%s"::%s`, newCol.ColType(), newCol.Name(), newCol.ColType())
+	}
+
+	if oldCol.IsAutoIncrement() && !newCol.IsAutoIncrement() {
+		alterColumn("DROP IDENTITY IF EXISTS")
+	} else if !oldCol.IsAutoIncrement() && newCol.IsAutoIncrement() {
+		if hasDefault(oldCol) && !typeChanged {
+			alterColumn("DROP DEFAULT")
+		}
+		alterColumn("ADD %s", identityDefinition(newCol.AutoIncrementOffset()))
+	} else if newCol.IsAutoIncrement() && oldCol.AutoIncrementOffset() != newCol.AutoIncrementOffset() {
+		alterColumn("SET START WITH %d", newCol.AutoIncrementOffset())
+	}
+
+	if hasDefault(newCol) {
+		if typeChanged || !hasDefault(oldCol) || defaultString(oldCol) != defaultString(newCol) {
+			alterColumn("SET DEFAULT %s", defaultString(newCol))
+		}
+	} else if hasDefault(oldCol) && !typeChanged && !newCol.IsAutoIncrement() {
+		alterColumn("DROP DEFAULT")
+	}
+
+	if oldCol.IsNullable() && !newCol.IsNullable() {
+		if hasDefault(newCol) {
+			alters = append(alters, fmt.Sprintf(`UPDATE "%s" SET "%s" = %s WHERE "%s" IS NULL`, ts.Name(), newCol.Name(), defaultString(newCol), newCol.Name()))
+		}
+		alterColumn("SET NOT NULL")
+	} else if !oldCol.IsNullable() && newCol.IsNullable() {
+		alterColumn("DROP NOT NULL")
+	}
+	return alters
+}
+
+func createIndexSQL(ts sqlchemy.ITableSpec, idx sqlchemy.STableIndex) string {
+	return fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" (%s)`, idx.Name(), ts.Name(), strings.Join(idx.QuotedColumns(`"`), ", "))
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/pkg/errors"
+)
+
+const (
+	// miekg/dns does not know SVCB and HTTPS yet, they are served as RFC 3597 unknown types
+	TypeSVCB  uint16 = 64
+	TypeHTTPS uint16 = 65
+
+	DnsTypeSVCB  = "SVCB"
+	DnsTypeHTTPS = "HTTPS"
+)
+
+var (
+	caaTagRegexp     = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	naptrFlagsRegexp = regexp.MustCompile(`^[a-zA-Z0-9]*$`)
+)
+
+// StringToType returns the rrtype of dnsType, including the types unknown to miekg/dns
+func StringToType(dnsType string) (uint16, bool) {
+	switch strings.ToUpper(dnsType) {
+	case DnsTypeSVCB:
+		return TypeSVCB, true
+	case DnsTypeHTTPS:
+		return TypeHTTPS, true
+	}
+	rrtype, ok := dns.StringToType[strings.ToUpper(dnsType)]
+	return rrtype, ok
+}
+
+// NewRR builds the resource record of name from the presentation format
+// rdata, i.e. the dns_value of dnsrecords. Relative domain names in the
+// rdata are treated as fully qualified
+func NewRR(name string, ttl uint32, dnsType string, value string) (dns.RR, error) {
+	dnsType = strings.ToUpper(dnsType)
+	rrtype, ok := StringToType(dnsType)
+	if !ok {
+		return nil, errors.Wrapf(errors.ErrNotSupported, "dns type %s", dnsType)
+	}
+	hdr := dns.RR_Header{
+		Name:   dns.Fqdn(name),
+		Rrtype: rrtype,
+		Class:  dns.ClassINET,
+		Ttl:    ttl,
+	}
+	if rrtype == TypeSVCB || rrtype == TypeHTTPS {
+		rdata, err := PackSVCB(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value %q", dnsType, value)
+		}
+		return &dns.RFC3597{Hdr: hdr, Rdata: hex.EncodeToString(rdata)}, nil
+	}
+	if rrtype == dns.TypeTXT && !strings.HasPrefix(value, "\"") {
+		// unquoted values are taken verbatim as one string
+		return &dns.TXT{Hdr: hdr, Txt: splitTxt(value)}, nil
+	}
+	line := fmt.Sprintf("%s %d IN %s %s", hdr.Name, ttl, dnsType, value)
+	zp := dns.NewZoneParser(strings.NewReader(line), ".", "")
+	rr, ok := zp.Next()
+	if !ok {
+		if err := zp.Err(); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s value %q", dnsType, value)
+		}
+		return nil, errors.Wrapf(errors.ErrEmpty, "%s value", dnsType)
+	}
+	if rr.Header().Rrtype != rrtype {
+		return nil, errors.Errorf("unexpected record type %s of %s value %q", dns.TypeToString[rr.Header().Rrtype], dnsType, value)
+	}
+	return rr, nil
+}
+
+// splitTxt splits the value into character strings of at most 255 bytes
+func splitTxt(value string) []string {
+	txt := []string{}
+	for len(value) > 255 {
+		txt = append(txt, value[:255])
+		value = value[255:]
+	}
+	return append(txt, value)
+}
+
+// ValidateValue checks the presentation format rdata of CAA, NAPTR, SVCB and HTTPS records
+func ValidateValue(dnsType string, value string) error {
+	rr, err := NewRR("example.com", 0, dnsType, value)
+	if err != nil {
+		return err
+	}
+	switch r := rr.(type) {
+	case *dns.CAA:
+		if !caaTagRegexp.MatchString(r.Tag) {
+			return errors.Errorf("invalid CAA tag %q", r.Tag)
+		}
+		if r.Flag != 0 && r.Flag != 128 {
+			return errors.Errorf("invalid CAA flags %d, only 0 and 128 are defined", r.Flag)
+		}
+	case *dns.NAPTR:
+		if !naptrFlagsRegexp.MatchString(r.Flags) {
+			return errors.Errorf("invalid NAPTR flags %q", r.Flags)
+		}
+		if len(r.Regexp) > 0 && r.Replacement != "." {
+			return errors.Errorf("NAPTR regexp and replacement are mutually exclusive")
+		}
+	}
+	return nil
+}
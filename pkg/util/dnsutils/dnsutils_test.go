@@ -0,0 +1,150 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsutils
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPackSVCB(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{
+			// RFC 9460 D.1 AliasMode
+			value: "0 foo.example.com.",
+			want:  "0000" + "03666f6f076578616d706c6503636f6d00",
+		},
+		{
+			// RFC 9460 D.2 port
+			value: "1 foo.example.com port=53",
+			want:  "0001" + "03666f6f076578616d706c6503636f6d00" + "000300020035",
+		},
+		{
+			// RFC 9460 D.2 unsorted keys and mandatory
+			value: `16 foo.example.org. alpn=h2,h3-19 mandatory=ipv4hint,alpn ipv4hint=192.0.2.1`,
+			want: "0010" + "03666f6f076578616d706c65036f726700" +
+				"0000000400010004" +
+				"000100090268320568332d3139" +
+				"00040004c0000201",
+		},
+		{
+			// RFC 9460 D.2 generic key
+			value: `1 foo.example.com. key667="hello"`,
+			want:  "0001" + "03666f6f076578616d706c6503636f6d00" + "029b000568656c6c6f",
+		},
+	}
+	for _, c := range cases {
+		got, err := PackSVCB(c.value)
+		if err != nil {
+			t.Errorf("PackSVCB %q: %v", c.value, err)
+			continue
+		}
+		if hex.EncodeToString(got) != c.want {
+			t.Errorf("PackSVCB %q: got %x want %s", c.value, got, c.want)
+		}
+	}
+
+	invalids := []string{
+		"1",
+		"65536 .",
+		"0 foo.example.com. port=53",
+		"1 . port=53 port=80",
+		"1 . no-default-alpn",
+		"1 . mandatory=alpn port=53",
+		"1 . ipv4hint=2001:db8::1",
+		"1 . unknown=1",
+	}
+	for _, v := range invalids {
+		if _, err := PackSVCB(v); err == nil {
+			t.Errorf("PackSVCB %q should fail", v)
+		}
+	}
+}
+
+func TestNewRR(t *testing.T) {
+	cases := []struct {
+		dnsType string
+		value   string
+		valid   bool
+	}{
+		{"CAA", `0 issue "letsencrypt.org"`, true},
+		{"CAA", `0 iodef "mailto:admin@example.com"`, true},
+		{"CAA", `1 issue "letsencrypt.org"`, false},
+		{"CAA", `0 issue`, false},
+		{"NAPTR", `100 10 "S" "SIP+D2U" "" _sip._udp.example.com`, true},
+		{"NAPTR", `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" .`, true},
+		{"NAPTR", `100 10 "U" "E2U+sip" "!^.*$!sip:info@example.com!" example.com`, false},
+		{"HTTPS", `1 . alpn=h2,h3 ipv4hint=192.0.2.1`, true},
+		{"SVCB", `1`, false},
+		{"LOC", `52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m`, true},
+	}
+	for _, c := range cases {
+		err := ValidateValue(c.dnsType, c.value)
+		if (err == nil) != c.valid {
+			t.Errorf("ValidateValue %s %q: %v, want valid %v", c.dnsType, c.value, err, c.valid)
+		}
+	}
+
+	rr, err := NewRR("www.example.com", 300, "HTTPS", "1 . alpn=h2")
+	if err != nil {
+		t.Fatalf("NewRR: %v", err)
+	}
+	if rr.Header().Rrtype != TypeHTTPS || rr.Header().Name != "www.example.com." {
+		t.Errorf("unexpected header %s", rr.Header())
+	}
+	// the generic rdata must survive a round trip of the wire format
+	msg := new(dns.Msg)
+	msg.SetQuestion("www.example.com.", TypeHTTPS)
+	msg.Answer = []dns.RR{rr}
+	buf, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	msg2 := new(dns.Msg)
+	if err := msg2.Unpack(buf); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	if msg2.Answer[0].String() != rr.String() {
+		t.Errorf("round trip got %s want %s", msg2.Answer[0], rr)
+	}
+}
+
+func TestNewRRTXT(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []string
+	}{
+		{"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0", []string{"LoqXcYV8q5ONbJQxbmR7SCTNo3tiAXDfowyjxAjEuX0"}},
+		{"v=spf1 include:example.com ~all", []string{"v=spf1 include:example.com ~all"}},
+		{`"part one" "part two"`, []string{"part one", "part two"}},
+		{strings.Repeat("a", 300), []string{strings.Repeat("a", 255), strings.Repeat("a", 45)}},
+	}
+	for _, c := range cases {
+		rr, err := NewRR("_acme-challenge.example.com", 60, "TXT", c.value)
+		if err != nil {
+			t.Fatalf("NewRR %q: %v", c.value, err)
+		}
+		txt := rr.(*dns.TXT).Txt
+		if strings.Join(txt, "|") != strings.Join(c.want, "|") {
+			t.Errorf("NewRR %q: got %q want %q", c.value, txt, c.want)
+		}
+	}
+}
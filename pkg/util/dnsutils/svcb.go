@@ -0,0 +1,214 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsutils
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// SvcParamKeys of RFC 9460
+const (
+	SvcParamMandatory     uint16 = 0
+	SvcParamAlpn          uint16 = 1
+	SvcParamNoDefaultAlpn uint16 = 2
+	SvcParamPort          uint16 = 3
+	SvcParamIpv4Hint      uint16 = 4
+	SvcParamEch           uint16 = 5
+	SvcParamIpv6Hint      uint16 = 6
+)
+
+var svcParamKeys = map[string]uint16{
+	"mandatory":       SvcParamMandatory,
+	"alpn":            SvcParamAlpn,
+	"no-default-alpn": SvcParamNoDefaultAlpn,
+	"port":            SvcParamPort,
+	"ipv4hint":        SvcParamIpv4Hint,
+	"ech":             SvcParamEch,
+	"ipv6hint":        SvcParamIpv6Hint,
+}
+
+type sSvcParam struct {
+	key   uint16
+	value []byte
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func parseSvcParamKey(key string) (uint16, error) {
+	if k, ok := svcParamKeys[key]; ok {
+		return k, nil
+	}
+	if strings.HasPrefix(key, "key") {
+		k, err := strconv.ParseUint(key[3:], 10, 16)
+		if err == nil && k != 65535 {
+			return uint16(k), nil
+		}
+	}
+	return 0, errors.Errorf("unknown svc param key %q", key)
+}
+
+func packSvcParamValue(key uint16, value string, hasValue bool) ([]byte, error) {
+	if key == SvcParamNoDefaultAlpn {
+		if hasValue {
+			return nil, errors.Errorf("no-default-alpn takes no value")
+		}
+		return []byte{}, nil
+	}
+	if !hasValue && key <= SvcParamIpv6Hint {
+		return nil, errors.Errorf("svc param key%d requires a value", key)
+	}
+	var ret []byte
+	switch key {
+	case SvcParamMandatory:
+		mks := []uint16{}
+		for _, k := range strings.Split(value, ",") {
+			mk, err := parseSvcParamKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if mk == SvcParamMandatory {
+				return nil, errors.Errorf("mandatory must not include itself")
+			}
+			mks = append(mks, mk)
+		}
+		// the keys must be in strictly increasing order on the wire
+		sort.Slice(mks, func(i, j int) bool { return mks[i] < mks[j] })
+		for i, mk := range mks {
+			if i > 0 && mk == mks[i-1] {
+				return nil, errors.Errorf("duplicated mandatory key %s", value)
+			}
+			ret = appendUint16(ret, mk)
+		}
+	case SvcParamAlpn:
+		for _, id := range strings.Split(value, ",") {
+			if len(id) == 0 || len(id) > 255 {
+				return nil, errors.Errorf("invalid alpn id %q", id)
+			}
+			ret = append(ret, byte(len(id)))
+			ret = append(ret, id...)
+		}
+	case SvcParamPort:
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, errors.Errorf("invalid port %q", value)
+		}
+		ret = appendUint16(ret, uint16(port))
+	case SvcParamIpv4Hint, SvcParamIpv6Hint:
+		for _, addr := range strings.Split(value, ",") {
+			ip := net.ParseIP(addr)
+			if ip == nil || (key == SvcParamIpv4Hint) != (ip.To4() != nil) {
+				return nil, errors.Errorf("invalid hint address %q", addr)
+			}
+			if key == SvcParamIpv4Hint {
+				ret = append(ret, ip.To4()...)
+			} else {
+				ret = append(ret, ip.To16()...)
+			}
+		}
+	case SvcParamEch:
+		ech, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid ech")
+		}
+		ret = ech
+	default:
+		ret = []byte(value)
+	}
+	if len(ret) > 65535 {
+		return nil, errors.Errorf("svc param key%d value too long", key)
+	}
+	return ret, nil
+}
+
+// PackSVCB packs the presentation format rdata of SVCB and HTTPS records,
+// i.e. "SvcPriority TargetName [SvcParams...]", into wire format
+func PackSVCB(value string) ([]byte, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return nil, errors.Errorf("SvcPriority and TargetName are required")
+	}
+	priority, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("invalid SvcPriority %q", fields[0])
+	}
+	if priority == 0 && len(fields) > 2 {
+		return nil, errors.Errorf("svc params are not allowed in AliasMode")
+	}
+	target := dns.Fqdn(fields[1])
+	if _, ok := dns.IsDomainName(target); !ok {
+		return nil, errors.Errorf("invalid TargetName %q", fields[1])
+	}
+	buf := make([]byte, 2+255)
+	binary.BigEndian.PutUint16(buf, uint16(priority))
+	off, err := dns.PackDomainName(target, buf, 2, nil, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "PackDomainName %s", target)
+	}
+	buf = buf[:off]
+
+	params := make([]sSvcParam, 0, len(fields)-2)
+	keys := map[uint16]bool{}
+	for _, field := range fields[2:] {
+		kv := strings.SplitN(field, "=", 2)
+		key, err := parseSvcParamKey(kv[0])
+		if err != nil {
+			return nil, err
+		}
+		if keys[key] {
+			return nil, errors.Errorf("duplicated svc param %s", kv[0])
+		}
+		keys[key] = true
+		val := ""
+		if len(kv) == 2 {
+			val = strings.Trim(kv[1], `"`)
+		}
+		data, err := packSvcParamValue(key, val, len(kv) == 2)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, sSvcParam{key: key, value: data})
+	}
+	if keys[SvcParamNoDefaultAlpn] && !keys[SvcParamAlpn] {
+		return nil, errors.Errorf("no-default-alpn requires alpn")
+	}
+	for _, p := range params {
+		if p.key != SvcParamMandatory {
+			continue
+		}
+		for i := 0; i < len(p.value); i += 2 {
+			if mk := binary.BigEndian.Uint16(p.value[i:]); !keys[mk] {
+				return nil, errors.Errorf("mandatory key%d is missing", mk)
+			}
+		}
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].key < params[j].key })
+	for _, p := range params {
+		buf = appendUint16(buf, p.key)
+		buf = appendUint16(buf, uint16(len(p.value)))
+		buf = append(buf, p.value...)
+	}
+	return buf, nil
+}
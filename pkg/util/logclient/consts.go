@@ -143,6 +143,7 @@ const (
 	ACT_GUEST_CREATE_FROM_IMPORT    = "guest_create_from_import"
 	ACT_GUEST_PANICKED              = "guest_panicked"
 	ACT_HOST_MAINTAINING            = "host_maintaining"
+	ACT_HOST_FENCE                  = "host_fence"
 
 	ACT_MKDIR          = "mkdir"
 	ACT_DELETE_OBJECT  = "delete_object"
@@ -250,6 +251,8 @@ const (
 
 	ACT_PANIC = "panic"
 
+	ACT_TASK_RESUME = "task_resume"
+
 	ACT_IP_MAC_BIND = "ip_mac_bind"
 	// 程序内初始化notifyconfigmap错误
 	ACT_INIT_NOTIFY_CONFIGMAP = "init_notify_configmap"
@@ -282,4 +285,15 @@ const (
 	ACT_CLEAN_PROJECT = "clean_project"
 
 	ACT_COLLECT_METRICS = "collect_metrics"
+
+	ACT_ENABLE_DNSSEC       = "enable_dnssec"
+	ACT_DISABLE_DNSSEC      = "disable_dnssec"
+	ACT_ROLLOVER_DNSSEC_KEY = "rollover_dnssec_key"
+	ACT_RETIRE_DNSSEC_KEY   = "retire_dnssec_key"
+
+	ACT_ISSUE_ACME_CERT = "issue_acme_cert"
+
+	ACT_ACKNOWLEDGE = "acknowledge"
+
+	ACT_ACCEPT_PEERING = "accept_peering"
 )
@@ -0,0 +1,331 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+)
+
+// ISpanExporter exports the finished spans, the payload is encoded as an
+// OTLP ExportTraceServiceRequest in JSON
+type ISpanExporter interface {
+	ExportSpans(payload []byte) error
+}
+
+const (
+	batchQueueSize    = 2048
+	batchMaxSize      = 512
+	batchFlushTimeout = 5 * time.Second
+)
+
+type sBatchProcessor struct {
+	serviceName string
+	exporter    ISpanExporter
+
+	queue chan *SSpan
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newBatchProcessor(serviceName string, exporter ISpanExporter) *sBatchProcessor {
+	p := &sBatchProcessor{
+		serviceName: serviceName,
+		exporter:    exporter,
+		queue:       make(chan *SSpan, batchQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *sBatchProcessor) onEnd(span *SSpan) {
+	select {
+	case p.queue <- span:
+	default:
+		log.Debugf("tracing queue is full, drop span %s", span.Name)
+	}
+}
+
+func (p *sBatchProcessor) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(batchFlushTimeout)
+	defer ticker.Stop()
+	batch := make([]*SSpan, 0, batchMaxSize)
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= batchMaxSize {
+				batch = p.export(batch)
+			}
+		case <-ticker.C:
+			batch = p.export(batch)
+		case <-p.stop:
+			for {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+				default:
+					p.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *sBatchProcessor) export(batch []*SSpan) []*SSpan {
+	if len(batch) == 0 {
+		return batch
+	}
+	payload, err := EncodeOTLP(p.serviceName, batch)
+	if err != nil {
+		log.Errorf("encode %d spans fail: %v", len(batch), err)
+	} else if err := p.exporter.ExportSpans(payload); err != nil {
+		log.Errorf("export %d spans fail: %v", len(batch), err)
+	}
+	return batch[:0]
+}
+
+func (p *sBatchProcessor) shutdown() {
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+}
+
+// OTLP/JSON data model, see opentelemetry-proto
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	TraceState        string         `json:"traceState,omitempty"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	otlpStatusCodeOk    = 1
+	otlpStatusCodeError = 2
+)
+
+func otlpValue(val interface{}) otlpAnyValue {
+	ret := otlpAnyValue{}
+	switch v := val.(type) {
+	case string:
+		ret.StringValue = &v
+	case bool:
+		ret.BoolValue = &v
+	case int:
+		s := strconv.FormatInt(int64(v), 10)
+		ret.IntValue = &s
+	case int32:
+		s := strconv.FormatInt(int64(v), 10)
+		ret.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		ret.IntValue = &s
+	case float32:
+		f := float64(v)
+		ret.DoubleValue = &f
+	case float64:
+		ret.DoubleValue = &v
+	default:
+		s := fmt.Sprintf("%v", v)
+		ret.StringValue = &s
+	}
+	return ret
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	ret := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		ret = append(ret, otlpKeyValue{Key: k, Value: otlpValue(v)})
+	}
+	return ret
+}
+
+func unixNano(tm time.Time) string {
+	return strconv.FormatInt(tm.UnixNano(), 10)
+}
+
+// EncodeOTLP encodes the spans of a service as an OTLP ExportTraceServiceRequest in JSON
+func EncodeOTLP(serviceName string, spans []*SSpan) ([]byte, error) {
+	hostname, _ := os.Hostname()
+	scopeSpans := otlpScopeSpans{
+		Scope: otlpScope{Name: "yunion.io/x/onecloud"},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+	for _, span := range spans {
+		span.lock.Lock()
+		s := otlpSpan{
+			TraceId:           span.SpanContext.TraceId.String(),
+			SpanId:            span.SpanContext.SpanId.String(),
+			TraceState:        span.SpanContext.TraceState,
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: unixNano(span.StartTime),
+			EndTimeUnixNano:   unixNano(span.EndTime),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCodeOk},
+		}
+		if span.ParentSpanId.IsValid() {
+			s.ParentSpanId = span.ParentSpanId.String()
+		}
+		for _, ev := range span.Events {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: unixNano(ev.Time),
+				Name:         ev.Name,
+				Attributes:   otlpAttributes(ev.Attributes),
+			})
+		}
+		if len(span.Error) > 0 {
+			s.Status = otlpStatus{Code: otlpStatusCodeError, Message: span.Error}
+		}
+		span.lock.Unlock()
+		scopeSpans.Spans = append(scopeSpans.Spans, s)
+	}
+	req := otlpTraceRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]interface{}{
+						"service.name": serviceName,
+						"host.name":    hostname,
+					}),
+				},
+				ScopeSpans: []otlpScopeSpans{scopeSpans},
+			},
+		},
+	}
+	return json.Marshal(&req)
+}
+
+type sFileExporter struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+// NewFileExporter returns an exporter appending the spans to a file, one OTLP
+// JSON request per line, the same as the file exporter of the OpenTelemetry collector
+func NewFileExporter(path string) (ISpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", path)
+	}
+	return &sFileExporter{file: file}, nil
+}
+
+func (e *sFileExporter) ExportSpans(payload []byte) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	_, err := e.file.Write(append(payload, '\n'))
+	return err
+}
+
+type sOtlpHttpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewOtlpHttpExporter returns an exporter sending the spans to an OTLP/HTTP
+// collector in JSON, e.g. http://127.0.0.1:4318
+func NewOtlpHttpExporter(endpoint string) ISpanExporter {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &sOtlpHttpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (e *sOtlpHttpExporter) ExportSpans(payload []byte) error {
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return errors.Wrapf(err, "post %s", e.endpoint)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("post %s: %s %s", e.endpoint, resp.Status, msg)
+	}
+	return nil
+}
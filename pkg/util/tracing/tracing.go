@@ -0,0 +1,383 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"yunion.io/x/pkg/errors"
+)
+
+// TSpanKind follows the span kinds of OpenTelemetry
+type TSpanKind int
+
+const (
+	SpanKindInternal = TSpanKind(1)
+	SpanKindServer   = TSpanKind(2)
+	SpanKindClient   = TSpanKind(3)
+)
+
+const (
+	// W3C trace context headers, see https://www.w3.org/TR/trace-context/
+	TRACEPARENT_HEADER = "traceparent"
+	TRACESTATE_HEADER  = "tracestate"
+
+	traceParentVersion = "00"
+	flagSampled        = 0x01
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id TraceId) IsValid() bool {
+	return id != TraceId{}
+}
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanId) IsValid() bool {
+	return id != SpanId{}
+}
+
+// SSpanContext is the part of a span propagated across process boundaries
+type SSpanContext struct {
+	TraceId    TraceId
+	SpanId     SpanId
+	Sampled    bool
+	TraceState string
+}
+
+func (sc SSpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+// TraceParent formats the span context as the value of the traceparent header
+func (sc SSpanContext) TraceParent() string {
+	flags := 0
+	if sc.Sampled {
+		flags |= flagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, sc.TraceId, sc.SpanId, flags)
+}
+
+func decodeLowerHex(dst []byte, src string) error {
+	if len(src) != 2*len(dst) || strings.ToLower(src) != src {
+		return errors.Errorf("invalid hex string %q", src)
+	}
+	_, err := hex.Decode(dst, []byte(src))
+	return err
+}
+
+// ParseTraceParent parses the value of a traceparent header
+func ParseTraceParent(val string) (SSpanContext, error) {
+	sc := SSpanContext{}
+	val = strings.TrimSpace(val)
+	if len(val) < 55 {
+		return sc, errors.Errorf("invalid traceparent %q", val)
+	}
+	version := val[:2]
+	if version == "ff" || strings.ToLower(version) != version {
+		return sc, errors.Errorf("invalid traceparent version %q", version)
+	}
+	// future versions may append fields after the flags
+	if (version == traceParentVersion && len(val) != 55) || (len(val) > 55 && val[55] != '-') {
+		return sc, errors.Errorf("invalid traceparent %q", val)
+	}
+	if val[2] != '-' || val[35] != '-' || val[52] != '-' {
+		return sc, errors.Errorf("invalid traceparent %q", val)
+	}
+	if err := decodeLowerHex(sc.TraceId[:], val[3:35]); err != nil {
+		return sc, errors.Wrap(err, "trace id")
+	}
+	if err := decodeLowerHex(sc.SpanId[:], val[36:52]); err != nil {
+		return sc, errors.Wrap(err, "span id")
+	}
+	flags := make([]byte, 1)
+	if err := decodeLowerHex(flags, val[53:55]); err != nil {
+		return sc, errors.Wrap(err, "flags")
+	}
+	if !sc.IsValid() {
+		return sc, errors.Errorf("all zero trace id or span id in traceparent %q", val)
+	}
+	sc.Sampled = flags[0]&flagSampled > 0
+	return sc, nil
+}
+
+type SSpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// SSpan is a timed operation of a trace, all methods are safe to be called on
+// a nil span, which is returned when tracing is disabled
+type SSpan struct {
+	Name         string
+	Kind         TSpanKind
+	SpanContext  SSpanContext
+	ParentSpanId SpanId
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Events       []SSpanEvent
+	// non-empty error marks the span as failed
+	Error string
+
+	lock  sync.Mutex
+	ended bool
+}
+
+func (span *SSpan) SetAttribute(key string, val interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.Attributes[key] = val
+}
+
+func (span *SSpan) AddEvent(name string, attrs map[string]interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.Events = append(span.Events, SSpanEvent{Name: name, Time: time.Now(), Attributes: attrs})
+}
+
+func (span *SSpan) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	span.Error = err.Error()
+}
+
+// SetHTTPStatus records the status code of a HTTP request and marks 5XX as errors
+func (span *SSpan) SetHTTPStatus(status int) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute("http.status_code", status)
+	if status >= 500 {
+		span.SetError(errors.Errorf("HTTP %d", status))
+	}
+}
+
+// End finishes the span and submits it to the exporter if it is sampled
+func (span *SSpan) End() {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	if span.ended {
+		span.lock.Unlock()
+		return
+	}
+	span.ended = true
+	span.EndTime = time.Now()
+	span.lock.Unlock()
+	if span.SpanContext.Sampled {
+		if t := getTracer(); t != nil {
+			t.processor.onEnd(span)
+		}
+	}
+}
+
+type sTracer struct {
+	serviceName string
+	sampleRatio float64
+	processor   *sBatchProcessor
+}
+
+var (
+	globalTracer     *sTracer
+	globalTracerLock sync.RWMutex
+)
+
+func getTracer() *sTracer {
+	globalTracerLock.RLock()
+	defer globalTracerLock.RUnlock()
+	return globalTracer
+}
+
+// Init enables tracing for the service, the sampled spans are exported by
+// exporter in batches. sampleRatio is the probability to sample a new trace,
+// a trace started by another service follows the sampling decision of its parent
+func Init(serviceName string, exporter ISpanExporter, sampleRatio float64) {
+	globalTracerLock.Lock()
+	defer globalTracerLock.Unlock()
+	if globalTracer != nil {
+		globalTracer.processor.shutdown()
+	}
+	globalTracer = &sTracer{
+		serviceName: serviceName,
+		sampleRatio: sampleRatio,
+		processor:   newBatchProcessor(serviceName, exporter),
+	}
+}
+
+// Shutdown flushes the pending spans and disables tracing
+func Shutdown() {
+	globalTracerLock.Lock()
+	defer globalTracerLock.Unlock()
+	if globalTracer != nil {
+		globalTracer.processor.shutdown()
+		globalTracer = nil
+	}
+}
+
+func IsEnabled() bool {
+	return getTracer() != nil
+}
+
+type spanContextKey struct{}
+type remoteSpanContextKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *SSpan) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *SSpan {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*SSpan)
+	return span
+}
+
+// ContextWithRemoteSpanContext saves the span context received from another
+// service in ctx, the spans started with ctx become its children
+func ContextWithRemoteSpanContext(ctx context.Context, sc SSpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span in ctx,
+// or the remote span context if there is no local span
+func SpanContextFromContext(ctx context.Context) (SSpanContext, bool) {
+	if ctx == nil {
+		return SSpanContext{}, false
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext, true
+	}
+	sc, ok := ctx.Value(remoteSpanContextKey{}).(SSpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// TraceParentFromContext returns the traceparent of the current span in ctx,
+// empty string is returned if there is no span
+func TraceParentFromContext(ctx context.Context) string {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	return sc.TraceParent()
+}
+
+// StartSpan starts a span as the child of the span in ctx. When there is no
+// span in ctx, a new trace is started if tracing is enabled, otherwise nil span
+// is returned and ctx is unchanged.
+func StartSpan(ctx context.Context, name string, kind TSpanKind) (context.Context, *SSpan) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent, hasParent := SpanContextFromContext(ctx)
+	tracer := getTracer()
+	if !hasParent && tracer == nil {
+		return ctx, nil
+	}
+	sc := SSpanContext{}
+	if hasParent {
+		sc.TraceId = parent.TraceId
+		sc.Sampled = parent.Sampled
+		sc.TraceState = parent.TraceState
+	} else {
+		rand.Read(sc.TraceId[:])
+		sc.Sampled = tracer.sampleRatio >= 1 || mrand.Float64() < tracer.sampleRatio
+	}
+	rand.Read(sc.SpanId[:])
+	span := &SSpan{
+		Name:        name,
+		Kind:        kind,
+		SpanContext: sc,
+		StartTime:   time.Now(),
+		Attributes:  map[string]interface{}{},
+	}
+	if hasParent {
+		span.ParentSpanId = parent.SpanId
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// StartSpanFromTraceParent starts a span as the child of the span described by
+// traceparent, the span in ctx is used if traceparent is invalid
+func StartSpanFromTraceParent(ctx context.Context, traceParent string, name string, kind TSpanKind) (context.Context, *SSpan) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(traceParent) > 0 {
+		sc, err := ParseTraceParent(traceParent)
+		if err == nil {
+			ctx = ContextWithRemoteSpanContext(ContextWithSpan(ctx, nil), sc)
+		}
+	}
+	return StartSpan(ctx, name, kind)
+}
+
+// Inject sets the trace context headers of the current span in ctx
+func Inject(ctx context.Context, header http.Header) http.Header {
+	sc, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return header
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(TRACEPARENT_HEADER, sc.TraceParent())
+	if len(sc.TraceState) > 0 {
+		header.Set(TRACESTATE_HEADER, sc.TraceState)
+	}
+	return header
+}
+
+// Extract returns ctx with the remote span context carried by the headers
+func Extract(ctx context.Context, header http.Header) context.Context {
+	val := header.Get(TRACEPARENT_HEADER)
+	if len(val) == 0 {
+		return ctx
+	}
+	sc, err := ParseTraceParent(val)
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = header.Get(TRACESTATE_HEADER)
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	cases := []struct {
+		in      string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		// future version with extra fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abcd", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abcd", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"", false, false},
+	}
+	for _, c := range cases {
+		sc, err := ParseTraceParent(c.in)
+		if (err == nil) != c.valid {
+			t.Errorf("%q: expect valid %v, got error %v", c.in, c.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sc.Sampled != c.sampled {
+			t.Errorf("%q: expect sampled %v", c.in, c.sampled)
+		}
+		if !strings.HasPrefix(c.in, "00-") {
+			continue
+		}
+		if sc.TraceParent() != c.in {
+			t.Errorf("%q: format back as %q", c.in, sc.TraceParent())
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "disabled", SpanKindServer)
+	if span != nil || len(TraceParentFromContext(ctx)) > 0 {
+		t.Fatalf("no span should be started when tracing is disabled")
+	}
+
+	header := http.Header{}
+	header.Set(TRACEPARENT_HEADER, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(TRACESTATE_HEADER, "vendor=value")
+	ctx = Extract(context.Background(), header)
+	ctx, span = StartSpan(ctx, "server", SpanKindServer)
+	if span == nil {
+		t.Fatalf("span of a remote parent should be started even if tracing is disabled")
+	}
+	if span.SpanContext.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanId.String() != "00f067aa0ba902b7" {
+		t.Errorf("unexpected span context %s parent %s", span.SpanContext.TraceParent(), span.ParentSpanId)
+	}
+	_, child := StartSpan(ctx, "client", SpanKindClient)
+	if child.ParentSpanId != span.SpanContext.SpanId || child.SpanContext.TraceId != span.SpanContext.TraceId {
+		t.Errorf("child %s is not a child of %s", child.SpanContext.TraceParent(), span.SpanContext.TraceParent())
+	}
+
+	out := Inject(ctx, nil)
+	if out.Get(TRACEPARENT_HEADER) != span.SpanContext.TraceParent() || out.Get(TRACESTATE_HEADER) != "vendor=value" {
+		t.Errorf("unexpected injected headers %v", out)
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "traces.json")
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init("test", exporter, 1)
+
+	ctx, root := StartSpan(context.Background(), "root", SpanKindServer)
+	root.SetAttribute("http.status_code", 200)
+	_, child := StartSpan(ctx, "child", SpanKindInternal)
+	child.SetHTTPStatus(503)
+	child.End()
+	root.End()
+	Shutdown()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := jsonutils.Parse(content)
+	if err != nil {
+		t.Fatalf("parse %s: %v", content, err)
+	}
+	rs, _ := obj.GetArray("resourceSpans")
+	if len(rs) != 1 {
+		t.Fatalf("expect 1 resource spans, got %s", content)
+	}
+	ss, _ := rs[0].GetArray("scopeSpans")
+	if len(ss) != 1 {
+		t.Fatalf("expect 1 scope spans, got %s", content)
+	}
+	spans, _ := ss[0].GetArray("spans")
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans, got %s", content)
+	}
+	name, _ := spans[0].GetString("name")
+	code, _ := spans[0].Int("status", "code")
+	parent, _ := spans[0].GetString("parentSpanId")
+	if name != "child" || code != otlpStatusCodeError || parent != root.SpanContext.SpanId.String() {
+		t.Errorf("unexpected child span %s", spans[0])
+	}
+}
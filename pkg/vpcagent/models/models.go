@@ -29,8 +29,11 @@ type Vpc struct {
 
 	RouteTable *RouteTable `json:"-"`
 
-	Wire     *Wire    `json:"-"`
-	Networks Networks `json:"-"`
+	Wire        *Wire       `json:"-"`
+	Networks    Networks    `json:"-"`
+	NatGateways NatGateways `json:"-"`
+
+	VpcPeeringConnections VpcPeeringConnections `json:"-"`
 }
 
 func (el *Vpc) Copy() *Vpc {
@@ -371,3 +374,56 @@ func (el *LoadbalancerAcl) Copy() *LoadbalancerAcl {
 		SLoadbalancerAcl: el.SLoadbalancerAcl,
 	}
 }
+
+type NatGateway struct {
+	compute_models.SNatGateway
+
+	Vpc         *Vpc        `json:"-"`
+	NatSEntries NatSEntries `json:"-"`
+	NatDEntries NatDEntries `json:"-"`
+}
+
+func (el *NatGateway) Copy() *NatGateway {
+	return &NatGateway{
+		SNatGateway: el.SNatGateway,
+	}
+}
+
+type NatSEntry struct {
+	compute_models.SNatSEntry
+
+	NatGateway *NatGateway `json:"-"`
+	// Network is nil when the entry is specified by source cidr
+	Network *Network `json:"-"`
+}
+
+func (el *NatSEntry) Copy() *NatSEntry {
+	return &NatSEntry{
+		SNatSEntry: el.SNatSEntry,
+	}
+}
+
+type NatDEntry struct {
+	compute_models.SNatDEntry
+
+	NatGateway *NatGateway `json:"-"`
+}
+
+func (el *NatDEntry) Copy() *NatDEntry {
+	return &NatDEntry{
+		SNatDEntry: el.SNatDEntry,
+	}
+}
+
+type VpcPeeringConnection struct {
+	compute_models.SVpcPeeringConnection
+
+	Vpc     *Vpc `json:"-"`
+	PeerVpc *Vpc `json:"-"`
+}
+
+func (el *VpcPeeringConnection) Copy() *VpcPeeringConnection {
+	return &VpcPeeringConnection{
+		SVpcPeeringConnection: el.SVpcPeeringConnection,
+	}
+}
@@ -54,6 +54,12 @@ type (
 	LoadbalancerNetworks  map[string]*LoadbalancerNetwork // key: networkId/loadbalancerId
 	LoadbalancerListeners map[string]*LoadbalancerListener
 	LoadbalancerAcls      map[string]*LoadbalancerAcl
+
+	NatGateways map[string]*NatGateway
+	NatSEntries map[string]*NatSEntry
+	NatDEntries map[string]*NatDEntry
+
+	VpcPeeringConnections map[string]*VpcPeeringConnection
 )
 
 func (set Vpcs) ModelManager() mcclient_modulebase.IBaseManager {
@@ -1139,3 +1145,225 @@ func (set LoadbalancerAcls) Copy() apihelper.IModelSet {
 	}
 	return setCopy
 }
+
+func (set NatGateways) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatGateways
+}
+
+func (set NatGateways) DBModelManager() db.IModelManager {
+	return models.NatGatewayManager
+}
+
+func (set NatGateways) NewModel() db.IModel {
+	return &NatGateway{}
+}
+
+func (set NatGateways) AddModel(i db.IModel) {
+	m := i.(*NatGateway)
+	set[m.Id] = m
+}
+
+func (set NatGateways) Copy() apihelper.IModelSet {
+	setCopy := NatGateways{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set NatGateways) ModelParamFilter() jsonutils.JSONObject {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString("OneCloud"), "provider")
+	return params
+}
+
+func (ms Vpcs) joinNatGateways(subEntries NatGateways) bool {
+	for _, m := range ms {
+		m.NatGateways = NatGateways{}
+	}
+	for subId, subEntry := range subEntries {
+		vpcId := subEntry.VpcId
+		m, ok := ms[vpcId]
+		if !ok {
+			// let it go.  Nat gateways of vpcs not managed by us
+			log.Warningf("natgateway %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, vpcId)
+			delete(subEntries, subId)
+			continue
+		}
+		subEntry.Vpc = m
+		m.NatGateways[subId] = subEntry
+	}
+	return true
+}
+
+func (set NatSEntries) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatSTable
+}
+
+func (set NatSEntries) DBModelManager() db.IModelManager {
+	return models.NatSEntryManager
+}
+
+func (set NatSEntries) NewModel() db.IModel {
+	return &NatSEntry{}
+}
+
+func (set NatSEntries) AddModel(i db.IModel) {
+	m := i.(*NatSEntry)
+	set[m.Id] = m
+}
+
+func (set NatSEntries) Copy() apihelper.IModelSet {
+	setCopy := NatSEntries{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set NatSEntries) ModelParamFilter() jsonutils.JSONObject {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString("OneCloud"), "provider")
+	return params
+}
+
+func (ms NatGateways) joinNatSEntries(subEntries NatSEntries, networks Networks) bool {
+	for _, m := range ms {
+		m.NatSEntries = NatSEntries{}
+	}
+	correct := true
+	for subId, subEntry := range subEntries {
+		natgatewayId := subEntry.NatgatewayId
+		m, ok := ms[natgatewayId]
+		if !ok {
+			log.Warningf("natsentry %s(%s): natgateway id %s not found",
+				subEntry.Name, subEntry.Id, natgatewayId)
+			delete(subEntries, subId)
+			continue
+		}
+		subEntry.NatGateway = m
+		m.NatSEntries[subId] = subEntry
+		if subEntry.NetworkId != "" {
+			network, ok := networks[subEntry.NetworkId]
+			if !ok {
+				log.Warningf("natsentry %s(%s): network id %s not found",
+					subEntry.Name, subEntry.Id, subEntry.NetworkId)
+				correct = false
+				continue
+			}
+			subEntry.Network = network
+		}
+	}
+	return correct
+}
+
+func (set NatDEntries) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.NatDTable
+}
+
+func (set NatDEntries) DBModelManager() db.IModelManager {
+	return models.NatDEntryManager
+}
+
+func (set NatDEntries) NewModel() db.IModel {
+	return &NatDEntry{}
+}
+
+func (set NatDEntries) AddModel(i db.IModel) {
+	m := i.(*NatDEntry)
+	set[m.Id] = m
+}
+
+func (set NatDEntries) Copy() apihelper.IModelSet {
+	setCopy := NatDEntries{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set NatDEntries) ModelParamFilter() jsonutils.JSONObject {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString("OneCloud"), "provider")
+	return params
+}
+
+func (ms NatGateways) joinNatDEntries(subEntries NatDEntries) bool {
+	for _, m := range ms {
+		m.NatDEntries = NatDEntries{}
+	}
+	for subId, subEntry := range subEntries {
+		natgatewayId := subEntry.NatgatewayId
+		m, ok := ms[natgatewayId]
+		if !ok {
+			log.Warningf("natdentry %s(%s): natgateway id %s not found",
+				subEntry.Name, subEntry.Id, natgatewayId)
+			delete(subEntries, subId)
+			continue
+		}
+		subEntry.NatGateway = m
+		m.NatDEntries[subId] = subEntry
+	}
+	return true
+}
+
+func (set VpcPeeringConnections) ModelManager() mcclient_modulebase.IBaseManager {
+	return &mcclient_modules.VpcPeeringConnections
+}
+
+func (set VpcPeeringConnections) DBModelManager() db.IModelManager {
+	return models.VpcPeeringConnectionManager
+}
+
+func (set VpcPeeringConnections) NewModel() db.IModel {
+	return &VpcPeeringConnection{}
+}
+
+func (set VpcPeeringConnections) AddModel(i db.IModel) {
+	m := i.(*VpcPeeringConnection)
+	set[m.Id] = m
+}
+
+func (set VpcPeeringConnections) Copy() apihelper.IModelSet {
+	setCopy := VpcPeeringConnections{}
+	for id, el := range set {
+		setCopy[id] = el.Copy()
+	}
+	return setCopy
+}
+
+func (set VpcPeeringConnections) ModelParamFilter() jsonutils.JSONObject {
+	params := jsonutils.NewDict()
+	params.Add(jsonutils.NewString("OneCloud"), "provider")
+	return params
+}
+
+func (ms Vpcs) joinVpcPeeringConnections(subEntries VpcPeeringConnections) bool {
+	for _, m := range ms {
+		m.VpcPeeringConnections = VpcPeeringConnections{}
+	}
+	for subId, subEntry := range subEntries {
+		vpcId := subEntry.VpcId
+		m, ok := ms[vpcId]
+		if !ok {
+			// let it go.  Peering connections of vpcs not managed by us
+			log.Warningf("vpc peering connection %s(%s): vpc id %s not found",
+				subEntry.Name, subEntry.Id, vpcId)
+			delete(subEntries, subId)
+			continue
+		}
+		peerVpcId := subEntry.PeerVpcId
+		peerM, ok := ms[peerVpcId]
+		if !ok {
+			log.Warningf("vpc peering connection %s(%s): peer vpc id %s not found",
+				subEntry.Name, subEntry.Id, peerVpcId)
+			delete(subEntries, subId)
+			continue
+		}
+		subEntry.Vpc = m
+		subEntry.PeerVpc = peerM
+		m.VpcPeeringConnections[subId] = subEntry
+	}
+	return true
+}
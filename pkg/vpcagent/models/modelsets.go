@@ -50,6 +50,12 @@ type ModelSetsMaxUpdatedAt struct {
 	LoadbalancerNetworks  time.Time
 	LoadbalancerListeners time.Time
 	LoadbalancerAcls      time.Time
+
+	NatGateways time.Time
+	NatSEntries time.Time
+	NatDEntries time.Time
+
+	VpcPeeringConnections time.Time
 }
 
 func NewModelSetsMaxUpdatedAt() *ModelSetsMaxUpdatedAt {
@@ -77,6 +83,12 @@ func NewModelSetsMaxUpdatedAt() *ModelSetsMaxUpdatedAt {
 		LoadbalancerNetworks:  apihelper.PseudoZeroTime,
 		LoadbalancerListeners: apihelper.PseudoZeroTime,
 		LoadbalancerAcls:      apihelper.PseudoZeroTime,
+
+		NatGateways: apihelper.PseudoZeroTime,
+		NatSEntries: apihelper.PseudoZeroTime,
+		NatDEntries: apihelper.PseudoZeroTime,
+
+		VpcPeeringConnections: apihelper.PseudoZeroTime,
 	}
 }
 
@@ -105,6 +117,12 @@ type ModelSets struct {
 	LoadbalancerNetworks  LoadbalancerNetworks
 	LoadbalancerListeners LoadbalancerListeners
 	LoadbalancerAcls      LoadbalancerAcls
+
+	NatGateways NatGateways
+	NatSEntries NatSEntries
+	NatDEntries NatDEntries
+
+	VpcPeeringConnections VpcPeeringConnections
 }
 
 func NewModelSets() *ModelSets {
@@ -133,6 +151,12 @@ func NewModelSets() *ModelSets {
 		LoadbalancerNetworks:  LoadbalancerNetworks{},
 		LoadbalancerListeners: LoadbalancerListeners{},
 		LoadbalancerAcls:      LoadbalancerAcls{},
+
+		NatGateways: NatGateways{},
+		NatSEntries: NatSEntries{},
+		NatDEntries: NatDEntries{},
+
+		VpcPeeringConnections: VpcPeeringConnections{},
 	}
 }
 
@@ -163,6 +187,12 @@ func (mss *ModelSets) ModelSetList() []apihelper.IModelSet {
 		mss.LoadbalancerNetworks,
 		mss.LoadbalancerListeners,
 		mss.LoadbalancerAcls,
+
+		mss.NatGateways,
+		mss.NatSEntries,
+		mss.NatDEntries,
+
+		mss.VpcPeeringConnections,
 	}
 }
 
@@ -196,6 +226,12 @@ func (mss *ModelSets) copy_() *ModelSets {
 		LoadbalancerNetworks:  mss.LoadbalancerNetworks.Copy().(LoadbalancerNetworks),
 		LoadbalancerListeners: mss.LoadbalancerListeners.Copy().(LoadbalancerListeners),
 		LoadbalancerAcls:      mss.LoadbalancerAcls.Copy().(LoadbalancerAcls),
+
+		NatGateways: mss.NatGateways.Copy().(NatGateways),
+		NatSEntries: mss.NatSEntries.Copy().(NatSEntries),
+		NatDEntries: mss.NatDEntries.Copy().(NatDEntries),
+
+		VpcPeeringConnections: mss.VpcPeeringConnections.Copy().(VpcPeeringConnections),
 	}
 	return mssCopy
 }
@@ -289,6 +325,14 @@ func (mss *ModelSets) join() bool {
 	msg = append(msg, "mss.LoadbalancerNetworks.joinLoadbalancerListeners(mss.LoadbalancerListeners)")
 	p = append(p, mss.LoadbalancerListeners.joinLoadbalancerAcls(mss.LoadbalancerAcls))
 	msg = append(msg, "mss.LoadbalancerListeners.joinLoadbalancerAcls(mss.LoadbalancerAcls)")
+	p = append(p, mss.Vpcs.joinNatGateways(mss.NatGateways))
+	msg = append(msg, "mss.Vpcs.joinNatGateways(mss.NatGateways)")
+	p = append(p, mss.NatGateways.joinNatSEntries(mss.NatSEntries, mss.Networks))
+	msg = append(msg, "mss.NatGateways.joinNatSEntries(mss.NatSEntries, mss.Networks)")
+	p = append(p, mss.NatGateways.joinNatDEntries(mss.NatDEntries))
+	msg = append(msg, "mss.NatGateways.joinNatDEntries(mss.NatDEntries)")
+	p = append(p, mss.Vpcs.joinVpcPeeringConnections(mss.VpcPeeringConnections))
+	msg = append(msg, "mss.Vpcs.joinVpcPeeringConnections(mss.VpcPeeringConnections)")
 	p = append(p, mss.DnsZones.joinRecords(mss.DnsRecords))
 	msg = append(msg, "mss.Vpcs.joinRecords(mss.DnsRecords)")
 	ret := true
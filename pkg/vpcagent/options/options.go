@@ -42,6 +42,7 @@ type VpcAgentOptions struct {
 	OvnWorkerCheckInterval int    `default:"180"`
 	OvnNorthDatabase       string `help:"address for accessing ovn north database.  Default to local unix socket"`
 	OvnUnderlayMtu         int    `help:"mtu of ovn underlay network" default:"1500"`
+	OvnNatGatewayChassis   string `help:"name of ovn chassis where snat/dnat rules of vpc nat gateways are centralized"`
 
 	DhcpLeaseTime   int `default:"100663296" help:"DHCP lease time in seconds"`
 	DhcpRenewalTime int `default:"67108864" help:"DHCP renewal time in seconds"`
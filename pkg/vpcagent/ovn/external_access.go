@@ -17,6 +17,7 @@ package ovn
 import (
 	apis "yunion.io/x/onecloud/pkg/apis/compute"
 	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/options"
 )
 
 func vpcHasDistgw(vpc *agentmodels.Vpc) bool {
@@ -42,3 +43,10 @@ func vpcHasEipgw(vpc *agentmodels.Vpc) bool {
 		return false
 	}
 }
+
+// vpcHasNatgw returns true if snat/dnat rules of vpc nat gateways should be
+// realized.  NAT traffic goes through eipgw and is centralized on the
+// configured gateway chassis
+func vpcHasNatgw(vpc *agentmodels.Vpc, opts *options.Options) bool {
+	return vpcHasEipgw(vpc) && len(vpc.NatGateways) > 0 && opts.OvnNatGatewayChassis != ""
+}
@@ -59,6 +59,8 @@ func DumpOVNNorthbound(ctx context.Context, cli *ovnutil.OvnNbCtl) (*OVNNorthbou
 		&db.DHCPOptions,
 		&db.QoS,
 		&db.DNS,
+		&db.NAT,
+		&db.LoadBalancer,
 	}
 	args := []string{"--format=json", "list", "<tbl>"}
 	for _, itbl := range itbls {
@@ -88,7 +90,7 @@ func ovnCreateArgs(irow types.IRow, idRef string) []string {
 	return args
 }
 
-func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodels.Vpc) error {
+func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodels.Vpc, opts *options.Options) error {
 	var (
 		args      []string
 		ocVersion = fmt.Sprintf("%s.%d", vpc.UpdatedAt, vpc.UpdateVersion)
@@ -208,6 +210,12 @@ func (keeper *OVNNorthboundKeeper) ClaimVpc(ctx context.Context, vpc *agentmodel
 			Mac:      apis.VpcEipGatewayMac,
 			Networks: []string{fmt.Sprintf("%s/%d", apis.VpcEipGatewayIP(), apis.VpcEipGatewayIPMask)},
 		}
+		if vpcHasNatgw(vpc, opts) {
+			// snat/dnat on distributed router requires a gateway port
+			vpcRep.Options = map[string]string{
+				"redirect-chassis": opts.OvnNatGatewayChassis,
+			}
+		}
 		vpcErp = &ovn_nb.LogicalSwitchPort{
 			Name:      vpcErpName(vpc.Id),
 			Type:      "router",
@@ -841,6 +849,256 @@ func (keeper *OVNNorthboundKeeper) ClaimRoutes(ctx context.Context, vpc *agentmo
 	return nil
 }
 
+// natGatewayRows generates rows for entries of nat gateway.  Snat entries
+// become NAT rows, dnat entries with ports become Load_Balancer vips.
+// Traffic from matching sources is routed through eipgw.  Entries that
+// cannot be realized are skipped, their errors are returned keyed by entry id
+func natGatewayRows(natgw *agentmodels.NatGateway) ([]*ovn_nb.NAT, []*ovn_nb.LoadBalancer, []*ovn_nb.LogicalRouterStaticRoute, map[string]error) {
+	var (
+		vpc        = natgw.Vpc
+		ocNatRef   = fmt.Sprintf("nat/%s", natgw.Id)
+		ocRouteRef = fmt.Sprintf("natRoute/%s", natgw.Id)
+	)
+
+	var (
+		nats          []*ovn_nb.NAT
+		lbs           []*ovn_nb.LoadBalancer
+		routes        []*ovn_nb.LogicalRouterStaticRoute
+		routePrefixes = map[string]bool{}
+		entryErrs     = map[string]error{}
+	)
+	addRoute := func(ipPrefix string) {
+		if routePrefixes[ipPrefix] {
+			return
+		}
+		routePrefixes[ipPrefix] = true
+		routes = append(routes, &ovn_nb.LogicalRouterStaticRoute{
+			Policy:     ptr("src-ip"),
+			IpPrefix:   ipPrefix,
+			Nexthop:    apis.VpcEipGatewayIP3().String(),
+			OutputPort: ptr(vpcRepName(vpc.Id)),
+			ExternalIds: map[string]string{
+				externalKeyOcRef: ocRouteRef,
+			},
+		})
+	}
+
+	for _, snat := range natgw.NatSEntries {
+		logicalIp := snat.SourceCIDR
+		if logicalIp == "" {
+			network := snat.Network
+			if network == nil {
+				entryErrs[snat.Id] = errors.Errorf("natsentry %s(%s) has neither source cidr nor network", snat.Name, snat.Id)
+				continue
+			}
+			prefix, err := netutils.NewIPV4Prefix(fmt.Sprintf("%s/%d", network.GuestIpStart, network.GuestIpMask))
+			if err != nil {
+				entryErrs[snat.Id] = errors.Wrapf(err, "natsentry %s(%s): network %s(%s) cidr", snat.Name, snat.Id, network.Name, network.Id)
+				continue
+			}
+			logicalIp = prefix.String()
+		}
+		nats = append(nats, &ovn_nb.NAT{
+			Type:       "snat",
+			ExternalIp: snat.IP,
+			LogicalIp:  logicalIp,
+			ExternalIds: map[string]string{
+				externalKeyOcRef: ocNatRef,
+			},
+		})
+		addRoute(logicalIp)
+	}
+
+	lbByProto := map[string]*ovn_nb.LoadBalancer{}
+	for _, dnat := range natgw.NatDEntries {
+		proto := strings.ToLower(dnat.IpProtocol)
+		switch proto {
+		case "tcp", "udp":
+		default:
+			entryErrs[dnat.Id] = errors.Errorf("natdentry %s(%s): unsupported protocol %q", dnat.Name, dnat.Id, dnat.IpProtocol)
+			continue
+		}
+		lb, ok := lbByProto[proto]
+		if !ok {
+			lb = &ovn_nb.LoadBalancer{
+				Name:     natgwLbName(natgw.Id, proto),
+				Protocol: ptr(proto),
+				Vips:     map[string]string{},
+			}
+			lbByProto[proto] = lb
+			lbs = append(lbs, lb)
+		}
+		vip := fmt.Sprintf("%s:%d", dnat.ExternalIP, dnat.ExternalPort)
+		lb.Vips[vip] = fmt.Sprintf("%s:%d", dnat.InternalIP, dnat.InternalPort)
+		addRoute(dnat.InternalIP + "/32")
+	}
+	return nats, lbs, routes, entryErrs
+}
+
+// ClaimVpcNatGateway realizes snat/dnat entries of nat gateway on the vpc
+// external router.  Valid entries are claimed even if some of the others
+// cannot be realized, errors of the latter are returned keyed by entry id.
+// The returned error is set only when the rows failed to be claimed
+func (keeper *OVNNorthboundKeeper) ClaimVpcNatGateway(ctx context.Context, natgw *agentmodels.NatGateway) (map[string]error, error) {
+	var (
+		vpc       = natgw.Vpc
+		lrName    = vpcExtLrName(vpc.Id)
+		ocVersion = fmt.Sprintf("%s.%d", natgw.UpdatedAt, natgw.UpdateVersion)
+	)
+
+	nats, lbs, routes, entryErrs := natGatewayRows(natgw)
+	var irows []types.IRow
+	for _, nat := range nats {
+		irows = append(irows, nat)
+	}
+	for _, lb := range lbs {
+		irows = append(irows, lb)
+	}
+	for _, route := range routes {
+		irows = append(irows, route)
+	}
+	if len(irows) == 0 {
+		return entryErrs, nil
+	}
+	allFound, args := cmp(&keeper.DB, ocVersion, irows...)
+	if allFound {
+		return entryErrs, nil
+	}
+	for i, nat := range nats {
+		ref := fmt.Sprintf("nat%d", i)
+		args = append(args, ovnCreateArgs(nat, ref)...)
+		args = append(args, "--", "add", "Logical_Router", lrName, "nat", "@"+ref)
+	}
+	for i, lb := range lbs {
+		ref := fmt.Sprintf("natLb%d", i)
+		args = append(args, ovnCreateArgs(lb, ref)...)
+		args = append(args, "--", "add", "Logical_Router", lrName, "load_balancer", "@"+ref)
+	}
+	for i, route := range routes {
+		ref := fmt.Sprintf("natRoute%d", i)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", lrName, "static_routes", "@"+ref)
+	}
+	if res := keeper.cli.Must(ctx, "ClaimVpcNatGateway", args); res != nil && res.Err != nil {
+		return entryErrs, res
+	}
+	return entryErrs, nil
+}
+
+// vpcPeeringRows generates the pair of peer router ports on the allocated
+// /30 of the peering connection, and routes to networks of each vpc through
+// them from the other side
+func vpcPeeringRows(peering *agentmodels.VpcPeeringConnection) (*ovn_nb.LogicalRouterPort, *ovn_nb.LogicalRouterPort, []*ovn_nb.LogicalRouterStaticRoute, []*ovn_nb.LogicalRouterStaticRoute, error) {
+	var (
+		vpc        = peering.Vpc
+		peerVpc    = peering.PeerVpc
+		ocRouteRef = fmt.Sprintf("peeringRoute/%s", peering.Id)
+	)
+
+	if peering.OvnInterIpAddr == "" {
+		return nil, nil, nil, nil, errors.Errorf("vpc peering connection %s(%s): no inter ip addr allocated",
+			peering.Name, peering.Id)
+	}
+	interIp, err := netutils.NewIPV4Addr(peering.OvnInterIpAddr)
+	if err != nil {
+		return nil, nil, nil, nil, errors.Wrapf(err, "vpc peering connection %s(%s): inter ip addr %q",
+			peering.Name, peering.Id, peering.OvnInterIpAddr)
+	}
+	var (
+		vpcIp  = interIp + 1
+		peerIp = interIp + 2
+	)
+
+	vpcRp := &ovn_nb.LogicalRouterPort{
+		Name:     vpcPeerRpName(peering.Id, vpc.Id),
+		Mac:      mac.HashVpcPeeringRouterPortMac(peering.Id, vpc.Id),
+		Networks: []string{fmt.Sprintf("%s/%d", vpcIp, apis.VpcPeeringIPMask)},
+		Peer:     ptr(vpcPeerRpName(peering.Id, peerVpc.Id)),
+	}
+	peerRp := &ovn_nb.LogicalRouterPort{
+		Name:     vpcPeerRpName(peering.Id, peerVpc.Id),
+		Mac:      mac.HashVpcPeeringRouterPortMac(peering.Id, peerVpc.Id),
+		Networks: []string{fmt.Sprintf("%s/%d", peerIp, apis.VpcPeeringIPMask)},
+		Peer:     ptr(vpcPeerRpName(peering.Id, vpc.Id)),
+	}
+
+	routesTo := func(dstVpc *agentmodels.Vpc, nexthop netutils.IPV4Addr, outputPort string) []*ovn_nb.LogicalRouterStaticRoute {
+		var routes []*ovn_nb.LogicalRouterStaticRoute
+		for _, network := range dstVpc.Networks {
+			ipAddr, err := netutils.NewIPV4Addr(network.GuestIpStart)
+			if err != nil {
+				log.Errorf("vpc peering connection %s(%s): network %s(%s) guest ip start: %v",
+					peering.Name, peering.Id, network.Name, network.Id, err)
+				continue
+			}
+			prefix := netutils.NewIPV4PrefixFromAddr(ipAddr, network.GuestIpMask)
+			routes = append(routes, &ovn_nb.LogicalRouterStaticRoute{
+				Policy:     ptr("dst-ip"),
+				IpPrefix:   prefix.String(),
+				Nexthop:    nexthop.String(),
+				OutputPort: ptr(outputPort),
+				ExternalIds: map[string]string{
+					externalKeyOcRef: ocRouteRef,
+				},
+			})
+		}
+		return routes
+	}
+	vpcRoutes := routesTo(peerVpc, peerIp, vpcRp.Name)
+	peerRoutes := routesTo(vpc, vpcIp, peerRp.Name)
+	return vpcRp, peerRp, vpcRoutes, peerRoutes, nil
+}
+
+// ClaimVpcPeering connects logical routers of both vpcs of the peering
+// connection with a pair of peer router ports, and routes networks of each
+// vpc through them to the other side
+func (keeper *OVNNorthboundKeeper) ClaimVpcPeering(ctx context.Context, peering *agentmodels.VpcPeeringConnection) error {
+	var (
+		vpc       = peering.Vpc
+		peerVpc   = peering.PeerVpc
+		ocVersion = fmt.Sprintf("%s.%d", peering.UpdatedAt, peering.UpdateVersion)
+	)
+
+	vpcRp, peerRp, vpcRoutes, peerRoutes, err := vpcPeeringRows(peering)
+	if err != nil {
+		return err
+	}
+
+	irows := []types.IRow{vpcRp, peerRp}
+	for _, route := range vpcRoutes {
+		irows = append(irows, route)
+	}
+	for _, route := range peerRoutes {
+		irows = append(irows, route)
+	}
+	allFound, args := cmp(&keeper.DB, ocVersion, irows...)
+	if allFound {
+		return nil
+	}
+	var (
+		vpcLr  = vpcLrName(vpc.Id)
+		peerLr = vpcLrName(peerVpc.Id)
+	)
+	args = append(args, ovnCreateArgs(vpcRp, "vpcRp")...)
+	args = append(args, ovnCreateArgs(peerRp, "peerRp")...)
+	args = append(args, "--", "add", "Logical_Router", vpcLr, "ports", "@vpcRp")
+	args = append(args, "--", "add", "Logical_Router", peerLr, "ports", "@peerRp")
+	for i, route := range vpcRoutes {
+		ref := fmt.Sprintf("vpcRoute%d", i)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", vpcLr, "static_routes", "@"+ref)
+	}
+	for i, route := range peerRoutes {
+		ref := fmt.Sprintf("peerRoute%d", i)
+		args = append(args, ovnCreateArgs(route, ref)...)
+		args = append(args, "--", "add", "Logical_Router", peerLr, "static_routes", "@"+ref)
+	}
+	if res := keeper.cli.Must(ctx, "ClaimVpcPeering", args); res != nil && res.Err != nil {
+		return res
+	}
+	return nil
+}
+
 func (keeper *OVNNorthboundKeeper) ClaimLoadbalancerNetwork(ctx context.Context, loadbalancerNetwork *agentmodels.LoadbalancerNetwork) error {
 	var (
 		// Callers assure that loadbalancerNetwork.Network is not nil
@@ -1216,6 +1474,8 @@ func (keeper *OVNNorthboundKeeper) Mark(ctx context.Context) {
 		&db.DHCPOptions,
 		&db.QoS,
 		&db.DNS,
+		&db.NAT,
+		&db.LoadBalancer,
 	}
 	for _, itbl := range itbls {
 		for _, irow := range itbl.Rows() {
@@ -1234,6 +1494,7 @@ func (keeper *OVNNorthboundKeeper) Sweep(ctx context.Context) error {
 		&db.LogicalRouter,
 		&db.DHCPOptions,
 		&db.DNS,
+		&db.LoadBalancer,
 	}
 	var irows []types.IRow
 	for _, itbl := range itbls {
@@ -1291,5 +1552,19 @@ func (keeper *OVNNorthboundKeeper) Sweep(ctx context.Context) error {
 			keeper.cli.Must(ctx, "Sweep qos", args)
 		}
 	}
+	{ //  remove unused NAT rows
+		var args []string
+		for _, irow := range db.NAT.Rows() {
+			_, ok := irow.GetExternalId(externalKeyOcVersion)
+			if !ok {
+				for _, lr := range db.LogicalRouter.FindNATReferrer_nat(irow.OvsdbUuid()) {
+					args = append(args, "--", "--if-exists", "remove", "Logical_Router", lr.Name, "nat", irow.OvsdbUuid())
+				}
+			}
+		}
+		if len(args) > 0 {
+			keeper.cli.Must(ctx, "Sweep nat", args)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,261 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package ovn
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/ovsdb/schema/ovn_nb"
+
+	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
+	"yunion.io/x/onecloud/pkg/vpcagent/ovn/mac"
+)
+
+func testNatGateway(snats []*agentmodels.NatSEntry, dnats []*agentmodels.NatDEntry) *agentmodels.NatGateway {
+	vpc := &agentmodels.Vpc{}
+	vpc.Id = "vpc0"
+	natgw := &agentmodels.NatGateway{
+		Vpc:         vpc,
+		NatSEntries: agentmodels.NatSEntries{},
+		NatDEntries: agentmodels.NatDEntries{},
+	}
+	natgw.Id = "natgw0"
+	for i, snat := range snats {
+		snat.Id = fmt.Sprintf("snat%d", i)
+		natgw.NatSEntries[snat.Id] = snat
+	}
+	for i, dnat := range dnats {
+		dnat.Id = fmt.Sprintf("dnat%d", i)
+		natgw.NatDEntries[dnat.Id] = dnat
+	}
+	return natgw
+}
+
+func testNatSEntry(sourceCidr string, network *agentmodels.Network) *agentmodels.NatSEntry {
+	snat := &agentmodels.NatSEntry{Network: network}
+	snat.IP = "10.168.10.10"
+	snat.SourceCIDR = sourceCidr
+	return snat
+}
+
+func testNatDEntry(proto string, externalPort int, internalIp string) *agentmodels.NatDEntry {
+	dnat := &agentmodels.NatDEntry{}
+	dnat.IpProtocol = proto
+	dnat.ExternalIP = "10.168.10.10"
+	dnat.ExternalPort = externalPort
+	dnat.InternalIP = internalIp
+	dnat.InternalPort = 22
+	return dnat
+}
+
+func testNetwork(guestIpStart string, guestIpMask int8) *agentmodels.Network {
+	network := &agentmodels.Network{}
+	network.GuestIpStart = guestIpStart
+	network.GuestIpMask = guestIpMask
+	return network
+}
+
+func testNatRoute(ipPrefix string) *ovn_nb.LogicalRouterStaticRoute {
+	return &ovn_nb.LogicalRouterStaticRoute{
+		Policy:     ptr("src-ip"),
+		IpPrefix:   ipPrefix,
+		Nexthop:    "100.64.128.3",
+		OutputPort: ptr(vpcRepName("vpc0")),
+		ExternalIds: map[string]string{
+			externalKeyOcRef: "natRoute/natgw0",
+		},
+	}
+}
+
+func TestNatGatewayRows(t *testing.T) {
+	snatRow := func(logicalIp string) *ovn_nb.NAT {
+		return &ovn_nb.NAT{
+			Type:       "snat",
+			ExternalIp: "10.168.10.10",
+			LogicalIp:  logicalIp,
+			ExternalIds: map[string]string{
+				externalKeyOcRef: "nat/natgw0",
+			},
+		}
+	}
+	cases := []struct {
+		name   string
+		natgw  *agentmodels.NatGateway
+		nats   []*ovn_nb.NAT
+		lbs    []*ovn_nb.LoadBalancer
+		routes []*ovn_nb.LogicalRouterStaticRoute
+		errIds []string
+	}{
+		{
+			name: "snat by source cidr and network",
+			natgw: testNatGateway([]*agentmodels.NatSEntry{
+				testNatSEntry("192.168.1.0/24", nil),
+				testNatSEntry("", testNetwork("192.168.2.1", 24)),
+			}, nil),
+			nats: []*ovn_nb.NAT{
+				snatRow("192.168.1.0/24"),
+				snatRow("192.168.2.0/24"),
+			},
+			routes: []*ovn_nb.LogicalRouterStaticRoute{
+				testNatRoute("192.168.1.0/24"),
+				testNatRoute("192.168.2.0/24"),
+			},
+		},
+		{
+			name: "dnat vips grouped by protocol",
+			natgw: testNatGateway(nil, []*agentmodels.NatDEntry{
+				testNatDEntry("TCP", 2222, "192.168.1.2"),
+				testNatDEntry("tcp", 2223, "192.168.1.3"),
+				testNatDEntry("udp", 53, "192.168.1.2"),
+			}),
+			lbs: []*ovn_nb.LoadBalancer{
+				{
+					Name:     natgwLbName("natgw0", "tcp"),
+					Protocol: ptr("tcp"),
+					Vips: map[string]string{
+						"10.168.10.10:2222": "192.168.1.2:22",
+						"10.168.10.10:2223": "192.168.1.3:22",
+					},
+				},
+				{
+					Name:     natgwLbName("natgw0", "udp"),
+					Protocol: ptr("udp"),
+					Vips: map[string]string{
+						"10.168.10.10:53": "192.168.1.2:22",
+					},
+				},
+			},
+			routes: []*ovn_nb.LogicalRouterStaticRoute{
+				testNatRoute("192.168.1.2/32"),
+				testNatRoute("192.168.1.3/32"),
+			},
+		},
+		{
+			name: "invalid entries skipped",
+			natgw: testNatGateway([]*agentmodels.NatSEntry{
+				testNatSEntry("", nil),
+				testNatSEntry("192.168.1.0/24", nil),
+			}, []*agentmodels.NatDEntry{
+				testNatDEntry("icmp", 0, "192.168.1.2"),
+			}),
+			nats: []*ovn_nb.NAT{
+				snatRow("192.168.1.0/24"),
+			},
+			routes: []*ovn_nb.LogicalRouterStaticRoute{
+				testNatRoute("192.168.1.0/24"),
+			},
+			errIds: []string{"dnat0", "snat0"},
+		},
+	}
+	for _, c := range cases {
+		nats, lbs, routes, entryErrs := natGatewayRows(c.natgw)
+		// entries are kept in maps, sort the rows for comparison
+		sort.Slice(nats, func(i, j int) bool { return nats[i].LogicalIp < nats[j].LogicalIp })
+		sort.Slice(lbs, func(i, j int) bool { return lbs[i].Name < lbs[j].Name })
+		sort.Slice(routes, func(i, j int) bool { return routes[i].IpPrefix < routes[j].IpPrefix })
+		var errIds []string
+		for id := range entryErrs {
+			errIds = append(errIds, id)
+		}
+		sort.Strings(errIds)
+		if !reflect.DeepEqual(errIds, c.errIds) {
+			t.Errorf("%s: failed entries want: %v got: %v", c.name, c.errIds, errIds)
+		}
+		if !reflect.DeepEqual(nats, c.nats) {
+			t.Errorf("%s: nat want: %s got: %s", c.name, jsonutils.Marshal(c.nats), jsonutils.Marshal(nats))
+		}
+		if !reflect.DeepEqual(lbs, c.lbs) {
+			t.Errorf("%s: lb want: %s got: %s", c.name, jsonutils.Marshal(c.lbs), jsonutils.Marshal(lbs))
+		}
+		if !reflect.DeepEqual(routes, c.routes) {
+			t.Errorf("%s: route want: %s got: %s", c.name, jsonutils.Marshal(c.routes), jsonutils.Marshal(routes))
+		}
+	}
+}
+
+func TestVpcPeeringRows(t *testing.T) {
+	vpc := &agentmodels.Vpc{
+		Networks: agentmodels.Networks{
+			"net0": testNetwork("192.168.1.1", 24),
+		},
+	}
+	vpc.Id = "vpc0"
+	peerVpc := &agentmodels.Vpc{
+		Networks: agentmodels.Networks{
+			"net1": testNetwork("10.0.0.1", 16),
+		},
+	}
+	peerVpc.Id = "vpc1"
+	peering := &agentmodels.VpcPeeringConnection{
+		Vpc:     vpc,
+		PeerVpc: peerVpc,
+	}
+	peering.Id = "peering0"
+	peering.OvnInterIpAddr = "100.65.0.4"
+
+	route := func(ipPrefix, nexthop, outputPort string) *ovn_nb.LogicalRouterStaticRoute {
+		return &ovn_nb.LogicalRouterStaticRoute{
+			Policy:     ptr("dst-ip"),
+			IpPrefix:   ipPrefix,
+			Nexthop:    nexthop,
+			OutputPort: ptr(outputPort),
+			ExternalIds: map[string]string{
+				externalKeyOcRef: "peeringRoute/peering0",
+			},
+		}
+	}
+	var (
+		vpcRpName  = vpcPeerRpName("peering0", "vpc0")
+		peerRpName = vpcPeerRpName("peering0", "vpc1")
+	)
+	want := []interface{}{
+		&ovn_nb.LogicalRouterPort{
+			Name:     vpcRpName,
+			Mac:      mac.HashVpcPeeringRouterPortMac("peering0", "vpc0"),
+			Networks: []string{"100.65.0.5/30"},
+			Peer:     ptr(peerRpName),
+		},
+		&ovn_nb.LogicalRouterPort{
+			Name:     peerRpName,
+			Mac:      mac.HashVpcPeeringRouterPortMac("peering0", "vpc1"),
+			Networks: []string{"100.65.0.6/30"},
+			Peer:     ptr(vpcRpName),
+		},
+		[]*ovn_nb.LogicalRouterStaticRoute{route("10.0.0.0/16", "100.65.0.6", vpcRpName)},
+		[]*ovn_nb.LogicalRouterStaticRoute{route("192.168.1.0/24", "100.65.0.5", peerRpName)},
+	}
+
+	vpcRp, peerRp, vpcRoutes, peerRoutes, err := vpcPeeringRows(peering)
+	if err != nil {
+		t.Fatalf("vpcPeeringRows: %v", err)
+	}
+	got := []interface{}{vpcRp, peerRp, vpcRoutes, peerRoutes}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("want: %s got: %s", jsonutils.Marshal(want[i]), jsonutils.Marshal(got[i]))
+		}
+	}
+
+	for _, addr := range []string{"", "100.65.0"} {
+		peering.OvnInterIpAddr = addr
+		if _, _, _, _, err := vpcPeeringRows(peering); err == nil {
+			t.Errorf("peering with inter ip addr %q should fail", addr)
+		}
+	}
+}
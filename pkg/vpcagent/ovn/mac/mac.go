@@ -39,6 +39,10 @@ func HashVpcHostDistgwMac(hostId string) string {
 	return HashMac(hostId)
 }
 
+func HashVpcPeeringRouterPortMac(peeringId string, vpcId string) string {
+	return HashMac(peeringId, vpcId, "rpeer")
+}
+
 func HashSubnetRouterPortMac(netId string) string {
 	return HashMac(netId, "rp")
 }
@@ -81,6 +81,16 @@ func vpcEipLspName(vpcId string, eipgwId string) string {
 	return fmt.Sprintf("vpc-ep/%s/%s", vpcId, eipgwId)
 }
 
+// natgw
+func natgwLbName(natgwId string, proto string) string {
+	return fmt.Sprintf("natgw-lb/%s/%s", natgwId, proto)
+}
+
+// peering
+func vpcPeerRpName(peeringId string, vpcId string) string {
+	return fmt.Sprintf("vpc-rpeer/%s/%s", peeringId, vpcId)
+}
+
 func netLsName(netId string) string {
 	return fmt.Sprintf("subnet/%s", netId)
 }
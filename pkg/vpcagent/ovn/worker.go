@@ -21,14 +21,17 @@ import (
 	"sync"
 	"time"
 
+	"yunion.io/x/jsonutils"
 	"yunion.io/x/log"
 	"yunion.io/x/pkg/errors"
 	"yunion.io/x/pkg/util/httputils"
+	"yunion.io/x/pkg/utils"
 
 	"yunion.io/x/onecloud/pkg/apihelper"
 	apis "yunion.io/x/onecloud/pkg/apis/compute"
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/mcclient/modulebase"
 	mcclient_modules "yunion.io/x/onecloud/pkg/mcclient/modules/compute"
 	"yunion.io/x/onecloud/pkg/util/ovsutils"
 	agentmodels "yunion.io/x/onecloud/pkg/vpcagent/models"
@@ -134,7 +137,7 @@ func (w *Worker) run(ctx context.Context, mss *agentmodels.ModelSets) (err error
 		if vpc.Id == apis.DEFAULT_VPC_ID {
 			continue
 		}
-		ovndb.ClaimVpc(ctx, vpc)
+		ovndb.ClaimVpc(ctx, vpc, w.opts)
 		if vpcHasEipgw(vpc) {
 			ovndb.ClaimVpcEipgw(ctx, vpc)
 		}
@@ -187,14 +190,83 @@ func (w *Worker) run(ctx context.Context, mss *agentmodels.ModelSets) (err error
 		}
 		routes := resolveRoutes(vpc, mss)
 		ovndb.ClaimRoutes(ctx, vpc, routes)
+		if vpcHasNatgw(vpc, w.opts) {
+			for _, natgw := range vpc.NatGateways {
+				entryErrs, err := ovndb.ClaimVpcNatGateway(ctx, natgw)
+				if err != nil {
+					log.Errorf("claim nat gateway %s(%s): %v", natgw.Name, natgw.Id, err)
+				}
+				for _, entryErr := range entryErrs {
+					log.Errorf("claim nat gateway %s(%s): %v", natgw.Name, natgw.Id, entryErr)
+				}
+				w.syncNatGatewayStatus(ctx, natgw, entryErrs, err)
+			}
+		} else if len(vpc.NatGateways) > 0 {
+			log.Warningf("vpc %s(%s) has nat gateways, but external access mode is %q and nat gateway chassis is %q",
+				vpc.Name, vpc.Id, vpc.ExternalAccessMode, w.opts.OvnNatGatewayChassis)
+		}
 	}
 	for _, vpc := range mss.Vpcs {
 		if vpc.Id == apis.DEFAULT_VPC_ID {
 			continue
 		}
+		for _, peering := range vpc.VpcPeeringConnections {
+			// pending ones wait for acceptance by the peer vpc owner
+			if peering.Status != apis.VPC_PEERING_CONNECTION_STATUS_ACTIVE || !peering.Enabled.IsTrue() {
+				continue
+			}
+			if peering.PeerVpc.Id == apis.DEFAULT_VPC_ID {
+				continue
+			}
+			ovndb.ClaimVpcPeering(ctx, peering)
+		}
 		ovndb.ClaimVpcGuestDnsRecords(ctx, vpc)
 	}
 	ovndb.ClaimDnsRecords(ctx, mss.Vpcs, mss.DnsRecords)
 	ovndb.Sweep(ctx)
 	return nil
 }
+
+// syncNatGatewayStatus marks nat gateway and its entries available after
+// their rules were claimed, or create_failed if the claim failed.  Entries
+// that cannot be realized are marked create_failed on their own.  Failed
+// ones become available again once the claim succeeds
+func (w *Worker) syncNatGatewayStatus(ctx context.Context, natgw *agentmodels.NatGateway, entryErrs map[string]error, claimErr error) {
+	s := auth.GetAdminSession(ctx, w.opts.Region)
+	setStatus := func(man modulebase.ResourceManager, id string, status *string, err error) {
+		var (
+			fromStatus = []string{apis.NAT_STATUS_ALLOCATE, apis.NAT_STATUS_UNKNOWN, apis.NAT_STATUS_CREATE_FAILED}
+			toStatus   = apis.NAT_STAUTS_AVAILABLE
+			reason     = "ovn rules claimed"
+		)
+		if err != nil {
+			fromStatus = []string{apis.NAT_STATUS_ALLOCATE, apis.NAT_STATUS_UNKNOWN}
+			toStatus = apis.NAT_STATUS_CREATE_FAILED
+			reason = err.Error()
+		}
+		if !utils.IsInStringArray(*status, fromStatus) {
+			return
+		}
+		params := jsonutils.NewDict()
+		params.Set("status", jsonutils.NewString(toStatus))
+		params.Set("reason", jsonutils.NewString(reason))
+		if _, err := man.PerformAction(s, id, "status", params); err != nil {
+			log.Errorf("%s %s set status: %v", man.GetKeyword(), id, err)
+			return
+		}
+		*status = toStatus // update local copy in place
+	}
+	entryErr := func(id string) error {
+		if claimErr != nil {
+			return claimErr
+		}
+		return entryErrs[id]
+	}
+	setStatus(mcclient_modules.NatGateways, natgw.Id, &natgw.Status, claimErr)
+	for _, snat := range natgw.NatSEntries {
+		setStatus(mcclient_modules.NatSTable, snat.Id, &snat.Status, entryErr(snat.Id))
+	}
+	for _, dnat := range natgw.NatDEntries {
+		setStatus(mcclient_modules.NatDTable, dnat.Id, &dnat.Status, entryErr(dnat.Id))
+	}
+}
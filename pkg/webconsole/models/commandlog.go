@@ -33,7 +33,8 @@ var commandLogManager *SCommandLogManager
 type CommandType string
 
 const (
-	CommandTypeSSH = "ssh"
+	CommandTypeSSH     = "ssh"
+	CommandTypeSession = "session"
 )
 
 func InitCommandLog() {
@@ -27,6 +27,7 @@ func InitDB() error {
 		 * initialization order matters, do not change the order
 		 */
 		GetCommandLogManager(),
+		GetSessionRecordingManager(),
 	} {
 		err := manager.InitializeData()
 		if err != nil {
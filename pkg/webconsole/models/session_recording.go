@@ -0,0 +1,424 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/sqlchemy"
+
+	"yunion.io/x/onecloud/pkg/apis"
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/asciicast"
+	"yunion.io/x/onecloud/pkg/util/stringutils2"
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+	"yunion.io/x/onecloud/pkg/webconsole/storage"
+)
+
+var sessionRecordingManager *SSessionRecordingManager
+
+func GetSessionRecordingManager() *SSessionRecordingManager {
+	if sessionRecordingManager != nil {
+		return sessionRecordingManager
+	}
+	sessionRecordingManager = &SSessionRecordingManager{
+		SUserResourceBaseManager: db.NewUserResourceBaseManager(
+			SSessionRecording{},
+			"session_recordings_tbl",
+			"session_recording",
+			"session_recordings",
+		),
+	}
+	sessionRecordingManager.SetVirtualObject(sessionRecordingManager)
+	return sessionRecordingManager
+}
+
+type SSessionRecordingManager struct {
+	db.SUserResourceBaseManager
+	db.SStatusResourceBaseManager
+}
+
+// SSessionRecording is the full asciicast recording of a pty or ssh session,
+// it is owned by the user who opened the session, other users can't see it
+// unless they are granted by a system scope policy, e.g. admins and auditors
+type SSessionRecording struct {
+	db.SUserResourceBase
+	db.SStatusResourceBase
+
+	SessionId string `width:"128" charset:"ascii" index:"true" list:"user"`
+	ObjId     string `width:"128" charset:"ascii" list:"user"`
+	ObjType   string `width:"40" charset:"ascii" list:"user"`
+	ObjName   string `width:"256" charset:"utf8" list:"user"`
+	// name of the owner when the session was opened
+	User      string `width:"128" charset:"utf8" list:"user"`
+	LoginUser string `width:"128" charset:"utf8" list:"user"`
+	Protocol  string `width:"32" charset:"ascii" list:"user"`
+
+	StartedAt time.Time `nullable:"false" index:"true" list:"user"`
+	EndedAt   time.Time `nullable:"true" list:"user"`
+	// 录像时长, 单位秒
+	Duration  float64 `nullable:"false" default:"0" list:"user"`
+	Width     int     `nullable:"false" default:"0" list:"user"`
+	Height    int     `nullable:"false" default:"0" list:"user"`
+	SizeBytes int64   `nullable:"false" default:"0" list:"user"`
+
+	Backend     string `width:"16" charset:"ascii" nullable:"false" list:"user"`
+	StoragePath string `width:"256" charset:"utf8" nullable:"false" list:"admin"`
+}
+
+type SessionRecordingCreateInput struct {
+	SessionId string
+	ObjId     string
+	ObjType   string
+	ObjName   string
+	LoginUser string
+	Protocol  string
+	StartedAt time.Time
+	Width     int
+	Height    int
+}
+
+func (manager *SSessionRecordingManager) ValidateCreateData(ctx context.Context, userCred mcclient.TokenCredential, ownerId mcclient.IIdentityProvider, query jsonutils.JSONObject, input apis.UserResourceCreateInput) (apis.UserResourceCreateInput, error) {
+	return input, httperrors.NewUnsupportOperationError("session recordings are created by webconsole sessions")
+}
+
+// Create inserts a recording in recording status, the recording is stored
+// under the storage path on the configured backend once the session ends
+func (manager *SSessionRecordingManager) Create(ctx context.Context, userCred mcclient.TokenCredential, input *SessionRecordingCreateInput) (*SSessionRecording, error) {
+	record := &SSessionRecording{}
+	record.SetModelManager(manager, record)
+
+	hint := input.ObjName
+	if len(hint) == 0 {
+		hint = input.Protocol
+	}
+	name, err := db.GenerateName(ctx, manager, userCred, fmt.Sprintf("%s-%s", hint, input.StartedAt.Format("20060102150405")))
+	if err != nil {
+		return nil, errors.Wrap(err, "GenerateName")
+	}
+	record.Name = name
+	record.Status = api.SESSION_RECORDING_STATUS_RECORDING
+	record.OwnerId = userCred.GetUserId()
+	record.User = userCred.GetUserName()
+	record.SessionId = input.SessionId
+	record.ObjId = input.ObjId
+	record.ObjType = input.ObjType
+	record.ObjName = input.ObjName
+	record.LoginUser = input.LoginUser
+	record.Protocol = input.Protocol
+	record.StartedAt = input.StartedAt
+	record.Width = input.Width
+	record.Height = input.Height
+	record.Backend = o.Options.SessionRecordingBackend
+
+	err = manager.TableSpec().Insert(ctx, record)
+	if err != nil {
+		return nil, errors.Wrap(err, "insert session recording")
+	}
+	return record, nil
+}
+
+func (manager *SSessionRecordingManager) ListItemFilter(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.SessionRecordingListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SUserResourceBaseManager.ListItemFilter(ctx, q, userCred, query.UserResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SUserResourceBaseManager.ListItemFilter")
+	}
+	q, err = manager.SStatusResourceBaseManager.ListItemFilter(ctx, q, userCred, query.StatusResourceBaseListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SStatusResourceBaseManager.ListItemFilter")
+	}
+	if len(query.SessionId) > 0 {
+		q = q.In("session_id", query.SessionId)
+	}
+	if len(query.ObjId) > 0 {
+		q = q.In("obj_id", query.ObjId)
+	}
+	if len(query.ObjType) > 0 {
+		q = q.In("obj_type", query.ObjType)
+	}
+	if len(query.ObjName) > 0 {
+		q = q.In("obj_name", query.ObjName)
+	}
+	if len(query.LoginUser) > 0 {
+		q = q.In("login_user", query.LoginUser)
+	}
+	if len(query.Protocol) > 0 {
+		q = q.In("protocol", query.Protocol)
+	}
+	if !query.Since.IsZero() {
+		q = q.GE("started_at", query.Since)
+	}
+	if !query.Until.IsZero() {
+		q = q.LE("started_at", query.Until)
+	}
+	return q, nil
+}
+
+func (manager *SSessionRecordingManager) OrderByExtraFields(
+	ctx context.Context,
+	q *sqlchemy.SQuery,
+	userCred mcclient.TokenCredential,
+	query api.SessionRecordingListInput,
+) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SUserResourceBaseManager.OrderByExtraFields(ctx, q, userCred, query.UserResourceListInput)
+	if err != nil {
+		return nil, errors.Wrap(err, "SUserResourceBaseManager.OrderByExtraFields")
+	}
+	return q, nil
+}
+
+func (manager *SSessionRecordingManager) QueryDistinctExtraField(q *sqlchemy.SQuery, field string) (*sqlchemy.SQuery, error) {
+	var err error
+	q, err = manager.SUserResourceBaseManager.QueryDistinctExtraField(q, field)
+	if err == nil {
+		return q, nil
+	}
+	return q, httperrors.ErrNotFound
+}
+
+func (manager *SSessionRecordingManager) FetchCustomizeColumns(
+	ctx context.Context,
+	userCred mcclient.TokenCredential,
+	query jsonutils.JSONObject,
+	objs []interface{},
+	fields stringutils2.SSortedStrings,
+	isList bool,
+) []api.SessionRecordingDetails {
+	rows := make([]api.SessionRecordingDetails, len(objs))
+	userRows := manager.SUserResourceBaseManager.FetchCustomizeColumns(ctx, userCred, query, objs, fields, isList)
+	for i := range rows {
+		rows[i] = api.SessionRecordingDetails{
+			UserResourceDetails: userRows[i],
+		}
+	}
+	return rows
+}
+
+func (r *SSessionRecording) SetStatus(ctx context.Context, userCred mcclient.TokenCredential, status string, reason string) error {
+	return db.StatusBaseSetStatus(ctx, r, userCred, status, reason)
+}
+
+// SetSaved records the final size and duration after the recording has been
+// written to the storage path
+func (r *SSessionRecording) SetSaved(ctx context.Context, userCred mcclient.TokenCredential, storagePath string, endedAt time.Time, sizeBytes int64, status string) error {
+	_, err := db.Update(r, func() error {
+		r.StoragePath = storagePath
+		r.EndedAt = endedAt
+		r.Duration = endedAt.Sub(r.StartedAt).Seconds()
+		r.SizeBytes = sizeBytes
+		r.Status = status
+		return nil
+	})
+	return err
+}
+
+func (r *SSessionRecording) ValidateDeleteCondition(ctx context.Context, info jsonutils.JSONObject) error {
+	if r.Status == api.SESSION_RECORDING_STATUS_RECORDING || r.Status == api.SESSION_RECORDING_STATUS_SAVING {
+		return httperrors.NewInvalidStatusError("session recording is in status %s", r.Status)
+	}
+	return r.SUserResourceBase.ValidateDeleteCondition(ctx, nil)
+}
+
+func (r *SSessionRecording) CustomizeDelete(ctx context.Context, userCred mcclient.TokenCredential, query jsonutils.JSONObject, data jsonutils.JSONObject) error {
+	return r.removeStorage(ctx)
+}
+
+// GetSpoolPath returns the local file the recording is written to while the
+// session is alive
+func (r *SSessionRecording) GetSpoolPath() string {
+	return filepath.Join(o.Options.SessionRecordingDir, ".spool", r.Id+".cast")
+}
+
+// SaveSpool saves the spooled recording to the storage backend and returns
+// the storage path of the recording
+func (r *SSessionRecording) SaveSpool(ctx context.Context) (string, error) {
+	s, err := storage.GetStorage(r.Backend)
+	if err != nil {
+		return "", errors.Wrap(err, "GetStorage")
+	}
+	key := fmt.Sprintf("%s/%s.cast", r.StartedAt.Format("2006/01/02"), r.Id)
+	err = s.Save(ctx, r.GetSpoolPath(), key)
+	if err != nil {
+		return "", errors.Wrapf(err, "save to %s", key)
+	}
+	return key, nil
+}
+
+func (r *SSessionRecording) removeStorage(ctx context.Context) error {
+	if len(r.StoragePath) == 0 {
+		// never saved, e.g. the service was restarted during the session
+		if err := os.Remove(r.GetSpoolPath()); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "remove %s", r.GetSpoolPath())
+		}
+		return nil
+	}
+	s, err := storage.GetStorage(r.Backend)
+	if err != nil {
+		return errors.Wrap(err, "GetStorage")
+	}
+	err = s.Remove(ctx, r.StoragePath)
+	if err != nil {
+		return errors.Wrapf(err, "remove recording %s", r.StoragePath)
+	}
+	return nil
+}
+
+// Open returns the asciicast content of a saved recording
+func (r *SSessionRecording) Open(ctx context.Context) (io.ReadCloser, error) {
+	if r.Status != api.SESSION_RECORDING_STATUS_READY {
+		return nil, httperrors.NewInvalidStatusError("session recording is in status %s", r.Status)
+	}
+	s, err := storage.GetStorage(r.Backend)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetStorage")
+	}
+	return s.Open(ctx, r.StoragePath)
+}
+
+// GetDetailsReplay returns a page of asciicast events so that the frontend
+// can replay a long session without downloading the whole recording
+func (r *SSessionRecording) GetDetailsReplay(ctx context.Context, userCred mcclient.TokenCredential, query api.SessionRecordingReplayInput) (*api.SessionRecordingReplayOutput, error) {
+	if query.Offset < 0 {
+		return nil, httperrors.NewInputParameterError("invalid offset %d", query.Offset)
+	}
+	if query.Limit <= 0 {
+		query.Limit = api.SESSION_RECORDING_REPLAY_DEFAULT_LIMIT
+	}
+	if query.Limit > api.SESSION_RECORDING_REPLAY_MAX_LIMIT {
+		query.Limit = api.SESSION_RECORDING_REPLAY_MAX_LIMIT
+	}
+	reader, err := r.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+	defer reader.Close()
+
+	cast, err := asciicast.NewReader(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "asciicast.NewReader")
+	}
+	ret := &api.SessionRecordingReplayOutput{
+		Header: cast.Header,
+		Events: []jsonutils.JSONObject{},
+	}
+	for idx := 0; ; idx++ {
+		ev, err := cast.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "read event %d", idx)
+		}
+		if idx < query.Offset {
+			continue
+		}
+		if len(ret.Events) >= query.Limit {
+			ret.NextOffset = idx
+			break
+		}
+		ret.Events = append(ret.Events, jsonutils.NewArray(
+			jsonutils.NewFloat64(ev.Time),
+			jsonutils.NewString(string(ev.Type)),
+			jsonutils.NewString(ev.Data),
+		))
+	}
+	return ret, nil
+}
+
+// recover settles the recording left unsaved by the last run of the service,
+// the spooled events are saved as they are, it is marked as save failed if
+// nothing is spooled
+func (r *SSessionRecording) recover(ctx context.Context, userCred mcclient.TokenCredential) error {
+	info, err := os.Stat(r.GetSpoolPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Wrapf(err, "stat %s", r.GetSpoolPath())
+		}
+		return r.SetStatus(ctx, userCred, api.SESSION_RECORDING_STATUS_SAVE_FAILED, "service restarted before the recording is spooled")
+	}
+	status := api.SESSION_RECORDING_STATUS_READY
+	storagePath, err := r.SaveSpool(ctx)
+	if err != nil {
+		log.Errorf("save session recording %s: %v", r.Id, err)
+		status = api.SESSION_RECORDING_STATUS_SAVE_FAILED
+	}
+	return r.SetSaved(ctx, userCred, storagePath, info.ModTime(), info.Size(), status)
+}
+
+// RecoverRecordings settles the recordings left in recording or saving status
+// by the last run of the service, so that they can be replayed or deleted
+func (manager *SSessionRecordingManager) RecoverRecordings(ctx context.Context, userCred mcclient.TokenCredential) {
+	q := manager.Query().In("status", []string{api.SESSION_RECORDING_STATUS_RECORDING, api.SESSION_RECORDING_STATUS_SAVING})
+	records := []SSessionRecording{}
+	err := db.FetchModelObjects(manager, q, &records)
+	if err != nil {
+		log.Errorf("fetch unsaved session recordings: %v", err)
+		return
+	}
+	for i := range records {
+		if err := records[i].recover(ctx, userCred); err != nil {
+			log.Errorf("recover session recording %s: %v", records[i].Id, err)
+		}
+	}
+	if len(records) > 0 {
+		log.Infof("recovered %d unsaved session recordings", len(records))
+	}
+}
+
+// PurgeExpiredRecordings removes recordings older than the retention days
+// from both the storage backend and the database
+func (manager *SSessionRecordingManager) PurgeExpiredRecordings(ctx context.Context, userCred mcclient.TokenCredential, isStart bool) {
+	if o.Options.SessionRecordingRetentionDays <= 0 {
+		return
+	}
+	expiredAt := time.Now().AddDate(0, 0, -o.Options.SessionRecordingRetentionDays)
+	q := manager.Query().LT("started_at", expiredAt)
+	records := []SSessionRecording{}
+	err := db.FetchModelObjects(manager, q, &records)
+	if err != nil {
+		log.Errorf("fetch expired session recordings: %v", err)
+		return
+	}
+	for i := range records {
+		if err := records[i].removeStorage(ctx); err != nil {
+			log.Errorf("remove expired session recording %s: %v", records[i].Id, err)
+			continue
+		}
+		if err := records[i].Delete(ctx, userCred); err != nil {
+			log.Errorf("delete expired session recording %s: %v", records[i].Id, err)
+		}
+	}
+	if len(records) > 0 {
+		log.Infof("purged %d session recordings started before %s", len(records), expiredAt)
+	}
+}
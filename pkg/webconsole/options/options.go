@@ -36,6 +36,16 @@ type WebConsoleOptions struct {
 	RdpSessionTimeoutMinutes int `help:"rdp timeout session" default:"-1"`
 
 	EnableWatermark bool `help:"enable water mark" default:"false"`
+
+	EnableSessionRecording        bool   `help:"record the full screen output of pty and ssh sessions in asciicast v2 format" default:"false"`
+	SessionRecordingInput         bool   `help:"record user input events as well, which may contain passwords" default:"false"`
+	SessionRecordingBackend       string `help:"storage backend of session recordings" choices:"local|object" default:"local"`
+	SessionRecordingDir           string `help:"local directory of session recordings, also used as spool directory of object backend" default:"/opt/cloud/workspace/webconsole/recordings"`
+	SessionRecordingBucketUrl     string `help:"bucket url of object storage backend, e.g. https://bucket.oss.example.com or https://oss.example.com/bucket"`
+	SessionRecordingAccessKey     string `help:"access key of object storage backend"`
+	SessionRecordingSecret        string `help:"secret of object storage backend"`
+	SessionRecordingMaxSizeMb     int    `help:"stop recording a session when its recording exceeds this size" default:"1024"`
+	SessionRecordingRetentionDays int    `help:"days to keep session recordings, 0 means forever" default:"180"`
 }
 
 func OnOptionsChange(oldO, newO interface{}) bool {
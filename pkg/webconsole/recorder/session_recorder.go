@@ -0,0 +1,177 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/util/asciicast"
+	"yunion.io/x/onecloud/pkg/webconsole/models"
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+)
+
+// SSessionRecorder records the whole terminal session in asciicast v2 format,
+// events are spooled to a local file and saved to the storage backend on Close
+type SSessionRecorder struct {
+	cs     *mcclient.ClientSession
+	record *models.SSessionRecording
+
+	file   *os.File
+	writer *asciicast.SWriter
+
+	recordInput bool
+	maxSize     int64
+	stopped     bool
+	lock        *sync.Mutex
+}
+
+func NewSessionRecorder(s *mcclient.ClientSession, obj *Object, sessionId string, protocol string, width, height int) (*SSessionRecorder, error) {
+	if width <= 0 || height <= 0 {
+		width, height = asciicast.DEFAULT_WIDTH, asciicast.DEFAULT_HEIGHT
+	}
+	startedAt := time.Now()
+	input := &models.SessionRecordingCreateInput{
+		SessionId: sessionId,
+		Protocol:  protocol,
+		StartedAt: startedAt,
+		Width:     width,
+		Height:    height,
+	}
+	if obj != nil {
+		input.ObjId = obj.Id
+		input.ObjName = obj.Name
+		input.ObjType = obj.Type
+		input.LoginUser = obj.LoginUser
+	}
+	record, err := models.GetSessionRecordingManager().Create(s.GetContext(), s.GetToken(), input)
+	if err != nil {
+		return nil, errors.Wrap(err, "create session recording")
+	}
+	spoolPath := record.GetSpoolPath()
+	if err := os.MkdirAll(filepath.Dir(spoolPath), 0755); err != nil {
+		return nil, errors.Wrapf(err, "mkdir %s", filepath.Dir(spoolPath))
+	}
+	file, err := os.OpenFile(spoolPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", spoolPath)
+	}
+	header := asciicast.SHeader{
+		Width:  width,
+		Height: height,
+		Title:  record.Name,
+		Env: map[string]string{
+			"TERM": "xterm-256color",
+		},
+	}
+	writer, err := asciicast.NewWriter(file, header, startedAt)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "asciicast.NewWriter")
+	}
+	return &SSessionRecorder{
+		cs:          s,
+		record:      record,
+		file:        file,
+		writer:      writer,
+		recordInput: o.Options.SessionRecordingInput,
+		maxSize:     int64(o.Options.SessionRecordingMaxSizeMb) * 1024 * 1024,
+		lock:        new(sync.Mutex),
+	}, nil
+}
+
+func (r *SSessionRecorder) write(f func() error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stopped {
+		return
+	}
+	if r.maxSize > 0 && r.writer.Size() > r.maxSize {
+		log.Warningf("session recording %s exceeds %d bytes, stop recording", r.record.Id, r.maxSize)
+		r.writer.WriteEvent(time.Since(r.record.StartedAt), asciicast.EventMarker, []byte("recording size limit exceeded"))
+		r.stopped = true
+		return
+	}
+	if err := f(); err != nil {
+		log.Errorf("write session recording %s: %v", r.record.Id, err)
+		r.stopped = true
+	}
+}
+
+func (r *SSessionRecorder) WriteOutput(data []byte) {
+	r.write(func() error {
+		return r.writer.WriteOutput(data)
+	})
+}
+
+func (r *SSessionRecorder) WriteInput(data []byte) {
+	if !r.recordInput {
+		return
+	}
+	r.write(func() error {
+		return r.writer.WriteInput(data)
+	})
+}
+
+func (r *SSessionRecorder) Resize(cols, rows int) {
+	r.write(func() error {
+		return r.writer.WriteResize(cols, rows)
+	})
+}
+
+// Close stops recording and saves the recording to the storage backend in
+// background, so that closing a session is never blocked by uploading
+func (r *SSessionRecorder) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	r.stopped = true
+	endedAt := time.Now()
+	if err := r.writer.Flush(); err != nil {
+		log.Errorf("flush session recording %s: %v", r.record.Id, err)
+	}
+	if err := r.file.Close(); err != nil {
+		log.Errorf("close session recording %s: %v", r.record.Id, err)
+	}
+	r.file = nil
+
+	ctx := context.Background()
+	userCred := r.cs.GetToken()
+	size := r.writer.Size()
+	r.record.SetStatus(ctx, userCred, api.SESSION_RECORDING_STATUS_SAVING, "")
+	go func() {
+		status := api.SESSION_RECORDING_STATUS_READY
+		storagePath, err := r.record.SaveSpool(ctx)
+		if err != nil {
+			log.Errorf("save session recording %s: %v", r.record.Id, err)
+			status = api.SESSION_RECORDING_STATUS_SAVE_FAILED
+		}
+		if err := r.record.SetSaved(ctx, userCred, storagePath, endedAt, size, status); err != nil {
+			log.Errorf("update session recording %s: %v", r.record.Id, err)
+		}
+	}()
+}
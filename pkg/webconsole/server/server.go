@@ -49,6 +49,11 @@ func (s *ConnectionServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		httperrors.BadRequestError(ctx, w, "Empty access_token")
 		return
 	}
+	if viewer, ok := session.Manager.GetViewer(accessToken); ok {
+		srv, _ := NewViewerServer(viewer)
+		srv.ServeHTTP(w, req)
+		return
+	}
 	sessionObj, ok := session.Manager.Get(accessToken)
 	if !ok {
 		httperrors.NotFoundError(ctx, w, "session not found")
@@ -48,6 +48,7 @@ type WebsocketServer struct {
 	conn      *ssh.Client
 	sftp      *sftp.Client
 	timer     *time.Timer
+	wsWriter  *WebSocketBufferWriter
 }
 
 func NewSshServer(s *session.SSession) (*WebsocketServer, error) {
@@ -63,6 +64,34 @@ func NewSshServer(s *session.SSession) (*WebsocketServer, error) {
 	return server, nil
 }
 
+// wsInput is the message sent by the websocket terminal
+type wsInput struct {
+	Type string `json:"type" choices:"resize|input|heartbeat|close"`
+	Data struct {
+		Cols   int
+		Rows   int
+		Data   string `json:"data"`
+		Base64 bool
+	}
+}
+
+func parseWsInput(p []byte) (*wsInput, error) {
+	input := &wsInput{}
+	obj, err := jsonutils.Parse(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse %s", string(p))
+	}
+	err = obj.Unmarshal(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unmarshal %s", string(p))
+	}
+	if input.Type == "input" && input.Data.Base64 {
+		data, _ := base64.StdEncoding.DecodeString(input.Data.Data)
+		input.Data.Data = string(data)
+	}
+	return input, nil
+}
+
 type WebSocketBufferWriter struct {
 	s    *session.SSession
 	ws   *websocket.Conn
@@ -74,6 +103,8 @@ func (w *WebSocketBufferWriter) Write(p []byte) (int, error) {
 	defer w.lock.Unlock()
 
 	go w.s.GetRecorder().Write("", string(p))
+	w.s.RecordOutput(p)
+	w.s.Broadcast(p)
 	err := w.ws.WriteMessage(websocket.BinaryMessage, p)
 	if err != nil {
 		return 0, err
@@ -81,6 +112,17 @@ func (w *WebSocketBufferWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// ShowMessage writes msg to the terminal without recording it
+func (w *WebSocketBufferWriter) ShowMessage(msg string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	err := w.ws.WriteMessage(websocket.BinaryMessage, []byte(msg))
+	if err != nil {
+		log.Errorf("show message %q error: %v", msg, err)
+	}
+}
+
 func (s *WebsocketServer) initWs(w http.ResponseWriter, r *http.Request) error {
 	username := s.Username
 	privateKey := s.PrivateKey
@@ -135,13 +177,13 @@ func (s *WebsocketServer) initWs(w http.ResponseWriter, r *http.Request) error {
 		return errors.Wrapf(err, "upgrade")
 	}
 
-	wsWriter := WebSocketBufferWriter{
+	s.wsWriter = &WebSocketBufferWriter{
 		s:  s.Session,
 		ws: s.ws,
 	}
 
-	s.session.Stdout = &wsWriter
-	s.session.Stderr = &wsWriter
+	s.session.Stdout = s.wsWriter
+	s.session.Stderr = s.wsWriter
 
 	modes := ssh.TerminalModes{
 		ssh.ECHO:          1,
@@ -154,6 +196,8 @@ func (s *WebsocketServer) initWs(w http.ResponseWriter, r *http.Request) error {
 		return errors.Wrapf(err, "request pty xterm")
 	}
 
+	s.Session.StartRecording(120, 32)
+
 	err = s.session.Shell()
 	if err != nil {
 		return errors.Wrapf(err, "Shell")
@@ -168,6 +212,19 @@ func (s *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.Session.SetConnection(&session.SSessionConnection{
+		WriteInput: func(data []byte) error {
+			go s.Session.GetRecorder().Write(string(data), "")
+			s.Session.RecordInput(data)
+			_, err := s.StdinPipe.Write(data)
+			return err
+		},
+		ShowMessage: s.wsWriter.ShowMessage,
+		Terminate: func() {
+			s.ws.Close()
+		},
+	})
+
 	done := make(chan bool, 3)
 	setDone := func() { done <- true }
 
@@ -182,23 +239,9 @@ func (s *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if options.Options.SshSessionTimeoutMinutes > 0 && s.timer != nil {
 				s.timer.Reset(time.Duration(options.Options.SshSessionTimeoutMinutes) * time.Minute)
 			}
-			input := struct {
-				Type string `json:"type" choices:"resize|input|heartbeat"`
-				Data struct {
-					Cols   int
-					Rows   int
-					Data   string `json:"data"`
-					Base64 bool
-				}
-			}{}
-			obj, err := jsonutils.Parse(p)
-			if err != nil {
-				log.Errorf("parse %s error: %v", string(p), err)
-				continue
-			}
-			err = obj.Unmarshal(&input)
+			input, err := parseWsInput(p)
 			if err != nil {
-				log.Errorf("unmarshal %s error: %v", string(p), err)
+				log.Errorf("%v", err)
 				continue
 			}
 
@@ -209,13 +252,12 @@ func (s *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				err = s.session.WindowChange(input.Data.Rows, input.Data.Cols)
 				if err != nil {
 					log.Errorf("resize %dx%d error: %v", input.Data.Cols, input.Data.Rows, err)
+				} else {
+					s.Session.RecordResize(input.Data.Cols, input.Data.Rows)
 				}
 			case "input":
-				if input.Data.Base64 {
-					data, _ := base64.StdEncoding.DecodeString(input.Data.Data)
-					input.Data.Data = string(data)
-				}
 				go s.Session.GetRecorder().Write(input.Data.Data, "")
+				s.Session.RecordInput([]byte(input.Data.Data))
 				_, err = s.StdinPipe.Write([]byte(input.Data.Data))
 				if err != nil {
 					log.Errorf("write %s error: %v", input.Data.Data, err)
@@ -230,12 +272,14 @@ func (s *WebsocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	defer func() {
+		s.Session.ClearConnection()
 		s.ws.Close()
 		s.StdinPipe.Close()
 		s.session.Close()
 		delSftpClient(s.Session.Id)
 		s.sftp.Close()
 		s.conn.Close()
+		s.Session.StopRecording()
 	}()
 
 	stop := make(chan bool)
@@ -20,6 +20,7 @@ import (
 
 	"yunion.io/x/log"
 
+	"yunion.io/x/onecloud/pkg/httperrors"
 	"yunion.io/x/onecloud/pkg/webconsole/session"
 )
 
@@ -67,6 +68,24 @@ func (server *TTYServer) initEventHandler(s *session.SSession) {
 }
 
 func initSocketHandler(so socketio.Socket, p *session.Pty) {
+	p.Session.SetConnection(&session.SSessionConnection{
+		WriteInput: func(data []byte) error {
+			if !p.IsInShellMode() {
+				return httperrors.NewInvalidStatusError("session is not in shell mode")
+			}
+			go p.Session.GetRecorder().Write(string(data), "")
+			p.Session.RecordInput(data)
+			_, err := p.Pty.Write(data)
+			return err
+		},
+		ShowMessage: func(msg string) {
+			so.Emit(OUTPUT_EVENT, msg)
+		},
+		Terminate: func() {
+			cleanUp(so, p)
+		},
+	})
+
 	// handle command output
 	go func() {
 		for !p.Exit {
@@ -110,6 +129,7 @@ func initSocketHandler(so socketio.Socket, p *session.Pty) {
 			}
 		} else {
 			p.Pty.Write([]byte(data))
+			p.Session.RecordInput([]byte(data))
 			go p.Session.GetRecorder().Write(data, "")
 		}
 	})
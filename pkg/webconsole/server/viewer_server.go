@@ -0,0 +1,136 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"yunion.io/x/log"
+
+	"yunion.io/x/onecloud/pkg/webconsole/session"
+)
+
+const viewerOutputQueueSize = 256
+
+// ViewerServer serves a user attached to the terminal session of another
+// user, output of the session is mirrored to the viewer and input of the
+// viewer is forwarded when it is allowed to co-type
+type ViewerServer struct {
+	viewer *session.SSessionViewer
+	ws     *websocket.Conn
+	outCh  chan []byte
+	once   sync.Once
+	closed chan struct{}
+}
+
+func NewViewerServer(v *session.SSessionViewer) (*ViewerServer, error) {
+	return &ViewerServer{
+		viewer: v,
+		outCh:  make(chan []byte, viewerOutputQueueSize),
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// WriteOutput never blocks the owner of the session, a viewer too slow to
+// keep up is detached
+func (s *ViewerServer) WriteOutput(data []byte) error {
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	select {
+	case <-s.closed:
+		return errors.New("viewer is closed")
+	case s.outCh <- buf:
+		return nil
+	default:
+		return errors.New("viewer output queue is full")
+	}
+}
+
+func (s *ViewerServer) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+	})
+	return nil
+}
+
+func (s *ViewerServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	up := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+	var err error
+	s.ws, err = up.Upgrade(w, r, nil)
+	if err != nil {
+		log.Errorf("viewer %s upgrade error: %v", s.viewer.Id, err)
+		return
+	}
+	defer s.ws.Close()
+
+	sess := s.viewer.Session
+	err = sess.ConnectViewer(s.viewer, s)
+	if err != nil {
+		log.Errorf("connect viewer %s to session %s error: %v", s.viewer.Id, sess.Id, err)
+		return
+	}
+	defer sess.DetachViewer(s.viewer)
+
+	go func() {
+		defer s.ws.Close()
+		for {
+			select {
+			case <-s.closed:
+				return
+			case data := <-s.outCh:
+				err := s.ws.WriteMessage(websocket.BinaryMessage, data)
+				if err != nil {
+					log.Errorf("viewer %s write error: %v", s.viewer.Id, err)
+					s.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, p, err := s.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		input, err := parseWsInput(p)
+		if err != nil {
+			log.Errorf("%v", err)
+			continue
+		}
+		switch input.Type {
+		case "close":
+			return
+		case "input":
+			err = sess.WriteViewerInput(s.viewer, []byte(input.Data.Data))
+			if err != nil {
+				log.Warningf("viewer %s input error: %v", s.viewer.Id, err)
+			}
+		default:
+			// the terminal size is decided by the owner of the session
+			continue
+		}
+	}
+}
@@ -32,6 +32,7 @@ import (
 	webconsole_api "yunion.io/x/onecloud/pkg/apis/webconsole"
 	"yunion.io/x/onecloud/pkg/appsrv"
 	"yunion.io/x/onecloud/pkg/appsrv/dispatcher"
+	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
 	"yunion.io/x/onecloud/pkg/httperrors"
@@ -54,6 +55,11 @@ const (
 )
 
 func initHandlers(app *appsrv.Application) {
+	db.InitAllManagers()
+
+	db.RegistUserCredCacheUpdater()
+	cronman.AddCronJobHandler(ApiPathPrefix, app)
+
 	app.AddHandler("POST", ApiPathPrefix+"k8s/<podName>/shell", auth.Authenticate(handleK8sShell))
 	app.AddHandler("POST", ApiPathPrefix+"climc/shell", auth.Authenticate(handleClimcShell))
 	app.AddHandler("POST", ApiPathPrefix+"k8s/<podName>/log", auth.Authenticate(handleK8sLog))
@@ -65,9 +71,28 @@ func initHandlers(app *appsrv.Application) {
 	app.AddHandler("GET", ApiPathPrefix+"sftp/<session-id>/list", server.HandleSftpList)
 	app.AddHandler("GET", ApiPathPrefix+"sftp/<session-id>/download", server.HandleSftpDownload)
 	app.AddHandler("POST", ApiPathPrefix+"sftp/<session-id>/upload", server.HandleSftpUpload)
+	app.AddHandler("GET", ApiPathPrefix+"session_recordings/<id>/download", auth.Authenticate(handleSessionRecordingDownload))
+	app.AddHandler("GET", ApiPathPrefix+"sessions", auth.Authenticate(handleListSessions))
+	app.AddHandler("POST", ApiPathPrefix+"sessions/<id>/share", auth.Authenticate(handleShareSession))
+	app.AddHandler("POST", ApiPathPrefix+"sessions/<id>/unshare", auth.Authenticate(handleUnshareSession))
+	app.AddHandler("POST", ApiPathPrefix+"sessions/<id>/attach", auth.Authenticate(handleAttachSession))
+	app.AddHandler("POST", ApiPathPrefix+"sessions/<id>/message", auth.Authenticate(handleSessionMessage))
+	app.AddHandler("POST", ApiPathPrefix+"sessions/<id>/terminate", auth.Authenticate(handleTerminateSession))
 
 	for _, man := range []db.IModelManager{
+		db.UserCacheManager,
+		db.TenantCacheManager,
+		db.RoleCacheManager,
+	} {
+		db.RegisterModelManager(man)
+	}
+
+	for _, man := range []db.IModelManager{
+		db.OpsLog,
+		db.Metadata,
+
 		models.GetCommandLogManager(),
+		models.GetSessionRecordingManager(),
 	} {
 		db.RegisterModelManager(man)
 		handler := db.NewModelHandler(man)
@@ -359,6 +384,11 @@ func handleDataSession(ctx context.Context, sData session.ISessionData, w http.R
 		httperrors.GeneralServerError(ctx, w, err)
 		return
 	}
+	// the client session of a command may be admin, the owner is whom
+	// opens the session
+	if userCred := auth.FetchUserCredential(ctx, policy.FilterPolicyCredential); userCred != nil {
+		s.SetOwner(userCred)
+	}
 	dispInfo, err := sData.GetDisplayInfo(ctx)
 	if err != nil {
 		httperrors.GeneralServerError(ctx, w, err)
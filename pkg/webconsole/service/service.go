@@ -22,6 +22,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -36,8 +37,10 @@ import (
 	"yunion.io/x/onecloud/pkg/cloudcommon/cronman"
 	"yunion.io/x/onecloud/pkg/cloudcommon/db"
 	common_options "yunion.io/x/onecloud/pkg/cloudcommon/options"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
 	"yunion.io/x/onecloud/pkg/webconsole/models"
 	o "yunion.io/x/onecloud/pkg/webconsole/options"
+	_ "yunion.io/x/onecloud/pkg/webconsole/policy"
 	"yunion.io/x/onecloud/pkg/webconsole/server"
 )
 
@@ -83,6 +86,9 @@ func start() {
 
 	db.EnsureAppSyncDB(app, dbOpts, models.InitDB)
 
+	// the recordings of the sessions closed by the restart are saved in background
+	go models.GetSessionRecordingManager().RecoverRecordings(context.Background(), auth.AdminCredential())
+
 	root := mux.NewRouter()
 	root.UseEncodedPath()
 
@@ -105,6 +111,7 @@ func start() {
 	cron := cronman.InitCronJobManager(true, o.Options.CronJobWorkerCount)
 
 	cron.AddJobEveryFewHour("AutoPurgeSplitable", 4, 30, 0, db.AutoPurgeSplitable, false)
+	cron.AddJobAtIntervals("PurgeExpiredSessionRecordings", time.Hour, models.GetSessionRecordingManager().PurgeExpiredRecordings)
 
 	cron.Start()
 	defer cron.Stop()
@@ -0,0 +1,70 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/util/streamutils"
+
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	"yunion.io/x/onecloud/pkg/webconsole/models"
+)
+
+// handleSessionRecordingDownload streams the asciicast file of a recording,
+// which can be played by asciinema directly
+func handleSessionRecordingDownload(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	params, _, _ := appsrv.FetchEnv(ctx, w, r)
+	userCred := auth.FetchUserCredential(ctx, policy.FilterPolicyCredential)
+	if userCred == nil {
+		httperrors.UnauthorizedError(ctx, w, "No token founded")
+		return
+	}
+	obj, err := db.FetchByIdOrName(ctx, models.GetSessionRecordingManager(), userCred, params["<id>"])
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	err = db.IsObjectRbacAllowed(ctx, obj, userCred, policy.PolicyActionGet, "download")
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	record := obj.(*models.SSessionRecording)
+	reader, err := record.Open(ctx)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", record.Id+".cast"))
+	if record.SizeBytes > 0 {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", record.SizeBytes))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = streamutils.StreamPipe(reader, w, false, nil)
+	if err != nil {
+		log.Errorf("download session recording %s: %v", record.Id, err)
+	}
+}
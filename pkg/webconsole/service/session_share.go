@@ -0,0 +1,279 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/pkg/gotypes"
+	"yunion.io/x/pkg/util/httputils"
+	"yunion.io/x/pkg/util/rbacscope"
+	"yunion.io/x/pkg/utils"
+
+	webconsole_api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/appsrv"
+	"yunion.io/x/onecloud/pkg/cloudcommon/consts"
+	"yunion.io/x/onecloud/pkg/cloudcommon/db"
+	"yunion.io/x/onecloud/pkg/cloudcommon/policy"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/mcclient/auth"
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+	"yunion.io/x/onecloud/pkg/webconsole/session"
+)
+
+const sessionResource = "sessions"
+
+type sessionEnv struct {
+	userCred mcclient.TokenCredential
+	session  *session.SSession
+	body     jsonutils.JSONObject
+}
+
+// fetchSessionEnv fetches the live session of the request, body is
+// unwrapped from the webconsole key like other webconsole requests
+func fetchSessionEnv(ctx context.Context, w http.ResponseWriter, r *http.Request) (*sessionEnv, error) {
+	params, _, body := appsrv.FetchEnv(ctx, w, r)
+	userCred := auth.FetchUserCredential(ctx, policy.FilterPolicyCredential)
+	if userCred == nil {
+		return nil, httperrors.NewUnauthorizedError("No token founded")
+	}
+	s, ok := session.Manager.GetConnected(params["<id>"])
+	if !ok {
+		return nil, httperrors.NewResourceNotFoundError2(sessionResource, params["<id>"])
+	}
+	if gotypes.IsNil(body) {
+		body = jsonutils.NewDict()
+	} else if body.Contains("webconsole") {
+		body, _ = body.Get("webconsole")
+	}
+	return &sessionEnv{
+		userCred: userCred,
+		session:  s,
+		body:     body,
+	}, nil
+}
+
+func isSessionAdmin(userCred mcclient.TokenCredential, action string, extra ...string) bool {
+	return policy.PolicyManager.Allow(rbacscope.ScopeSystem, userCred, consts.GetServiceType(), sessionResource, action, extra...).Result.IsAllow()
+}
+
+func validateShareMode(mode string) (string, error) {
+	if len(mode) == 0 {
+		return webconsole_api.SESSION_SHARE_MODE_READ, nil
+	}
+	if !utils.IsInStringArray(mode, []string{webconsole_api.SESSION_SHARE_MODE_READ, webconsole_api.SESSION_SHARE_MODE_WRITE}) {
+		return "", httperrors.NewInputParameterError("invalid mode %q", mode)
+	}
+	return mode, nil
+}
+
+// handleListSessions lists the live terminal sessions owned by or shared
+// with the user, all sessions are listed for admin
+func handleListSessions(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	_, query, _ := appsrv.FetchEnv(ctx, w, r)
+	userCred := auth.FetchUserCredential(ctx, policy.FilterPolicyCredential)
+	if userCred == nil {
+		httperrors.UnauthorizedError(ctx, w, "No token founded")
+		return
+	}
+	input := webconsole_api.SessionListInput{}
+	if query != nil {
+		query.Unmarshal(&input)
+	}
+	if input.Admin && !isSessionAdmin(userCred, policy.PolicyActionList) {
+		httperrors.ForbiddenError(ctx, w, "not allow to list all sessions")
+		return
+	}
+	output := webconsole_api.SessionListOutput{
+		Data: []webconsole_api.SessionDetails{},
+	}
+	for _, s := range session.Manager.ListConnected() {
+		if !input.Admin && !s.IsOwner(userCred) {
+			if _, ok := s.GetShare(userCred.GetUserId()); !ok {
+				continue
+			}
+		}
+		output.Data = append(output.Data, s.GetDetails())
+	}
+	sort.Slice(output.Data, func(i, j int) bool {
+		return output.Data[i].ConnectedAt.Before(output.Data[j].ConnectedAt)
+	})
+	output.Total = len(output.Data)
+	sendJSON(w, output.JSON(output))
+}
+
+func handleShareSession(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchSessionEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if !env.session.IsOwner(env.userCred) {
+		httperrors.ForbiddenError(ctx, w, "only the owner can share session")
+		return
+	}
+	input := webconsole_api.SessionShareInput{}
+	env.body.Unmarshal(&input)
+	input.Mode, err = validateShareMode(input.Mode)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if len(input.User) == 0 {
+		httperrors.MissingParameterError(ctx, w, "user")
+		return
+	}
+	user, err := db.UserCacheManager.FetchUserByIdOrName(ctx, input.User)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, httperrors.NewResourceNotFoundError2("user", input.User))
+		return
+	}
+	if user.Id == env.userCred.GetUserId() {
+		httperrors.InputParameterError(ctx, w, "cannot share session with self")
+		return
+	}
+	err = env.session.Share(ctx, env.userCred, user.Id, user.Name, input.Mode)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	details := env.session.GetDetails()
+	sendJSON(w, jsonutils.Marshal(details))
+}
+
+func handleUnshareSession(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchSessionEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if !env.session.IsOwner(env.userCred) {
+		httperrors.ForbiddenError(ctx, w, "only the owner can unshare session")
+		return
+	}
+	input := webconsole_api.SessionUnshareInput{}
+	env.body.Unmarshal(&input)
+	if len(input.User) == 0 {
+		httperrors.MissingParameterError(ctx, w, "user")
+		return
+	}
+	user, err := db.UserCacheManager.FetchUserByIdOrName(ctx, input.User)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, httperrors.NewResourceNotFoundError2("user", input.User))
+		return
+	}
+	env.session.Unshare(ctx, env.userCred, user.Id, user.Name)
+	details := env.session.GetDetails()
+	sendJSON(w, jsonutils.Marshal(details))
+}
+
+// handleAttachSession attaches the user to a live session, invited users
+// are limited to the mode of the invitation, admin can take over any session
+func handleAttachSession(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchSessionEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	input := webconsole_api.SessionAttachInput{}
+	env.body.Unmarshal(&input)
+	mode := input.Mode
+	if share, ok := env.session.GetShare(env.userCred.GetUserId()); ok {
+		if len(mode) == 0 || share.Mode == webconsole_api.SESSION_SHARE_MODE_READ {
+			mode = share.Mode
+		}
+	} else if !isSessionAdmin(env.userCred, policy.PolicyActionPerform, "attach") {
+		httperrors.ForbiddenError(ctx, w, "session %s is not shared with you", env.session.Id)
+		return
+	}
+	mode, err = validateShareMode(mode)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	dispInfo, err := env.session.GetDisplayInfo(ctx)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	viewer, err := env.session.Attach(ctx, env.userCred, mode)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	params, err := env.session.GetViewerConnectParams(viewer, dispInfo)
+	if err != nil {
+		env.session.DetachViewer(viewer)
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	dataVal := url.Values{}
+	dataVal.Add("data", base64.StdEncoding.EncodeToString([]byte(params)))
+	resp := webconsole_api.ServerRemoteConsoleResponse{
+		AccessUrl:     httputils.JoinPath(o.Options.ApiServer, fmt.Sprintf("web-console/ws?%s", dataVal.Encode())),
+		ConnectParams: params,
+		Session:       env.session.Id,
+	}
+	sendJSON(w, resp.JSON(resp))
+}
+
+func handleSessionMessage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchSessionEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if !env.session.IsOwner(env.userCred) && !isSessionAdmin(env.userCred, policy.PolicyActionPerform, "message") {
+		httperrors.ForbiddenError(ctx, w, "not allow to send message to session %s", env.session.Id)
+		return
+	}
+	input := webconsole_api.SessionMessageInput{}
+	env.body.Unmarshal(&input)
+	if len(input.Message) == 0 {
+		httperrors.MissingParameterError(ctx, w, "message")
+		return
+	}
+	err = env.session.SendMessage(ctx, env.userCred, input.Message)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	sendJSON(w, nil)
+}
+
+func handleTerminateSession(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	env, err := fetchSessionEnv(ctx, w, r)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	if !env.session.IsOwner(env.userCred) && !isSessionAdmin(env.userCred, policy.PolicyActionPerform, "terminate") {
+		httperrors.ForbiddenError(ctx, w, "not allow to terminate session %s", env.session.Id)
+		return
+	}
+	err = env.session.Terminate(ctx, env.userCred)
+	if err != nil {
+		httperrors.GeneralServerError(ctx, w, err)
+		return
+	}
+	sendJSON(w, nil)
+}
@@ -53,6 +53,7 @@ func NewPty(session *SSession) (p *Pty, err error) {
 			return
 		}
 	}
+	session.StartRecording(0, 0)
 	p.sizeCh = make(chan os.Signal, 1)
 	p.size = &pty.Winsize{}
 	p.startResizeMonitor()
@@ -76,6 +77,8 @@ func (p *Pty) Read() ([]byte, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "Pty.Read")
 	}
+	p.Session.RecordOutput(buf[0:n])
+	p.Session.Broadcast(buf[0:n])
 	return buf[0:n], nil
 }
 
@@ -95,6 +98,7 @@ func (p *Pty) startResizeMonitor() {
 }
 
 func (p *Pty) Resize(size *pty.Winsize) {
+	p.Session.RecordResize(int(size.Cols), int(size.Rows))
 	p.size = size
 	p.sizeCh <- syscall.SIGWINCH
 }
@@ -30,6 +30,8 @@ import (
 	"yunion.io/x/pkg/util/stringutils"
 	"yunion.io/x/pkg/utils"
 
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/mcclient"
 	"yunion.io/x/onecloud/pkg/webconsole/command"
 	o "yunion.io/x/onecloud/pkg/webconsole/options"
 	"yunion.io/x/onecloud/pkg/webconsole/recorder"
@@ -48,11 +50,14 @@ func init() {
 
 type SSessionManager struct {
 	*sync.Map
+
+	viewers *sync.Map
 }
 
 func NewSessionManager() *SSessionManager {
 	s := &SSessionManager{
-		Map: &sync.Map{},
+		Map:     &sync.Map{},
+		viewers: &sync.Map{},
 	}
 	return s
 }
@@ -162,9 +167,33 @@ type SSession struct {
 	AccessedAt    time.Time
 	duplicateHook func()
 	recorder      recorder.Recoder
+
+	sessionRecorder *recorder.SSessionRecorder
+	recordingLock   sync.Mutex
+
+	owner       mcclient.TokenCredential
+	connection  *SSessionConnection
+	connectedAt time.Time
+	shares      map[string]api.SessionShare
+	viewers     map[string]*SSessionViewer
+	backlog     []byte
+	shareLock   sync.Mutex
 }
 
 func (s *SSession) GetConnectParams(params url.Values, dispInfo *SDisplayInfo) (string, error) {
+	params, err := s.getConnectParams(params, dispInfo, s.AccessToken, s.GetProtocol())
+	if err != nil {
+		return "", err
+	}
+	isNeedLogin, err := s.IsNeedLogin()
+	if err != nil {
+		params.Set("login_error_message", fmt.Sprintf("%v", err))
+	}
+	params.Set("is_need_login", fmt.Sprintf("%v", isNeedLogin))
+	return params.Encode(), nil
+}
+
+func (s *SSession) getConnectParams(params url.Values, dispInfo *SDisplayInfo, accessToken string, protocol string) (url.Values, error) {
 	if params == nil {
 		params = url.Values{}
 	}
@@ -173,7 +202,7 @@ func (s *SSession) GetConnectParams(params url.Values, dispInfo *SDisplayInfo) (
 
 	apiUrl, err := url.Parse(o.Options.ApiServer)
 	if err != nil {
-		return "", errors.Errorf("invalid api_server url: %s", o.Options.ApiServer)
+		return nil, errors.Errorf("invalid api_server url: %s", o.Options.ApiServer)
 	}
 	schemeHost := fmt.Sprintf("%s://%s", apiUrl.Scheme, apiUrl.Host)
 	uPath := filepath.Join(strings.Split(apiUrl.Path, "/")...)
@@ -185,17 +214,14 @@ func (s *SSession) GetConnectParams(params url.Values, dispInfo *SDisplayInfo) (
 	}
 
 	params.Set("api_server", trimUrl)
-	params.Set("access_token", s.AccessToken)
-	params.Set("protocol", s.GetProtocol())
-	isNeedLogin, err := s.IsNeedLogin()
-	if err != nil {
-		params.Set("login_error_message", fmt.Sprintf("%v", err))
-	}
-	params.Set("is_need_login", fmt.Sprintf("%v", isNeedLogin))
-	return params.Encode(), nil
+	params.Set("access_token", accessToken)
+	params.Set("protocol", protocol)
+	return params, nil
 }
 
 func (s *SSession) Close() error {
+	s.ClearConnection()
+	s.StopRecording()
 	if err := s.ISessionData.Cleanup(); err != nil {
 		log.Errorf("Clean up command error: %v", err)
 	}
@@ -218,3 +244,73 @@ func (s *SSession) GetRecorder() recorder.Recoder {
 	}
 	return s.recorder
 }
+
+func (s *SSession) getRecordingObject() *recorder.Object {
+	if obj := s.GetRecordObject(); obj != nil {
+		return obj
+	}
+	name := s.GetProtocol()
+	if rs, ok := s.ISessionData.(*RandomSessionData); ok {
+		if si, ok := rs.ICommand.(ISessionCommand); ok && len(si.GetInstanceName()) > 0 {
+			name = si.GetInstanceName()
+		} else if cmd := rs.GetCommand(); cmd != nil {
+			name = filepath.Base(cmd.Path)
+		}
+	}
+	return recorder.NewObject(s.Id, name, s.GetProtocol(), "", nil)
+}
+
+// StartRecording starts the full session recording when it is enabled,
+// width and height are the initial terminal size, 0 means the default size
+func (s *SSession) StartRecording(width, height int) {
+	if !o.Options.EnableSessionRecording {
+		return
+	}
+	s.recordingLock.Lock()
+	defer s.recordingLock.Unlock()
+
+	if s.sessionRecorder != nil {
+		return
+	}
+	r, err := recorder.NewSessionRecorder(s.GetClientSession(), s.getRecordingObject(), s.Id, s.GetProtocol(), width, height)
+	if err != nil {
+		log.Errorf("start recording session %s: %v", s.Id, err)
+		return
+	}
+	s.sessionRecorder = r
+}
+
+func (s *SSession) getSessionRecorder() *recorder.SSessionRecorder {
+	s.recordingLock.Lock()
+	defer s.recordingLock.Unlock()
+
+	return s.sessionRecorder
+}
+
+func (s *SSession) RecordOutput(data []byte) {
+	if r := s.getSessionRecorder(); r != nil {
+		r.WriteOutput(data)
+	}
+}
+
+func (s *SSession) RecordInput(data []byte) {
+	if r := s.getSessionRecorder(); r != nil {
+		r.WriteInput(data)
+	}
+}
+
+func (s *SSession) RecordResize(cols, rows int) {
+	if r := s.getSessionRecorder(); r != nil {
+		r.Resize(cols, rows)
+	}
+}
+
+func (s *SSession) StopRecording() {
+	s.recordingLock.Lock()
+	defer s.recordingLock.Unlock()
+
+	if s.sessionRecorder != nil {
+		s.sessionRecorder.Close()
+		s.sessionRecorder = nil
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"yunion.io/x/jsonutils"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+	"yunion.io/x/pkg/util/stringutils"
+	"yunion.io/x/pkg/utils"
+
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	"yunion.io/x/onecloud/pkg/mcclient"
+	"yunion.io/x/onecloud/pkg/webconsole/models"
+)
+
+const (
+	viewerTokenPrefix = "viewer:"
+
+	// recent output sent to a viewer on connecting, so that the screen is
+	// not blank until the next output
+	outputBacklogSize = 64 * 1024
+
+	SESSION_ACTION_SHARE     = "share"
+	SESSION_ACTION_UNSHARE   = "unshare"
+	SESSION_ACTION_ATTACH    = "attach"
+	SESSION_ACTION_DETACH    = "detach"
+	SESSION_ACTION_MESSAGE   = "message"
+	SESSION_ACTION_TERMINATE = "terminate"
+)
+
+// SSessionConnection is registered by the server serving the owner of a
+// terminal session, it is what makes the session shareable
+type SSessionConnection struct {
+	// 写入终端输入, 并写入会话录像
+	WriteInput func(data []byte) error
+	// 在会话所有者的终端上显示消息, 不会写入终端输入
+	ShowMessage func(msg string)
+	// 强制断开会话
+	Terminate func()
+}
+
+type IViewerConn interface {
+	WriteOutput(data []byte) error
+	Close() error
+}
+
+// SSessionViewer is another user attached to a live terminal session
+type SSessionViewer struct {
+	Id          string
+	Session     *SSession
+	UserId      string
+	User        string
+	Mode        string
+	AttachedAt  time.Time
+	AccessToken string
+
+	userCred mcclient.TokenCredential
+	conn     IViewerConn
+}
+
+func (v *SSessionViewer) CanWrite() bool {
+	return v.Mode == api.SESSION_SHARE_MODE_WRITE
+}
+
+func (man *SSessionManager) GetViewer(accessToken string) (*SSessionViewer, bool) {
+	id, err := utils.DescryptAESBase64Url(AES_KEY, accessToken)
+	if err != nil || !strings.HasPrefix(id, viewerTokenPrefix) {
+		return nil, false
+	}
+	obj, ok := man.viewers.Load(strings.TrimPrefix(id, viewerTokenPrefix))
+	if !ok {
+		return nil, false
+	}
+	return obj.(*SSessionViewer), true
+}
+
+func (man *SSessionManager) GetConnected(id string) (*SSession, bool) {
+	obj, ok := man.Load(id)
+	if !ok {
+		return nil, false
+	}
+	s := obj.(*SSession)
+	if !s.IsConnected() {
+		return nil, false
+	}
+	return s, true
+}
+
+// ListConnected returns the live sessions which can be shared
+func (man *SSessionManager) ListConnected() []*SSession {
+	ret := []*SSession{}
+	man.Range(func(key, value interface{}) bool {
+		s := value.(*SSession)
+		if s.IsConnected() {
+			ret = append(ret, s)
+		}
+		return true
+	})
+	return ret
+}
+
+// SetOwner records the user who opened the session, which may differ from
+// the client session of the command, e.g. k8s shells run as admin
+func (s *SSession) SetOwner(userCred mcclient.TokenCredential) {
+	s.owner = userCred
+}
+
+func (s *SSession) GetOwner() mcclient.TokenCredential {
+	if s.owner != nil {
+		return s.owner
+	}
+	return s.GetClientSession().GetToken()
+}
+
+func (s *SSession) IsOwner(userCred mcclient.TokenCredential) bool {
+	return s.GetOwner().GetUserId() == userCred.GetUserId()
+}
+
+func (s *SSession) SetConnection(conn *SSessionConnection) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	s.connection = conn
+	s.connectedAt = time.Now()
+}
+
+// ClearConnection is called when the owner disconnects, all viewers are
+// detached and pending invitations are dropped
+func (s *SSession) ClearConnection() {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	if s.connection == nil {
+		return
+	}
+	s.connection = nil
+	for id, v := range s.viewers {
+		Manager.viewers.Delete(id)
+		if v.conn != nil {
+			v.conn.Close()
+		}
+	}
+	s.viewers = nil
+	s.shares = nil
+	s.backlog = nil
+}
+
+func (s *SSession) IsConnected() bool {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	return s.connection != nil
+}
+
+// Broadcast sends the terminal output to all connected viewers
+func (s *SSession) Broadcast(data []byte) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	if s.connection == nil {
+		return
+	}
+	s.backlog = append(s.backlog, data...)
+	if len(s.backlog) > outputBacklogSize {
+		s.backlog = append([]byte{}, s.backlog[len(s.backlog)-outputBacklogSize:]...)
+	}
+	for id, v := range s.viewers {
+		if v.conn == nil {
+			continue
+		}
+		if err := v.conn.WriteOutput(data); err != nil {
+			log.Warningf("session %s viewer %s: %v, detach it", s.Id, id, err)
+			v.conn.Close()
+			v.conn = nil
+		}
+	}
+}
+
+func (s *SSession) getConnection() (*SSessionConnection, error) {
+	if s.connection == nil {
+		return nil, httperrors.NewInvalidStatusError("session %s is not connected", s.Id)
+	}
+	return s.connection, nil
+}
+
+// Share invites a user to attach the session in mode
+func (s *SSession) Share(ctx context.Context, userCred mcclient.TokenCredential, userId, userName, mode string) error {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	if _, err := s.getConnection(); err != nil {
+		return err
+	}
+	if s.shares == nil {
+		s.shares = map[string]api.SessionShare{}
+	}
+	s.shares[userId] = api.SessionShare{
+		UserId:    userId,
+		User:      userName,
+		Mode:      mode,
+		CreatedAt: time.Now(),
+	}
+	s.audit(ctx, userCred, SESSION_ACTION_SHARE, fmt.Sprintf("share with %s (%s)", userName, mode))
+	return nil
+}
+
+// Unshare revokes the invitation of a user and detaches the viewers of the user
+func (s *SSession) Unshare(ctx context.Context, userCred mcclient.TokenCredential, userId, userName string) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	delete(s.shares, userId)
+	for id, v := range s.viewers {
+		if v.UserId != userId {
+			continue
+		}
+		Manager.viewers.Delete(id)
+		delete(s.viewers, id)
+		if v.conn != nil {
+			v.conn.Close()
+		}
+	}
+	s.audit(ctx, userCred, SESSION_ACTION_UNSHARE, fmt.Sprintf("unshare with %s", userName))
+}
+
+func (s *SSession) GetShare(userId string) (api.SessionShare, bool) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	share, ok := s.shares[userId]
+	return share, ok
+}
+
+// Attach creates a viewer of the session, the viewer connects with its own
+// access token
+func (s *SSession) Attach(ctx context.Context, userCred mcclient.TokenCredential, mode string) (*SSessionViewer, error) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	if _, err := s.getConnection(); err != nil {
+		return nil, err
+	}
+	v := &SSessionViewer{
+		Id:         stringutils.UUID4(),
+		Session:    s,
+		UserId:     userCred.GetUserId(),
+		User:       userCred.GetUserName(),
+		Mode:       mode,
+		AttachedAt: time.Now(),
+		userCred:   userCred,
+	}
+	token, err := utils.EncryptAESBase64Url(AES_KEY, viewerTokenPrefix+v.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "EncryptAESBase64Url")
+	}
+	v.AccessToken = token
+	if s.viewers == nil {
+		s.viewers = map[string]*SSessionViewer{}
+	}
+	s.viewers[v.Id] = v
+	Manager.viewers.Store(v.Id, v)
+	s.audit(ctx, userCred, SESSION_ACTION_ATTACH, fmt.Sprintf("attach (%s)", mode))
+	return v, nil
+}
+
+// ConnectViewer binds the websocket connection of a viewer, a viewer
+// token can only be connected once
+func (s *SSession) ConnectViewer(v *SSessionViewer, conn IViewerConn) error {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	if _, err := s.getConnection(); err != nil {
+		return err
+	}
+	if _, ok := s.viewers[v.Id]; !ok {
+		return httperrors.NewNotFoundError("viewer %s is detached", v.Id)
+	}
+	if v.conn != nil {
+		return httperrors.NewConflictError("viewer %s is already connected", v.Id)
+	}
+	if len(s.backlog) > 0 {
+		if err := conn.WriteOutput(s.backlog); err != nil {
+			return errors.Wrap(err, "write backlog")
+		}
+	}
+	v.conn = conn
+	return nil
+}
+
+func (s *SSession) DetachViewer(v *SSessionViewer) {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	Manager.viewers.Delete(v.Id)
+	if _, ok := s.viewers[v.Id]; !ok {
+		return
+	}
+	delete(s.viewers, v.Id)
+	if v.conn != nil {
+		v.conn.Close()
+		v.conn = nil
+	}
+	s.audit(context.Background(), v.userCred, SESSION_ACTION_DETACH, "detach")
+}
+
+func (s *SSession) WriteViewerInput(v *SSessionViewer, data []byte) error {
+	if !v.CanWrite() {
+		return httperrors.NewForbiddenError("viewer %s is read only", v.Id)
+	}
+	s.shareLock.Lock()
+	conn, err := s.getConnection()
+	s.shareLock.Unlock()
+	if err != nil {
+		return err
+	}
+	// the input is recorded by the connection
+	return conn.WriteInput(data)
+}
+
+func formatSessionMessage(from, msg string) string {
+	return fmt.Sprintf("\r\n\x1b[1;33m[%s] %s\x1b[0m\r\n", from, msg)
+}
+
+// SendMessage shows a message on the terminals of the owner and all viewers
+func (s *SSession) SendMessage(ctx context.Context, userCred mcclient.TokenCredential, msg string) error {
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	conn, err := s.getConnection()
+	if err != nil {
+		return err
+	}
+	text := formatSessionMessage(userCred.GetUserName(), msg)
+	conn.ShowMessage(text)
+	for _, v := range s.viewers {
+		if v.conn != nil {
+			v.conn.WriteOutput([]byte(text))
+		}
+	}
+	s.audit(ctx, userCred, SESSION_ACTION_MESSAGE, msg)
+	return nil
+}
+
+// Terminate forcibly disconnects the owner and all viewers of the session
+func (s *SSession) Terminate(ctx context.Context, userCred mcclient.TokenCredential) error {
+	s.shareLock.Lock()
+	conn, err := s.getConnection()
+	if err == nil {
+		s.audit(ctx, userCred, SESSION_ACTION_TERMINATE, "terminate")
+		conn.ShowMessage(formatSessionMessage(userCred.GetUserName(), "session is terminated"))
+	}
+	s.shareLock.Unlock()
+	if err != nil {
+		return err
+	}
+	// terminating closes the session, which takes the share lock
+	conn.Terminate()
+	s.ClearConnection()
+	return nil
+}
+
+func (s *SSession) GetDetails() api.SessionDetails {
+	obj := s.getRecordingObject()
+	owner := s.GetOwner()
+	s.shareLock.Lock()
+	defer s.shareLock.Unlock()
+
+	ret := api.SessionDetails{
+		Id:          s.Id,
+		Protocol:    s.GetProtocol(),
+		ObjId:       obj.Id,
+		ObjType:     obj.Type,
+		ObjName:     obj.Name,
+		LoginUser:   obj.LoginUser,
+		UserId:      owner.GetUserId(),
+		User:        owner.GetUserName(),
+		TenantId:    owner.GetProjectId(),
+		Tenant:      owner.GetProjectName(),
+		ConnectedAt: s.connectedAt,
+		Shares:      []api.SessionShare{},
+		Viewers:     []api.SessionViewer{},
+	}
+	for _, share := range s.shares {
+		ret.Shares = append(ret.Shares, share)
+	}
+	for _, v := range s.viewers {
+		ret.Viewers = append(ret.Viewers, api.SessionViewer{
+			Id:         v.Id,
+			UserId:     v.UserId,
+			User:       v.User,
+			Mode:       v.Mode,
+			AttachedAt: v.AttachedAt,
+			Connected:  v.conn != nil,
+		})
+	}
+	return ret
+}
+
+// GetViewerConnectParams returns the connect params of a viewer, viewers of
+// both tty and ssh sessions use the websocket terminal
+func (s *SSession) GetViewerConnectParams(v *SSessionViewer, dispInfo *SDisplayInfo) (string, error) {
+	params, err := s.getConnectParams(url.Values{}, dispInfo, v.AccessToken, api.WS)
+	if err != nil {
+		return "", err
+	}
+	params.Set("is_need_login", "false")
+	params.Set("read_only", fmt.Sprintf("%v", !v.CanWrite()))
+	return params.Encode(), nil
+}
+
+// audit saves the sharing action into the command log
+func (s *SSession) audit(ctx context.Context, userCred mcclient.TokenCredential, action string, command string) {
+	obj := s.getRecordingObject()
+	notes := obj.Notes
+	if notes == nil {
+		notes = jsonutils.NewDict()
+	}
+	input := &models.CommandLogCreateInput{
+		ObjId:           obj.Id,
+		ObjName:         obj.Name,
+		ObjType:         obj.Type,
+		Notes:           notes,
+		Action:          action,
+		UserId:          userCred.GetUserId(),
+		User:            userCred.GetUserName(),
+		TenantId:        userCred.GetTenantId(),
+		Tenant:          userCred.GetTenantName(),
+		DomainId:        userCred.GetDomainId(),
+		Domain:          userCred.GetDomainName(),
+		ProjectDomainId: userCred.GetProjectDomainId(),
+		ProjectDomain:   userCred.GetProjectDomain(),
+		Roles:           strings.Join(userCred.GetRoles(), ","),
+		SessionId:       s.Id,
+		AccessedAt:      s.AccessedAt,
+		LoginUser:       obj.LoginUser,
+		Type:            models.CommandTypeSession,
+		StartTime:       time.Now(),
+		Command:         command,
+	}
+	_, err := models.GetCommandLogManager().Create(ctx, userCred, input)
+	if err != nil {
+		log.Errorf("audit session %s %s: %v", s.Id, action, err)
+	}
+}
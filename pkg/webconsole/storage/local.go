@@ -0,0 +1,91 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+)
+
+type sLocalStorage struct {
+	dir string
+}
+
+func newLocalStorage() *sLocalStorage {
+	return &sLocalStorage{
+		dir: o.Options.SessionRecordingDir,
+	}
+}
+
+func (s *sLocalStorage) GetBackend() string {
+	return api.SESSION_RECORDING_BACKEND_LOCAL
+}
+
+func (s *sLocalStorage) getPath(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", errors.Wrapf(httperrors.ErrInputParameter, "invalid recording key %q", key)
+	}
+	return path, nil
+}
+
+func (s *sLocalStorage) Save(ctx context.Context, srcFilename string, key string) error {
+	path, err := s.getPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "mkdir %s", filepath.Dir(path))
+	}
+	if err := os.Rename(srcFilename, path); err != nil {
+		return errors.Wrapf(err, "rename %s to %s", srcFilename, path)
+	}
+	return nil
+}
+
+func (s *sLocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.getPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(errors.ErrNotFound, "recording %s", key)
+		}
+		return nil, errors.Wrapf(err, "open %s", path)
+	}
+	return f, nil
+}
+
+func (s *sLocalStorage) Remove(ctx context.Context, key string) error {
+	path, err := s.getPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"yunion.io/x/cloudmux/pkg/cloudprovider"
+	"yunion.io/x/cloudmux/pkg/multicloud/objectstore"
+	"yunion.io/x/log"
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/httperrors"
+	o "yunion.io/x/onecloud/pkg/webconsole/options"
+)
+
+const recordingPathPrefix = "session-recordings"
+
+type sObjectStorage struct {
+	bucket string
+	store  *objectstore.SObjectStoreClient
+}
+
+func objectStorageCacheKey() string {
+	return strings.Join([]string{o.Options.SessionRecordingBucketUrl, o.Options.SessionRecordingAccessKey, o.Options.SessionRecordingSecret}, "|")
+}
+
+func newObjectStorage() (*sObjectStorage, error) {
+	if len(o.Options.SessionRecordingBucketUrl) == 0 {
+		return nil, errors.Wrap(httperrors.ErrInputParameter, "session_recording_bucket_url is not set")
+	}
+	if len(o.Options.SessionRecordingAccessKey) == 0 || len(o.Options.SessionRecordingSecret) == 0 {
+		return nil, errors.Wrap(httperrors.ErrInputParameter, "session_recording_access_key or session_recording_secret is not set")
+	}
+	bucket, endpoint, err := parseBucketUrl(o.Options.SessionRecordingBucketUrl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parseBucketUrl %s", o.Options.SessionRecordingBucketUrl)
+	}
+	cfg := objectstore.NewObjectStoreClientConfig(endpoint, o.Options.SessionRecordingAccessKey, o.Options.SessionRecordingSecret)
+	store, err := objectstore.NewObjectStoreClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewObjectStoreClient")
+	}
+	return &sObjectStorage{
+		bucket: bucket,
+		store:  store,
+	}, nil
+}
+
+func parseBucketUrl(bucketUrl string) (string, string, error) {
+	bu, err := url.Parse(bucketUrl)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "url.Parse %s", bucketUrl)
+	}
+	path := strings.Trim(bu.Path, "/")
+	if len(path) > 0 {
+		return path, fmt.Sprintf("%s://%s", bu.Scheme, bu.Host), nil
+	}
+	parts := strings.SplitN(bu.Host, ".", 2)
+	if len(parts) < 2 || !strings.Contains(parts[1], ".") {
+		return "", "", errors.Wrapf(errors.ErrInvalidFormat, "host %s should have at least 3 segments", bu.Host)
+	}
+	return parts[0], fmt.Sprintf("%s://%s", bu.Scheme, parts[1]), nil
+}
+
+func (s *sObjectStorage) GetBackend() string {
+	return api.SESSION_RECORDING_BACKEND_OBJECT
+}
+
+func (s *sObjectStorage) getKey(key string) string {
+	return fmt.Sprintf("%s/%s", recordingPathPrefix, key)
+}
+
+func (s *sObjectStorage) getBucket() (cloudprovider.ICloudBucket, error) {
+	bucket, err := s.store.GetIRegion().GetIBucketByName(s.bucket)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetIBucketByName %s", s.bucket)
+	}
+	return bucket, nil
+}
+
+func (s *sObjectStorage) Save(ctx context.Context, srcFilename string, key string) error {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return errors.Wrap(err, "getBucket")
+	}
+	fileInfo, err := os.Stat(srcFilename)
+	if err != nil {
+		return errors.Wrapf(err, "stat %s", srcFilename)
+	}
+	file, err := os.Open(srcFilename)
+	if err != nil {
+		return errors.Wrapf(err, "open %s", srcFilename)
+	}
+	defer file.Close()
+
+	err = cloudprovider.UploadObject(ctx, bucket, s.getKey(key), 0, file, fileInfo.Size(), cloudprovider.ACLPrivate, "", nil, false)
+	if err != nil {
+		return errors.Wrapf(err, "UploadObject %s", s.getKey(key))
+	}
+	if err := os.Remove(srcFilename); err != nil {
+		log.Warningf("remove spooled recording %s: %v", srcFilename, err)
+	}
+	return nil
+}
+
+func (s *sObjectStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return nil, errors.Wrap(err, "getBucket")
+	}
+	reader, err := bucket.GetObject(ctx, s.getKey(key), nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetObject %s", s.getKey(key))
+	}
+	return reader, nil
+}
+
+func (s *sObjectStorage) Remove(ctx context.Context, key string) error {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return errors.Wrap(err, "getBucket")
+	}
+	err = bucket.DeleteObject(ctx, s.getKey(key))
+	if err != nil {
+		return errors.Wrapf(err, "DeleteObject %s", s.getKey(key))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import "testing"
+
+func TestParseBucketUrl(t *testing.T) {
+	for _, c := range []struct {
+		url      string
+		bucket   string
+		endpoint string
+		wantErr  bool
+	}{
+		{"https://oss.example.com/recordings", "recordings", "https://oss.example.com", false},
+		{"https://oss.example.com/recordings/", "recordings", "https://oss.example.com", false},
+		{"https://recordings.oss.example.com", "recordings", "https://oss.example.com", false},
+		{"http://example.com", "", "", true},
+	} {
+		bucket, endpoint, err := parseBucketUrl(c.url)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseBucketUrl(%s) should fail", c.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBucketUrl(%s): %v", c.url, err)
+			continue
+		}
+		if bucket != c.bucket || endpoint != c.endpoint {
+			t.Errorf("parseBucketUrl(%s) = %s %s, want %s %s", c.url, bucket, endpoint, c.bucket, c.endpoint)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Yunion
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"yunion.io/x/pkg/errors"
+
+	api "yunion.io/x/onecloud/pkg/apis/webconsole"
+	"yunion.io/x/onecloud/pkg/httperrors"
+)
+
+// IRecordingStorage stores finished session recordings, recordings are
+// always written to a local spool file first and saved when the session ends
+type IRecordingStorage interface {
+	GetBackend() string
+	// 将本地录像文件保存到存储的key路径下，成功后本地文件不再保留
+	Save(ctx context.Context, srcFilename string, key string) error
+	// 读取录像文件
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// 删除录像文件
+	Remove(ctx context.Context, key string) error
+}
+
+var (
+	storages    = map[string]IRecordingStorage{}
+	storageLock = &sync.Mutex{}
+)
+
+// GetStorage returns the storage of backend, object storage clients are
+// cached by their access info and rebuilt when the options change
+func GetStorage(backend string) (IRecordingStorage, error) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+
+	switch backend {
+	case api.SESSION_RECORDING_BACKEND_LOCAL:
+		return newLocalStorage(), nil
+	case api.SESSION_RECORDING_BACKEND_OBJECT:
+		key := objectStorageCacheKey()
+		if s, ok := storages[key]; ok {
+			return s, nil
+		}
+		s, err := newObjectStorage()
+		if err != nil {
+			return nil, errors.Wrap(err, "newObjectStorage")
+		}
+		storages = map[string]IRecordingStorage{key: s}
+		return s, nil
+	default:
+		return nil, errors.Wrapf(httperrors.ErrNotSupported, "session recording backend %q", backend)
+	}
+}